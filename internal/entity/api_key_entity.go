@@ -0,0 +1,67 @@
+package entity
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKeyScope grants an API key access to one kind of resource, written as resource:read or resource:write
+type APIKeyScope string
+
+const (
+	APIKeyScopeVideosRead          APIKeyScope = "videos:read"
+	APIKeyScopeVideosWrite         APIKeyScope = "videos:write"
+	APIKeyScopeTranscriptionsRead  APIKeyScope = "transcriptions:read"
+	APIKeyScopeTranscriptionsWrite APIKeyScope = "transcriptions:write"
+	APIKeyScopeAudiosRead          APIKeyScope = "audios:read"
+	APIKeyScopeAudiosWrite         APIKeyScope = "audios:write"
+)
+
+// APIKeyScopes lists every scope an API key may be created with
+var APIKeyScopes = []APIKeyScope{
+	APIKeyScopeVideosRead, APIKeyScopeVideosWrite,
+	APIKeyScopeTranscriptionsRead, APIKeyScopeTranscriptionsWrite,
+	APIKeyScopeAudiosRead, APIKeyScopeAudiosWrite,
+}
+
+// IsValidAPIKeyScope reports whether the scope is one of the known scopes
+func IsValidAPIKeyScope(scope APIKeyScope) bool {
+	for _, known := range APIKeyScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey lets scripts act as the user who created it, limited to its scopes. Only the hash of the key is stored.
+type APIKey struct {
+	ID         uint64        `json:"id"`
+	UserID     uint64        `json:"user_id"`
+	Name       string        `json:"name"`
+	Prefix     string        `json:"prefix"` // First characters of the key, to tell keys apart
+	KeyHash    string        `json:"-"`
+	Scopes     []APIKeyScope `json:"scopes"`
+	ExpiresAt  *time.Time    `json:"expires_at,omitempty"` // Nil for keys that do not expire
+	LastUsedAt *time.Time    `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time    `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// HasScope reports whether the key grants the scope. A write scope also grants reading the same resource.
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope {
+			return true
+		}
+		if strings.HasSuffix(string(granted), ":write") && string(scope) == strings.TrimSuffix(string(granted), ":write")+":read" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive reports whether the key was not revoked and has not expired
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
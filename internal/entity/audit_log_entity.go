@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// AuditAction is a user-management action taken through the admin API
+type AuditAction string
+
+const (
+	AuditActionUserSuspend       AuditAction = "user.suspend"
+	AuditActionUserUnsuspend     AuditAction = "user.unsuspend"
+	AuditActionUserPasswordReset AuditAction = "user.password_reset"
+	AuditActionUserRoleChange    AuditAction = "user.role_change"
+	AuditActionUserRestore       AuditAction = "user.restore"
+	AuditActionUserUnlock        AuditAction = "user.unlock"
+)
+
+// AuditLog records who took an admin action, on which user and why
+type AuditLog struct {
+	ID           uint64      `json:"id"`
+	ActorID      uint64      `json:"actor_id"` // User who took the action
+	Action       AuditAction `json:"action"`
+	TargetUserID uint64      `json:"target_user_id"`
+	Reason       string      `json:"reason"`
+	Details      string      `json:"details,omitempty"` // What changed, e.g. "User -> Moderator"
+	CreatedAt    time.Time   `json:"created_at"`
+}
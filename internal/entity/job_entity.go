@@ -0,0 +1,45 @@
+package entity
+
+import "time"
+
+// JobType selects the handler that processes a job
+type JobType string
+
+const (
+	JobTypeProcessVideo    JobType = "video.process"    // Moves a finalized video from raw through processing to success or failed
+	JobTypeAdvancePipeline JobType = "pipeline.advance" // Queues the steps of a pipeline whose dependencies finished
+)
+
+// JobStatus is the state of a job in the queue
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"    // Waiting for run_at, including jobs scheduled for a retry
+	JobStatusRunning   JobStatus = "running"   // Leased by a worker until leased_until
+	JobStatusSucceeded JobStatus = "succeeded" // Completed by a worker
+	JobStatusDead      JobStatus = "dead"      // Dead-lettered after a permanent failure or too many attempts
+)
+
+// Job is a unit of background work stored in the jobs table
+type Job struct {
+	ID          uint64     `json:"id"`
+	Type        JobType    `json:"type"`
+	Payload     string     `json:"payload"` // JSON encoded arguments of the job
+	Status      JobStatus  `json:"status"`
+	SourceLang  string     `json:"source_lang"`  // Language the worker must support, empty for any
+	TargetLang  string     `json:"target_lang"`  // Second language the worker must support, empty for any
+	Progress    int        `json:"progress"`     // Percent done as reported by the worker holding the lease
+	Attempts    int        `json:"attempts"`     // Number of times the job has been leased
+	MaxAttempts int        `json:"max_attempts"` // Attempts allowed before the job is dead-lettered
+	RunAt       time.Time  `json:"run_at"`       // Earliest time the job may be leased
+	LeaseOwner  string     `json:"lease_owner"`  // Worker holding the lease while running
+	LeasedUntil *time.Time `json:"leased_until"` // Lease expiry, after which another worker may take the job
+	LastError   string     `json:"last_error"`   // Error of the last failed attempt
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// ProcessVideoPayload is the payload of a JobTypeProcessVideo job
+type ProcessVideoPayload struct {
+	VideoID uint64 `json:"video_id"`
+}
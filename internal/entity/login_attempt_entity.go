@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+// LoginAttempt counts the failed logins for an email address or a client IP
+type LoginAttempt struct {
+	Key           string // "email:<address>" or "ip:<address>"
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time // Logins are refused until then
+}
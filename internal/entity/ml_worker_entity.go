@@ -0,0 +1,48 @@
+package entity
+
+import "time"
+
+// MLTaskType is a kind of work done by the external ML workers
+type MLTaskType string
+
+const (
+	MLTaskSpeechToText MLTaskType = "stt" // Transcribes the audio track of a video
+	MLTaskTranslation  MLTaskType = "mt"  // Translates a transcription into the target language
+	MLTaskTextToSpeech MLTaskType = "tts" // Synthesizes speech from a transcription
+	MLTaskMux          MLTaskType = "mux" // Replaces the audio track of a video with a synthesized audio
+)
+
+// JobType is the job type ML tasks of this kind are queued with
+func (t MLTaskType) JobType() JobType {
+	return JobType("ml." + string(t))
+}
+
+// IsValid reports whether t is a known task type
+func (t MLTaskType) IsValid() bool {
+	return t == MLTaskSpeechToText || t == MLTaskTranslation || t == MLTaskTextToSpeech || t == MLTaskMux
+}
+
+// MLWorker is an external process running ML models that pulls tasks from the backend
+type MLWorker struct {
+	ID         uint64       `json:"id"`
+	Name       string       `json:"name"`
+	TokenHash  string       `json:"-"`          // SHA-256 of the worker token, the token itself is only returned at registration
+	TaskTypes  []MLTaskType `json:"task_types"` // Kinds of task the worker can run
+	Languages  []string     `json:"languages"`  // Languages the worker supports (e.g., "en", "vi")
+	LastSeenAt *time.Time   `json:"last_seen_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// MLTaskPayload is the payload of the jobs behind ML tasks
+type MLTaskPayload struct {
+	VideoID         uint64 `json:"video_id"`
+	SourceLang      string `json:"source_lang"`
+	TargetLang      string `json:"target_lang,omitempty"`
+	TranscriptionID uint64 `json:"transcription_id,omitempty"` // Input transcription of mt and tts tasks
+	AudioID         uint64 `json:"audio_id,omitempty"`         // Input audio of mux tasks
+	PipelineStepID  uint64 `json:"pipeline_step_id,omitempty"` // Step of the pipeline the task runs for, if any
+	Folder          string `json:"folder"`                     // Where the worker uploads the result file
+	FileName        string `json:"file_name"`
+	ContentType     string `json:"content_type"`
+}
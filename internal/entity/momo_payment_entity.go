@@ -4,44 +4,262 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
 )
 
-type MoMoRequest struct {
-	PartnerCode string
-	AccessKey   string
-	RequestID   string
-	Amount      string
-	OrderID     string
-	Signature   string
-}
-
-// NewMoMoRequest is a factory function to create a MoMoRequest with the necessary fields
-func NewMoMoRequest(partnerCode, accessKey, requestID, amount, orderID string) *MoMoRequest {
-	return &MoMoRequest{
-		PartnerCode: partnerCode,
-		AccessKey:   accessKey,
-		RequestID:   requestID,
-		Amount:      amount,
-		OrderID:     orderID,
+// MoMoResultCode is the resultCode MoMo answers every request with
+type MoMoResultCode int
+
+const (
+	MoMoResultSuccess            MoMoResultCode = 0    // Paid, or the request succeeded
+	MoMoResultAuthorized         MoMoResultCode = 9000 // Authorized, waiting to be captured
+	MoMoResultPending            MoMoResultCode = 1000 // Created, waiting for the user to pay
+	MoMoResultProcessing         MoMoResultCode = 7000 // Being processed
+	MoMoResultProcessingProvider MoMoResultCode = 7002 // Being processed by the payment provider
+	MoMoResultInsufficientFunds  MoMoResultCode = 1001
+	MoMoResultRejectedByIssuer   MoMoResultCode = 1002
+	MoMoResultCancelled          MoMoResultCode = 1003
+	MoMoResultExpired            MoMoResultCode = 1005 // The payment URL or QR code expired
+	MoMoResultDeniedByUser       MoMoResultCode = 1006
+	MoMoResultRefundRejected     MoMoResultCode = 1081
+	MoMoResultAccessDenied       MoMoResultCode = 11
+	MoMoResultAuthFailed         MoMoResultCode = 13 // Unknown partner or wrong signature
+	MoMoResultBadFormat          MoMoResultCode = 20
+	MoMoResultInvalidAmount      MoMoResultCode = 21
+	MoMoResultDuplicateOrderID   MoMoResultCode = 41
+	MoMoResultOrderNotFound      MoMoResultCode = 42
+	MoMoResultUnknownError       MoMoResultCode = 99
+)
+
+// IsSuccess reports whether the payment was made or the request succeeded
+func (c MoMoResultCode) IsSuccess() bool {
+	return c == MoMoResultSuccess || c == MoMoResultAuthorized
+}
+
+// IsPending reports whether the payment may still succeed
+func (c MoMoResultCode) IsPending() bool {
+	return c == MoMoResultPending || c == MoMoResultProcessing || c == MoMoResultProcessingProvider
+}
+
+// IsRequestError reports whether MoMo rejected the request itself, e.g. for a wrong signature or an unknown
+// order, rather than answering with the status of a payment
+func (c MoMoResultCode) IsRequestError() bool {
+	return c != MoMoResultSuccess && c < MoMoResultPending
+}
+
+// PaymentState returns the state of a payment with the result code
+func (c MoMoResultCode) PaymentState() PaymentState {
+	switch {
+	case c.IsSuccess():
+		return PaymentStatePaid
+	case c.IsPending():
+		return PaymentStatePending
+	default:
+		return PaymentStateFailed
 	}
 }
 
-// GenerateSignature generates an HMAC SHA256 signature for the MoMo payment
-func (r *MoMoRequest) GenerateSignature(secrectKey string) {
-	rawSignature := r.PartnerCode + r.RequestID + r.Amount + r.AccessKey
-	h := hmac.New(sha256.New, []byte(secrectKey))
+// MoMoError is a request MoMo answered with a result code other than success
+type MoMoError struct {
+	ResultCode MoMoResultCode
+	Message    string
+}
+
+func (e *MoMoError) Error() string {
+	return fmt.Sprintf("momo: result code %d: %s", e.ResultCode, e.Message)
+}
+
+func (e *MoMoError) ProviderMessage() string {
+	return e.Message
+}
+
+// MoMoCreateRequest asks MoMo for a payment URL (POST /v2/gateway/api/create)
+type MoMoCreateRequest struct {
+	PartnerCode string `json:"partnerCode"`
+	RequestID   string `json:"requestId"`
+	Amount      int64  `json:"amount"` // In VND
+	OrderID     string `json:"orderId"`
+	OrderInfo   string `json:"orderInfo"`
+	RedirectURL string `json:"redirectUrl"` // Where MoMo sends the user after paying
+	IPNURL      string `json:"ipnUrl"`      // Where MoMo notifies this server of the result
+	RequestType string `json:"requestType"` // e.g. captureWallet or payWithATM
+	ExtraData   string `json:"extraData"`   // Base64, returned as is in the notification
+	Lang        string `json:"lang"`        // vi or en
+	Signature   string `json:"signature"`
+}
+
+// RawSignature returns the signed fields in the order MoMo documents
+func (r *MoMoCreateRequest) RawSignature(accessKey string) string {
+	return "accessKey=" + accessKey +
+		"&amount=" + strconv.FormatInt(r.Amount, 10) +
+		"&extraData=" + r.ExtraData +
+		"&ipnUrl=" + r.IPNURL +
+		"&orderId=" + r.OrderID +
+		"&orderInfo=" + r.OrderInfo +
+		"&partnerCode=" + r.PartnerCode +
+		"&redirectUrl=" + r.RedirectURL +
+		"&requestId=" + r.RequestID +
+		"&requestType=" + r.RequestType
+}
+
+// Sign sets the signature of the request
+func (r *MoMoCreateRequest) Sign(accessKey, secretKey string) {
+	r.Signature = SignMoMo(secretKey, r.RawSignature(accessKey))
+}
+
+// MoMoCreateResponse is the answer to a MoMoCreateRequest
+type MoMoCreateResponse struct {
+	PartnerCode  string         `json:"partnerCode"`
+	RequestID    string         `json:"requestId"`
+	OrderID      string         `json:"orderId"`
+	Amount       int64          `json:"amount"`
+	ResponseTime int64          `json:"responseTime"` // Unix milliseconds
+	Message      string         `json:"message"`
+	ResultCode   MoMoResultCode `json:"resultCode"`
+	PayURL       string         `json:"payUrl"`    // Payment page to open in a browser
+	Deeplink     string         `json:"deeplink"`  // Opens the MoMo app on a phone
+	QRCodeURL    string         `json:"qrCodeUrl"` // Data for a QR code scanned with the MoMo app
+}
+
+// MoMoQueryRequest asks MoMo for the status of a payment (POST /v2/gateway/api/query)
+type MoMoQueryRequest struct {
+	PartnerCode string `json:"partnerCode"`
+	RequestID   string `json:"requestId"`
+	OrderID     string `json:"orderId"`
+	Lang        string `json:"lang"`
+	Signature   string `json:"signature"`
+}
+
+// RawSignature returns the signed fields in the order MoMo documents
+func (r *MoMoQueryRequest) RawSignature(accessKey string) string {
+	return "accessKey=" + accessKey +
+		"&orderId=" + r.OrderID +
+		"&partnerCode=" + r.PartnerCode +
+		"&requestId=" + r.RequestID
+}
+
+// Sign sets the signature of the request
+func (r *MoMoQueryRequest) Sign(accessKey, secretKey string) {
+	r.Signature = SignMoMo(secretKey, r.RawSignature(accessKey))
+}
+
+// MoMoRefundTrans is a refund listed in a MoMoQueryResponse
+type MoMoRefundTrans struct {
+	OrderID      string         `json:"orderId"`
+	Amount       int64          `json:"amount"`
+	ResultCode   MoMoResultCode `json:"resultCode"`
+	TransID      int64          `json:"transId"`
+	CreatedTime  int64          `json:"createdTime"`
+	Description  string         `json:"description"`
+	ResponseTime int64          `json:"responseTime"`
+}
+
+// MoMoQueryResponse is the answer to a MoMoQueryRequest. ResultCode is the status of the payment.
+type MoMoQueryResponse struct {
+	PartnerCode  string            `json:"partnerCode"`
+	RequestID    string            `json:"requestId"`
+	OrderID      string            `json:"orderId"`
+	ExtraData    string            `json:"extraData"`
+	Amount       int64             `json:"amount"`
+	TransID      int64             `json:"transId"` // MoMo's ID of the payment, needed to refund it
+	PayType      string            `json:"payType"`
+	ResultCode   MoMoResultCode    `json:"resultCode"`
+	RefundTrans  []MoMoRefundTrans `json:"refundTrans"`
+	Message      string            `json:"message"`
+	ResponseTime int64             `json:"responseTime"`
+	LastUpdated  int64             `json:"lastUpdated"`
+}
+
+// MoMoRefundRequest refunds all or part of a payment (POST /v2/gateway/api/refund)
+type MoMoRefundRequest struct {
+	PartnerCode string `json:"partnerCode"`
+	OrderID     string `json:"orderId"` // New ID for the refund, not the ID of the payment
+	RequestID   string `json:"requestId"`
+	Amount      int64  `json:"amount"`
+	TransID     int64  `json:"transId"` // MoMo's ID of the payment
+	Lang        string `json:"lang"`
+	Description string `json:"description"`
+	Signature   string `json:"signature"`
+}
+
+// RawSignature returns the signed fields in the order MoMo documents
+func (r *MoMoRefundRequest) RawSignature(accessKey string) string {
+	return "accessKey=" + accessKey +
+		"&amount=" + strconv.FormatInt(r.Amount, 10) +
+		"&description=" + r.Description +
+		"&orderId=" + r.OrderID +
+		"&partnerCode=" + r.PartnerCode +
+		"&requestId=" + r.RequestID +
+		"&transId=" + strconv.FormatInt(r.TransID, 10)
+}
+
+// Sign sets the signature of the request
+func (r *MoMoRefundRequest) Sign(accessKey, secretKey string) {
+	r.Signature = SignMoMo(secretKey, r.RawSignature(accessKey))
+}
+
+// MoMoRefundResponse is the answer to a MoMoRefundRequest
+type MoMoRefundResponse struct {
+	PartnerCode  string         `json:"partnerCode"`
+	OrderID      string         `json:"orderId"`
+	RequestID    string         `json:"requestId"`
+	Amount       int64          `json:"amount"`
+	TransID      int64          `json:"transId"` // MoMo's ID of the refund
+	ResultCode   MoMoResultCode `json:"resultCode"`
+	Message      string         `json:"message"`
+	ResponseTime int64          `json:"responseTime"`
+}
+
+// MoMoIPN is the notification MoMo posts to the ipnUrl of a payment when it ends
+type MoMoIPN struct {
+	PartnerCode  string         `json:"partnerCode"`
+	OrderID      string         `json:"orderId"`
+	RequestID    string         `json:"requestId"`
+	Amount       int64          `json:"amount"`
+	OrderInfo    string         `json:"orderInfo"`
+	OrderType    string         `json:"orderType"`
+	TransID      int64          `json:"transId"`
+	ResultCode   MoMoResultCode `json:"resultCode"`
+	Message      string         `json:"message"`
+	PayType      string         `json:"payType"`
+	ResponseTime int64          `json:"responseTime"` // Unix milliseconds
+	ExtraData    string         `json:"extraData"`
+	Signature    string         `json:"signature"`
+}
+
+// RawSignature returns the signed fields in the order MoMo documents
+func (n *MoMoIPN) RawSignature(accessKey string) string {
+	return "accessKey=" + accessKey +
+		"&amount=" + strconv.FormatInt(n.Amount, 10) +
+		"&extraData=" + n.ExtraData +
+		"&message=" + n.Message +
+		"&orderId=" + n.OrderID +
+		"&orderInfo=" + n.OrderInfo +
+		"&orderType=" + n.OrderType +
+		"&partnerCode=" + n.PartnerCode +
+		"&payType=" + n.PayType +
+		"&requestId=" + n.RequestID +
+		"&responseTime=" + strconv.FormatInt(n.ResponseTime, 10) +
+		"&resultCode=" + strconv.Itoa(int(n.ResultCode)) +
+		"&transId=" + strconv.FormatInt(n.TransID, 10)
+}
+
+// Sign sets the signature of the notification
+func (n *MoMoIPN) Sign(accessKey, secretKey string) {
+	n.Signature = SignMoMo(secretKey, n.RawSignature(accessKey))
+}
+
+// SignMoMo returns the hex HMAC-SHA256 of the raw signature with the secret key
+func SignMoMo(secretKey, rawSignature string) string {
+	h := hmac.New(sha256.New, []byte(secretKey))
 	h.Write([]byte(rawSignature))
-	r.Signature = hex.EncodeToString(h.Sum(nil))
-}
-
-// ToMap converts the MoMoRequest to a map of strings, which can be used to send to the MoMo API
-func (r *MoMoRequest) ToMap() map[string]string {
-	return map[string]string{
-		"partnerCode": r.PartnerCode,
-		"accessKey":   r.AccessKey,
-		"requestId":   r.RequestID,
-		"amount":      r.Amount,
-		"orderId":     r.OrderID,
-		"signature":   r.Signature,
-	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ValidMoMoSignature reports in constant time whether the signature signs the raw signature with the secret key
+func ValidMoMoSignature(secretKey, rawSignature, signature string) bool {
+	expected := SignMoMo(secretKey, rawSignature)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
 }
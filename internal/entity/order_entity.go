@@ -0,0 +1,68 @@
+package entity
+
+import "time"
+
+// Names of the payment providers, as stored in orders
+const (
+	PaymentProviderMoMo  = "momo"
+	PaymentProviderVNPay = "vnpay"
+)
+
+// OrderStatus is the state of the payment of an order
+type OrderStatus string
+
+const (
+	OrderStatusPending           OrderStatus = "pending"            // Created, waiting for the user to pay
+	OrderStatusPaid              OrderStatus = "paid"               // Paid in full
+	OrderStatusFailed            OrderStatus = "failed"             // The payment was denied, cancelled or expired
+	OrderStatusRefunded          OrderStatus = "refunded"           // Paid, then refunded in full
+	OrderStatusPartiallyRefunded OrderStatus = "partially_refunded" // Paid, then refunded in part
+)
+
+// orderTransitions lists the statuses an order may move to from each status. Failed and refunded orders are final.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:           {OrderStatusPaid, OrderStatusFailed},
+	OrderStatusPaid:              {OrderStatusPartiallyRefunded, OrderStatusRefunded},
+	OrderStatusPartiallyRefunded: {OrderStatusPartiallyRefunded, OrderStatusRefunded},
+}
+
+// CanTransitionTo reports whether an order with the status may move to the next one. A partially refunded order
+// stays partially refunded after another partial refund.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if next == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPaid reports whether the order was paid, refunded or not
+func (s OrderStatus) IsPaid() bool {
+	return s == OrderStatusPaid || s == OrderStatusPartiallyRefunded || s == OrderStatusRefunded
+}
+
+// Order is a payment of a plan by a user through a payment provider
+type Order struct {
+	ID             uint64      `json:"id"`
+	Reference      string      `json:"order_id"` // Order ID given to the payment provider
+	UserID         uint64      `json:"user_id"`
+	PlanID         uint64      `json:"plan_id"`
+	Amount         int64       `json:"amount"`   // In minor units of the currency, copied from the plan
+	Currency       string      `json:"currency"` // ISO 4217, e.g. VND
+	Provider       string      `json:"provider"` // e.g. momo
+	Status         OrderStatus `json:"status"`
+	TransactionID  string      `json:"transaction_id,omitempty"` // The provider's ID of the payment, set once paid
+	RefundedAmount int64       `json:"refunded_amount"`
+	PaidAt         *time.Time  `json:"paid_at,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+// RefundableAmount returns what is left to refund of a paid order
+func (o *Order) RefundableAmount() int64 {
+	if !o.Status.IsPaid() {
+		return 0
+	}
+	return o.Amount - o.RefundedAmount
+}
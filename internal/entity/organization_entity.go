@@ -0,0 +1,71 @@
+package entity
+
+import "time"
+
+// OrgRole is the role of a member in an organization
+type OrgRole string
+
+const (
+	OrgRoleOwner  OrgRole = "owner"  // Manages the members and invitations, and edits the resources
+	OrgRoleEditor OrgRole = "editor" // Adds, edits and deletes the resources of the organization
+	OrgRoleViewer OrgRole = "viewer" // Reads the resources of the organization
+)
+
+// orgRoleRanks orders the roles, each including what the lower ones may do
+var orgRoleRanks = map[OrgRole]int{
+	OrgRoleViewer: 1,
+	OrgRoleEditor: 2,
+	OrgRoleOwner:  3,
+}
+
+// IsValidOrgRole reports whether the role is one of the known roles
+func IsValidOrgRole(role OrgRole) bool {
+	_, ok := orgRoleRanks[role]
+	return ok
+}
+
+// AtLeast reports whether the role may do what the required role may. The empty role of non-members includes nothing.
+func (r OrgRole) AtLeast(required OrgRole) bool {
+	rank, ok := orgRoleRanks[r]
+	return ok && rank >= orgRoleRanks[required]
+}
+
+// Organization groups users who share videos, audios and transcriptions
+type Organization struct {
+	ID        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy uint64    `json:"created_by"`
+	Role      OrgRole   `json:"role,omitempty"` // Role of the current user, when listing their organizations
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OrganizationMember is a user's membership in an organization
+type OrganizationMember struct {
+	OrgID     uint64    `json:"org_id"`
+	UserID    uint64    `json:"user_id"`
+	UserName  string    `json:"username"`
+	Email     string    `json:"email"`
+	Role      OrgRole   `json:"role"`
+	CreatedAt time.Time `json:"created_at"` // When the user joined
+}
+
+// OrganizationInvite invites the holder of an email address to join an organization with a role.
+// Only the hash of the mailed token is stored.
+type OrganizationInvite struct {
+	ID         uint64     `json:"id"`
+	OrgID      uint64     `json:"org_id"`
+	Email      string     `json:"email"`
+	Role       OrgRole    `json:"role"`
+	TokenHash  string     `json:"-"`
+	InvitedBy  uint64     `json:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IsPending reports whether the invitation may still be accepted
+func (i *OrganizationInvite) IsPending(now time.Time) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
@@ -0,0 +1,81 @@
+package entity
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// PaymentState is the state of a payment at its provider
+type PaymentState string
+
+const (
+	PaymentStatePending PaymentState = "pending" // The user may still pay
+	PaymentStatePaid    PaymentState = "paid"
+	PaymentStateFailed  PaymentState = "failed" // Denied, cancelled or expired
+)
+
+// Checkout is a payment created at a provider for an order, which the user completes with the provider
+type Checkout struct {
+	PayURL   string // Payment page to open in a browser
+	Deeplink string // Opens the provider's app on a phone, if it has one
+	QRData   string // Data of a QR code to scan with the provider's app, if it has one
+}
+
+// PaymentStatus is what a provider reports about the payment of an order
+type PaymentStatus struct {
+	State         PaymentState
+	Amount        int64  // In minor units of the currency of the order
+	TransactionID string // The provider's ID of the payment, once paid
+	Code          string // The provider's own status code, e.g. MoMo's resultCode
+	Message       string // The provider's message
+}
+
+// PaymentRefund is a refund made by a provider
+type PaymentRefund struct {
+	RefundID      string // ID of the refund given to the provider
+	TransactionID string // The provider's ID of the refund, if it gives one
+	Amount        int64
+}
+
+// WebhookRequest is a notification a provider sent to this server, as received
+type WebhookRequest struct {
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// PaymentEvent is a verified notification from a provider about the payment of an order
+type PaymentEvent struct {
+	ID            string // The same for every retry of the notification
+	OrderID       string // Order ID given to the provider
+	State         PaymentState
+	Amount        int64
+	TransactionID string
+	OccurredAt    time.Time // When the provider sent the notification first; zero if it does not say
+	Details       string    // The notification, as JSON
+}
+
+// WebhookOutcome is how a notification was handled, which each provider expects an answer in its own format for
+type WebhookOutcome string
+
+const (
+	WebhookAccepted         WebhookOutcome = "accepted"          // Handled now or before
+	WebhookInvalidSignature WebhookOutcome = "invalid_signature" // Not from the provider
+	WebhookOrderNotFound    WebhookOutcome = "order_not_found"
+	WebhookAmountMismatch   WebhookOutcome = "amount_mismatch" // For another amount than the order's
+	WebhookRejected         WebhookOutcome = "rejected"        // Malformed or too old, and never accepted later
+	WebhookFailed           WebhookOutcome = "failed"          // Could not be handled now; the provider should retry
+)
+
+// WebhookResponse is the answer a provider expects to a notification
+type WebhookResponse struct {
+	Status int
+	Body   interface{} // Sent as JSON; nothing is sent when nil
+}
+
+// PaymentProviderError is implemented by the errors of requests a provider rejected
+type PaymentProviderError interface {
+	error
+	ProviderMessage() string // What the provider said, for the user
+}
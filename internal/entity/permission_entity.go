@@ -0,0 +1,44 @@
+package entity
+
+// Permission is an action a role may perform, written as resource:action[:scope]
+type Permission string
+
+const (
+	PermissionVideoDeleteAny    Permission = "video:delete:any"    // Delete videos of any user
+	PermissionUserList          Permission = "user:list"           // List all users
+	PermissionUserSuspend       Permission = "user:suspend"        // Suspend and reinstate users
+	PermissionUserAssignRole    Permission = "user:role:assign"    // Change the role of users
+	PermissionUserResetPassword Permission = "user:password:reset" // Force users to change their password
+	PermissionUserRestore       Permission = "user:restore"        // Restore deleted users
+	PermissionUserUnlock        Permission = "user:unlock"         // Lift login lockouts after failed logins
+	PermissionAuditLogRead      Permission = "audit:read"          // Read the audit trail of the admin API
+	PermissionPaymentRefund     Permission = "payment:refund"      // Refund payments
+)
+
+// RolePermissions maps each role to the permissions it grants. Roles missing from the map grant nothing.
+var RolePermissions = map[string][]Permission{
+	UserRoleUser: {},
+	UserRoleModerator: {
+		PermissionVideoDeleteAny,
+		PermissionUserList,
+		PermissionUserSuspend,
+		PermissionUserUnlock,
+	},
+	UserRoleAdmin: {
+		PermissionVideoDeleteAny,
+		PermissionUserList,
+		PermissionUserSuspend,
+		PermissionUserAssignRole,
+		PermissionUserResetPassword,
+		PermissionUserRestore,
+		PermissionUserUnlock,
+		PermissionAuditLogRead,
+		PermissionPaymentRefund,
+	},
+}
+
+// IsValidRole reports whether the role is one of the known roles
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
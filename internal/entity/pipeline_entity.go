@@ -0,0 +1,71 @@
+package entity
+
+import "time"
+
+// PipelineStatus is the overall state of a pipeline
+type PipelineStatus string
+
+const (
+	PipelineStatusRunning   PipelineStatus = "running"   // Some steps have not finished yet
+	PipelineStatusSucceeded PipelineStatus = "succeeded" // Every step succeeded
+	PipelineStatusFailed    PipelineStatus = "failed"    // Every step finished and at least one failed
+)
+
+// PipelineStepStatus is the state of a single step of a pipeline
+type PipelineStepStatus string
+
+const (
+	PipelineStepPending   PipelineStepStatus = "pending"   // Waiting for the step it depends on
+	PipelineStepQueued    PipelineStepStatus = "queued"    // Task queued for the ML workers
+	PipelineStepRunning   PipelineStepStatus = "running"   // Task leased by a worker, only reported and never stored
+	PipelineStepSucceeded PipelineStepStatus = "succeeded" // Task done, outputs recorded
+	PipelineStepFailed    PipelineStepStatus = "failed"    // Task dead-lettered
+	PipelineStepSkipped   PipelineStepStatus = "skipped"   // Not run because the step it depends on failed
+)
+
+// IsFinished reports whether the step will not change anymore
+func (s PipelineStepStatus) IsFinished() bool {
+	return s == PipelineStepSucceeded || s == PipelineStepFailed || s == PipelineStepSkipped
+}
+
+// Pipeline is a DAG of ML steps (STT -> MT -> TTS -> mux) run for a video
+type Pipeline struct {
+	ID         uint64         `json:"id"`
+	VideoID    uint64         `json:"video_id"`
+	UserID     uint64         `json:"user_id"` // ID of the user who submitted the pipeline
+	SourceLang string         `json:"source_lang"`
+	Status     PipelineStatus `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	Progress   int            `json:"progress"` // Percent done across all steps, computed when the pipeline is loaded
+	Steps      []PipelineStep `json:"steps"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// PipelineStep is one ML task of a pipeline. Inputs are set when the step is queued and outputs when it succeeds.
+type PipelineStep struct {
+	ID                    uint64             `json:"id"`
+	PipelineID            uint64             `json:"pipeline_id"`
+	Name                  string             `json:"name"` // Unique within the pipeline (e.g., "stt:en", "mt:vi", "tts:vi", "mux:vi")
+	Type                  MLTaskType         `json:"type"`
+	SourceLang            string             `json:"source_lang,omitempty"`
+	TargetLang            string             `json:"target_lang,omitempty"`
+	DependsOn             string             `json:"depends_on,omitempty"` // Name of the step whose output is the input of this step
+	Status                PipelineStepStatus `json:"status"`
+	Progress              int                `json:"progress"`         // Percent done, computed from the task when the pipeline is loaded
+	JobID                 uint64             `json:"job_id,omitempty"` // Task of the step once queued
+	InputTranscriptionID  uint64             `json:"input_transcription_id,omitempty"`
+	InputAudioID          uint64             `json:"input_audio_id,omitempty"`
+	OutputTranscriptionID uint64             `json:"output_transcription_id,omitempty"` // Created by stt and mt steps
+	OutputAudioID         uint64             `json:"output_audio_id,omitempty"`         // Created by tts steps
+	OutputFolder          string             `json:"output_folder,omitempty"`           // Dubbed video created by mux steps
+	OutputFileName        string             `json:"output_file_name,omitempty"`
+	Error                 string             `json:"error,omitempty"`
+	CreatedAt             time.Time          `json:"created_at"`
+	UpdatedAt             time.Time          `json:"updated_at"`
+}
+
+// PipelinePayload is the payload of a JobTypeAdvancePipeline job
+type PipelinePayload struct {
+	PipelineID uint64 `json:"pipeline_id"`
+}
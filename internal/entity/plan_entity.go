@@ -0,0 +1,46 @@
+package entity
+
+import "time"
+
+// CurrencyVND is the Vietnamese dong, which has no minor unit
+const CurrencyVND = "VND"
+
+// BillingPeriod is how long one payment of a plan lasts
+type BillingPeriod string
+
+const (
+	BillingPeriodMonthly BillingPeriod = "monthly"
+	BillingPeriodYearly  BillingPeriod = "yearly"
+)
+
+// After returns the end of a period starting at start. Months and years follow the calendar, so a monthly
+// period starting on January 31st ends on March 3rd, as time.AddDate normalizes it.
+func (p BillingPeriod) After(start time.Time) time.Time {
+	if p == BillingPeriodYearly {
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// Quotas limit what a user may do; 0 means no limit
+type Quotas struct {
+	MaxVideos       int `json:"max_videos"`        // Videos a user may have
+	MaxVideoMinutes int `json:"max_video_minutes"` // Length of each video
+}
+
+// FreeQuotas apply to users without premium
+var FreeQuotas = Quotas{MaxVideos: 5, MaxVideoMinutes: 10}
+
+// Plan is something users pay for. Orders take their amount from the plan, never from the client.
+type Plan struct {
+	ID            uint64        `json:"id"`
+	Code          string        `json:"code"` // e.g. premium_monthly
+	Name          string        `json:"name"`
+	Amount        int64         `json:"amount"`         // In minor units of the currency
+	Currency      string        `json:"currency"`       // ISO 4217, e.g. VND
+	BillingPeriod BillingPeriod `json:"billing_period"` // How long a payment of the plan lasts
+	Quotas        Quotas        `json:"quotas"`         // Granted while subscribed
+	Active        bool          `json:"active"`         // Inactive plans can no longer be ordered
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
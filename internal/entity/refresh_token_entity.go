@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// RefreshToken is a single-use token exchanged for a new access token. Only the hash of the token is stored.
+type RefreshToken struct {
+	ID           uint64     `json:"id"`
+	UserID       uint64     `json:"user_id"`
+	TokenHash    string     `json:"-"`
+	TokenVersion int        `json:"-"` // Token version of the user when the token was issued
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"` // Set once the token was used or the user logged out
+	CreatedAt    time.Time  `json:"created_at"`
+}
@@ -0,0 +1,52 @@
+package entity
+
+import "time"
+
+// SubscriptionStatus is the state of the premium subscription of a user
+type SubscriptionStatus string
+
+const (
+	SubscriptionStatusActive   SubscriptionStatus = "active"   // Paid for the current period
+	SubscriptionStatusPastDue  SubscriptionStatus = "past_due" // The period ended unpaid; premium is kept for the grace period
+	SubscriptionStatusCanceled SubscriptionStatus = "canceled" // Will not be renewed; premium is kept until the period ends
+	SubscriptionStatusExpired  SubscriptionStatus = "expired"  // Back to free until the next payment
+)
+
+// IsPremium reports whether a subscription with the status grants premium
+func (s SubscriptionStatus) IsPremium() bool {
+	return s == SubscriptionStatusActive || s == SubscriptionStatusPastDue || s == SubscriptionStatusCanceled
+}
+
+// Subscription is the premium subscription of a user. Each paid order of a plan adds a period to it;
+// a subscription that was not renewed in time expires and is reactivated by the next payment.
+type Subscription struct {
+	ID                 uint64             `json:"id"`
+	UserID             uint64             `json:"user_id"`
+	PlanID             uint64             `json:"plan_id"`
+	Status             SubscriptionStatus `json:"status"`
+	CurrentPeriodStart time.Time          `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time          `json:"current_period_end"` // Paid until then, including periods paid in advance
+	CanceledAt         *time.Time         `json:"canceled_at,omitempty"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+}
+
+// SubscriptionPeriod is the time one paid order added to a subscription
+type SubscriptionPeriod struct {
+	ID             uint64    `json:"id"`
+	SubscriptionID uint64    `json:"subscription_id"`
+	OrderID        uint64    `json:"order_id"`
+	PlanID         uint64    `json:"plan_id"`
+	PeriodStart    time.Time `json:"period_start"`
+	PeriodEnd      time.Time `json:"period_end"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Quotas returns the quotas the subscription grants with its plan: those of the plan while it grants premium,
+// the free ones once expired
+func (s *Subscription) Quotas(plan *Plan) Quotas {
+	if !s.Status.IsPremium() {
+		return FreeQuotas
+	}
+	return plan.Quotas
+}
@@ -1,10 +1,22 @@
 package entity
 
+import "time"
+
+// Statuses of a payment recorded in the transaction logs
+const (
+	TransactionStatusPending = "pending"
+	TransactionStatusPaid    = "paid"
+	TransactionStatusFailed  = "failed"
+)
+
 // TransactionLog represents a log entry for a transaction
 type TransactionLog struct {
-	OrderID       string
-	PaymentMethod string
-	Action        string
-	Status        string
-	Details       string
+	ID            uint64    `json:"id"`
+	OrderID       string    `json:"order_id"`
+	PaymentMethod string    `json:"payment_method"` // e.g. momo
+	Action        string    `json:"action"`         // e.g. ipn for a notification from the gateway
+	Status        string    `json:"status"`
+	Details       string    `json:"details"`             // What the gateway sent, as JSON
+	Reference     string    `json:"reference,omitempty"` // Identifies the event at the gateway; logged once per payment method
+	CreatedAt     time.Time `json:"created_at"`
 }
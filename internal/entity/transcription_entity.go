@@ -3,13 +3,18 @@ package entity
 import "time"
 
 type Transcription struct {
-	ID        uint64    `json:"id"`
-	VideoID   uint64    `json:"video_id"`   // ID of the related video
-	UserID    uint64    `json:"user_id"`    // ID of the user who created the transcription
-	Text      string    `json:"text"`       // The transcription text
-	Lang      string    `json:"lang"`       // Language of the transcription (e.g., "en", "es", etc.)
-	Folder    string    `json:"folder"`     // S3 folder or path containing the transcription file
-	FileName  string    `json:"file_name"`  // The transcription file name in S3
-	CreatedAt time.Time `json:"created_at"` // Timestamp of when the transcription was created
-	UpdatedAt time.Time `json:"updated_at"` // Timestamp of the last update to the transcription
+	ID          uint64     `json:"id"`
+	VideoID     uint64     `json:"video_id"`         // ID of the related video
+	UserID      uint64     `json:"user_id"`          // ID of the user who created the transcription
+	OrgID       *uint64    `json:"org_id,omitempty"` // ID of the organization the transcription is shared with, if any
+	Text        string     `json:"text"`             // The transcription text
+	Lang        string     `json:"lang"`             // Language of the transcription (e.g., "en", "es", etc.)
+	Folder      string     `json:"folder"`           // S3 folder or path containing the transcription file
+	FileName    string     `json:"file_name"`        // The transcription file name in S3
+	FileSize    int64      `json:"file_size"`        // Size of the stored file in bytes, recorded when the upload is finalized
+	ETag        string     `json:"etag"`             // ETag of the stored file, recorded when the upload is finalized
+	ContentType string     `json:"content_type"`     // Declared MIME type of the file
+	Status      FileStatus `json:"status"`           // pending_upload until the file is verified in storage
+	CreatedAt   time.Time  `json:"created_at"`       // Timestamp of when the transcription was created
+	UpdatedAt   time.Time  `json:"updated_at"`       // Timestamp of the last update to the transcription
 }
@@ -0,0 +1,44 @@
+package entity
+
+import "time"
+
+// TranscriptionRevisionAction is the kind of edit that produced a revision
+type TranscriptionRevisionAction string
+
+const (
+	RevisionActionInitial       TranscriptionRevisionAction = "initial"        // Segments as they were before the first recorded edit
+	RevisionActionImport        TranscriptionRevisionAction = "import"         // Segments imported from a subtitle file
+	RevisionActionAddSegments   TranscriptionRevisionAction = "add_segments"   // Segments added in a batch
+	RevisionActionUpdateSegment TranscriptionRevisionAction = "update_segment" // A single segment patched
+	RevisionActionRestore       TranscriptionRevisionAction = "restore"        // Segments reset to an older revision
+)
+
+// SegmentChangeType tells how a segment differs between two revisions
+type SegmentChangeType string
+
+const (
+	SegmentAdded    SegmentChangeType = "added"
+	SegmentRemoved  SegmentChangeType = "removed"
+	SegmentModified SegmentChangeType = "modified"
+)
+
+// SegmentChange describes one segment that differs between two revisions
+type SegmentChange struct {
+	SegmentID uint64                `json:"segment_id"`
+	Type      SegmentChangeType     `json:"type"`
+	Before    *TranscriptionSegment `json:"before,omitempty"` // Not set for added segments
+	After     *TranscriptionSegment `json:"after,omitempty"`  // Not set for removed segments
+}
+
+// TranscriptionRevision is a recorded edit of the segments of a transcription
+type TranscriptionRevision struct {
+	ID              uint64                      `json:"id"`
+	TranscriptionID uint64                      `json:"transcription_id"`
+	Number          int                         `json:"number"`  // Sequence number of the revision within the transcription, starting at 1
+	UserID          uint64                      `json:"user_id"` // Author of the edit
+	Action          TranscriptionRevisionAction `json:"action"`
+	RestoredFrom    int                         `json:"restored_from,omitempty"` // Number of the revision restored, restore only
+	Changes         []SegmentChange             `json:"changes"`                 // Segments changed by the edit
+	Segments        []TranscriptionSegment      `json:"segments,omitempty"`      // All segments after the edit; only loaded for a single revision
+	CreatedAt       time.Time                   `json:"created_at"`
+}
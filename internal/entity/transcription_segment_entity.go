@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// TranscriptionSegment is a time-coded part of a transcription, used for subtitling and dubbing alignment
+type TranscriptionSegment struct {
+	ID              uint64    `json:"id"`
+	TranscriptionID uint64    `json:"transcription_id"` // ID of the transcription the segment belongs to
+	StartMs         int64     `json:"start_ms"`         // Start of the segment in milliseconds from the beginning of the video
+	EndMs           int64     `json:"end_ms"`           // End of the segment in milliseconds from the beginning of the video
+	Text            string    `json:"text"`             // Text spoken during the segment
+	Speaker         string    `json:"speaker"`          // Optional speaker label (e.g., "SPEAKER_1")
+	Confidence      float64   `json:"confidence"`       // Recognition confidence between 0 and 1, 0 when unknown
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
@@ -0,0 +1,36 @@
+package entity
+
+import "time"
+
+// UploadSessionStatus is the state of a multipart upload
+type UploadSessionStatus string
+
+const (
+	UploadStatusInProgress UploadSessionStatus = "in_progress"
+	UploadStatusCompleted  UploadSessionStatus = "completed"
+	UploadStatusAborted    UploadSessionStatus = "aborted"
+)
+
+// FileStatus tells whether the file of an audio or transcription has been verified in storage
+type FileStatus string
+
+const (
+	FileStatusPendingUpload FileStatus = "pending_upload" // Record created, file not confirmed yet
+	FileStatusReady         FileStatus = "ready"          // File found in storage and matching the declared type
+)
+
+// UploadSession tracks a multipart upload so clients can resume it after a crash
+type UploadSession struct {
+	ID         uint64              `json:"id"`
+	UploadID   string              `json:"upload_id"`   // Multipart upload ID issued by the storage backend
+	UserID     uint64              `json:"user_id"`     // ID of the user who started the upload
+	Folder     string              `json:"folder"`      // S3 folder the file is uploaded to
+	FileName   string              `json:"file_name"`   // The file name in S3
+	FileType   string              `json:"file_type"`   // Content type of the file (e.g., video/mp4)
+	FileSize   int64               `json:"file_size"`   // Total size of the file in bytes
+	PartSize   int64               `json:"part_size"`   // Size of every part except the last one in bytes
+	TotalParts int                 `json:"total_parts"` // Number of parts the file is split into
+	Status     UploadSessionStatus `json:"status"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
@@ -9,6 +9,13 @@ const (
 	UserStatusDeleted   = 10
 )
 
+// UserRole constants
+const (
+	UserRoleUser      = "User"
+	UserRoleModerator = "Moderator"
+	UserRoleAdmin     = "Admin"
+)
+
 // User represents the schema for user data
 type User struct {
 	ID           uint64    `json:"id"`         // Unique identifier for the user
@@ -24,4 +31,36 @@ type User struct {
 	AvatarFolder string    `json:"avatar_folder"` // Folder that contain the avatar image on s3
 	CreatedAt    time.Time `json:"created_at"`    // Timestamp of when the user was created
 	UpdatedAt    time.Time `json:"updated_at"`    // Timestamp of the last update to the user's data
+
+	PasswordResetRequired bool `json:"password_reset_required"` // Set by an admin; the user must change their password first
+	TokenVersion          int  `json:"-"`                       // Bumped to revoke every token issued to the user
+	EmailVerified         bool `json:"email_verified"`          // Set once the user followed the verification link mailed to them
+
+	TOTPSecret   string `json:"-"`            // Base32 TOTP secret; pending until TOTPEnabled is set
+	TOTPEnabled  bool   `json:"totp_enabled"` // Login asks for a TOTP or recovery code after the password
+	TOTPLastStep int64  `json:"-"`            // Last TOTP period a code was accepted for
+}
+
+// UserFilter narrows the users listed through the admin API. Zero values match every user.
+type UserFilter struct {
+	Status        int
+	Role          string
+	Premium       *bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// IsAdmin reports whether the user may act on resources of other users
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
+
+// HasPermission reports whether the role of the user grants the permission
+func (u *User) HasPermission(permission Permission) bool {
+	for _, granted := range RolePermissions[u.Role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
 }
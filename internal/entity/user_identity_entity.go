@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// UserIdentity links a user to their account at an OpenID Connect provider
+type UserIdentity struct {
+	ID          uint64     `json:"id"`
+	UserID      uint64     `json:"user_id"`
+	Provider    string     `json:"provider"` // Name of the provider in the configuration
+	Subject     string     `json:"-"`        // Stable ID of the user at the provider
+	Email       string     `json:"email"`    // Email address at the provider when linked
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+// UserTokenPurpose is what a mailed user token may be used for
+type UserTokenPurpose string
+
+// UserTokenPurpose constants
+const (
+	UserTokenEmailVerification UserTokenPurpose = "email_verification"
+	UserTokenPasswordReset     UserTokenPurpose = "password_reset"
+)
+
+// UserToken is a single-use, expiring token mailed to a user. Only the hash of the token is stored.
+type UserToken struct {
+	ID        uint64           `json:"id"`
+	UserID    uint64           `json:"user_id"`
+	Purpose   UserTokenPurpose `json:"purpose"`
+	TokenHash string           `json:"-"`
+	ExpiresAt time.Time        `json:"expires_at"`
+	UsedAt    *time.Time       `json:"used_at,omitempty"` // Set once the token was used or replaced by a newer one
+	CreatedAt time.Time        `json:"created_at"`
+}
@@ -6,10 +6,11 @@ import "time"
 type VideoStatus string
 
 const (
-	StatusRaw        VideoStatus = "raw"
-	StatusProcessing VideoStatus = "processing"
-	StatusFailed     VideoStatus = "failed"
-	StatusSuccess    VideoStatus = "success"
+	StatusPendingUpload VideoStatus = "pending_upload" // Record created, file not confirmed in storage yet
+	StatusRaw           VideoStatus = "raw"
+	StatusProcessing    VideoStatus = "processing"
+	StatusFailed        VideoStatus = "failed"
+	StatusSuccess       VideoStatus = "success"
 )
 
 type Video struct {
@@ -21,7 +22,11 @@ type Video struct {
 	Folder      string      `json:"folder"`
 	Image       string      `json:"image"`
 	Status      VideoStatus `json:"status"`
-	UserID      uint64      `json:"user_id"`    // ID of the user who uploaded the video
-	CreatedAt   time.Time   `json:"created_at"` // Timestamp of when the video was created
-	UpdatedAt   time.Time   `json:"updated_at"` // Timestamp of the last update to the video
+	UserID      uint64      `json:"user_id"`          // ID of the user who uploaded the video
+	OrgID       *uint64     `json:"org_id,omitempty"` // ID of the organization the video is shared with, if any
+	FileSize    int64       `json:"file_size"`        // Size of the stored file in bytes, recorded when the upload is finalized
+	ETag        string      `json:"etag"`             // ETag of the stored file, recorded when the upload is finalized
+	ContentType string      `json:"content_type"`     // Declared MIME type of the file (e.g., video/mp4)
+	CreatedAt   time.Time   `json:"created_at"`       // Timestamp of when the video was created
+	UpdatedAt   time.Time   `json:"updated_at"`       // Timestamp of the last update to the video
 }
@@ -0,0 +1,208 @@
+package entity
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VNPayVersion is the version of the VNPay API requests are made with
+const VNPayVersion = "2.1.0"
+
+// VNPayDateFormat is the format of the dates VNPay sends and expects, in VNPayLocation
+const VNPayDateFormat = "20060102150405"
+
+// VNPayLocation is the time zone of VNPay's dates
+var VNPayLocation = time.FixedZone("GMT+7", 7*60*60)
+
+// Codes in vnp_ResponseCode. Payment results share them with the answers to API requests.
+const (
+	VNPayResponseSuccess          = "00" // Paid, or the request succeeded
+	VNPayResponseSuspicious       = "07" // Paid but flagged as suspected fraud
+	VNPayResponseExpired          = "11" // The payment page expired
+	VNPayResponseCancelled        = "24" // The user cancelled the payment
+	VNPayResponseInsufficientFund = "51"
+	VNPayResponseOrderNotFound    = "91"
+	VNPayResponseDuplicate        = "94" // The request ID was already used
+	VNPayResponseRefundRejected   = "95"
+	VNPayResponseInvalidChecksum  = "97"
+	VNPayResponseUnknownError     = "99"
+)
+
+// Codes in vnp_TransactionStatus, the status of a payment at VNPay
+const (
+	VNPayTransactionSuccess = "00"
+	VNPayTransactionPending = "01" // Not completed yet
+	VNPayTransactionFailed  = "02"
+)
+
+// Codes in vnp_TransactionType of refunds
+const (
+	VNPayRefundFull    = "02"
+	VNPayRefundPartial = "03"
+)
+
+// VNPayPaymentState returns the state of a payment with its vnp_TransactionStatus
+func VNPayPaymentState(transactionStatus string) PaymentState {
+	switch transactionStatus {
+	case VNPayTransactionSuccess:
+		return PaymentStatePaid
+	case VNPayTransactionPending:
+		return PaymentStatePending
+	default:
+		return PaymentStateFailed
+	}
+}
+
+// VNPayError is a request VNPay answered with a response code other than success
+type VNPayError struct {
+	ResponseCode string
+	Message      string
+}
+
+func (e *VNPayError) Error() string {
+	return fmt.Sprintf("vnpay: response code %s: %s", e.ResponseCode, e.Message)
+}
+
+func (e *VNPayError) ProviderMessage() string {
+	return e.Message
+}
+
+// VNPayQueryRequest asks VNPay for the status of a payment (POST merchant_webapi/api/transaction, command querydr)
+type VNPayQueryRequest struct {
+	RequestID       string `json:"vnp_RequestId"`
+	Version         string `json:"vnp_Version"`
+	Command         string `json:"vnp_Command"`
+	TmnCode         string `json:"vnp_TmnCode"`
+	TxnRef          string `json:"vnp_TxnRef"` // Order ID of the payment
+	OrderInfo       string `json:"vnp_OrderInfo"`
+	TransactionDate string `json:"vnp_TransactionDate"` // vnp_CreateDate of the payment
+	CreateDate      string `json:"vnp_CreateDate"`
+	IPAddr          string `json:"vnp_IpAddr"`
+	SecureHash      string `json:"vnp_SecureHash"`
+}
+
+// RawSignature returns the signed fields in the order VNPay documents
+func (r *VNPayQueryRequest) RawSignature() string {
+	return strings.Join([]string{
+		r.RequestID, r.Version, r.Command, r.TmnCode, r.TxnRef, r.TransactionDate, r.CreateDate, r.IPAddr, r.OrderInfo,
+	}, "|")
+}
+
+// Sign sets the secure hash of the request
+func (r *VNPayQueryRequest) Sign(hashSecret string) {
+	r.SecureHash = SignVNPay(hashSecret, r.RawSignature())
+}
+
+// VNPayQueryResponse is the answer to a VNPayQueryRequest. TransactionStatus is the status of the payment.
+type VNPayQueryResponse struct {
+	ResponseID        string `json:"vnp_ResponseId"`
+	Command           string `json:"vnp_Command"`
+	ResponseCode      string `json:"vnp_ResponseCode"`
+	Message           string `json:"vnp_Message"`
+	TmnCode           string `json:"vnp_TmnCode"`
+	TxnRef            string `json:"vnp_TxnRef"`
+	Amount            int64  `json:"vnp_Amount,string"` // In VND times 100
+	OrderInfo         string `json:"vnp_OrderInfo"`
+	BankCode          string `json:"vnp_BankCode"`
+	PayDate           string `json:"vnp_PayDate"`
+	TransactionNo     string `json:"vnp_TransactionNo"` // VNPay's ID of the payment, needed to refund it
+	TransactionType   string `json:"vnp_TransactionType"`
+	TransactionStatus string `json:"vnp_TransactionStatus"`
+	SecureHash        string `json:"vnp_SecureHash"`
+}
+
+// VNPayRefundRequest refunds all or part of a payment (POST merchant_webapi/api/transaction, command refund)
+type VNPayRefundRequest struct {
+	RequestID       string `json:"vnp_RequestId"`
+	Version         string `json:"vnp_Version"`
+	Command         string `json:"vnp_Command"`
+	TmnCode         string `json:"vnp_TmnCode"`
+	TransactionType string `json:"vnp_TransactionType"` // VNPayRefundFull or VNPayRefundPartial
+	TxnRef          string `json:"vnp_TxnRef"`
+	Amount          int64  `json:"vnp_Amount,string"` // In VND times 100
+	OrderInfo       string `json:"vnp_OrderInfo"`
+	TransactionNo   string `json:"vnp_TransactionNo"`
+	TransactionDate string `json:"vnp_TransactionDate"`
+	CreateBy        string `json:"vnp_CreateBy"`
+	CreateDate      string `json:"vnp_CreateDate"`
+	IPAddr          string `json:"vnp_IpAddr"`
+	SecureHash      string `json:"vnp_SecureHash"`
+}
+
+// RawSignature returns the signed fields in the order VNPay documents
+func (r *VNPayRefundRequest) RawSignature() string {
+	return strings.Join([]string{
+		r.RequestID, r.Version, r.Command, r.TmnCode, r.TransactionType, r.TxnRef, strconv.FormatInt(r.Amount, 10),
+		r.TransactionNo, r.TransactionDate, r.CreateBy, r.CreateDate, r.IPAddr, r.OrderInfo,
+	}, "|")
+}
+
+// Sign sets the secure hash of the request
+func (r *VNPayRefundRequest) Sign(hashSecret string) {
+	r.SecureHash = SignVNPay(hashSecret, r.RawSignature())
+}
+
+// VNPayRefundResponse is the answer to a VNPayRefundRequest
+type VNPayRefundResponse struct {
+	ResponseID        string `json:"vnp_ResponseId"`
+	Command           string `json:"vnp_Command"`
+	ResponseCode      string `json:"vnp_ResponseCode"`
+	Message           string `json:"vnp_Message"`
+	TmnCode           string `json:"vnp_TmnCode"`
+	TxnRef            string `json:"vnp_TxnRef"`
+	Amount            int64  `json:"vnp_Amount,string"`
+	OrderInfo         string `json:"vnp_OrderInfo"`
+	BankCode          string `json:"vnp_BankCode"`
+	PayDate           string `json:"vnp_PayDate"`
+	TransactionNo     string `json:"vnp_TransactionNo"` // VNPay's ID of the refund
+	TransactionType   string `json:"vnp_TransactionType"`
+	TransactionStatus string `json:"vnp_TransactionStatus"`
+	SecureHash        string `json:"vnp_SecureHash"`
+}
+
+// VNPayIPNResponse is the answer VNPay expects to the notification of a payment
+type VNPayIPNResponse struct {
+	RspCode string `json:"RspCode"`
+	Message string `json:"Message"`
+}
+
+// Codes in VNPayIPNResponse.RspCode
+const (
+	VNPayIPNConfirmed        = "00"
+	VNPayIPNOrderNotFound    = "01"
+	VNPayIPNAlreadyConfirmed = "02"
+	VNPayIPNInvalidAmount    = "04"
+	VNPayIPNInvalidChecksum  = "97"
+	VNPayIPNUnknownError     = "99" // VNPay retries the notification
+)
+
+// VNPayHashData returns the signed data of the vnp_ parameters of a payment URL or notification: the parameters
+// but the hash itself, sorted by name and URL-encoded
+func VNPayHashData(params url.Values) string {
+	signed := url.Values{}
+	for name, values := range params {
+		if strings.HasPrefix(name, "vnp_") && name != "vnp_SecureHash" && name != "vnp_SecureHashType" {
+			signed[name] = values
+		}
+	}
+	return signed.Encode()
+}
+
+// SignVNPay returns the hex HMAC-SHA512 of the data with the hash secret
+func SignVNPay(hashSecret, data string) string {
+	h := hmac.New(sha512.New, []byte(hashSecret))
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ValidVNPaySignature reports in constant time whether the hash signs the data with the hash secret
+func ValidVNPaySignature(hashSecret, data, hash string) bool {
+	expected := SignVNPay(hashSecret, data)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(hash)))
+}
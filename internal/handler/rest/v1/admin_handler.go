@@ -0,0 +1,381 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// AdminController serves the user-management API for admins and moderators
+type AdminController struct {
+	adminService service.AdminService
+}
+
+// NewAdminController creates a new AdminController
+func NewAdminController(adminService service.AdminService) *AdminController {
+	return &AdminController{
+		adminService: adminService,
+	}
+}
+
+// ModerationRequest represents the request body of admin actions that take an optional reason
+type ModerationRequest struct {
+	Reason string `json:"reason"` // Recorded in the audit trail
+}
+
+// SuspendUserRequest represents the request body for suspending a user
+type SuspendUserRequest struct {
+	Reason string `json:"reason" binding:"required"` // Recorded in the audit trail
+}
+
+// UnlockUserRequest represents the request body for lifting a login lockout
+type UnlockUserRequest struct {
+	IP     string `json:"ip" binding:"omitempty,ip"` // Also lift the lockout of this client IP
+	Reason string `json:"reason"`                    // Recorded in the audit trail
+}
+
+// ChangeRoleRequest represents the request body for changing the role of a user
+type ChangeRoleRequest struct {
+	Role   string `json:"role" binding:"required"` // User, Moderator or Admin
+	Reason string `json:"reason"`                  // Recorded in the audit trail
+}
+
+// ListUsers godoc
+// @Summary List users
+// @Description Returns one page of the users matching the filters, oldest first. Requires the user:list permission
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param status query int false "Status: 1 available, 9 suspended, 10 deleted"
+// @Param role query string false "Role: User, Moderator or Admin"
+// @Param premium query bool false "Premium users only (true) or non-premium users only (false)"
+// @Param created_after query string false "Created at or after this RFC 3339 time"
+// @Param created_before query string false "Created before this RFC 3339 time"
+// @Param page query int false "Page number, starting at 1" default(1)
+// @Param page_size query int false "Users per page, at most 200" default(50)
+// @Success 200 {object} response.UserPageResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /admin/users [get]
+func (h *AdminController) ListUsers(c *gin.Context) {
+	filter, ok := userFilterQuery(c)
+	if !ok {
+		return
+	}
+	page, pageSize, ok := adminPageQuery(c)
+	if !ok {
+		return
+	}
+
+	users, total, err := h.adminService.ListUsers(filter, page, pageSize)
+	if err != nil {
+		handleAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.UserPageResponse{Users: users, Page: page, PageSize: pageSize, Total: total})
+}
+
+// SuspendUser godoc
+// @Summary Suspend a user
+// @Description Stops an available user from using the API. Requires the user:suspend permission; only admins may suspend admins
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path uint64 true "User ID"
+// @Param request body SuspendUserRequest true "Reason"
+// @Success 200 {object} response.UserResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /admin/users/{user_id}/suspend [post]
+func (h *AdminController) SuspendUser(c *gin.Context) {
+	var req SuspendUserRequest
+	h.moderate(c, &req, func(actor *entity.User, userID uint64) (*entity.User, error) {
+		return h.adminService.SuspendUser(actor, userID, req.Reason)
+	})
+}
+
+// UnsuspendUser godoc
+// @Summary Unsuspend a user
+// @Description Lets a suspended user use the API again. Requires the user:suspend permission
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path uint64 true "User ID"
+// @Param request body ModerationRequest false "Reason"
+// @Success 200 {object} response.UserResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /admin/users/{user_id}/unsuspend [post]
+func (h *AdminController) UnsuspendUser(c *gin.Context) {
+	var req ModerationRequest
+	h.moderate(c, &req, func(actor *entity.User, userID uint64) (*entity.User, error) {
+		return h.adminService.UnsuspendUser(actor, userID, req.Reason)
+	})
+}
+
+// ForcePasswordReset godoc
+// @Summary Force a password reset
+// @Description Requires the user to change their password before they can use the rest of the API. Requires the user:password:reset permission
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path uint64 true "User ID"
+// @Param request body ModerationRequest false "Reason"
+// @Success 200 {object} response.UserResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /admin/users/{user_id}/password-reset [post]
+func (h *AdminController) ForcePasswordReset(c *gin.Context) {
+	var req ModerationRequest
+	h.moderate(c, &req, func(actor *entity.User, userID uint64) (*entity.User, error) {
+		return h.adminService.ForcePasswordReset(actor, userID, req.Reason)
+	})
+}
+
+// ChangeRole godoc
+// @Summary Change the role of a user
+// @Description Changes the role of the user, and with it the permissions they are granted. Requires the user:role:assign permission
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path uint64 true "User ID"
+// @Param request body ChangeRoleRequest true "New role"
+// @Success 200 {object} response.UserResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /admin/users/{user_id}/role [put]
+func (h *AdminController) ChangeRole(c *gin.Context) {
+	var req ChangeRoleRequest
+	h.moderate(c, &req, func(actor *entity.User, userID uint64) (*entity.User, error) {
+		return h.adminService.ChangeRole(actor, userID, req.Role, req.Reason)
+	})
+}
+
+// RestoreUser godoc
+// @Summary Restore a deleted user
+// @Description Makes a soft-deleted user available again. Requires the user:restore permission
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path uint64 true "User ID"
+// @Param request body ModerationRequest false "Reason"
+// @Success 200 {object} response.UserResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /admin/users/{user_id}/restore [post]
+func (h *AdminController) RestoreUser(c *gin.Context) {
+	var req ModerationRequest
+	h.moderate(c, &req, func(actor *entity.User, userID uint64) (*entity.User, error) {
+		return h.adminService.RestoreUser(actor, userID, req.Reason)
+	})
+}
+
+// UnlockUser godoc
+// @Summary Lift a login lockout
+// @Description Forgets the failed logins of the user, so they can log in again at once, and those of the client IP if given. Requires the user:unlock permission
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path uint64 true "User ID"
+// @Param request body UnlockUserRequest false "Client IP and reason"
+// @Success 200 {object} response.UserResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /admin/users/{user_id}/unlock [post]
+func (h *AdminController) UnlockUser(c *gin.Context) {
+	var req UnlockUserRequest
+	h.moderate(c, &req, func(actor *entity.User, userID uint64) (*entity.User, error) {
+		return h.adminService.UnlockUser(actor, userID, req.IP, req.Reason)
+	})
+}
+
+// ListAuditLogs godoc
+// @Summary List the audit trail
+// @Description Returns one page of the actions taken through the admin API, newest first. Requires the audit:read permission
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query uint64 false "Only actions taken on this user"
+// @Param page query int false "Page number, starting at 1" default(1)
+// @Param page_size query int false "Audit logs per page, at most 200" default(50)
+// @Success 200 {object} response.AuditLogPageResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /admin/audit-logs [get]
+func (h *AdminController) ListAuditLogs(c *gin.Context) {
+	var targetUserID uint64
+	if value := c.Query("user_id"); value != "" {
+		var err error
+		targetUserID, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid user ID"})
+			return
+		}
+	}
+	page, pageSize, ok := adminPageQuery(c)
+	if !ok {
+		return
+	}
+
+	auditLogs, total, err := h.adminService.ListAuditLogs(targetUserID, page, pageSize)
+	if err != nil {
+		handleAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.AuditLogPageResponse{AuditLogs: auditLogs, Page: page, PageSize: pageSize, Total: total})
+}
+
+// moderate parses the user ID and the request body of an admin action on a user, runs the action
+// as the authenticated user and responds with the user after the action
+func (h *AdminController) moderate(c *gin.Context, req interface{}, action func(actor *entity.User, userID uint64) (*entity.User, error)) {
+	actor, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid user ID"})
+		return
+	}
+
+	// The body may be omitted when every field of the request is optional
+	if c.Request.ContentLength == 0 {
+		err = binding.Validator.ValidateStruct(req)
+	} else {
+		err = c.ShouldBindJSON(req)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	user, err := action(actor, userID)
+	if err != nil {
+		handleAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.UserResponse{User: *user})
+}
+
+// userFilterQuery parses the user filter from the query string, responding with 400 when it is invalid
+func userFilterQuery(c *gin.Context) (entity.UserFilter, bool) {
+	var filter entity.UserFilter
+	var err error
+	var ok bool
+	if value := c.Query("status"); value != "" {
+		if filter.Status, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid status"})
+			return filter, false
+		}
+	}
+	if filter.Role = c.Query("role"); filter.Role != "" && !entity.IsValidRole(filter.Role) {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid role"})
+		return filter, false
+	}
+	if value := c.Query("premium"); value != "" {
+		premium, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid premium"})
+			return filter, false
+		}
+		filter.Premium = &premium
+	}
+	if filter.CreatedAfter, ok = timeQuery(c, "created_after"); !ok {
+		return filter, false
+	}
+	if filter.CreatedBefore, ok = timeQuery(c, "created_before"); !ok {
+		return filter, false
+	}
+	return filter, true
+}
+
+// timeQuery parses an optional RFC 3339 time from the query string, responding with 400 when it is invalid
+func timeQuery(c *gin.Context, param string) (*time.Time, bool) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, true
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid " + param})
+		return nil, false
+	}
+	return &parsed, true
+}
+
+// adminPageQuery parses the page and page size from the query string, responding with 400 when they are invalid
+func adminPageQuery(c *gin.Context) (int, int, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid page"})
+		return 0, 0, false
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(service.DefaultAdminPageSize)))
+	if err != nil || pageSize < 1 || pageSize > service.MaxAdminPageSize {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid page size"})
+		return 0, 0, false
+	}
+	return page, pageSize, true
+}
+
+func handleAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidRole):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrCannotModerateSelf), errors.Is(err, service.ErrInsufficientRole):
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrUserStatusConflict):
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("Admin request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
@@ -0,0 +1,286 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAdminRouter(controller *AdminController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	admin := router.Group("/admin")
+	admin.Use(middleware.NewMockAuthMiddleware().MustAuthAs(adminUser))
+	admin.GET("/users", controller.ListUsers)
+	admin.POST("/users/:user_id/suspend", controller.SuspendUser)
+	admin.POST("/users/:user_id/unsuspend", controller.UnsuspendUser)
+	admin.POST("/users/:user_id/password-reset", controller.ForcePasswordReset)
+	admin.PUT("/users/:user_id/role", controller.ChangeRole)
+	admin.POST("/users/:user_id/restore", controller.RestoreUser)
+	admin.POST("/users/:user_id/unlock", controller.UnlockUser)
+	admin.GET("/audit-logs", controller.ListAuditLogs)
+
+	return router
+}
+
+func TestAdminListUsers(t *testing.T) {
+	mockService := new(service.MockAdminService)
+	router := setupAdminRouter(NewAdminController(mockService))
+
+	t.Run("Success", func(t *testing.T) {
+		premium := false
+		after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		filter := entity.UserFilter{Status: entity.UserStatusSuspended, Role: entity.UserRoleUser, Premium: &premium, CreatedAfter: &after}
+		mockService.On("ListUsers", filter, 2, 10).Return([]entity.User{*otherUser}, 11, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/admin/users?status=9&role=User&premium=false&created_after=2024-01-01T00:00:00Z&page=2&page_size=10", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.UserPageResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, resp.Users, 1)
+		assert.Equal(t, 2, resp.Page)
+		assert.Equal(t, 11, resp.Total)
+	})
+
+	for _, query := range []string{"?status=x", "?role=Owner", "?premium=maybe", "?created_before=yesterday", "?page=0", "?page_size=1000"} {
+		t.Run("Invalid Query "+query, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/admin/users"+query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAdminSuspendUser(t *testing.T) {
+	mockService := new(service.MockAdminService)
+	router := setupAdminRouter(NewAdminController(mockService))
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("SuspendUser", adminUser, uint64(2), "spam").
+			Return(&entity.User{ID: 2, Status: entity.UserStatusSuspended}, nil).Once()
+
+		body, _ := json.Marshal(SuspendUserRequest{Reason: "spam"})
+		req, _ := http.NewRequest("POST", "/admin/users/2/suspend", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.UserResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, entity.UserStatusSuspended, resp.User.Status)
+	})
+
+	for _, body := range []string{"", "{}"} {
+		t.Run("Missing Reason "+body, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/admin/users/2/suspend", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"Already Suspended", service.ErrUserStatusConflict, http.StatusConflict},
+		{"Admin Target", service.ErrInsufficientRole, http.StatusForbidden},
+		{"User Not Found", service.ErrUserNotFound, http.StatusNotFound},
+		{"Internal Error", errors.New("database error"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService.On("SuspendUser", adminUser, uint64(2), "spam").Return(nil, tt.err).Once()
+
+			body, _ := json.Marshal(SuspendUserRequest{Reason: "spam"})
+			req, _ := http.NewRequest("POST", "/admin/users/2/suspend", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAdminUserActionsWithoutReason(t *testing.T) {
+	mockService := new(service.MockAdminService)
+	router := setupAdminRouter(NewAdminController(mockService))
+	restored := &entity.User{ID: 2, Status: entity.UserStatusAvailable}
+	mockService.On("UnsuspendUser", adminUser, uint64(2), "").Return(restored, nil).Once()
+	mockService.On("ForcePasswordReset", adminUser, uint64(2), "").Return(&entity.User{ID: 2, PasswordResetRequired: true}, nil).Once()
+	mockService.On("RestoreUser", adminUser, uint64(2), "").Return(restored, nil).Once()
+
+	for _, path := range []string{"/admin/users/2/unsuspend", "/admin/users/2/password-reset", "/admin/users/2/restore"} {
+		t.Run(path, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+
+	t.Run("Invalid User ID", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/admin/users/abc/restore", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAdminChangeRole(t *testing.T) {
+	mockService := new(service.MockAdminService)
+	router := setupAdminRouter(NewAdminController(mockService))
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("ChangeRole", adminUser, uint64(2), entity.UserRoleModerator, "trusted").
+			Return(&entity.User{ID: 2, Role: entity.UserRoleModerator}, nil).Once()
+
+		body, _ := json.Marshal(ChangeRoleRequest{Role: entity.UserRoleModerator, Reason: "trusted"})
+		req, _ := http.NewRequest("PUT", "/admin/users/2/role", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.UserResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, entity.UserRoleModerator, resp.User.Role)
+	})
+
+	tests := []struct {
+		name string
+		role string
+		err  error
+		code int
+	}{
+		{"Invalid Role", "Owner", service.ErrInvalidRole, http.StatusBadRequest},
+		{"User Not Found", entity.UserRoleAdmin, service.ErrUserNotFound, http.StatusNotFound},
+		{"Own Role", entity.UserRoleUser, service.ErrCannotModerateSelf, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService.On("ChangeRole", adminUser, uint64(2), tt.role, "").Return(nil, tt.err).Once()
+
+			body, _ := json.Marshal(ChangeRoleRequest{Role: tt.role})
+			req, _ := http.NewRequest("PUT", "/admin/users/2/role", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+
+	t.Run("Missing Role", func(t *testing.T) {
+		req, _ := http.NewRequest("PUT", "/admin/users/2/role", bytes.NewBufferString(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAdminListAuditLogs(t *testing.T) {
+	mockService := new(service.MockAdminService)
+	router := setupAdminRouter(NewAdminController(mockService))
+
+	t.Run("Success", func(t *testing.T) {
+		auditLogs := []entity.AuditLog{{ID: 3, ActorID: 3, Action: entity.AuditActionUserSuspend, TargetUserID: 2, Reason: "spam"}}
+		mockService.On("ListAuditLogs", uint64(2), 1, service.DefaultAdminPageSize).Return(auditLogs, 1, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/admin/audit-logs?user_id=2", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.AuditLogPageResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, resp.AuditLogs, 1)
+		assert.Equal(t, entity.AuditActionUserSuspend, resp.AuditLogs[0].Action)
+	})
+
+	t.Run("Invalid User ID", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/admin/audit-logs?user_id=abc", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAdminUnlockUser(t *testing.T) {
+	mockService := new(service.MockAdminService)
+	router := setupAdminRouter(NewAdminController(mockService))
+
+	tests := []struct {
+		name string
+		body string
+		ip   string
+	}{
+		{"Without Body", "", ""},
+		{"With IP", `{"ip":"10.0.0.1","reason":"office network"}`, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := ""
+			if tt.ip != "" {
+				reason = "office network"
+			}
+			mockService.On("UnlockUser", adminUser, uint64(2), tt.ip, reason).Return(&entity.User{ID: 2}, nil).Once()
+
+			req, _ := http.NewRequest("POST", "/admin/users/2/unlock", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+
+	t.Run("Invalid IP", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/admin/users/2/unlock", bytes.NewBufferString(`{"ip":"nowhere"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyController lets the authenticated user manage their personal API keys
+type APIKeyController struct {
+	apiKeyService service.APIKeyService
+}
+
+// NewAPIKeyController creates a new APIKeyController
+func NewAPIKeyController(apiKeyService service.APIKeyService) *APIKeyController {
+	return &APIKeyController{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// CreateAPIKeyRequest represents the request body for creating an API key
+type CreateAPIKeyRequest struct {
+	Name      string               `json:"name" binding:"required,max=100"`
+	Scopes    []entity.APIKeyScope `json:"scopes" binding:"required,min=1"` // e.g. videos:read, transcriptions:write
+	ExpiresAt *time.Time           `json:"expires_at"`                      // RFC 3339; omitted for a key that does not expire
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Creates a personal API key for scripts, limited to the scopes: videos, transcriptions and audios, each with read or write access. Write access includes read access. The key is sent in the X-API-Key header and is returned only once
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateAPIKeyRequest true "Name, scopes and optional expiry"
+// @Success 201 {object} response.APIKeyCreatedResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /users/api-keys [post]
+func (h *APIKeyController) CreateAPIKey(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	apiKey, key, err := h.apiKeyService.CreateAPIKey(userInfo.ID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		handleAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.APIKeyCreatedResponse{APIKey: *apiKey, Key: key})
+}
+
+// ListAPIKeys godoc
+// @Summary List API keys
+// @Description Lists the API keys of the current user that were not revoked, with their scopes, expiry and when they were last used
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.APIKeysResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /users/api-keys [get]
+func (h *APIKeyController) ListAPIKeys(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	apiKeys, err := h.apiKeyService.ListAPIKeys(userInfo.ID)
+	if err != nil {
+		handleAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.APIKeysResponse{APIKeys: apiKeys})
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revokes an API key of the current user, which stops working at once
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Param key_id path int true "API key ID"
+// @Success 200 {object} response.MessageResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /users/api-keys/{key_id} [delete]
+func (h *APIKeyController) RevokeAPIKey(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	keyID, err := strconv.ParseUint(c.Param("key_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid API key ID"})
+		return
+	}
+
+	if err := h.apiKeyService.RevokeAPIKey(userInfo.ID, keyID); err != nil {
+		handleAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "API key revoked"})
+}
+
+func handleAPIKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrAPIKeyNameRequired), errors.Is(err, service.ErrInvalidAPIKeyScope), errors.Is(err, service.ErrInvalidAPIKeyExpiry):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrTooManyAPIKeys):
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("API key request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
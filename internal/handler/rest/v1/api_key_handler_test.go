@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupAPIKeyRouter(controller *APIKeyController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	apiKeys := router.Group("/users/api-keys")
+	apiKeys.Use(middleware.NewMockAuthMiddleware().MustAuthAs(ownerUser))
+	apiKeys.POST("", controller.CreateAPIKey)
+	apiKeys.GET("", controller.ListAPIKeys)
+	apiKeys.DELETE("/:key_id", controller.RevokeAPIKey)
+
+	return router
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	mockService := new(service.MockAPIKeyService)
+	router := setupAPIKeyRouter(NewAPIKeyController(mockService))
+	scopes := []entity.APIKeyScope{entity.APIKeyScopeVideosWrite}
+
+	tests := []struct {
+		name       string
+		body       string
+		setup      func()
+		wantStatus int
+	}{
+		{"Success", `{"name":"upload script","scopes":["videos:write"]}`, func() {
+			apiKey := &entity.APIKey{ID: 5, UserID: ownerUser.ID, Name: "upload script", Prefix: "mlvt_abcdefg", Scopes: scopes}
+			mockService.On("CreateAPIKey", ownerUser.ID, "upload script", scopes, mock.Anything).Return(apiKey, "mlvt_abcdefgh", nil).Once()
+		}, http.StatusCreated},
+		{"Missing Scopes", `{"name":"upload script","scopes":[]}`, func() {}, http.StatusBadRequest},
+		{"Unknown Scope", `{"name":"upload script","scopes":["videos:write"]}`, func() {
+			mockService.On("CreateAPIKey", ownerUser.ID, "upload script", scopes, mock.Anything).Return(nil, "", service.ErrInvalidAPIKeyScope).Once()
+		}, http.StatusBadRequest},
+		{"Too Many", `{"name":"upload script","scopes":["videos:write"]}`, func() {
+			mockService.On("CreateAPIKey", ownerUser.ID, "upload script", scopes, mock.Anything).Return(nil, "", service.ErrTooManyAPIKeys).Once()
+		}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			req, _ := http.NewRequest("POST", "/users/api-keys", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusCreated {
+				var resp response.APIKeyCreatedResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, "mlvt_abcdefgh", resp.Key)
+				assert.Equal(t, uint64(5), resp.APIKey.ID)
+				assert.NotContains(t, w.Body.String(), "key_hash")
+			}
+		})
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestListAPIKeys(t *testing.T) {
+	mockService := new(service.MockAPIKeyService)
+	router := setupAPIKeyRouter(NewAPIKeyController(mockService))
+
+	apiKeys := []entity.APIKey{{ID: 5, UserID: ownerUser.ID, Name: "upload script", KeyHash: "secret hash"}}
+	mockService.On("ListAPIKeys", ownerUser.ID).Return(apiKeys, nil).Once()
+
+	req, _ := http.NewRequest("GET", "/users/api-keys", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp response.APIKeysResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, resp.APIKeys, 1)
+	assert.NotContains(t, w.Body.String(), "secret hash")
+	mockService.AssertExpectations(t)
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	mockService := new(service.MockAPIKeyService)
+	router := setupAPIKeyRouter(NewAPIKeyController(mockService))
+
+	tests := []struct {
+		name       string
+		path       string
+		setup      func()
+		wantStatus int
+	}{
+		{"Success", "/users/api-keys/5", func() {
+			mockService.On("RevokeAPIKey", ownerUser.ID, uint64(5)).Return(nil).Once()
+		}, http.StatusOK},
+		{"Not Found", "/users/api-keys/6", func() {
+			mockService.On("RevokeAPIKey", ownerUser.ID, uint64(6)).Return(service.ErrAPIKeyNotFound).Once()
+		}, http.StatusNotFound},
+		{"Invalid ID", "/users/api-keys/abc", func() {}, http.StatusBadRequest},
+		{"Internal Error", "/users/api-keys/7", func() {
+			mockService.On("RevokeAPIKey", ownerUser.ID, uint64(7)).Return(errors.New("db error")).Once()
+		}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			req, _ := http.NewRequest("DELETE", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	mockService.AssertExpectations(t)
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"mlvt/internal/entity"
 	"mlvt/internal/infra/env"
+	"mlvt/internal/pkg/middleware"
 	"mlvt/internal/pkg/response"
 	"mlvt/internal/service"
 	"net/http"
@@ -12,26 +13,39 @@ import (
 )
 
 type AudioController struct {
-	audioService service.AudioService
+	audioService        service.AudioService
+	organizationService service.OrganizationService
+	ownershipService    service.OwnershipService
 }
 
-func NewAudioController(audioService service.AudioService) *AudioController {
+func NewAudioController(audioService service.AudioService, organizationService service.OrganizationService,
+	ownershipService service.OwnershipService) *AudioController {
 	return &AudioController{
-		audioService: audioService,
+		audioService:        audioService,
+		organizationService: organizationService,
+		ownershipService:    ownershipService,
 	}
 }
 
 // GenerateUploadURL godoc
 // @Summary Generate presigned upload URL
-// @Description Generates a presigned URL to upload an audio file to the storage service.
+// @Description Generates a presigned URL to upload an audio file to the storage service. The file is stored under the prefix of the current user; add the audio with the returned file_name.
 // @Tags audios
 // @Produce json
 // @Param file_name query string true "Name of the file to be uploaded"
 // @Param file_type query string true "MIME type of the file (e.g., audio/mpeg)"
-// @Success 200 {object} response.UploadURLResponse "upload_url"
+// @Success 200 {object} response.UploadURLResponse "upload_url, file_name"
+// @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 401 {object} response.ErrorResponse "error"
 // @Failure 500 {object} response.ErrorResponse "error"
 // @Router /audios/generate-upload-url [get]
 func (h *AudioController) GenerateUploadURL(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
 	folder := env.EnvConfig.AudioFolder
 	fileName := c.Query("file_name")
 	fileType := c.Query("file_type")
@@ -41,6 +55,10 @@ func (h *AudioController) GenerateUploadURL(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "file_name and file_type are required"})
 		return
 	}
+	if fileName, ok = userFileName(userInfo.ID, fileName); !ok {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid file_name"})
+		return
+	}
 
 	url, err := h.audioService.GeneratePresignedUploadURL(folder, fileName, fileType)
 	if err != nil {
@@ -48,7 +66,7 @@ func (h *AudioController) GenerateUploadURL(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, response.UploadURLResponse{UploadURL: url})
+	c.JSON(http.StatusOK, response.UploadURLResponse{UploadURL: url, FileName: fileName})
 }
 
 // GenerateDownloadURL godoc
@@ -56,14 +74,14 @@ func (h *AudioController) GenerateUploadURL(c *gin.Context) {
 // @Description Generates a presigned URL to download an audio file from the storage service.
 // @Tags audios
 // @Produce json
-// @Param audio_id path uint64 true "ID of the audio file"
+// @Param audioID path uint64 true "ID of the audio file"
 // @Success 200 {object} response.DownloadURLResponse "download_url"
 // @Failure 400 {object} response.ErrorResponse "error"
 // @Failure 500 {object} response.ErrorResponse "error"
-// @Router /audios/{audio_id}/download-url [get]
+// @Router /audios/{audioID}/download-url [get]
 func (h *AudioController) GenerateDownloadURL(c *gin.Context) {
 	// Parse audio ID from the URL path
-	audioIDStr := c.Param("audio_id")
+	audioIDStr := c.Param("audioID")
 	audioID, err := strconv.ParseUint(audioIDStr, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid audio ID"})
@@ -83,28 +101,88 @@ func (h *AudioController) GenerateDownloadURL(c *gin.Context) {
 
 // AddAudio godoc
 // @Summary Add audio
-// @Description Adds a new audio file's metadata to the system.
+// @Description Adds a new audio file's metadata to the system in the pending_upload state. Call the finalize endpoint once the file is uploaded. The file is looked up in the audio folder under the prefix of the current user, who must be allowed to change the video.
 // @Tags audios
 // @Accept json
 // @Produce json
 // @Param audio body entity.Audio true "Audio object"
-// @Success 201 {object} response.MessageResponse "message"
+// @Success 201 {object} response.CreatedResponse "message, id"
 // @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 401 {object} response.ErrorResponse "error"
+// @Failure 403 {object} response.ErrorResponse "error"
+// @Failure 404 {object} response.ErrorResponse "error"
 // @Failure 500 {object} response.ErrorResponse "error"
 // @Router /audios [post]
 func (h *AudioController) AddAudio(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
 	var audio entity.Audio
 	if err := c.ShouldBindJSON(&audio); err != nil {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	// The owner defaults to the current user; only admins may add an audio for another user
+	if audio.UserID == 0 {
+		audio.UserID = userInfo.ID
+	}
+	if !middleware.CanAccess(userInfo, audio.UserID) {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "cannot add an audio for another user"})
+		return
+	}
+	if !canAddToOrg(c, h.organizationService, userInfo, audio.OrgID) {
+		return
+	}
+	if !canWriteVideo(c, h.ownershipService, userInfo, audio.VideoID) {
+		return
+	}
+
+	// The file is the one the current user uploaded; the key is never taken from the client
+	audio.Folder = env.EnvConfig.AudioFolder
+	if audio.FileName, ok = userFileName(userInfo.ID, audio.FileName); !ok {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid file_name"})
+		return
+	}
+
 	if err := h.audioService.CreateAudio(&audio); err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, response.MessageResponse{Message: "Audio added successfully"})
+	c.JSON(http.StatusCreated, response.CreatedResponse{Message: "Audio added successfully", ID: audio.ID})
+}
+
+// FinalizeAudioUpload godoc
+// @Summary Confirm the upload of an audio file
+// @Description Checks that the audio file exists in storage and matches the declared content type, records its size and ETag, and marks the audio ready
+// @Tags audios
+// @Produce json
+// @Param audioID path uint64 true "ID of the audio file"
+// @Success 200 {object} response.AudioResponse "audio"
+// @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 404 {object} response.ErrorResponse "error"
+// @Failure 409 {object} response.ErrorResponse "file has not been uploaded"
+// @Failure 422 {object} response.ErrorResponse "file type mismatch"
+// @Failure 500 {object} response.ErrorResponse "error"
+// @Router /audios/{audioID}/finalize [post]
+func (h *AudioController) FinalizeAudioUpload(c *gin.Context) {
+	audioID, err := strconv.ParseUint(c.Param("audioID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid audio ID"})
+		return
+	}
+
+	audio, err := h.audioService.FinalizeAudioUpload(audioID)
+	if err != nil {
+		handleFinalizeError(c, err, "audio not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.AudioResponse{Audio: *audio})
 }
 
 // GetAudio godoc
@@ -112,12 +190,12 @@ func (h *AudioController) AddAudio(c *gin.Context) {
 // @Description Retrieves an audio file's metadata and generates a presigned download URL.
 // @Tags audios
 // @Produce json
-// @Param audio_id path uint64 true "ID of the audio file"
+// @Param audioID path uint64 true "ID of the audio file"
 // @Success 200 {object} response.AudioResponse "audio, download_url"
 // @Failure 404 {object} response.ErrorResponse "error"
-// @Router /audios/{audio_id} [get]
+// @Router /audios/{audioID} [get]
 func (h *AudioController) GetAudio(c *gin.Context) {
-	audioIDStr := c.Param("audio_id")
+	audioIDStr := c.Param("audioID")
 	audioID, err := strconv.ParseUint(audioIDStr, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid audio ID"})
@@ -183,12 +261,12 @@ func (h *AudioController) GetAudioByUser(c *gin.Context) {
 // @Description Retrieves all audio files belonging to a specific user.
 // @Tags audios
 // @Produce json
-// @Param user_id path uint64 true "ID of the user"
+// @Param userID path uint64 true "ID of the user"
 // @Success 200 {object} response.AudiosResponse "audios"
 // @Failure 500 {object} response.ErrorResponse "error"
-// @Router /audios/user/{user_id} [get]
+// @Router /audios/user/{userID} [get]
 func (h *AudioController) ListAudiosByUserID(c *gin.Context) {
-	userIDStr := c.Param("user_id")
+	userIDStr := c.Param("userID")
 	userID, err := strconv.ParseUint(userIDStr, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid user ID"})
@@ -204,6 +282,33 @@ func (h *AudioController) ListAudiosByUserID(c *gin.Context) {
 	c.JSON(http.StatusOK, response.AudiosResponse{Audios: transcriptions})
 }
 
+// ListAudiosByOrgID godoc
+// @Summary List audios by organization ID
+// @Description Retrieves all audio files shared with an organization. Needs membership of the organization.
+// @Tags audios
+// @Produce json
+// @Param orgID path uint64 true "ID of the organization"
+// @Success 200 {object} response.AudiosResponse "audios"
+// @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 403 {object} response.ErrorResponse "error"
+// @Failure 500 {object} response.ErrorResponse "error"
+// @Router /audios/org/{orgID} [get]
+func (h *AudioController) ListAudiosByOrgID(c *gin.Context) {
+	orgID, err := strconv.ParseUint(c.Param("orgID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid organization ID"})
+		return
+	}
+
+	audios, err := h.audioService.ListAudiosByOrgID(orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.AudiosResponse{Audios: audios})
+}
+
 // GetAudioByVideoID godoc
 // @Summary Get audio by video and audio ID
 // @Description Retrieves an audio file for a specific video and generates a presigned download URL.
@@ -251,13 +356,13 @@ func (h *AudioController) GetAudioByVideoID(c *gin.Context) {
 // @Description Retrieves all audio files belonging to a specific video.
 // @Tags audios
 // @Produce json
-// @Param video_id path uint64 true "ID of the video"
+// @Param videoID path uint64 true "ID of the video"
 // @Success 200 {object} response.AudiosResponse "audios"
 // @Failure 400 {object} response.ErrorResponse "error"
 // @Failure 500 {object} response.ErrorResponse "error"
-// @Router /audios/video/{video_id} [get]
+// @Router /audios/video/{videoID} [get]
 func (h *AudioController) ListAudiosByVideoID(c *gin.Context) {
-	videoIDStr := c.Param("video_id")
+	videoIDStr := c.Param("videoID")
 	videoID, err := strconv.ParseUint(videoIDStr, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid video ID"})
@@ -277,14 +382,14 @@ func (h *AudioController) ListAudiosByVideoID(c *gin.Context) {
 // @Summary Delete audio by ID
 // @Description Deletes an audio file from the system.
 // @Tags audios
-// @Param audio_id path uint64 true "ID of the audio file"
+// @Param audioID path uint64 true "ID of the audio file"
 // @Success 200 {object} response.MessageResponse "message"
 // @Failure 400 {object} response.ErrorResponse "error"
 // @Failure 500 {object} response.ErrorResponse "error"
-// @Router /audios/{audio_id} [delete]
+// @Router /audios/{audioID} [delete]
 func (h *AudioController) DeleteAudio(c *gin.Context) {
 	// Parse audio ID from the URL path
-	audioIDStr := c.Param("audio_id")
+	audioIDStr := c.Param("audioID")
 	audioID, err := strconv.ParseUint(audioIDStr, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid audio ID"})
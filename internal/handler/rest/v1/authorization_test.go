@@ -0,0 +1,444 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	ownerUser     = &entity.User{ID: 1, Role: entity.UserRoleUser, Status: entity.UserStatusAvailable}
+	otherUser     = &entity.User{ID: 2, Role: entity.UserRoleUser, Status: entity.UserStatusAvailable}
+	adminUser     = &entity.User{ID: 3, Role: entity.UserRoleAdmin, Status: entity.UserStatusAvailable}
+	moderatorUser = &entity.User{ID: 4, Role: entity.UserRoleModerator, Status: entity.UserStatusAvailable}
+)
+
+type authorizationControllers struct {
+	users          *service.MockUserService
+	videos         *service.MockVideoService
+	subscriptions  *service.MockSubscriptionService
+	transcriptions *service.MockTranscriptionService
+	owners         *service.MockOwnershipService
+	admins         *service.MockAdminService
+}
+
+// setupAuthorizationRouter registers user-scoped routes behind the ownership middleware, as the app router does,
+// with userInfo as the authenticated user
+func setupAuthorizationRouter(userInfo *entity.User) (*gin.Engine, *authorizationControllers) {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	mocks := &authorizationControllers{
+		users:          new(service.MockUserService),
+		videos:         new(service.MockVideoService),
+		subscriptions:  new(service.MockSubscriptionService),
+		transcriptions: new(service.MockTranscriptionService),
+		owners:         new(service.MockOwnershipService),
+		admins:         new(service.MockAdminService),
+	}
+	ownership := middleware.NewOwnershipMiddleware(mocks.owners)
+	userController := NewUserController(mocks.users)
+	videoController := NewVideoController(mocks.videos, nil, mocks.subscriptions)
+	transcriptionController := NewTranscriptionController(mocks.transcriptions, nil, mocks.owners)
+	audioController := NewAudioController(nil, nil, mocks.owners)
+	adminController := NewAdminController(mocks.admins)
+	paymentController := NewPaymentController(nil, nil)
+	permissions := &middleware.AuthUserMiddleware{}
+
+	api := router.Group("/")
+	api.Use(middleware.NewMockAuthMiddleware().MustAuthAs(userInfo))
+
+	users := api.Group("/users", ownership.MustOwnUser("user_id"))
+	users.GET("/:user_id", userController.GetUser)
+	users.PUT("/:user_id/change-password", userController.ChangePassword)
+
+	api.POST("/videos", videoController.AddVideo)
+	api.POST("/videos/generate-upload-url/video", videoController.GenerateUploadURLForVideo)
+	api.POST("/videos/generate-upload-url/image", videoController.GenerateUploadURLForImage)
+	api.POST("/videos/uploads", videoController.InitiateMultipartUpload)
+	api.GET("/videos/user/:user_id", ownership.MustOwnUser("user_id"), videoController.ListVideosByUserID)
+	api.DELETE("/videos/:video_id", ownership.MustOwnVideo("video_id", entity.PermissionVideoDeleteAny), videoController.DeleteVideo)
+
+	api.POST("/transcriptions", transcriptionController.AddTranscription)
+	api.POST("/transcriptions/import", transcriptionController.ImportTranscription)
+	api.GET("/transcriptions/:transcriptionID/segments", ownership.MustOwnTranscription("transcriptionID"), transcriptionController.ListSegments)
+
+	api.POST("/audios", audioController.AddAudio)
+	api.DELETE("/audios/:audioID", ownership.MustOwnAudio("audioID"), audioController.DeleteAudio)
+
+	api.GET("/admin/users", permissions.RequirePermission(entity.PermissionUserList), adminController.ListUsers)
+	api.PUT("/admin/users/:user_id/role", permissions.RequirePermission(entity.PermissionUserAssignRole), adminController.ChangeRole)
+	api.POST("/payments/:provider/refund", permissions.RequirePermission(entity.PermissionPaymentRefund), paymentController.RefundPayment)
+
+	return router, mocks
+}
+
+func TestCrossUserAccessDenied(t *testing.T) {
+	router, mocks := setupAuthorizationRouter(otherUser)
+	mocks.owners.On("VideoOwner", uint64(5)).Return(&service.ResourceOwner{UserID: ownerUser.ID}, nil)
+	mocks.owners.On("AudioOwner", uint64(6)).Return(&service.ResourceOwner{UserID: ownerUser.ID}, nil)
+	mocks.owners.On("TranscriptionOwner", uint64(7)).Return(&service.ResourceOwner{UserID: ownerUser.ID}, nil)
+
+	password, _ := json.Marshal(map[string]string{"old_password": "old", "new_password": "new"})
+	toVideo, _ := json.Marshal(map[string]interface{}{"video_id": 5, "file_name": "subs.json"})
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   []byte
+	}{
+		{"Get Other User", "GET", "/users/1", nil},
+		{"Change Password Of Other User", "PUT", "/users/1/change-password", password},
+		{"List Videos Of Other User", "GET", "/videos/user/1", nil},
+		{"Delete Video Of Other User", "DELETE", "/videos/5", nil},
+		{"List Segments Of Other User", "GET", "/transcriptions/7/segments", nil},
+		{"Delete Audio Of Other User", "DELETE", "/audios/6", nil},
+		{"Add Transcription To Video Of Other User", "POST", "/transcriptions", toVideo},
+		{"Add Audio To Video Of Other User", "POST", "/audios", toVideo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBuffer(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+		})
+	}
+
+	t.Run("Import Transcription To Video Of Other User", func(t *testing.T) {
+		content := []byte("1\n00:00:00,000 --> 00:00:01,000\nHello\n")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newImportRequest(t, map[string]string{"video_id": "5", "lang": "vi"}, "episode.srt", content))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	// None of the requests reached the services
+	mocks.users.AssertNotCalled(t, "ChangePassword")
+	mocks.users.AssertNotCalled(t, "GetUserByID")
+	mocks.videos.AssertNotCalled(t, "DeleteVideo")
+	mocks.videos.AssertNotCalled(t, "ListVideosByUserID")
+	mocks.transcriptions.AssertNotCalled(t, "ListSegmentsPage")
+	mocks.transcriptions.AssertNotCalled(t, "CreateTranscription")
+	mocks.transcriptions.AssertNotCalled(t, "ImportTranscription")
+}
+
+// TestCrossUserStorageKeys checks that video files are always stored and recorded under the prefix of the current
+// user, so a user cannot overwrite the files of another, nor add a video record pointing at them and then download
+// or delete them through it
+func TestCrossUserStorageKeys(t *testing.T) {
+	router, mocks := setupAuthorizationRouter(otherUser)
+	mocks.subscriptions.On("CheckVideoQuota", otherUser.ID, mock.AnythingOfType("int")).Return(nil)
+
+	t.Run("Overwrite Video Of Other User", func(t *testing.T) {
+		mocks.videos.On("GeneratePresignedUploadURLForVideo", env.EnvConfig.VideosFolder, "user_2/clip.mp4", "video/mp4").Return("https://upload", nil).Once()
+
+		req, _ := http.NewRequest("POST", "/videos/generate-upload-url/video?file_name=../user_1/clip.mp4&file_type=video/mp4", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp map[string]string
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "user_2/clip.mp4", resp["file_name"])
+	})
+
+	t.Run("Overwrite Thumbnail Of Other User", func(t *testing.T) {
+		mocks.videos.On("GeneratePresignedUploadURLForImage", env.EnvConfig.VideoFramesFolder, "user_2/clip.jpg", "image/jpeg").Return("https://upload", nil).Once()
+
+		req, _ := http.NewRequest("POST", "/videos/generate-upload-url/image?file_name=user_1/clip.jpg&file_type=image/jpeg", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Multipart Upload Over Video Of Other User", func(t *testing.T) {
+		session := &entity.UploadSession{UploadID: "upload-1", UserID: otherUser.ID, FileName: "user_2/clip.mp4"}
+		mocks.videos.On("InitiateMultipartUpload", otherUser.ID, env.EnvConfig.VideosFolder, "user_2/clip.mp4", "video/mp4", int64(1<<30)).Return(session, nil).Once()
+
+		body, _ := json.Marshal(InitiateUploadRequest{FileName: "user_1/clip.mp4", FileType: "video/mp4", FileSize: 1 << 30})
+		req, _ := http.NewRequest("POST", "/videos/uploads", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	// Downloading and deleting a video use the keys of its record, so a record must not name the files of others
+	t.Run("Video Record Pointing At Files Of Other User", func(t *testing.T) {
+		ownFiles := mock.MatchedBy(func(video *entity.Video) bool {
+			return video.Folder == env.EnvConfig.VideosFolder && video.FileName == "user_2/clip.mp4" && video.Image == "user_2/clip.jpg"
+		})
+		mocks.videos.On("CreateVideo", ownFiles).Return(nil).Once()
+
+		body, _ := json.Marshal(map[string]interface{}{"title": "Not mine", "folder": "transcriptions", "file_name": "user_1/clip.mp4", "image": "../user_1/clip.jpg"})
+		req, _ := http.NewRequest("POST", "/videos", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("Video Record Without File Name", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{"title": "Nothing", "file_name": ".."})
+		req, _ := http.NewRequest("POST", "/videos", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mocks.videos.AssertExpectations(t)
+}
+
+func TestOwnerAccessAllowed(t *testing.T) {
+	router, mocks := setupAuthorizationRouter(ownerUser)
+
+	t.Run("Change Own Password", func(t *testing.T) {
+		mocks.users.On("ChangePassword", ownerUser.ID, "old", "new").Return(nil).Once()
+
+		body, _ := json.Marshal(map[string]string{"old_password": "old", "new_password": "new"})
+		req, _ := http.NewRequest("PUT", "/users/1/change-password", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Delete Own Video", func(t *testing.T) {
+		mocks.owners.On("VideoOwner", uint64(5)).Return(&service.ResourceOwner{UserID: ownerUser.ID}, nil).Once()
+		mocks.videos.On("DeleteVideo", uint64(5)).Return(nil).Once()
+
+		req, _ := http.NewRequest("DELETE", "/videos/5", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Video Not Found", func(t *testing.T) {
+		mocks.owners.On("VideoOwner", uint64(8)).Return(nil, service.ErrVideoNotFound).Once()
+
+		req, _ := http.NewRequest("DELETE", "/videos/8", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Invalid Video ID", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/videos/abc", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Add Transcription To Own Video", func(t *testing.T) {
+		mocks.owners.On("VideoOwner", uint64(5)).Return(&service.ResourceOwner{UserID: ownerUser.ID}, nil).Once()
+		ownFile := mock.MatchedBy(func(transcription *entity.Transcription) bool {
+			return transcription.Folder == env.EnvConfig.TranscriptionsFolder && transcription.FileName == "user_1/subs.json"
+		})
+		mocks.transcriptions.On("CreateTranscription", ownFile).Return(nil).Once()
+
+		// The storage key is built from the base name, whatever folder the client sends
+		body, _ := json.Marshal(map[string]interface{}{"video_id": 5, "folder": "other", "file_name": "../user_2/subs.json"})
+		req, _ := http.NewRequest("POST", "/transcriptions", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	mocks.users.AssertExpectations(t)
+	mocks.videos.AssertExpectations(t)
+	mocks.transcriptions.AssertExpectations(t)
+	mocks.owners.AssertExpectations(t)
+}
+
+func TestAdminOverride(t *testing.T) {
+	router, mocks := setupAuthorizationRouter(adminUser)
+
+	t.Run("Change Password Of Other User", func(t *testing.T) {
+		mocks.users.On("ChangePassword", ownerUser.ID, "old", "new").Return(nil).Once()
+
+		body, _ := json.Marshal(map[string]string{"old_password": "old", "new_password": "new"})
+		req, _ := http.NewRequest("PUT", "/users/1/change-password", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Delete Video Of Other User", func(t *testing.T) {
+		mocks.owners.On("VideoOwner", uint64(5)).Return(&service.ResourceOwner{UserID: ownerUser.ID}, nil).Once()
+		mocks.videos.On("DeleteVideo", uint64(5)).Return(nil).Once()
+
+		req, _ := http.NewRequest("DELETE", "/videos/5", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("List Segments Of Other User", func(t *testing.T) {
+		mocks.owners.On("TranscriptionOwner", uint64(7)).Return(&service.ResourceOwner{UserID: ownerUser.ID}, nil).Once()
+		mocks.transcriptions.On("ListSegmentsPage", uint64(7), 1, service.DefaultSegmentPageSize).Return([]entity.TranscriptionSegment{}, 0, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/7/segments", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	mocks.users.AssertExpectations(t)
+	mocks.videos.AssertExpectations(t)
+	mocks.transcriptions.AssertExpectations(t)
+	mocks.owners.AssertExpectations(t)
+}
+
+func TestRequirePermission(t *testing.T) {
+	role, _ := json.Marshal(ChangeRoleRequest{Role: entity.UserRoleModerator})
+	tests := []struct {
+		name   string
+		user   *entity.User
+		method string
+		path   string
+		body   []byte
+	}{
+		{"User Lists Users", otherUser, "GET", "/admin/users", nil},
+		{"User Assigns Role", otherUser, "PUT", "/admin/users/2/role", role},
+		{"Moderator Assigns Role", moderatorUser, "PUT", "/admin/users/2/role", role},
+		{"User Refunds Payment", otherUser, "POST", "/payments/momo/refund", []byte(`{}`)},
+		{"Moderator Refunds Payment", moderatorUser, "POST", "/payments/momo/refund", []byte(`{}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, mocks := setupAuthorizationRouter(tt.user)
+
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBuffer(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			mocks.admins.AssertNotCalled(t, "ChangeRole")
+			mocks.admins.AssertNotCalled(t, "ListUsers")
+		})
+	}
+
+	t.Run("Moderator Lists Users", func(t *testing.T) {
+		router, mocks := setupAuthorizationRouter(moderatorUser)
+		mocks.admins.On("ListUsers", entity.UserFilter{}, 1, service.DefaultAdminPageSize).Return([]entity.User{*ownerUser, *otherUser}, 2, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/admin/users", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mocks.admins.AssertExpectations(t)
+	})
+
+	t.Run("Moderator Deletes Any Video", func(t *testing.T) {
+		router, mocks := setupAuthorizationRouter(moderatorUser)
+		mocks.owners.On("VideoOwner", uint64(5)).Return(&service.ResourceOwner{UserID: ownerUser.ID}, nil).Once()
+		mocks.videos.On("DeleteVideo", uint64(5)).Return(nil).Once()
+
+		req, _ := http.NewRequest("DELETE", "/videos/5", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mocks.videos.AssertExpectations(t)
+	})
+
+	t.Run("Moderator Cannot List Segments Of Other User", func(t *testing.T) {
+		router, mocks := setupAuthorizationRouter(moderatorUser)
+		mocks.owners.On("TranscriptionOwner", uint64(7)).Return(&service.ResourceOwner{UserID: ownerUser.ID}, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/7/segments", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestOrganizationAccess(t *testing.T) {
+	orgID := uint64(9)
+	shared := &service.ResourceOwner{UserID: ownerUser.ID, OrgID: &orgID}
+
+	t.Run("Viewer Reads Shared Transcription", func(t *testing.T) {
+		router, mocks := setupAuthorizationRouter(otherUser)
+		mocks.owners.On("TranscriptionOwner", uint64(7)).Return(shared, nil).Once()
+		mocks.owners.On("OrgRole", orgID, otherUser.ID).Return(entity.OrgRoleViewer, nil).Once()
+		mocks.transcriptions.On("ListSegmentsPage", uint64(7), 1, service.DefaultSegmentPageSize).Return([]entity.TranscriptionSegment{}, 0, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/7/segments", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mocks.transcriptions.AssertExpectations(t)
+	})
+
+	t.Run("Viewer Cannot Delete Shared Video", func(t *testing.T) {
+		router, mocks := setupAuthorizationRouter(otherUser)
+		mocks.owners.On("VideoOwner", uint64(5)).Return(shared, nil).Once()
+		mocks.owners.On("OrgRole", orgID, otherUser.ID).Return(entity.OrgRoleViewer, nil).Once()
+
+		req, _ := http.NewRequest("DELETE", "/videos/5", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mocks.videos.AssertNotCalled(t, "DeleteVideo")
+	})
+
+	t.Run("Editor Deletes Shared Video", func(t *testing.T) {
+		router, mocks := setupAuthorizationRouter(otherUser)
+		mocks.owners.On("VideoOwner", uint64(5)).Return(shared, nil).Once()
+		mocks.owners.On("OrgRole", orgID, otherUser.ID).Return(entity.OrgRoleEditor, nil).Once()
+		mocks.videos.On("DeleteVideo", uint64(5)).Return(nil).Once()
+
+		req, _ := http.NewRequest("DELETE", "/videos/5", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mocks.videos.AssertExpectations(t)
+	})
+
+	t.Run("Non-Member Cannot Read Shared Transcription", func(t *testing.T) {
+		router, mocks := setupAuthorizationRouter(otherUser)
+		mocks.owners.On("TranscriptionOwner", uint64(7)).Return(shared, nil).Once()
+		mocks.owners.On("OrgRole", orgID, otherUser.ID).Return(entity.OrgRole(""), nil).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/7/segments", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mocks.transcriptions.AssertNotCalled(t, "ListSegmentsPage")
+	})
+}
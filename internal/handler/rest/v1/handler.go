@@ -8,5 +8,15 @@ var ProviderSetHandler = wire.NewSet(
 	NewVideoController,
 	NewAudioController,
 	NewTranscriptionController,
-	NewMoMoPaymentHandler,
+	NewPaymentController,
+	NewOrderController,
+	NewSubscriptionController,
+	NewStorageController,
+	NewMLWorkerController,
+	NewPipelineController,
+	NewAdminController,
+	NewTwoFactorController,
+	NewAPIKeyController,
+	NewOIDCController,
+	NewOrganizationController,
 )
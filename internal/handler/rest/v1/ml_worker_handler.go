@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkerSecretHeader carries the shared registration secret of the ML workers
+const WorkerSecretHeader = "X-Worker-Secret"
+
+// RegisterWorkerRequest represents the request body for registering an ML worker
+type RegisterWorkerRequest struct {
+	Name      string              `json:"name" binding:"required"`
+	TaskTypes []entity.MLTaskType `json:"task_types" binding:"required,min=1"`
+	Languages []string            `json:"languages" binding:"required,min=1"`
+}
+
+// TaskProgressRequest represents the request body for reporting the progress of a task
+type TaskProgressRequest struct {
+	Progress int `json:"progress" binding:"min=0,max=100"`
+}
+
+// TaskResultRequest represents the request body for submitting the result of a task
+type TaskResultRequest struct {
+	Text     string `json:"text"`     // Transcribed or translated text, stt and mt only
+	Duration int    `json:"duration"` // Duration of the synthesized audio in seconds, tts only
+}
+
+// TaskFailureRequest represents the request body for reporting a failed task
+type TaskFailureRequest struct {
+	Error     string `json:"error" binding:"required"`
+	Retryable bool   `json:"retryable"`
+}
+
+type MLWorkerController struct {
+	workerService service.MLWorkerService
+}
+
+func NewMLWorkerController(workerService service.MLWorkerService) *MLWorkerController {
+	return &MLWorkerController{
+		workerService: workerService,
+	}
+}
+
+// RegisterWorker godoc
+// @Summary Register an ML worker
+// @Description Registers an external speech-to-text, translation or text-to-speech worker with its capabilities. The shared secret goes in the X-Worker-Secret header; the returned token authenticates the worker on the other worker routes
+// @Tags Workers
+// @Accept json
+// @Produce json
+// @Param X-Worker-Secret header string true "Worker registration secret"
+// @Param request body RegisterWorkerRequest true "Worker capabilities"
+// @Success 201 {object} response.WorkerRegistrationResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse "registration disabled"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /workers/register [post]
+func (h *MLWorkerController) RegisterWorker(c *gin.Context) {
+	var req RegisterWorkerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	worker, token, err := h.workerService.RegisterWorker(c.GetHeader(WorkerSecretHeader), req.Name, req.TaskTypes, req.Languages)
+	if err != nil {
+		handleWorkerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.WorkerRegistrationResponse{Worker: *worker, Token: token})
+}
+
+// Heartbeat godoc
+// @Summary Send a worker heartbeat
+// @Description Records that the worker is alive. Leases of running tasks are extended with the progress route
+// @Tags Workers
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.MessageResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /workers/heartbeat [post]
+func (h *MLWorkerController) Heartbeat(c *gin.Context) {
+	worker, ok := middleware.GetWorkerInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	if err := h.workerService.Heartbeat(worker); err != nil {
+		handleWorkerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "heartbeat recorded"})
+}
+
+// ClaimTask godoc
+// @Summary Claim the next task
+// @Description Leases the next queued task matching the task types and languages of the worker. The worker must report progress before leased_until or the task is handed to another worker
+// @Tags Workers
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.MLTaskResponse
+// @Success 204 "no task available"
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /workers/tasks/claim [post]
+func (h *MLWorkerController) ClaimTask(c *gin.Context) {
+	worker, ok := middleware.GetWorkerInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	assignment, err := h.workerService.ClaimTask(worker)
+	if err != nil {
+		handleWorkerError(c, err)
+		return
+	}
+	if assignment == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.MLTaskResponse{Task: response.MLTask{
+		ID:              assignment.Task.ID,
+		Type:            assignment.TaskType,
+		VideoID:         assignment.Payload.VideoID,
+		SourceLang:      assignment.Payload.SourceLang,
+		TargetLang:      assignment.Payload.TargetLang,
+		TranscriptionID: assignment.Payload.TranscriptionID,
+		AudioID:         assignment.Payload.AudioID,
+		InputURL:        assignment.InputURL,
+		InputText:       assignment.InputText,
+		InputAudioURL:   assignment.InputAudioURL,
+		UploadURL:       assignment.UploadURL,
+		ContentType:     assignment.Payload.ContentType,
+		Attempt:         assignment.Task.Attempts,
+		MaxAttempts:     assignment.Task.MaxAttempts,
+		LeasedUntil:     assignment.Task.LeasedUntil,
+	}})
+}
+
+// ReportTaskProgress godoc
+// @Summary Report the progress of a task
+// @Description Records the progress in percent and extends the lease of the task, acting as the task heartbeat
+// @Tags Workers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param task_id path uint64 true "Task ID"
+// @Param request body TaskProgressRequest true "Progress"
+// @Success 200 {object} response.JobResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "task not leased by this worker"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /workers/tasks/{task_id}/progress [post]
+func (h *MLWorkerController) ReportTaskProgress(c *gin.Context) {
+	worker, taskID, ok := h.workerTask(c)
+	if !ok {
+		return
+	}
+
+	var req TaskProgressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	job, err := h.workerService.ReportProgress(worker, taskID, req.Progress)
+	if err != nil {
+		handleWorkerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.JobResponse{Job: *job})
+}
+
+// SubmitTaskResult godoc
+// @Summary Submit the result of a task
+// @Description Checks the result file uploaded to the upload_url of the task, records it as a transcription (stt, mt) or audio (tts) of the video and completes the task. The video moves to success, unless the task belongs to a pipeline which advances to its next steps instead
+// @Tags Workers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param task_id path uint64 true "Task ID"
+// @Param request body TaskResultRequest true "Result"
+// @Success 201 {object} response.MLTaskResultResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "task not leased by this worker, or result file not uploaded"
+// @Failure 422 {object} response.ErrorResponse "result file type mismatch"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /workers/tasks/{task_id}/result [post]
+func (h *MLWorkerController) SubmitTaskResult(c *gin.Context) {
+	worker, taskID, ok := h.workerTask(c)
+	if !ok {
+		return
+	}
+
+	var req TaskResultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	outcome, err := h.workerService.SubmitResult(worker, taskID, service.MLTaskResult{Text: req.Text, Duration: req.Duration})
+	if err != nil {
+		handleWorkerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.MLTaskResultResponse{Transcription: outcome.Transcription, Audio: outcome.Audio, Step: outcome.Step})
+}
+
+// FailTask godoc
+// @Summary Report a failed task
+// @Description Records the failure of a task. Retryable failures are queued again with exponential backoff; other failures, or failures on the last attempt, dead-letter the task and mark the video as failed
+// @Tags Workers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param task_id path uint64 true "Task ID"
+// @Param request body TaskFailureRequest true "Failure"
+// @Success 200 {object} response.JobResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "task not leased by this worker"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /workers/tasks/{task_id}/fail [post]
+func (h *MLWorkerController) FailTask(c *gin.Context) {
+	worker, taskID, ok := h.workerTask(c)
+	if !ok {
+		return
+	}
+
+	var req TaskFailureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	job, err := h.workerService.FailTask(worker, taskID, req.Error, req.Retryable)
+	if err != nil {
+		handleWorkerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.JobResponse{Job: *job})
+}
+
+// workerTask returns the authenticated worker and the task ID of the path, writing the error response when either is missing
+func (h *MLWorkerController) workerTask(c *gin.Context) (*entity.MLWorker, uint64, bool) {
+	worker, ok := middleware.GetWorkerInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return nil, 0, false
+	}
+
+	taskID, err := strconv.ParseUint(c.Param("task_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid task ID"})
+		return nil, 0, false
+	}
+	return worker, taskID, true
+}
+
+// handleWorkerError maps errors of the worker protocol to HTTP responses
+func handleWorkerError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrWorkerRegistrationDisabled):
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidRegistrationSecret):
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidWorkerCapabilities), errors.Is(err, service.ErrInvalidTaskResult):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrTaskNotFound), errors.Is(err, service.ErrVideoNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrTaskNotLeased), errors.Is(err, service.ErrFileNotUploaded):
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrFileTypeMismatch):
+		c.JSON(http.StatusUnprocessableEntity, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("Worker request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
@@ -0,0 +1,444 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/aws"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/repo"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWorkerSecret = "worker-secret"
+
+// workerTestEnv is a backend serving the storage and worker routes on a real database and local storage
+type workerTestEnv struct {
+	server        *httptest.Server
+	storage       *aws.LocalStorageClient
+	workerService service.MLWorkerService
+	videoRepo     repo.VideoRepository
+	transcripts   repo.TranscriptionRepository
+	audios        repo.AudioRepository
+	jobService    service.JobService
+	pipelines     service.PipelineService
+}
+
+func setupWorkerEnv(t *testing.T) *workerTestEnv {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := filepath.Glob("../../../../migration/*.up.sql")
+	require.NoError(t, err)
+	for _, migration := range migrations {
+		schema, err := os.ReadFile(migration)
+		require.NoError(t, err)
+		_, err = db.Exec(string(schema))
+		require.NoError(t, err, migration)
+	}
+
+	secret := env.EnvConfig.WorkerRegistrationSecret
+	env.EnvConfig.WorkerRegistrationSecret = testWorkerSecret
+	t.Cleanup(func() { env.EnvConfig.WorkerRegistrationSecret = secret })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	storage, err := aws.NewLocalStorageClient(t.TempDir(), server.URL, "storage-secret")
+	require.NoError(t, err)
+
+	videoRepo := repo.NewVideoRepo(db)
+	transcriptionRepo := repo.NewTranscriptionRepository(db)
+	audioRepo := repo.NewAudioRepository(db)
+	pipelineRepo := repo.NewPipelineRepo(db)
+	jobService := service.NewJobService(repo.NewJobRepo(db))
+	workerService := service.NewMLWorkerService(repo.NewMLWorkerRepo(db), jobService, videoRepo, transcriptionRepo, audioRepo, pipelineRepo, storage)
+
+	storageController := NewStorageController(storage)
+	router.PUT(aws.LocalStorageRoute+"/*key", storageController.UploadObject)
+	router.GET(aws.LocalStorageRoute+"/*key", storageController.DownloadObject)
+
+	controller := NewMLWorkerController(workerService)
+	router.POST("/api/workers/register", controller.RegisterWorker)
+	workers := router.Group("/api/workers")
+	workers.Use(middleware.NewAuthWorkerMiddleware(workerService).MustAuthWorker())
+	workers.POST("/heartbeat", controller.Heartbeat)
+	workers.POST("/tasks/claim", controller.ClaimTask)
+	workers.POST("/tasks/:task_id/progress", controller.ReportTaskProgress)
+	workers.POST("/tasks/:task_id/result", controller.SubmitTaskResult)
+	workers.POST("/tasks/:task_id/fail", controller.FailTask)
+
+	return &workerTestEnv{
+		server:        server,
+		storage:       storage,
+		workerService: workerService,
+		videoRepo:     videoRepo,
+		transcripts:   transcriptionRepo,
+		audios:        audioRepo,
+		jobService:    jobService,
+		pipelines:     service.NewPipelineService(pipelineRepo, videoRepo, transcriptionRepo, jobService, workerService),
+	}
+}
+
+// createVideo stores a video of the sample user together with its file
+func (e *workerTestEnv) createVideo(t *testing.T, data string) *entity.Video {
+	video := &entity.Video{Title: "Lecture", FileName: "lecture.mp4", Folder: "videos", UserID: 1, Status: entity.StatusRaw, ContentType: "video/mp4"}
+	require.NoError(t, e.videoRepo.CreateVideo(video))
+	require.NoError(t, e.storage.WriteObject("videos/lecture.mp4", "video/mp4", strings.NewReader(data)))
+	return video
+}
+
+// fakeWorker talks to the backend over HTTP the way an external model process does
+type fakeWorker struct {
+	t       *testing.T
+	baseURL string
+	token   string
+}
+
+func (w *fakeWorker) do(method, url, contentType string, body io.Reader) *http.Response {
+	req, err := http.NewRequest(method, url, body)
+	require.NoError(w.t, err)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(w.t, err)
+	w.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func (w *fakeWorker) post(path string, body interface{}, out interface{}) int {
+	data, err := json.Marshal(body)
+	require.NoError(w.t, err)
+	resp := w.do(http.MethodPost, w.baseURL+"/api/workers"+path, "application/json", bytes.NewReader(data))
+	if out != nil && resp.StatusCode < 300 && resp.StatusCode != http.StatusNoContent {
+		require.NoError(w.t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func (w *fakeWorker) register(name string, taskTypes []entity.MLTaskType, languages []string) {
+	data, err := json.Marshal(RegisterWorkerRequest{Name: name, TaskTypes: taskTypes, Languages: languages})
+	require.NoError(w.t, err)
+	req, err := http.NewRequest(http.MethodPost, w.baseURL+"/api/workers/register", bytes.NewReader(data))
+	require.NoError(w.t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WorkerSecretHeader, testWorkerSecret)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(w.t, err)
+	defer resp.Body.Close()
+	require.Equal(w.t, http.StatusCreated, resp.StatusCode)
+
+	var registration response.WorkerRegistrationResponse
+	require.NoError(w.t, json.NewDecoder(resp.Body).Decode(&registration))
+	w.token = registration.Token
+}
+
+func (w *fakeWorker) claim() (*response.MLTask, int) {
+	var resp response.MLTaskResponse
+	status := w.post("/tasks/claim", nil, &resp)
+	if status != http.StatusOK {
+		return nil, status
+	}
+	return &resp.Task, status
+}
+
+func TestMLWorkerRegistration(t *testing.T) {
+	e := setupWorkerEnv(t)
+
+	t.Run("Invalid Secret", func(t *testing.T) {
+		body := `{"name":"stt-1","task_types":["stt"],"languages":["en"]}`
+		req, _ := http.NewRequest(http.MethodPost, e.server.URL+"/api/workers/register", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(WorkerSecretHeader, "wrong")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Unknown Task Type", func(t *testing.T) {
+		body := `{"name":"ocr-1","task_types":["ocr"],"languages":["en"]}`
+		req, _ := http.NewRequest(http.MethodPost, e.server.URL+"/api/workers/register", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(WorkerSecretHeader, testWorkerSecret)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Unknown Token", func(t *testing.T) {
+		worker := &fakeWorker{t: t, baseURL: e.server.URL, token: "not-a-token"}
+		assert.Equal(t, http.StatusUnauthorized, worker.post("/heartbeat", nil, nil))
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		worker := &fakeWorker{t: t, baseURL: e.server.URL}
+		worker.register("stt-1", []entity.MLTaskType{entity.MLTaskSpeechToText}, []string{"en"})
+		assert.Equal(t, http.StatusOK, worker.post("/heartbeat", nil, nil))
+	})
+}
+
+func TestMLWorkerSpeechToTextFlow(t *testing.T) {
+	e := setupWorkerEnv(t)
+	video := e.createVideo(t, "fake video bytes")
+
+	worker := &fakeWorker{t: t, baseURL: e.server.URL}
+	worker.register("stt-1", []entity.MLTaskType{entity.MLTaskSpeechToText}, []string{"en"})
+
+	_, status := worker.claim()
+	assert.Equal(t, http.StatusNoContent, status, "nothing is queued yet")
+
+	// Tasks in languages the worker does not serve are left for other workers
+	_, err := e.workerService.EnqueueTask(service.MLTaskRequest{Type: entity.MLTaskSpeechToText, VideoID: video.ID, SourceLang: "vi"})
+	require.NoError(t, err)
+	_, status = worker.claim()
+	assert.Equal(t, http.StatusNoContent, status)
+
+	job, err := e.workerService.EnqueueTask(service.MLTaskRequest{Type: entity.MLTaskSpeechToText, VideoID: video.ID, SourceLang: "en"})
+	require.NoError(t, err)
+
+	task, status := worker.claim()
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, job.ID, task.ID)
+	assert.Equal(t, entity.MLTaskSpeechToText, task.Type)
+	assert.Equal(t, "en", task.SourceLang)
+	assert.Equal(t, "text/plain", task.ContentType)
+	assert.Equal(t, 1, task.Attempt)
+
+	claimed, err := e.videoRepo.GetVideoByID(video.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.StatusProcessing, claimed.Status)
+
+	// The worker downloads its input and uploads the transcript through the presigned URLs
+	input := worker.do(http.MethodGet, task.InputURL, "", nil)
+	require.Equal(t, http.StatusOK, input.StatusCode)
+	data, err := io.ReadAll(input.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "fake video bytes", string(data))
+
+	var progress response.JobResponse
+	require.Equal(t, http.StatusOK, worker.post("/tasks/"+strconv.FormatUint(task.ID, 10)+"/progress", TaskProgressRequest{Progress: 40}, &progress))
+	assert.Equal(t, 40, progress.Job.Progress)
+
+	// Submitting before the upload leaves the task with the worker
+	assert.Equal(t, http.StatusConflict, worker.post("/tasks/"+strconv.FormatUint(task.ID, 10)+"/result", TaskResultRequest{Text: "hello world"}, nil))
+
+	upload := worker.do(http.MethodPut, task.UploadURL, task.ContentType, strings.NewReader("hello world"))
+	require.Equal(t, http.StatusOK, upload.StatusCode)
+
+	var result response.MLTaskResultResponse
+	require.Equal(t, http.StatusCreated, worker.post("/tasks/"+strconv.FormatUint(task.ID, 10)+"/result", TaskResultRequest{Text: "hello world"}, &result))
+	require.NotNil(t, result.Transcription)
+	assert.Nil(t, result.Audio)
+
+	transcription, err := e.transcripts.GetTranscriptionByID(result.Transcription.ID)
+	require.NoError(t, err)
+	require.NotNil(t, transcription)
+	assert.Equal(t, video.ID, transcription.VideoID)
+	assert.Equal(t, video.UserID, transcription.UserID)
+	assert.Equal(t, "hello world", transcription.Text)
+	assert.Equal(t, "en", transcription.Lang)
+	assert.Equal(t, int64(len("hello world")), transcription.FileSize)
+	assert.Equal(t, entity.FileStatusReady, transcription.Status)
+
+	done, err := e.jobService.GetJob(task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.JobStatusSucceeded, done.Status)
+
+	processed, err := e.videoRepo.GetVideoByID(video.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.StatusSuccess, processed.Status)
+
+	// A finished task can no longer be reported on
+	assert.Equal(t, http.StatusConflict, worker.post("/tasks/"+strconv.FormatUint(task.ID, 10)+"/progress", TaskProgressRequest{Progress: 100}, nil))
+}
+
+func TestMLWorkerTaskFailure(t *testing.T) {
+	e := setupWorkerEnv(t)
+	video := e.createVideo(t, "fake video bytes")
+
+	transcription := &entity.Transcription{VideoID: video.ID, UserID: video.UserID, Text: "xin chao", Lang: "vi", Status: entity.FileStatusReady}
+	require.NoError(t, e.transcripts.CreateTranscription(transcription))
+
+	job, err := e.workerService.EnqueueTask(service.MLTaskRequest{Type: entity.MLTaskTextToSpeech, VideoID: video.ID, TranscriptionID: transcription.ID})
+	require.NoError(t, err)
+
+	worker := &fakeWorker{t: t, baseURL: e.server.URL}
+	worker.register("tts-1", []entity.MLTaskType{entity.MLTaskTextToSpeech}, []string{"vi"})
+	other := &fakeWorker{t: t, baseURL: e.server.URL}
+	other.register("tts-2", []entity.MLTaskType{entity.MLTaskTextToSpeech}, []string{"vi"})
+
+	task, status := worker.claim()
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, job.ID, task.ID)
+	assert.Equal(t, "xin chao", task.InputText)
+	assert.Equal(t, "audio/mpeg", task.ContentType)
+
+	path := "/tasks/" + strconv.FormatUint(task.ID, 10) + "/fail"
+	assert.Equal(t, http.StatusConflict, other.post(path, TaskFailureRequest{Error: "stolen"}, nil), "only the lease holder can fail a task")
+	assert.Equal(t, http.StatusNotFound, worker.post("/tasks/999/fail", TaskFailureRequest{Error: "missing"}, nil))
+	assert.Equal(t, http.StatusBadRequest, worker.post(path, map[string]string{}, nil))
+
+	var failed response.JobResponse
+	require.Equal(t, http.StatusOK, worker.post(path, TaskFailureRequest{Error: "unsupported voice"}, &failed))
+	assert.Equal(t, entity.JobStatusDead, failed.Job.Status)
+	assert.Equal(t, "unsupported voice", failed.Job.LastError)
+
+	processed, err := e.videoRepo.GetVideoByID(video.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.StatusFailed, processed.Status)
+}
+
+// runTask claims the next task and completes it the way a worker running the model would
+func (w *fakeWorker) runTask(result TaskResultRequest) *response.MLTaskResultResponse {
+	task, status := w.claim()
+	require.Equal(w.t, http.StatusOK, status)
+
+	upload := w.do(http.MethodPut, task.UploadURL, task.ContentType, strings.NewReader("result of "+string(task.Type)))
+	require.Equal(w.t, http.StatusOK, upload.StatusCode)
+
+	var resp response.MLTaskResultResponse
+	require.Equal(w.t, http.StatusCreated, w.post("/tasks/"+strconv.FormatUint(task.ID, 10)+"/result", result, &resp))
+	require.NotNil(w.t, resp.Step)
+	return &resp
+}
+
+func TestMLWorkerPipelineFlow(t *testing.T) {
+	e := setupWorkerEnv(t)
+	video := e.createVideo(t, "fake video bytes")
+
+	stt := &fakeWorker{t: t, baseURL: e.server.URL}
+	stt.register("stt-1", []entity.MLTaskType{entity.MLTaskSpeechToText}, []string{"en"})
+	mt := &fakeWorker{t: t, baseURL: e.server.URL}
+	mt.register("mt-1", []entity.MLTaskType{entity.MLTaskTranslation}, []string{"en", "vi", "ja"})
+	tts := &fakeWorker{t: t, baseURL: e.server.URL}
+	tts.register("tts-1", []entity.MLTaskType{entity.MLTaskTextToSpeech}, []string{"vi"})
+	mux := &fakeWorker{t: t, baseURL: e.server.URL}
+	mux.register("mux-1", []entity.MLTaskType{entity.MLTaskMux}, []string{"vi"})
+
+	pipeline, err := e.pipelines.CreatePipeline(1, video.ID, service.PipelineSpec{
+		SourceLang: "EN",
+		Targets:    []service.PipelineTarget{{Lang: "vi", Synthesize: true, Mux: true}, {Lang: "ja"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, pipeline.Steps, 5)
+	assert.Equal(t, entity.PipelineStepQueued, pipeline.Steps[0].Status, "stt has no dependency")
+	for _, step := range pipeline.Steps[1:] {
+		assert.Equal(t, entity.PipelineStepPending, step.Status, step.Name)
+	}
+
+	// The pool runs the pipeline.advance jobs queued by each result; the status endpoint advances the same way
+	advance := func() *entity.Pipeline {
+		current, err := e.pipelines.GetPipeline(video.ID, pipeline.ID)
+		require.NoError(t, err)
+		return current
+	}
+
+	_, status := mt.claim()
+	assert.Equal(t, http.StatusNoContent, status, "translations wait for the transcript")
+
+	transcript := stt.runTask(TaskResultRequest{Text: "hello everyone"})
+	assert.Equal(t, "stt:en", transcript.Step.Name)
+	current := advance()
+	assert.Equal(t, entity.PipelineStatusRunning, current.Status)
+	assert.Equal(t, entity.PipelineStepQueued, current.Steps[1].Status)
+	assert.Equal(t, transcript.Transcription.ID, current.Steps[1].InputTranscriptionID)
+
+	vi := mt.runTask(TaskResultRequest{Text: "xin chao moi nguoi"})
+	ja := mt.runTask(TaskResultRequest{Text: "minasan konnichiwa"})
+	assert.ElementsMatch(t, []string{"mt:vi", "mt:ja"}, []string{vi.Step.Name, ja.Step.Name})
+	advance()
+
+	audio := tts.runTask(TaskResultRequest{Duration: 12})
+	assert.Equal(t, "vi", audio.Audio.Lang)
+	advance()
+
+	task, status := mux.claim()
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, audio.Audio.ID, task.AudioID)
+	assert.NotEmpty(t, task.InputURL)
+	assert.NotEmpty(t, task.InputAudioURL)
+	assert.Equal(t, "video/mp4", task.ContentType)
+
+	current = advance()
+	assert.Equal(t, entity.PipelineStepRunning, current.Steps[3].Status, "a leased task is reported as running")
+
+	var progress response.JobResponse
+	require.Equal(t, http.StatusOK, mux.post("/tasks/"+strconv.FormatUint(task.ID, 10)+"/progress", TaskProgressRequest{Progress: 50}, &progress))
+	current = advance()
+	assert.Equal(t, 50, current.Steps[3].Progress)
+	assert.Equal(t, (4*100+50)/5, current.Progress)
+
+	upload := mux.do(http.MethodPut, task.UploadURL, task.ContentType, strings.NewReader("dubbed video"))
+	require.Equal(t, http.StatusOK, upload.StatusCode)
+	var muxed response.MLTaskResultResponse
+	require.Equal(t, http.StatusCreated, mux.post("/tasks/"+strconv.FormatUint(task.ID, 10)+"/result", TaskResultRequest{}, &muxed))
+	assert.NotEmpty(t, muxed.Step.OutputFileName)
+
+	current = advance()
+	assert.Equal(t, entity.PipelineStatusSucceeded, current.Status)
+	assert.Equal(t, 100, current.Progress)
+	for _, step := range current.Steps {
+		assert.Equal(t, entity.PipelineStepSucceeded, step.Status, step.Name)
+	}
+
+	processed, err := e.videoRepo.GetVideoByID(video.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.StatusSuccess, processed.Status)
+}
+
+func TestMLWorkerPipelineFailure(t *testing.T) {
+	e := setupWorkerEnv(t)
+	video := e.createVideo(t, "fake video bytes")
+
+	stt := &fakeWorker{t: t, baseURL: e.server.URL}
+	stt.register("stt-1", []entity.MLTaskType{entity.MLTaskSpeechToText}, []string{"en"})
+
+	pipeline, err := e.pipelines.CreatePipeline(1, video.ID, service.PipelineSpec{
+		SourceLang: "en",
+		Targets:    []service.PipelineTarget{{Lang: "vi", Synthesize: true}},
+	})
+	require.NoError(t, err)
+
+	task, status := stt.claim()
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, http.StatusOK, stt.post("/tasks/"+strconv.FormatUint(task.ID, 10)+"/fail", TaskFailureRequest{Error: "no speech detected"}, nil))
+
+	current, err := e.pipelines.AdvancePipeline(pipeline.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.PipelineStatusFailed, current.Status)
+	assert.Equal(t, "step stt:en failed: no speech detected", current.Error)
+	assert.Equal(t, entity.PipelineStepFailed, current.Steps[0].Status)
+	assert.Equal(t, entity.PipelineStepSkipped, current.Steps[1].Status)
+	assert.Equal(t, entity.PipelineStepSkipped, current.Steps[2].Status)
+
+	processed, err := e.videoRepo.GetVideoByID(video.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.StatusFailed, processed.Status)
+}
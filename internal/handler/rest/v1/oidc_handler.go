@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"mlvt/internal/infra/env"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCStateCookie holds the state token between the redirect to the provider and the callback
+const OIDCStateCookie = "oidc_state"
+
+// OIDCController handles logins through OpenID Connect providers
+type OIDCController struct {
+	oidcService service.OIDCService
+}
+
+// NewOIDCController creates a new OIDCController
+func NewOIDCController(oidcService service.OIDCService) *OIDCController {
+	return &OIDCController{
+		oidcService: oidcService,
+	}
+}
+
+// ListProviders godoc
+// @Summary List identity providers
+// @Description Lists the OpenID Connect providers users may log in with
+// @Tags users
+// @Produce json
+// @Success 200 {object} response.OIDCProvidersResponse
+// @Router /users/oidc/providers [get]
+func (h *OIDCController) ListProviders(c *gin.Context) {
+	providers := h.oidcService.Providers()
+	if providers == nil {
+		providers = []string{}
+	}
+	c.JSON(http.StatusOK, response.OIDCProvidersResponse{Providers: providers})
+}
+
+// Login godoc
+// @Summary Log in with an identity provider
+// @Description Redirects the browser to the login page of the OpenID Connect provider. The state of the login is kept in a short-lived cookie until the provider redirects back to the callback
+// @Tags users
+// @Param provider path string true "Provider name"
+// @Success 302 "Redirect to the provider"
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /users/oidc/{provider}/login [get]
+func (h *OIDCController) Login(c *gin.Context) {
+	authorization, err := h.oidcService.Authorize(c.Param("provider"))
+	if err != nil {
+		handleOIDCError(c, err)
+		return
+	}
+
+	// SameSite=Lax, since the provider sends the browser back with a top-level navigation
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OIDCStateCookie, authorization.StateToken, int(service.OIDCStateTTL.Seconds()), oidcCookiePath(c), "", secureCookie(c), true)
+	c.Redirect(http.StatusFound, authorization.URL)
+}
+
+// Callback godoc
+// @Summary Complete a login with an identity provider
+// @Description Where the OpenID Connect provider sends the browser back. Exchanges the code for an ID token and logs in its user, linked by the provider account or by the verified email address, or created on first login. Must come from the browser that started the login
+// @Tags users
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Success 200 {object} response.TokenResponse "token, or response.TwoFactorChallengeResponse for users with two-factor authentication"
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Router /users/oidc/{provider}/callback [get]
+func (h *OIDCController) Callback(c *gin.Context) {
+	stateToken, _ := c.Cookie(OIDCStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OIDCStateCookie, "", -1, oidcCookiePath(c), "", secureCookie(c), true) // Each login works once
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "login cancelled at the identity provider: " + providerError})
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	result, err := h.oidcService.Callback(c.Param("provider"), code, state, stateToken)
+	if err != nil {
+		handleOIDCError(c, err)
+		return
+	}
+	respondLoginResult(c, result)
+}
+
+// oidcCookiePath limits the state cookie to the login and callback routes of the provider
+func oidcCookiePath(c *gin.Context) string {
+	path := c.Request.URL.Path
+	return path[:strings.LastIndex(path, "/")]
+}
+
+// secureCookie reports whether the cookie should only be sent over HTTPS, which is also the case behind a proxy
+// terminating TLS for a public https:// URL
+func secureCookie(c *gin.Context) bool {
+	return c.Request.TLS != nil || strings.HasPrefix(env.EnvConfig.AppBaseURL, "https://")
+}
+
+func handleOIDCError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrOIDCProviderNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidOIDCState):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrOIDCLoginFailed):
+		log.Warnf("OpenID Connect login failed: %v", err)
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: service.ErrOIDCLoginFailed.Error()})
+	case errors.Is(err, service.ErrOIDCEmailNotVerified):
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrOIDCProviderUnavailable):
+		log.Errorf("OpenID Connect provider unavailable: %v", err)
+		c.JSON(http.StatusBadGateway, response.ErrorResponse{Error: service.ErrOIDCProviderUnavailable.Error()})
+	default:
+		log.Errorf("OpenID Connect login failed: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
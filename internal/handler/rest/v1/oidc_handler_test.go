@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupOIDCRouter(controller *OIDCController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	oidc := router.Group("/api/users/oidc")
+	oidc.GET("/providers", controller.ListProviders)
+	oidc.GET("/:provider/login", controller.Login)
+	oidc.GET("/:provider/callback", controller.Callback)
+
+	return router
+}
+
+func TestOIDCListProviders(t *testing.T) {
+	mockService := new(service.MockOIDCService)
+	router := setupOIDCRouter(NewOIDCController(mockService))
+	mockService.On("Providers").Return([]string{"google", "corp"}).Once()
+
+	req, _ := http.NewRequest("GET", "/api/users/oidc/providers", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"providers":["google","corp"]}`, w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	mockService := new(service.MockOIDCService)
+	router := setupOIDCRouter(NewOIDCController(mockService))
+
+	t.Run("Redirects With State Cookie", func(t *testing.T) {
+		authorization := &service.OIDCAuthorization{URL: "https://idp.example.com/authorize?state=abc", StateToken: "state-token"}
+		mockService.On("Authorize", "google").Return(authorization, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/api/users/oidc/google/login", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, authorization.URL, w.Header().Get("Location"))
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, OIDCStateCookie, cookies[0].Name)
+		assert.Equal(t, "state-token", cookies[0].Value)
+		assert.Equal(t, "/api/users/oidc/google", cookies[0].Path, "sent back to the callback only")
+		assert.True(t, cookies[0].HttpOnly)
+		assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	})
+
+	t.Run("Unknown Provider", func(t *testing.T) {
+		mockService.On("Authorize", "other").Return(nil, service.ErrOIDCProviderNotFound).Once()
+
+		req, _ := http.NewRequest("GET", "/api/users/oidc/other/login", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Provider Down", func(t *testing.T) {
+		mockService.On("Authorize", "google").Return(nil, fmt.Errorf("%w: timeout", service.ErrOIDCProviderUnavailable)).Once()
+
+		req, _ := http.NewRequest("GET", "/api/users/oidc/google/login", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.NotContains(t, w.Body.String(), "timeout")
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestOIDCCallback(t *testing.T) {
+	mockService := new(service.MockOIDCService)
+	router := setupOIDCRouter(NewOIDCController(mockService))
+	tokens := &service.TokenPair{UserID: 1, AccessToken: "access", RefreshToken: "refresh", ExpiresAt: time.Now().Add(time.Minute)}
+
+	tests := []struct {
+		name       string
+		query      string
+		setup      func()
+		wantStatus int
+	}{
+		{"Success", "?code=abc&state=xyz", func() {
+			mockService.On("Callback", "google", "abc", "xyz", "state-token").Return(&service.LoginResult{TokenPair: tokens}, nil).Once()
+		}, http.StatusOK},
+		{"Cancelled At Provider", "?error=access_denied&state=xyz", func() {}, http.StatusUnauthorized},
+		{"Missing Code", "?state=xyz", func() {}, http.StatusBadRequest},
+		{"Invalid State", "?code=abc&state=xyz", func() {
+			mockService.On("Callback", "google", "abc", "xyz", "state-token").Return(nil, service.ErrInvalidOIDCState).Once()
+		}, http.StatusBadRequest},
+		{"Email Not Verified", "?code=abc&state=xyz", func() {
+			mockService.On("Callback", "google", "abc", "xyz", "state-token").Return(nil, service.ErrOIDCEmailNotVerified).Once()
+		}, http.StatusForbidden},
+		{"Exchange Failed", "?code=abc&state=xyz", func() {
+			mockService.On("Callback", "google", "abc", "xyz", "state-token").Return(nil, fmt.Errorf("%w: invalid_grant", service.ErrOIDCLoginFailed)).Once()
+		}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			req, _ := http.NewRequest("GET", "/api/users/oidc/google/callback"+tt.query, nil)
+			req.AddCookie(&http.Cookie{Name: OIDCStateCookie, Value: "state-token"})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			cookies := w.Result().Cookies()
+			require.Len(t, cookies, 1)
+			assert.Equal(t, OIDCStateCookie, cookies[0].Name)
+			assert.Negative(t, cookies[0].MaxAge, "the state cookie is cleared")
+			if tt.wantStatus == http.StatusOK {
+				var resp response.TokenResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, "access", resp.Token)
+				assert.Equal(t, "refresh", resp.RefreshToken)
+			}
+		})
+	}
+
+	mockService.AssertExpectations(t)
+}
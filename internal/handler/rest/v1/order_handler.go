@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrderController lists the plans users can pay for and the orders of the current user
+type OrderController struct {
+	orderService service.OrderService
+}
+
+// NewOrderController creates a new OrderController
+func NewOrderController(orderService service.OrderService) *OrderController {
+	return &OrderController{orderService: orderService}
+}
+
+// ListPlans godoc
+// @Summary List plans
+// @Description Lists the plans that can be paid for, the cheapest first. Amounts are in minor units of the currency
+// @Tags payments
+// @Produce json
+// @Success 200 {object} response.PlansResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /payments/plans [get]
+func (h *OrderController) ListPlans(c *gin.Context) {
+	plans, err := h.orderService.ListPlans()
+	if err != nil {
+		handleOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.PlansResponse{Plans: plans})
+}
+
+// ListOrders godoc
+// @Summary List orders
+// @Description Lists the orders of the current user with the status of their payment, the newest first
+// @Tags payments
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.OrdersResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /payments/orders [get]
+func (h *OrderController) ListOrders(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	orders, err := h.orderService.ListOrders(userInfo.ID)
+	if err != nil {
+		handleOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.OrdersResponse{Orders: orders})
+}
+
+// GetOrder godoc
+// @Summary Get an order
+// @Description Returns an order of the current user with the status of its payment. Users with the payment:refund permission may get any order
+// @Tags payments
+// @Produce json
+// @Security BearerAuth
+// @Param order_id path string true "Order ID"
+// @Success 200 {object} entity.Order
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /payments/orders/{order_id} [get]
+func (h *OrderController) GetOrder(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	order, err := h.orderService.GetOrder(c.Param("order_id"))
+	if err == nil && !canSeeOrder(userInfo, order) {
+		err = service.ErrOrderNotFound
+	}
+	if err != nil {
+		handleOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// canSeeOrder reports whether the user may see the order: their own, or any with the payment:refund permission.
+// Orders of other users are reported as not found.
+func canSeeOrder(userInfo *entity.User, order *entity.Order) bool {
+	return order.UserID == userInfo.ID || userInfo.HasPermission(entity.PermissionPaymentRefund)
+}
+
+func handleOrderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound), errors.Is(err, service.ErrPlanNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("Order request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mlvt/internal/pkg/response"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListPlans(t *testing.T) {
+	router := setupPaymentRouter(t)
+
+	req, _ := http.NewRequest("GET", "/payments/plans", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var plans response.PlansResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &plans))
+	require.Len(t, plans.Plans, 2)
+	assert.Equal(t, "premium_monthly", plans.Plans[0].Code)
+	assert.Equal(t, int64(99000), plans.Plans[0].Amount)
+}
+
+func TestOrdersOfCurrentUser(t *testing.T) {
+	router := setupPaymentRouter(t)
+	order := createOrder(t, router, "momo").Order
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantOrders int
+	}{
+		{"Own Orders", "/owner/payments/orders", http.StatusOK, 1},
+		{"No Orders", "/other/payments/orders", http.StatusOK, 0},
+		{"Own Order", "/owner/payments/orders/" + order.Reference, http.StatusOK, -1},
+		{"Order Of Another User", "/other/payments/orders/" + order.Reference, http.StatusNotFound, -1},
+		{"Admin Gets Any Order", "/admin/payments/orders/" + order.Reference, http.StatusOK, -1},
+		{"Unknown Order", "/owner/payments/orders/unknown", http.StatusNotFound, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantOrders >= 0 {
+				var orders response.OrdersResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &orders))
+				assert.Len(t, orders.Orders, tt.wantOrders)
+			}
+		})
+	}
+}
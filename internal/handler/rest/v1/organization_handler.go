@@ -0,0 +1,500 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationController manages organizations, their members and invitations. Which member may call
+// which handler is decided by the routes.
+type OrganizationController struct {
+	organizationService service.OrganizationService
+}
+
+// NewOrganizationController creates a new OrganizationController
+func NewOrganizationController(organizationService service.OrganizationService) *OrganizationController {
+	return &OrganizationController{
+		organizationService: organizationService,
+	}
+}
+
+// CreateOrganizationRequest represents the request body for creating an organization
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// InviteRequest represents the request body for inviting someone to an organization
+type InviteRequest struct {
+	Email string         `json:"email" binding:"required,email"`
+	Role  entity.OrgRole `json:"role" binding:"required"` // owner, editor or viewer
+}
+
+// ChangeMemberRoleRequest represents the request body for changing the role of a member
+type ChangeMemberRoleRequest struct {
+	Role entity.OrgRole `json:"role" binding:"required"` // owner, editor or viewer
+}
+
+// AcceptInviteRequest represents the request body for accepting an invitation
+type AcceptInviteRequest struct {
+	Token string `json:"token" binding:"required"` // Token from the link in the invitation email
+}
+
+// CreateOrganization godoc
+// @Summary Create an organization
+// @Description Creates an organization owned by the current user, to share videos, audios and transcriptions with its members
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateOrganizationRequest true "Name of the organization"
+// @Success 201 {object} entity.Organization
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /organizations [post]
+func (h *OrganizationController) CreateOrganization(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var req CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	org, err := h.organizationService.CreateOrganization(userInfo.ID, req.Name)
+	if err != nil {
+		handleOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// ListOrganizations godoc
+// @Summary List organizations
+// @Description Lists the organizations the current user is a member of, with their role in each
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.OrganizationsResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /organizations [get]
+func (h *OrganizationController) ListOrganizations(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	orgs, err := h.organizationService.ListOrganizations(userInfo.ID)
+	if err != nil {
+		handleOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.OrganizationsResponse{Organizations: orgs})
+}
+
+// GetOrganization godoc
+// @Summary Get an organization
+// @Description Retrieves an organization the current user is a member of, with their role in it
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path int true "Organization ID"
+// @Success 200 {object} entity.Organization
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /organizations/{org_id} [get]
+func (h *OrganizationController) GetOrganization(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+	orgID, ok := parseIDParam(c, "org_id", "invalid organization ID")
+	if !ok {
+		return
+	}
+
+	org, err := h.organizationService.GetOrganization(orgID)
+	if err != nil {
+		handleOrganizationError(c, err)
+		return
+	}
+	role, err := h.organizationService.MemberRole(orgID, userInfo.ID)
+	if err != nil {
+		handleOrganizationError(c, err)
+		return
+	}
+	org.Role = role
+
+	c.JSON(http.StatusOK, org)
+}
+
+// ListMembers godoc
+// @Summary List the members of an organization
+// @Description Lists the members of an organization with their roles, in the order they joined
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path int true "Organization ID"
+// @Success 200 {object} response.OrganizationMembersResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /organizations/{org_id}/members [get]
+func (h *OrganizationController) ListMembers(c *gin.Context) {
+	orgID, ok := parseIDParam(c, "org_id", "invalid organization ID")
+	if !ok {
+		return
+	}
+
+	members, err := h.organizationService.ListMembers(orgID)
+	if err != nil {
+		handleOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.OrganizationMembersResponse{Members: members})
+}
+
+// ChangeMemberRole godoc
+// @Summary Change the role of a member
+// @Description Gives a member of the organization another role. Only owners may call it, and the last owner cannot step down
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path int true "Organization ID"
+// @Param user_id path int true "User ID of the member"
+// @Param request body ChangeMemberRoleRequest true "New role"
+// @Success 200 {object} response.MessageResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /organizations/{org_id}/members/{user_id} [put]
+func (h *OrganizationController) ChangeMemberRole(c *gin.Context) {
+	orgID, ok := parseIDParam(c, "org_id", "invalid organization ID")
+	if !ok {
+		return
+	}
+	userID, ok := parseIDParam(c, "user_id", "invalid user ID")
+	if !ok {
+		return
+	}
+
+	var req ChangeMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	if err := h.organizationService.ChangeMemberRole(orgID, userID, req.Role); err != nil {
+		handleOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "member role updated"})
+}
+
+// RemoveMember godoc
+// @Summary Remove a member
+// @Description Removes a member from the organization. Only owners may call it, and the last owner cannot be removed
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path int true "Organization ID"
+// @Param user_id path int true "User ID of the member"
+// @Success 200 {object} response.MessageResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /organizations/{org_id}/members/{user_id} [delete]
+func (h *OrganizationController) RemoveMember(c *gin.Context) {
+	orgID, ok := parseIDParam(c, "org_id", "invalid organization ID")
+	if !ok {
+		return
+	}
+	userID, ok := parseIDParam(c, "user_id", "invalid user ID")
+	if !ok {
+		return
+	}
+
+	if err := h.organizationService.RemoveMember(orgID, userID); err != nil {
+		handleOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "member removed"})
+}
+
+// LeaveOrganization godoc
+// @Summary Leave an organization
+// @Description Removes the current user from the organization. The last owner cannot leave
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path int true "Organization ID"
+// @Success 200 {object} response.MessageResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /organizations/{org_id}/leave [post]
+func (h *OrganizationController) LeaveOrganization(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+	orgID, ok := parseIDParam(c, "org_id", "invalid organization ID")
+	if !ok {
+		return
+	}
+
+	if err := h.organizationService.RemoveMember(orgID, userInfo.ID); err != nil {
+		handleOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "left the organization"})
+}
+
+// Invite godoc
+// @Summary Invite someone to an organization
+// @Description Mails an invitation to join the organization with a role to the email address, replacing the pending invitations sent to it. It may be accepted for 7 days. Only owners may call it
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path int true "Organization ID"
+// @Param request body InviteRequest true "Email address and role"
+// @Success 201 {object} entity.OrganizationInvite
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /organizations/{org_id}/invites [post]
+func (h *OrganizationController) Invite(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+	orgID, ok := parseIDParam(c, "org_id", "invalid organization ID")
+	if !ok {
+		return
+	}
+
+	var req InviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	invite, err := h.organizationService.Invite(orgID, userInfo, req.Email, req.Role)
+	if err != nil {
+		handleOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+// ListInvites godoc
+// @Summary List pending invitations
+// @Description Lists the invitations of the organization that may still be accepted, newest first. Only owners may call it
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path int true "Organization ID"
+// @Success 200 {object} response.OrganizationInvitesResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /organizations/{org_id}/invites [get]
+func (h *OrganizationController) ListInvites(c *gin.Context) {
+	orgID, ok := parseIDParam(c, "org_id", "invalid organization ID")
+	if !ok {
+		return
+	}
+
+	invites, err := h.organizationService.ListInvites(orgID)
+	if err != nil {
+		handleOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.OrganizationInvitesResponse{Invites: invites})
+}
+
+// RevokeInvite godoc
+// @Summary Revoke an invitation
+// @Description Revokes a pending invitation of the organization, so its link stops working. Only owners may call it
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path int true "Organization ID"
+// @Param invite_id path int true "Invitation ID"
+// @Success 200 {object} response.MessageResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /organizations/{org_id}/invites/{invite_id} [delete]
+func (h *OrganizationController) RevokeInvite(c *gin.Context) {
+	orgID, ok := parseIDParam(c, "org_id", "invalid organization ID")
+	if !ok {
+		return
+	}
+	inviteID, ok := parseIDParam(c, "invite_id", "invalid invitation ID")
+	if !ok {
+		return
+	}
+
+	if err := h.organizationService.RevokeInvite(orgID, inviteID); err != nil {
+		handleOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "invitation revoked"})
+}
+
+// AcceptInvite godoc
+// @Summary Accept an invitation
+// @Description Adds the current user to the organization of the invitation with its role. The user must have the email address the invitation was sent to
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body AcceptInviteRequest true "Token from the invitation link"
+// @Success 200 {object} entity.Organization
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /organizations/invites/accept [post]
+func (h *OrganizationController) AcceptInvite(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var req AcceptInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	org, err := h.organizationService.AcceptInvite(userInfo, req.Token)
+	if err != nil {
+		handleOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// canAddToOrg writes a 403 unless the user may add a resource to the organization, which takes an editor.
+// Resources without an organization are always allowed.
+func canAddToOrg(c *gin.Context, orgs service.OrganizationService, userInfo *entity.User, orgID *uint64) bool {
+	if orgID == nil || userInfo.IsAdmin() {
+		return true
+	}
+	role, err := orgs.MemberRole(*orgID, userInfo.ID)
+	if err != nil {
+		log.Errorf("failed to get the role of user %d in organization %d: %v", userInfo.ID, *orgID, err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+		return false
+	}
+	if !role.AtLeast(entity.OrgRoleEditor) {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "only editors of the organization may add to it"})
+		return false
+	}
+	return true
+}
+
+// canWriteVideo writes an error and reports false unless the user may change the video: its owner, an admin, or
+// an editor of its organization, as MustOwnVideo requires for writes
+func canWriteVideo(c *gin.Context, ownership service.OwnershipService, userInfo *entity.User, videoID uint64) bool {
+	owner, err := ownership.VideoOwner(videoID)
+	ok := false
+	if err == nil {
+		ok, err = middleware.CanReach(ownership, userInfo, owner, false)
+	}
+	if errors.Is(err, service.ErrVideoNotFound) {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		return false
+	}
+	if err != nil {
+		log.Errorf("failed to check access of user %d to video %d: %v", userInfo.ID, videoID, err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "cannot add to a video of another user"})
+		return false
+	}
+	return true
+}
+
+// parseIDParam parses a numeric path parameter, writing a 400 with the message when it is not one
+func parseIDParam(c *gin.Context, param, message string) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param(param), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: message})
+		return 0, false
+	}
+	return id, true
+}
+
+func handleOrganizationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrOrganizationNotFound), errors.Is(err, service.ErrOrgMemberNotFound), errors.Is(err, service.ErrOrgInviteNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidOrgName), errors.Is(err, service.ErrInvalidOrgRole), errors.Is(err, service.ErrInvalidOrgInvite):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrLastOrgOwner), errors.Is(err, service.ErrAlreadyOrgMember):
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrOrgInviteEmailMismatch):
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("organization request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
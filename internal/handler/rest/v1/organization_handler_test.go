@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// setupOrganizationRouter registers the organization routes behind the role checks, as the app router does,
+// with otherUser as the authenticated user
+func setupOrganizationRouter(orgs *service.MockOrganizationService, owners *service.MockOwnershipService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	controller := NewOrganizationController(orgs)
+	ownership := middleware.NewOwnershipMiddleware(owners)
+
+	api := router.Group("/organizations")
+	api.Use(middleware.NewMockAuthMiddleware().MustAuthAs(otherUser))
+	api.POST("", controller.CreateOrganization)
+	api.POST("/invites/accept", controller.AcceptInvite)
+	api.GET("/:org_id/members", ownership.MustHaveOrgRole("org_id", entity.OrgRoleViewer), controller.ListMembers)
+	api.POST("/:org_id/leave", ownership.MustHaveOrgRole("org_id", entity.OrgRoleViewer), controller.LeaveOrganization)
+	api.PUT("/:org_id/members/:user_id", ownership.MustHaveOrgRole("org_id", entity.OrgRoleOwner), controller.ChangeMemberRole)
+	api.POST("/:org_id/invites", ownership.MustHaveOrgRole("org_id", entity.OrgRoleOwner), controller.Invite)
+
+	return router
+}
+
+func TestCreateOrganization(t *testing.T) {
+	orgs := new(service.MockOrganizationService)
+	router := setupOrganizationRouter(orgs, new(service.MockOwnershipService))
+
+	tests := []struct {
+		name       string
+		body       string
+		setup      func()
+		wantStatus int
+	}{
+		{"Success", `{"name":"Studio"}`, func() {
+			org := &entity.Organization{ID: 9, Name: "Studio", CreatedBy: otherUser.ID, Role: entity.OrgRoleOwner}
+			orgs.On("CreateOrganization", otherUser.ID, "Studio").Return(org, nil).Once()
+		}, http.StatusCreated},
+		{"Missing Name", `{}`, func() {}, http.StatusBadRequest},
+		{"Blank Name", `{"name":" "}`, func() {
+			orgs.On("CreateOrganization", otherUser.ID, " ").Return(nil, service.ErrInvalidOrgName).Once()
+		}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			req, _ := http.NewRequest("POST", "/organizations", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	orgs.AssertExpectations(t)
+}
+
+func TestOrganizationRoleChecks(t *testing.T) {
+	orgs := new(service.MockOrganizationService)
+	owners := new(service.MockOwnershipService)
+	router := setupOrganizationRouter(orgs, owners)
+	owners.On("OrgRole", uint64(9), otherUser.ID).Return(entity.OrgRoleViewer, nil)
+	owners.On("OrgRole", uint64(10), otherUser.ID).Return(entity.OrgRoleOwner, nil)
+	owners.On("OrgRole", uint64(11), otherUser.ID).Return(entity.OrgRole(""), nil)
+
+	members := []entity.OrganizationMember{{OrgID: 9, UserID: otherUser.ID, Role: entity.OrgRoleViewer}}
+	orgs.On("ListMembers", uint64(9)).Return(members, nil).Once()
+	orgs.On("ChangeMemberRole", uint64(10), ownerUser.ID, entity.OrgRoleEditor).Return(nil).Once()
+	orgs.On("ChangeMemberRole", uint64(10), otherUser.ID, entity.OrgRoleEditor).Return(service.ErrLastOrgOwner).Once()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"Viewer Lists Members", "GET", "/organizations/9/members", "", http.StatusOK},
+		{"Non-Member Cannot List Members", "GET", "/organizations/11/members", "", http.StatusForbidden},
+		{"Viewer Cannot Change Roles", "PUT", "/organizations/9/members/1", `{"role":"editor"}`, http.StatusForbidden},
+		{"Viewer Cannot Invite", "POST", "/organizations/9/invites", `{"email":"bob@example.com","role":"viewer"}`, http.StatusForbidden},
+		{"Owner Changes Role", "PUT", "/organizations/10/members/1", `{"role":"editor"}`, http.StatusOK},
+		{"Last Owner Cannot Step Down", "PUT", "/organizations/10/members/2", `{"role":"editor"}`, http.StatusConflict},
+		{"Invalid Organization ID", "GET", "/organizations/abc/members", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	orgs.AssertExpectations(t)
+	orgs.AssertNotCalled(t, "Invite", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestInviteToOrganization(t *testing.T) {
+	orgs := new(service.MockOrganizationService)
+	owners := new(service.MockOwnershipService)
+	router := setupOrganizationRouter(orgs, owners)
+	owners.On("OrgRole", uint64(10), otherUser.ID).Return(entity.OrgRoleOwner, nil)
+
+	tests := []struct {
+		name       string
+		body       string
+		setup      func()
+		wantStatus int
+	}{
+		{"Success", `{"email":"bob@example.com","role":"editor"}`, func() {
+			invite := &entity.OrganizationInvite{ID: 3, OrgID: 10, Email: "bob@example.com", Role: entity.OrgRoleEditor, TokenHash: "secret hash"}
+			orgs.On("Invite", uint64(10), otherUser, "bob@example.com", entity.OrgRoleEditor).Return(invite, nil).Once()
+		}, http.StatusCreated},
+		{"Invalid Email", `{"email":"bob","role":"editor"}`, func() {}, http.StatusBadRequest},
+		{"Invalid Role", `{"email":"bob@example.com","role":"admin"}`, func() {
+			orgs.On("Invite", uint64(10), otherUser, "bob@example.com", entity.OrgRole("admin")).Return(nil, service.ErrInvalidOrgRole).Once()
+		}, http.StatusBadRequest},
+		{"Already Member", `{"email":"carol@example.com","role":"viewer"}`, func() {
+			orgs.On("Invite", uint64(10), otherUser, "carol@example.com", entity.OrgRoleViewer).Return(nil, service.ErrAlreadyOrgMember).Once()
+		}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			req, _ := http.NewRequest("POST", "/organizations/10/invites", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.NotContains(t, w.Body.String(), "secret hash")
+		})
+	}
+
+	orgs.AssertExpectations(t)
+}
+
+func TestAcceptInvite(t *testing.T) {
+	orgs := new(service.MockOrganizationService)
+	router := setupOrganizationRouter(orgs, new(service.MockOwnershipService))
+
+	tests := []struct {
+		name       string
+		body       string
+		setup      func()
+		wantStatus int
+	}{
+		{"Success", `{"token":"good"}`, func() {
+			org := &entity.Organization{ID: 9, Name: "Studio", Role: entity.OrgRoleEditor}
+			orgs.On("AcceptInvite", otherUser, "good").Return(org, nil).Once()
+		}, http.StatusOK},
+		{"Missing Token", `{}`, func() {}, http.StatusBadRequest},
+		{"Expired", `{"token":"old"}`, func() {
+			orgs.On("AcceptInvite", otherUser, "old").Return(nil, service.ErrInvalidOrgInvite).Once()
+		}, http.StatusBadRequest},
+		{"Other Email", `{"token":"theirs"}`, func() {
+			orgs.On("AcceptInvite", otherUser, "theirs").Return(nil, service.ErrOrgInviteEmailMismatch).Once()
+		}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			req, _ := http.NewRequest("POST", "/organizations/invites/accept", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				var org entity.Organization
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &org))
+				assert.Equal(t, entity.OrgRoleEditor, org.Role)
+			}
+		})
+	}
+
+	orgs.AssertExpectations(t)
+}
+
+func TestAddVideoToOrganization(t *testing.T) {
+	videos := new(service.MockVideoService)
+	orgs := new(service.MockOrganizationService)
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(middleware.NewMockAuthMiddleware().MustAuthAs(otherUser))
+	subscriptions := new(service.MockSubscriptionService)
+	subscriptions.On("CheckVideoQuota", otherUser.ID, 0).Return(nil)
+	router.POST("/videos", NewVideoController(videos, orgs, subscriptions).AddVideo)
+
+	orgs.On("MemberRole", uint64(9), otherUser.ID).Return(entity.OrgRoleViewer, nil).Once()
+	orgs.On("MemberRole", uint64(10), otherUser.ID).Return(entity.OrgRoleEditor, nil).Once()
+	videos.On("CreateVideo", mock.MatchedBy(func(v *entity.Video) bool { return v.OrgID != nil && *v.OrgID == 10 })).Return(nil).Once()
+
+	for _, tt := range []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"Viewer Cannot Add", `{"title":"Talk","file_name":"talk.mp4","org_id":9}`, http.StatusForbidden},
+		{"Editor Adds", `{"title":"Talk","file_name":"talk.mp4","org_id":10}`, http.StatusCreated},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/videos", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusCreated {
+				var resp response.CreatedResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			}
+		})
+	}
+
+	videos.AssertExpectations(t)
+	orgs.AssertExpectations(t)
+}
@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/repo"
+	"mlvt/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookBodySize bounds the notifications read from providers
+const maxWebhookBodySize = 1 << 20
+
+type PaymentController struct {
+	paymentService service.PaymentService
+	orderService   service.OrderService
+}
+
+func NewPaymentController(paymentService service.PaymentService, orderService service.OrderService) *PaymentController {
+	return &PaymentController{paymentService: paymentService, orderService: orderService}
+}
+
+// CreatePaymentRequest represents the request body for creating a payment
+type CreatePaymentRequest struct {
+	Plan string `json:"plan" binding:"required"` // Code of the plan, e.g. premium_monthly
+}
+
+// CheckPaymentStatusRequest represents the request body for checking a payment
+type CheckPaymentStatusRequest struct {
+	OrderID string `json:"order_id" binding:"required"`
+}
+
+// RefundPaymentRequest represents the request body for refunding a payment
+type RefundPaymentRequest struct {
+	OrderID string `json:"order_id" binding:"required"`
+	Amount  int64  `json:"amount"` // In the currency of the order; what is left to refund when omitted
+}
+
+// ListProviders godoc
+// @Summary List payment providers
+// @Description Lists the providers orders can be paid through, by the name used in the payment routes
+// @Tags payments
+// @Produce json
+// @Success 200 {object} response.PaymentProvidersResponse
+// @Router /payments/providers [get]
+func (p *PaymentController) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, response.PaymentProvidersResponse{Providers: p.paymentService.Providers()})
+}
+
+// CreatePayment godoc
+// @Summary Create a payment
+// @Description Creates an order of the plan for the current user, for the price of the plan, and a payment for it at the provider. Returns the order with the payment page and a QR code to pay it with a phone
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "Payment provider, e.g. momo or vnpay"
+// @Param request body CreatePaymentRequest true "Plan to pay for"
+// @Success 201 {object} response.CheckoutResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Failure 503 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /payments/{provider}/create [post]
+func (p *PaymentController) CreatePayment(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var request CreatePaymentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request"})
+		return
+	}
+
+	checkout, err := p.paymentService.CreateCheckout(userInfo.ID, c.Param("provider"), request.Plan, c.ClientIP())
+	if err != nil {
+		handlePaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.CheckoutResponse{
+		Order:    *checkout.Order,
+		PayURL:   checkout.PayURL,
+		Deeplink: checkout.Deeplink,
+		QRCode:   checkout.QRCode,
+	})
+}
+
+// CheckPaymentStatus godoc
+// @Summary Check a payment
+// @Description Asks the provider for the status of the payment of an order of the current user and updates the order with it. Users with the payment:refund permission may check any order
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "Payment provider of the order"
+// @Param request body CheckPaymentStatusRequest true "Order ID"
+// @Success 200 {object} response.PaymentStatusResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Failure 503 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /payments/{provider}/check-status [post]
+func (p *PaymentController) CheckPaymentStatus(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var request CheckPaymentStatusRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request"})
+		return
+	}
+
+	order, err := p.orderService.GetOrder(request.OrderID)
+	if err == nil && !canSeeOrder(userInfo, order) {
+		err = service.ErrOrderNotFound
+	}
+	if err != nil {
+		handlePaymentError(c, err)
+		return
+	}
+
+	order, status, err := p.paymentService.CheckPaymentStatus(c.Param("provider"), order.Reference)
+	if err != nil {
+		handlePaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.PaymentStatusResponse{
+		OrderID:       order.Reference,
+		Provider:      order.Provider,
+		Status:        order.Status,
+		Paid:          status.State == entity.PaymentStatePaid,
+		Pending:       status.State == entity.PaymentStatePending,
+		Amount:        status.Amount,
+		TransactionID: status.TransactionID,
+		Code:          status.Code,
+		Message:       status.Message,
+	})
+}
+
+// RefundPayment godoc
+// @Summary Refund a payment
+// @Description Refunds all or part of a paid order at its provider. Requires the payment:refund permission
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "Payment provider of the order"
+// @Param request body RefundPaymentRequest true "Order ID and optional amount"
+// @Success 200 {object} response.RefundResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Failure 503 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /payments/{provider}/refund [post]
+func (p *PaymentController) RefundPayment(c *gin.Context) {
+	var request RefundPaymentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request"})
+		return
+	}
+
+	order, refund, err := p.paymentService.RefundPayment(c.Param("provider"), request.OrderID, request.Amount)
+	if err != nil {
+		handlePaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.RefundResponse{
+		OrderID:        order.Reference,
+		RefundID:       refund.RefundID,
+		Amount:         refund.Amount,
+		TransactionID:  refund.TransactionID,
+		Status:         order.Status,
+		RefundedAmount: order.RefundedAmount,
+	})
+}
+
+// PaymentWebhook godoc
+// @Summary Receive a payment notification
+// @Description Called by the provider when a payment ends: MoMo posts JSON, VNPay calls with query parameters. The notification must be signed with the provider's secret and at most an hour old when it first arrives. It is answered in the format the provider expects; retries of a notification already handled are answered the same way
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param provider path string true "Payment provider"
+// @Success 200
+// @Success 204
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /payments/{provider}/ipn [post]
+// @Router /payments/{provider}/ipn [get]
+func (p *PaymentController) PaymentWebhook(c *gin.Context) {
+	provider := c.Param("provider")
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodySize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request"})
+		return
+	}
+
+	err = p.paymentService.HandleWebhook(provider, &entity.WebhookRequest{
+		Query:  c.Request.URL.Query(),
+		Header: c.Request.Header,
+		Body:   body,
+	})
+	if errors.Is(err, service.ErrUnknownPaymentProvider) {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, repo.ErrInvalidWebhookSignature), errors.Is(err, repo.ErrMalformedWebhook),
+		errors.Is(err, service.ErrStaleWebhook), errors.Is(err, service.ErrWebhookAmountMismatch), errors.Is(err, service.ErrOrderNotFound):
+		log.Warnf("Rejected %s notification: %v", provider, err)
+	default:
+		// The provider retries the notification
+		log.Errorf("Failed to handle %s notification: %v", provider, err)
+	}
+
+	answer, err := p.paymentService.WebhookResponse(provider, err)
+	if err != nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if answer.Body == nil {
+		c.Status(answer.Status)
+		return
+	}
+	c.JSON(answer.Status, answer.Body)
+}
+
+func handlePaymentError(c *gin.Context, err error) {
+	var momoErr *entity.MoMoError
+	var vnpayErr *entity.VNPayError
+	var providerErr entity.PaymentProviderError
+	switch {
+	case errors.Is(err, service.ErrInvalidPaymentAmount), errors.Is(err, service.ErrRefundTooLarge), errors.Is(err, service.ErrPlanNotPayable):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrUnknownPaymentProvider), errors.Is(err, service.ErrPlanNotFound), errors.Is(err, service.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrPaymentNotPaid), errors.Is(err, service.ErrInvalidOrderTransition):
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+	case errors.As(err, &momoErr) && momoErr.ResultCode == entity.MoMoResultOrderNotFound,
+		errors.As(err, &vnpayErr) && vnpayErr.ResponseCode == entity.VNPayResponseOrderNotFound:
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "order not found"})
+	case errors.As(err, &momoErr) && momoErr.ResultCode == entity.MoMoResultDuplicateOrderID:
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: "a payment was already created for the order"})
+	case errors.As(err, &providerErr):
+		log.Errorf("Payment provider rejected the request: %v", err)
+		c.JSON(http.StatusBadGateway, response.ErrorResponse{Error: "the payment provider rejected the request: " + providerErr.ProviderMessage()})
+	case errors.Is(err, repo.ErrProviderNotConfigured):
+		log.Errorf("Payment request failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, response.ErrorResponse{Error: "the payment provider is not configured"})
+	default:
+		log.Errorf("Payment request failed: %v", err)
+		c.JSON(http.StatusBadGateway, response.ErrorResponse{Error: "the payment provider is unavailable"})
+	}
+}
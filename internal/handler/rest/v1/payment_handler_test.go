@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/repo"
+	"mlvt/internal/repo/momotest"
+	"mlvt/internal/repo/vnpaytest"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// paymentTestRouter serves the payment routes with MoMo and VNPay clients calling local fake gateways
+type paymentTestRouter struct {
+	*gin.Engine
+	url   string // Of a local server serving the routes too, which the fakes send their notifications to
+	momo  *momotest.Server
+	vnpay *vnpaytest.Server
+}
+
+// setupPaymentRouter serves the payment and subscription routes. The routes of the current user are served under /owner, /other
+// and /admin, authenticated as ownerUser, otherUser and adminUser.
+func setupPaymentRouter(t *testing.T) *paymentTestRouter {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	for _, migration := range []string{
+		"0001_create_users_table", "0004_create_transaction_logs_table", "0025_rework_transaction_logs",
+		"0026_create_orders_tables", "0027_create_subscriptions_tables",
+	} {
+		schema, err := os.ReadFile("../../../../migration/" + migration + ".up.sql")
+		require.NoError(t, err)
+		_, err = db.Exec(string(schema))
+		require.NoError(t, err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	momo := momotest.NewServer("MOMOTEST", "access-key", "secret-key")
+	t.Cleanup(momo.Close)
+	vnpay := vnpaytest.NewServer("VNPTEST1", "hash-secret")
+	t.Cleanup(vnpay.Close)
+	momoRepo := repo.NewMoMoRepoWithConfig(env.MoMoConfig{
+		Endpoint: momo.URL, PartnerCode: "MOMOTEST", AccessKey: "access-key", SecretKey: "secret-key",
+		RedirectURL: "http://localhost:3000/payment-result", IPNURL: server.URL + "/payments/momo/ipn",
+		RequestType: "captureWallet", Lang: "en", Timeout: 5 * time.Second,
+	})
+	vnpayRepo := repo.NewVNPayRepoWithConfig(env.VNPayConfig{
+		PayURL: vnpay.PayURL, APIURL: vnpay.APIURL, TmnCode: "VNPTEST1", HashSecret: "hash-secret",
+		ReturnURL: "http://localhost:3000/payment-result", Locale: "en", ExpireAfter: 15 * time.Minute, Timeout: 5 * time.Second,
+	})
+	orderService := service.NewOrderService(repo.NewOrderRepo(db), repo.NewPlanRepo(db))
+	subscriptionService := service.NewSubscriptionService(repo.NewSubscriptionRepo(db), repo.NewPlanRepo(db), repo.NewVideoRepo(db))
+	paymentService := service.NewPaymentService(repo.NewPaymentProviders(momoRepo, vnpayRepo), repo.NewTransactionLogRepo(db), orderService,
+		subscriptionService)
+	controller := NewPaymentController(paymentService, orderService)
+	orderController := NewOrderController(orderService)
+	subscriptionController := NewSubscriptionController(subscriptionService, paymentService)
+
+	router.GET("/payments/plans", orderController.ListPlans)
+	router.GET("/payments/providers", controller.ListProviders)
+	router.POST("/payments/:provider/ipn", controller.PaymentWebhook)
+	router.GET("/payments/:provider/ipn", controller.PaymentWebhook)
+	auth := middleware.NewMockAuthMiddleware()
+	for prefix, userInfo := range map[string]*entity.User{"/owner": ownerUser, "/other": otherUser, "/admin": adminUser} {
+		user := router.Group(prefix, auth.MustAuthAs(userInfo))
+		user.GET("/payments/orders", orderController.ListOrders)
+		user.GET("/payments/orders/:order_id", orderController.GetOrder)
+		user.POST("/payments/:provider/create", controller.CreatePayment)
+		user.POST("/payments/:provider/check-status", controller.CheckPaymentStatus)
+		user.POST("/payments/:provider/refund", controller.RefundPayment)
+		user.GET("/subscriptions", subscriptionController.GetSubscription)
+		user.GET("/subscriptions/periods", subscriptionController.ListPeriods)
+		user.POST("/subscriptions/renew", subscriptionController.RenewSubscription)
+		user.POST("/subscriptions/cancel", subscriptionController.CancelSubscription)
+		user.POST("/subscriptions/resume", subscriptionController.ResumeSubscription)
+	}
+	return &paymentTestRouter{Engine: router, url: server.URL, momo: momo, vnpay: vnpay}
+}
+
+func postJSON(router http.Handler, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// createOrder orders the monthly plan through the provider as ownerUser and returns the checkout
+func createOrder(t *testing.T, router http.Handler, provider string) response.CheckoutResponse {
+	w := postJSON(router, "/owner/payments/"+provider+"/create", `{"plan":"premium_monthly"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var checkout response.CheckoutResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &checkout))
+	return checkout
+}
+
+// getOrder gets an order as ownerUser
+func getOrder(t *testing.T, router http.Handler, reference string) entity.Order {
+	req, _ := http.NewRequest("GET", "/owner/payments/orders/"+reference, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var order entity.Order
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	return order
+}
+
+func TestListPaymentProviders(t *testing.T) {
+	router := setupPaymentRouter(t)
+
+	req, _ := http.NewRequest("GET", "/payments/providers", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var providers response.PaymentProvidersResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &providers))
+	assert.Equal(t, []string{"momo", "vnpay"}, providers.Providers)
+}
+
+func TestMoMoPaymentFlow(t *testing.T) {
+	router := setupPaymentRouter(t)
+
+	w := postJSON(router, "/owner/payments/momo/create", `{"plan":"premium_monthly","amount":1000}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var checkout response.CheckoutResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &checkout))
+	assert.Equal(t, int64(99000), checkout.Order.Amount, "the amount comes from the plan, not the client")
+	assert.Equal(t, ownerUser.ID, checkout.Order.UserID)
+	assert.Equal(t, entity.OrderStatusPending, checkout.Order.Status)
+	assert.NotEmpty(t, checkout.QRCode)
+	orderID := checkout.Order.Reference
+
+	w = postJSON(router, "/admin/payments/momo/refund", `{"order_id":"`+orderID+`"}`)
+	assert.Equal(t, http.StatusConflict, w.Code, "the payment is not completed")
+
+	_, ok := router.momo.Pay(orderID)
+	require.True(t, ok)
+	w = postJSON(router, "/owner/payments/momo/check-status", `{"order_id":"`+orderID+`"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status response.PaymentStatusResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.True(t, status.Paid)
+	assert.False(t, status.Pending)
+	assert.Equal(t, entity.OrderStatusPaid, status.Status)
+	assert.Equal(t, int64(99000), status.Amount)
+	assert.Equal(t, "0", status.Code, "MoMo's result code")
+
+	w = postJSON(router, "/admin/payments/momo/refund", `{"order_id":"`+orderID+`","amount":10000}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var refund response.RefundResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refund))
+	assert.Equal(t, int64(10000), refund.Amount)
+	assert.NotEqual(t, orderID, refund.RefundID)
+	assert.Equal(t, entity.OrderStatusPartiallyRefunded, refund.Status)
+	assert.Equal(t, int64(10000), refund.RefundedAmount)
+}
+
+func TestVNPayPaymentFlow(t *testing.T) {
+	router := setupPaymentRouter(t)
+	checkout := createOrder(t, router, "vnpay")
+	assert.Equal(t, entity.PaymentProviderVNPay, checkout.Order.Provider)
+	assert.NotEmpty(t, checkout.QRCode)
+	require.NoError(t, router.vnpay.Open(checkout.PayURL))
+	orderID := checkout.Order.Reference
+
+	w := postJSON(router, "/owner/payments/vnpay/check-status", `{"order_id":"`+orderID+`"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status response.PaymentStatusResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.True(t, status.Pending)
+
+	// VNPay calls the IPN URL registered for the terminal with query parameters
+	transactionNo, ok := router.vnpay.Pay(orderID)
+	require.True(t, ok)
+	answer, err := router.vnpay.Notify(orderID, router.url+"/payments/vnpay/ipn")
+	require.NoError(t, err)
+	assert.Equal(t, entity.VNPayIPNConfirmed, answer.RspCode)
+	answer, err = router.vnpay.Notify(orderID, router.url+"/payments/vnpay/ipn")
+	require.NoError(t, err)
+	assert.Equal(t, entity.VNPayIPNConfirmed, answer.RspCode, "retries are answered the same way")
+	paid := getOrder(t, router, orderID)
+	assert.Equal(t, entity.OrderStatusPaid, paid.Status)
+	assert.Equal(t, transactionNo, paid.TransactionID)
+
+	w = postJSON(router, "/admin/payments/vnpay/refund", `{"order_id":"`+orderID+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var refund response.RefundResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refund))
+	assert.Equal(t, int64(99000), refund.Amount)
+	assert.Equal(t, entity.OrderStatusRefunded, refund.Status)
+
+	req := httptest.NewRequest("GET", "/payments/vnpay/ipn?vnp_TxnRef="+orderID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, "VNPay expects 200 with a response code")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), answer))
+	assert.Equal(t, entity.VNPayIPNInvalidChecksum, answer.RspCode)
+}
+
+func TestPaymentErrors(t *testing.T) {
+	router := setupPaymentRouter(t)
+	order := createOrder(t, router, "momo").Order
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"Missing Plan", "/owner/payments/momo/create", `{"amount":50000}`, http.StatusBadRequest},
+		{"Unknown Plan", "/owner/payments/momo/create", `{"plan":"gold"}`, http.StatusNotFound},
+		{"Unknown Provider", "/owner/payments/paypal/create", `{"plan":"premium_monthly"}`, http.StatusNotFound},
+		{"Unknown Order", "/owner/payments/momo/check-status", `{"order_id":"unknown"}`, http.StatusNotFound},
+		{"Order Of Another Provider", "/owner/payments/vnpay/check-status", `{"order_id":"` + order.Reference + `"}`, http.StatusNotFound},
+		{"Order Of Another User", "/other/payments/momo/check-status", `{"order_id":"` + order.Reference + `"}`, http.StatusNotFound},
+		{"Refund Unknown Order", "/admin/payments/momo/refund", `{"order_id":"unknown"}`, http.StatusNotFound},
+		{"Negative Refund", "/admin/payments/momo/refund", `{"order_id":"` + order.Reference + `","amount":-1}`, http.StatusBadRequest},
+		{"Notification For Unknown Provider", "/payments/paypal/ipn", `{}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(router, tt.path, tt.body)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	w := postJSON(router, "/admin/payments/momo/check-status", `{"order_id":"`+order.Reference+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code, "users who may refund payments may check any order")
+}
+
+func TestMoMoIPN(t *testing.T) {
+	router := setupPaymentRouter(t)
+	order := createOrder(t, router, "momo").Order
+	_, ok := router.momo.Pay(order.Reference)
+	require.True(t, ok)
+
+	status, err := router.momo.Notify(order.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, status)
+	status, err = router.momo.Notify(order.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, status, "retries are answered the same way")
+	assert.Equal(t, entity.OrderStatusPaid, getOrder(t, router, order.Reference).Status, "the notification updated the order")
+
+	notification, ok := router.momo.Notification(order.Reference)
+	require.True(t, ok)
+	notification.Amount = 1000
+	body, err := json.Marshal(notification)
+	require.NoError(t, err)
+	w := postJSON(router, "/payments/momo/ipn", string(body))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postJSON(router, "/payments/momo/ipn", `{"orderId":`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PipelineTargetRequest represents a language the video is translated to in a pipeline
+type PipelineTargetRequest struct {
+	Lang       string `json:"lang" binding:"required"`
+	Synthesize bool   `json:"synthesize"` // Synthesize speech from the translation
+	Mux        bool   `json:"mux"`        // Replace the audio track of the video with the synthesized speech, requires synthesize
+}
+
+// CreatePipelineRequest represents the request body for creating a pipeline
+type CreatePipelineRequest struct {
+	SourceLang      string                  `json:"source_lang"`      // Spoken language of the video, defaults to the language of transcription_id
+	TranscriptionID uint64                  `json:"transcription_id"` // Existing transcription to translate instead of transcribing the video
+	Targets         []PipelineTargetRequest `json:"targets" binding:"dive"`
+}
+
+type PipelineController struct {
+	pipelineService service.PipelineService
+}
+
+func NewPipelineController(pipelineService service.PipelineService) *PipelineController {
+	return &PipelineController{
+		pipelineService: pipelineService,
+	}
+}
+
+// CreatePipeline godoc
+// @Summary Create a translation pipeline for a video
+// @Description Declares the steps to run for the video: speech-to-text in source_lang, then for each target a translation, optionally followed by speech synthesis and muxing of the synthesized audio into the video. Steps are handed to the ML workers as soon as the step they depend on succeeds
+// @Tags Pipelines
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param video_id path uint64 true "Video ID"
+// @Param request body CreatePipelineRequest true "Pipeline definition"
+// @Success 201 {object} response.PipelineResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /videos/{video_id}/pipelines [post]
+func (h *PipelineController) CreatePipeline(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	videoID, err := strconv.ParseUint(c.Param("video_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid video ID"})
+		return
+	}
+
+	var req CreatePipelineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	spec := service.PipelineSpec{SourceLang: req.SourceLang, TranscriptionID: req.TranscriptionID}
+	for _, target := range req.Targets {
+		spec.Targets = append(spec.Targets, service.PipelineTarget{Lang: target.Lang, Synthesize: target.Synthesize, Mux: target.Mux})
+	}
+
+	pipeline, err := h.pipelineService.CreatePipeline(userInfo.ID, videoID, spec)
+	if err != nil {
+		handlePipelineError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.PipelineResponse{Pipeline: *pipeline})
+}
+
+// GetPipeline godoc
+// @Summary Get the status of a pipeline
+// @Description Retrieves a pipeline of the video with the status, inputs and outputs of every step. progress aggregates the progress reported by the workers across all steps
+// @Tags Pipelines
+// @Produce json
+// @Security BearerAuth
+// @Param video_id path uint64 true "Video ID"
+// @Param pipeline_id path uint64 true "Pipeline ID"
+// @Success 200 {object} response.PipelineResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /videos/{video_id}/pipelines/{pipeline_id} [get]
+func (h *PipelineController) GetPipeline(c *gin.Context) {
+	videoID, err := strconv.ParseUint(c.Param("video_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid video ID"})
+		return
+	}
+	pipelineID, err := strconv.ParseUint(c.Param("pipeline_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid pipeline ID"})
+		return
+	}
+
+	pipeline, err := h.pipelineService.GetPipeline(videoID, pipelineID)
+	if err != nil {
+		handlePipelineError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.PipelineResponse{Pipeline: *pipeline})
+}
+
+// ListPipelines godoc
+// @Summary List the pipelines of a video
+// @Description Lists the pipelines of the video, newest first, with the status of their steps
+// @Tags Pipelines
+// @Produce json
+// @Security BearerAuth
+// @Param video_id path uint64 true "Video ID"
+// @Success 200 {object} response.PipelinesResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /videos/{video_id}/pipelines [get]
+func (h *PipelineController) ListPipelines(c *gin.Context) {
+	videoID, err := strconv.ParseUint(c.Param("video_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid video ID"})
+		return
+	}
+
+	pipelines, err := h.pipelineService.ListPipelinesByVideoID(videoID)
+	if err != nil {
+		handlePipelineError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.PipelinesResponse{Pipelines: pipelines})
+}
+
+// handlePipelineError maps pipeline errors to HTTP responses
+func handlePipelineError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidPipeline):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrVideoNotFound), errors.Is(err, service.ErrPipelineNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("Pipeline request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
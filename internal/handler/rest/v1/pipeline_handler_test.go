@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupPipelineRouter(controller *PipelineController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	videos := router.Group("/videos")
+	videos.Use(middleware.NewMockAuthMiddleware().MustAuthAuthenticated())
+	videos.POST("/:video_id/pipelines", controller.CreatePipeline)
+	videos.GET("/:video_id/pipelines", controller.ListPipelines)
+	videos.GET("/:video_id/pipelines/:pipeline_id", controller.GetPipeline)
+
+	return router
+}
+
+func TestCreatePipeline(t *testing.T) {
+	mockService := new(service.MockPipelineService)
+	router := setupPipelineRouter(NewPipelineController(mockService))
+
+	t.Run("Success", func(t *testing.T) {
+		spec := service.PipelineSpec{
+			SourceLang: "en",
+			Targets:    []service.PipelineTarget{{Lang: "vi", Synthesize: true, Mux: true}, {Lang: "ja"}},
+		}
+		pipeline := &entity.Pipeline{ID: 3, VideoID: 7, UserID: 1, SourceLang: "en", Status: entity.PipelineStatusRunning,
+			Steps: []entity.PipelineStep{{Name: "stt:en", Type: entity.MLTaskSpeechToText, Status: entity.PipelineStepQueued}}}
+		mockService.On("CreatePipeline", uint64(1), uint64(7), spec).Return(pipeline, nil).Once()
+
+		body := `{"source_lang":"en","targets":[{"lang":"vi","synthesize":true,"mux":true},{"lang":"ja"}]}`
+		req, _ := http.NewRequest("POST", "/videos/7/pipelines", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.PipelineResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, uint64(3), resp.Pipeline.ID)
+		assert.Equal(t, "stt:en", resp.Pipeline.Steps[0].Name)
+	})
+
+	tests := []struct {
+		name string
+		lang string
+		err  error
+		code int
+	}{
+		{"Invalid Pipeline", "en", fmt.Errorf("%w: same language", service.ErrInvalidPipeline), http.StatusBadRequest},
+		{"Video Not Found", "de", service.ErrVideoNotFound, http.StatusNotFound},
+		{"Internal Error", "fr", errors.New("database error"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := service.PipelineSpec{SourceLang: "en", Targets: []service.PipelineTarget{{Lang: tt.lang}}}
+			mockService.On("CreatePipeline", uint64(1), uint64(7), spec).Return(nil, tt.err).Once()
+
+			body := fmt.Sprintf(`{"source_lang":"en","targets":[{"lang":%q}]}`, tt.lang)
+			req, _ := http.NewRequest("POST", "/videos/7/pipelines", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+
+	t.Run("Missing Target Language", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/videos/7/pipelines", bytes.NewBufferString(`{"source_lang":"en","targets":[{"synthesize":true}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invalid Video ID", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/videos/abc/pipelines", bytes.NewBufferString(`{"source_lang":"en"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetPipeline(t *testing.T) {
+	mockService := new(service.MockPipelineService)
+	router := setupPipelineRouter(NewPipelineController(mockService))
+
+	t.Run("Success", func(t *testing.T) {
+		pipeline := &entity.Pipeline{ID: 3, VideoID: 7, Status: entity.PipelineStatusRunning, Progress: 60}
+		mockService.On("GetPipeline", uint64(7), uint64(3)).Return(pipeline, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/videos/7/pipelines/3", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.PipelineResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 60, resp.Pipeline.Progress)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		mockService.On("GetPipeline", uint64(7), uint64(4)).Return(nil, service.ErrPipelineNotFound).Once()
+
+		req, _ := http.NewRequest("GET", "/videos/7/pipelines/4", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Invalid Pipeline ID", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/videos/7/pipelines/abc", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestListPipelines(t *testing.T) {
+	mockService := new(service.MockPipelineService)
+	router := setupPipelineRouter(NewPipelineController(mockService))
+
+	pipelines := []entity.Pipeline{{ID: 4, VideoID: 7}, {ID: 3, VideoID: 7}}
+	mockService.On("ListPipelinesByVideoID", uint64(7)).Return(pipelines, nil).Once()
+
+	req, _ := http.NewRequest("GET", "/videos/7/pipelines", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp response.PipelinesResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, resp.Pipelines, 2)
+	mockService.AssertExpectations(t)
+}
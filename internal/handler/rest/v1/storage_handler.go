@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"mlvt/internal/infra/aws"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StorageController serves signed upload and download URLs for the local storage backend
+type StorageController struct {
+	local *aws.LocalStorageClient
+}
+
+func NewStorageController(s3Client aws.S3ClientInterface) *StorageController {
+	local, _ := s3Client.(*aws.LocalStorageClient)
+	return &StorageController{local: local}
+}
+
+// Enabled reports whether the server is running on the local storage backend
+func (h *StorageController) Enabled() bool {
+	return h.local != nil
+}
+
+// userFileName returns the storage file name of a file the user uploads: the base name of fileName under the
+// prefix of the user, so clients cannot point records at the files of others. Passing a name it returned gives it
+// back unchanged. It reports false when fileName has no base name.
+func userFileName(userID uint64, fileName string) (string, bool) {
+	base := path.Base(fileName)
+	if base == "." || base == "/" || base == ".." {
+		return "", false
+	}
+	return fmt.Sprintf("user_%d/%s", userID, base), true
+}
+
+// UploadObject godoc
+// @Summary Upload a file to local storage
+// @Description Stores the request body under the object key using a signed URL issued by the local storage backend
+// @Tags storage
+// @Accept octet-stream
+// @Produce json
+// @Param key path string true "Object key (folder/file name)"
+// @Param method query string true "Signed method"
+// @Param expires query int true "Expiry as unix timestamp"
+// @Param signature query string true "HMAC signature"
+// @Param upload_id query string false "Multipart upload ID when uploading a part"
+// @Param part_number query int false "Part number when uploading a part"
+// @Success 200 {object} response.MessageResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /storage/{key} [put]
+func (h *StorageController) UploadObject(c *gin.Context) {
+	key, ok := h.authorize(c, http.MethodPut)
+	if !ok {
+		return
+	}
+
+	if uploadID := c.Query(aws.LocalParamUploadID); uploadID != "" {
+		h.uploadPart(c, key, uploadID)
+		return
+	}
+
+	// Presigned S3 uploads are bound to the content type they were issued for
+	if contentType := c.Query(aws.LocalParamContentType); contentType != "" && contentType != c.ContentType() {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "content type does not match signed URL"})
+		return
+	}
+
+	if err := h.local.WriteObject(key, c.GetHeader("Content-Type"), c.Request.Body); err != nil {
+		log.Errorf("Failed to store object %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to store object"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "File uploaded successfully"})
+}
+
+// DownloadObject godoc
+// @Summary Download a file from local storage
+// @Description Serves the object stored under the key using a signed URL issued by the local storage backend
+// @Tags storage
+// @Produce octet-stream
+// @Param key path string true "Object key (folder/file name)"
+// @Param method query string true "Signed method"
+// @Param expires query int true "Expiry as unix timestamp"
+// @Param signature query string true "HMAC signature"
+// @Param response_content_type query string false "Signed Content-Type override"
+// @Param response_content_disposition query string false "Signed Content-Disposition override"
+// @Success 200 {file} file
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /storage/{key} [get]
+func (h *StorageController) DownloadObject(c *gin.Context) {
+	key, ok := h.authorize(c, http.MethodGet)
+	if !ok {
+		return
+	}
+
+	path, err := h.local.ObjectPath(key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "file not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+		}
+		return
+	}
+
+	// Apply the response overrides the URL was signed with, like S3 does
+	if contentType := c.Query(aws.LocalParamResponseContentType); contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	if disposition := c.Query(aws.LocalParamResponseContentDisposition); disposition != "" {
+		c.Header("Content-Disposition", disposition)
+	}
+
+	c.File(path)
+}
+
+// uploadPart stores one part of a multipart upload and returns its ETag header like S3 does
+func (h *StorageController) uploadPart(c *gin.Context, key string, uploadID string) {
+	partNumber, err := strconv.ParseInt(c.Query(aws.LocalParamPartNumber), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid part number"})
+		return
+	}
+
+	etag, err := h.local.WritePart(key, uploadID, int32(partNumber), c.Request.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, aws.ErrNoSuchUpload):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, aws.ErrInvalidPart):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid part number"})
+		default:
+			log.Errorf("Failed to store part %d of upload %s: %v", partNumber, uploadID, err)
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "failed to store part"})
+		}
+		return
+	}
+
+	c.Header("ETag", etag)
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "Part uploaded successfully"})
+}
+
+// authorize validates the signed query of the request and returns the object key
+func (h *StorageController) authorize(c *gin.Context, method string) (string, bool) {
+	if h.local == nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: "local storage is not enabled"})
+		return "", false
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	err := h.local.VerifySignedRequest(method, key, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+		return "", false
+	}
+
+	return key, true
+}
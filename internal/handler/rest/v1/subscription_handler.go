@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubscriptionController manages the premium subscription of the current user
+type SubscriptionController struct {
+	subscriptionService service.SubscriptionService
+	paymentService      service.PaymentService
+}
+
+// NewSubscriptionController creates a new SubscriptionController
+func NewSubscriptionController(subscriptionService service.SubscriptionService, paymentService service.PaymentService) *SubscriptionController {
+	return &SubscriptionController{subscriptionService: subscriptionService, paymentService: paymentService}
+}
+
+// RenewSubscriptionRequest represents the request body for renewing a subscription
+type RenewSubscriptionRequest struct {
+	Provider string `json:"provider" binding:"required"` // Payment provider, e.g. momo or vnpay
+	Plan     string `json:"plan"`                        // Code of the plan to switch to; the plan of the subscription when empty
+}
+
+// GetSubscription godoc
+// @Summary Get the subscription
+// @Description Returns whether the current user has premium, their subscription with its plan and period, and the quotas that apply to them. Users who never subscribed get the free quotas
+// @Tags subscriptions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.SubscriptionResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /subscriptions [get]
+func (h *SubscriptionController) GetSubscription(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	h.respond(c, userInfo.ID)
+}
+
+// ListPeriods godoc
+// @Summary List subscription periods
+// @Description Lists the periods the paid orders of the current user added to their subscription, the newest first
+// @Tags subscriptions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.SubscriptionPeriodsResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /subscriptions/periods [get]
+func (h *SubscriptionController) ListPeriods(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	periods, err := h.subscriptionService.ListPeriods(userInfo.ID)
+	if err != nil {
+		handleSubscriptionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SubscriptionPeriodsResponse{Periods: periods})
+}
+
+// RenewSubscription godoc
+// @Summary Renew the subscription
+// @Description Creates an order of the plan of the subscription of the current user, or of another plan to switch to, and a payment of it at the provider. Once paid, the billing period of the plan is added after the time already paid for; an expired subscription starts again from the payment
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RenewSubscriptionRequest true "Provider to pay through and plan"
+// @Success 201 {object} response.CheckoutResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 502 {object} response.ErrorResponse
+// @Failure 503 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /subscriptions/renew [post]
+func (h *SubscriptionController) RenewSubscription(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var request RenewSubscriptionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "Invalid request"})
+		return
+	}
+
+	if request.Plan == "" {
+		_, plan, err := h.subscriptionService.GetSubscription(userInfo.ID)
+		if errors.Is(err, service.ErrSubscriptionNotFound) {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "plan is required without a subscription"})
+			return
+		}
+		if err != nil {
+			handleSubscriptionError(c, err)
+			return
+		}
+		request.Plan = plan.Code
+	}
+
+	checkout, err := h.paymentService.CreateCheckout(userInfo.ID, request.Provider, request.Plan, c.ClientIP())
+	if err != nil {
+		handlePaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.CheckoutResponse{
+		Order:    *checkout.Order,
+		PayURL:   checkout.PayURL,
+		Deeplink: checkout.Deeplink,
+		QRCode:   checkout.QRCode,
+	})
+}
+
+// CancelSubscription godoc
+// @Summary Cancel the subscription
+// @Description Stops the subscription of the current user from being renewed. Premium is kept until the end of the paid period; a past due subscription expires at once
+// @Tags subscriptions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.SubscriptionResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /subscriptions/cancel [post]
+func (h *SubscriptionController) CancelSubscription(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	if _, err := h.subscriptionService.Cancel(userInfo.ID); err != nil {
+		handleSubscriptionError(c, err)
+		return
+	}
+
+	h.respond(c, userInfo.ID)
+}
+
+// ResumeSubscription godoc
+// @Summary Resume the subscription
+// @Description Undoes the cancellation of the subscription of the current user while its paid period has not ended
+// @Tags subscriptions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.SubscriptionResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /subscriptions/resume [post]
+func (h *SubscriptionController) ResumeSubscription(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	if _, err := h.subscriptionService.Resume(userInfo.ID); err != nil {
+		handleSubscriptionError(c, err)
+		return
+	}
+
+	h.respond(c, userInfo.ID)
+}
+
+// respond writes the subscription of the user with its plan and quotas
+func (h *SubscriptionController) respond(c *gin.Context, userID uint64) {
+	sub, plan, err := h.subscriptionService.GetSubscription(userID)
+	if errors.Is(err, service.ErrSubscriptionNotFound) {
+		c.JSON(http.StatusOK, response.SubscriptionResponse{Quotas: entity.FreeQuotas})
+		return
+	}
+	if err != nil {
+		handleSubscriptionError(c, err)
+		return
+	}
+
+	result := response.SubscriptionResponse{Premium: sub.Status.IsPremium(), Subscription: sub, Plan: plan, Quotas: sub.Quotas(plan)}
+	if sub.Status == entity.SubscriptionStatusPastDue {
+		graceEnd := h.subscriptionService.GracePeriodEnd(sub)
+		result.GracePeriodEnd = &graceEnd
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func handleSubscriptionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrSubscriptionNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrSubscriptionNotResumable):
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("Subscription request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/pkg/response"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getSubscription gets the subscription of ownerUser
+func getSubscription(t *testing.T, router http.Handler) response.SubscriptionResponse {
+	req, _ := http.NewRequest("GET", "/owner/subscriptions", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var subscription response.SubscriptionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &subscription))
+	return subscription
+}
+
+func TestSubscriptionFlow(t *testing.T) {
+	router := setupPaymentRouter(t)
+
+	free := getSubscription(t, router)
+	assert.False(t, free.Premium)
+	assert.Nil(t, free.Subscription)
+	assert.Equal(t, entity.FreeQuotas, free.Quotas)
+	w := postJSON(router, "/owner/subscriptions/cancel", ``)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = postJSON(router, "/owner/subscriptions/renew", `{"provider":"momo"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "the first order needs a plan")
+
+	// Paying an order of a plan starts the subscription
+	w = postJSON(router, "/owner/subscriptions/renew", `{"provider":"momo","plan":"premium_monthly"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var checkout response.CheckoutResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &checkout))
+	_, ok := router.momo.Pay(checkout.Order.Reference)
+	require.True(t, ok)
+	_, err := router.momo.Notify(checkout.Order.Reference)
+	require.NoError(t, err)
+
+	premium := getSubscription(t, router)
+	assert.True(t, premium.Premium)
+	require.NotNil(t, premium.Subscription)
+	assert.Equal(t, entity.SubscriptionStatusActive, premium.Subscription.Status)
+	assert.Equal(t, "premium_monthly", premium.Plan.Code)
+	assert.Equal(t, premium.Plan.Quotas, premium.Quotas)
+	assert.Nil(t, premium.GracePeriodEnd)
+
+	// Renewing without a plan orders the plan of the subscription
+	w = postJSON(router, "/owner/subscriptions/renew", `{"provider":"vnpay"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &checkout))
+	assert.Equal(t, premium.Plan.ID, checkout.Order.PlanID)
+	assert.Equal(t, entity.OrderStatusPending, checkout.Order.Status)
+
+	w = postJSON(router, "/owner/subscriptions/cancel", ``)
+	require.Equal(t, http.StatusOK, w.Code)
+	var canceled response.SubscriptionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &canceled))
+	assert.Equal(t, entity.SubscriptionStatusCanceled, canceled.Subscription.Status)
+	assert.True(t, canceled.Premium, "premium is kept until the period ends")
+
+	w = postJSON(router, "/owner/subscriptions/resume", ``)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resumed response.SubscriptionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resumed))
+	assert.Equal(t, entity.SubscriptionStatusActive, resumed.Subscription.Status)
+
+	req, _ := http.NewRequest("GET", "/owner/subscriptions/periods", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var periods response.SubscriptionPeriodsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &periods))
+	require.Len(t, periods.Periods, 1)
+	assert.Equal(t, premium.Subscription.CurrentPeriodEnd, periods.Periods[0].PeriodEnd)
+
+	other := httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/other/subscriptions/periods", nil)
+	router.ServeHTTP(other, req)
+	assert.Equal(t, http.StatusNotFound, other.Code, "subscriptions belong to the current user")
+}
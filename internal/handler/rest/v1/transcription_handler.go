@@ -3,6 +3,7 @@ package handler
 import (
 	"mlvt/internal/entity"
 	"mlvt/internal/infra/env"
+	"mlvt/internal/pkg/middleware"
 	"mlvt/internal/pkg/response"
 	"mlvt/internal/service"
 	"net/http"
@@ -13,25 +14,44 @@ import (
 
 type TranscriptionController struct {
 	transcriptionService service.TranscriptionService
+	organizationService  service.OrganizationService
+	ownershipService     service.OwnershipService
 }
 
-func NewTranscriptionController(transcriptionService service.TranscriptionService) *TranscriptionController {
-	return &TranscriptionController{transcriptionService: transcriptionService}
+func NewTranscriptionController(transcriptionService service.TranscriptionService, organizationService service.OrganizationService,
+	ownershipService service.OwnershipService) *TranscriptionController {
+	return &TranscriptionController{
+		transcriptionService: transcriptionService,
+		organizationService:  organizationService,
+		ownershipService:     ownershipService,
+	}
 }
 
 // GenerateUploadURL godoc
 // @Summary Generate presigned upload URL
-// @Description Generates a presigned URL to upload a transcription file to the storage service.
+// @Description Generates a presigned URL to upload a transcription file to the storage service. The file is stored under the prefix of the current user; add the transcription with the returned file_name.
 // @Tags transcriptions
 // @Produce json
 // @Param file_name query string true "Name of the file to be uploaded"
 // @Param file_type query string true "MIME type of the file (e.g., application/json)"
-// @Success 200 {object} response.UploadURLResponse "upload_url"
+// @Success 200 {object} response.UploadURLResponse "upload_url, file_name"
+// @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 401 {object} response.ErrorResponse "error"
 // @Failure 500 {object} response.ErrorResponse "error"
 // @Router /transcriptions/generate-upload-url [post]
 func (h *TranscriptionController) GenerateUploadURL(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
 	folder := env.EnvConfig.TranscriptionsFolder
-	fileName := c.Query("file_name")
+	fileName, ok := userFileName(userInfo.ID, c.Query("file_name"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid file_name"})
+		return
+	}
 	fileType := c.Query("file_type")
 
 	url, err := h.transcriptionService.GeneratePresignedUploadURL(folder, fileName, fileType)
@@ -40,7 +60,7 @@ func (h *TranscriptionController) GenerateUploadURL(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, response.UploadURLResponse{UploadURL: url})
+	c.JSON(http.StatusOK, response.UploadURLResponse{UploadURL: url, FileName: fileName})
 }
 
 // GenerateDownloadURL godoc
@@ -48,14 +68,14 @@ func (h *TranscriptionController) GenerateUploadURL(c *gin.Context) {
 // @Description Generates a presigned URL to download a transcription file from the storage service.
 // @Tags transcriptions
 // @Produce json
-// @Param transcription_id path uint64 true "ID of the transcription file"
+// @Param transcriptionID path uint64 true "ID of the transcription file"
 // @Success 200 {object} response.DownloadURLResponse "download_url"
 // @Failure 400 {object} response.ErrorResponse "error"
 // @Failure 500 {object} response.ErrorResponse "error"
-// @Router /transcriptions/{transcription_id}/download-url [get]
+// @Router /transcriptions/{transcriptionID}/download-url [get]
 func (h *TranscriptionController) GenerateDownloadURL(c *gin.Context) {
 	// Parse transcription ID from the URL path
-	transcriptionIDStr := c.Param("transcription_id")
+	transcriptionIDStr := c.Param("transcriptionID")
 	transcriptionID, err := strconv.ParseUint(transcriptionIDStr, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid transcription ID"})
@@ -75,28 +95,88 @@ func (h *TranscriptionController) GenerateDownloadURL(c *gin.Context) {
 
 // AddTranscription godoc
 // @Summary Add transcription
-// @Description Adds a new transcription file's metadata to the system.
+// @Description Adds a new transcription file's metadata to the system in the pending_upload state. Call the finalize endpoint once the file is uploaded. The file is looked up in the transcriptions folder under the prefix of the current user, who must be allowed to change the video.
 // @Tags transcriptions
 // @Accept json
 // @Produce json
 // @Param transcription body entity.Transcription true "Transcription object"
-// @Success 201 {object} response.MessageResponse "message"
+// @Success 201 {object} response.CreatedResponse "message, id"
 // @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 401 {object} response.ErrorResponse "error"
+// @Failure 403 {object} response.ErrorResponse "error"
+// @Failure 404 {object} response.ErrorResponse "error"
 // @Failure 500 {object} response.ErrorResponse "error"
 // @Router /transcriptions [post]
 func (h *TranscriptionController) AddTranscription(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
 	var transcription entity.Transcription
 	if err := c.ShouldBindJSON(&transcription); err != nil {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	// The owner defaults to the current user; only admins may add a transcription for another user
+	if transcription.UserID == 0 {
+		transcription.UserID = userInfo.ID
+	}
+	if !middleware.CanAccess(userInfo, transcription.UserID) {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "cannot add a transcription for another user"})
+		return
+	}
+	if !canAddToOrg(c, h.organizationService, userInfo, transcription.OrgID) {
+		return
+	}
+	if !canWriteVideo(c, h.ownershipService, userInfo, transcription.VideoID) {
+		return
+	}
+
+	// The file is the one the current user uploaded; the key is never taken from the client
+	transcription.Folder = env.EnvConfig.TranscriptionsFolder
+	if transcription.FileName, ok = userFileName(userInfo.ID, transcription.FileName); !ok {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid file_name"})
+		return
+	}
+
 	if err := h.transcriptionService.CreateTranscription(&transcription); err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, response.MessageResponse{Message: "Transcription added successfully"})
+	c.JSON(http.StatusCreated, response.CreatedResponse{Message: "Transcription added successfully", ID: transcription.ID})
+}
+
+// FinalizeTranscriptionUpload godoc
+// @Summary Confirm the upload of a transcription file
+// @Description Checks that the transcription file exists in storage and matches the declared content type, records its size and ETag, and marks the transcription ready
+// @Tags transcriptions
+// @Produce json
+// @Param transcriptionID path uint64 true "ID of the transcription"
+// @Success 200 {object} response.TranscriptionResponse "transcription"
+// @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 404 {object} response.ErrorResponse "error"
+// @Failure 409 {object} response.ErrorResponse "file has not been uploaded"
+// @Failure 422 {object} response.ErrorResponse "file type mismatch"
+// @Failure 500 {object} response.ErrorResponse "error"
+// @Router /transcriptions/{transcriptionID}/finalize [post]
+func (h *TranscriptionController) FinalizeTranscriptionUpload(c *gin.Context) {
+	transcriptionID, err := strconv.ParseUint(c.Param("transcriptionID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid transcription ID"})
+		return
+	}
+
+	transcription, err := h.transcriptionService.FinalizeTranscriptionUpload(transcriptionID)
+	if err != nil {
+		handleFinalizeError(c, err, "transcription not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TranscriptionResponse{Transcription: *transcription})
 }
 
 // GetTranscriptionByID godoc
@@ -104,12 +184,12 @@ func (h *TranscriptionController) AddTranscription(c *gin.Context) {
 // @Description Retrieves a transcription and generates a presigned download URL for it.
 // @Tags transcriptions
 // @Produce json
-// @Param transcription_id path uint64 true "ID of the transcription file"
+// @Param transcriptionID path uint64 true "ID of the transcription file"
 // @Success 200 {object} response.TranscriptionResponse "transcription, download_url"
 // @Failure 404 {object} response.ErrorResponse "error"
-// @Router /transcriptions/{transcription_id} [get]
+// @Router /transcriptions/{transcriptionID} [get]
 func (h *TranscriptionController) GetTranscriptionByID(c *gin.Context) {
-	transcriptionIDStr := c.Param("transcription_id")
+	transcriptionIDStr := c.Param("transcriptionID")
 	transcriptionID, err := strconv.ParseUint(transcriptionIDStr, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid transcription ID"})
@@ -231,6 +311,33 @@ func (h *TranscriptionController) ListTranscriptionsByUserID(c *gin.Context) {
 	c.JSON(http.StatusOK, response.TranscriptionsResponse{Transcriptions: transcriptions})
 }
 
+// ListTranscriptionsByOrgID godoc
+// @Summary List transcriptions by organization ID
+// @Description Retrieves all transcriptions shared with an organization. Needs membership of the organization.
+// @Tags transcriptions
+// @Produce json
+// @Param org_id path uint64 true "ID of the organization"
+// @Success 200 {object} response.TranscriptionsResponse "transcriptions"
+// @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 403 {object} response.ErrorResponse "error"
+// @Failure 500 {object} response.ErrorResponse "error"
+// @Router /transcriptions/org/{org_id} [get]
+func (h *TranscriptionController) ListTranscriptionsByOrgID(c *gin.Context) {
+	orgID, err := strconv.ParseUint(c.Param("org_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid organization ID"})
+		return
+	}
+
+	transcriptions, err := h.transcriptionService.ListTranscriptionsByOrgID(orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TranscriptionsResponse{Transcriptions: transcriptions})
+}
+
 // ListTranscriptionsByVideoID godoc
 // @Summary List transcriptions by Video ID
 // @Description Retrieves all transcriptions belonging to a specific video.
@@ -262,12 +369,12 @@ func (h *TranscriptionController) ListTranscriptionsByVideoID(c *gin.Context) {
 // @Summary Delete transcription by ID
 // @Description Deletes a transcription record from the system.
 // @Tags transcriptions
-// @Param transcription_id path uint64 true "ID of the transcription file"
+// @Param transcriptionID path uint64 true "ID of the transcription file"
 // @Success 200 {object} response.MessageResponse "message"
 // @Failure 500 {object} response.ErrorResponse "error"
-// @Router /transcriptions/{transcription_id} [delete]
+// @Router /transcriptions/{transcriptionID} [delete]
 func (h *TranscriptionController) DeleteTranscription(c *gin.Context) {
-	transcriptionIDStr := c.Param("transcription_id")
+	transcriptionIDStr := c.Param("transcriptionID")
 	transcriptionID, err := strconv.ParseUint(transcriptionIDStr, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid transcription ID"})
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListRevisions godoc
+// @Summary List the revisions of a transcription
+// @Description Returns the edit history of the transcription, newest first. Each revision holds its author, time and changed segments
+// @Tags transcriptions
+// @Produce json
+// @Security BearerAuth
+// @Param transcriptionID path uint64 true "ID of the transcription"
+// @Success 200 {object} response.TranscriptionRevisionsResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /transcriptions/{transcriptionID}/revisions [get]
+func (h *TranscriptionController) ListRevisions(c *gin.Context) {
+	transcriptionID, ok := transcriptionIDParam(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.transcriptionService.ListRevisions(transcriptionID)
+	if err != nil {
+		handleRevisionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TranscriptionRevisionsResponse{Revisions: revisions})
+}
+
+// GetRevision godoc
+// @Summary Get a transcription revision
+// @Description Returns the revision with all segments of the transcription as they were after it
+// @Tags transcriptions
+// @Produce json
+// @Security BearerAuth
+// @Param transcriptionID path uint64 true "ID of the transcription"
+// @Param revision path int true "Number of the revision"
+// @Success 200 {object} response.TranscriptionRevisionResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /transcriptions/{transcriptionID}/revisions/{revision} [get]
+func (h *TranscriptionController) GetRevision(c *gin.Context) {
+	transcriptionID, number, ok := revisionParams(c)
+	if !ok {
+		return
+	}
+
+	revision, err := h.transcriptionService.GetRevision(transcriptionID, number)
+	if err != nil {
+		handleRevisionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TranscriptionRevisionResponse{Revision: *revision})
+}
+
+// DiffRevisions godoc
+// @Summary Compare two transcription revisions
+// @Description Lists the segments added, removed or modified between the two revisions, ordered by segment ID
+// @Tags transcriptions
+// @Produce json
+// @Security BearerAuth
+// @Param transcriptionID path uint64 true "ID of the transcription"
+// @Param from query int true "Number of the older revision"
+// @Param to query int true "Number of the newer revision"
+// @Success 200 {object} response.TranscriptionDiffResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /transcriptions/{transcriptionID}/revisions/diff [get]
+func (h *TranscriptionController) DiffRevisions(c *gin.Context) {
+	transcriptionID, ok := transcriptionIDParam(c)
+	if !ok {
+		return
+	}
+
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil || from < 1 {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid from revision"})
+		return
+	}
+	to, err := strconv.Atoi(c.Query("to"))
+	if err != nil || to < 1 {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid to revision"})
+		return
+	}
+
+	changes, err := h.transcriptionService.DiffRevisions(transcriptionID, from, to)
+	if err != nil {
+		handleRevisionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TranscriptionDiffResponse{From: from, To: to, Changes: changes})
+}
+
+// RestoreRevision godoc
+// @Summary Restore a transcription revision
+// @Description Puts the segments of the transcription back as they were after the revision. The restore is recorded as a new revision
+// @Tags transcriptions
+// @Produce json
+// @Security BearerAuth
+// @Param transcriptionID path uint64 true "ID of the transcription"
+// @Param revision path int true "Number of the revision to restore"
+// @Success 200 {object} response.TranscriptionRevisionResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /transcriptions/{transcriptionID}/revisions/{revision}/restore [post]
+func (h *TranscriptionController) RestoreRevision(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	transcriptionID, number, ok := revisionParams(c)
+	if !ok {
+		return
+	}
+
+	revision, err := h.transcriptionService.RestoreRevision(userInfo.ID, transcriptionID, number)
+	if err != nil {
+		handleRevisionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TranscriptionRevisionResponse{Revision: *revision})
+}
+
+// revisionParams parses the transcription ID and revision number of the path, writing the error response when either is invalid
+func revisionParams(c *gin.Context) (uint64, int, bool) {
+	transcriptionID, ok := transcriptionIDParam(c)
+	if !ok {
+		return 0, 0, false
+	}
+	number, err := strconv.Atoi(c.Param("revision"))
+	if err != nil || number < 1 {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid revision number"})
+		return 0, 0, false
+	}
+	return transcriptionID, number, true
+}
+
+// handleRevisionError maps transcription revision errors to HTTP responses
+func handleRevisionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTranscriptionNotFound), errors.Is(err, service.ErrRevisionNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("Transcription revision request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTranscriptionRevisionRouter(controller *TranscriptionController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	transcriptions := router.Group("/transcriptions")
+	transcriptions.Use(middleware.NewMockAuthMiddleware().MustAuthAuthenticated())
+	transcriptions.GET("/:transcriptionID/revisions", controller.ListRevisions)
+	transcriptions.GET("/:transcriptionID/revisions/diff", controller.DiffRevisions)
+	transcriptions.GET("/:transcriptionID/revisions/:revision", controller.GetRevision)
+	transcriptions.POST("/:transcriptionID/revisions/:revision/restore", controller.RestoreRevision)
+
+	return router
+}
+
+func TestListRevisions(t *testing.T) {
+	mockService := new(service.MockTranscriptionService)
+	router := setupTranscriptionRevisionRouter(NewTranscriptionController(mockService, nil, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		revisions := []entity.TranscriptionRevision{
+			{ID: 2, TranscriptionID: 4, Number: 2, UserID: 1, Action: entity.RevisionActionUpdateSegment},
+			{ID: 1, TranscriptionID: 4, Number: 1, UserID: 1, Action: entity.RevisionActionAddSegments},
+		}
+		mockService.On("ListRevisions", uint64(4)).Return(revisions, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/4/revisions", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.TranscriptionRevisionsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, resp.Revisions, 2)
+		assert.Equal(t, entity.RevisionActionUpdateSegment, resp.Revisions[0].Action)
+	})
+
+	t.Run("Transcription Not Found", func(t *testing.T) {
+		mockService.On("ListRevisions", uint64(5)).Return(nil, service.ErrTranscriptionNotFound).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/5/revisions", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetRevision(t *testing.T) {
+	mockService := new(service.MockTranscriptionService)
+	router := setupTranscriptionRevisionRouter(NewTranscriptionController(mockService, nil, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		revision := &entity.TranscriptionRevision{ID: 1, TranscriptionID: 4, Number: 1, UserID: 1, Action: entity.RevisionActionImport,
+			Segments: []entity.TranscriptionSegment{{ID: 1, TranscriptionID: 4, StartMs: 0, EndMs: 1000, Text: "Hello"}}}
+		mockService.On("GetRevision", uint64(4), 1).Return(revision, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/4/revisions/1", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.TranscriptionRevisionResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, resp.Revision.Segments, 1)
+	})
+
+	t.Run("Revision Not Found", func(t *testing.T) {
+		mockService.On("GetRevision", uint64(4), 9).Return(nil, service.ErrRevisionNotFound).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/4/revisions/9", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	for _, path := range []string{"/transcriptions/abc/revisions/1", "/transcriptions/4/revisions/0", "/transcriptions/4/revisions/x"} {
+		t.Run("Invalid Path "+path, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestDiffRevisions(t *testing.T) {
+	mockService := new(service.MockTranscriptionService)
+	router := setupTranscriptionRevisionRouter(NewTranscriptionController(mockService, nil, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		changes := []entity.SegmentChange{{
+			SegmentID: 2,
+			Type:      entity.SegmentModified,
+			Before:    &entity.TranscriptionSegment{ID: 2, StartMs: 1000, EndMs: 2000, Text: "world"},
+			After:     &entity.TranscriptionSegment{ID: 2, StartMs: 1000, EndMs: 2000, Text: "there"},
+		}}
+		mockService.On("DiffRevisions", uint64(4), 1, 3).Return(changes, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/4/revisions/diff?from=1&to=3", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.TranscriptionDiffResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 1, resp.From)
+		assert.Equal(t, 3, resp.To)
+		assert.Len(t, resp.Changes, 1)
+		assert.Equal(t, "there", resp.Changes[0].After.Text)
+	})
+
+	t.Run("Internal Error", func(t *testing.T) {
+		mockService.On("DiffRevisions", uint64(4), 1, 2).Return(nil, errors.New("database error")).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/4/revisions/diff?from=1&to=2", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	for _, query := range []string{"", "?from=1", "?from=0&to=2", "?from=1&to=b"} {
+		t.Run("Invalid Query "+query, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/transcriptions/4/revisions/diff"+query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRestoreRevision(t *testing.T) {
+	mockService := new(service.MockTranscriptionService)
+	router := setupTranscriptionRevisionRouter(NewTranscriptionController(mockService, nil, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		revision := &entity.TranscriptionRevision{ID: 4, TranscriptionID: 4, Number: 4, UserID: 1, Action: entity.RevisionActionRestore, RestoredFrom: 1}
+		mockService.On("RestoreRevision", uint64(1), uint64(4), 1).Return(revision, nil).Once()
+
+		req, _ := http.NewRequest("POST", "/transcriptions/4/revisions/1/restore", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.TranscriptionRevisionResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 1, resp.Revision.RestoredFrom)
+	})
+
+	t.Run("Revision Not Found", func(t *testing.T) {
+		mockService.On("RestoreRevision", uint64(1), uint64(4), 7).Return(nil, service.ErrRevisionNotFound).Once()
+
+		req, _ := http.NewRequest("POST", "/transcriptions/4/revisions/7/restore", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TranscriptionSegmentRequest represents one time-coded segment to add to a transcription
+type TranscriptionSegmentRequest struct {
+	StartMs    int64   `json:"start_ms"`
+	EndMs      int64   `json:"end_ms"`
+	Text       string  `json:"text" binding:"required"`
+	Speaker    string  `json:"speaker"`
+	Confidence float64 `json:"confidence"` // Between 0 and 1, 0 when unknown
+}
+
+// AddSegmentsRequest represents the request body for adding segments to a transcription
+type AddSegmentsRequest struct {
+	Segments []TranscriptionSegmentRequest `json:"segments" binding:"required,min=1,dive"`
+}
+
+// UpdateSegmentRequest represents the request body for patching a segment; omitted fields are left unchanged
+type UpdateSegmentRequest struct {
+	StartMs    *int64   `json:"start_ms"`
+	EndMs      *int64   `json:"end_ms"`
+	Text       *string  `json:"text"`
+	Speaker    *string  `json:"speaker"`
+	Confidence *float64 `json:"confidence"`
+}
+
+// AddSegments godoc
+// @Summary Add segments to a transcription
+// @Description Stores time-coded segments of the transcription in one batch and records the edit as a revision. The text of the transcription is derived from all of its segments in playback order
+// @Tags transcriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param transcriptionID path uint64 true "ID of the transcription"
+// @Param request body AddSegmentsRequest true "Segments"
+// @Success 201 {object} response.TranscriptionSegmentsResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /transcriptions/{transcriptionID}/segments [post]
+func (h *TranscriptionController) AddSegments(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	transcriptionID, ok := transcriptionIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req AddSegmentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	segments := make([]entity.TranscriptionSegment, len(req.Segments))
+	for i, segment := range req.Segments {
+		segments[i] = entity.TranscriptionSegment{
+			StartMs:    segment.StartMs,
+			EndMs:      segment.EndMs,
+			Text:       segment.Text,
+			Speaker:    segment.Speaker,
+			Confidence: segment.Confidence,
+		}
+	}
+
+	created, err := h.transcriptionService.AddSegments(userInfo.ID, transcriptionID, segments)
+	if err != nil {
+		handleSegmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.TranscriptionSegmentsResponse{Segments: created})
+}
+
+// ListSegments godoc
+// @Summary List the segments of a transcription
+// @Description Returns one page of the segments of the transcription in playback order
+// @Tags transcriptions
+// @Produce json
+// @Security BearerAuth
+// @Param transcriptionID path uint64 true "ID of the transcription"
+// @Param page query int false "Page number, starting at 1" default(1)
+// @Param page_size query int false "Segments per page, at most 500" default(50)
+// @Success 200 {object} response.TranscriptionSegmentPageResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /transcriptions/{transcriptionID}/segments [get]
+func (h *TranscriptionController) ListSegments(c *gin.Context) {
+	transcriptionID, ok := transcriptionIDParam(c)
+	if !ok {
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid page"})
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(service.DefaultSegmentPageSize)))
+	if err != nil || pageSize < 1 || pageSize > service.MaxSegmentPageSize {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid page size"})
+		return
+	}
+
+	segments, total, err := h.transcriptionService.ListSegmentsPage(transcriptionID, page, pageSize)
+	if err != nil {
+		handleSegmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TranscriptionSegmentPageResponse{Segments: segments, Page: page, PageSize: pageSize, Total: total})
+}
+
+// GetSegment godoc
+// @Summary Get a transcription segment
+// @Tags transcriptions
+// @Produce json
+// @Security BearerAuth
+// @Param transcriptionID path uint64 true "ID of the transcription"
+// @Param segmentID path uint64 true "ID of the segment"
+// @Success 200 {object} response.TranscriptionSegmentResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /transcriptions/{transcriptionID}/segments/{segmentID} [get]
+func (h *TranscriptionController) GetSegment(c *gin.Context) {
+	transcriptionID, segmentID, ok := segmentIDParams(c)
+	if !ok {
+		return
+	}
+
+	segment, err := h.transcriptionService.GetSegment(transcriptionID, segmentID)
+	if err != nil {
+		handleSegmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TranscriptionSegmentResponse{Segment: *segment})
+}
+
+// UpdateSegment godoc
+// @Summary Update a transcription segment
+// @Description Changes the timing, text, speaker or confidence of a segment. Omitted fields are left unchanged; the text of the transcription is derived again from its segments and the edit is recorded as a revision
+// @Tags transcriptions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param transcriptionID path uint64 true "ID of the transcription"
+// @Param segmentID path uint64 true "ID of the segment"
+// @Param request body UpdateSegmentRequest true "Fields to change"
+// @Success 200 {object} response.TranscriptionSegmentResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /transcriptions/{transcriptionID}/segments/{segmentID} [patch]
+func (h *TranscriptionController) UpdateSegment(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	transcriptionID, segmentID, ok := segmentIDParams(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateSegmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	segment, err := h.transcriptionService.UpdateSegment(userInfo.ID, transcriptionID, segmentID, service.TranscriptionSegmentPatch{
+		StartMs:    req.StartMs,
+		EndMs:      req.EndMs,
+		Text:       req.Text,
+		Speaker:    req.Speaker,
+		Confidence: req.Confidence,
+	})
+	if err != nil {
+		handleSegmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TranscriptionSegmentResponse{Segment: *segment})
+}
+
+// transcriptionIDParam parses the transcription ID of the path, writing the error response when it is invalid
+func transcriptionIDParam(c *gin.Context) (uint64, bool) {
+	transcriptionID, err := strconv.ParseUint(c.Param("transcriptionID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid transcription ID"})
+		return 0, false
+	}
+	return transcriptionID, true
+}
+
+// segmentIDParams parses the transcription and segment IDs of the path, writing the error response when either is invalid
+func segmentIDParams(c *gin.Context) (uint64, uint64, bool) {
+	transcriptionID, ok := transcriptionIDParam(c)
+	if !ok {
+		return 0, 0, false
+	}
+	segmentID, err := strconv.ParseUint(c.Param("segmentID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid segment ID"})
+		return 0, 0, false
+	}
+	return transcriptionID, segmentID, true
+}
+
+// handleSegmentError maps transcription segment errors to HTTP responses
+func handleSegmentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTranscriptionNotFound), errors.Is(err, service.ErrSegmentNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidSegment):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("Transcription segment request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
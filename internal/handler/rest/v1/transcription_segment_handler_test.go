@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTranscriptionSegmentRouter(controller *TranscriptionController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	transcriptions := router.Group("/transcriptions")
+	transcriptions.Use(middleware.NewMockAuthMiddleware().MustAuthAuthenticated())
+	transcriptions.POST("/:transcriptionID/segments", controller.AddSegments)
+	transcriptions.GET("/:transcriptionID/segments", controller.ListSegments)
+	transcriptions.GET("/:transcriptionID/segments/:segmentID", controller.GetSegment)
+	transcriptions.PATCH("/:transcriptionID/segments/:segmentID", controller.UpdateSegment)
+
+	return router
+}
+
+func TestAddSegments(t *testing.T) {
+	mockService := new(service.MockTranscriptionService)
+	router := setupTranscriptionSegmentRouter(NewTranscriptionController(mockService, nil, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		segments := []entity.TranscriptionSegment{
+			{StartMs: 0, EndMs: 1500, Text: "Hello", Speaker: "SPEAKER_1", Confidence: 0.9},
+			{StartMs: 1500, EndMs: 3000, Text: "world"},
+		}
+		created := []entity.TranscriptionSegment{
+			{ID: 1, TranscriptionID: 4, StartMs: 0, EndMs: 1500, Text: "Hello", Speaker: "SPEAKER_1", Confidence: 0.9},
+			{ID: 2, TranscriptionID: 4, StartMs: 1500, EndMs: 3000, Text: "world"},
+		}
+		mockService.On("AddSegments", uint64(1), uint64(4), segments).Return(created, nil).Once()
+
+		body := `{"segments":[{"start_ms":0,"end_ms":1500,"text":"Hello","speaker":"SPEAKER_1","confidence":0.9},{"start_ms":1500,"end_ms":3000,"text":"world"}]}`
+		req, _ := http.NewRequest("POST", "/transcriptions/4/segments", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.TranscriptionSegmentsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Len(t, resp.Segments, 2)
+		assert.Equal(t, uint64(2), resp.Segments[1].ID)
+	})
+
+	tests := []struct {
+		name string
+		text string
+		err  error
+		code int
+	}{
+		{"Invalid Segment", "bad timing", fmt.Errorf("segment 0: %w: end_ms must be after start_ms", service.ErrInvalidSegment), http.StatusBadRequest},
+		{"Transcription Not Found", "missing", service.ErrTranscriptionNotFound, http.StatusNotFound},
+		{"Internal Error", "broken", errors.New("database error"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments := []entity.TranscriptionSegment{{StartMs: 1000, EndMs: 500, Text: tt.text}}
+			mockService.On("AddSegments", uint64(1), uint64(4), segments).Return(nil, tt.err).Once()
+
+			body := fmt.Sprintf(`{"segments":[{"start_ms":1000,"end_ms":500,"text":%q}]}`, tt.text)
+			req, _ := http.NewRequest("POST", "/transcriptions/4/segments", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+
+	t.Run("No Segments", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/transcriptions/4/segments", bytes.NewBufferString(`{"segments":[]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestListSegments(t *testing.T) {
+	mockService := new(service.MockTranscriptionService)
+	router := setupTranscriptionSegmentRouter(NewTranscriptionController(mockService, nil, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		segments := []entity.TranscriptionSegment{{ID: 3, TranscriptionID: 4, StartMs: 2000, EndMs: 3000, Text: "third"}}
+		mockService.On("ListSegmentsPage", uint64(4), 2, 2).Return(segments, 5, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/4/segments?page=2&page_size=2", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.TranscriptionSegmentPageResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 5, resp.Total)
+		assert.Equal(t, 2, resp.Page)
+		assert.Equal(t, 2, resp.PageSize)
+		assert.Len(t, resp.Segments, 1)
+	})
+
+	t.Run("Default Page", func(t *testing.T) {
+		mockService.On("ListSegmentsPage", uint64(5), 1, service.DefaultSegmentPageSize).Return([]entity.TranscriptionSegment{}, 0, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/5/segments", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Transcription Not Found", func(t *testing.T) {
+		mockService.On("ListSegmentsPage", uint64(6), 1, service.DefaultSegmentPageSize).Return(nil, 0, service.ErrTranscriptionNotFound).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/6/segments", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	for _, query := range []string{"page=0", "page=abc", "page_size=0", fmt.Sprintf("page_size=%d", service.MaxSegmentPageSize+1)} {
+		t.Run("Invalid Query "+query, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/transcriptions/4/segments?"+query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetSegment(t *testing.T) {
+	mockService := new(service.MockTranscriptionService)
+	router := setupTranscriptionSegmentRouter(NewTranscriptionController(mockService, nil, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		segment := &entity.TranscriptionSegment{ID: 2, TranscriptionID: 4, StartMs: 1500, EndMs: 3000, Text: "world"}
+		mockService.On("GetSegment", uint64(4), uint64(2)).Return(segment, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/4/segments/2", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.TranscriptionSegmentResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "world", resp.Segment.Text)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		mockService.On("GetSegment", uint64(4), uint64(9)).Return(nil, service.ErrSegmentNotFound).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/4/segments/9", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Invalid Segment ID", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/transcriptions/4/segments/abc", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateSegment(t *testing.T) {
+	mockService := new(service.MockTranscriptionService)
+	router := setupTranscriptionSegmentRouter(NewTranscriptionController(mockService, nil, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		text := "Hello there"
+		endMs := int64(1800)
+		patch := service.TranscriptionSegmentPatch{Text: &text, EndMs: &endMs}
+		segment := &entity.TranscriptionSegment{ID: 1, TranscriptionID: 4, StartMs: 0, EndMs: 1800, Text: text}
+		mockService.On("UpdateSegment", uint64(1), uint64(4), uint64(1), patch).Return(segment, nil).Once()
+
+		req, _ := http.NewRequest("PATCH", "/transcriptions/4/segments/1", bytes.NewBufferString(`{"text":"Hello there","end_ms":1800}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.TranscriptionSegmentResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(1800), resp.Segment.EndMs)
+	})
+
+	t.Run("Invalid Segment", func(t *testing.T) {
+		confidence := 1.5
+		patch := service.TranscriptionSegmentPatch{Confidence: &confidence}
+		mockService.On("UpdateSegment", uint64(1), uint64(4), uint64(1), patch).
+			Return(nil, fmt.Errorf("%w: confidence must be between 0 and 1", service.ErrInvalidSegment)).Once()
+
+		req, _ := http.NewRequest("PATCH", "/transcriptions/4/segments/1", bytes.NewBufferString(`{"confidence":1.5}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		req, _ := http.NewRequest("PATCH", "/transcriptions/4/segments/1", bytes.NewBufferString(`{"text":`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/pkg/subtitle"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSubtitleFileSize is the largest subtitle file accepted by the import endpoint
+const maxSubtitleFileSize = 10 << 20
+
+// ExportTranscription godoc
+// @Summary Export a transcription as subtitles
+// @Description Writes the segments of the transcription as an SRT, WebVTT or TTML file
+// @Tags transcriptions
+// @Produce application/x-subrip,text/vtt,application/ttml+xml
+// @Security BearerAuth
+// @Param transcriptionID path uint64 true "ID of the transcription"
+// @Param format query string true "Subtitle format" Enums(srt, vtt, ttml)
+// @Success 200 {file} file "subtitle file"
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "transcription has no segments"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /transcriptions/{transcriptionID}/export [get]
+func (h *TranscriptionController) ExportTranscription(c *gin.Context) {
+	transcriptionID, ok := transcriptionIDParam(c)
+	if !ok {
+		return
+	}
+
+	format, err := subtitle.ParseFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	transcription, data, err := h.transcriptionService.ExportTranscription(transcriptionID, format)
+	if err != nil {
+		handleSubtitleError(c, err)
+		return
+	}
+
+	fileName := fmt.Sprintf("transcription_%d", transcription.ID)
+	if transcription.Lang != "" {
+		fileName += "." + transcription.Lang
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName+format.Extension()))
+	c.Data(http.StatusOK, format.ContentType()+"; charset=utf-8", data)
+}
+
+// ImportTranscription godoc
+// @Summary Import a subtitle file as a transcription
+// @Description Creates a ready transcription of the video from an SRT, WebVTT or TTML file, with one segment per cue. The format defaults to the extension of the file name. The current user must be allowed to change the video
+// @Tags transcriptions
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "Subtitle file, at most 10 MiB"
+// @Param video_id formData uint64 true "ID of the video"
+// @Param lang formData string true "Language of the subtitles"
+// @Param format formData string false "Subtitle format" Enums(srt, vtt, ttml)
+// @Success 201 {object} response.ImportedTranscriptionResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 413 {object} response.ErrorResponse
+// @Failure 422 {object} response.ErrorResponse "malformed subtitle file"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /transcriptions/import [post]
+func (h *TranscriptionController) ImportTranscription(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	videoID, err := strconv.ParseUint(c.PostForm("video_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid video ID"})
+		return
+	}
+	lang := c.PostForm("lang")
+	if lang == "" {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "lang is required"})
+		return
+	}
+	if !canWriteVideo(c, h.ownershipService, userInfo, videoID) {
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "file is required"})
+		return
+	}
+	if fileHeader.Size > maxSubtitleFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, response.ErrorResponse{Error: "subtitle file is too large"})
+		return
+	}
+
+	var format subtitle.Format
+	if name := c.PostForm("format"); name != "" {
+		format, err = subtitle.ParseFormat(name)
+	} else {
+		format, err = subtitle.FormatFromFileName(fileHeader.Filename)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Errorf("Failed to open uploaded subtitle file: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		log.Errorf("Failed to read uploaded subtitle file: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+		return
+	}
+
+	transcription, segments, err := h.transcriptionService.ImportTranscription(userInfo.ID, videoID, lang, format, data)
+	if err != nil {
+		handleSubtitleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.ImportedTranscriptionResponse{Transcription: *transcription, SegmentCount: len(segments)})
+}
+
+// handleSubtitleError maps errors of the subtitle import and export to HTTP responses
+func handleSubtitleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTranscriptionNotFound), errors.Is(err, service.ErrVideoNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrNoSegments):
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, subtitle.ErrUnsupportedFormat):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, subtitle.ErrMalformed), errors.Is(err, service.ErrInvalidSegment):
+		c.JSON(http.StatusUnprocessableEntity, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("Subtitle request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/pkg/subtitle"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTranscriptionSubtitleRouter(controller *TranscriptionController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	transcriptions := router.Group("/transcriptions")
+	transcriptions.Use(middleware.NewMockAuthMiddleware().MustAuthAuthenticated())
+	transcriptions.GET("/:transcriptionID/export", controller.ExportTranscription)
+	transcriptions.POST("/import", controller.ImportTranscription)
+
+	return router
+}
+
+// newImportRequest builds a multipart import request; an empty fileName leaves out the file
+func newImportRequest(t *testing.T, fields map[string]string, fileName string, content []byte) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		require.NoError(t, writer.WriteField(name, value))
+	}
+	if fileName != "" {
+		part, err := writer.CreateFormFile("file", fileName)
+		require.NoError(t, err)
+		_, err = part.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	req, _ := http.NewRequest("POST", "/transcriptions/import", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestExportTranscription(t *testing.T) {
+	mockService := new(service.MockTranscriptionService)
+	router := setupTranscriptionSubtitleRouter(NewTranscriptionController(mockService, nil, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		data := []byte("WEBVTT\n\n1\n00:00:00.000 --> 00:00:01.000\nHello\n\n")
+		mockService.On("ExportTranscription", uint64(4), subtitle.FormatVTT).Return(&entity.Transcription{ID: 4, Lang: "en"}, data, nil).Once()
+
+		req, _ := http.NewRequest("GET", "/transcriptions/4/export?format=vtt", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/vtt; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="transcription_4.en.vtt"`, w.Header().Get("Content-Disposition"))
+		assert.Equal(t, data, w.Body.Bytes())
+	})
+
+	tests := []struct {
+		name string
+		id   uint64
+		err  error
+		code int
+	}{
+		{"Transcription Not Found", 5, service.ErrTranscriptionNotFound, http.StatusNotFound},
+		{"No Segments", 6, service.ErrNoSegments, http.StatusConflict},
+		{"Internal Error", 7, errors.New("database error"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService.On("ExportTranscription", tt.id, subtitle.FormatSRT).Return(nil, nil, tt.err).Once()
+
+			req, _ := http.NewRequest("GET", "/transcriptions/"+strconv.FormatUint(tt.id, 10)+"/export?format=srt", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+
+	for _, query := range []string{"", "?format=docx"} {
+		t.Run("Unsupported Format "+query, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/transcriptions/4/export"+query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestImportTranscription(t *testing.T) {
+	mockService := new(service.MockTranscriptionService)
+	owners := new(service.MockOwnershipService)
+	owners.On("VideoOwner", uint64(7)).Return(&service.ResourceOwner{UserID: 1}, nil)
+	router := setupTranscriptionSubtitleRouter(NewTranscriptionController(mockService, nil, owners))
+	content := []byte("1\n00:00:00,000 --> 00:00:01,000\nHello\n")
+
+	t.Run("Success", func(t *testing.T) {
+		transcription := &entity.Transcription{ID: 9, VideoID: 7, UserID: 1, Lang: "vi", Text: "Hello", Status: entity.FileStatusReady}
+		segments := []entity.TranscriptionSegment{{ID: 1, TranscriptionID: 9, StartMs: 0, EndMs: 1000, Text: "Hello"}}
+		mockService.On("ImportTranscription", uint64(1), uint64(7), "vi", subtitle.FormatSRT, content).Return(transcription, segments, nil).Once()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newImportRequest(t, map[string]string{"video_id": "7", "lang": "vi"}, "episode.srt", content))
+
+		var resp response.ImportedTranscriptionResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, uint64(9), resp.Transcription.ID)
+		assert.Equal(t, 1, resp.SegmentCount)
+	})
+
+	t.Run("Format Field Overrides Extension", func(t *testing.T) {
+		mockService.On("ImportTranscription", uint64(1), uint64(7), "en", subtitle.FormatTTML, content).
+			Return(nil, nil, &subtitle.ParseError{Line: 1, Reason: "expected tt root element"}).Once()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newImportRequest(t, map[string]string{"video_id": "7", "lang": "en", "format": "ttml"}, "episode.xml.txt", content))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "line 1")
+	})
+
+	t.Run("Video Not Found", func(t *testing.T) {
+		owners.On("VideoOwner", uint64(8)).Return(nil, service.ErrVideoNotFound).Once()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newImportRequest(t, map[string]string{"video_id": "8", "lang": "vi"}, "episode.srt", content))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	tests := []struct {
+		name     string
+		fields   map[string]string
+		fileName string
+		code     int
+	}{
+		{"Missing Video ID", map[string]string{"lang": "vi"}, "episode.srt", http.StatusBadRequest},
+		{"Missing Language", map[string]string{"video_id": "7"}, "episode.srt", http.StatusBadRequest},
+		{"Missing File", map[string]string{"video_id": "7", "lang": "vi"}, "", http.StatusBadRequest},
+		{"Unknown Extension", map[string]string{"video_id": "7", "lang": "vi"}, "episode.docx", http.StatusBadRequest},
+		{"Unknown Format", map[string]string{"video_id": "7", "lang": "vi", "format": "sub"}, "episode.srt", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newImportRequest(t, tt.fields, tt.fileName, content))
+
+			assert.Equal(t, tt.code, w.Code)
+		})
+	}
+
+	mockService.AssertExpectations(t)
+}
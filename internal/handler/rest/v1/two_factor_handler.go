@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorController lets the authenticated user manage their TOTP two-factor authentication
+type TwoFactorController struct {
+	twoFactorService service.TwoFactorService
+}
+
+// NewTwoFactorController creates a new TwoFactorController
+func NewTwoFactorController(twoFactorService service.TwoFactorService) *TwoFactorController {
+	return &TwoFactorController{
+		twoFactorService: twoFactorService,
+	}
+}
+
+// TwoFactorCodeRequest represents the request body of two-factor actions that need a code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"` // TOTP code, or a recovery code where the action allows it
+}
+
+// GetStatus godoc
+// @Summary Get the two-factor authentication status
+// @Description Tells whether the current user has two-factor authentication enabled and how many recovery codes are left
+// @Tags two-factor
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.TwoFactorStatusResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /users/2fa [get]
+func (h *TwoFactorController) GetStatus(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	status, err := h.twoFactorService.Status(userInfo.ID)
+	if err != nil {
+		handleTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TwoFactorStatusResponse{
+		Enabled:           status.Enabled,
+		RecoveryCodesLeft: status.RecoveryCodesLeft,
+	})
+}
+
+// Enroll godoc
+// @Summary Start two-factor enrollment
+// @Description Generates a new TOTP secret for the current user, with its otpauth URI and a QR code to scan with an authenticator app. Two-factor authentication is enabled once confirmed with a code
+// @Tags two-factor
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.TwoFactorEnrollmentResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /users/2fa/enroll [post]
+func (h *TwoFactorController) Enroll(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	enrollment, err := h.twoFactorService.Enroll(userInfo.ID)
+	if err != nil {
+		handleTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.TwoFactorEnrollmentResponse{
+		Secret:     enrollment.Secret,
+		OTPAuthURI: enrollment.URI,
+		QRCode:     enrollment.QRCode,
+	})
+}
+
+// Confirm godoc
+// @Summary Confirm two-factor enrollment
+// @Description Enables two-factor authentication with a TOTP code from the enrolled authenticator app and returns the one-time recovery codes, which are not shown again
+// @Tags two-factor
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} response.RecoveryCodesResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /users/2fa/confirm [post]
+func (h *TwoFactorController) Confirm(c *gin.Context) {
+	h.withCode(c, func(userID uint64, code string) {
+		codes, err := h.twoFactorService.Confirm(userID, code)
+		if err != nil {
+			handleTwoFactorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, response.RecoveryCodesResponse{RecoveryCodes: codes})
+	})
+}
+
+// Disable godoc
+// @Summary Disable two-factor authentication
+// @Description Turns off two-factor authentication for the current user and deletes their recovery codes
+// @Tags two-factor
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "TOTP code or recovery code"
+// @Success 200 {object} response.MessageResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse "error, with the seconds to wait in the Retry-After header"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /users/2fa/disable [post]
+func (h *TwoFactorController) Disable(c *gin.Context) {
+	h.withCode(c, func(userID uint64, code string) {
+		if err := h.twoFactorService.Disable(userID, code, c.ClientIP()); err != nil {
+			handleTwoFactorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, response.MessageResponse{Message: "Two-factor authentication disabled"})
+	})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replaces the recovery codes of the current user with new ones, which are not shown again
+// @Tags two-factor
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "TOTP code or recovery code"
+// @Success 200 {object} response.RecoveryCodesResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse "error, with the seconds to wait in the Retry-After header"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /users/2fa/recovery-codes [post]
+func (h *TwoFactorController) RegenerateRecoveryCodes(c *gin.Context) {
+	h.withCode(c, func(userID uint64, code string) {
+		codes, err := h.twoFactorService.RegenerateRecoveryCodes(userID, code, c.ClientIP())
+		if err != nil {
+			handleTwoFactorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, response.RecoveryCodesResponse{RecoveryCodes: codes})
+	})
+}
+
+// withCode runs the action for the authenticated user with the code from the request body
+func (h *TwoFactorController) withCode(c *gin.Context, action func(userID uint64, code string)) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	action(userInfo.ID, req.Code)
+}
+
+func handleTwoFactorError(c *gin.Context, err error) {
+	if respondLoginLocked(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled), errors.Is(err, service.ErrTwoFactorNotEnrolled), errors.Is(err, service.ErrTwoFactorNotEnabled):
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("Two-factor request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupTwoFactorRouter(controller *TwoFactorController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	twoFactor := router.Group("/users/2fa")
+	twoFactor.Use(middleware.NewMockAuthMiddleware().MustAuthAs(ownerUser))
+	twoFactor.GET("", controller.GetStatus)
+	twoFactor.POST("/enroll", controller.Enroll)
+	twoFactor.POST("/confirm", controller.Confirm)
+	twoFactor.POST("/disable", controller.Disable)
+	twoFactor.POST("/recovery-codes", controller.RegenerateRecoveryCodes)
+
+	return router
+}
+
+func TestTwoFactorStatus(t *testing.T) {
+	mockService := new(service.MockTwoFactorService)
+	router := setupTwoFactorRouter(NewTwoFactorController(mockService))
+
+	mockService.On("Status", ownerUser.ID).Return(&service.TwoFactorStatus{Enabled: true, RecoveryCodesLeft: 7}, nil).Once()
+
+	req, _ := http.NewRequest("GET", "/users/2fa", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp response.TwoFactorStatusResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, response.TwoFactorStatusResponse{Enabled: true, RecoveryCodesLeft: 7}, resp)
+	mockService.AssertExpectations(t)
+}
+
+func TestTwoFactorEnroll(t *testing.T) {
+	mockService := new(service.MockTwoFactorService)
+	router := setupTwoFactorRouter(NewTwoFactorController(mockService))
+
+	t.Run("Success", func(t *testing.T) {
+		enrollment := &service.TwoFactorEnrollment{Secret: "JBSWY3DPEHPK3PXP", URI: "otpauth://totp/MLVT:owner", QRCode: []byte("\x89PNG")}
+		mockService.On("Enroll", ownerUser.ID).Return(enrollment, nil).Once()
+
+		req, _ := http.NewRequest("POST", "/users/2fa/enroll", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.TwoFactorEnrollmentResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, enrollment.Secret, resp.Secret)
+		assert.Equal(t, enrollment.URI, resp.OTPAuthURI)
+		assert.Equal(t, enrollment.QRCode, resp.QRCode)
+	})
+
+	t.Run("Already Enabled", func(t *testing.T) {
+		mockService.On("Enroll", ownerUser.ID).Return(nil, service.ErrTwoFactorAlreadyEnabled).Once()
+
+		req, _ := http.NewRequest("POST", "/users/2fa/enroll", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestTwoFactorCodeActions(t *testing.T) {
+	mockService := new(service.MockTwoFactorService)
+	router := setupTwoFactorRouter(NewTwoFactorController(mockService))
+	codes := []string{"abcd-efgh", "ijkl-mnop"}
+
+	tests := []struct {
+		name       string
+		path       string
+		setup      func()
+		wantStatus int
+	}{
+		{"Confirm", "/users/2fa/confirm", func() {
+			mockService.On("Confirm", ownerUser.ID, "123456").Return(codes, nil).Once()
+		}, http.StatusOK},
+		{"Confirm Not Enrolled", "/users/2fa/confirm", func() {
+			mockService.On("Confirm", ownerUser.ID, "123456").Return(nil, service.ErrTwoFactorNotEnrolled).Once()
+		}, http.StatusConflict},
+		{"Disable", "/users/2fa/disable", func() {
+			mockService.On("Disable", ownerUser.ID, "123456", mock.Anything).Return(nil).Once()
+		}, http.StatusOK},
+		{"Disable Invalid Code", "/users/2fa/disable", func() {
+			mockService.On("Disable", ownerUser.ID, "123456", mock.Anything).Return(service.ErrInvalidTwoFactorCode).Once()
+		}, http.StatusBadRequest},
+		{"Regenerate Recovery Codes", "/users/2fa/recovery-codes", func() {
+			mockService.On("RegenerateRecoveryCodes", ownerUser.ID, "123456", mock.Anything).Return(codes, nil).Once()
+		}, http.StatusOK},
+		{"Regenerate Not Enabled", "/users/2fa/recovery-codes", func() {
+			mockService.On("RegenerateRecoveryCodes", ownerUser.ID, "123456", mock.Anything).Return(nil, service.ErrTwoFactorNotEnabled).Once()
+		}, http.StatusConflict},
+		{"Disable Locked Out", "/users/2fa/disable", func() {
+			mockService.On("Disable", ownerUser.ID, "123456", mock.Anything).Return(&service.LoginLockedError{RetryAfter: time.Minute}).Once()
+		}, http.StatusTooManyRequests},
+		{"Internal Error", "/users/2fa/recovery-codes", func() {
+			mockService.On("RegenerateRecoveryCodes", ownerUser.ID, "123456", mock.Anything).Return(nil, errors.New("db error")).Once()
+		}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			req, _ := http.NewRequest("POST", tt.path, bytes.NewBufferString(`{"code":"123456"}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	t.Run("Missing Code", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/users/2fa/confirm", bytes.NewBufferString(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
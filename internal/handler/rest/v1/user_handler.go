@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"mlvt/internal/entity"
 	"mlvt/internal/infra/env"
+	"mlvt/internal/infra/zap-logging/log"
 	"mlvt/internal/pkg/response"
 	"mlvt/internal/service"
 
@@ -82,9 +84,11 @@ func (h *UserController) GenerateAvatarDownloadURL(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param credentials body object true "Email and password"
-// @Success 200 {object} response.TokenResponse "token"
+// @Success 200 {object} response.TokenResponse "token, or response.TwoFactorChallengeResponse for users with two-factor authentication"
 // @Failure 400 {object} response.ErrorResponse "error"
 // @Failure 401 {object} response.ErrorResponse "error"
+// @Failure 403 {object} response.ErrorResponse "error"
+// @Failure 429 {object} response.ErrorResponse "error, with the seconds to wait in the Retry-After header"
 // @Router /users/login [post]
 func (h *UserController) LoginUser(c *gin.Context) {
 	var credentials struct {
@@ -96,16 +100,269 @@ func (h *UserController) LoginUser(c *gin.Context) {
 		return
 	}
 
-	token, userID, err := h.userService.Login(credentials.Email, credentials.Password)
+	result, err := h.userService.Login(credentials.Email, credentials.Password, c.ClientIP())
+	if respondLoginLocked(c, err) {
+		return
+	}
+	if errors.Is(err, service.ErrEmailNotVerified) {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, response.TokenResponse{
-		Token:  token,
-		UserID: userID,
-	})
+	respondLoginResult(c, result)
+}
+
+// respondLoginResult responds with the tokens of a completed login, or the challenge of a login waiting for a two-factor code
+func respondLoginResult(c *gin.Context, result *service.LoginResult) {
+	if result.Challenge != nil {
+		c.JSON(http.StatusOK, response.TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    result.Challenge.Token,
+			ExpiresAt:         result.Challenge.ExpiresAt,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, tokenResponse(result.TokenPair))
+}
+
+// TwoFactorLoginRequest represents the request body for the second step of the login
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+// LoginTwoFactor godoc
+// @Summary Complete a login with a two-factor code
+// @Description Exchanges the challenge token returned by the login and a TOTP code or an unused recovery code for tokens
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorLoginRequest true "Challenge token and code"
+// @Success 200 {object} response.TokenResponse "token"
+// @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 401 {object} response.ErrorResponse "error"
+// @Failure 429 {object} response.ErrorResponse "error, with the seconds to wait in the Retry-After header"
+// @Failure 500 {object} response.ErrorResponse "error"
+// @Router /users/login/2fa [post]
+func (h *UserController) LoginTwoFactor(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	tokens, err := h.userService.VerifyTwoFactor(req.ChallengeToken, req.Code, c.ClientIP())
+	if respondLoginLocked(c, err) {
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidChallenge), errors.Is(err, service.ErrInvalidTwoFactorCode):
+			c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: err.Error()})
+		default:
+			log.Errorf("Two-factor login failed: %v", err)
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse(tokens))
+}
+
+// RefreshTokenRequest represents the request body for refreshing the access token
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest represents the request body for logging out
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+	All          bool   `json:"all"` // Log out of every device by revoking every token of the user
+}
+
+// RefreshToken godoc
+// @Summary Refresh the access token
+// @Description Exchanges a refresh token for a new access token and refresh token. Each refresh token works once; reusing one revokes every token of the user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} response.TokenResponse "token"
+// @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 401 {object} response.ErrorResponse "error"
+// @Failure 500 {object} response.ErrorResponse "error"
+// @Router /users/refresh [post]
+func (h *UserController) RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	tokens, err := h.userService.RefreshToken(req.RefreshToken)
+	if err != nil {
+		handleTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse(tokens))
+}
+
+// Logout godoc
+// @Summary Log out
+// @Description Revokes the refresh token. With "all" set, revokes every access and refresh token of the user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body LogoutRequest true "Refresh token"
+// @Success 200 {object} response.MessageResponse "message"
+// @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 401 {object} response.ErrorResponse "error"
+// @Failure 500 {object} response.ErrorResponse "error"
+// @Router /users/logout [post]
+func (h *UserController) Logout(c *gin.Context) {
+	var req LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	if err := h.userService.Logout(req.RefreshToken, req.All); err != nil {
+		handleTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "Logged out successfully"})
+}
+
+// ForgotPasswordRequest represents the request body for requesting a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents the request body for setting a new password with a mailed token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset email
+// @Description Mails a single-use link to reset the password, valid for one hour. Responds the same whether or not the email is registered
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Email address"
+// @Success 200 {object} response.MessageResponse "message"
+// @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 500 {object} response.ErrorResponse "error"
+// @Router /users/forgot-password [post]
+func (h *UserController) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	if err := h.userService.ForgotPassword(req.Email); err != nil {
+		handleUserTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "If the email is registered, a password reset link has been sent"})
+}
+
+// ResetPassword godoc
+// @Summary Reset the password
+// @Description Sets a new password with the token from a password reset email. Revokes every session of the user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Token and new password"
+// @Success 200 {object} response.MessageResponse "message"
+// @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 500 {object} response.ErrorResponse "error"
+// @Router /users/reset-password [post]
+func (h *UserController) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	if err := h.userService.ResetPassword(req.Token, req.NewPassword); err != nil {
+		handleUserTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "Password reset successfully"})
+}
+
+// VerifyEmail godoc
+// @Summary Verify the email address
+// @Description Verifies the email address with the token from the link mailed on registration, valid for 24 hours
+// @Tags users
+// @Produce json
+// @Param token query string true "Verification token"
+// @Success 200 {object} response.MessageResponse "message"
+// @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 500 {object} response.ErrorResponse "error"
+// @Router /users/verify-email [get]
+func (h *UserController) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "token is required"})
+		return
+	}
+
+	if err := h.userService.VerifyEmail(token); err != nil {
+		handleUserTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "Email verified successfully"})
+}
+
+func tokenResponse(tokens *service.TokenPair) response.TokenResponse {
+	return response.TokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
+		UserID:       tokens.UserID,
+	}
+}
+
+// respondLoginLocked answers 429 with the seconds to wait in the Retry-After header when logins are locked out
+func respondLoginLocked(c *gin.Context, err error) bool {
+	var locked *service.LoginLockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(service.RetryAfterSeconds(locked.RetryAfter)))
+	c.JSON(http.StatusTooManyRequests, response.ErrorResponse{Error: locked.Error()})
+	return true
+}
+
+func handleTokenError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrInvalidRefreshToken) {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	log.Errorf("Token request failed: %v", err)
+	c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+}
+
+func handleUserTokenError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrInvalidUserToken) {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+		return
+	}
+	log.Errorf("Email token request failed: %v", err)
+	c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
 }
 
 // ChangePassword godoc
@@ -147,7 +404,7 @@ func (h *UserController) ChangePassword(c *gin.Context) {
 
 // UpdateUser godoc
 // @Summary Update user information
-// @Description Updates the user's information, excluding the avatar
+// @Description Updates the user's information, excluding the avatar. Status, premium and role are left unchanged. A new email address must be verified again through the link mailed to it
 // @Tags users
 // @Accept json
 // @Produce json
@@ -155,6 +412,7 @@ func (h *UserController) ChangePassword(c *gin.Context) {
 // @Param user body entity.User true "User data"
 // @Success 200 {object} response.MessageResponse "message"
 // @Failure 400 {object} response.ErrorResponse "error"
+// @Failure 404 {object} response.ErrorResponse "error"
 // @Failure 500 {object} response.ErrorResponse "error"
 // @Router /users/{user_id} [put]
 func (h *UserController) UpdateUser(c *gin.Context) {
@@ -173,6 +431,10 @@ func (h *UserController) UpdateUser(c *gin.Context) {
 	user.ID = userID
 
 	if err := h.userService.UpdateUser(&user); err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
 		return
 	}
@@ -276,24 +538,6 @@ func (h *UserController) GetUser(c *gin.Context) {
 	c.JSON(http.StatusOK, response.UserResponse{User: *user})
 }
 
-// GetAllUsers godoc
-// @Summary Get all users
-// @Description Retrieves a list of all users in the system
-// @Tags users
-// @Produce json
-// @Success 200 {object} response.UsersResponse "users"
-// @Failure 500 {object} response.ErrorResponse "error"
-// @Router /users [get]
-func (h *UserController) GetAllUsers(c *gin.Context) {
-	users, err := h.userService.GetAllUsers()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
-		return
-	}
-
-	c.JSON(http.StatusOK, response.UsersResponse{Users: users})
-}
-
 // DeleteUser godoc
 // @Summary Delete user
 // @Description Soft deletes a user by updating their status
@@ -12,6 +12,7 @@ import (
 	"net/http/httptest"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -154,7 +155,7 @@ func TestLoginUser_Success(t *testing.T) {
 
 	token := "jwt.token.here"
 
-	mockService.On("Login", credentials.Email, credentials.Password).Return(token, nil)
+	mockService.On("Login", credentials.Email, credentials.Password, mock.Anything).Return(&service.LoginResult{TokenPair: &service.TokenPair{UserID: 1, AccessToken: token, RefreshToken: "refresh.token.here"}}, nil)
 
 	body, _ := json.Marshal(credentials)
 
@@ -175,6 +176,7 @@ func TestLoginUser_Success(t *testing.T) {
 	err = json.Unmarshal(rr.Body.Bytes(), &resp)
 	assert.NoError(t, err)
 	assert.Equal(t, token, resp.Token)
+	assert.Equal(t, "refresh.token.here", resp.RefreshToken)
 
 	mockService.AssertExpectations(t)
 }
@@ -193,7 +195,7 @@ func TestLoginUser_Failure_Unauthorized(t *testing.T) {
 		Password: "wrongpassword",
 	}
 
-	mockService.On("Login", credentials.Email, credentials.Password).Return("", errors.New("invalid credentials"))
+	mockService.On("Login", credentials.Email, credentials.Password, mock.Anything).Return(nil, errors.New("invalid credentials"))
 
 	body, _ := json.Marshal(credentials)
 
@@ -218,6 +220,122 @@ func TestLoginUser_Failure_Unauthorized(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestLoginUser_Failure_EmailNotVerified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(service.MockUserService)
+	controller := NewUserController(mockService)
+
+	mockService.On("Login", "jane@example.com", "password123", mock.Anything).Return(nil, service.ErrEmailNotVerified)
+
+	body, _ := json.Marshal(map[string]string{"email": "jane@example.com", "password": "password123"})
+	req, _ := http.NewRequest(http.MethodPost, "/users/login", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router := gin.Default()
+	router.POST("/users/login", controller.LoginUser)
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestLoginUser_Failure_TooManyAttempts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(service.MockUserService)
+	controller := NewUserController(mockService)
+
+	mockService.On("Login", "jane@example.com", "password123", "192.0.2.1").
+		Return(nil, &service.LoginLockedError{RetryAfter: 1500 * time.Millisecond})
+
+	body, _ := json.Marshal(map[string]string{"email": "jane@example.com", "password": "password123"})
+	req := httptest.NewRequest(http.MethodPost, "/users/login", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router := gin.Default()
+	router.POST("/users/login", controller.LoginUser)
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("Retry-After"), "rounded up to whole seconds")
+	mockService.AssertExpectations(t)
+}
+
+func TestLoginUser_TwoFactorChallenge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(service.MockUserService)
+	controller := NewUserController(mockService)
+
+	expiresAt := time.Now().Add(service.ChallengeTTL).UTC().Truncate(time.Second)
+	challenge := &service.TwoFactorChallenge{Token: "challenge.token.here", ExpiresAt: expiresAt}
+	mockService.On("Login", "jane@example.com", "password123", mock.Anything).Return(&service.LoginResult{Challenge: challenge}, nil)
+
+	body, _ := json.Marshal(map[string]string{"email": "jane@example.com", "password": "password123"})
+	req, _ := http.NewRequest(http.MethodPost, "/users/login", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router := gin.Default()
+	router.POST("/users/login", controller.LoginUser)
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resp response.TwoFactorChallengeResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.True(t, resp.TwoFactorRequired)
+	assert.Equal(t, "challenge.token.here", resp.ChallengeToken)
+	assert.True(t, expiresAt.Equal(resp.ExpiresAt))
+	assert.NotContains(t, rr.Body.String(), `"token"`, "no tokens before the second step")
+
+	mockService.AssertExpectations(t)
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(service.MockUserService)
+	controller := NewUserController(mockService)
+
+	router := gin.Default()
+	router.POST("/users/login/2fa", controller.LoginTwoFactor)
+
+	tests := []struct {
+		name       string
+		body       string
+		setup      func()
+		wantStatus int
+	}{
+		{"Success", `{"challenge_token":"challenge","code":"123456"}`, func() {
+			mockService.On("VerifyTwoFactor", "challenge", "123456", mock.Anything).Return(&service.TokenPair{UserID: 1, AccessToken: "jwt.token.here"}, nil).Once()
+		}, http.StatusOK},
+		{"Invalid Code", `{"challenge_token":"challenge","code":"000000"}`, func() {
+			mockService.On("VerifyTwoFactor", "challenge", "000000", mock.Anything).Return(nil, service.ErrInvalidTwoFactorCode).Once()
+		}, http.StatusUnauthorized},
+		{"Invalid Challenge", `{"challenge_token":"expired","code":"123456"}`, func() {
+			mockService.On("VerifyTwoFactor", "expired", "123456", mock.Anything).Return(nil, service.ErrInvalidChallenge).Once()
+		}, http.StatusUnauthorized},
+		{"Missing Code", `{"challenge_token":"challenge"}`, func() {}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+
+			req, _ := http.NewRequest(http.MethodPost, "/users/login/2fa", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+		})
+	}
+
+	mockService.AssertExpectations(t)
+}
+
 func TestChangePassword_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -681,82 +799,65 @@ func TestGetUser_Failure_ServiceError(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
-func TestGetAllUsers_Success(t *testing.T) {
+func TestDeleteUser_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mockService := new(service.MockUserService)
 	controller := NewUserController(mockService)
 
-	users := []entity.User{
-		{
-			ID:        1,
-			FirstName: "John",
-			LastName:  "Doe",
-			UserName:  "johndoe",
-			Email:     "john@example.com",
-		},
-		{
-			ID:        2,
-			FirstName: "Jane",
-			LastName:  "Smith",
-			UserName:  "janesmith",
-			Email:     "jane@example.com",
-		},
-	}
+	userID := uint64(1)
 
-	mockService.On("GetAllUsers").Return(users, nil)
+	mockService.On("DeleteUser", userID).Return(nil)
 
-	req, err := http.NewRequest(http.MethodGet, "/users", nil)
+	req, err := http.NewRequest(http.MethodDelete, "/users/"+strconv.FormatUint(userID, 10), nil)
 	assert.NoError(t, err)
 
 	rr := httptest.NewRecorder()
 
 	router := gin.Default()
-	router.GET("/users", controller.GetAllUsers)
+	router.DELETE("/users/:user_id", controller.DeleteUser)
 
 	router.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
 
-	var resp response.UsersResponse
+	var resp response.MessageResponse
 	err = json.Unmarshal(rr.Body.Bytes(), &resp)
 	assert.NoError(t, err)
-	assert.Len(t, resp.Users, 2)
-	assert.Equal(t, "john@example.com", resp.Users[0].Email)
-	assert.Equal(t, "jane@example.com", resp.Users[1].Email)
+	assert.Equal(t, "User deleted successfully", resp.Message)
 
 	mockService.AssertExpectations(t)
 }
 
-func TestGetAllUsers_Failure_ServiceError(t *testing.T) {
+func TestDeleteUser_Failure_InvalidUserID(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mockService := new(service.MockUserService)
 	controller := NewUserController(mockService)
 
-	mockService.On("GetAllUsers").Return(nil, errors.New("db error"))
+	invalidUserID := "abc"
 
-	req, err := http.NewRequest(http.MethodGet, "/users", nil)
+	req, err := http.NewRequest(http.MethodDelete, "/users/"+invalidUserID, nil)
 	assert.NoError(t, err)
 
 	rr := httptest.NewRecorder()
 
 	router := gin.Default()
-	router.GET("/users", controller.GetAllUsers)
+	router.DELETE("/users/:user_id", controller.DeleteUser)
 
 	router.ServeHTTP(rr, req)
 
-	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
 
 	var resp response.ErrorResponse
 	err = json.Unmarshal(rr.Body.Bytes(), &resp)
 	assert.NoError(t, err)
-	assert.Equal(t, "internal server error", resp.Error)
+	assert.Equal(t, "invalid user ID", resp.Error)
 
-	mockService.AssertExpectations(t)
+	mockService.AssertNotCalled(t, "DeleteUser", mock.Anything)
 }
 
-func TestDeleteUser_Success(t *testing.T) {
+func TestDeleteUser_Failure_UserNotFound(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mockService := new(service.MockUserService)
@@ -764,7 +865,7 @@ func TestDeleteUser_Success(t *testing.T) {
 
 	userID := uint64(1)
 
-	mockService.On("DeleteUser", userID).Return(nil)
+	mockService.On("DeleteUser", userID).Return(errors.New("user not found"))
 
 	req, err := http.NewRequest(http.MethodDelete, "/users/"+strconv.FormatUint(userID, 10), nil)
 	assert.NoError(t, err)
@@ -776,25 +877,27 @@ func TestDeleteUser_Success(t *testing.T) {
 
 	router.ServeHTTP(rr, req)
 
-	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
 
-	var resp response.MessageResponse
+	var resp response.ErrorResponse
 	err = json.Unmarshal(rr.Body.Bytes(), &resp)
 	assert.NoError(t, err)
-	assert.Equal(t, "User deleted successfully", resp.Message)
+	assert.Equal(t, "user not found", resp.Error)
 
 	mockService.AssertExpectations(t)
 }
 
-func TestDeleteUser_Failure_InvalidUserID(t *testing.T) {
+func TestDeleteUser_Failure_ServiceError(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mockService := new(service.MockUserService)
 	controller := NewUserController(mockService)
 
-	invalidUserID := "abc"
+	userID := uint64(1)
 
-	req, err := http.NewRequest(http.MethodDelete, "/users/"+invalidUserID, nil)
+	mockService.On("DeleteUser", userID).Return(errors.New("db error"))
+
+	req, err := http.NewRequest(http.MethodDelete, "/users/"+strconv.FormatUint(userID, 10), nil)
 	assert.NoError(t, err)
 
 	rr := httptest.NewRecorder()
@@ -804,72 +907,218 @@ func TestDeleteUser_Failure_InvalidUserID(t *testing.T) {
 
 	router.ServeHTTP(rr, req)
 
-	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
 
 	var resp response.ErrorResponse
 	err = json.Unmarshal(rr.Body.Bytes(), &resp)
 	assert.NoError(t, err)
-	assert.Equal(t, "invalid user ID", resp.Error)
+	assert.Equal(t, "internal server error", resp.Error)
 
-	mockService.AssertNotCalled(t, "DeleteUser", mock.Anything)
+	mockService.AssertExpectations(t)
 }
 
-func TestDeleteUser_Failure_UserNotFound(t *testing.T) {
+func TestRefreshToken(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mockService := new(service.MockUserService)
 	controller := NewUserController(mockService)
 
-	userID := uint64(1)
+	router := gin.Default()
+	router.POST("/users/refresh", controller.RefreshToken)
+
+	t.Run("Success", func(t *testing.T) {
+		tokens := &service.TokenPair{UserID: 1, AccessToken: "new.access.token", RefreshToken: "new-refresh-token"}
+		mockService.On("RefreshToken", "old-refresh-token").Return(tokens, nil).Once()
+
+		body, _ := json.Marshal(RefreshTokenRequest{RefreshToken: "old-refresh-token"})
+		req, _ := http.NewRequest(http.MethodPost, "/users/refresh", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		var resp response.TokenResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "new.access.token", resp.Token)
+		assert.Equal(t, "new-refresh-token", resp.RefreshToken)
+	})
+
+	t.Run("Invalid Refresh Token", func(t *testing.T) {
+		mockService.On("RefreshToken", "used-refresh-token").Return(nil, service.ErrInvalidRefreshToken).Once()
+
+		body, _ := json.Marshal(RefreshTokenRequest{RefreshToken: "used-refresh-token"})
+		req, _ := http.NewRequest(http.MethodPost, "/users/refresh", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("Missing Refresh Token", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "/users/refresh", bytes.NewBufferString(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
 
-	mockService.On("DeleteUser", userID).Return(errors.New("user not found"))
+	mockService.AssertExpectations(t)
+}
 
-	req, err := http.NewRequest(http.MethodDelete, "/users/"+strconv.FormatUint(userID, 10), nil)
-	assert.NoError(t, err)
+func TestLogout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-	rr := httptest.NewRecorder()
+	mockService := new(service.MockUserService)
+	controller := NewUserController(mockService)
 
 	router := gin.Default()
-	router.DELETE("/users/:user_id", controller.DeleteUser)
+	router.POST("/users/logout", controller.Logout)
 
-	router.ServeHTTP(rr, req)
+	t.Run("Everywhere", func(t *testing.T) {
+		mockService.On("Logout", "refresh-token", true).Return(nil).Once()
 
-	assert.Equal(t, http.StatusNotFound, rr.Code)
+		body, _ := json.Marshal(LogoutRequest{RefreshToken: "refresh-token", All: true})
+		req, _ := http.NewRequest(http.MethodPost, "/users/logout", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
 
-	var resp response.ErrorResponse
-	err = json.Unmarshal(rr.Body.Bytes(), &resp)
-	assert.NoError(t, err)
-	assert.Equal(t, "user not found", resp.Error)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Internal Error", func(t *testing.T) {
+		mockService.On("Logout", "refresh-token", false).Return(errors.New("db error")).Once()
+
+		body, _ := json.Marshal(LogoutRequest{RefreshToken: "refresh-token"})
+		req, _ := http.NewRequest(http.MethodPost, "/users/logout", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
 
 	mockService.AssertExpectations(t)
 }
 
-func TestDeleteUser_Failure_ServiceError(t *testing.T) {
+func TestForgotPassword(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mockService := new(service.MockUserService)
 	controller := NewUserController(mockService)
 
-	userID := uint64(1)
+	router := gin.Default()
+	router.POST("/users/forgot-password", controller.ForgotPassword)
 
-	mockService.On("DeleteUser", userID).Return(errors.New("db error"))
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("ForgotPassword", "john@example.com").Return(nil).Once()
 
-	req, err := http.NewRequest(http.MethodDelete, "/users/"+strconv.FormatUint(userID, 10), nil)
-	assert.NoError(t, err)
+		body, _ := json.Marshal(ForgotPasswordRequest{Email: "john@example.com"})
+		req, _ := http.NewRequest(http.MethodPost, "/users/forgot-password", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
 
-	rr := httptest.NewRecorder()
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Invalid Email", func(t *testing.T) {
+		body, _ := json.Marshal(ForgotPasswordRequest{Email: "john"})
+		req, _ := http.NewRequest(http.MethodPost, "/users/forgot-password", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestResetPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(service.MockUserService)
+	controller := NewUserController(mockService)
 
 	router := gin.Default()
-	router.DELETE("/users/:user_id", controller.DeleteUser)
+	router.POST("/users/reset-password", controller.ResetPassword)
 
-	router.ServeHTTP(rr, req)
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("ResetPassword", "reset-token", "newpassword").Return(nil).Once()
 
-	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		body, _ := json.Marshal(ResetPasswordRequest{Token: "reset-token", NewPassword: "newpassword"})
+		req, _ := http.NewRequest(http.MethodPost, "/users/reset-password", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
 
-	var resp response.ErrorResponse
-	err = json.Unmarshal(rr.Body.Bytes(), &resp)
-	assert.NoError(t, err)
-	assert.Equal(t, "internal server error", resp.Error)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Invalid Token", func(t *testing.T) {
+		mockService.On("ResetPassword", "used-token", "newpassword").Return(service.ErrInvalidUserToken).Once()
+
+		body, _ := json.Marshal(ResetPasswordRequest{Token: "used-token", NewPassword: "newpassword"})
+		req, _ := http.NewRequest(http.MethodPost, "/users/reset-password", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Missing Password", func(t *testing.T) {
+		body, _ := json.Marshal(ResetPasswordRequest{Token: "reset-token"})
+		req, _ := http.NewRequest(http.MethodPost, "/users/reset-password", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(service.MockUserService)
+	controller := NewUserController(mockService)
+
+	router := gin.Default()
+	router.GET("/users/verify-email", controller.VerifyEmail)
+
+	t.Run("Success", func(t *testing.T) {
+		mockService.On("VerifyEmail", "verify-token").Return(nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/users/verify-email?token=verify-token", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Expired Token", func(t *testing.T) {
+		mockService.On("VerifyEmail", "expired-token").Return(service.ErrInvalidUserToken).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/users/verify-email?token=expired-token", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Missing Token", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/users/verify-email", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
 
 	mockService.AssertExpectations(t)
 }
@@ -1,12 +1,14 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"mlvt/internal/entity"
 	"mlvt/internal/infra/env"
 	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
 	"mlvt/internal/pkg/response"
 	"mlvt/internal/service"
 
@@ -14,11 +16,18 @@ import (
 )
 
 type VideoController struct {
-	videoService service.VideoService
+	videoService        service.VideoService
+	organizationService service.OrganizationService
+	subscriptionService service.SubscriptionService
 }
 
-func NewVideoController(videoService service.VideoService) *VideoController {
-	return &VideoController{videoService: videoService}
+func NewVideoController(videoService service.VideoService, organizationService service.OrganizationService,
+	subscriptionService service.SubscriptionService) *VideoController {
+	return &VideoController{
+		videoService:        videoService,
+		organizationService: organizationService,
+		subscriptionService: subscriptionService,
+	}
 }
 
 // GetVideoStatus godoc
@@ -101,43 +110,91 @@ func (vc *VideoController) UpdateVideoStatus(c *gin.Context) {
 
 // AddVideo handles adding a new video
 // @Summary Add a new video
-// @Description Creates a new video record in the system
+// @Description Creates a new video record in the pending_upload state. Call the finalize endpoint once the file is uploaded. The file and image are looked up under the prefix of the current user. The owner must have videos left in the quotas of their plan, or the free quotas without one, and the duration must fit them
 // @Tags Videos
 // @Accept json
 // @Produce json
 // @Param video body entity.Video true "Video data"
-// @Success 201 {object} response.MessageResponse
+// @Success 201 {object} response.CreatedResponse
 // @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse "not allowed, or over the quotas"
 // @Failure 500 {object} response.ErrorResponse
 // @Router /videos [post]
 func (h *VideoController) AddVideo(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
 	var video entity.Video
 	if err := c.ShouldBindJSON(&video); err != nil {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	// The owner defaults to the current user; only admins may add a video for another user
+	if video.UserID == 0 {
+		video.UserID = userInfo.ID
+	}
+	if !middleware.CanAccess(userInfo, video.UserID) {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: "cannot add a video for another user"})
+		return
+	}
+	if !canAddToOrg(c, h.organizationService, userInfo, video.OrgID) {
+		return
+	}
+	if !h.checkVideoQuota(c, video.UserID, video.Duration) {
+		return
+	}
+
+	// The files are the ones the current user uploaded; the keys are never taken from the client
+	video.Folder = env.EnvConfig.VideosFolder
+	if video.FileName, ok = userFileName(userInfo.ID, video.FileName); !ok {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid file_name"})
+		return
+	}
+	if video.Image != "" {
+		if video.Image, ok = userFileName(userInfo.ID, video.Image); !ok {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid image"})
+			return
+		}
+	}
+
 	if err := h.videoService.CreateVideo(&video); err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, response.MessageResponse{Message: "Video added successfully"})
+	c.JSON(http.StatusCreated, response.CreatedResponse{Message: "Video added successfully", ID: video.ID})
 }
 
 // GenerateUploadURLForVideo generates a presigned URL for uploading a video file
 // @Summary Generate presigned upload URL for a video
-// @Description Generates a presigned URL to upload a video file to S3
+// @Description Generates a presigned URL to upload a video file to S3. The file is stored under the prefix of the current user; add the video with the returned file_name.
 // @Tags Videos
 // @Produce json
 // @Param file_name query string true "Name of the video file"
 // @Param file_type query string true "Type of the video file (e.g., video/mp4)"
-// @Success 200 {object} map[string]string "upload_url"
+// @Success 200 {object} response.UploadURLResponse "upload_url, file_name"
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Router /videos/generate-upload-url/video [post]
 func (h *VideoController) GenerateUploadURLForVideo(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
 	folder := env.EnvConfig.VideosFolder
-	fileName := c.Query("file_name")
+	fileName, ok := userFileName(userInfo.ID, c.Query("file_name"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid file_name"})
+		return
+	}
 	fileType := c.Query("file_type")
 
 	url, err := h.videoService.GeneratePresignedUploadURLForVideo(folder, fileName, fileType)
@@ -146,22 +203,34 @@ func (h *VideoController) GenerateUploadURLForVideo(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"upload_url": url})
+	c.JSON(http.StatusOK, response.UploadURLResponse{UploadURL: url, FileName: fileName})
 }
 
 // GenerateUploadURLForImage generates a presigned URL for uploading an image file
 // @Summary Generate presigned upload URL for an image
-// @Description Generates a presigned URL to upload an image (e.g., thumbnail) to S3
+// @Description Generates a presigned URL to upload an image (e.g., thumbnail) to S3. The image is stored under the prefix of the current user; add the video with the returned file_name as its image.
 // @Tags Videos
 // @Produce json
 // @Param file_name query string true "Name of the image file"
 // @Param file_type query string true "Type of the image file (e.g., image/jpeg)"
-// @Success 200 {object} map[string]string "upload_url"
+// @Success 200 {object} response.UploadURLResponse "upload_url, file_name"
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Router /videos/generate-upload-url/image [post]
 func (h *VideoController) GenerateUploadURLForImage(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
 	folder := env.EnvConfig.VideoFramesFolder
-	fileName := c.Query("file_name")
+	fileName, ok := userFileName(userInfo.ID, c.Query("file_name"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid file_name"})
+		return
+	}
 	fileType := c.Query("file_type")
 
 	url, err := h.videoService.GeneratePresignedUploadURLForImage(folder, fileName, fileType)
@@ -170,7 +239,7 @@ func (h *VideoController) GenerateUploadURLForImage(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"upload_url": url})
+	c.JSON(http.StatusOK, response.UploadURLResponse{UploadURL: url, FileName: fileName})
 }
 
 // GenerateDownloadURLForVideo generates a presigned URL for downloading a video file
@@ -328,3 +397,49 @@ func (h *VideoController) ListVideosByUserID(c *gin.Context) {
 		"frames": frames,
 	})
 }
+
+// ListVideosByOrgID handles listing all videos shared with an organization along with presigned image URLs
+// @Summary List videos by organization ID
+// @Description Fetches all videos shared with an organization along with presigned image URLs. Needs membership of the organization
+// @Tags Videos
+// @Produce json
+// @Param org_id path uint64 true "Organization ID"
+// @Success 200 {object} map[string]interface{} "videos, frames"
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /videos/org/{org_id} [get]
+func (h *VideoController) ListVideosByOrgID(c *gin.Context) {
+	orgID, err := strconv.ParseUint(c.Param("org_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid organization ID"})
+		return
+	}
+
+	videos, frames, err := h.videoService.ListVideosByOrgID(orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"videos": videos,
+		"frames": frames,
+	})
+}
+
+// checkVideoQuota writes an error and reports false unless the quotas of the user allow another video of the
+// duration in seconds
+func (h *VideoController) checkVideoQuota(c *gin.Context, userID uint64, duration int) bool {
+	err := h.subscriptionService.CheckVideoQuota(userID, duration)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, service.ErrVideoQuotaExceeded), errors.Is(err, service.ErrVideoTooLong):
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("Failed to check the video quota of user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+	return false
+}
@@ -11,6 +11,7 @@ import (
 
 	"mlvt/internal/entity"
 	"mlvt/internal/infra/env"
+	"mlvt/internal/pkg/middleware"
 	"mlvt/internal/pkg/response"
 	"mlvt/internal/service"
 
@@ -24,6 +25,7 @@ func setupRouter(controller *VideoController) *gin.Engine {
 	// Set Gin to Test Mode to reduce unnecessary logs
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
+	router.Use(middleware.NewMockAuthMiddleware().MustAuthAuthenticated())
 
 	// Register routes
 	router.GET("/videos/:video_id/status", controller.GetVideoStatus)
@@ -42,7 +44,7 @@ func setupRouter(controller *VideoController) *gin.Engine {
 
 func TestGetVideoStatus(t *testing.T) {
 	mockService := new(service.MockVideoService)
-	controller := NewVideoController(mockService)
+	controller := NewVideoController(mockService, nil, nil)
 	router := setupRouter(controller)
 
 	t.Run("Success", func(t *testing.T) {
@@ -115,7 +117,7 @@ func TestGetVideoStatus(t *testing.T) {
 
 func TestUpdateVideoStatus(t *testing.T) {
 	mockService := new(service.MockVideoService)
-	controller := NewVideoController(mockService)
+	controller := NewVideoController(mockService, nil, nil)
 	router := setupRouter(controller)
 
 	t.Run("Success", func(t *testing.T) {
@@ -225,7 +227,9 @@ func TestUpdateVideoStatus(t *testing.T) {
 
 func TestAddVideo(t *testing.T) {
 	mockService := new(service.MockVideoService)
-	controller := NewVideoController(mockService)
+	subscriptions := new(service.MockSubscriptionService)
+	subscriptions.On("CheckVideoQuota", uint64(1), mock.AnythingOfType("int")).Return(nil)
+	controller := NewVideoController(mockService, nil, subscriptions)
 	router := setupRouter(controller)
 
 	t.Run("Success", func(t *testing.T) {
@@ -259,12 +263,58 @@ func TestAddVideo(t *testing.T) {
 		assert.Equal(t, "Video added successfully", resp.Message)
 
 		mockService.AssertCalled(t, "CreateVideo", mock.AnythingOfType("*entity.Video"))
+		created := mockService.Calls[len(mockService.Calls)-1].Arguments.Get(0).(*entity.Video)
+		assert.Equal(t, env.EnvConfig.VideosFolder, created.Folder, "the folder is never taken from the client")
+		assert.Equal(t, "user_1/test.mp4", created.FileName)
+		assert.Equal(t, "user_1/test.jpg", created.Image)
+	})
+
+	t.Run("Owner Defaults To Current User", func(t *testing.T) {
+		body, _ := json.Marshal(entity.Video{Title: "Untitled", FileName: "untitled.mp4"})
+		req, _ := http.NewRequest("POST", "/videos", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		created := mockService.Calls[len(mockService.Calls)-1].Arguments.Get(0).(*entity.Video)
+		assert.Equal(t, uint64(1), created.UserID)
+	})
+
+	t.Run("Other User Forbidden", func(t *testing.T) {
+		body, _ := json.Marshal(entity.Video{Title: "Not mine", FileName: "other.mp4", UserID: 2})
+		req, _ := http.NewRequest("POST", "/videos", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Over Quota", func(t *testing.T) {
+		subscriptions := new(service.MockSubscriptionService)
+		subscriptions.On("CheckVideoQuota", uint64(1), 11*60).Return(service.ErrVideoTooLong).Once()
+		subscriptions.On("CheckVideoQuota", uint64(1), 60).Return(service.ErrVideoQuotaExceeded).Once()
+		videos := new(service.MockVideoService)
+		router := setupRouter(NewVideoController(videos, nil, subscriptions))
+
+		for _, duration := range []int{11 * 60, 60} {
+			body, _ := json.Marshal(entity.Video{Title: "Too much", FileName: "long.mp4", Duration: duration})
+			req, _ := http.NewRequest("POST", "/videos", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+		}
+		subscriptions.AssertExpectations(t)
+		videos.AssertNotCalled(t, "CreateVideo", mock.Anything)
 	})
 }
 
 func TestGenerateUploadURLForVideo(t *testing.T) {
 	mockService := new(service.MockVideoService)
-	controller := NewVideoController(mockService)
+	controller := NewVideoController(mockService, nil, nil)
 	router := setupRouter(controller)
 
 	// Mock environment variable
@@ -275,7 +325,7 @@ func TestGenerateUploadURLForVideo(t *testing.T) {
 	}()
 
 	t.Run("Success", func(t *testing.T) {
-		fileName := "video.mp4"
+		fileName := "user_1/video.mp4"
 		fileType := "video/mp4"
 		uploadURL := "https://s3.amazonaws.com/test_videos/video.mp4?signature=abc"
 
@@ -290,12 +340,13 @@ func TestGenerateUploadURLForVideo(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Equal(t, uploadURL, resp["upload_url"])
+		assert.Equal(t, fileName, resp["file_name"])
 
 		mockService.AssertCalled(t, "GeneratePresignedUploadURLForVideo", "test_videos", fileName, fileType)
 	})
 
 	t.Run("Internal Server Error", func(t *testing.T) {
-		fileName := "video2.mp4"
+		fileName := "user_1/video2.mp4"
 		fileType := "video/mp4"
 		errMsg := "S3 service unavailable"
 
@@ -317,7 +368,7 @@ func TestGenerateUploadURLForVideo(t *testing.T) {
 
 func TestGenerateUploadURLForImage(t *testing.T) {
 	mockService := new(service.MockVideoService)
-	controller := NewVideoController(mockService)
+	controller := NewVideoController(mockService, nil, nil)
 	router := setupRouter(controller)
 
 	// Mock environment variable
@@ -328,7 +379,7 @@ func TestGenerateUploadURLForImage(t *testing.T) {
 	}()
 
 	t.Run("Success", func(t *testing.T) {
-		fileName := "image.jpg"
+		fileName := "user_1/image.jpg"
 		fileType := "image/jpeg"
 		uploadURL := "https://s3.amazonaws.com/test_frames/image.jpg?signature=xyz"
 
@@ -343,12 +394,13 @@ func TestGenerateUploadURLForImage(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Equal(t, uploadURL, resp["upload_url"])
+		assert.Equal(t, fileName, resp["file_name"])
 
 		mockService.AssertCalled(t, "GeneratePresignedUploadURLForImage", "test_frames", fileName, fileType)
 	})
 
 	t.Run("Internal Server Error", func(t *testing.T) {
-		fileName := "image2.jpg"
+		fileName := "user_1/image2.jpg"
 		fileType := "image/jpeg"
 		errMsg := "S3 service timeout"
 
@@ -370,7 +422,7 @@ func TestGenerateUploadURLForImage(t *testing.T) {
 
 func TestGenerateDownloadURLForVideo(t *testing.T) {
 	mockService := new(service.MockVideoService)
-	controller := NewVideoController(mockService)
+	controller := NewVideoController(mockService, nil, nil)
 	router := setupRouter(controller)
 
 	t.Run("Success", func(t *testing.T) {
@@ -426,7 +478,7 @@ func TestGenerateDownloadURLForVideo(t *testing.T) {
 
 func TestGenerateDownloadURLForImage(t *testing.T) {
 	mockService := new(service.MockVideoService)
-	controller := NewVideoController(mockService)
+	controller := NewVideoController(mockService, nil, nil)
 	router := setupRouter(controller)
 
 	t.Run("Success", func(t *testing.T) {
@@ -482,7 +534,7 @@ func TestGenerateDownloadURLForImage(t *testing.T) {
 
 func TestGetVideoByID(t *testing.T) {
 	mockService := new(service.MockVideoService)
-	controller := NewVideoController(mockService)
+	controller := NewVideoController(mockService, nil, nil)
 	router := setupRouter(controller)
 
 	t.Run("Success", func(t *testing.T) {
@@ -590,7 +642,7 @@ func TestGetVideoByID(t *testing.T) {
 
 func TestDeleteVideo(t *testing.T) {
 	mockService := new(service.MockVideoService)
-	controller := NewVideoController(mockService)
+	controller := NewVideoController(mockService, nil, nil)
 	router := setupRouter(controller)
 
 	t.Run("Success", func(t *testing.T) {
@@ -660,12 +712,12 @@ func TestDeleteVideo(t *testing.T) {
 
 func TestListVideosByUserID(t *testing.T) {
 	mockService := new(service.MockVideoService)
-	controller := NewVideoController(mockService)
+	controller := NewVideoController(mockService, nil, nil)
 	router := setupRouter(controller)
 
 	t.Run("Success", func(t *testing.T) {
 		userID := uint64(1)
-		fixedTime := time.Date(2024, time.October, 14, 21, 35, 25, 616671000, time.Local)
+		fixedTime := time.Date(2024, time.October, 14, 21, 35, 25, 616671000, time.UTC)
 
 		videos := []entity.Video{
 			{
@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"mlvt/internal/infra/aws"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InitiateUploadRequest represents the request body for starting a multipart upload
+type InitiateUploadRequest struct {
+	FileName string `json:"file_name" binding:"required"`
+	FileType string `json:"file_type" binding:"required"`
+	FileSize int64  `json:"file_size" binding:"required,gt=0"`
+}
+
+// PresignPartsRequest represents the request body for presigning part upload URLs
+type PresignPartsRequest struct {
+	PartNumbers []int32 `json:"part_numbers" binding:"required,min=1,max=1000"`
+}
+
+// CompleteUploadRequest represents the request body for completing a multipart upload
+type CompleteUploadRequest struct {
+	Parts []aws.UploadedPart `json:"parts" binding:"required,min=1"`
+}
+
+// FinalizeVideoUpload godoc
+// @Summary Confirm the upload of a video file
+// @Description Checks that the video file exists in storage and matches the declared content type, records its size and ETag, and moves the video from pending_upload to raw
+// @Tags Videos
+// @Produce json
+// @Param video_id path uint64 true "Video ID"
+// @Success 200 {object} response.VideoResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse "file has not been uploaded"
+// @Failure 422 {object} response.ErrorResponse "file type mismatch"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /videos/{video_id}/finalize [post]
+func (h *VideoController) FinalizeVideoUpload(c *gin.Context) {
+	videoID, err := strconv.ParseUint(c.Param("video_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid video ID"})
+		return
+	}
+
+	video, err := h.videoService.FinalizeVideoUpload(videoID)
+	if err != nil {
+		handleFinalizeError(c, err, "video not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.VideoResponse{Video: *video})
+}
+
+// InitiateMultipartUpload godoc
+// @Summary Start a multipart upload for a video
+// @Description Starts a resumable multipart upload and returns the session with the part size to split the file with. The file is stored under the prefix of the current user; add the video with the file_name of the session. The user must have videos left in the quotas of their plan, or the free quotas without one
+// @Tags Videos
+// @Accept json
+// @Produce json
+// @Param request body InitiateUploadRequest true "File to upload"
+// @Success 201 {object} response.UploadSessionResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse "over the quotas"
+// @Failure 500 {object} response.ErrorResponse
+// @Router /videos/uploads [post]
+func (h *VideoController) InitiateMultipartUpload(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var req InitiateUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+	fileName, ok := userFileName(userInfo.ID, req.FileName)
+	if !ok {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid file_name"})
+		return
+	}
+	// The duration is not known before the file is uploaded; it is checked when the video is added
+	if !h.checkVideoQuota(c, userInfo.ID, 0) {
+		return
+	}
+
+	session, err := h.videoService.InitiateMultipartUpload(userInfo.ID, env.EnvConfig.VideosFolder, fileName, req.FileType, req.FileSize)
+	if err != nil {
+		h.handleUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.UploadSessionResponse{UploadSession: *session})
+}
+
+// ListUploadSessions godoc
+// @Summary List unfinished multipart uploads
+// @Description Lists the multipart uploads of the current user that are still in progress, so they can be resumed
+// @Tags Videos
+// @Produce json
+// @Success 200 {object} response.UploadSessionsResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /videos/uploads [get]
+func (h *VideoController) ListUploadSessions(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	sessions, err := h.videoService.ListUploadSessions(userInfo.ID)
+	if err != nil {
+		h.handleUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.UploadSessionsResponse{UploadSessions: sessions})
+}
+
+// PresignUploadParts godoc
+// @Summary Generate presigned URLs for upload parts
+// @Description Generates a presigned PUT URL for each requested part. The ETag header of every part upload must be kept to complete the upload
+// @Tags Videos
+// @Accept json
+// @Produce json
+// @Param upload_id path string true "Upload ID"
+// @Param request body PresignPartsRequest true "Part numbers"
+// @Success 200 {object} response.UploadPartURLsResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /videos/uploads/{upload_id}/parts [post]
+func (h *VideoController) PresignUploadParts(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var req PresignPartsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	urls, err := h.videoService.GeneratePresignedUploadPartURLs(userInfo.ID, c.Param("upload_id"), req.PartNumbers)
+	if err != nil {
+		h.handleUploadError(c, err)
+		return
+	}
+
+	parts := make([]response.UploadPartURL, 0, len(req.PartNumbers))
+	for _, partNumber := range req.PartNumbers {
+		parts = append(parts, response.UploadPartURL{PartNumber: partNumber, UploadURL: urls[partNumber]})
+	}
+	c.JSON(http.StatusOK, response.UploadPartURLsResponse{Parts: parts})
+}
+
+// ListUploadedParts godoc
+// @Summary List the uploaded parts of a multipart upload
+// @Description Returns the upload session and the parts already stored, so an interrupted upload can be resumed
+// @Tags Videos
+// @Produce json
+// @Param upload_id path string true "Upload ID"
+// @Success 200 {object} response.UploadedPartsResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /videos/uploads/{upload_id}/parts [get]
+func (h *VideoController) ListUploadedParts(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	session, parts, err := h.videoService.ListUploadedParts(userInfo.ID, c.Param("upload_id"))
+	if err != nil {
+		h.handleUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.UploadedPartsResponse{UploadSession: *session, Parts: parts})
+}
+
+// CompleteMultipartUpload godoc
+// @Summary Complete a multipart upload
+// @Description Assembles the uploaded parts into the video file. Every part must be listed with the ETag returned when it was uploaded
+// @Tags Videos
+// @Accept json
+// @Produce json
+// @Param upload_id path string true "Upload ID"
+// @Param request body CompleteUploadRequest true "Uploaded parts"
+// @Success 200 {object} response.UploadSessionResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /videos/uploads/{upload_id}/complete [post]
+func (h *VideoController) CompleteMultipartUpload(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	var req CompleteUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: "invalid input"})
+		return
+	}
+
+	session, err := h.videoService.CompleteMultipartUpload(userInfo.ID, c.Param("upload_id"), req.Parts)
+	if err != nil {
+		h.handleUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.UploadSessionResponse{UploadSession: *session})
+}
+
+// AbortMultipartUpload godoc
+// @Summary Abort a multipart upload
+// @Description Cancels a multipart upload and discards the parts already uploaded
+// @Tags Videos
+// @Produce json
+// @Param upload_id path string true "Upload ID"
+// @Success 200 {object} response.MessageResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /videos/uploads/{upload_id} [delete]
+func (h *VideoController) AbortMultipartUpload(c *gin.Context) {
+	userInfo, ok := middleware.GetUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, response.ErrorResponse{Error: "Unauthorized"})
+		return
+	}
+
+	if err := h.videoService.AbortMultipartUpload(userInfo.ID, c.Param("upload_id")); err != nil {
+		h.handleUploadError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.MessageResponse{Message: "upload aborted successfully"})
+}
+
+// handleFinalizeError maps errors of the finalize upload step to HTTP responses
+func handleFinalizeError(c *gin.Context, err error, notFound string) {
+	switch {
+	case err.Error() == notFound:
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: notFound})
+	case errors.Is(err, service.ErrFileNotUploaded):
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrFileTypeMismatch):
+		c.JSON(http.StatusUnprocessableEntity, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("Failed to finalize upload: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
+
+// handleUploadError maps multipart upload errors to HTTP responses
+func (h *VideoController) handleUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUploadSessionNotFound):
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrUploadSessionClosed):
+		c.JSON(http.StatusConflict, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidPartNumber), errors.Is(err, service.ErrIncompleteUpload), errors.Is(err, service.ErrInvalidFileSize):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, aws.ErrInvalidPart), errors.Is(err, aws.ErrPartTooSmall):
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Error: err.Error()})
+	default:
+		log.Errorf("Multipart upload failed: %v", err)
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Error: "internal server error"})
+	}
+}
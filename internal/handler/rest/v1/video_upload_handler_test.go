@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/aws"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/pkg/middleware"
+	"mlvt/internal/pkg/response"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupUploadRouter registers the multipart upload routes next to the video routes they share a prefix with
+func setupUploadRouter(controller *VideoController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+
+	videos := router.Group("/videos")
+	videos.Use(middleware.NewMockAuthMiddleware().MustAuthAuthenticated())
+	videos.GET("/:video_id", controller.GetVideoByID)
+	videos.DELETE("/:video_id", controller.DeleteVideo)
+	videos.POST("/:video_id/finalize", controller.FinalizeVideoUpload)
+	videos.POST("/uploads", controller.InitiateMultipartUpload)
+	videos.GET("/uploads", controller.ListUploadSessions)
+	videos.POST("/uploads/:upload_id/parts", controller.PresignUploadParts)
+	videos.GET("/uploads/:upload_id/parts", controller.ListUploadedParts)
+	videos.POST("/uploads/:upload_id/complete", controller.CompleteMultipartUpload)
+	videos.DELETE("/uploads/:upload_id", controller.AbortMultipartUpload)
+
+	return router
+}
+
+func TestInitiateMultipartUpload(t *testing.T) {
+	mockService := new(service.MockVideoService)
+	subscriptions := new(service.MockSubscriptionService)
+	router := setupUploadRouter(NewVideoController(mockService, nil, subscriptions))
+
+	t.Run("Success", func(t *testing.T) {
+		session := &entity.UploadSession{UploadID: "upload-1", UserID: 1, FileName: "user_1/big.mp4", TotalParts: 96, Status: entity.UploadStatusInProgress}
+		subscriptions.On("CheckVideoQuota", uint64(1), 0).Return(nil).Once()
+		mockService.On("InitiateMultipartUpload", uint64(1), env.EnvConfig.VideosFolder, "user_1/big.mp4", "video/mp4", int64(6<<30)).Return(session, nil).Once()
+
+		body, _ := json.Marshal(InitiateUploadRequest{FileName: "big.mp4", FileType: "video/mp4", FileSize: 6 << 30})
+		req, _ := http.NewRequest("POST", "/videos/uploads", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.UploadSessionResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "upload-1", resp.UploadSession.UploadID)
+		assert.Equal(t, 96, resp.UploadSession.TotalParts)
+	})
+
+	t.Run("Invalid Input", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/videos/uploads", bytes.NewBufferString(`{"file_name":"big.mp4"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Over Quota", func(t *testing.T) {
+		subscriptions.On("CheckVideoQuota", uint64(1), 0).Return(service.ErrVideoQuotaExceeded).Once()
+
+		body, _ := json.Marshal(InitiateUploadRequest{FileName: "big.mp4", FileType: "video/mp4", FileSize: 6 << 30})
+		req, _ := http.NewRequest("POST", "/videos/uploads", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	subscriptions.AssertExpectations(t)
+	mockService.AssertExpectations(t)
+}
+
+func TestPresignUploadParts(t *testing.T) {
+	mockService := new(service.MockVideoService)
+	router := setupUploadRouter(NewVideoController(mockService, nil, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		urls := map[int32]string{1: "https://s3/part-1", 2: "https://s3/part-2"}
+		mockService.On("GeneratePresignedUploadPartURLs", uint64(1), "upload-1", []int32{1, 2}).Return(urls, nil)
+
+		req, _ := http.NewRequest("POST", "/videos/uploads/upload-1/parts", bytes.NewBufferString(`{"part_numbers":[1,2]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp response.UploadPartURLsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []response.UploadPartURL{{PartNumber: 1, UploadURL: urls[1]}, {PartNumber: 2, UploadURL: urls[2]}}, resp.Parts)
+	})
+
+	t.Run("Session Not Found", func(t *testing.T) {
+		mockService.On("GeneratePresignedUploadPartURLs", uint64(1), "other", []int32{1}).Return(nil, service.ErrUploadSessionNotFound)
+
+		req, _ := http.NewRequest("POST", "/videos/uploads/other/parts", bytes.NewBufferString(`{"part_numbers":[1]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestListUploadedParts(t *testing.T) {
+	mockService := new(service.MockVideoService)
+	router := setupUploadRouter(NewVideoController(mockService, nil, nil))
+
+	session := &entity.UploadSession{UploadID: "upload-1", UserID: 1, TotalParts: 3, Status: entity.UploadStatusInProgress}
+	parts := []aws.UploadedPart{{PartNumber: 1, ETag: `"a"`, Size: 5 << 20}}
+	mockService.On("ListUploadedParts", uint64(1), "upload-1").Return(session, parts, nil)
+
+	req, _ := http.NewRequest("GET", "/videos/uploads/upload-1/parts", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp response.UploadedPartsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, parts, resp.Parts)
+	assert.Equal(t, 3, resp.UploadSession.TotalParts)
+}
+
+func TestCompleteMultipartUpload(t *testing.T) {
+	mockService := new(service.MockVideoService)
+	router := setupUploadRouter(NewVideoController(mockService, nil, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		parts := []aws.UploadedPart{{PartNumber: 1, ETag: `"a"`}}
+		session := &entity.UploadSession{UploadID: "upload-1", UserID: 1, Status: entity.UploadStatusCompleted}
+		mockService.On("CompleteMultipartUpload", uint64(1), "upload-1", parts).Return(session, nil)
+
+		body, _ := json.Marshal(CompleteUploadRequest{Parts: parts})
+		req, _ := http.NewRequest("POST", "/videos/uploads/upload-1/complete", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Already Completed", func(t *testing.T) {
+		parts := []aws.UploadedPart{{PartNumber: 1, ETag: `"a"`}}
+		mockService.On("CompleteMultipartUpload", uint64(1), "upload-2", parts).Return(nil, service.ErrUploadSessionClosed)
+
+		body, _ := json.Marshal(CompleteUploadRequest{Parts: parts})
+		req, _ := http.NewRequest("POST", "/videos/uploads/upload-2/complete", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+func TestAbortMultipartUpload(t *testing.T) {
+	mockService := new(service.MockVideoService)
+	router := setupUploadRouter(NewVideoController(mockService, nil, nil))
+
+	mockService.On("AbortMultipartUpload", uint64(1), "upload-1").Return(nil)
+
+	req, _ := http.NewRequest("DELETE", "/videos/uploads/upload-1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestFinalizeVideoUpload(t *testing.T) {
+	mockService := new(service.MockVideoService)
+	router := setupUploadRouter(NewVideoController(mockService, nil, nil))
+
+	tests := []struct {
+		name       string
+		videoID    uint64
+		video      *entity.Video
+		err        error
+		wantStatus int
+	}{
+		{"Success", 1, &entity.Video{ID: 1, Status: entity.StatusRaw, FileSize: 2048}, nil, http.StatusOK},
+		{"Video Not Found", 2, nil, errors.New("video not found"), http.StatusNotFound},
+		{"File Not Uploaded", 3, nil, service.ErrFileNotUploaded, http.StatusConflict},
+		{"Type Mismatch", 4, nil, fmt.Errorf("%w: declared \"video/mp4\", stored \"image/png\"", service.ErrFileTypeMismatch), http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService.On("FinalizeVideoUpload", tt.videoID).Return(tt.video, tt.err)
+
+			req, _ := http.NewRequest("POST", fmt.Sprintf("/videos/%d/finalize", tt.videoID), nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.err == nil {
+				var resp response.VideoResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, int64(2048), resp.Video.FileSize)
+			}
+		})
+	}
+}
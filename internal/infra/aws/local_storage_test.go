@@ -0,0 +1,232 @@
+package aws
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupLocalStorage(t *testing.T) *LocalStorageClient {
+	client, err := NewLocalStorageClient(t.TempDir(), "http://localhost:8080/", "test-secret")
+	require.NoError(t, err)
+	return client
+}
+
+func parseSignedURL(t *testing.T, rawURL string) (string, url.Values) {
+	parsed, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return strings.TrimPrefix(parsed.Path, LocalStorageRoute+"/"), parsed.Query()
+}
+
+func TestLocalStorage_GeneratePresignedUploadURL(t *testing.T) {
+	client := setupLocalStorage(t)
+
+	rawURL, err := client.GeneratePresignedUploadURL("videos", "clip 1.mp4", "video/mp4")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rawURL, "http://localhost:8080/api/storage/videos/clip%201.mp4?"))
+
+	key, query := parseSignedURL(t, rawURL)
+	assert.Equal(t, "videos/clip 1.mp4", key)
+	assert.Equal(t, "video/mp4", query.Get(LocalParamContentType))
+	assert.NoError(t, client.VerifySignedRequest(http.MethodPut, key, query))
+
+	// An upload URL must not be usable for downloads or for another key
+	assert.ErrorIs(t, client.VerifySignedRequest(http.MethodGet, key, query), ErrInvalidSignature)
+	assert.ErrorIs(t, client.VerifySignedRequest(http.MethodPut, "videos/other.mp4", query), ErrInvalidSignature)
+
+	// The signed content type cannot be swapped
+	query.Set(LocalParamContentType, "text/html")
+	assert.ErrorIs(t, client.VerifySignedRequest(http.MethodPut, key, query), ErrInvalidSignature)
+}
+
+func TestLocalStorage_GeneratePresignedDownloadURL(t *testing.T) {
+	client := setupLocalStorage(t)
+
+	rawURL, err := client.GeneratePresignedDownloadURL("videos", "clip.mp4", DownloadURLOptions{
+		Expires:     time.Hour,
+		FileName:    "my clip.mp4",
+		ContentType: "application/octet-stream",
+	})
+	require.NoError(t, err)
+
+	key, query := parseSignedURL(t, rawURL)
+	assert.NoError(t, client.VerifySignedRequest(http.MethodGet, key, query))
+	assert.ErrorIs(t, client.VerifySignedRequest(http.MethodPut, key, query), ErrInvalidSignature)
+	assert.Equal(t, `attachment; filename="my clip.mp4"`, query.Get(LocalParamResponseContentDisposition))
+	assert.Equal(t, "application/octet-stream", query.Get(LocalParamResponseContentType))
+
+	expires, err := strconv.ParseInt(query.Get(LocalParamExpires), 10, 64)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), expires, 5)
+}
+
+func TestLocalStorage_VerifySignedRequest_Tampered(t *testing.T) {
+	client := setupLocalStorage(t)
+
+	rawURL, err := client.PresignURL(http.MethodGet, "avatars/a.jpg", nil, time.Minute)
+	require.NoError(t, err)
+	key, query := parseSignedURL(t, rawURL)
+
+	query.Set("expires", "9999999999")
+	assert.ErrorIs(t, client.VerifySignedRequest(http.MethodGet, key, query), ErrInvalidSignature)
+
+	other, err := NewLocalStorageClient(t.TempDir(), "", "another-secret")
+	require.NoError(t, err)
+	_, query = parseSignedURL(t, rawURL)
+	assert.ErrorIs(t, other.VerifySignedRequest(http.MethodGet, key, query), ErrInvalidSignature)
+}
+
+func TestLocalStorage_VerifySignedRequest_Expired(t *testing.T) {
+	client := setupLocalStorage(t)
+
+	rawURL, err := client.PresignURL(http.MethodGet, "avatars/a.jpg", nil, -time.Minute)
+	require.NoError(t, err)
+	key, query := parseSignedURL(t, rawURL)
+
+	assert.ErrorIs(t, client.VerifySignedRequest(http.MethodGet, key, query), ErrURLExpired)
+}
+
+func TestLocalStorage_UploadAndDeleteFile(t *testing.T) {
+	client := setupLocalStorage(t)
+
+	err := client.UploadFile("avatars/", "user.jpg", "image/jpeg", []byte("image-bytes"))
+	require.NoError(t, err)
+
+	path, err := client.ObjectPath("avatars/user.jpg")
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "image-bytes", string(data))
+
+	require.NoError(t, client.DeleteFile("avatars", "user.jpg"))
+	_, err = client.ObjectPath("avatars/user.jpg")
+	assert.True(t, os.IsNotExist(err))
+
+	// Deleting a missing object is not an error, matching S3 semantics
+	assert.NoError(t, client.DeleteFile("avatars", "user.jpg"))
+}
+
+func TestLocalStorage_RejectsKeysOutsideRoot(t *testing.T) {
+	client := setupLocalStorage(t)
+
+	for _, key := range []string{"", "../secret", "videos/../../etc/passwd", "videos//a.mp4", "./a.mp4"} {
+		_, err := client.PresignURL(http.MethodGet, key, nil, time.Minute)
+		assert.ErrorIs(t, err, ErrInvalidObjectKey, key)
+		assert.ErrorIs(t, client.WriteObject(key, "text/plain", strings.NewReader("x")), ErrInvalidObjectKey, key)
+	}
+}
+
+func TestLocalStorage_MultipartUpload(t *testing.T) {
+	client := setupLocalStorage(t)
+
+	uploadID, err := client.CreateMultipartUpload("videos", "big.mp4", "video/mp4")
+	require.NoError(t, err)
+
+	rawURL, err := client.GeneratePresignedUploadPartURL("videos", "big.mp4", uploadID, 2)
+	require.NoError(t, err)
+	key, query := parseSignedURL(t, rawURL)
+	assert.Equal(t, "videos/big.mp4", key)
+	assert.Equal(t, uploadID, query.Get(LocalParamUploadID))
+	assert.Equal(t, "2", query.Get(LocalParamPartNumber))
+	assert.NoError(t, client.VerifySignedRequest(http.MethodPut, key, query))
+
+	// The part number is covered by the signature
+	query.Set(LocalParamPartNumber, "3")
+	assert.ErrorIs(t, client.VerifySignedRequest(http.MethodPut, key, query), ErrInvalidSignature)
+
+	first := strings.Repeat("a", int(MinUploadPartSize))
+	etag1, err := client.WritePart(key, uploadID, 1, strings.NewReader(first))
+	require.NoError(t, err)
+	etag2, err := client.WritePart(key, uploadID, 2, strings.NewReader("tail"))
+	require.NoError(t, err)
+
+	// Parts cannot be written for another object than the upload was started for
+	_, err = client.WritePart("videos/other.mp4", uploadID, 1, strings.NewReader("x"))
+	assert.ErrorIs(t, err, ErrNoSuchUpload)
+
+	parts, err := client.ListUploadedParts("videos", "big.mp4", uploadID)
+	require.NoError(t, err)
+	assert.Equal(t, []UploadedPart{
+		{PartNumber: 1, ETag: etag1, Size: MinUploadPartSize},
+		{PartNumber: 2, ETag: etag2, Size: 4},
+	}, parts)
+
+	err = client.CompleteMultipartUpload("videos", "big.mp4", uploadID, []UploadedPart{{PartNumber: 1, ETag: etag2}, {PartNumber: 2, ETag: etag2}})
+	assert.ErrorIs(t, err, ErrInvalidPart)
+
+	err = client.CompleteMultipartUpload("videos", "big.mp4", uploadID, []UploadedPart{{PartNumber: 1, ETag: etag1}, {PartNumber: 2, ETag: etag2}})
+	require.NoError(t, err)
+
+	path, err := client.ObjectPath("videos/big.mp4")
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, first+"tail", string(data))
+
+	info, err := client.HeadObject("videos", "big.mp4")
+	require.NoError(t, err)
+	assert.Equal(t, "video/mp4", info.ContentType)
+	assert.True(t, strings.HasSuffix(info.ETag, `-2"`), info.ETag)
+
+	// The parts are removed once the upload is complete
+	_, err = client.ListUploadedParts("videos", "big.mp4", uploadID)
+	assert.ErrorIs(t, err, ErrNoSuchUpload)
+}
+
+func TestLocalStorage_MultipartUploadRejectsSmallParts(t *testing.T) {
+	client := setupLocalStorage(t)
+
+	uploadID, err := client.CreateMultipartUpload("videos", "clip.mp4", "video/mp4")
+	require.NoError(t, err)
+	etag1, err := client.WritePart("videos/clip.mp4", uploadID, 1, strings.NewReader("small"))
+	require.NoError(t, err)
+	etag2, err := client.WritePart("videos/clip.mp4", uploadID, 2, strings.NewReader("tail"))
+	require.NoError(t, err)
+
+	err = client.CompleteMultipartUpload("videos", "clip.mp4", uploadID, []UploadedPart{{PartNumber: 1, ETag: etag1}, {PartNumber: 2, ETag: etag2}})
+	assert.ErrorIs(t, err, ErrPartTooSmall)
+
+	require.NoError(t, client.AbortMultipartUpload("videos", "clip.mp4", uploadID))
+	_, err = client.ListUploadedParts("videos", "clip.mp4", uploadID)
+	assert.ErrorIs(t, err, ErrNoSuchUpload)
+
+	// The staging directory is not reachable through object keys
+	_, err = client.PresignURL(http.MethodGet, localMultipartDir+"/"+uploadID+"/key", nil, time.Minute)
+	assert.ErrorIs(t, err, ErrInvalidObjectKey)
+}
+
+func TestLocalStorage_HeadObject(t *testing.T) {
+	client := setupLocalStorage(t)
+
+	_, err := client.HeadObject("videos", "clip.mp4")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+
+	require.NoError(t, client.UploadFile("videos", "clip.mp4", "video/mp4", []byte("video-bytes")))
+	info, err := client.HeadObject("videos", "clip.mp4")
+	require.NoError(t, err)
+	assert.Equal(t, &ObjectInfo{Size: 11, ETag: `"a48430af7aa4e5de44c140e595ae2a9c"`, ContentType: "video/mp4"}, info)
+
+	// Files placed in the directory by hand are described from their extension and content
+	path := filepath.Join(client.RootDir, "videos", "manual.txt")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0o644))
+	info, err = client.HeadObject("videos", "manual.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), info.Size)
+	assert.Equal(t, `"5d41402abc4b2a76b9719d911017c592"`, info.ETag)
+	assert.True(t, strings.HasPrefix(info.ContentType, "text/plain"))
+
+	// Deleting the object removes its metadata too
+	require.NoError(t, client.DeleteFile("videos", "clip.mp4"))
+	_, err = client.HeadObject("videos", "clip.mp4")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+	_, err = client.readMetadata("videos/clip.mp4")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -4,5 +4,5 @@ import "github.com/google/wire"
 
 // ProviderSetAwsBucket is providers.
 var ProviderSetAwsBucket = wire.NewSet(
-	NewS3Client,
+	NewStorageClient,
 )
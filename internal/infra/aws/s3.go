@@ -3,10 +3,13 @@ package aws
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"mime"
 	"mlvt/internal/infra/env"
 	"mlvt/internal/infra/reason"
 	"mlvt/internal/infra/zap-logging/log"
+	"sort"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -16,10 +19,69 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// DefaultPresignExpiry is how long presigned URLs stay valid unless a caller asks otherwise
+const DefaultPresignExpiry = 15 * time.Minute
+
+// Limits of S3 multipart uploads, enforced by the local backend as well
+const (
+	MinUploadPartSize int64 = 5 << 20 // Every part except the last must be at least 5 MiB
+	MaxUploadParts          = 10000   // Part numbers range from 1 to 10000
+	MaxObjectSize     int64 = 5 << 40 // Largest object a multipart upload can produce (5 TiB)
+)
+
 type S3ClientInterface interface {
-	GeneratePresignedURL(folder string, fileName string, fileType string) (string, error)
+	GeneratePresignedUploadURL(folder string, fileName string, fileType string) (string, error)
+	GeneratePresignedDownloadURL(folder string, fileName string, opts DownloadURLOptions) (string, error)
 	UploadFile(folder string, fileName string, fileType string, fileData []byte) error
 	DeleteFile(folder string, fileName string) error
+	HeadObject(folder string, fileName string) (*ObjectInfo, error)
+
+	// Multipart uploads for large files
+	CreateMultipartUpload(folder string, fileName string, fileType string) (string, error)
+	GeneratePresignedUploadPartURL(folder string, fileName string, uploadID string, partNumber int32) (string, error)
+	ListUploadedParts(folder string, fileName string, uploadID string) ([]UploadedPart, error)
+	CompleteMultipartUpload(folder string, fileName string, uploadID string, parts []UploadedPart) error
+	AbortMultipartUpload(folder string, fileName string, uploadID string) error
+}
+
+// ErrObjectNotFound is returned by HeadObject when nothing is stored under the key
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo is the metadata of a stored object returned by HeadObject
+type ObjectInfo struct {
+	Size        int64
+	ETag        string
+	ContentType string
+}
+
+// UploadedPart describes one part of a multipart upload
+type UploadedPart struct {
+	PartNumber int32  `json:"part_number"`
+	ETag       string `json:"etag"`
+	Size       int64  `json:"size,omitempty"`
+}
+
+// DownloadURLOptions customises a presigned download (GET) URL
+type DownloadURLOptions struct {
+	Expires     time.Duration // Validity of the URL, DefaultPresignExpiry when zero
+	FileName    string        // Served as an attachment with this file name when set
+	ContentType string        // Overrides the Content-Type of the response when set
+}
+
+// expiry returns the requested expiry or the default one
+func (o DownloadURLOptions) expiry() time.Duration {
+	if o.Expires <= 0 {
+		return DefaultPresignExpiry
+	}
+	return o.Expires
+}
+
+// contentDisposition builds the Content-Disposition header for the requested file name
+func (o DownloadURLOptions) contentDisposition() string {
+	if o.FileName == "" {
+		return ""
+	}
+	return mime.FormatMediaType("attachment", map[string]string{"filename": o.FileName})
 }
 
 type S3Client struct {
@@ -49,8 +111,8 @@ func NewS3Client() (S3ClientInterface, error) {
 	return &S3Client{Client: client, Bucket: bucket}, nil
 }
 
-// GeneratePresignedURL generates a presigned URL for uploading a file to S3
-func (s *S3Client) GeneratePresignedURL(folder string, fileName string, fileType string) (string, error) {
+// GeneratePresignedUploadURL generates a presigned PUT URL for uploading a file to S3
+func (s *S3Client) GeneratePresignedUploadURL(folder string, fileName string, fileType string) (string, error) {
 	log.Info("Folder: ", folder, ", File name: ", fileName)
 	if fileName == "" {
 		return "", fmt.Errorf("file name must not be empty")
@@ -72,7 +134,7 @@ func (s *S3Client) GeneratePresignedURL(folder string, fileName string, fileType
 
 	// Use functional options to set the expiration time
 	presignReq, err := presignClient.PresignPutObject(context.TODO(), reqParams, func(o *s3.PresignOptions) {
-		o.Expires = 15 * time.Minute // Set the expiration time for the presigned URL
+		o.Expires = DefaultPresignExpiry // Set the expiration time for the presigned URL
 	})
 	if err != nil {
 		log.Error(reason.FailedToPresignPutObjectRequest.Message()+": ", err)
@@ -83,6 +145,42 @@ func (s *S3Client) GeneratePresignedURL(folder string, fileName string, fileType
 	return presignReq.URL, nil
 }
 
+// GeneratePresignedDownloadURL generates a presigned GET URL for downloading a file from S3
+func (s *S3Client) GeneratePresignedDownloadURL(folder string, fileName string, opts DownloadURLOptions) (string, error) {
+	if fileName == "" {
+		return "", fmt.Errorf("file name must not be empty")
+	}
+
+	// Combine folder and fileName to form the S3 key (path to the file)
+	fullPath := fileName
+	if folder != "" {
+		fullPath = folder + "/" + fileName
+	}
+
+	presignClient := s3.NewPresignClient(s.Client)
+
+	reqParams := &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(fullPath),
+	}
+	if disposition := opts.contentDisposition(); disposition != "" {
+		reqParams.ResponseContentDisposition = aws.String(disposition)
+	}
+	if opts.ContentType != "" {
+		reqParams.ResponseContentType = aws.String(opts.ContentType)
+	}
+
+	presignReq, err := presignClient.PresignGetObject(context.TODO(), reqParams, func(o *s3.PresignOptions) {
+		o.Expires = opts.expiry()
+	})
+	if err != nil {
+		log.Error(reason.FailedToPresignGetObjectRequest.Message()+": ", err)
+		return "", fmt.Errorf(reason.FailedToPresignGetObjectRequest.Message()+", %v", err)
+	}
+
+	return presignReq.URL, nil
+}
+
 // UploadFile uploads a file directly to S3
 func (s *S3Client) UploadFile(folder string, fileName string, fileType string, fileData []byte) error {
 	log.Info("Uploading file to folder: ", folder, ", file name: ", fileName)
@@ -153,3 +251,147 @@ func (s *S3Client) DeleteFile(folder string, fileName string) error {
 	log.Info("Successfully deleted file from S3: ", fullPath)
 	return nil
 }
+
+// HeadObject fetches the metadata of an object without downloading it
+func (s *S3Client) HeadObject(folder string, fileName string) (*ObjectInfo, error) {
+	if fileName == "" {
+		return nil, fmt.Errorf("file name must not be empty")
+	}
+
+	output, err := s.Client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(objectKey(folder, fileName)),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, ErrObjectNotFound
+		}
+		log.Errorf("failed to head object: %v", err)
+		return nil, fmt.Errorf("failed to head object: %v", err)
+	}
+
+	return &ObjectInfo{
+		Size:        aws.ToInt64(output.ContentLength),
+		ETag:        aws.ToString(output.ETag),
+		ContentType: aws.ToString(output.ContentType),
+	}, nil
+}
+
+// CreateMultipartUpload starts a multipart upload for the file and returns its upload ID
+func (s *S3Client) CreateMultipartUpload(folder string, fileName string, fileType string) (string, error) {
+	if fileName == "" {
+		return "", fmt.Errorf("file name must not be empty")
+	}
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(objectKey(folder, fileName)),
+		ContentType: aws.String(fileType),
+		ACL:         types.ObjectCannedACLPrivate,
+	}
+
+	output, err := s.Client.CreateMultipartUpload(context.TODO(), input)
+	if err != nil {
+		log.Errorf("failed to create multipart upload: %v", err)
+		return "", fmt.Errorf("failed to create multipart upload: %v", err)
+	}
+
+	log.Infof("multipart upload created: %s", aws.ToString(output.UploadId))
+	return aws.ToString(output.UploadId), nil
+}
+
+// GeneratePresignedUploadPartURL generates a presigned PUT URL for uploading one part of a multipart upload
+func (s *S3Client) GeneratePresignedUploadPartURL(folder string, fileName string, uploadID string, partNumber int32) (string, error) {
+	if fileName == "" || uploadID == "" {
+		return "", fmt.Errorf("file name and upload ID must not be empty")
+	}
+
+	presignClient := s3.NewPresignClient(s.Client)
+
+	reqParams := &s3.UploadPartInput{
+		Bucket:     aws.String(s.Bucket),
+		Key:        aws.String(objectKey(folder, fileName)),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(partNumber),
+	}
+
+	presignReq, err := presignClient.PresignUploadPart(context.TODO(), reqParams, func(o *s3.PresignOptions) {
+		o.Expires = DefaultPresignExpiry
+	})
+	if err != nil {
+		log.Error(reason.FailedToPresignPutObjectRequest.Message()+": ", err)
+		return "", fmt.Errorf(reason.FailedToPresignPutObjectRequest.Message()+", %v", err)
+	}
+
+	return presignReq.URL, nil
+}
+
+// ListUploadedParts lists the parts already stored for a multipart upload, ordered by part number
+func (s *S3Client) ListUploadedParts(folder string, fileName string, uploadID string) ([]UploadedPart, error) {
+	paginator := s3.NewListPartsPaginator(s.Client, &s3.ListPartsInput{
+		Bucket:   aws.String(s.Bucket),
+		Key:      aws.String(objectKey(folder, fileName)),
+		UploadId: aws.String(uploadID),
+	})
+
+	parts := []UploadedPart{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			log.Errorf("failed to list uploaded parts: %v", err)
+			return nil, fmt.Errorf("failed to list uploaded parts: %v", err)
+		}
+		for _, part := range page.Parts {
+			parts = append(parts, UploadedPart{
+				PartNumber: aws.ToInt32(part.PartNumber),
+				ETag:       aws.ToString(part.ETag),
+				Size:       aws.ToInt64(part.Size),
+			})
+		}
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts into the final object
+func (s *S3Client) CompleteMultipartUpload(folder string, fileName string, uploadID string, parts []UploadedPart) error {
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, types.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int32(part.PartNumber),
+		})
+	}
+
+	_, err := s.Client.CompleteMultipartUpload(context.TODO(), &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.Bucket),
+		Key:             aws.String(objectKey(folder, fileName)),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		log.Errorf("failed to complete multipart upload: %v", err)
+		return fmt.Errorf("failed to complete multipart upload: %v", err)
+	}
+
+	log.Infof("multipart upload completed: %s", objectKey(folder, fileName))
+	return nil
+}
+
+// AbortMultipartUpload aborts a multipart upload and discards its uploaded parts
+func (s *S3Client) AbortMultipartUpload(folder string, fileName string, uploadID string) error {
+	_, err := s.Client.AbortMultipartUpload(context.TODO(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.Bucket),
+		Key:      aws.String(objectKey(folder, fileName)),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		log.Errorf("failed to abort multipart upload: %v", err)
+		return fmt.Errorf("failed to abort multipart upload: %v", err)
+	}
+
+	log.Infof("multipart upload aborted: %s", uploadID)
+	return nil
+}
@@ -9,7 +9,54 @@ type MockS3Client struct {
 	mock.Mock
 }
 
-func (m *MockS3Client) GeneratePresignedURL(folder string, fileName string, fileType string) (string, error) {
+func (m *MockS3Client) GeneratePresignedUploadURL(folder string, fileName string, fileType string) (string, error) {
 	args := m.Called(folder, fileName, fileType)
 	return args.String(0), args.Error(1)
 }
+
+func (m *MockS3Client) GeneratePresignedDownloadURL(folder string, fileName string, opts DownloadURLOptions) (string, error) {
+	args := m.Called(folder, fileName, opts)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockS3Client) UploadFile(folder string, fileName string, fileType string, fileData []byte) error {
+	args := m.Called(folder, fileName, fileType, fileData)
+	return args.Error(0)
+}
+
+func (m *MockS3Client) DeleteFile(folder string, fileName string) error {
+	args := m.Called(folder, fileName)
+	return args.Error(0)
+}
+
+func (m *MockS3Client) HeadObject(folder string, fileName string) (*ObjectInfo, error) {
+	args := m.Called(folder, fileName)
+	info, _ := args.Get(0).(*ObjectInfo)
+	return info, args.Error(1)
+}
+
+func (m *MockS3Client) CreateMultipartUpload(folder string, fileName string, fileType string) (string, error) {
+	args := m.Called(folder, fileName, fileType)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockS3Client) GeneratePresignedUploadPartURL(folder string, fileName string, uploadID string, partNumber int32) (string, error) {
+	args := m.Called(folder, fileName, uploadID, partNumber)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockS3Client) ListUploadedParts(folder string, fileName string, uploadID string) ([]UploadedPart, error) {
+	args := m.Called(folder, fileName, uploadID)
+	parts, _ := args.Get(0).([]UploadedPart)
+	return parts, args.Error(1)
+}
+
+func (m *MockS3Client) CompleteMultipartUpload(folder string, fileName string, uploadID string, parts []UploadedPart) error {
+	args := m.Called(folder, fileName, uploadID, parts)
+	return args.Error(0)
+}
+
+func (m *MockS3Client) AbortMultipartUpload(folder string, fileName string, uploadID string) error {
+	args := m.Called(folder, fileName, uploadID)
+	return args.Error(0)
+}
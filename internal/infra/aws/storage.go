@@ -0,0 +1,23 @@
+package aws
+
+import (
+	"fmt"
+	"mlvt/internal/infra/env"
+)
+
+// NewStorageClient returns the storage backend selected by STORAGE_DRIVER.
+// It defaults to S3 so existing deployments keep their behaviour.
+func NewStorageClient() (S3ClientInterface, error) {
+	switch env.EnvConfig.StorageDriver {
+	case "", StorageDriverS3:
+		return NewS3Client()
+	case StorageDriverLocal:
+		secret := env.EnvConfig.LocalStorageSecret
+		if secret == "" {
+			secret = env.EnvConfig.JWTSecret
+		}
+		return NewLocalStorageClient(env.EnvConfig.LocalStoragePath, env.EnvConfig.LocalStorageBaseURL, secret)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", env.EnvConfig.StorageDriver)
+	}
+}
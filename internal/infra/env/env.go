@@ -3,7 +3,9 @@ package env
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"mlvt/internal/infra/zap-logging/log"
 
@@ -15,33 +17,112 @@ var (
 	mu        sync.RWMutex
 )
 
-const defaultEnvFilePath = ".env"
+const (
+	defaultEnvFilePath             = ".env"
+	defaultLocalStoragePath        = "storage"
+	defaultPasswordResetURL        = "http://localhost:3000/reset-password"
+	defaultOrgInviteURL            = "http://localhost:3000/accept-invite"
+	defaultMoMoEndpoint            = "https://test-payment.momo.vn" // MoMo's sandbox
+	defaultMoMoRedirectURL         = "http://localhost:3000/payment-result"
+	defaultMoMoRequestType         = "captureWallet"
+	defaultMoMoLang                = "vi"
+	defaultMoMoTimeout             = 30 * time.Second
+	defaultVNPayPayURL             = "https://sandbox.vnpayment.vn/paymentv2/vpcpay.html"           // VNPay's sandbox
+	defaultVNPayAPIURL             = "https://sandbox.vnpayment.vn/merchant_webapi/api/transaction" // VNPay's sandbox
+	defaultVNPayReturnURL          = "http://localhost:3000/payment-result"
+	defaultVNPayLocale             = "vn"
+	defaultVNPayExpireAfter        = 15 * time.Minute
+	defaultVNPayTimeout            = 30 * time.Second
+	defaultSubscriptionGracePeriod = 72 * time.Hour
+	defaultSubscriptionCheckEvery  = time.Hour
+)
 
 // Config holds all the environment variables used in the application.
 type Config struct {
-	AppName              string
-	AppEnv               string
-	AppDebug             bool
-	ServerPort           string
-	LogLevel             string
-	LogPath              string
-	DBDriver             string
-	DBConnection         string
-	JWTSecret            string
-	SwaggerEnabled       bool
-	SwaggerURL           string
-	AWSRegion            string
-	AWSBucket            string
-	AWSAccessKeyID       string
-	AWSSecretKey         string
-	AudioFolder          string
-	AvatarFolder         string
-	VideosFolder         string
-	TranscriptionsFolder string
-	VideoFramesFolder    string
-	Language             string
-	I18NPath             string
-	RootDir              string
+	AppName                  string
+	AppEnv                   string
+	AppDebug                 bool
+	ServerPort               string
+	LogLevel                 string
+	LogPath                  string
+	DBDriver                 string
+	DBConnection             string
+	JWTSecret                string
+	SwaggerEnabled           bool
+	SwaggerURL               string
+	AWSRegion                string
+	AWSBucket                string
+	AWSAccessKeyID           string
+	AWSSecretKey             string
+	StorageDriver            string
+	LocalStoragePath         string
+	LocalStorageBaseURL      string
+	LocalStorageSecret       string
+	AudioFolder              string
+	AvatarFolder             string
+	VideosFolder             string
+	TranscriptionsFolder     string
+	VideoFramesFolder        string
+	Language                 string
+	I18NPath                 string
+	RootDir                  string
+	WorkerConcurrency        int            // Number of background jobs processed at the same time
+	JobVisibilityTimeout     time.Duration  // How long a leased job stays hidden from other workers without a heartbeat
+	JobPollInterval          time.Duration  // Wait between polls of an empty job queue
+	WorkerRegistrationSecret string         // Shared secret external ML workers register with; registration is disabled when empty
+	AppBaseURL               string         // Public base URL of this server, used in the links mailed to users
+	PasswordResetURL         string         // Frontend page the mailed password reset link opens, with ?token= appended
+	OrgInviteURL             string         // Frontend page the mailed organization invitation link opens, with ?token= appended
+	MailDriver               string         // How emails are sent: smtp, file or log
+	MailFrom                 string         // Sender address of the emails
+	MailDir                  string         // Directory the file mailer writes emails to
+	SMTPHost                 string         // SMTP server used by the smtp mailer
+	SMTPPort                 int            // Port of the SMTP server
+	SMTPUsername             string         // SMTP user; no authentication when empty
+	SMTPPassword             string         // SMTP password
+	LoginAttemptStore        string         // Where failed logins are counted: sql, or memory for a single instance
+	TrustedProxies           []string       // Proxies whose X-Forwarded-For header gives the client IP; none when empty
+	OIDCProviders            []OIDCProvider // Identity providers users may log in with; none when empty
+	MoMo                     MoMoConfig     // MoMo payment gateway
+	VNPay                    VNPayConfig    // VNPay payment gateway
+	SubscriptionGracePeriod  time.Duration  // How long premium is kept after a subscription period ends unpaid
+	SubscriptionCheckEvery   time.Duration  // Interval of the check that moves ended subscriptions back to free
+}
+
+// MoMoConfig configures the MoMo payment gateway, read from MOMO_*
+type MoMoConfig struct {
+	Endpoint    string        // MOMO_ENDPOINT, base URL of the API; the sandbox when empty
+	PartnerCode string        // MOMO_PARTNER_CODE
+	AccessKey   string        // MOMO_ACCESS_KEY
+	SecretKey   string        // MOMO_SECRET_KEY, signs the requests
+	RedirectURL string        // MOMO_REDIRECT_URL, page MoMo sends the user to after paying
+	IPNURL      string        // MOMO_IPN_URL, where MoMo notifies this server; the notification route of this server when empty
+	RequestType string        // MOMO_REQUEST_TYPE, captureWallet when empty
+	Lang        string        // MOMO_LANG, vi or en, for the messages MoMo answers with
+	Timeout     time.Duration // MOMO_TIMEOUT for each request, 30s when unset
+}
+
+// VNPayConfig configures the VNPay payment gateway, read from VNPAY_*. VNPay sends its notifications to the
+// IPN URL registered for the terminal, which is /api/payments/vnpay/ipn on this server.
+type VNPayConfig struct {
+	PayURL      string        // VNPAY_PAY_URL, payment page; the sandbox when empty
+	APIURL      string        // VNPAY_API_URL, query and refund API; the sandbox when empty
+	TmnCode     string        // VNPAY_TMN_CODE, terminal code of the merchant
+	HashSecret  string        // VNPAY_HASH_SECRET, signs the requests
+	ReturnURL   string        // VNPAY_RETURN_URL, page VNPay sends the user to after paying
+	Locale      string        // VNPAY_LOCALE, vn or en, for the payment page
+	ExpireAfter time.Duration // VNPAY_EXPIRE_AFTER, how long the payment page stays valid, 15m when unset
+	Timeout     time.Duration // VNPAY_TIMEOUT for each request, 30s when unset
+}
+
+// OIDCProvider configures login with an OpenID Connect provider, read from OIDC_<NAME>_* for each name in OIDC_PROVIDERS
+type OIDCProvider struct {
+	Name         string   // Lowercase, used in the login and callback URLs
+	Issuer       string   // OIDC_<NAME>_ISSUER, e.g. https://accounts.google.com
+	ClientID     string   // OIDC_<NAME>_CLIENT_ID
+	ClientSecret string   // OIDC_<NAME>_CLIENT_SECRET
+	RedirectURL  string   // OIDC_<NAME>_REDIRECT_URL; the callback route of this server when empty
+	Scopes       []string // OIDC_<NAME>_SCOPES, comma-separated; email and profile when empty
 }
 
 // init loads the environment variables at startup
@@ -91,30 +172,165 @@ func initializeConfig() error {
 	i18nPath := resolvePath(rootDir, viper.GetString("I18N_PATH"))
 	dbPath := resolvePath(rootDir, viper.GetString("DB_CONNECTION"))
 
+	// Local storage falls back to ./storage served by this server
+	localStoragePath := viper.GetString("LOCAL_STORAGE_PATH")
+	if localStoragePath == "" {
+		localStoragePath = defaultLocalStoragePath
+	}
+	localStoragePath = resolvePath(rootDir, localStoragePath)
+	localStorageBaseURL := viper.GetString("LOCAL_STORAGE_BASE_URL")
+	if localStorageBaseURL == "" {
+		localStorageBaseURL = "http://localhost:" + viper.GetString("SERVER_PORT")
+	}
+
+	appBaseURL := viper.GetString("APP_BASE_URL")
+	if appBaseURL == "" {
+		appBaseURL = "http://localhost:" + viper.GetString("SERVER_PORT")
+	}
+	passwordResetURL := viper.GetString("PASSWORD_RESET_URL")
+	if passwordResetURL == "" {
+		passwordResetURL = defaultPasswordResetURL
+	}
+	orgInviteURL := viper.GetString("ORG_INVITE_URL")
+	if orgInviteURL == "" {
+		orgInviteURL = defaultOrgInviteURL
+	}
+	var oidcProviders []OIDCProvider
+	for _, name := range splitList(viper.GetString("OIDC_PROVIDERS")) {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		redirectURL := viper.GetString(prefix + "REDIRECT_URL")
+		if redirectURL == "" {
+			redirectURL = appBaseURL + "/api/users/oidc/" + name + "/callback"
+		}
+		oidcProviders = append(oidcProviders, OIDCProvider{
+			Name:         name,
+			Issuer:       viper.GetString(prefix + "ISSUER"),
+			ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+			RedirectURL:  redirectURL,
+			Scopes:       splitList(viper.GetString(prefix + "SCOPES")),
+		})
+	}
+	momo := MoMoConfig{
+		Endpoint:    strings.TrimRight(viper.GetString("MOMO_ENDPOINT"), "/"),
+		PartnerCode: viper.GetString("MOMO_PARTNER_CODE"),
+		AccessKey:   viper.GetString("MOMO_ACCESS_KEY"),
+		SecretKey:   viper.GetString("MOMO_SECRET_KEY"),
+		RedirectURL: viper.GetString("MOMO_REDIRECT_URL"),
+		IPNURL:      viper.GetString("MOMO_IPN_URL"),
+		RequestType: viper.GetString("MOMO_REQUEST_TYPE"),
+		Lang:        viper.GetString("MOMO_LANG"),
+		Timeout:     viper.GetDuration("MOMO_TIMEOUT"),
+	}
+	if momo.Endpoint == "" {
+		momo.Endpoint = defaultMoMoEndpoint
+	}
+	if momo.RedirectURL == "" {
+		momo.RedirectURL = defaultMoMoRedirectURL
+	}
+	if momo.IPNURL == "" {
+		momo.IPNURL = appBaseURL + "/api/payments/momo/ipn"
+	}
+	if momo.RequestType == "" {
+		momo.RequestType = defaultMoMoRequestType
+	}
+	if momo.Lang == "" {
+		momo.Lang = defaultMoMoLang
+	}
+	if momo.Timeout <= 0 {
+		momo.Timeout = defaultMoMoTimeout
+	}
+	vnpay := VNPayConfig{
+		PayURL:      viper.GetString("VNPAY_PAY_URL"),
+		APIURL:      viper.GetString("VNPAY_API_URL"),
+		TmnCode:     viper.GetString("VNPAY_TMN_CODE"),
+		HashSecret:  viper.GetString("VNPAY_HASH_SECRET"),
+		ReturnURL:   viper.GetString("VNPAY_RETURN_URL"),
+		Locale:      viper.GetString("VNPAY_LOCALE"),
+		ExpireAfter: viper.GetDuration("VNPAY_EXPIRE_AFTER"),
+		Timeout:     viper.GetDuration("VNPAY_TIMEOUT"),
+	}
+	if vnpay.PayURL == "" {
+		vnpay.PayURL = defaultVNPayPayURL
+	}
+	if vnpay.APIURL == "" {
+		vnpay.APIURL = defaultVNPayAPIURL
+	}
+	if vnpay.ReturnURL == "" {
+		vnpay.ReturnURL = defaultVNPayReturnURL
+	}
+	if vnpay.Locale == "" {
+		vnpay.Locale = defaultVNPayLocale
+	}
+	if vnpay.ExpireAfter <= 0 {
+		vnpay.ExpireAfter = defaultVNPayExpireAfter
+	}
+	if vnpay.Timeout <= 0 {
+		vnpay.Timeout = defaultVNPayTimeout
+	}
+	gracePeriod := viper.GetDuration("SUBSCRIPTION_GRACE_PERIOD")
+	if viper.GetString("SUBSCRIPTION_GRACE_PERIOD") == "" || gracePeriod < 0 {
+		gracePeriod = defaultSubscriptionGracePeriod
+	}
+	checkEvery := viper.GetDuration("SUBSCRIPTION_CHECK_INTERVAL")
+	if checkEvery <= 0 {
+		checkEvery = defaultSubscriptionCheckEvery
+	}
+	mailDir := viper.GetString("MAIL_DIR")
+	if mailDir != "" {
+		mailDir = resolvePath(rootDir, mailDir)
+	}
+
 	EnvConfig = &Config{
-		AppName:              viper.GetString("APP_NAME"),
-		AppEnv:               viper.GetString("APP_ENV"),
-		AppDebug:             viper.GetBool("APP_DEBUG"),
-		ServerPort:           viper.GetString("SERVER_PORT"),
-		LogLevel:             viper.GetString("LOG_LEVEL"),
-		LogPath:              logPath,
-		DBDriver:             viper.GetString("DB_DRIVER"),
-		DBConnection:         dbPath,
-		JWTSecret:            viper.GetString("JWT_SECRET"),
-		SwaggerEnabled:       viper.GetBool("SWAGGER_ENABLED"),
-		SwaggerURL:           viper.GetString("SWAGGER_URL"),
-		AWSRegion:            viper.GetString("AWS_REGION"),
-		AWSBucket:            viper.GetString("AWS_BUCKET"),
-		AWSAccessKeyID:       viper.GetString("AWS_ACCESS_KEY_ID"),
-		AWSSecretKey:         viper.GetString("AWS_SECRET_KEY"),
-		Language:             viper.GetString("LANGUAGE"),
-		AudioFolder:          viper.GetString("AUDIO_FOLDER"),
-		AvatarFolder:         viper.GetString("AVATAR_FOLDER"),
-		VideosFolder:         viper.GetString("VIDEOS_FOLDER"),
-		TranscriptionsFolder: viper.GetString("TRANSCRIPTIONS_FOLDER"),
-		VideoFramesFolder:    viper.GetString("VIDEO_FRAMES_FOLDER"),
-		I18NPath:             i18nPath,
-		RootDir:              rootDir,
+		AppName:                  viper.GetString("APP_NAME"),
+		AppEnv:                   viper.GetString("APP_ENV"),
+		AppDebug:                 viper.GetBool("APP_DEBUG"),
+		ServerPort:               viper.GetString("SERVER_PORT"),
+		LogLevel:                 viper.GetString("LOG_LEVEL"),
+		LogPath:                  logPath,
+		DBDriver:                 viper.GetString("DB_DRIVER"),
+		DBConnection:             dbPath,
+		JWTSecret:                viper.GetString("JWT_SECRET"),
+		SwaggerEnabled:           viper.GetBool("SWAGGER_ENABLED"),
+		SwaggerURL:               viper.GetString("SWAGGER_URL"),
+		AWSRegion:                viper.GetString("AWS_REGION"),
+		AWSBucket:                viper.GetString("AWS_BUCKET"),
+		AWSAccessKeyID:           viper.GetString("AWS_ACCESS_KEY_ID"),
+		AWSSecretKey:             viper.GetString("AWS_SECRET_KEY"),
+		StorageDriver:            viper.GetString("STORAGE_DRIVER"),
+		LocalStoragePath:         localStoragePath,
+		LocalStorageBaseURL:      localStorageBaseURL,
+		LocalStorageSecret:       viper.GetString("LOCAL_STORAGE_SECRET"),
+		Language:                 viper.GetString("LANGUAGE"),
+		AudioFolder:              viper.GetString("AUDIO_FOLDER"),
+		AvatarFolder:             viper.GetString("AVATAR_FOLDER"),
+		VideosFolder:             viper.GetString("VIDEOS_FOLDER"),
+		TranscriptionsFolder:     viper.GetString("TRANSCRIPTIONS_FOLDER"),
+		VideoFramesFolder:        viper.GetString("VIDEO_FRAMES_FOLDER"),
+		I18NPath:                 i18nPath,
+		RootDir:                  rootDir,
+		WorkerConcurrency:        viper.GetInt("WORKER_CONCURRENCY"),
+		JobVisibilityTimeout:     viper.GetDuration("JOB_VISIBILITY_TIMEOUT"),
+		JobPollInterval:          viper.GetDuration("JOB_POLL_INTERVAL"),
+		WorkerRegistrationSecret: viper.GetString("WORKER_REGISTRATION_SECRET"),
+		AppBaseURL:               appBaseURL,
+		PasswordResetURL:         passwordResetURL,
+		OrgInviteURL:             orgInviteURL,
+		MailDriver:               viper.GetString("MAIL_DRIVER"),
+		MailFrom:                 viper.GetString("MAIL_FROM"),
+		MailDir:                  mailDir,
+		SMTPHost:                 viper.GetString("SMTP_HOST"),
+		SMTPPort:                 viper.GetInt("SMTP_PORT"),
+		SMTPUsername:             viper.GetString("SMTP_USERNAME"),
+		SMTPPassword:             viper.GetString("SMTP_PASSWORD"),
+		LoginAttemptStore:        viper.GetString("LOGIN_ATTEMPT_STORE"),
+		TrustedProxies:           splitList(viper.GetString("TRUSTED_PROXIES")),
+		OIDCProviders:            oidcProviders,
+		MoMo:                     momo,
+		VNPay:                    vnpay,
+		SubscriptionGracePeriod:  gracePeriod,
+		SubscriptionCheckEvery:   checkEvery,
 	}
 
 	if EnvConfig.JWTSecret == "" {
@@ -123,6 +339,17 @@ func initializeConfig() error {
 	return nil
 }
 
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // getProjectRootDir returns the root directory of the project
 func getProjectRootDir() (string, error) {
 	// Set your own root go manage the .env
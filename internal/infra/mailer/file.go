@@ -0,0 +1,108 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"mlvt/internal/infra/zap-logging/log"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// FileMailer writes each email to a .eml file instead of sending it, for development and tests
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer creates a mailer writing emails to dir, creating it when missing
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if dir == "" {
+		return nil, errors.New("MAIL_DIR must be set for the file mailer")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %v", err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send writes the message to <dir>/<time>-<random>.eml, so the files sort in the order they were sent
+func (m *FileMailer) Send(msg Message) error {
+	now := time.Now()
+	data, err := formatMessage(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write email to %s: %v", msg.To, err)
+	}
+	return nil
+}
+
+// Messages reads back the emails written so far, oldest first
+func (m *FileMailer) Messages() ([]Message, error) {
+	names, err := filepath.Glob(filepath.Join(m.dir, "*.eml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	messages := make([]Message, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := parseMessage(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", filepath.Base(name), err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// parseMessage decodes an email rendered by formatMessage
+func parseMessage(data []byte) (Message, error) {
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return Message{}, err
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		return Message{}, err
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{To: parsed.Header.Get("To"), Subject: subject, Body: string(body)}, nil
+}
+
+// LogMailer writes emails to the application log instead of sending them
+type LogMailer struct{}
+
+// NewLogMailer creates a mailer writing emails to the log
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+// Send logs the recipient, subject and body of the message
+func (m *LogMailer) Send(msg Message) error {
+	log.Infof("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
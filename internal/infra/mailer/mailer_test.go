@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailer(t *testing.T) {
+	fileMailer, err := NewFileMailer(t.TempDir(), "noreply@example.com")
+	require.NoError(t, err)
+
+	link := "https://example.com/api/users/verify-email?token=" + strings.Repeat("a", 100)
+	require.NoError(t, fileMailer.Send(Message{To: "john@example.com", Subject: "Xác minh email", Body: "Xin chào John,\n" + link}))
+	require.NoError(t, fileMailer.Send(Message{To: "jane@example.com", Subject: "Reset", Body: "Hello"}))
+
+	messages, err := fileMailer.Messages()
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Equal(t, "john@example.com", messages[0].To)
+	assert.Equal(t, "Xác minh email", messages[0].Subject, "non-ASCII subjects survive encoding")
+	assert.Contains(t, messages[0].Body, link, "long lines are not broken")
+	assert.Equal(t, "jane@example.com", messages[1].To)
+
+	_, err = NewFileMailer("", "noreply@example.com")
+	assert.Error(t, err)
+}
+
+func TestFormatMessage(t *testing.T) {
+	msg := Message{To: "john@example.com", Subject: "パスワードのリセット", Body: "こんにちは"}
+	data, err := formatMessage("noreply@example.com", msg, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "From: noreply@example.com\r\n")
+	assert.Contains(t, string(data), "Content-Type: text/plain; charset=UTF-8\r\n")
+	assert.NotContains(t, string(data), msg.Subject, "the subject is encoded")
+
+	parsed, err := parseMessage(data)
+	require.NoError(t, err)
+	assert.Equal(t, msg, parsed)
+}
+
+func TestNewSMTPMailer(t *testing.T) {
+	_, err := NewSMTPMailer("", 587, "", "", "noreply@example.com")
+	assert.Error(t, err)
+
+	smtpMailer, err := NewSMTPMailer("smtp.example.com", 587, "user", "pass", "noreply@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "smtp.example.com:587", smtpMailer.addr)
+	assert.NotNil(t, smtpMailer.auth)
+}
@@ -0,0 +1,28 @@
+package mailer
+
+import (
+	"fmt"
+	"mlvt/internal/infra/env"
+
+	"github.com/google/wire"
+)
+
+// ProviderSetMailer is providers.
+var ProviderSetMailer = wire.NewSet(
+	NewMailer,
+)
+
+// NewMailer returns the mailer selected by MAIL_DRIVER. It defaults to logging emails, so development
+// setups work without a mail server.
+func NewMailer() (Mailer, error) {
+	switch env.EnvConfig.MailDriver {
+	case "", MailDriverLog:
+		return NewLogMailer(), nil
+	case MailDriverFile:
+		return NewFileMailer(env.EnvConfig.MailDir, env.EnvConfig.MailFrom)
+	case MailDriverSMTP:
+		return NewSMTPMailer(env.EnvConfig.SMTPHost, env.EnvConfig.SMTPPort, env.EnvConfig.SMTPUsername, env.EnvConfig.SMTPPassword, env.EnvConfig.MailFrom)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", env.EnvConfig.MailDriver)
+	}
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPMailer sends emails through an SMTP server
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer creates a mailer for the SMTP server at host:port. It authenticates only when username is set.
+func NewSMTPMailer(host string, port int, username, password, from string) (*SMTPMailer, error) {
+	if host == "" || from == "" {
+		return nil, errors.New("SMTP_HOST and MAIL_FROM must be set for the smtp mailer")
+	}
+	mailer := &SMTPMailer{
+		addr: host + ":" + strconv.Itoa(port),
+		from: from,
+	}
+	if username != "" {
+		mailer.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return mailer, nil
+}
+
+// Send delivers the message to the SMTP server
+func (m *SMTPMailer) Send(msg Message) error {
+	data, err := formatMessage(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("failed to send email to %s: %v", msg.To, err)
+	}
+	return nil
+}
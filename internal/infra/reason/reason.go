@@ -18,6 +18,7 @@ var (
 	InvalidToken             localization.LocalizedString = "error.user.invalid_token"
 	InvalidTokenClaims       localization.LocalizedString = "error.user.invalid_token_claims"
 	InvalidUserIDTypeInToken localization.LocalizedString = "error.user.invalid_userid_type_in_token"
+	TooManyLoginAttempts     localization.LocalizedString = "error.user.too_many_login_attempts" // Takes the seconds to wait
 
 	// Error messages under 'error.video'
 	VideoInvalidRequest         localization.LocalizedString = "error.video.invalid_request"
@@ -38,6 +39,7 @@ var (
 	FailedToCreatePresignedURL      localization.LocalizedString = "error.data.failed_to_create_presigned_url"
 	FailedToGeneratePresignedURL    localization.LocalizedString = "error.data.failed_to_generate_presigned_url"
 	FailedToPresignPutObjectRequest localization.LocalizedString = "error.data.failed_to_presign_put_object_request"
+	FailedToPresignGetObjectRequest localization.LocalizedString = "error.data.failed_to_presign_get_object_request"
 	RequestFormatError              localization.LocalizedString = "error.data.request_format_error"
 	ErrorReadingYAMLFile            localization.LocalizedString = "error.data.error_reading_yaml"
 	ErrorUnmarshalingYAMLData       localization.LocalizedString = "error.data.error_unmarshaling_yaml"
@@ -86,4 +88,13 @@ var (
 	LoadedMessagesForLanguage    localization.LocalizedString = "common.info.loaded_messages_for_language"
 	ServerShutdown               localization.LocalizedString = "common.info.server_shutdown"
 	ServerForcedShutdown         localization.LocalizedString = "common.info.server_forced_shutdown"
+
+	// Emails under 'email'; the bodies take the first name of the user and the link
+	VerifyEmailSubject   localization.LocalizedString = "email.verify_email.subject"
+	VerifyEmailBody      localization.LocalizedString = "email.verify_email.body"
+	ResetPasswordSubject localization.LocalizedString = "email.reset_password.subject"
+	ResetPasswordBody    localization.LocalizedString = "email.reset_password.body"
+	// The invitation subject takes the name of the organization; its body the inviter, the organization and the link
+	OrgInviteSubject localization.LocalizedString = "email.org_invite.subject"
+	OrgInviteBody    localization.LocalizedString = "email.org_invite.body"
 )
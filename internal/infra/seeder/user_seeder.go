@@ -92,6 +92,8 @@ func (s *UserSeeder) SeedUsersFromFolder(imagesFolder string) error {
 			AvatarFolder: "", // To be updated after upload
 			CreatedAt:    time.Now(),
 			UpdatedAt:    time.Now(),
+			// Seeded accounts can log in without verifying their email address
+			EmailVerified: true,
 		}
 
 		// Insert user into the database
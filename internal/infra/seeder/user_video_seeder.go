@@ -113,6 +113,8 @@ func (s *UserVideoSeeder) SeedUsersFromFolder(avatarsFolder string) error {
 			AvatarFolder: "", // To be updated after upload
 			CreatedAt:    time.Now(),
 			UpdatedAt:    time.Now(),
+			// Seeded accounts can log in without verifying their email address
+			EmailVerified: true,
 		}
 
 		// Upload avatar to S3
@@ -6,12 +6,12 @@ import (
 	"mlvt/internal/infra/zap-logging/log"
 )
 
-// InitAWS initializes AWS services like S3.
+// InitAWS initializes the storage backend selected in the configuration (S3 or local disk).
 func InitAWS() (aws.S3ClientInterface, error) {
-	s3Client, err := aws.NewS3Client()
+	s3Client, err := aws.NewStorageClient()
 	if err != nil {
-		log.Errorf("Failed to initialize AWS S3 client: %v", err)
-		return nil, fmt.Errorf("failed to initialize AWS S3 client: %w", err)
+		log.Errorf("Failed to initialize storage client: %v", err)
+		return nil, fmt.Errorf("failed to initialize storage client: %w", err)
 	}
 	return s3Client, nil
 }
@@ -1,14 +1,19 @@
 package initialize
 
 import (
+	"context"
 	"fmt"
 	"mlvt/internal/infra/zap-logging/log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
+// workerShutdownTimeout bounds how long running jobs may take to finish on shutdown
+const workerShutdownTimeout = 30 * time.Second
+
 // Run initializes the application and starts the server.
 // It encapsulates all initialization logic and handles graceful shutdown.
 func Run() {
@@ -44,18 +49,35 @@ func Run() {
 		os.Exit(1)
 	}
 
+	// Initialize Worker Pool
+	workerPool, err := InitWorkerPool(dbConn)
+	if err != nil {
+		log.Errorf("Worker pool initialization failed: %v", err)
+		os.Exit(1)
+	}
+	workerPool.Start()
+
 	// Initialize Server
 	server := InitServer(appRouter)
 
 	// Handle graceful shutdown
 	quit := make(chan os.Signal, 1)
+	shutdownDone := make(chan struct{})
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
+		defer close(shutdownDone)
 		sig := <-quit
 		log.Infof("Received signal '%v'. Shutting down server...", sig)
 		if err := server.Shutdown(); err != nil {
 			log.Warnf("Server forced to shutdown: %v", err)
 		}
+
+		log.Info("Draining job workers...")
+		ctx, cancel := context.WithTimeout(context.Background(), workerShutdownTimeout)
+		defer cancel()
+		if err := workerPool.Shutdown(ctx); err != nil {
+			log.Warnf("Job workers forced to stop: %v", err)
+		}
 		log.Info("Server exiting")
 	}()
 
@@ -64,4 +86,7 @@ func Run() {
 		log.Errorf("Failed to run the server: %v", err)
 		os.Exit(1)
 	}
+
+	// Keep the database open until the workers are drained
+	<-shutdownDone
 }
@@ -3,6 +3,7 @@ package initialize
 import (
 	"mlvt/internal/infra/env"
 	"mlvt/internal/infra/server/http"
+	"mlvt/internal/infra/zap-logging/log"
 	"mlvt/internal/router"
 	"time"
 
@@ -14,11 +15,16 @@ import (
 func InitServer(appRouter *router.AppRouter) *http.Server {
 	// Create a new Gin router
 	r := gin.Default()
+	// The client IP counts failed logins, so it is only taken from X-Forwarded-For behind a trusted proxy
+	if err := r.SetTrustedProxies(env.EnvConfig.TrustedProxies); err != nil {
+		log.Errorf("Invalid TRUSTED_PROXIES, trusting none: %v", err)
+		_ = r.SetTrustedProxies(nil)
+	}
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000"}, // Set your allowed origins
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key", "X-Worker-Secret"},
+		ExposeHeaders:    []string{"Content-Length", "ETag", "Retry-After"},
 		AllowCredentials: true, // Allow credentials like cookies
 		MaxAge:           12 * time.Hour,
 	}))
@@ -26,10 +32,15 @@ func InitServer(appRouter *router.AppRouter) *http.Server {
 	// Register routes
 	api := r.Group("/api")
 	appRouter.RegisterUserRoutes(api)
+	appRouter.RegisterAdminRoutes(api)
+	appRouter.RegisterOrganizationRoutes(api)
 	appRouter.RegisterVideoRoutes(api)
 	appRouter.RegisterAudioRoutes(api)
 	appRouter.RegisterTranscriptionRoutes(api)
 	appRouter.RegisterPaymentRoutes(api)
+	appRouter.RegisterSubscriptionRoutes(api)
+	appRouter.RegisterStorageRoutes(api)
+	appRouter.RegisterWorkerRoutes(api)
 	appRouter.RegisterSwaggerRoutes(r.Group("/"))
 
 	// Create the HTTP server
@@ -8,10 +8,12 @@ import (
 	"database/sql"
 	handler "mlvt/internal/handler/rest/v1"
 	"mlvt/internal/infra/aws"
+	"mlvt/internal/infra/mailer"
 	"mlvt/internal/pkg/middleware"
 	"mlvt/internal/repo"
 	"mlvt/internal/router"
 	"mlvt/internal/service"
+	"mlvt/internal/worker"
 
 	"github.com/google/wire"
 )
@@ -19,6 +21,7 @@ import (
 func InitializeApp(db *sql.DB) (*router.AppRouter, error) {
 	wire.Build(
 		aws.ProviderSetAwsBucket,
+		mailer.ProviderSetMailer,
 		repo.ProviderSetRepository,
 		service.ProviderSetService,
 		handler.ProviderSetHandler,
@@ -27,3 +30,13 @@ func InitializeApp(db *sql.DB) (*router.AppRouter, error) {
 	)
 	return &router.AppRouter{}, nil
 }
+
+func InitializeWorkerPool(db *sql.DB) (*worker.Pool, error) {
+	wire.Build(
+		aws.ProviderSetAwsBucket,
+		repo.ProviderSetRepository,
+		service.ProviderSetService,
+		worker.ProviderSetWorker,
+	)
+	return &worker.Pool{}, nil
+}
@@ -10,42 +10,118 @@ import (
 	"database/sql"
 	"mlvt/internal/handler/rest/v1"
 	"mlvt/internal/infra/aws"
+	"mlvt/internal/infra/mailer"
 	"mlvt/internal/pkg/middleware"
 	"mlvt/internal/repo"
 	"mlvt/internal/router"
 	"mlvt/internal/service"
+	"mlvt/internal/worker"
 )
 
 // Injectors from wire.go:
 
 func InitializeApp(db *sql.DB) (*router.AppRouter, error) {
 	userRepository := repo.NewUserRepo(db)
-	s3ClientInterface, err := aws.NewS3Client()
+	s3ClientInterface, err := aws.NewStorageClient()
 	if err != nil {
 		return nil, err
 	}
+	refreshTokenRepository := repo.NewRefreshTokenRepo(db)
+	recoveryCodeRepository := repo.NewRecoveryCodeRepo(db)
+	loginAttemptRepository, err := repo.NewLoginAttemptStore(db)
+	if err != nil {
+		return nil, err
+	}
+	loginThrottle := service.NewLoginThrottle(loginAttemptRepository)
+	twoFactorService := service.NewTwoFactorService(userRepository, recoveryCodeRepository, loginThrottle)
 	string2 := _wireStringValue
-	authServiceInterface := service.NewAuthService(userRepository, string2)
-	userService := service.NewUserService(userRepository, s3ClientInterface, authServiceInterface)
+	authServiceInterface := service.NewAuthService(userRepository, refreshTokenRepository, twoFactorService, loginThrottle, string2)
+	userTokenRepository := repo.NewUserTokenRepo(db)
+	mailerMailer, err := mailer.NewMailer()
+	if err != nil {
+		return nil, err
+	}
+	verificationService := service.NewVerificationService(userRepository, userTokenRepository, mailerMailer)
+	userService := service.NewUserService(userRepository, s3ClientInterface, authServiceInterface, verificationService)
 	userController := handler.NewUserController(userService)
+	organizationRepository := repo.NewOrganizationRepo(db)
+	organizationService := service.NewOrganizationService(organizationRepository, userRepository, mailerMailer)
 	videoRepository := repo.NewVideoRepo(db)
-	videoService := service.NewVideoService(videoRepository, s3ClientInterface)
-	videoController := handler.NewVideoController(videoService)
+	uploadSessionRepository := repo.NewUploadSessionRepo(db)
+	jobRepository := repo.NewJobRepo(db)
+	videoService := service.NewVideoService(videoRepository, uploadSessionRepository, jobRepository, s3ClientInterface)
+	subscriptionRepository := repo.NewSubscriptionRepo(db)
+	planRepository := repo.NewPlanRepo(db)
+	subscriptionService := service.NewSubscriptionService(subscriptionRepository, planRepository, videoRepository)
+	videoController := handler.NewVideoController(videoService, organizationService, subscriptionService)
 	audioRepository := repo.NewAudioRepository(db)
 	audioService := service.NewAudioService(audioRepository, s3ClientInterface)
-	audioController := handler.NewAudioController(audioService)
 	transcriptionRepository := repo.NewTranscriptionRepository(db)
-	transcriptionService := service.NewTranscriptionService(transcriptionRepository, s3ClientInterface)
-	transcriptionController := handler.NewTranscriptionController(transcriptionService)
-	authUserMiddleware := middleware.NewAuthUserMiddleware(authServiceInterface)
+	transcriptionSegmentRepository := repo.NewTranscriptionSegmentRepo(db)
+	transcriptionService := service.NewTranscriptionService(transcriptionRepository, transcriptionSegmentRepository, videoRepository, s3ClientInterface)
+	ownershipService := service.NewOwnershipService(videoRepository, audioRepository, transcriptionRepository, organizationRepository)
+	audioController := handler.NewAudioController(audioService, organizationService, ownershipService)
+	transcriptionController := handler.NewTranscriptionController(transcriptionService, organizationService, ownershipService)
+	apiKeyRepository := repo.NewAPIKeyRepo(db)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepository, userRepository)
+	authUserMiddleware := middleware.NewAuthUserMiddleware(authServiceInterface, apiKeyService)
 	moMoRepo := repo.NewMoMoRepo()
-	moMoPaymentService := service.NewMoMoPaymentService(moMoRepo)
-	moMoPaymentController := handler.NewMoMoPaymentHandler(moMoPaymentService)
+	vnPayRepo := repo.NewVNPayRepo()
+	paymentProviders := repo.NewPaymentProviders(moMoRepo, vnPayRepo)
+	transactionLogRepo := repo.NewTransactionLogRepo(db)
+	orderRepository := repo.NewOrderRepo(db)
+	orderService := service.NewOrderService(orderRepository, planRepository)
+	paymentService := service.NewPaymentService(paymentProviders, transactionLogRepo, orderService, subscriptionService)
+	paymentController := handler.NewPaymentController(paymentService, orderService)
+	orderController := handler.NewOrderController(orderService)
+	subscriptionController := handler.NewSubscriptionController(subscriptionService, paymentService)
+	storageController := handler.NewStorageController(s3ClientInterface)
+	mlWorkerRepository := repo.NewMLWorkerRepo(db)
+	jobService := service.NewJobService(jobRepository)
+	pipelineRepository := repo.NewPipelineRepo(db)
+	mlWorkerService := service.NewMLWorkerService(mlWorkerRepository, jobService, videoRepository, transcriptionRepository, audioRepository, pipelineRepository, s3ClientInterface)
+	mlWorkerController := handler.NewMLWorkerController(mlWorkerService)
+	pipelineService := service.NewPipelineService(pipelineRepository, videoRepository, transcriptionRepository, jobService, mlWorkerService)
+	pipelineController := handler.NewPipelineController(pipelineService)
+	authWorkerMiddleware := middleware.NewAuthWorkerMiddleware(mlWorkerService)
+	ownershipMiddleware := middleware.NewOwnershipMiddleware(ownershipService)
+	auditLogRepository := repo.NewAuditLogRepo(db)
+	adminService := service.NewAdminService(userRepository, auditLogRepository, userService, loginThrottle)
+	adminController := handler.NewAdminController(adminService)
+	twoFactorController := handler.NewTwoFactorController(twoFactorService)
+	apiKeyController := handler.NewAPIKeyController(apiKeyService)
+	userIdentityRepository := repo.NewUserIdentityRepo(db)
+	oidcService := service.NewOIDCService(userRepository, userIdentityRepository, authServiceInterface, string2)
+	oidcController := handler.NewOIDCController(oidcService)
+	organizationController := handler.NewOrganizationController(organizationService)
 	swaggerRouter := router.NewSwaggerRouter()
-	appRouter := router.NewAppRouter(userController, videoController, audioController, transcriptionController, authUserMiddleware, moMoPaymentController, swaggerRouter)
+	appRouter := router.NewAppRouter(userController, videoController, audioController, transcriptionController, authUserMiddleware, paymentController, orderController, subscriptionController, storageController, mlWorkerController, pipelineController, adminController, twoFactorController, apiKeyController, oidcController, organizationController, authWorkerMiddleware, ownershipMiddleware, swaggerRouter)
 	return appRouter, nil
 }
 
+func InitializeWorkerPool(db *sql.DB) (*worker.Pool, error) {
+	jobRepository := repo.NewJobRepo(db)
+	jobService := service.NewJobService(jobRepository)
+	videoRepository := repo.NewVideoRepo(db)
+	uploadSessionRepository := repo.NewUploadSessionRepo(db)
+	s3ClientInterface, err := aws.NewStorageClient()
+	if err != nil {
+		return nil, err
+	}
+	videoService := service.NewVideoService(videoRepository, uploadSessionRepository, jobRepository, s3ClientInterface)
+	pipelineRepository := repo.NewPipelineRepo(db)
+	transcriptionRepository := repo.NewTranscriptionRepository(db)
+	mlWorkerRepository := repo.NewMLWorkerRepo(db)
+	audioRepository := repo.NewAudioRepository(db)
+	mlWorkerService := service.NewMLWorkerService(mlWorkerRepository, jobService, videoRepository, transcriptionRepository, audioRepository, pipelineRepository, s3ClientInterface)
+	pipelineService := service.NewPipelineService(pipelineRepository, videoRepository, transcriptionRepository, jobService, mlWorkerService)
+	subscriptionRepository := repo.NewSubscriptionRepo(db)
+	planRepository := repo.NewPlanRepo(db)
+	subscriptionService := service.NewSubscriptionService(subscriptionRepository, planRepository, videoRepository)
+	pool := worker.NewAppPool(jobService, videoService, pipelineService, subscriptionService)
+	return pool, nil
+}
+
 var (
 	_wireStringValue = service.SecretKey
 )
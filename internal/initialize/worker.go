@@ -0,0 +1,15 @@
+package initialize
+
+import (
+	"database/sql"
+	"mlvt/internal/worker"
+)
+
+// InitWorkerPool builds the background job worker pool using dependency injection.
+func InitWorkerPool(dbConn *sql.DB) (*worker.Pool, error) {
+	pool, err := InitializeWorkerPool(dbConn)
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
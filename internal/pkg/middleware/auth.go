@@ -15,23 +15,33 @@ type AuthService interface {
 	// Add other authentication-related methods if needed
 }
 
+// APIKeyHeader carries a personal API key instead of an access token
+const APIKeyHeader = "X-API-Key"
+
 // AuthUserMiddleware handles user authentication
 type AuthUserMiddleware struct {
-	authService service.AuthServiceInterface
+	authService   service.AuthServiceInterface
+	apiKeyService service.APIKeyService
 }
 
 // NewAuthUserMiddleware creates a new AuthUserMiddleware
-func NewAuthUserMiddleware(authService service.AuthServiceInterface) *AuthUserMiddleware {
+func NewAuthUserMiddleware(authService service.AuthServiceInterface, apiKeyService service.APIKeyService) *AuthUserMiddleware {
 	return &AuthUserMiddleware{
-		authService: authService,
+		authService:   authService,
+		apiKeyService: apiKeyService,
 	}
 }
 
-// Auth is a middleware function that authenticates the user if a token is present
+// apiKeyScopes are the scopes an API key needs on a route: read for GET and HEAD requests, write otherwise
+type apiKeyScopes struct {
+	read, write entity.APIKeyScope
+}
+
+// Auth is a middleware function that authenticates the user if a token is present. API keys are ignored.
 func (am *AuthUserMiddleware) Auth() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		token := extractToken(ctx)
-		if len(token) == 0 {
+		token, isAPIKey := extractToken(ctx)
+		if len(token) == 0 || isAPIKey {
 			ctx.Next()
 			return
 		}
@@ -47,31 +57,134 @@ func (am *AuthUserMiddleware) Auth() gin.HandlerFunc {
 	}
 }
 
-// MustAuth ensures the user is authenticated; otherwise, returns an error
+// MustAuth ensures the user is authenticated with an access token; otherwise, returns an error.
+// Users whose password reset was forced by an admin are rejected until they change their password.
+// API keys are refused, so they cannot manage the account.
 func (am *AuthUserMiddleware) MustAuth() gin.HandlerFunc {
+	return am.mustAuth(false, nil)
+}
+
+// MustAuthForPasswordChange is MustAuth for the change-password route, which also lets users through
+// whose password reset was forced
+func (am *AuthUserMiddleware) MustAuthForPasswordChange() gin.HandlerFunc {
+	return am.mustAuth(true, nil)
+}
+
+// MustAuthWithAPIKey is MustAuth that also accepts API keys, which need the read scope for GET and HEAD
+// requests and the write scope for the others
+func (am *AuthUserMiddleware) MustAuthWithAPIKey(read, write entity.APIKeyScope) gin.HandlerFunc {
+	return am.mustAuth(false, &apiKeyScopes{read: read, write: write})
+}
+
+func (am *AuthUserMiddleware) mustAuth(allowPasswordReset bool, scopes *apiKeyScopes) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		token := extractToken(ctx)
+		token, isAPIKey := extractToken(ctx)
 		if len(token) == 0 {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
 
-		userInfo, err := am.authService.GetUserByToken(token)
-		if err != nil || userInfo == nil || userInfo.Status == entity.UserStatusSuspended || userInfo.Status == entity.UserStatusDeleted {
+		var userInfo *entity.User
+		if isAPIKey {
+			if scopes == nil {
+				ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API keys are not accepted here"})
+				return
+			}
+			user, apiKey, err := am.apiKeyService.Authenticate(token)
+			if err != nil || user == nil {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+				return
+			}
+			if !apiKey.HasScope(scopes.required(ctx.Request.Method)) {
+				ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient API key scope"})
+				return
+			}
+			ctx.Set("apiKey", apiKey)
+			userInfo = user
+		} else {
+			user, err := am.authService.GetUserByToken(token)
+			if err != nil || user == nil {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+				return
+			}
+			userInfo = user
+		}
+
+		if userInfo.Status == entity.UserStatusSuspended || userInfo.Status == entity.UserStatusDeleted {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
+		if userInfo.PasswordResetRequired && !allowPasswordReset {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "password reset required"})
+			return
+		}
 
 		ctx.Set("userInfo", userInfo)
 		ctx.Next()
 	}
 }
 
-// extractToken extracts the token from the Authorization header or query parameter
-func extractToken(ctx *gin.Context) string {
+// RequirePermission ensures the authenticated user has every one of the permissions; it runs after MustAuth
+func (am *AuthUserMiddleware) RequirePermission(permissions ...entity.Permission) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userInfo, ok := GetUserInfo(ctx)
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		for _, permission := range permissions {
+			if !userInfo.HasPermission(permission) {
+				ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
+
+// GetUserInfo returns the authenticated user stored in the context by Auth or MustAuth
+func GetUserInfo(ctx *gin.Context) (*entity.User, bool) {
+	value, exists := ctx.Get("userInfo")
+	if !exists {
+		return nil, false
+	}
+	userInfo, ok := value.(*entity.User)
+	return userInfo, ok && userInfo != nil
+}
+
+// GetAPIKey returns the API key stored in the context by MustAuthWithAPIKey, if the request was made with one
+func GetAPIKey(ctx *gin.Context) (*entity.APIKey, bool) {
+	value, exists := ctx.Get("apiKey")
+	if !exists {
+		return nil, false
+	}
+	apiKey, ok := value.(*entity.APIKey)
+	return apiKey, ok && apiKey != nil
+}
+
+func (s *apiKeyScopes) required(method string) entity.APIKeyScope {
+	if isReadMethod(method) {
+		return s.read
+	}
+	return s.write
+}
+
+// isReadMethod reports whether requests with the method only read
+func isReadMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// extractToken extracts the token from the X-API-Key header, or else from the Authorization header or query
+// parameter, reporting whether it is an API key. API keys are only taken from the header, so they stay out of URLs.
+func extractToken(ctx *gin.Context) (string, bool) {
+	if apiKey := ctx.GetHeader(APIKeyHeader); len(apiKey) > 0 {
+		return apiKey, true
+	}
+
 	token := ctx.GetHeader("Authorization")
 	if len(token) == 0 {
 		token = ctx.Query("Authorization")
 	}
-	return strings.TrimPrefix(token, "Bearer ")
+	return strings.TrimPrefix(token, "Bearer "), false
 }
@@ -80,3 +80,11 @@ func (m *MockAuthMiddleware) AuthUnauthenticated() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// MustAuthAs simulates the given user being authenticated, e.g. another user or an admin
+func (m *MockAuthMiddleware) MustAuthAs(userInfo *entity.User) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("userInfo", userInfo)
+		c.Next()
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupAPIKeyAuthRouter serves /videos, which accepts API keys with a videos scope, and /account, which does not
+func setupAPIKeyAuthRouter(authService *service.MockAuthService, apiKeyService *service.MockAPIKeyService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	am := NewAuthUserMiddleware(authService, apiKeyService)
+
+	ok := func(c *gin.Context) {
+		userInfo, _ := GetUserInfo(c)
+		c.JSON(http.StatusOK, gin.H{"user_id": userInfo.ID})
+	}
+	videos := router.Group("/videos", am.MustAuthWithAPIKey(entity.APIKeyScopeVideosRead, entity.APIKeyScopeVideosWrite))
+	videos.GET("", ok)
+	videos.POST("", ok)
+	router.GET("/account", am.MustAuth(), ok)
+
+	return router
+}
+
+func TestMustAuthWithAPIKey(t *testing.T) {
+	user := &entity.User{ID: 1, Role: entity.UserRoleUser, Status: entity.UserStatusAvailable}
+	readKey := &entity.APIKey{ID: 1, UserID: user.ID, Scopes: []entity.APIKeyScope{entity.APIKeyScopeVideosRead}}
+	writeKey := &entity.APIKey{ID: 2, UserID: user.ID, Scopes: []entity.APIKeyScope{entity.APIKeyScopeVideosWrite}}
+
+	authService := new(service.MockAuthService)
+	apiKeyService := new(service.MockAPIKeyService)
+	apiKeyService.On("Authenticate", "mlvt_read").Return(user, readKey, nil)
+	apiKeyService.On("Authenticate", "mlvt_write").Return(user, writeKey, nil)
+	apiKeyService.On("Authenticate", "mlvt_revoked").Return(nil, nil, service.ErrInvalidAPIKey)
+	authService.On("GetUserByToken", "access-token").Return(user, nil)
+	router := setupAPIKeyAuthRouter(authService, apiKeyService)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{"Read Scope Reads", "GET", "/videos", APIKeyHeader, "mlvt_read", http.StatusOK},
+		{"Read Scope Cannot Write", "POST", "/videos", APIKeyHeader, "mlvt_read", http.StatusForbidden},
+		{"Write Scope Reads", "GET", "/videos", APIKeyHeader, "mlvt_write", http.StatusOK},
+		{"Write Scope Writes", "POST", "/videos", APIKeyHeader, "mlvt_write", http.StatusOK},
+		{"Revoked Key", "GET", "/videos", APIKeyHeader, "mlvt_revoked", http.StatusUnauthorized},
+		{"Key Refused By MustAuth", "GET", "/account", APIKeyHeader, "mlvt_write", http.StatusForbidden},
+		{"Access Token", "POST", "/videos", "Authorization", "Bearer access-token", http.StatusOK},
+		{"No Credentials", "GET", "/videos", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
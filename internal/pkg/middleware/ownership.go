@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"errors"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OwnershipMiddleware only lets users reach the users, videos, audios and transcriptions they own, and the
+// resources of their organizations as their role allows. It runs after MustAuth; admins may reach the
+// resources of every user and organization.
+type OwnershipMiddleware struct {
+	ownershipService service.OwnershipService
+}
+
+// NewOwnershipMiddleware creates a new OwnershipMiddleware
+func NewOwnershipMiddleware(ownershipService service.OwnershipService) *OwnershipMiddleware {
+	return &OwnershipMiddleware{
+		ownershipService: ownershipService,
+	}
+}
+
+// CanAccess reports whether the user may act on a resource owned by ownerID
+func CanAccess(userInfo *entity.User, ownerID uint64) bool {
+	return userInfo != nil && (userInfo.ID == ownerID || userInfo.IsAdmin())
+}
+
+// MustOwnUser ensures the user ID in the path parameter is the authenticated user
+func (om *OwnershipMiddleware) MustOwnUser(param string) gin.HandlerFunc {
+	return om.mustOwn(param, "invalid user ID", func(userID uint64) (*service.ResourceOwner, error) {
+		return &service.ResourceOwner{UserID: userID}, nil
+	})
+}
+
+// MustOwnVideo ensures the video in the path parameter belongs to the authenticated user or to one of their
+// organizations, or that the user has one of the override permissions
+func (om *OwnershipMiddleware) MustOwnVideo(param string, overrides ...entity.Permission) gin.HandlerFunc {
+	return om.mustOwn(param, "invalid video ID", om.ownershipService.VideoOwner, overrides...)
+}
+
+// MustOwnAudio ensures the audio in the path parameter belongs to the authenticated user or to one of their organizations
+func (om *OwnershipMiddleware) MustOwnAudio(param string) gin.HandlerFunc {
+	return om.mustOwn(param, "invalid audio ID", om.ownershipService.AudioOwner)
+}
+
+// MustOwnTranscription ensures the transcription in the path parameter belongs to the authenticated user or to
+// one of their organizations
+func (om *OwnershipMiddleware) MustOwnTranscription(param string) gin.HandlerFunc {
+	return om.mustOwn(param, "invalid transcription ID", om.ownershipService.TranscriptionOwner)
+}
+
+// MustHaveOrgRole ensures the authenticated user is a member of the organization in the path parameter with
+// at least the role. Admins may act on every organization.
+func (om *OwnershipMiddleware) MustHaveOrgRole(param string, role entity.OrgRole) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userInfo, ok := GetUserInfo(ctx)
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		orgID, err := strconv.ParseUint(ctx.Param(param), 10, 64)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid organization ID"})
+			return
+		}
+		if userInfo.IsAdmin() {
+			ctx.Next()
+			return
+		}
+
+		memberRole, err := om.ownershipService.OrgRole(orgID, userInfo.ID)
+		if err != nil {
+			log.Errorf("Failed to look up the role of user %d in organization %d: %v", userInfo.ID, orgID, err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if !memberRole.AtLeast(role) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// mustOwn parses the ID in the path parameter, looks up its owner and aborts with 403 unless the authenticated
+// user may reach it or has one of the override permissions
+func (om *OwnershipMiddleware) mustOwn(param, invalidMessage string, owner func(id uint64) (*service.ResourceOwner, error), overrides ...entity.Permission) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userInfo, ok := GetUserInfo(ctx)
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		id, err := strconv.ParseUint(ctx.Param(param), 10, 64)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": invalidMessage})
+			return
+		}
+
+		resourceOwner, err := owner(id)
+		if err == nil {
+			ok, err = CanReach(om.ownershipService, userInfo, resourceOwner, isReadMethod(ctx.Request.Method))
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrVideoNotFound), errors.Is(err, service.ErrAudioNotFound), errors.Is(err, service.ErrTranscriptionNotFound):
+				ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			default:
+				log.Errorf("Failed to look up the owner of %s %d: %v", param, id, err)
+				ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			}
+			return
+		}
+
+		if !ok && !hasAnyPermission(userInfo, overrides) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// CanReach reports whether the user may act on the resource: its owner and admins always may, members of its
+// organization may read it, and editors and owners of the organization may change it
+func CanReach(ownershipService service.OwnershipService, userInfo *entity.User, owner *service.ResourceOwner, read bool) (bool, error) {
+	if CanAccess(userInfo, owner.UserID) {
+		return true, nil
+	}
+	if owner.OrgID == nil {
+		return false, nil
+	}
+
+	role, err := ownershipService.OrgRole(*owner.OrgID, userInfo.ID)
+	if err != nil {
+		return false, err
+	}
+	if read {
+		return role.AtLeast(entity.OrgRoleViewer), nil
+	}
+	return role.AtLeast(entity.OrgRoleEditor), nil
+}
+
+func hasAnyPermission(userInfo *entity.User, permissions []entity.Permission) bool {
+	for _, permission := range permissions {
+		if userInfo.HasPermission(permission) {
+			return true
+		}
+	}
+	return false
+}
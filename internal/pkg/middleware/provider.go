@@ -5,4 +5,4 @@ import (
 )
 
 // ProviderSetMiddleware is providers.
-var ProviderSetMiddleware = wire.NewSet(NewAuthUserMiddleware)
+var ProviderSetMiddleware = wire.NewSet(NewAuthUserMiddleware, NewAuthWorkerMiddleware, NewOwnershipMiddleware)
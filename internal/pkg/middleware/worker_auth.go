@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"mlvt/internal/entity"
+	"mlvt/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthWorkerMiddleware authenticates the external ML workers by the token issued at registration
+type AuthWorkerMiddleware struct {
+	workerService service.MLWorkerService
+}
+
+// NewAuthWorkerMiddleware creates a new AuthWorkerMiddleware
+func NewAuthWorkerMiddleware(workerService service.MLWorkerService) *AuthWorkerMiddleware {
+	return &AuthWorkerMiddleware{
+		workerService: workerService,
+	}
+}
+
+// MustAuthWorker ensures the request comes from a registered worker; otherwise, returns an error
+func (am *AuthWorkerMiddleware) MustAuthWorker() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token, isAPIKey := extractToken(ctx)
+		if isAPIKey {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		worker, err := am.workerService.AuthenticateWorker(token)
+		if err != nil || worker == nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		ctx.Set("workerInfo", worker)
+		ctx.Next()
+	}
+}
+
+// GetWorkerInfo returns the authenticated worker stored in the context by MustAuthWorker
+func GetWorkerInfo(ctx *gin.Context) (*entity.MLWorker, bool) {
+	value, exists := ctx.Get("workerInfo")
+	if !exists {
+		return nil, false
+	}
+	worker, ok := value.(*entity.MLWorker)
+	return worker, ok && worker != nil
+}
@@ -0,0 +1,313 @@
+// Package oidc implements the OpenID Connect authorization code flow with PKCE (RFC 7636) for logging in with
+// an external identity provider: discovery, the authorization URL, the code exchange and ID token validation
+// against the keys the provider publishes.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrDiscovery      = errors.New("oidc: discovery failed")
+	ErrExchange       = errors.New("oidc: code exchange failed")
+	ErrInvalidIDToken = errors.New("oidc: invalid ID token")
+)
+
+const (
+	// ClockSkew is how far the clocks of the provider and this server may drift apart
+	ClockSkew = time.Minute
+	// keyRefreshInterval limits how often the keys are fetched again for an ID token signed with an unknown key
+	keyRefreshInterval = time.Minute
+)
+
+// Config identifies this application to a provider
+type Config struct {
+	Issuer       string // e.g. https://accounts.google.com
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string   // Where the provider sends the browser back with the code
+	Scopes       []string // Requested besides openid; email and profile when empty
+}
+
+// Metadata is the part of the discovery document this package uses
+type Metadata struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	JWKSURI                       string   `json:"jwks_uri"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+}
+
+// Token is the response of the token endpoint
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Claims are the claims of a validated ID token that identify the user
+type Claims struct {
+	Subject       string // Stable ID of the user at the provider
+	Email         string
+	EmailVerified bool
+	Name          string
+	GivenName     string
+	FamilyName    string
+}
+
+// Provider is a discovered identity provider. It is safe for concurrent use.
+type Provider struct {
+	config   Config
+	metadata Metadata
+	client   *http.Client
+
+	mu            sync.Mutex
+	keys          map[string]interface{} // Public keys by key ID
+	keysFetchedAt time.Time
+}
+
+// Discover reads the discovery document of the issuer and the keys it signs ID tokens with
+func Discover(ctx context.Context, client *http.Client, config Config) (*Provider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var metadata Metadata
+	wellKnown := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, client, wellKnown, &metadata); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	// The issuer must be the one configured, or the provider could vouch for another
+	if metadata.Issuer != config.Issuer {
+		return nil, fmt.Errorf("%w: issuer %q does not match %q", ErrDiscovery, metadata.Issuer, config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: missing endpoints", ErrDiscovery)
+	}
+
+	p := &Provider{config: config, metadata: metadata, client: client}
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	return p, nil
+}
+
+// Metadata returns the discovery document of the provider
+func (p *Provider) Metadata() Metadata {
+	return p.metadata
+}
+
+// AuthCodeURL returns the URL of the provider's login page. The state and nonce are checked on the callback
+// and in the ID token; the code verifier is kept until the exchange and only its S256 challenge is sent.
+func (p *Provider) AuthCodeURL(state, nonce, codeVerifier string) string {
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"email", "profile"}
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(append([]string{"openid"}, scopes...), " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.metadata.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// Exchange trades the code from the callback for the tokens, proving with the code verifier that this
+// server started the login
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.config.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d: %s", ErrExchange, resp.StatusCode, body)
+	}
+
+	var token Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%w: no ID token", ErrExchange)
+	}
+	return &token, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token and returns its claims
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	parser := &jwt.Parser{
+		ValidMethods:         []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"},
+		SkipClaimsValidation: true, // Checked below, with leeway for clock skew
+	}
+	token, err := parser.Parse(rawIDToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidIDToken
+	}
+
+	now := time.Now()
+	if iss, _ := claims["iss"].(string); iss != p.metadata.Issuer {
+		return nil, fmt.Errorf("%w: issuer %q", ErrInvalidIDToken, iss)
+	}
+	if !p.validAudience(claims) {
+		return nil, fmt.Errorf("%w: not issued to this client", ErrInvalidIDToken)
+	}
+	if !claims.VerifyExpiresAt(now.Add(-ClockSkew).Unix(), true) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	}
+	if !claims.VerifyIssuedAt(now.Add(ClockSkew).Unix(), false) || !claims.VerifyNotBefore(now.Add(ClockSkew).Unix(), false) {
+		return nil, fmt.Errorf("%w: not valid yet", ErrInvalidIDToken)
+	}
+	if tokenNonce, _ := claims["nonce"].(string); nonce == "" || tokenNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+	result := &Claims{Subject: subject, EmailVerified: isTrue(claims["email_verified"])}
+	result.Email, _ = claims["email"].(string)
+	result.Name, _ = claims["name"].(string)
+	result.GivenName, _ = claims["given_name"].(string)
+	result.FamilyName, _ = claims["family_name"].(string)
+	return result, nil
+}
+
+// validAudience reports whether the token was issued to this client. A token for several audiences must
+// name this client as the authorized party.
+func (p *Provider) validAudience(claims jwt.MapClaims) bool {
+	var audiences []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []interface{}:
+		for _, value := range aud {
+			if s, ok := value.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+
+	found := false
+	for _, aud := range audiences {
+		if aud == p.config.ClientID {
+			found = true
+		}
+	}
+	if !found {
+		return false
+	}
+	if azp, ok := claims["azp"].(string); ok {
+		return azp == p.config.ClientID
+	}
+	return len(audiences) == 1
+}
+
+// NewState returns a random value for the state and nonce parameters
+func NewState() (string, error) {
+	return randomString(16)
+}
+
+// NewCodeVerifier returns a random PKCE code verifier
+func NewCodeVerifier() (string, error) {
+	return randomString(32)
+}
+
+// CodeChallenge returns the S256 challenge of a PKCE code verifier
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// isTrue accepts true and "true"; some providers send email_verified as a string
+func isTrue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,133 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"mlvt/internal/pkg/oidc/oidctest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupProvider(t *testing.T) (*Provider, *oidctest.Provider) {
+	fake := oidctest.NewProvider("client-id", "client secret")
+	t.Cleanup(fake.Close)
+
+	provider, err := Discover(context.Background(), nil, Config{
+		Issuer:       fake.Issuer,
+		ClientID:     "client-id",
+		ClientSecret: "client secret",
+		RedirectURL:  "http://localhost:8080/api/users/oidc/test/callback",
+	})
+	require.NoError(t, err)
+	return provider, fake
+}
+
+// login runs the authorization code flow and returns the code from the callback
+func login(t *testing.T, provider *Provider, fake *oidctest.Provider, verifier string) string {
+	callback, err := fake.Authorize(provider.AuthCodeURL("state-1", "nonce-1", verifier))
+	require.NoError(t, err)
+	assert.Equal(t, "/api/users/oidc/test/callback", callback.Path)
+	assert.Equal(t, "state-1", callback.Query().Get("state"))
+	return callback.Query().Get("code")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	provider, fake := setupProvider(t)
+	verifier, err := NewCodeVerifier()
+	require.NoError(t, err)
+
+	code := login(t, provider, fake, verifier)
+	token, err := provider.Exchange(context.Background(), code, verifier)
+	require.NoError(t, err)
+
+	claims, err := provider.VerifyIDToken(context.Background(), token.IDToken, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, &Claims{Subject: "subject-1", Email: "john@example.com", EmailVerified: true, Name: "John Doe", GivenName: "John", FamilyName: "Doe"}, claims)
+
+	_, err = provider.Exchange(context.Background(), code, verifier)
+	assert.ErrorIs(t, err, ErrExchange, "a code works once")
+}
+
+func TestExchangeChecksCodeVerifier(t *testing.T) {
+	provider, fake := setupProvider(t)
+
+	code := login(t, provider, fake, "the-real-verifier-of-this-login-0000000000")
+	_, err := provider.Exchange(context.Background(), code, "another-verifier-0000000000000000000000000")
+	assert.ErrorIs(t, err, ErrExchange)
+}
+
+func TestDiscoverRejectsOtherIssuer(t *testing.T) {
+	fake := oidctest.NewProvider("client-id", "")
+	defer fake.Close()
+
+	_, err := Discover(context.Background(), http.DefaultClient, Config{Issuer: fake.Issuer + "/other"})
+	assert.ErrorIs(t, err, ErrDiscovery)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	provider, fake := setupProvider(t)
+	user := oidctest.User{Subject: "subject-1", Email: "john@example.com", EmailVerified: true}
+
+	tests := []struct {
+		name   string
+		modify func(claims map[string]interface{})
+		nonce  string
+	}{
+		{"Wrong Nonce", func(map[string]interface{}) {}, "other-nonce"},
+		{"Wrong Audience", func(claims map[string]interface{}) { claims["aud"] = "other-client" }, "nonce-1"},
+		{"Other Authorized Party", func(claims map[string]interface{}) {
+			claims["aud"] = []string{"client-id", "other-client"}
+			claims["azp"] = "other-client"
+		}, "nonce-1"},
+		{"Wrong Issuer", func(claims map[string]interface{}) { claims["iss"] = "https://evil.example.com" }, "nonce-1"},
+		{"Expired", func(claims map[string]interface{}) { claims["exp"] = time.Now().Add(-2 * ClockSkew).Unix() }, "nonce-1"},
+		{"No Subject", func(claims map[string]interface{}) { delete(claims, "sub") }, "nonce-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := fake.IDTokenClaims(user, "nonce-1")
+			tt.modify(claims)
+
+			_, err := provider.VerifyIDToken(context.Background(), fake.SignIDToken(claims), tt.nonce)
+			assert.ErrorIs(t, err, ErrInvalidIDToken)
+		})
+	}
+
+	t.Run("Multiple Audiences With This Client As Authorized Party", func(t *testing.T) {
+		claims := fake.IDTokenClaims(user, "nonce-1")
+		claims["aud"] = []string{"client-id", "other-client"}
+		claims["azp"] = "client-id"
+
+		_, err := provider.VerifyIDToken(context.Background(), fake.SignIDToken(claims), "nonce-1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Tampered Signature", func(t *testing.T) {
+		token := fake.SignIDToken(fake.IDTokenClaims(user, "nonce-1"))
+		_, err := provider.VerifyIDToken(context.Background(), token[:len(token)-4]+"AAAA", "nonce-1")
+		assert.ErrorIs(t, err, ErrInvalidIDToken)
+	})
+}
+
+func TestVerifyIDTokenAfterKeyRotation(t *testing.T) {
+	provider, fake := setupProvider(t)
+	claims := fake.IDTokenClaims(oidctest.User{Subject: "subject-1"}, "nonce-1")
+
+	// The keys were fetched on discovery, so the new key is only looked up after the refresh interval
+	fake.RotateKey()
+	_, err := provider.VerifyIDToken(context.Background(), fake.SignIDToken(claims), "nonce-1")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+
+	provider.keysFetchedAt = time.Now().Add(-keyRefreshInterval)
+	_, err = provider.VerifyIDToken(context.Background(), fake.SignIDToken(claims), "nonce-1")
+	assert.NoError(t, err)
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// Example from RFC 7636, appendix B
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
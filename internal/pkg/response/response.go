@@ -1,6 +1,10 @@
 package response
 
-import "mlvt/internal/entity"
+import (
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/aws"
+	"time"
+)
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
@@ -17,10 +21,59 @@ type MessageResponse struct {
 	Message string `json:"message"`
 }
 
+// CreatedResponse represents a message response carrying the ID of the created record
+type CreatedResponse struct {
+	Message string `json:"message"`
+	ID      uint64 `json:"id"`
+}
+
 // TokenResponse represents the response containing a token
 type TokenResponse struct {
-	Token  string `json:"token"`
-	UserID uint64 `json:"user_id"`
+	Token        string    `json:"token"`         // Access token, sent as "Authorization: Bearer <token>"
+	RefreshToken string    `json:"refresh_token"` // Single-use token for POST /users/refresh
+	ExpiresAt    time.Time `json:"expires_at"`    // When the access token expires
+	UserID       uint64    `json:"user_id"`
+}
+
+// TwoFactorChallengeResponse is returned by the login instead of tokens when the user has two-factor authentication
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"` // Sent with the code to POST /users/login/2fa
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// OIDCProvidersResponse lists the identity providers users may log in with
+type OIDCProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+// TwoFactorStatusResponse tells whether two-factor authentication is enabled
+type TwoFactorStatusResponse struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TwoFactorEnrollmentResponse holds what an authenticator app needs to generate codes
+type TwoFactorEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRCode     []byte `json:"qr_code"` // Base64 encoded PNG of the otpauth URI
+}
+
+// RecoveryCodesResponse holds one-time recovery codes, shown to the user only once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// APIKeyCreatedResponse holds a new API key with its value, shown to the user only once
+type APIKeyCreatedResponse struct {
+	APIKey entity.APIKey `json:"api_key"`
+	Key    string        `json:"key"` // Sent in the X-API-Key header
+}
+
+// APIKeysResponse lists the API keys of the user
+type APIKeysResponse struct {
+	APIKeys []entity.APIKey `json:"api_keys"`
 }
 
 // AvatarDownloadURLResponse represents the response containing avatar download URL
@@ -43,9 +96,26 @@ type UsersResponse struct {
 	Users []entity.User `json:"users"`
 }
 
+// UserPageResponse represents the response containing one page of the users matching an admin filter
+type UserPageResponse struct {
+	Users    []entity.User `json:"users"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+	Total    int           `json:"total"` // Number of matching users across all pages
+}
+
+// AuditLogPageResponse represents the response containing one page of the audit trail
+type AuditLogPageResponse struct {
+	AuditLogs []entity.AuditLog `json:"audit_logs"`
+	Page      int               `json:"page"`
+	PageSize  int               `json:"page_size"`
+	Total     int               `json:"total"` // Number of audit logs across all pages
+}
+
 // UploadURLResponse represents the response containing an upload URL
 type UploadURLResponse struct {
 	UploadURL string `json:"upload_url"`
+	FileName  string `json:"file_name,omitempty"` // File name to add the record with, when the server chose it
 }
 
 // DownloadURLResponse represents the response containing a download URL
@@ -56,7 +126,7 @@ type DownloadURLResponse struct {
 // TranscriptionResponse represents the response containing a transcription and its download URL
 type TranscriptionResponse struct {
 	Transcription entity.Transcription `json:"transcription"`
-	DownloadURL   string               `json:"download_url"`
+	DownloadURL   string               `json:"download_url,omitempty"`
 }
 
 // TranscriptionsResponse represents the response containing a list of transcriptions
@@ -67,10 +137,211 @@ type TranscriptionsResponse struct {
 // AudioResponse represents the response containing an audio and its download URL
 type AudioResponse struct {
 	Audio       entity.Audio `json:"audio"`
-	DownloadURL string       `json:"download_url"`
+	DownloadURL string       `json:"download_url,omitempty"`
 }
 
 // AudiosResponse represents the response containing a list of audios
 type AudiosResponse struct {
 	Audios []entity.Audio `json:"audios"`
 }
+
+// VideoResponse represents the response containing a video
+type VideoResponse struct {
+	Video entity.Video `json:"video"`
+}
+
+// UploadSessionResponse represents the response containing a multipart upload session
+type UploadSessionResponse struct {
+	UploadSession entity.UploadSession `json:"upload_session"`
+}
+
+// UploadSessionsResponse represents the response containing a list of multipart upload sessions
+type UploadSessionsResponse struct {
+	UploadSessions []entity.UploadSession `json:"upload_sessions"`
+}
+
+// UploadPartURL represents the presigned upload URL of one part
+type UploadPartURL struct {
+	PartNumber int32  `json:"part_number"`
+	UploadURL  string `json:"upload_url"`
+}
+
+// UploadPartURLsResponse represents the response containing presigned part upload URLs
+type UploadPartURLsResponse struct {
+	Parts []UploadPartURL `json:"parts"`
+}
+
+// UploadedPartsResponse represents the response containing an upload session and its uploaded parts
+type UploadedPartsResponse struct {
+	UploadSession entity.UploadSession `json:"upload_session"`
+	Parts         []aws.UploadedPart   `json:"parts"`
+}
+
+// WorkerRegistrationResponse represents the response containing a registered ML worker and its token
+type WorkerRegistrationResponse struct {
+	Worker entity.MLWorker `json:"worker"`
+	Token  string          `json:"token"` // Only returned once; send it as a Bearer token on the worker routes
+}
+
+// MLTask represents a task handed out to an ML worker
+type MLTask struct {
+	ID              uint64            `json:"id"`
+	Type            entity.MLTaskType `json:"type"`
+	VideoID         uint64            `json:"video_id"`
+	SourceLang      string            `json:"source_lang"`
+	TargetLang      string            `json:"target_lang,omitempty"`
+	TranscriptionID uint64            `json:"transcription_id,omitempty"`
+	AudioID         uint64            `json:"audio_id,omitempty"`
+	InputURL        string            `json:"input_url,omitempty"`       // Download URL of the video or input transcription file
+	InputText       string            `json:"input_text,omitempty"`      // Text of the input transcription
+	InputAudioURL   string            `json:"input_audio_url,omitempty"` // Download URL of the input audio, mux only
+	UploadURL       string            `json:"upload_url"`                // Presigned PUT URL for the result file
+	ContentType     string            `json:"content_type"`              // Content-Type the result file must be uploaded with
+	Attempt         int               `json:"attempt"`
+	MaxAttempts     int               `json:"max_attempts"`
+	LeasedUntil     *time.Time        `json:"leased_until"` // Send progress before this time to keep the task
+}
+
+// MLTaskResponse represents the response containing a claimed ML task
+type MLTaskResponse struct {
+	Task MLTask `json:"task"`
+}
+
+// JobResponse represents the response containing the state of a background job
+type JobResponse struct {
+	Job entity.Job `json:"job"`
+}
+
+// MLTaskResultResponse represents the response containing the record created from an ML task result
+type MLTaskResultResponse struct {
+	Transcription *entity.Transcription `json:"transcription,omitempty"`
+	Audio         *entity.Audio         `json:"audio,omitempty"`
+	Step          *entity.PipelineStep  `json:"step,omitempty"` // Pipeline step completed by the task
+}
+
+// PipelineResponse represents the response containing a pipeline and the status of its steps
+type PipelineResponse struct {
+	Pipeline entity.Pipeline `json:"pipeline"`
+}
+
+// PipelinesResponse represents the response containing the pipelines of a video
+type PipelinesResponse struct {
+	Pipelines []entity.Pipeline `json:"pipelines"`
+}
+
+// TranscriptionSegmentResponse represents the response containing a single transcription segment
+type TranscriptionSegmentResponse struct {
+	Segment entity.TranscriptionSegment `json:"segment"`
+}
+
+// TranscriptionSegmentsResponse represents the response containing a list of transcription segments
+type TranscriptionSegmentsResponse struct {
+	Segments []entity.TranscriptionSegment `json:"segments"`
+}
+
+// TranscriptionSegmentPageResponse represents the response containing one page of the segments of a transcription
+type TranscriptionSegmentPageResponse struct {
+	Segments []entity.TranscriptionSegment `json:"segments"`
+	Page     int                           `json:"page"`
+	PageSize int                           `json:"page_size"`
+	Total    int                           `json:"total"` // Number of segments of the transcription across all pages
+}
+
+// TranscriptionRevisionResponse represents the response containing a single transcription revision
+type TranscriptionRevisionResponse struct {
+	Revision entity.TranscriptionRevision `json:"revision"`
+}
+
+// TranscriptionRevisionsResponse represents the response containing the revisions of a transcription
+type TranscriptionRevisionsResponse struct {
+	Revisions []entity.TranscriptionRevision `json:"revisions"`
+}
+
+// TranscriptionDiffResponse represents the response containing the segment changes between two revisions
+type TranscriptionDiffResponse struct {
+	From    int                    `json:"from"`
+	To      int                    `json:"to"`
+	Changes []entity.SegmentChange `json:"changes"`
+}
+
+// ImportedTranscriptionResponse represents the response containing a transcription created from a subtitle file
+type ImportedTranscriptionResponse struct {
+	Transcription entity.Transcription `json:"transcription"`
+	SegmentCount  int                  `json:"segment_count"` // Number of cues imported as segments
+}
+
+// OrganizationsResponse represents the response containing the organizations of a user
+type OrganizationsResponse struct {
+	Organizations []entity.Organization `json:"organizations"`
+}
+
+// OrganizationMembersResponse represents the response containing the members of an organization
+type OrganizationMembersResponse struct {
+	Members []entity.OrganizationMember `json:"members"`
+}
+
+// OrganizationInvitesResponse represents the response containing the pending invitations of an organization
+type OrganizationInvitesResponse struct {
+	Invites []entity.OrganizationInvite `json:"invites"`
+}
+
+// PlansResponse represents the plans users can pay for
+type PlansResponse struct {
+	Plans []entity.Plan `json:"plans"`
+}
+
+// OrdersResponse represents the orders of a user
+type OrdersResponse struct {
+	Orders []entity.Order `json:"orders"`
+}
+
+// PaymentProvidersResponse represents the providers orders can be paid through
+type PaymentProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+// CheckoutResponse represents the payment created for a new order
+type CheckoutResponse struct {
+	Order    entity.Order `json:"order"`
+	PayURL   string       `json:"pay_url"`            // Payment page to open in a browser
+	Deeplink string       `json:"deeplink,omitempty"` // Opens the provider's app on a phone, if it has one
+	QRCode   []byte       `json:"qr_code"`            // PNG QR code to scan with a phone, base64 encoded
+}
+
+// PaymentStatusResponse represents the status of a payment at its provider
+type PaymentStatusResponse struct {
+	OrderID       string             `json:"order_id"`
+	Provider      string             `json:"provider"`
+	Status        entity.OrderStatus `json:"status"` // Of the order, updated with the status from the provider
+	Paid          bool               `json:"paid"`
+	Pending       bool               `json:"pending"` // The user may still pay
+	Amount        int64              `json:"amount"`
+	TransactionID string             `json:"transaction_id,omitempty"` // The provider's ID of the payment
+	Code          string             `json:"code"`                     // The provider's own status code
+	Message       string             `json:"message"`                  // From the provider
+}
+
+// RefundResponse represents a completed refund
+type RefundResponse struct {
+	OrderID       string `json:"order_id"`
+	RefundID      string `json:"refund_id,omitempty"` // ID of the refund given to the provider
+	Amount        int64  `json:"amount"`
+	TransactionID string `json:"transaction_id,omitempty"` // The provider's ID of the refund
+	// Status and RefundedAmount are those of the order after the refund
+	Status         entity.OrderStatus `json:"status"`
+	RefundedAmount int64              `json:"refunded_amount"`
+}
+
+// SubscriptionResponse represents the premium subscription of the current user
+type SubscriptionResponse struct {
+	Premium        bool                 `json:"premium"`
+	Subscription   *entity.Subscription `json:"subscription,omitempty"`     // None for a user who never subscribed
+	Plan           *entity.Plan         `json:"plan,omitempty"`             // Plan of the subscription
+	GracePeriodEnd *time.Time           `json:"grace_period_end,omitempty"` // Premium is kept until then while past due
+	Quotas         entity.Quotas        `json:"quotas"`                     // Of the plan with premium, the free ones otherwise
+}
+
+// SubscriptionPeriodsResponse represents the periods paid orders added to a subscription
+type SubscriptionPeriodsResponse struct {
+	Periods []entity.SubscriptionPeriod `json:"periods"`
+}
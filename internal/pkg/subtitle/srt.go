@@ -0,0 +1,89 @@
+package subtitle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mlvt/internal/entity"
+)
+
+// srtTimestamp matches HH:MM:SS,mmm; a dot is accepted in place of the comma as many tools write one
+var srtTimestamp = regexp.MustCompile(`^(\d{1,3}):([0-5]\d):([0-5]\d)[,.](\d{3})$`)
+
+func parseSRTTimestamp(s string) (int64, bool) {
+	match := srtTimestamp.FindStringSubmatch(s)
+	if match == nil {
+		return 0, false
+	}
+	return timestampMs(match[1], match[2], match[3], match[4]), true
+}
+
+// timestampMs converts matched hours, minutes, seconds and milliseconds into milliseconds
+func timestampMs(hours, minutes, seconds, millis string) int64 {
+	h, _ := strconv.ParseInt(hours, 10, 64)
+	m, _ := strconv.ParseInt(minutes, 10, 64)
+	s, _ := strconv.ParseInt(seconds, 10, 64)
+	ms, _ := strconv.ParseInt(millis, 10, 64)
+	return ((h*60+m)*60+s)*1000 + ms
+}
+
+// parseSRT reads SubRip cues: an optional sequence number, the timing line and one or more text lines,
+// separated by blank lines
+func parseSRT(r io.Reader) ([]entity.TranscriptionSegment, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []entity.TranscriptionSegment
+	for i := 0; i < len(lines); {
+		if strings.TrimSpace(lines[i]) == "" {
+			i++
+			continue
+		}
+
+		if !strings.Contains(lines[i], "-->") {
+			if _, err := strconv.Atoi(strings.TrimSpace(lines[i])); err != nil {
+				return nil, &ParseError{Line: i + 1, Reason: fmt.Sprintf("expected cue number, got %q", strings.TrimSpace(lines[i]))}
+			}
+			i++
+			if i == len(lines) || strings.TrimSpace(lines[i]) == "" {
+				return nil, &ParseError{Line: i + 1, Reason: "expected cue timing"}
+			}
+		}
+
+		startMs, endMs, err := parseTiming(lines[i], i+1, parseSRTTimestamp)
+		if err != nil {
+			return nil, err
+		}
+		i++
+
+		var text []string
+		for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+			text = append(text, strings.TrimSpace(lines[i]))
+		}
+		if len(text) == 0 {
+			return nil, &ParseError{Line: i + 1, Reason: "cue has no text"}
+		}
+
+		segments = append(segments, entity.TranscriptionSegment{StartMs: startMs, EndMs: endMs, Text: strings.Join(text, "\n")})
+	}
+	return segments, nil
+}
+
+// writeSRT writes the segments as numbered SubRip cues. SubRip has no speaker field, so speakers are dropped.
+func writeSRT(w io.Writer, segments []entity.TranscriptionSegment) error {
+	bw := bufio.NewWriter(w)
+	for i, segment := range segments {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n", i+1, clockTime(segment.StartMs, ","), clockTime(segment.EndMs, ","))
+		for _, line := range cueLines(segment.Text) {
+			fmt.Fprintln(bw, line)
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,170 @@
+// Package subtitle converts transcription segments from and to the SRT, WebVTT and TTML subtitle formats.
+package subtitle
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"mlvt/internal/entity"
+)
+
+// Format is a supported subtitle format
+type Format string
+
+const (
+	FormatSRT  Format = "srt"
+	FormatVTT  Format = "vtt"
+	FormatTTML Format = "ttml"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported subtitle format")
+	ErrMalformed         = errors.New("malformed subtitle file")
+)
+
+// ParseError reports the line of a subtitle file that could not be parsed
+type ParseError struct {
+	Line   int
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%v: line %d: %s", ErrMalformed, e.Line, e.Reason)
+}
+
+func (e *ParseError) Unwrap() error {
+	return ErrMalformed
+}
+
+// ParseFormat returns the format named by s, e.g. "srt", "vtt" or "ttml"
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(s))); format {
+	case FormatSRT, FormatVTT, FormatTTML:
+		return format, nil
+	case "webvtt":
+		return FormatVTT, nil
+	case "xml", "dfxp":
+		return FormatTTML, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedFormat, s)
+	}
+}
+
+// FormatFromFileName derives the format from the extension of a file name
+func FormatFromFileName(fileName string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(path.Ext(fileName), "."))
+}
+
+// ContentType returns the MIME type files of the format are stored with
+func (f Format) ContentType() string {
+	switch f {
+	case FormatVTT:
+		return "text/vtt"
+	case FormatTTML:
+		return "application/ttml+xml"
+	default:
+		return "application/x-subrip"
+	}
+}
+
+// Extension returns the file extension of the format, including the dot
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// Parse reads the cues of a subtitle file as segments in file order. It returns a *ParseError,
+// matching ErrMalformed, when a cue is malformed or the file has no cues.
+func Parse(r io.Reader, format Format) ([]entity.TranscriptionSegment, error) {
+	var (
+		segments []entity.TranscriptionSegment
+		err      error
+	)
+	switch format {
+	case FormatSRT:
+		segments, err = parseSRT(r)
+	case FormatVTT:
+		segments, err = parseVTT(r)
+	case FormatTTML:
+		segments, err = parseTTML(r)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, &ParseError{Line: 1, Reason: "no cues found"}
+	}
+	return segments, nil
+}
+
+// Write writes the segments as a subtitle file. lang is the language of the transcription, used by TTML.
+func Write(w io.Writer, format Format, segments []entity.TranscriptionSegment, lang string) error {
+	switch format {
+	case FormatSRT:
+		return writeSRT(w, segments)
+	case FormatVTT:
+		return writeVTT(w, segments)
+	case FormatTTML:
+		return writeTTML(w, segments, lang)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+}
+
+// readLines reads the whole file as lines, dropping a leading byte order mark and carriage returns
+func readLines(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.Split(text, "\n"), nil
+}
+
+// parseTiming parses a "start --> end" cue timing line, ignoring anything after the end timestamp
+// such as WebVTT cue settings
+func parseTiming(line string, lineNumber int, parseTimestamp func(string) (int64, bool)) (int64, int64, error) {
+	start, rest, found := strings.Cut(line, "-->")
+	if !found {
+		return 0, 0, &ParseError{Line: lineNumber, Reason: "expected cue timing"}
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return 0, 0, &ParseError{Line: lineNumber, Reason: "missing end timestamp"}
+	}
+
+	startMs, ok := parseTimestamp(strings.TrimSpace(start))
+	if !ok {
+		return 0, 0, &ParseError{Line: lineNumber, Reason: fmt.Sprintf("invalid start timestamp %q", strings.TrimSpace(start))}
+	}
+	endMs, ok := parseTimestamp(fields[0])
+	if !ok {
+		return 0, 0, &ParseError{Line: lineNumber, Reason: fmt.Sprintf("invalid end timestamp %q", fields[0])}
+	}
+	if endMs <= startMs {
+		return 0, 0, &ParseError{Line: lineNumber, Reason: "cue ends before it starts"}
+	}
+	return startMs, endMs, nil
+}
+
+// clockTime formats milliseconds as HH:MM:SS followed by the separator and the milliseconds
+func clockTime(ms int64, separator string) string {
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}
+
+// cueLines splits the text of a segment into its non-blank lines, as blank lines end a cue
+func cueLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,219 @@
+package subtitle
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"mlvt/internal/entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		want   []entity.TranscriptionSegment
+	}{
+		{
+			name:   "SRT",
+			format: FormatSRT,
+			input:  "\ufeff1\r\n00:00:01,000 --> 00:00:02,500\r\nHello\r\nthere\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,250\r\nGeneral Kenobi\r\n",
+			want: []entity.TranscriptionSegment{
+				{StartMs: 1000, EndMs: 2500, Text: "Hello\nthere"},
+				{StartMs: 3000, EndMs: 4250, Text: "General Kenobi"},
+			},
+		},
+		{
+			name:   "SRT Without Numbers Or Trailing Newline",
+			format: FormatSRT,
+			input:  "\n\n01:02:03.004 --> 01:02:04.000 X1:10 X2:20\nLate line",
+			want:   []entity.TranscriptionSegment{{StartMs: 3723004, EndMs: 3724000, Text: "Late line"}},
+		},
+		{
+			name:   "VTT",
+			format: FormatVTT,
+			input: "WEBVTT - Interview\nKind: captions\n\nNOTE edited by hand\nsecond note line\n\nSTYLE\n::cue { color: red }\n\n" +
+				"intro\n00:01.000 --> 00:02.000 align:start position:10%\n<v.loud Esme Weatherwax>Hello &amp; <i>welcome</i></v>\n\n" +
+				"00:00:02.500 --> 00:00:04.000\n<c.yellow>Fish</c> &lt;3\n",
+			want: []entity.TranscriptionSegment{
+				{StartMs: 1000, EndMs: 2000, Text: "Hello & welcome", Speaker: "Esme Weatherwax"},
+				{StartMs: 2500, EndMs: 4000, Text: "Fish <3"},
+			},
+		},
+		{
+			name:   "TTML",
+			format: FormatTTML,
+			input: `<?xml version="1.0" encoding="UTF-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xml:lang="en">
+  <head><metadata><ttm:agent xml:id="a1" type="person"><ttm:name type="full">Alice</ttm:name></ttm:agent></metadata></head>
+  <body>
+    <div begin="10s">
+      <p begin="00:00:01.5" end="00:00:03.000" ttm:agent="a1">Hello
+        <span>big</span> world<br/>again</p>
+      <p begin="4s" dur="1500ms" ttm:agent="b2">Bye</p>
+    </div>
+    <div><p begin="00:01:00:15" end="61.5s">Frames</p></div>
+  </body>
+</tt>`,
+			want: []entity.TranscriptionSegment{
+				{StartMs: 11500, EndMs: 13000, Text: "Hello big world\nagain", Speaker: "Alice"},
+				{StartMs: 14000, EndMs: 15500, Text: "Bye", Speaker: "b2"},
+				{StartMs: 60500, EndMs: 61500, Text: "Frames"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := Parse(strings.NewReader(tt.input), tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, segments)
+		})
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		input  string
+		line   int
+		reason string
+	}{
+		{"SRT Empty File", FormatSRT, "\n\n", 1, "no cues found"},
+		{"SRT Cue Number Not Numeric", FormatSRT, "one\n00:00:01,000 --> 00:00:02,000\nHi\n", 1, "expected cue number"},
+		{"SRT Missing Timing", FormatSRT, "1\nHello\n", 2, "expected cue timing"},
+		{"SRT Number Without Timing", FormatSRT, "1\n\n", 2, "expected cue timing"},
+		{"SRT Invalid Start", FormatSRT, "1\n00:00:1,000 --> 00:00:02,000\nHi\n", 2, "invalid start timestamp"},
+		{"SRT Invalid End", FormatSRT, "1\n00:00:01,000 --> 00:61:02,000\nHi\n", 2, "invalid end timestamp"},
+		{"SRT Missing End", FormatSRT, "1\n00:00:01,000 -->\nHi\n", 2, "missing end timestamp"},
+		{"SRT End Before Start", FormatSRT, "1\n00:00:02,000 --> 00:00:01,000\nHi\n", 2, "cue ends before it starts"},
+		{"SRT Empty Cue", FormatSRT, "1\n00:00:01,000 --> 00:00:02,000\n\n2\n00:00:03,000 --> 00:00:04,000\nHi\n", 3, "cue has no text"},
+		{"VTT Missing Header", FormatVTT, "00:01.000 --> 00:02.000\nHi\n", 1, "missing WEBVTT header"},
+		{"VTT Header Prefix Only", FormatVTT, "WEBVTTX\n\n00:01.000 --> 00:02.000\nHi\n", 1, "missing WEBVTT header"},
+		{"VTT No Cues", FormatVTT, "WEBVTT\n\nNOTE nothing here\n", 1, "no cues found"},
+		{"VTT SRT Style Timestamp", FormatVTT, "WEBVTT\n\n00:00:01,000 --> 00:00:02,000\nHi\n", 3, "invalid start timestamp"},
+		{"VTT Identifier Without Timing", FormatVTT, "WEBVTT\n\nintro\nHi\n", 4, "expected cue timing"},
+		{"VTT End Before Start", FormatVTT, "WEBVTT\n\n00:02.000 --> 00:02.000\nHi\n", 3, "cue ends before it starts"},
+		{"VTT Markup Only", FormatVTT, "WEBVTT\n\n00:01.000 --> 00:02.000\n<i></i>\n", 5, "cue has no text"},
+		{"TTML Wrong Root", FormatTTML, `<html><p begin="1s" end="2s">Hi</p></html>`, 1, "expected tt root element"},
+		{"TTML Not XML", FormatTTML, "1\n00:00:01,000 --> 00:00:02,000\n", 1, "missing tt root element"},
+		{"TTML Unclosed Element", FormatTTML, "<tt>\n<body><p begin=\"1s\" end=\"2s\">Hi</body></tt>", 2, "element <p> closed by </body>"},
+		{"TTML Missing Begin", FormatTTML, "<tt><body>\n<p end=\"2s\">Hi</p></body></tt>", 2, "p element without begin time"},
+		{"TTML Missing End", FormatTTML, "<tt><body>\n<p begin=\"1s\">Hi</p></body></tt>", 2, "p element without end time or duration"},
+		{"TTML Invalid Begin", FormatTTML, "<tt><body>\n<p begin=\"soon\" end=\"2s\">Hi</p></body></tt>", 2, `invalid begin time "soon"`},
+		{"TTML Invalid Duration", FormatTTML, "<tt><body>\n<p begin=\"1s\" dur=\"2x\">Hi</p></body></tt>", 2, "invalid duration"},
+		{"TTML End Before Start", FormatTTML, "<tt><body>\n<p begin=\"2s\" end=\"1s\">Hi</p></body></tt>", 2, "cue ends before it starts"},
+		{"TTML Empty Paragraph", FormatTTML, "<tt><body>\n<p begin=\"1s\" end=\"2s\"> <br/> </p></body></tt>", 2, "cue has no text"},
+		{"TTML No Paragraphs", FormatTTML, "<tt><body/></tt>", 1, "no cues found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, err := Parse(strings.NewReader(tt.input), tt.format)
+			assert.Nil(t, segments)
+			require.ErrorIs(t, err, ErrMalformed)
+
+			var parseErr *ParseError
+			require.ErrorAs(t, err, &parseErr)
+			assert.Equal(t, tt.line, parseErr.Line)
+			assert.Contains(t, parseErr.Reason, tt.reason)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	segments := []entity.TranscriptionSegment{
+		{StartMs: 1000, EndMs: 2500, Text: "Hello\n\nthere", Speaker: "Alice"},
+		{StartMs: 3723004, EndMs: 3724000, Text: "Fish & <chips>"},
+	}
+
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatSRT, "1\n00:00:01,000 --> 00:00:02,500\nHello\nthere\n\n2\n01:02:03,004 --> 01:02:04,000\nFish & <chips>\n\n"},
+		{FormatVTT, "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\n<v Alice>Hello\nthere\n\n2\n01:02:03.004 --> 01:02:04.000\nFish &amp; &lt;chips&gt;\n\n"},
+		{FormatTTML, `<?xml version="1.0" encoding="UTF-8"?>
+<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttm="http://www.w3.org/ns/ttml#metadata" xml:lang="en">
+  <head>
+    <metadata>
+      <ttm:agent xml:id="speaker1" type="person"><ttm:name type="full">Alice</ttm:name></ttm:agent>
+    </metadata>
+  </head>
+  <body>
+    <div>
+      <p begin="00:00:01.000" end="00:00:02.500" ttm:agent="speaker1">Hello<br/>there</p>
+      <p begin="01:02:03.004" end="01:02:04.000">Fish &amp; &lt;chips&gt;</p>
+    </div>
+  </body>
+</tt>
+`},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Write(&buf, tt.format, segments, "en"))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	segments := []entity.TranscriptionSegment{
+		{StartMs: 0, EndMs: 1500, Text: "Hello", Speaker: "SPEAKER_1"},
+		{StartMs: 1500, EndMs: 3200, Text: "two\nlines & more", Speaker: "SPEAKER_2"},
+		{StartMs: 5000, EndMs: 7000, Text: "no speaker"},
+	}
+
+	for _, format := range []Format{FormatSRT, FormatVTT, FormatTTML} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Write(&buf, format, segments, "en"))
+
+			parsed, err := Parse(&buf, format)
+			require.NoError(t, err)
+			require.Len(t, parsed, len(segments))
+			for i := range segments {
+				want := segments[i]
+				if format == FormatSRT {
+					want.Speaker = "" // SubRip has no speakers
+				}
+				assert.Equal(t, want, parsed[i])
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Format
+		ok    bool
+	}{
+		{"srt", FormatSRT, true},
+		{" VTT ", FormatVTT, true},
+		{"webvtt", FormatVTT, true},
+		{"ttml", FormatTTML, true},
+		{"dfxp", FormatTTML, true},
+		{"txt", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		format, err := ParseFormat(tt.input)
+		assert.Equal(t, tt.want, format, tt.input)
+		if tt.ok {
+			assert.NoError(t, err, tt.input)
+		} else {
+			assert.ErrorIs(t, err, ErrUnsupportedFormat, tt.input)
+		}
+	}
+
+	format, err := FormatFromFileName("episode_1.en.vtt")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatVTT, format)
+	_, err = FormatFromFileName("episode_1")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
@@ -0,0 +1,261 @@
+package subtitle
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mlvt/internal/entity"
+)
+
+const (
+	ttmlNamespace         = "http://www.w3.org/ns/ttml"
+	ttmlMetadataNamespace = "http://www.w3.org/ns/ttml#metadata"
+
+	// ttmlFrameRate is the TTML default frame rate, used for clock times with a frames field
+	ttmlFrameRate = 30
+)
+
+var (
+	// ttmlClockTime matches HH:MM:SS with an optional fraction (.fff) or frames (:FF) field
+	ttmlClockTime = regexp.MustCompile(`^(\d+):([0-5]\d):([0-5]\d)(?:\.(\d+)|:(\d+))?$`)
+	// ttmlOffsetTime matches offset times in hours, minutes, seconds or milliseconds, e.g. 1.5s or 1500ms
+	ttmlOffsetTime = regexp.MustCompile(`^(\d+(?:\.\d+)?)(h|m|s|ms)$`)
+)
+
+// parseTTMLTime parses a TTML clock or offset time expression into milliseconds
+func parseTTMLTime(s string) (int64, bool) {
+	s = strings.TrimSpace(s)
+	if match := ttmlClockTime.FindStringSubmatch(s); match != nil {
+		ms := timestampMs(match[1], match[2], match[3], "0")
+		if match[4] != "" {
+			fraction, _ := strconv.ParseFloat("0."+match[4], 64)
+			ms += int64(fraction*1000 + 0.5)
+		}
+		if match[5] != "" {
+			frames, _ := strconv.ParseInt(match[5], 10, 64)
+			ms += frames * 1000 / ttmlFrameRate
+		}
+		return ms, true
+	}
+	if match := ttmlOffsetTime.FindStringSubmatch(s); match != nil {
+		value, _ := strconv.ParseFloat(match[1], 64)
+		unit := map[string]float64{"h": 3600000, "m": 60000, "s": 1000, "ms": 1}[match[2]]
+		return int64(value*unit + 0.5), true
+	}
+	return 0, false
+}
+
+// ttmlCue collects the paragraph being parsed
+type ttmlCue struct {
+	line    int
+	startMs int64
+	endMs   int64
+	agent   string
+	text    strings.Builder
+}
+
+// parseTTML reads the p elements of a TTML document. Begin times of enclosing body, div and span elements
+// are added to the times of their children; the speaker is the name of the ttm:agent of the paragraph.
+func parseTTML(r io.Reader) ([]entity.TranscriptionSegment, error) {
+	decoder := xml.NewDecoder(r)
+	lineAt := func() int {
+		line, _ := decoder.InputPos()
+		return line
+	}
+
+	var (
+		segments  []entity.TranscriptionSegment
+		agents    = map[string]string{}
+		agentID   string
+		inName    bool
+		begins    []int64 // Begin times of the open elements, which the times of their children are relative to
+		cue       *ttmlCue
+		agentRefs []string
+		sawRoot   bool
+	)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, &ParseError{Line: lineAt(), Reason: err.Error()}
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if !sawRoot {
+				if t.Name.Local != "tt" {
+					return nil, &ParseError{Line: lineAt(), Reason: fmt.Sprintf("expected tt root element, got %q", t.Name.Local)}
+				}
+				sawRoot = true
+			}
+
+			parentBegin := int64(0)
+			if len(begins) > 0 {
+				parentBegin = begins[len(begins)-1]
+			}
+			begin, end, dur := ttmlAttr(t, "begin"), ttmlAttr(t, "end"), ttmlAttr(t, "dur")
+			elementBegin := parentBegin
+			if begin != "" {
+				offset, ok := parseTTMLTime(begin)
+				if !ok {
+					return nil, &ParseError{Line: lineAt(), Reason: fmt.Sprintf("invalid begin time %q", begin)}
+				}
+				elementBegin += offset
+			}
+			begins = append(begins, elementBegin)
+
+			switch t.Name.Local {
+			case "agent":
+				agentID = ttmlAttr(t, "id")
+			case "name":
+				inName = agentID != ""
+			case "p":
+				if cue != nil {
+					return nil, &ParseError{Line: lineAt(), Reason: "nested p element"}
+				}
+				if begin == "" {
+					return nil, &ParseError{Line: lineAt(), Reason: "p element without begin time"}
+				}
+				cue = &ttmlCue{line: lineAt(), startMs: elementBegin, agent: ttmlAttr(t, "agent")}
+				switch {
+				case end != "":
+					endMs, ok := parseTTMLTime(end)
+					if !ok {
+						return nil, &ParseError{Line: lineAt(), Reason: fmt.Sprintf("invalid end time %q", end)}
+					}
+					cue.endMs = parentBegin + endMs
+				case dur != "":
+					durMs, ok := parseTTMLTime(dur)
+					if !ok {
+						return nil, &ParseError{Line: lineAt(), Reason: fmt.Sprintf("invalid duration %q", dur)}
+					}
+					cue.endMs = cue.startMs + durMs
+				default:
+					return nil, &ParseError{Line: lineAt(), Reason: "p element without end time or duration"}
+				}
+				if cue.endMs <= cue.startMs {
+					return nil, &ParseError{Line: lineAt(), Reason: "cue ends before it starts"}
+				}
+			case "br":
+				if cue != nil {
+					cue.text.WriteString("\n")
+				}
+			}
+
+		case xml.EndElement:
+			if len(begins) > 0 {
+				begins = begins[:len(begins)-1]
+			}
+			switch t.Name.Local {
+			case "agent":
+				agentID = ""
+			case "name":
+				inName = false
+			case "p":
+				var lines []string
+				for _, line := range cueLines(cue.text.String()) {
+					lines = append(lines, strings.Join(strings.Fields(line), " "))
+				}
+				segment := entity.TranscriptionSegment{StartMs: cue.startMs, EndMs: cue.endMs, Text: strings.Join(lines, "\n")}
+				if segment.Text == "" {
+					return nil, &ParseError{Line: cue.line, Reason: "cue has no text"}
+				}
+				segments = append(segments, segment)
+				agentRefs = append(agentRefs, cue.agent)
+				cue = nil
+			}
+
+		case xml.CharData:
+			switch {
+			case cue != nil:
+				// Line breaks in the markup are layout; only br elements break lines
+				cue.text.WriteString(strings.Map(func(r rune) rune {
+					if r == '\n' || r == '\r' || r == '\t' {
+						return ' '
+					}
+					return r
+				}, string(t)))
+			case inName:
+				agents[agentID] += strings.TrimSpace(string(t))
+			}
+		}
+	}
+	if !sawRoot {
+		return nil, &ParseError{Line: 1, Reason: "missing tt root element"}
+	}
+
+	for i, ref := range agentRefs {
+		if ref == "" {
+			continue
+		}
+		if name := agents[ref]; name != "" {
+			segments[i].Speaker = name
+		} else {
+			segments[i].Speaker = ref
+		}
+	}
+	return segments, nil
+}
+
+// ttmlAttr returns the value of an attribute by local name, ignoring its namespace
+func ttmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// writeTTML writes the segments as p elements of a single div. Speakers are declared as ttm:agent
+// elements in the head and referenced from the paragraphs.
+func writeTTML(w io.Writer, segments []entity.TranscriptionSegment, lang string) error {
+	agentIDs := map[string]string{}
+	var speakers []string
+	for _, segment := range segments {
+		if segment.Speaker != "" && agentIDs[segment.Speaker] == "" {
+			speakers = append(speakers, segment.Speaker)
+			agentIDs[segment.Speaker] = fmt.Sprintf("speaker%d", len(speakers))
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, xml.Header)
+	fmt.Fprintf(bw, "<tt xmlns=%q xmlns:ttm=%q xml:lang=\"%s\">\n", ttmlNamespace, ttmlMetadataNamespace, escapeXML(lang))
+	if len(speakers) > 0 {
+		fmt.Fprint(bw, "  <head>\n    <metadata>\n")
+		for _, speaker := range speakers {
+			fmt.Fprintf(bw, "      <ttm:agent xml:id=\"%s\" type=\"person\"><ttm:name type=\"full\">%s</ttm:name></ttm:agent>\n",
+				agentIDs[speaker], escapeXML(speaker))
+		}
+		fmt.Fprint(bw, "    </metadata>\n  </head>\n")
+	}
+	fmt.Fprint(bw, "  <body>\n    <div>\n")
+	for _, segment := range segments {
+		fmt.Fprintf(bw, "      <p begin=\"%s\" end=\"%s\"", clockTime(segment.StartMs, "."), clockTime(segment.EndMs, "."))
+		if segment.Speaker != "" {
+			fmt.Fprintf(bw, " ttm:agent=\"%s\"", agentIDs[segment.Speaker])
+		}
+		lines := cueLines(segment.Text)
+		for i := range lines {
+			lines[i] = escapeXML(lines[i])
+		}
+		fmt.Fprintf(bw, ">%s</p>\n", strings.Join(lines, "<br/>"))
+	}
+	fmt.Fprint(bw, "    </div>\n  </body>\n</tt>\n")
+	return bw.Flush()
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
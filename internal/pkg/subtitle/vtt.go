@@ -0,0 +1,131 @@
+package subtitle
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+
+	"mlvt/internal/entity"
+)
+
+var (
+	// vttTimestamp matches [HH:]MM:SS.mmm
+	vttTimestamp = regexp.MustCompile(`^(?:(\d+):)?([0-5]\d):([0-5]\d)\.(\d{3})$`)
+	// vttVoice matches an opening voice span such as <v Esme> or <v.loud Esme>
+	vttVoice = regexp.MustCompile(`^<v(?:\.[^\s>]*)?\s+([^>]+)>`)
+	vttTag   = regexp.MustCompile(`<[^>]*>`)
+)
+
+func parseVTTTimestamp(s string) (int64, bool) {
+	match := vttTimestamp.FindStringSubmatch(s)
+	if match == nil {
+		return 0, false
+	}
+	hours := match[1]
+	if hours == "" {
+		hours = "0"
+	}
+	return timestampMs(hours, match[2], match[3], match[4]), true
+}
+
+// parseVTT reads WebVTT cues. The speaker is taken from a voice span opening the cue; other markup is
+// stripped and NOTE, STYLE and REGION blocks are skipped.
+func parseVTT(r io.Reader) ([]entity.TranscriptionSegment, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	if header := lines[0]; header != "WEBVTT" && !strings.HasPrefix(header, "WEBVTT ") && !strings.HasPrefix(header, "WEBVTT\t") {
+		return nil, &ParseError{Line: 1, Reason: "missing WEBVTT header"}
+	}
+
+	i := skipBlock(lines, 0)
+	var segments []entity.TranscriptionSegment
+	for i < len(lines) {
+		if strings.TrimSpace(lines[i]) == "" {
+			i++
+			continue
+		}
+		if isVTTMetadataBlock(lines[i]) {
+			i = skipBlock(lines, i)
+			continue
+		}
+
+		if !strings.Contains(lines[i], "-->") {
+			// Cue identifier
+			i++
+			if i == len(lines) || strings.TrimSpace(lines[i]) == "" {
+				return nil, &ParseError{Line: i + 1, Reason: "expected cue timing"}
+			}
+		}
+
+		startMs, endMs, err := parseTiming(lines[i], i+1, parseVTTTimestamp)
+		if err != nil {
+			return nil, err
+		}
+		i++
+
+		var payload []string
+		for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+			payload = append(payload, lines[i])
+		}
+
+		segment := entity.TranscriptionSegment{StartMs: startMs, EndMs: endMs}
+		if len(payload) > 0 {
+			if match := vttVoice.FindStringSubmatch(payload[0]); match != nil {
+				segment.Speaker = html.UnescapeString(strings.TrimSpace(match[1]))
+			}
+		}
+		var text []string
+		for _, line := range payload {
+			if line = strings.TrimSpace(html.UnescapeString(vttTag.ReplaceAllString(line, ""))); line != "" {
+				text = append(text, line)
+			}
+		}
+		if len(text) == 0 {
+			return nil, &ParseError{Line: i + 1, Reason: "cue has no text"}
+		}
+		segment.Text = strings.Join(text, "\n")
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+func isVTTMetadataBlock(line string) bool {
+	for _, keyword := range []string{"NOTE", "STYLE", "REGION"} {
+		if line == keyword || strings.HasPrefix(line, keyword+" ") || strings.HasPrefix(line, keyword+"\t") {
+			return true
+		}
+	}
+	return false
+}
+
+// skipBlock returns the index of the first blank line after the block starting at i
+func skipBlock(lines []string, i int) int {
+	for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+		i++
+	}
+	return i
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// writeVTT writes the segments as numbered WebVTT cues, wrapping the text of segments with a speaker in a voice span
+func writeVTT(w io.Writer, segments []entity.TranscriptionSegment) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, "WEBVTT\n\n")
+	for i, segment := range segments {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n", i+1, clockTime(segment.StartMs, "."), clockTime(segment.EndMs, "."))
+		for j, line := range cueLines(segment.Text) {
+			if j == 0 && segment.Speaker != "" {
+				fmt.Fprintf(bw, "<v %s>", vttEscaper.Replace(segment.Speaker))
+			}
+			fmt.Fprintln(bw, vttEscaper.Replace(line))
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"mlvt/internal/entity"
+	"strings"
+	"time"
+)
+
+// APIKeyRepository stores the hashes of the personal API keys of users
+type APIKeyRepository interface {
+	CreateAPIKey(key *entity.APIKey) error
+	GetAPIKeyByHash(keyHash string) (*entity.APIKey, error)
+	ListAPIKeysByUserID(userID uint64) ([]entity.APIKey, error) // Keys not revoked yet, newest first
+	CountAPIKeys(userID uint64) (int, error)                    // Counts the keys not revoked yet
+	RevokeAPIKey(userID, keyID uint64) (bool, error)            // Reports whether the user had the key and it was not revoked
+	TouchAPIKey(keyID uint64, usedAt time.Time) error
+}
+
+type apiKeyRepo struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepo(db *sql.DB) APIKeyRepository {
+	return &apiKeyRepo{db: db}
+}
+
+const apiKeyColumns = `id, user_id, name, key_prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+// CreateAPIKey inserts an API key, setting its ID and creation time
+func (r *apiKeyRepo) CreateAPIKey(key *entity.APIKey) error {
+	key.CreatedAt = time.Now()
+	query := `
+		INSERT INTO api_keys (user_id, name, key_prefix, key_hash, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, key.UserID, key.Name, key.Prefix, key.KeyHash, joinAPIKeyScopes(key.Scopes), key.ExpiresAt, key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	key.ID = uint64(id)
+	return nil
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of its value, revoked or not
+func (r *apiKeyRepo) GetAPIKeyByHash(keyHash string) (*entity.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = ?`, keyHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ListAPIKeysByUserID retrieves the API keys of the user that were not revoked, the newest first
+func (r *apiKeyRepo) ListAPIKeysByUserID(userID uint64) ([]entity.APIKey, error) {
+	rows, err := r.db.Query(`SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = ? AND revoked_at IS NULL ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %v", err)
+	}
+	defer rows.Close()
+
+	keys := []entity.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// CountAPIKeys counts the API keys of the user that were not revoked
+func (r *apiKeyRepo) CountAPIKeys(userID uint64) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM api_keys WHERE user_id = ? AND revoked_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// RevokeAPIKey marks an API key of the user as revoked, so it stops working at once
+func (r *apiKeyRepo) RevokeAPIKey(userID, keyID uint64) (bool, error) {
+	result, err := r.db.Exec(`UPDATE api_keys SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`, time.Now(), keyID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke API key: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// TouchAPIKey records when the API key was last used
+func (r *apiKeyRepo) TouchAPIKey(keyID uint64, usedAt time.Time) error {
+	if _, err := r.db.Exec(`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, usedAt, keyID); err != nil {
+		return fmt.Errorf("failed to update API key: %v", err)
+	}
+	return nil
+}
+
+func scanAPIKey(row rowScanner) (*entity.APIKey, error) {
+	key := &entity.APIKey{}
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &scopes, &expiresAt, &lastUsedAt, &revokedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Scopes = splitAPIKeyScopes(scopes)
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
+
+func joinAPIKeyScopes(scopes []entity.APIKeyScope) string {
+	values := make([]string, len(scopes))
+	for i, scope := range scopes {
+		values[i] = string(scope)
+	}
+	return strings.Join(values, ",")
+}
+
+func splitAPIKeyScopes(value string) []entity.APIKeyScope {
+	scopes := []entity.APIKeyScope{}
+	for _, scope := range strings.Split(value, ",") {
+		if scope != "" {
+			scopes = append(scopes, entity.APIKeyScope(scope))
+		}
+	}
+	return scopes
+}
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"database/sql"
+	"fmt"
 	"mlvt/internal/entity"
 	"time"
 )
@@ -11,9 +12,11 @@ type AudioRepository interface {
 	GetAudioByID(audioID uint64) (*entity.Audio, error)
 	GetAudioByIDAndUserID(audioID, userID uint64) (*entity.Audio, error)
 	ListAudiosByUserID(userID uint64) ([]entity.Audio, error)
+	ListAudiosByOrgID(orgID uint64) ([]entity.Audio, error)
 	GetAudioByVideoID(videoID, audioID uint64) (*entity.Audio, error)
 	ListAudiosByVideoID(videoID uint64) ([]entity.Audio, error)
 	DeleteAudioByID(audioID uint64) error
+	UpdateAudioFileInfo(audio *entity.Audio) error
 }
 
 type audioRepo struct {
@@ -27,27 +30,35 @@ func NewAudioRepository(db *sql.DB) AudioRepository {
 // CreateAudio inserts a new audio record into the database
 func (r *audioRepo) CreateAudio(audio *entity.Audio) error {
 	query := `
-		INSERT INTO audios (video_id, user_id, duration, lang, folder, file_name, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+		INSERT INTO audios (video_id, user_id, org_id, duration, lang, folder, file_name, file_size, etag, content_type, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
+	if audio.Status == "" {
+		audio.Status = entity.FileStatusReady
+	}
 	now := time.Now()
-	_, err := r.db.Exec(query,
-		audio.VideoID, audio.UserID, audio.Duration, audio.Lang, audio.Folder, audio.FileName, now, now)
+	result, err := r.db.Exec(query,
+		audio.VideoID, audio.UserID, audio.OrgID, audio.Duration, audio.Lang, audio.Folder, audio.FileName,
+		audio.FileSize, audio.ETag, audio.ContentType, audio.Status, now, now)
+	if err != nil {
+		return err
+	}
 
-	return err
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	audio.ID = uint64(id)
+	return nil
 }
 
-// GetAudioByID fetches an audio by its ID and user ID
-func (r *audioRepo) GetAudioByID(audioID uint64) (*entity.Audio, error) {
-	query := `SELECT id, video_id, user_id, duration, lang, folder, file_name, created_at, updated_at
-	          FROM audios WHERE id = ?`
-
-	row := r.db.QueryRow(query, audioID)
-
-	audio := &entity.Audio{}
-	err := row.Scan(&audio.ID, &audio.VideoID, &audio.UserID, &audio.Duration, &audio.Lang, &audio.Folder,
-		&audio.FileName, &audio.CreatedAt, &audio.UpdatedAt)
+// audioColumns are the columns scanAudio reads, in order
+const audioColumns = `id, video_id, user_id, org_id, duration, lang, folder, file_name, file_size, etag, content_type, status, created_at, updated_at`
 
+// GetAudioByID fetches an audio by its ID
+func (r *audioRepo) GetAudioByID(audioID uint64) (*entity.Audio, error) {
+	query := `SELECT ` + audioColumns + ` FROM audios WHERE id = ?`
+	audio, err := scanAudio(r.db.QueryRow(query, audioID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -56,14 +67,8 @@ func (r *audioRepo) GetAudioByID(audioID uint64) (*entity.Audio, error) {
 
 // GetAudioByIDAndUserID retrieves a single audio by its ID and User ID (owner)
 func (r *audioRepo) GetAudioByIDAndUserID(audioID, userID uint64) (*entity.Audio, error) {
-	query := `
-		SELECT id, video_id, user_id, duration, lang, folder, file_name, created_at, updated_at
-		FROM audios WHERE id = ? AND user_id = ?`
-
-	row := r.db.QueryRow(query, audioID, userID)
-
-	audio := &entity.Audio{}
-	err := row.Scan(&audio.ID, &audio.VideoID, &audio.UserID, &audio.Duration, &audio.Lang, &audio.Folder, &audio.FileName, &audio.CreatedAt, &audio.UpdatedAt)
+	query := `SELECT ` + audioColumns + ` FROM audios WHERE id = ? AND user_id = ?`
+	audio, err := scanAudio(r.db.QueryRow(query, audioID, userID))
 	if err == sql.ErrNoRows {
 		return nil, nil // No record found
 	}
@@ -72,39 +77,18 @@ func (r *audioRepo) GetAudioByIDAndUserID(audioID, userID uint64) (*entity.Audio
 
 // ListAudiosByUserID returns all audios associated with a given user ID
 func (r *audioRepo) ListAudiosByUserID(userID uint64) ([]entity.Audio, error) {
-	query := `SELECT id, video_id, user_id, duration, lang, folder, file_name, created_at, updated_at
-	          FROM audios WHERE user_id = ?`
-
-	rows, err := r.db.Query(query, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var audios []entity.Audio
-	for rows.Next() {
-		var audio entity.Audio
-		if err := rows.Scan(&audio.ID, &audio.VideoID, &audio.UserID, &audio.Duration, &audio.Lang, &audio.Folder,
-			&audio.FileName, &audio.CreatedAt, &audio.UpdatedAt); err != nil {
-			return nil, err
-		}
-		audios = append(audios, audio)
-	}
+	return r.listAudios(`SELECT `+audioColumns+` FROM audios WHERE user_id = ?`, userID)
+}
 
-	return audios, nil
+// ListAudiosByOrgID returns all audios shared with an organization
+func (r *audioRepo) ListAudiosByOrgID(orgID uint64) ([]entity.Audio, error) {
+	return r.listAudios(`SELECT `+audioColumns+` FROM audios WHERE org_id = ?`, orgID)
 }
 
 // GetAudioByVideoID retrieves a specific audio by its video ID and audio ID
 func (r *audioRepo) GetAudioByVideoID(videoID, audioID uint64) (*entity.Audio, error) {
-	query := `SELECT id, video_id, user_id, duration, lang, folder, file_name, created_at, updated_at
-	          FROM audios WHERE video_id = ? AND id = ?`
-
-	row := r.db.QueryRow(query, videoID, audioID)
-
-	audio := &entity.Audio{}
-	err := row.Scan(&audio.ID, &audio.VideoID, &audio.UserID, &audio.Duration, &audio.Lang, &audio.Folder,
-		&audio.FileName, &audio.CreatedAt, &audio.UpdatedAt)
-
+	query := `SELECT ` + audioColumns + ` FROM audios WHERE video_id = ? AND id = ?`
+	audio, err := scanAudio(r.db.QueryRow(query, videoID, audioID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -113,10 +97,11 @@ func (r *audioRepo) GetAudioByVideoID(videoID, audioID uint64) (*entity.Audio, e
 
 // ListAudiosByVideoID returns all audios associated with a given video ID
 func (r *audioRepo) ListAudiosByVideoID(videoID uint64) ([]entity.Audio, error) {
-	query := `SELECT id, video_id, user_id, duration, lang, folder, file_name, created_at, updated_at
-	          FROM audios WHERE video_id = ?`
+	return r.listAudios(`SELECT `+audioColumns+` FROM audios WHERE video_id = ?`, videoID)
+}
 
-	rows, err := r.db.Query(query, videoID)
+func (r *audioRepo) listAudios(query string, args ...interface{}) ([]entity.Audio, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -124,15 +109,25 @@ func (r *audioRepo) ListAudiosByVideoID(videoID uint64) ([]entity.Audio, error)
 
 	var audios []entity.Audio
 	for rows.Next() {
-		var audio entity.Audio
-		if err := rows.Scan(&audio.ID, &audio.VideoID, &audio.UserID, &audio.Duration, &audio.Lang, &audio.Folder,
-			&audio.FileName, &audio.CreatedAt, &audio.UpdatedAt); err != nil {
+		audio, err := scanAudio(rows)
+		if err != nil {
 			return nil, err
 		}
-		audios = append(audios, audio)
+		audios = append(audios, *audio)
 	}
+	return audios, rows.Err()
+}
 
-	return audios, nil
+func scanAudio(row rowScanner) (*entity.Audio, error) {
+	audio := &entity.Audio{}
+	var orgID sql.NullInt64
+	err := row.Scan(&audio.ID, &audio.VideoID, &audio.UserID, &orgID, &audio.Duration, &audio.Lang, &audio.Folder,
+		&audio.FileName, &audio.FileSize, &audio.ETag, &audio.ContentType, &audio.Status, &audio.CreatedAt, &audio.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	audio.OrgID = nullableID(orgID)
+	return audio, nil
 }
 
 // DeleteAudioByID deletes an audio record by its ID
@@ -141,3 +136,26 @@ func (r *audioRepo) DeleteAudioByID(audioID uint64) error {
 	_, err := r.db.Exec(query, audioID)
 	return err
 }
+
+// UpdateAudioFileInfo records the size, ETag and content type of the stored file along with the status
+func (r *audioRepo) UpdateAudioFileInfo(audio *entity.Audio) error {
+	query := `
+		UPDATE audios
+		SET file_size = ?, etag = ?, content_type = ?, status = ?, updated_at = ?
+		WHERE id = ?`
+	now := time.Now()
+	result, err := r.db.Exec(query, audio.FileSize, audio.ETag, audio.ContentType, audio.Status, now, audio.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update audio file info: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no audio found with id %d", audio.ID)
+	}
+	audio.UpdatedAt = now
+	return nil
+}
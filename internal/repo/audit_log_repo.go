@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"database/sql"
+	"mlvt/internal/entity"
+	"time"
+)
+
+// AuditLogRepository stores the audit trail of the admin API
+type AuditLogRepository interface {
+	CreateAuditLog(auditLog *entity.AuditLog) error
+	ListAuditLogs(targetUserID uint64, limit, offset int) ([]entity.AuditLog, error) // targetUserID 0 lists the logs of every user
+	CountAuditLogs(targetUserID uint64) (int, error)
+}
+
+type auditLogRepo struct {
+	db *sql.DB
+}
+
+func NewAuditLogRepo(db *sql.DB) AuditLogRepository {
+	return &auditLogRepo{db: db}
+}
+
+const auditLogColumns = `id, actor_id, action, target_user_id, reason, details, created_at`
+
+// CreateAuditLog inserts an audit log, setting its ID and creation time
+func (r *auditLogRepo) CreateAuditLog(auditLog *entity.AuditLog) error {
+	auditLog.CreatedAt = time.Now()
+	query := `
+		INSERT INTO audit_logs (actor_id, action, target_user_id, reason, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, auditLog.ActorID, auditLog.Action, auditLog.TargetUserID, auditLog.Reason, auditLog.Details, auditLog.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	auditLog.ID = uint64(id)
+	return nil
+}
+
+// ListAuditLogs lists at most limit audit logs, newest first, skipping the first offset
+func (r *auditLogRepo) ListAuditLogs(targetUserID uint64, limit, offset int) ([]entity.AuditLog, error) {
+	where, args := auditLogFilterClause(targetUserID)
+	args = append(args, limit, offset)
+	rows, err := r.db.Query(`SELECT `+auditLogColumns+` FROM audit_logs`+where+` ORDER BY id DESC LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var auditLogs []entity.AuditLog
+	for rows.Next() {
+		var auditLog entity.AuditLog
+		if err := rows.Scan(&auditLog.ID, &auditLog.ActorID, &auditLog.Action, &auditLog.TargetUserID, &auditLog.Reason, &auditLog.Details, &auditLog.CreatedAt); err != nil {
+			return nil, err
+		}
+		auditLogs = append(auditLogs, auditLog)
+	}
+	return auditLogs, rows.Err()
+}
+
+// CountAuditLogs returns the number of audit logs of a user, or of every user when targetUserID is 0
+func (r *auditLogRepo) CountAuditLogs(targetUserID uint64) (int, error) {
+	where, args := auditLogFilterClause(targetUserID)
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM audit_logs`+where, args...).Scan(&count)
+	return count, err
+}
+
+func auditLogFilterClause(targetUserID uint64) (string, []interface{}) {
+	if targetUserID == 0 {
+		return "", nil
+	}
+	return " WHERE target_user_id = ?", []interface{}{targetUserID}
+}
@@ -0,0 +1,258 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"strings"
+	"time"
+)
+
+// DefaultJobMaxAttempts is used when a job is enqueued without MaxAttempts
+const DefaultJobMaxAttempts = 5
+
+// ErrJobLeaseLost is returned when a worker updates a job it no longer holds the lease for
+var ErrJobLeaseLost = errors.New("job lease lost")
+
+// leaseRetries bounds how often LeaseJob retries when another worker claims the same job first
+const leaseRetries = 3
+
+// JobFilter selects the jobs a worker can lease
+type JobFilter struct {
+	Types     []entity.JobType
+	Languages []string // When set, jobs needing another language are skipped
+}
+
+type JobRepository interface {
+	CreateJob(job *entity.Job) error
+	GetJobByID(jobID uint64) (*entity.Job, error)
+	ListJobsByStatus(status entity.JobStatus, limit int) ([]entity.Job, error)
+	LeaseJob(filter JobFilter, owner string, lease time.Duration) (*entity.Job, error)
+	ExtendJobLease(jobID uint64, owner string, lease time.Duration) error
+	ReportJobProgress(jobID uint64, owner string, progress int, lease time.Duration) error
+	CompleteJob(jobID uint64, owner string) error
+	RetryJob(jobID uint64, owner, lastError string, runAt time.Time) error
+	DeadLetterJob(jobID uint64, owner, lastError string) error
+	ReleaseJob(jobID uint64, owner string) error
+}
+
+type jobRepo struct {
+	db *sql.DB
+}
+
+func NewJobRepo(db *sql.DB) JobRepository {
+	return &jobRepo{db: db}
+}
+
+const jobColumns = `id, type, payload, status, source_lang, target_lang, progress, attempts, max_attempts, run_at, lease_owner, leased_until, last_error, created_at, updated_at`
+
+// CreateJob enqueues a job. Empty fields default to a queued job runnable now with DefaultJobMaxAttempts.
+func (r *jobRepo) CreateJob(job *entity.Job) error {
+	now := time.Now().UTC()
+	if job.Status == "" {
+		job.Status = entity.JobStatusQueued
+	}
+	if job.Payload == "" {
+		job.Payload = "{}"
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = DefaultJobMaxAttempts
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = now
+	}
+	job.RunAt = job.RunAt.UTC()
+
+	query := `
+		INSERT INTO jobs (type, payload, status, source_lang, target_lang, attempts, max_attempts, run_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, 0, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, job.Type, job.Payload, job.Status, job.SourceLang, job.TargetLang, job.MaxAttempts, job.RunAt, now, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	job.ID = uint64(id)
+	job.Attempts = 0
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	return nil
+}
+
+// GetJobByID retrieves a job by its ID
+func (r *jobRepo) GetJobByID(jobID uint64) (*entity.Job, error) {
+	row := r.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, jobID)
+	job, err := scanJob(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// ListJobsByStatus lists jobs in the given status, oldest first
+func (r *jobRepo) ListJobsByStatus(status entity.JobStatus, limit int) ([]entity.Job, error) {
+	rows, err := r.db.Query(`SELECT `+jobColumns+` FROM jobs WHERE status = ? ORDER BY updated_at, id LIMIT ?`, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []entity.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// LeaseJob claims the next runnable job matching the filter for owner until the lease expires.
+// Queued jobs whose run_at has passed and running jobs whose lease expired are both runnable.
+// Jobs whose lease expired on their last attempt are dead-lettered instead. It returns nil when nothing is runnable.
+func (r *jobRepo) LeaseJob(filter JobFilter, owner string, lease time.Duration) (*entity.Job, error) {
+	if len(filter.Types) == 0 {
+		return nil, nil
+	}
+	now := time.Now().UTC()
+
+	_, err := r.db.Exec(`
+		UPDATE jobs
+		SET status = ?, last_error = ?, lease_owner = '', leased_until = NULL, updated_at = ?
+		WHERE status = ? AND leased_until <= ? AND attempts >= max_attempts`,
+		entity.JobStatusDead, "lease expired on the last attempt", now, entity.JobStatusRunning, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dead-letter expired jobs: %v", err)
+	}
+
+	runnable := `((status = ? AND run_at <= ?) OR (status = ? AND leased_until <= ?))`
+	where := `type IN (` + placeholders(len(filter.Types)) + `) AND ` + runnable
+	args := []interface{}{}
+	for _, jobType := range filter.Types {
+		args = append(args, jobType)
+	}
+	args = append(args, entity.JobStatusQueued, now, entity.JobStatusRunning, now)
+	if len(filter.Languages) > 0 {
+		languages := placeholders(len(filter.Languages))
+		where += ` AND (source_lang = '' OR source_lang IN (` + languages + `)) AND (target_lang = '' OR target_lang IN (` + languages + `))`
+		for i := 0; i < 2; i++ {
+			for _, language := range filter.Languages {
+				args = append(args, language)
+			}
+		}
+	}
+
+	for i := 0; i < leaseRetries; i++ {
+		var jobID uint64
+		err := r.db.QueryRow(`SELECT id FROM jobs WHERE `+where+` ORDER BY run_at, id LIMIT 1`, args...).Scan(&jobID)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// The runnable condition is repeated so only one worker wins a race for the same row
+		result, err := r.db.Exec(`
+			UPDATE jobs
+			SET status = ?, lease_owner = ?, leased_until = ?, attempts = attempts + 1, updated_at = ?
+			WHERE id = ? AND `+runnable,
+			entity.JobStatusRunning, owner, now.Add(lease), now, jobID,
+			entity.JobStatusQueued, now, entity.JobStatusRunning, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lease job: %v", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve rows affected: %v", err)
+		}
+		if rowsAffected == 1 {
+			return r.GetJobByID(jobID)
+		}
+	}
+	return nil, nil
+}
+
+// ExtendJobLease pushes the lease of a running job forward, acting as the worker heartbeat
+func (r *jobRepo) ExtendJobLease(jobID uint64, owner string, lease time.Duration) error {
+	now := time.Now().UTC()
+	return r.updateLeasedJob(`leased_until = ?, updated_at = ?`, jobID, owner, now.Add(lease), now)
+}
+
+// ReportJobProgress records the progress of a running job and extends its lease like ExtendJobLease
+func (r *jobRepo) ReportJobProgress(jobID uint64, owner string, progress int, lease time.Duration) error {
+	now := time.Now().UTC()
+	return r.updateLeasedJob(`progress = ?, leased_until = ?, updated_at = ?`, jobID, owner, progress, now.Add(lease), now)
+}
+
+// CompleteJob marks a running job as succeeded
+func (r *jobRepo) CompleteJob(jobID uint64, owner string) error {
+	return r.updateLeasedJob(`status = ?, progress = 100, lease_owner = '', leased_until = NULL, last_error = '', updated_at = ?`,
+		jobID, owner, entity.JobStatusSucceeded, time.Now().UTC())
+}
+
+// RetryJob puts a failed job back in the queue to be leased again at runAt
+func (r *jobRepo) RetryJob(jobID uint64, owner, lastError string, runAt time.Time) error {
+	return r.updateLeasedJob(`status = ?, run_at = ?, lease_owner = '', leased_until = NULL, last_error = ?, updated_at = ?`,
+		jobID, owner, entity.JobStatusQueued, runAt.UTC(), lastError, time.Now().UTC())
+}
+
+// DeadLetterJob moves a failed job to the dead-letter state where it is no longer leased
+func (r *jobRepo) DeadLetterJob(jobID uint64, owner, lastError string) error {
+	return r.updateLeasedJob(`status = ?, lease_owner = '', leased_until = NULL, last_error = ?, updated_at = ?`,
+		jobID, owner, entity.JobStatusDead, lastError, time.Now().UTC())
+}
+
+// ReleaseJob returns a job to the queue without counting the attempt, used when a worker stops mid-job
+func (r *jobRepo) ReleaseJob(jobID uint64, owner string) error {
+	now := time.Now().UTC()
+	return r.updateLeasedJob(`status = ?, run_at = ?, attempts = MAX(attempts - 1, 0), lease_owner = '', leased_until = NULL, updated_at = ?`,
+		jobID, owner, entity.JobStatusQueued, now, now)
+}
+
+// updateLeasedJob applies the SET clause to a job only while owner still holds its lease
+func (r *jobRepo) updateLeasedJob(set string, jobID uint64, owner string, args ...interface{}) error {
+	args = append(args, jobID, entity.JobStatusRunning, owner)
+	result, err := r.db.Exec(`UPDATE jobs SET `+set+` WHERE id = ? AND status = ? AND lease_owner = ?`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update job: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: job %d is not leased by %s", ErrJobLeaseLost, jobID, owner)
+	}
+	return nil
+}
+
+// placeholders returns n comma separated query placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row rowScanner) (*entity.Job, error) {
+	job := &entity.Job{}
+	var leasedUntil sql.NullTime
+	err := row.Scan(&job.ID, &job.Type, &job.Payload, &job.Status, &job.SourceLang, &job.TargetLang, &job.Progress, &job.Attempts, &job.MaxAttempts, &job.RunAt,
+		&job.LeaseOwner, &leasedUntil, &job.LastError, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if leasedUntil.Valid {
+		job.LeasedUntil = &leasedUntil.Time
+	}
+	return job, nil
+}
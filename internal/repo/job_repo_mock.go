@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"mlvt/internal/entity"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockJobRepository struct {
+	mock.Mock
+}
+
+func (m *MockJobRepository) CreateJob(job *entity.Job) error {
+	args := m.Called(job)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) GetJobByID(jobID uint64) (*entity.Job, error) {
+	args := m.Called(jobID)
+	job, _ := args.Get(0).(*entity.Job)
+	return job, args.Error(1)
+}
+
+func (m *MockJobRepository) ListJobsByStatus(status entity.JobStatus, limit int) ([]entity.Job, error) {
+	args := m.Called(status, limit)
+	jobs, _ := args.Get(0).([]entity.Job)
+	return jobs, args.Error(1)
+}
+
+func (m *MockJobRepository) LeaseJob(filter JobFilter, owner string, lease time.Duration) (*entity.Job, error) {
+	args := m.Called(filter, owner, lease)
+	job, _ := args.Get(0).(*entity.Job)
+	return job, args.Error(1)
+}
+
+func (m *MockJobRepository) ExtendJobLease(jobID uint64, owner string, lease time.Duration) error {
+	args := m.Called(jobID, owner, lease)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) ReportJobProgress(jobID uint64, owner string, progress int, lease time.Duration) error {
+	args := m.Called(jobID, owner, progress, lease)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) CompleteJob(jobID uint64, owner string) error {
+	args := m.Called(jobID, owner)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) RetryJob(jobID uint64, owner, lastError string, runAt time.Time) error {
+	args := m.Called(jobID, owner, lastError, runAt)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) DeadLetterJob(jobID uint64, owner, lastError string) error {
+	args := m.Called(jobID, owner, lastError)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) ReleaseJob(jobID uint64, owner string) error {
+	args := m.Called(jobID, owner)
+	return args.Error(0)
+}
@@ -0,0 +1,191 @@
+package repo
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupJobTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	// Every connection to :memory: opens its own database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	for _, migration := range []string{"0010_create_jobs_table", "0011_create_ml_workers_table"} {
+		schema, err := os.ReadFile("../../migration/" + migration + ".up.sql")
+		require.NoError(t, err)
+		_, err = db.Exec(string(schema))
+		require.NoError(t, err)
+	}
+	return db
+}
+
+func TestCreateJob(t *testing.T) {
+	jobRepo := NewJobRepo(setupJobTestDB(t))
+
+	job := &entity.Job{Type: entity.JobTypeProcessVideo, Payload: `{"video_id":1}`}
+	err := jobRepo.CreateJob(job)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), job.ID)
+
+	saved, err := jobRepo.GetJobByID(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.JobStatusQueued, saved.Status)
+	assert.Equal(t, DefaultJobMaxAttempts, saved.MaxAttempts)
+	assert.Equal(t, `{"video_id":1}`, saved.Payload)
+	assert.Nil(t, saved.LeasedUntil)
+
+	missing, err := jobRepo.GetJobByID(99)
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestLeaseJob(t *testing.T) {
+	jobRepo := NewJobRepo(setupJobTestDB(t))
+	types := JobFilter{Types: []entity.JobType{entity.JobTypeProcessVideo}}
+
+	later := &entity.Job{Type: entity.JobTypeProcessVideo, RunAt: time.Now().Add(time.Hour)}
+	other := &entity.Job{Type: "other"}
+	ready := &entity.Job{Type: entity.JobTypeProcessVideo}
+	for _, job := range []*entity.Job{later, other, ready} {
+		require.NoError(t, jobRepo.CreateJob(job))
+	}
+
+	leased, err := jobRepo.LeaseJob(types, "worker-1", time.Minute)
+	assert.NoError(t, err)
+	require.NotNil(t, leased)
+	assert.Equal(t, ready.ID, leased.ID)
+	assert.Equal(t, entity.JobStatusRunning, leased.Status)
+	assert.Equal(t, "worker-1", leased.LeaseOwner)
+	assert.Equal(t, 1, leased.Attempts)
+	require.NotNil(t, leased.LeasedUntil)
+
+	// Nothing else is runnable: one job is scheduled later, the other has another type
+	none, err := jobRepo.LeaseJob(types, "worker-2", time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, none)
+}
+
+func TestLeaseJobAfterVisibilityTimeout(t *testing.T) {
+	jobRepo := NewJobRepo(setupJobTestDB(t))
+	types := JobFilter{Types: []entity.JobType{entity.JobTypeProcessVideo}}
+
+	job := &entity.Job{Type: entity.JobTypeProcessVideo, MaxAttempts: 2}
+	require.NoError(t, jobRepo.CreateJob(job))
+
+	_, err := jobRepo.LeaseJob(types, "worker-1", -time.Second)
+	require.NoError(t, err)
+
+	// The lease of worker-1 expired, so worker-2 takes the job over
+	leased, err := jobRepo.LeaseJob(types, "worker-2", -time.Second)
+	assert.NoError(t, err)
+	require.NotNil(t, leased)
+	assert.Equal(t, "worker-2", leased.LeaseOwner)
+	assert.Equal(t, 2, leased.Attempts)
+
+	err = jobRepo.CompleteJob(job.ID, "worker-1")
+	assert.ErrorIs(t, err, ErrJobLeaseLost)
+
+	// The lease expired on the last attempt, so the job is dead-lettered
+	none, err := jobRepo.LeaseJob(types, "worker-3", time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, none)
+
+	dead, err := jobRepo.GetJobByID(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.JobStatusDead, dead.Status)
+}
+
+func TestJobLifecycle(t *testing.T) {
+	db := setupJobTestDB(t)
+	jobRepo := NewJobRepo(db)
+	types := JobFilter{Types: []entity.JobType{entity.JobTypeProcessVideo}}
+
+	job := &entity.Job{Type: entity.JobTypeProcessVideo}
+	require.NoError(t, jobRepo.CreateJob(job))
+
+	_, err := jobRepo.LeaseJob(types, "worker-1", time.Minute)
+	require.NoError(t, err)
+	assert.NoError(t, jobRepo.ExtendJobLease(job.ID, "worker-1", time.Hour))
+	assert.ErrorIs(t, jobRepo.ExtendJobLease(job.ID, "worker-2", time.Hour), ErrJobLeaseLost)
+
+	// A retry is not runnable before its run_at
+	assert.NoError(t, jobRepo.RetryJob(job.ID, "worker-1", "boom", time.Now().Add(time.Hour)))
+	retried, err := jobRepo.GetJobByID(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.JobStatusQueued, retried.Status)
+	assert.Equal(t, "boom", retried.LastError)
+	none, err := jobRepo.LeaseJob(types, "worker-1", time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, none)
+
+	_, err = db.Exec(`UPDATE jobs SET run_at = ? WHERE id = ?`, time.Now().UTC().Add(-time.Second), job.ID)
+	require.NoError(t, err)
+	_, err = jobRepo.LeaseJob(types, "worker-1", time.Minute)
+	require.NoError(t, err)
+
+	// Releasing gives the attempt back
+	assert.NoError(t, jobRepo.ReleaseJob(job.ID, "worker-1"))
+	released, err := jobRepo.GetJobByID(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.JobStatusQueued, released.Status)
+
+	leased, err := jobRepo.LeaseJob(types, "worker-1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 2, leased.Attempts)
+	assert.NoError(t, jobRepo.CompleteJob(job.ID, "worker-1"))
+
+	done, err := jobRepo.GetJobByID(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.JobStatusSucceeded, done.Status)
+	assert.Empty(t, done.LeaseOwner)
+}
+
+func TestDeadLetterJob(t *testing.T) {
+	jobRepo := NewJobRepo(setupJobTestDB(t))
+
+	job := &entity.Job{Type: entity.JobTypeProcessVideo}
+	require.NoError(t, jobRepo.CreateJob(job))
+	_, err := jobRepo.LeaseJob(JobFilter{Types: []entity.JobType{entity.JobTypeProcessVideo}}, "worker-1", time.Minute)
+	require.NoError(t, err)
+
+	assert.NoError(t, jobRepo.DeadLetterJob(job.ID, "worker-1", "video not found"))
+
+	dead, err := jobRepo.ListJobsByStatus(entity.JobStatusDead, 10)
+	assert.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "video not found", dead[0].LastError)
+}
+
+func TestLeaseJobByLanguage(t *testing.T) {
+	jobRepo := NewJobRepo(setupJobTestDB(t))
+	jobType := entity.MLTaskTranslation.JobType()
+
+	toFrench := &entity.Job{Type: jobType, SourceLang: "en", TargetLang: "fr"}
+	toVietnamese := &entity.Job{Type: jobType, SourceLang: "en", TargetLang: "vi"}
+	require.NoError(t, jobRepo.CreateJob(toFrench))
+	require.NoError(t, jobRepo.CreateJob(toVietnamese))
+
+	leased, err := jobRepo.LeaseJob(JobFilter{Types: []entity.JobType{jobType}, Languages: []string{"en", "vi"}}, "worker-1", time.Minute)
+	assert.NoError(t, err)
+	require.NotNil(t, leased)
+	assert.Equal(t, toVietnamese.ID, leased.ID)
+
+	assert.NoError(t, jobRepo.ReportJobProgress(leased.ID, "worker-1", 40, time.Minute))
+	progressed, err := jobRepo.GetJobByID(leased.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 40, progressed.Progress)
+
+	none, err := jobRepo.LeaseJob(JobFilter{Types: []entity.JobType{jobType}, Languages: []string{"en", "vi"}}, "worker-1", time.Minute)
+	assert.NoError(t, err)
+	assert.Nil(t, none)
+}
@@ -0,0 +1,105 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"time"
+)
+
+// Where failed logins are counted, set with LOGIN_ATTEMPT_STORE
+const (
+	LoginAttemptStoreSQL    = "sql"    // Shared by every instance of the server
+	LoginAttemptStoreMemory = "memory" // Lost on restart and not shared, for a single instance
+)
+
+// LoginAttemptRepository counts failed logins by key and keeps the lockouts they lead to
+type LoginAttemptRepository interface {
+	GetLoginAttempt(key string) (*entity.LoginAttempt, error)
+	RecordLoginFailure(key string, since time.Time) (int, error) // Counts a failure and returns the failures since the time; earlier ones are forgotten
+	LockLoginAttempt(key string, until time.Time) error
+	DeleteLoginAttempt(key string) error
+}
+
+// NewLoginAttemptStore returns the login attempt repository selected by LOGIN_ATTEMPT_STORE, the database by default
+func NewLoginAttemptStore(db *sql.DB) (LoginAttemptRepository, error) {
+	switch env.EnvConfig.LoginAttemptStore {
+	case "", LoginAttemptStoreSQL:
+		return NewLoginAttemptRepo(db), nil
+	case LoginAttemptStoreMemory:
+		return NewMemoryLoginAttemptRepo(), nil
+	default:
+		return nil, fmt.Errorf("unknown login attempt store %q", env.EnvConfig.LoginAttemptStore)
+	}
+}
+
+type loginAttemptRepo struct {
+	db *sql.DB
+}
+
+func NewLoginAttemptRepo(db *sql.DB) LoginAttemptRepository {
+	return &loginAttemptRepo{db: db}
+}
+
+// GetLoginAttempt retrieves the failures counted for the key, or nil when there are none
+func (r *loginAttemptRepo) GetLoginAttempt(key string) (*entity.LoginAttempt, error) {
+	query := `SELECT attempt_key, failures, last_failure_at, locked_until FROM login_attempts WHERE attempt_key = ?`
+
+	attempt := &entity.LoginAttempt{}
+	var lockedUntil sql.NullTime
+	err := r.db.QueryRow(query, key).Scan(&attempt.Key, &attempt.Failures, &attempt.LastFailureAt, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		attempt.LockedUntil = &lockedUntil.Time
+	}
+	return attempt, nil
+}
+
+// RecordLoginFailure counts a failure for the key. Keys without a failure since the time are deleted first,
+// so their count starts over.
+func (r *loginAttemptRepo) RecordLoginFailure(key string, since time.Time) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM login_attempts WHERE last_failure_at < ?`, since); err != nil {
+		return 0, fmt.Errorf("failed to delete stale login attempts: %v", err)
+	}
+
+	query := `
+		INSERT INTO login_attempts (attempt_key, failures, last_failure_at) VALUES (?, 1, ?)
+		ON CONFLICT (attempt_key) DO UPDATE SET failures = failures + 1, last_failure_at = excluded.last_failure_at`
+	if _, err := tx.Exec(query, key, time.Now()); err != nil {
+		return 0, fmt.Errorf("failed to record login failure: %v", err)
+	}
+
+	var failures int
+	if err := tx.QueryRow(`SELECT failures FROM login_attempts WHERE attempt_key = ?`, key).Scan(&failures); err != nil {
+		return 0, err
+	}
+	return failures, tx.Commit()
+}
+
+// LockLoginAttempt refuses logins for the key until the time
+func (r *loginAttemptRepo) LockLoginAttempt(key string, until time.Time) error {
+	if _, err := r.db.Exec(`UPDATE login_attempts SET locked_until = ? WHERE attempt_key = ?`, until, key); err != nil {
+		return fmt.Errorf("failed to lock login attempt: %v", err)
+	}
+	return nil
+}
+
+// DeleteLoginAttempt forgets the failures of the key and lifts its lockout
+func (r *loginAttemptRepo) DeleteLoginAttempt(key string) error {
+	if _, err := r.db.Exec(`DELETE FROM login_attempts WHERE attempt_key = ?`, key); err != nil {
+		return fmt.Errorf("failed to delete login attempt: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package repo
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupLoginAttemptTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	schema, err := os.ReadFile("../../migration/0021_create_login_attempts_table.up.sql")
+	require.NoError(t, err)
+	_, err = db.Exec(string(schema))
+	require.NoError(t, err)
+	return db
+}
+
+// TestLoginAttemptRepositories runs the same checks against the SQL and the in-memory implementation
+func TestLoginAttemptRepositories(t *testing.T) {
+	stores := map[string]func(t *testing.T) LoginAttemptRepository{
+		"SQL":    func(t *testing.T) LoginAttemptRepository { return NewLoginAttemptRepo(setupLoginAttemptTestDB(t)) },
+		"Memory": func(t *testing.T) LoginAttemptRepository { return NewMemoryLoginAttemptRepo() },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			since := time.Now().Add(-time.Hour)
+
+			attempt, err := store.GetLoginAttempt("email:john@example.com")
+			require.NoError(t, err)
+			assert.Nil(t, attempt)
+
+			for want := 1; want <= 3; want++ {
+				failures, err := store.RecordLoginFailure("email:john@example.com", since)
+				require.NoError(t, err)
+				assert.Equal(t, want, failures)
+			}
+			failures, err := store.RecordLoginFailure("ip:10.0.0.1", since)
+			require.NoError(t, err)
+			assert.Equal(t, 1, failures, "keys are counted apart")
+
+			until := time.Now().Add(time.Minute)
+			require.NoError(t, store.LockLoginAttempt("email:john@example.com", until))
+			attempt, err = store.GetLoginAttempt("email:john@example.com")
+			require.NoError(t, err)
+			assert.Equal(t, 3, attempt.Failures)
+			require.NotNil(t, attempt.LockedUntil)
+			assert.WithinDuration(t, until, *attempt.LockedUntil, time.Millisecond)
+
+			// Failures older than the window are forgotten
+			failures, err = store.RecordLoginFailure("email:john@example.com", time.Now().Add(time.Second))
+			require.NoError(t, err)
+			assert.Equal(t, 1, failures)
+
+			require.NoError(t, store.DeleteLoginAttempt("email:john@example.com"))
+			attempt, err = store.GetLoginAttempt("email:john@example.com")
+			require.NoError(t, err)
+			assert.Nil(t, attempt)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"mlvt/internal/entity"
+	"sync"
+	"time"
+)
+
+// memoryPruneInterval is how often stale keys are swept from the map
+const memoryPruneInterval = time.Minute
+
+type memoryLoginAttemptRepo struct {
+	mu         sync.Mutex
+	attempts   map[string]*entity.LoginAttempt
+	lastPruned time.Time
+}
+
+// NewMemoryLoginAttemptRepo keeps login attempts in memory. They are lost on restart and not shared
+// between instances of the server.
+func NewMemoryLoginAttemptRepo() LoginAttemptRepository {
+	return &memoryLoginAttemptRepo{attempts: make(map[string]*entity.LoginAttempt)}
+}
+
+func (r *memoryLoginAttemptRepo) GetLoginAttempt(key string) (*entity.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	copied := *attempt
+	return &copied, nil
+}
+
+// RecordLoginFailure counts a failure for the key, starting over when it had no failure since the time.
+// Stale keys are swept from time to time, so the map does not grow without bound.
+func (r *memoryLoginAttemptRepo) RecordLoginFailure(key string, since time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.lastPruned) >= memoryPruneInterval {
+		for k, attempt := range r.attempts {
+			if attempt.LastFailureAt.Before(since) {
+				delete(r.attempts, k)
+			}
+		}
+		r.lastPruned = now
+	}
+
+	attempt, ok := r.attempts[key]
+	if !ok || attempt.LastFailureAt.Before(since) {
+		attempt = &entity.LoginAttempt{Key: key}
+		r.attempts[key] = attempt
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = now
+	return attempt.Failures, nil
+}
+
+func (r *memoryLoginAttemptRepo) LockLoginAttempt(key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[key]; ok {
+		attempt.LockedUntil = &until
+	}
+	return nil
+}
+
+func (r *memoryLoginAttemptRepo) DeleteLoginAttempt(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
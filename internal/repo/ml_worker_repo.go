@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mlvt/internal/entity"
+	"time"
+)
+
+type MLWorkerRepository interface {
+	CreateMLWorker(worker *entity.MLWorker) error
+	GetMLWorkerByTokenHash(tokenHash string) (*entity.MLWorker, error)
+	UpdateMLWorkerLastSeen(workerID uint64) error
+}
+
+type mlWorkerRepo struct {
+	db *sql.DB
+}
+
+func NewMLWorkerRepo(db *sql.DB) MLWorkerRepository {
+	return &mlWorkerRepo{db: db}
+}
+
+// CreateMLWorker inserts a new worker; task types and languages are stored as JSON arrays
+func (r *mlWorkerRepo) CreateMLWorker(worker *entity.MLWorker) error {
+	taskTypes, err := json.Marshal(worker.TaskTypes)
+	if err != nil {
+		return err
+	}
+	languages, err := json.Marshal(worker.Languages)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO ml_workers (name, token_hash, task_types, languages, last_seen_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	now := time.Now()
+	result, err := r.db.Exec(query, worker.Name, worker.TokenHash, string(taskTypes), string(languages), now, now, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	worker.ID = uint64(id)
+	worker.LastSeenAt = &now
+	worker.CreatedAt = now
+	worker.UpdatedAt = now
+	return nil
+}
+
+// GetMLWorkerByTokenHash retrieves the worker owning the token with the given hash
+func (r *mlWorkerRepo) GetMLWorkerByTokenHash(tokenHash string) (*entity.MLWorker, error) {
+	query := `SELECT id, name, token_hash, task_types, languages, last_seen_at, created_at, updated_at
+	          FROM ml_workers WHERE token_hash = ?`
+	row := r.db.QueryRow(query, tokenHash)
+
+	worker := &entity.MLWorker{}
+	var taskTypes, languages string
+	var lastSeenAt sql.NullTime
+	err := row.Scan(&worker.ID, &worker.Name, &worker.TokenHash, &taskTypes, &languages, &lastSeenAt, &worker.CreatedAt, &worker.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(taskTypes), &worker.TaskTypes); err != nil {
+		return nil, fmt.Errorf("invalid task types of worker %d: %v", worker.ID, err)
+	}
+	if err := json.Unmarshal([]byte(languages), &worker.Languages); err != nil {
+		return nil, fmt.Errorf("invalid languages of worker %d: %v", worker.ID, err)
+	}
+	if lastSeenAt.Valid {
+		worker.LastSeenAt = &lastSeenAt.Time
+	}
+	return worker, nil
+}
+
+// UpdateMLWorkerLastSeen records that the worker just contacted the backend
+func (r *mlWorkerRepo) UpdateMLWorkerLastSeen(workerID uint64) error {
+	query := `UPDATE ml_workers SET last_seen_at = ?, updated_at = ? WHERE id = ?`
+	now := time.Now()
+	result, err := r.db.Exec(query, now, now, workerID)
+	if err != nil {
+		return fmt.Errorf("failed to update worker: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no worker found with id %d", workerID)
+	}
+	return nil
+}
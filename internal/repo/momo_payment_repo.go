@@ -4,134 +4,283 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
+	"fmt"
+	"io"
 	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
+var (
+	ErrMoMoNotConfigured = fmt.Errorf("momo: partner code, access key and secret key are not configured: %w", ErrProviderNotConfigured)
+	ErrMoMoBadResponse   = errors.New("momo: unexpected response")
+)
+
+// maxMoMoResponseSize bounds the responses read from MoMo
+const maxMoMoResponseSize = 1 << 20
+
+// Limits MoMo puts on payments, in VND
+const (
+	MinMoMoAmount = 1000
+	MaxMoMoAmount = 50000000
+)
+
+// momoOrderID is the format MoMo accepts for order IDs
+var momoOrderID = regexp.MustCompile(`^[0-9a-zA-Z]([-_.]?[0-9a-zA-Z]+)*$`)
+
+// MoMoRepo calls the MoMo payment gateway (API v2). Requests MoMo rejects return an *entity.MoMoError.
 type MoMoRepo interface {
-	CreatePayment(orderID, amount string) (string, error)
-	CheckPaymentStatus(orderID string) (bool, error)
-	RefundPayment(orderID, amount string) (string, error)
+	PaymentProvider
+	// CreatePayment asks MoMo for the URLs the user pays orderID with
+	CreatePayment(orderID string, amount int64, orderInfo, extraData string) (*entity.MoMoCreateResponse, error)
+	// QueryPayment returns the status of the payment of orderID in its ResultCode
+	QueryPayment(orderID string) (*entity.MoMoQueryResponse, error)
+	// RefundPayment refunds amount of the payment transID under the new refundOrderID
+	RefundPayment(refundOrderID string, transID, amount int64, description string) (*entity.MoMoRefundResponse, error)
+	// VerifyIPN reports whether the notification comes from MoMo for this partner, by its signature
+	VerifyIPN(notification *entity.MoMoIPN) bool
 }
 
 type momoRepo struct {
-	endpoint    string
-	partnerCode string
-	accessKey   string
-	secrectKey  string
+	config env.MoMoConfig
+	client *http.Client
 }
 
+// NewMoMoRepo creates a MoMo client from env.EnvConfig.MoMo
 func NewMoMoRepo() MoMoRepo {
+	return NewMoMoRepoWithConfig(env.EnvConfig.MoMo)
+}
+
+// NewMoMoRepoWithConfig creates a MoMo client calling config.Endpoint
+func NewMoMoRepoWithConfig(config env.MoMoConfig) MoMoRepo {
 	return &momoRepo{
-		endpoint:    "", //env.EnvConfig.MoMoEndpoint,
-		partnerCode: "", //env.EnvConfig.MoMoPartnerCode,
-		accessKey:   "", //env.EnvConfig.MoMoAccessKey,
-		secrectKey:  "", //env.EnvConfig.MoMoSecretKey,
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
 	}
 }
 
-func (m *momoRepo) CreatePayment(orderID, amount string) (string, error) {
-	momoRequest := entity.NewMoMoRequest(m.partnerCode, m.accessKey, orderID, amount, orderID)
-	momoRequest.GenerateSignature(m.secrectKey)
+func (m *momoRepo) CreatePayment(orderID string, amount int64, orderInfo, extraData string) (*entity.MoMoCreateResponse, error) {
+	requestID, err := newPaymentRequestID()
+	if err != nil {
+		return nil, err
+	}
+	request := &entity.MoMoCreateRequest{
+		PartnerCode: m.config.PartnerCode,
+		RequestID:   requestID,
+		Amount:      amount,
+		OrderID:     orderID,
+		OrderInfo:   orderInfo,
+		RedirectURL: m.config.RedirectURL,
+		IPNURL:      m.config.IPNURL,
+		RequestType: m.config.RequestType,
+		ExtraData:   extraData,
+		Lang:        m.config.Lang,
+	}
+	request.Sign(m.config.AccessKey, m.config.SecretKey)
 
-	requestBody := momoRequest.ToMap()
+	var response entity.MoMoCreateResponse
+	if err := m.post("/v2/gateway/api/create", request, &response); err != nil {
+		return nil, err
+	}
+	if response.ResultCode != entity.MoMoResultSuccess {
+		return nil, &entity.MoMoError{ResultCode: response.ResultCode, Message: response.Message}
+	}
+	return &response, nil
+}
 
-	jsonBody, err := json.Marshal(requestBody)
+func (m *momoRepo) QueryPayment(orderID string) (*entity.MoMoQueryResponse, error) {
+	requestID, err := newPaymentRequestID()
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	request := &entity.MoMoQueryRequest{
+		PartnerCode: m.config.PartnerCode,
+		RequestID:   requestID,
+		OrderID:     orderID,
+		Lang:        m.config.Lang,
 	}
+	request.Sign(m.config.AccessKey, m.config.SecretKey)
 
-	client := &http.Client{Timeout: time.Second * 30}
-	req, err := http.NewRequest("POST", m.endpoint, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", err
+	var response entity.MoMoQueryResponse
+	if err := m.post("/v2/gateway/api/query", request, &response); err != nil {
+		return nil, err
+	}
+	// Codes of failed payments are a status; those of failed requests are not
+	if response.ResultCode.IsRequestError() {
+		return nil, &entity.MoMoError{ResultCode: response.ResultCode, Message: response.Message}
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return &response, nil
+}
 
-	resp, err := client.Do(req)
+func (m *momoRepo) RefundPayment(refundOrderID string, transID, amount int64, description string) (*entity.MoMoRefundResponse, error) {
+	requestID, err := newPaymentRequestID()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	body, _ := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return "", errors.New("payment request failed")
+	request := &entity.MoMoRefundRequest{
+		PartnerCode: m.config.PartnerCode,
+		OrderID:     refundOrderID,
+		RequestID:   requestID,
+		Amount:      amount,
+		TransID:     transID,
+		Lang:        m.config.Lang,
+		Description: description,
 	}
+	request.Sign(m.config.AccessKey, m.config.SecretKey)
 
-	var response struct {
-		PayURL string `json:"payUrl"`
+	var response entity.MoMoRefundResponse
+	if err := m.post("/v2/gateway/api/refund", request, &response); err != nil {
+		return nil, err
+	}
+	if response.ResultCode != entity.MoMoResultSuccess {
+		return nil, &entity.MoMoError{ResultCode: response.ResultCode, Message: response.Message}
 	}
-	json.Unmarshal(body, &response)
+	return &response, nil
+}
 
-	return response.PayURL, nil
+func (m *momoRepo) VerifyIPN(notification *entity.MoMoIPN) bool {
+	if m.config.PartnerCode == "" || m.config.SecretKey == "" || notification.PartnerCode != m.config.PartnerCode {
+		return false
+	}
+	return entity.ValidMoMoSignature(m.config.SecretKey, notification.RawSignature(m.config.AccessKey), notification.Signature)
 }
 
-func (m *momoRepo) CheckPaymentStatus(orderID string) (bool, error) {
-	momoRequest := entity.NewMoMoRequest(m.partnerCode, m.accessKey, orderID, "0", orderID)
-	momoRequest.GenerateSignature(m.secrectKey)
+func (m *momoRepo) Name() string {
+	return entity.PaymentProviderMoMo
+}
 
-	requestBody := momoRequest.ToMap()
-	jsonBody, err := json.Marshal(requestBody)
+func (m *momoRepo) CreateCheckout(order *entity.Order, description, clientIP string) (*entity.Checkout, error) {
+	if order.Currency != entity.CurrencyVND || order.Amount < MinMoMoAmount || order.Amount > MaxMoMoAmount ||
+		!validMoMoOrderID(order.Reference) {
+		return nil, ErrCheckoutNotSupported
+	}
+	payment, err := m.CreatePayment(order.Reference, order.Amount, description, "")
 	if err != nil {
-		return false, err
+		return nil, err
 	}
+	return &entity.Checkout{PayURL: payment.PayURL, Deeplink: payment.Deeplink, QRData: payment.QRCodeURL}, nil
+}
 
-	client := &http.Client{Timeout: time.Second * 30}
-	req, err := http.NewRequest("POST", m.endpoint+"/check-status", bytes.NewBuffer(jsonBody))
+func (m *momoRepo) GetPaymentStatus(order *entity.Order) (*entity.PaymentStatus, error) {
+	payment, err := m.QueryPayment(order.Reference)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	status := &entity.PaymentStatus{
+		State:   payment.ResultCode.PaymentState(),
+		Amount:  payment.Amount,
+		Code:    strconv.Itoa(int(payment.ResultCode)),
+		Message: payment.Message,
+	}
+	if payment.TransID != 0 {
+		status.TransactionID = strconv.FormatInt(payment.TransID, 10)
+	}
+	return status, nil
+}
 
-	resp, err := client.Do(req)
+func (m *momoRepo) Refund(order *entity.Order, amount int64) (*entity.PaymentRefund, error) {
+	transID, err := strconv.ParseInt(order.TransactionID, 10, 64)
 	if err != nil {
-		return false, err
+		return nil, fmt.Errorf("%w: %q", ErrNoProviderTransaction, order.TransactionID)
 	}
-	defer resp.Body.Close()
+	// Every refund needs an order ID of its own
+	refund, err := m.RefundPayment(refundOrderIDFor(order.Reference), transID, amount, "Refund of order "+order.Reference)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.PaymentRefund{
+		RefundID:      refund.OrderID,
+		TransactionID: strconv.FormatInt(refund.TransID, 10),
+		Amount:        refund.Amount,
+	}, nil
+}
 
-	body, _ := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return false, errors.New("failed to check payment status")
+func (m *momoRepo) VerifyWebhook(request *entity.WebhookRequest) (*entity.PaymentEvent, error) {
+	var notification entity.MoMoIPN
+	if err := json.Unmarshal(request.Body, &notification); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedWebhook, err)
+	}
+	if !m.VerifyIPN(&notification) {
+		return nil, ErrInvalidWebhookSignature
 	}
 
-	var response struct {
-		Status string `json:"status"`
+	event := &entity.PaymentEvent{
+		// The same notification is retried with the same request ID, transaction ID and result code
+		ID:         fmt.Sprintf("ipn:%s:%d:%d", notification.RequestID, notification.TransID, notification.ResultCode),
+		OrderID:    notification.OrderID,
+		State:      notification.ResultCode.PaymentState(),
+		Amount:     notification.Amount,
+		OccurredAt: time.UnixMilli(notification.ResponseTime),
+		Details:    string(request.Body),
+	}
+	if notification.TransID != 0 {
+		event.TransactionID = strconv.FormatInt(notification.TransID, 10)
 	}
-	json.Unmarshal(body, &response)
+	return event, nil
+}
 
-	return response.Status == "success", nil
+// WebhookResponse answers 204 No Content to notifications MoMo should not send again, and 500 to those it
+// should retry
+func (m *momoRepo) WebhookResponse(outcome entity.WebhookOutcome) *entity.WebhookResponse {
+	switch outcome {
+	case entity.WebhookAccepted, entity.WebhookOrderNotFound:
+		return &entity.WebhookResponse{Status: http.StatusNoContent}
+	case entity.WebhookFailed:
+		return &entity.WebhookResponse{Status: http.StatusInternalServerError, Body: map[string]string{"error": "internal server error"}}
+	default:
+		return &entity.WebhookResponse{Status: http.StatusBadRequest, Body: map[string]string{"error": string(outcome)}}
+	}
 }
 
-func (m *momoRepo) RefundPayment(orderID, amount string) (string, error) {
-	momoRequest := entity.NewMoMoRequest(m.partnerCode, m.accessKey, orderID+"_refund", amount, orderID)
-	momoRequest.GenerateSignature(m.secrectKey)
+// post sends the request as JSON and decodes the response. MoMo answers rejected requests with a 4xx status
+// and the same body, so the body is decoded whatever the status.
+func (m *momoRepo) post(path string, request, response interface{}) error {
+	if m.config.PartnerCode == "" || m.config.AccessKey == "" || m.config.SecretKey == "" {
+		return ErrMoMoNotConfigured
+	}
 
-	requestBody := momoRequest.ToMap()
-	jsonBody, err := json.Marshal(requestBody)
+	body, err := json.Marshal(request)
 	if err != nil {
-		return "", err
+		return err
 	}
-
-	client := &http.Client{Timeout: time.Second * 30}
-	req, err := http.NewRequest("POST", m.endpoint+"/refund", bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequest(http.MethodPost, m.config.Endpoint+path, bytes.NewReader(body))
 	if err != nil {
-		return "", err
+		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
 
-	resp, err := client.Do(req)
+	resp, err := m.client.Do(req)
 	if err != nil {
-		return "", err
+		return fmt.Errorf("momo: request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, _ := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return "", errors.New("refund request failed")
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMoMoResponseSize))
+	if err != nil {
+		return fmt.Errorf("momo: failed to read response: %w", err)
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%w: status %d", ErrMoMoBadResponse, resp.StatusCode)
 	}
+	if err := json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("%w: status %d: %v", ErrMoMoBadResponse, resp.StatusCode, err)
+	}
+	return nil
+}
+
+// refundOrderIDFor returns a new order ID for a refund of the order, within MoMo's 50 characters
+func refundOrderIDFor(orderID string) string {
+	suffix := "-r" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if len(orderID)+len(suffix) > 50 {
+		orderID = strings.TrimRight(orderID[:50-len(suffix)], "-_.")
+	}
+	return orderID + suffix
+}
 
-	return string(body), nil
+func validMoMoOrderID(orderID string) bool {
+	return len(orderID) <= 50 && momoOrderID.MatchString(orderID)
 }
@@ -0,0 +1,204 @@
+package repo
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/repo/momotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupMoMoRepo(t *testing.T) (MoMoRepo, *momotest.Server, env.MoMoConfig) {
+	fake := momotest.NewServer("MOMOTEST", "access-key", "secret-key")
+	t.Cleanup(fake.Close)
+
+	config := env.MoMoConfig{
+		Endpoint:    fake.URL,
+		PartnerCode: "MOMOTEST",
+		AccessKey:   "access-key",
+		SecretKey:   "secret-key",
+		RedirectURL: "http://localhost:3000/payment-result",
+		IPNURL:      "http://localhost:8080/api/payments/momo/ipn",
+		RequestType: "captureWallet",
+		Lang:        "en",
+		Timeout:     5 * time.Second,
+	}
+	return NewMoMoRepoWithConfig(config), fake, config
+}
+
+func TestMoMoSignatureFieldOrder(t *testing.T) {
+	create := &entity.MoMoCreateRequest{PartnerCode: "P", RequestID: "R", Amount: 1000, OrderID: "O", OrderInfo: "I",
+		RedirectURL: "U", IPNURL: "N", RequestType: "captureWallet", ExtraData: "E"}
+	assert.Equal(t, "accessKey=K&amount=1000&extraData=E&ipnUrl=N&orderId=O&orderInfo=I&partnerCode=P&redirectUrl=U&requestId=R&requestType=captureWallet",
+		create.RawSignature("K"))
+
+	query := &entity.MoMoQueryRequest{PartnerCode: "P", RequestID: "R", OrderID: "O"}
+	assert.Equal(t, "accessKey=K&orderId=O&partnerCode=P&requestId=R", query.RawSignature("K"))
+
+	refund := &entity.MoMoRefundRequest{PartnerCode: "P", OrderID: "O", RequestID: "R", Amount: 500, TransID: 42, Description: "D"}
+	assert.Equal(t, "accessKey=K&amount=500&description=D&orderId=O&partnerCode=P&requestId=R&transId=42", refund.RawSignature("K"))
+
+	// HMAC-SHA256 of "data" with the key "key"
+	assert.Equal(t, "5031fe3d989c6d1537a013fa6e739da23463fdaec3b70137d828e36ace221bd0", entity.SignMoMo("key", "data"))
+}
+
+func TestMoMoCreateQueryAndRefund(t *testing.T) {
+	momo, fake, config := setupMoMoRepo(t)
+
+	created, err := momo.CreatePayment("order-1", 50000, "Payment for order order-1", "eyJwbGFuIjoicHJvIn0=")
+	require.NoError(t, err)
+	assert.Equal(t, entity.MoMoResultSuccess, created.ResultCode)
+	assert.Equal(t, "order-1", created.OrderID)
+	assert.Equal(t, int64(50000), created.Amount)
+	assert.NotEmpty(t, created.PayURL)
+	assert.NotEmpty(t, created.QRCodeURL)
+	assert.NotEmpty(t, created.Deeplink)
+
+	sent, ok := fake.Payment("order-1")
+	require.True(t, ok)
+	assert.Equal(t, config.RedirectURL, sent.RedirectURL)
+	assert.Equal(t, config.IPNURL, sent.IPNURL)
+	assert.Equal(t, "captureWallet", sent.RequestType)
+	assert.Equal(t, "eyJwbGFuIjoicHJvIn0=", sent.ExtraData)
+	assert.Equal(t, "en", sent.Lang)
+
+	status, err := momo.QueryPayment("order-1")
+	require.NoError(t, err)
+	assert.True(t, status.ResultCode.IsPending())
+	assert.Zero(t, status.TransID)
+
+	transID, ok := fake.Pay("order-1")
+	require.True(t, ok)
+	status, err = momo.QueryPayment("order-1")
+	require.NoError(t, err)
+	assert.True(t, status.ResultCode.IsSuccess())
+	assert.Equal(t, transID, status.TransID)
+	assert.Equal(t, "eyJwbGFuIjoicHJvIn0=", status.ExtraData)
+
+	refund, err := momo.RefundPayment("order-1-r1", transID, 20000, "Refund of order order-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(20000), refund.Amount)
+	assert.NotZero(t, refund.TransID)
+
+	status, err = momo.QueryPayment("order-1")
+	require.NoError(t, err)
+	require.Len(t, status.RefundTrans, 1)
+	assert.Equal(t, int64(20000), status.RefundTrans[0].Amount)
+
+	_, err = momo.RefundPayment("order-1-r2", transID, 40000, "Refund of order order-1")
+	var momoErr *entity.MoMoError
+	require.ErrorAs(t, err, &momoErr)
+	assert.Equal(t, entity.MoMoResultRefundRejected, momoErr.ResultCode, "only 30,000 is left to refund")
+}
+
+func TestMoMoRejectedRequests(t *testing.T) {
+	momo, fake, config := setupMoMoRepo(t)
+	var momoErr *entity.MoMoError
+
+	_, err := momo.CreatePayment("order-1", 500, "Payment for order order-1", "")
+	require.ErrorAs(t, err, &momoErr)
+	assert.Equal(t, entity.MoMoResultInvalidAmount, momoErr.ResultCode)
+
+	_, err = momo.CreatePayment("order-2", 10000, "Payment for order order-2", "")
+	require.NoError(t, err)
+	_, err = momo.CreatePayment("order-2", 10000, "Payment for order order-2", "")
+	require.ErrorAs(t, err, &momoErr)
+	assert.Equal(t, entity.MoMoResultDuplicateOrderID, momoErr.ResultCode)
+
+	_, err = momo.QueryPayment("unknown")
+	require.ErrorAs(t, err, &momoErr)
+	assert.Equal(t, entity.MoMoResultOrderNotFound, momoErr.ResultCode)
+
+	// A failed payment is a status, not an error
+	require.True(t, fake.Fail("order-2", entity.MoMoResultDeniedByUser))
+	status, err := momo.QueryPayment("order-2")
+	require.NoError(t, err)
+	assert.Equal(t, entity.MoMoResultDeniedByUser, status.ResultCode)
+	assert.False(t, status.ResultCode.IsSuccess())
+	assert.False(t, status.ResultCode.IsPending())
+
+	config.SecretKey = "wrong"
+	_, err = NewMoMoRepoWithConfig(config).QueryPayment("order-2")
+	require.ErrorAs(t, err, &momoErr)
+	assert.Equal(t, entity.MoMoResultAuthFailed, momoErr.ResultCode)
+
+	config.SecretKey = ""
+	_, err = NewMoMoRepoWithConfig(config).QueryPayment("order-2")
+	assert.ErrorIs(t, err, ErrMoMoNotConfigured)
+
+	config.SecretKey = "secret-key"
+	config.Endpoint = fake.URL + "/missing"
+	_, err = NewMoMoRepoWithConfig(config).QueryPayment("order-2")
+	assert.ErrorIs(t, err, ErrMoMoBadResponse)
+}
+
+func TestMoMoPaymentProvider(t *testing.T) {
+	momo, fake, _ := setupMoMoRepo(t)
+	order := &entity.Order{Reference: "ord-1", Amount: 99000, Currency: entity.CurrencyVND, CreatedAt: time.Now()}
+
+	checkout, err := momo.CreateCheckout(order, "Payment for Premium", "203.0.113.7")
+	require.NoError(t, err)
+	assert.NotEmpty(t, checkout.PayURL)
+	assert.NotEmpty(t, checkout.QRData)
+	status, err := momo.GetPaymentStatus(order)
+	require.NoError(t, err)
+	assert.Equal(t, entity.PaymentStatePending, status.State)
+	assert.Empty(t, status.TransactionID)
+
+	transID, ok := fake.Pay(order.Reference)
+	require.True(t, ok)
+	status, err = momo.GetPaymentStatus(order)
+	require.NoError(t, err)
+	assert.Equal(t, entity.PaymentStatePaid, status.State)
+	assert.Equal(t, int64(99000), status.Amount)
+	assert.Equal(t, strconv.FormatInt(transID, 10), status.TransactionID)
+	order.TransactionID = status.TransactionID
+
+	refund, err := momo.Refund(order, 9000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9000), refund.Amount)
+	assert.True(t, strings.HasPrefix(refund.RefundID, order.Reference+"-r"), "refunds get order IDs of their own")
+
+	notification, ok := fake.Notification(order.Reference)
+	require.True(t, ok)
+	body, err := json.Marshal(notification)
+	require.NoError(t, err)
+	event, err := momo.VerifyWebhook(&entity.WebhookRequest{Body: body})
+	require.NoError(t, err)
+	assert.Equal(t, order.Reference, event.OrderID)
+	assert.Equal(t, entity.PaymentStatePaid, event.State)
+	assert.Equal(t, int64(99000), event.Amount)
+	assert.Equal(t, status.TransactionID, event.TransactionID)
+	assert.WithinDuration(t, time.Now(), event.OccurredAt, time.Minute)
+
+	notification.Amount = 1000
+	body, err = json.Marshal(notification)
+	require.NoError(t, err)
+	_, err = momo.VerifyWebhook(&entity.WebhookRequest{Body: body})
+	assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+	_, err = momo.VerifyWebhook(&entity.WebhookRequest{Body: []byte(`{"orderId":`)})
+	assert.ErrorIs(t, err, ErrMalformedWebhook)
+
+	assert.Equal(t, http.StatusNoContent, momo.WebhookResponse(entity.WebhookAccepted).Status)
+	assert.Equal(t, http.StatusBadRequest, momo.WebhookResponse(entity.WebhookInvalidSignature).Status)
+	assert.Equal(t, http.StatusInternalServerError, momo.WebhookResponse(entity.WebhookFailed).Status, "MoMo retries")
+
+	usd := &entity.Order{Reference: "ord-2", Amount: 999, Currency: "USD"}
+	_, err = momo.CreateCheckout(usd, "Payment for Premium", "")
+	assert.ErrorIs(t, err, ErrCheckoutNotSupported)
+}
+
+func TestRefundOrderIDFitsMoMo(t *testing.T) {
+	orderID := strings.Repeat("a", 30) + "-" + strings.Repeat("b", 19)
+	refundOrderID := refundOrderIDFor(orderID)
+	assert.LessOrEqual(t, len(refundOrderID), 50)
+	assert.True(t, validMoMoOrderID(refundOrderID), refundOrderID)
+}
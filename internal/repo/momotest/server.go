@@ -0,0 +1,341 @@
+// Package momotest runs a local MoMo payment gateway for tests. It checks the partner code and the signature of
+// every request like MoMo does, keeps the payments in memory and lets tests decide how each payment ends.
+package momotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"mlvt/internal/entity"
+)
+
+// Amount limits of the captureWallet payments, in VND
+const (
+	MinAmount = 1000
+	MaxAmount = 50000000
+)
+
+// Server is the stand-in gateway. URL is the endpoint to configure the client with.
+type Server struct {
+	URL         string
+	PartnerCode string
+	AccessKey   string
+	SecretKey   string
+
+	server *httptest.Server
+
+	mu          sync.Mutex
+	payments    map[string]*payment // By order ID
+	refundIDs   map[string]bool     // Order IDs of the refunds
+	nextTransID int64
+}
+
+// payment is a payment created through the gateway
+type payment struct {
+	request    entity.MoMoCreateRequest
+	resultCode entity.MoMoResultCode
+	transID    int64
+	payType    string
+	refunds    []entity.MoMoRefundTrans
+	updated    time.Time
+}
+
+// NewServer starts a gateway for the partner. Close it when done.
+func NewServer(partnerCode, accessKey, secretKey string) *Server {
+	s := &Server{
+		PartnerCode: partnerCode,
+		AccessKey:   accessKey,
+		SecretKey:   secretKey,
+		payments:    make(map[string]*payment),
+		refundIDs:   make(map[string]bool),
+		nextTransID: 4000000000,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/gateway/api/create", s.create)
+	mux.HandleFunc("/v2/gateway/api/query", s.query)
+	mux.HandleFunc("/v2/gateway/api/refund", s.refund)
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Payment returns the request a payment was created with
+func (s *Server) Payment(orderID string) (entity.MoMoCreateRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.payments[orderID]
+	if !ok {
+		return entity.MoMoCreateRequest{}, false
+	}
+	return p.request, true
+}
+
+// Pay completes a pending payment as if the user paid it, returning MoMo's transaction ID
+func (s *Server) Pay(orderID string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.payments[orderID]
+	if !ok || !p.resultCode.IsPending() {
+		return 0, false
+	}
+	s.nextTransID++
+	p.resultCode = entity.MoMoResultSuccess
+	p.transID = s.nextTransID
+	p.payType = "qr"
+	p.updated = time.Now()
+	return p.transID, true
+}
+
+// Fail ends a pending payment with the result code, e.g. entity.MoMoResultDeniedByUser
+func (s *Server) Fail(orderID string, code entity.MoMoResultCode) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.payments[orderID]
+	if !ok || !p.resultCode.IsPending() {
+		return false
+	}
+	p.resultCode = code
+	p.updated = time.Now()
+	return true
+}
+
+// Notification returns the signed notification MoMo sends to the ipnUrl of a payment that ended
+func (s *Server) Notification(orderID string) (*entity.MoMoIPN, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.payments[orderID]
+	if !ok || p.resultCode.IsPending() {
+		return nil, false
+	}
+	notification := &entity.MoMoIPN{
+		PartnerCode:  s.PartnerCode,
+		OrderID:      orderID,
+		RequestID:    p.request.RequestID,
+		Amount:       p.request.Amount,
+		OrderInfo:    p.request.OrderInfo,
+		OrderType:    "momo_wallet",
+		TransID:      p.transID,
+		ResultCode:   p.resultCode,
+		Message:      "Successful.",
+		PayType:      p.payType,
+		ResponseTime: p.updated.UnixMilli(),
+		ExtraData:    p.request.ExtraData,
+	}
+	if !p.resultCode.IsSuccess() {
+		notification.Message = "Transaction failed."
+	}
+	notification.Sign(s.AccessKey, s.SecretKey)
+	return notification, true
+}
+
+// Notify posts the notification of a payment that ended to its ipnUrl, returning the HTTP status of the answer.
+// MoMo expects 204 No Content and retries otherwise.
+func (s *Server) Notify(orderID string) (int, error) {
+	notification, ok := s.Notification(orderID)
+	if !ok {
+		return 0, errors.New("momotest: no ended payment for the order")
+	}
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	ipnURL := s.payments[orderID].request.IPNURL
+	s.mu.Unlock()
+
+	resp, err := http.Post(ipnURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request) {
+	var req entity.MoMoCreateRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	resp := entity.MoMoCreateResponse{
+		PartnerCode:  req.PartnerCode,
+		RequestID:    req.RequestID,
+		OrderID:      req.OrderID,
+		Amount:       req.Amount,
+		ResponseTime: time.Now().UnixMilli(),
+	}
+	if !s.authorized(req.PartnerCode, req.Signature, req.RawSignature(s.AccessKey)) {
+		resp.ResultCode, resp.Message = entity.MoMoResultAuthFailed, "Merchant authentication failed."
+		writeJSON(w, http.StatusUnauthorized, resp)
+		return
+	}
+	if req.RequestID == "" || req.OrderID == "" || req.OrderInfo == "" || req.RedirectURL == "" || req.IPNURL == "" || req.RequestType == "" {
+		resp.ResultCode, resp.Message = entity.MoMoResultBadFormat, "Bad format request."
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+	if req.Amount < MinAmount || req.Amount > MaxAmount {
+		resp.ResultCode, resp.Message = entity.MoMoResultInvalidAmount, "Invalid amount."
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+
+	s.mu.Lock()
+	_, exists := s.payments[req.OrderID]
+	if !exists {
+		s.payments[req.OrderID] = &payment{request: req, resultCode: entity.MoMoResultPending, updated: time.Now()}
+	}
+	s.mu.Unlock()
+	if exists {
+		resp.ResultCode, resp.Message = entity.MoMoResultDuplicateOrderID, "Duplicated orderId."
+		writeJSON(w, http.StatusConflict, resp)
+		return
+	}
+
+	resp.Message = "Successful."
+	resp.PayURL = s.URL + "/pay?orderId=" + req.OrderID
+	resp.Deeplink = "momo://app?action=payWithApp&orderId=" + req.OrderID
+	resp.QRCodeURL = "momo://app?action=payWithApp&isScanQR=true&orderId=" + req.OrderID
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) query(w http.ResponseWriter, r *http.Request) {
+	var req entity.MoMoQueryRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	resp := entity.MoMoQueryResponse{
+		PartnerCode:  req.PartnerCode,
+		RequestID:    req.RequestID,
+		OrderID:      req.OrderID,
+		ResponseTime: time.Now().UnixMilli(),
+	}
+	if !s.authorized(req.PartnerCode, req.Signature, req.RawSignature(s.AccessKey)) {
+		resp.ResultCode, resp.Message = entity.MoMoResultAuthFailed, "Merchant authentication failed."
+		writeJSON(w, http.StatusUnauthorized, resp)
+		return
+	}
+
+	s.mu.Lock()
+	p, ok := s.payments[req.OrderID]
+	if ok {
+		resp.ExtraData = p.request.ExtraData
+		resp.Amount = p.request.Amount
+		resp.TransID = p.transID
+		resp.PayType = p.payType
+		resp.ResultCode = p.resultCode
+		resp.RefundTrans = append([]entity.MoMoRefundTrans{}, p.refunds...)
+		resp.LastUpdated = p.updated.UnixMilli()
+	}
+	s.mu.Unlock()
+	if !ok {
+		resp.ResultCode, resp.Message = entity.MoMoResultOrderNotFound, "Order not found."
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+
+	resp.Message = "Successful."
+	if resp.ResultCode.IsPending() {
+		resp.Message = "Transaction is initiated, waiting for user confirmation."
+	} else if !resp.ResultCode.IsSuccess() {
+		resp.Message = "Transaction failed."
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) refund(w http.ResponseWriter, r *http.Request) {
+	var req entity.MoMoRefundRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	resp := entity.MoMoRefundResponse{
+		PartnerCode:  req.PartnerCode,
+		OrderID:      req.OrderID,
+		RequestID:    req.RequestID,
+		Amount:       req.Amount,
+		ResponseTime: time.Now().UnixMilli(),
+	}
+	if !s.authorized(req.PartnerCode, req.Signature, req.RawSignature(s.AccessKey)) {
+		resp.ResultCode, resp.Message = entity.MoMoResultAuthFailed, "Merchant authentication failed."
+		writeJSON(w, http.StatusUnauthorized, resp)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refundIDs[req.OrderID] || s.payments[req.OrderID] != nil {
+		resp.ResultCode, resp.Message = entity.MoMoResultDuplicateOrderID, "Duplicated orderId."
+		writeJSON(w, http.StatusConflict, resp)
+		return
+	}
+	var paid *payment
+	for _, p := range s.payments {
+		if p.transID == req.TransID && p.resultCode.IsSuccess() {
+			paid = p
+		}
+	}
+	refunded := int64(0)
+	if paid != nil {
+		for _, refund := range paid.refunds {
+			refunded += refund.Amount
+		}
+	}
+	if paid == nil || req.Amount < 1 || refunded+req.Amount > paid.request.Amount {
+		resp.ResultCode, resp.Message = entity.MoMoResultRefundRejected, "Refund rejected."
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	s.nextTransID++
+	s.refundIDs[req.OrderID] = true
+	resp.TransID = s.nextTransID
+	resp.Message = "Successful."
+	paid.refunds = append(paid.refunds, entity.MoMoRefundTrans{
+		OrderID:      req.OrderID,
+		Amount:       req.Amount,
+		ResultCode:   entity.MoMoResultSuccess,
+		TransID:      resp.TransID,
+		CreatedTime:  resp.ResponseTime,
+		Description:  req.Description,
+		ResponseTime: resp.ResponseTime,
+	})
+	paid.updated = time.Now()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// decode reads the JSON body of a POST, answering 400 when it is not one
+func (s *Server) decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"resultCode": entity.MoMoResultBadFormat,
+			"message":    "Bad format request.",
+		})
+		return false
+	}
+	return true
+}
+
+// authorized checks the partner code and the signature of a request
+func (s *Server) authorized(partnerCode, signature, rawSignature string) bool {
+	return partnerCode == s.PartnerCode && signature == entity.SignMoMo(s.SecretKey, rawSignature)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
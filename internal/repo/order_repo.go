@@ -0,0 +1,124 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"mlvt/internal/entity"
+	"time"
+)
+
+// OrderRepository stores the orders of users and the status of their payment
+type OrderRepository interface {
+	CreateOrder(order *entity.Order) error
+	GetOrderByID(orderID uint64) (*entity.Order, error)
+	GetOrderByReference(reference string) (*entity.Order, error)
+	ListOrdersByUserID(userID uint64) ([]entity.Order, error) // Newest first
+	// UpdateOrderPayment saves the status, transaction ID, refunded amount and payment time of the order if its
+	// status and refunded amount are still those of current, reporting whether they were
+	UpdateOrderPayment(order, current *entity.Order) (bool, error)
+}
+
+type orderRepo struct {
+	db *sql.DB
+}
+
+func NewOrderRepo(db *sql.DB) OrderRepository {
+	return &orderRepo{db: db}
+}
+
+const orderColumns = `id, reference, user_id, plan_id, amount, currency, provider, status, transaction_id, refunded_amount, paid_at, created_at, updated_at`
+
+// CreateOrder inserts an order, setting its ID and creation time
+func (r *orderRepo) CreateOrder(order *entity.Order) error {
+	order.CreatedAt = time.Now()
+	order.UpdatedAt = order.CreatedAt
+	query := `
+		INSERT INTO orders (reference, user_id, plan_id, amount, currency, provider, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, order.Reference, order.UserID, order.PlanID, order.Amount, order.Currency, order.Provider,
+		order.Status, order.CreatedAt, order.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create order: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	order.ID = uint64(id)
+	return nil
+}
+
+// GetOrderByID retrieves an order by its ID
+func (r *orderRepo) GetOrderByID(orderID uint64) (*entity.Order, error) {
+	return r.getOrder(`SELECT `+orderColumns+` FROM orders WHERE id = ?`, orderID)
+}
+
+// GetOrderByReference retrieves an order by the order ID given to the payment provider
+func (r *orderRepo) GetOrderByReference(reference string) (*entity.Order, error) {
+	return r.getOrder(`SELECT `+orderColumns+` FROM orders WHERE reference = ?`, reference)
+}
+
+func (r *orderRepo) getOrder(query string, arg interface{}) (*entity.Order, error) {
+	order, err := scanOrder(r.db.QueryRow(query, arg))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// ListOrdersByUserID retrieves the orders of the user, the newest first
+func (r *orderRepo) ListOrdersByUserID(userID uint64) ([]entity.Order, error) {
+	rows, err := r.db.Query(`SELECT `+orderColumns+` FROM orders WHERE user_id = ? ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %v", err)
+	}
+	defer rows.Close()
+
+	orders := []entity.Order{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *order)
+	}
+	return orders, rows.Err()
+}
+
+// UpdateOrderPayment saves the payment of the order unless it changed since current was read, so two
+// notifications of the same payment or two refunds cannot both apply to the same state
+func (r *orderRepo) UpdateOrderPayment(order, current *entity.Order) (bool, error) {
+	updatedAt := time.Now()
+	query := `
+		UPDATE orders SET status = ?, transaction_id = ?, refunded_amount = ?, paid_at = ?, updated_at = ?
+		WHERE id = ? AND status = ? AND refunded_amount = ?`
+	result, err := r.db.Exec(query, order.Status, order.TransactionID, order.RefundedAmount, order.PaidAt, updatedAt,
+		order.ID, current.Status, current.RefundedAmount)
+	if err != nil {
+		return false, fmt.Errorf("failed to update order: %v", err)
+	}
+	updated, err := affected(result)
+	if err != nil || !updated {
+		return false, err
+	}
+	order.UpdatedAt = updatedAt
+	return true, nil
+}
+
+func scanOrder(row rowScanner) (*entity.Order, error) {
+	order := &entity.Order{}
+	var paidAt sql.NullTime
+	err := row.Scan(&order.ID, &order.Reference, &order.UserID, &order.PlanID, &order.Amount, &order.Currency, &order.Provider,
+		&order.Status, &order.TransactionID, &order.RefundedAmount, &paidAt, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if paidAt.Valid {
+		order.PaidAt = &paidAt.Time
+	}
+	return order, nil
+}
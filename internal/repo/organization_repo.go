@@ -0,0 +1,289 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"mlvt/internal/entity"
+	"time"
+)
+
+// OrganizationRepository stores the organizations, their members and the invitations to join them
+type OrganizationRepository interface {
+	CreateOrganization(org *entity.Organization) error // The creator joins as the owner
+	GetOrganizationByID(orgID uint64) (*entity.Organization, error)
+	ListOrganizationsByUserID(userID uint64) ([]entity.Organization, error) // With the role of the user, by name
+	GetMemberRole(orgID, userID uint64) (entity.OrgRole, error)             // Empty when the user is no member
+	ListMembers(orgID uint64) ([]entity.OrganizationMember, error)
+	CountOwners(orgID uint64) (int, error)
+	UpdateMemberRole(orgID, userID uint64, role entity.OrgRole) (bool, error) // Reports whether the user is a member
+	RemoveMember(orgID, userID uint64) (bool, error)                          // Reports whether the user was a member
+	CreateInvite(invite *entity.OrganizationInvite) error
+	GetInviteByHash(tokenHash string) (*entity.OrganizationInvite, error)
+	ListPendingInvites(orgID uint64, now time.Time) ([]entity.OrganizationInvite, error)
+	RevokeInvite(orgID, inviteID uint64) (bool, error)                           // Reports whether the invitation was still pending
+	RevokeInvitesForEmail(orgID uint64, email string) error                      // Revokes the pending invitations of the address
+	AcceptInvite(invite *entity.OrganizationInvite, userID uint64) (bool, error) // Reports whether it was still pending
+}
+
+type organizationRepo struct {
+	db *sql.DB
+}
+
+func NewOrganizationRepo(db *sql.DB) OrganizationRepository {
+	return &organizationRepo{db: db}
+}
+
+const organizationInviteColumns = `id, org_id, email, role, token_hash, invited_by, expires_at, accepted_at, revoked_at, created_at`
+
+// CreateOrganization inserts the organization with its creator as the owner in a single transaction
+func (r *organizationRepo) CreateOrganization(org *entity.Organization) error {
+	now := time.Now()
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO organizations (name, created_by, created_at, updated_at) VALUES (?, ?, ?, ?)`, org.Name, org.CreatedBy, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to create organization: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO organization_members (org_id, user_id, role, created_at) VALUES (?, ?, ?, ?)`, id, org.CreatedBy, entity.OrgRoleOwner, now)
+	if err != nil {
+		return fmt.Errorf("failed to add organization owner: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	org.ID = uint64(id)
+	org.Role = entity.OrgRoleOwner
+	org.CreatedAt = now
+	org.UpdatedAt = now
+	return nil
+}
+
+// GetOrganizationByID retrieves an organization by its ID
+func (r *organizationRepo) GetOrganizationByID(orgID uint64) (*entity.Organization, error) {
+	org := &entity.Organization{}
+	err := r.db.QueryRow(`SELECT id, name, created_by, created_at, updated_at FROM organizations WHERE id = ?`, orgID).
+		Scan(&org.ID, &org.Name, &org.CreatedBy, &org.CreatedAt, &org.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// ListOrganizationsByUserID retrieves the organizations the user is a member of with their role, by name
+func (r *organizationRepo) ListOrganizationsByUserID(userID uint64) ([]entity.Organization, error) {
+	query := `
+		SELECT o.id, o.name, o.created_by, m.role, o.created_at, o.updated_at
+		FROM organizations o JOIN organization_members m ON m.org_id = o.id
+		WHERE m.user_id = ? ORDER BY o.name, o.id`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %v", err)
+	}
+	defer rows.Close()
+
+	orgs := []entity.Organization{}
+	for rows.Next() {
+		var org entity.Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.CreatedBy, &org.Role, &org.CreatedAt, &org.UpdatedAt); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+// GetMemberRole retrieves the role of the user in the organization, empty when they are no member
+func (r *organizationRepo) GetMemberRole(orgID, userID uint64) (entity.OrgRole, error) {
+	var role entity.OrgRole
+	err := r.db.QueryRow(`SELECT role FROM organization_members WHERE org_id = ? AND user_id = ?`, orgID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// ListMembers retrieves the members of the organization with their username and email, in the order they joined
+func (r *organizationRepo) ListMembers(orgID uint64) ([]entity.OrganizationMember, error) {
+	query := `
+		SELECT m.org_id, m.user_id, u.username, u.email, m.role, m.created_at
+		FROM organization_members m JOIN users u ON u.id = m.user_id
+		WHERE m.org_id = ? ORDER BY m.created_at, m.user_id`
+	rows, err := r.db.Query(query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization members: %v", err)
+	}
+	defer rows.Close()
+
+	members := []entity.OrganizationMember{}
+	for rows.Next() {
+		var member entity.OrganizationMember
+		if err := rows.Scan(&member.OrgID, &member.UserID, &member.UserName, &member.Email, &member.Role, &member.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// CountOwners counts the members of the organization with the owner role
+func (r *organizationRepo) CountOwners(orgID uint64) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM organization_members WHERE org_id = ? AND role = ?`, orgID, entity.OrgRoleOwner).Scan(&count)
+	return count, err
+}
+
+// UpdateMemberRole changes the role of a member of the organization
+func (r *organizationRepo) UpdateMemberRole(orgID, userID uint64, role entity.OrgRole) (bool, error) {
+	result, err := r.db.Exec(`UPDATE organization_members SET role = ? WHERE org_id = ? AND user_id = ?`, role, orgID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update organization member: %v", err)
+	}
+	return affected(result)
+}
+
+// RemoveMember removes the user from the organization. The resources they shared stay with the organization.
+func (r *organizationRepo) RemoveMember(orgID, userID uint64) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM organization_members WHERE org_id = ? AND user_id = ?`, orgID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove organization member: %v", err)
+	}
+	return affected(result)
+}
+
+// CreateInvite inserts an invitation, setting its ID and creation time
+func (r *organizationRepo) CreateInvite(invite *entity.OrganizationInvite) error {
+	invite.CreatedAt = time.Now()
+	query := `
+		INSERT INTO organization_invites (org_id, email, role, token_hash, invited_by, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, invite.OrgID, invite.Email, invite.Role, invite.TokenHash, invite.InvitedBy, invite.ExpiresAt, invite.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create organization invite: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	invite.ID = uint64(id)
+	return nil
+}
+
+// GetInviteByHash retrieves an invitation by the hash of its token, pending or not
+func (r *organizationRepo) GetInviteByHash(tokenHash string) (*entity.OrganizationInvite, error) {
+	invite, err := scanOrganizationInvite(r.db.QueryRow(`SELECT `+organizationInviteColumns+` FROM organization_invites WHERE token_hash = ?`, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// ListPendingInvites retrieves the invitations of the organization that may still be accepted, the newest first
+func (r *organizationRepo) ListPendingInvites(orgID uint64, now time.Time) ([]entity.OrganizationInvite, error) {
+	query := `SELECT ` + organizationInviteColumns + ` FROM organization_invites
+		WHERE org_id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ? ORDER BY created_at DESC, id DESC`
+	rows, err := r.db.Query(query, orgID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization invites: %v", err)
+	}
+	defer rows.Close()
+
+	invites := []entity.OrganizationInvite{}
+	for rows.Next() {
+		invite, err := scanOrganizationInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, *invite)
+	}
+	return invites, rows.Err()
+}
+
+// RevokeInvite marks an invitation of the organization as revoked, so its token stops working
+func (r *organizationRepo) RevokeInvite(orgID, inviteID uint64) (bool, error) {
+	result, err := r.db.Exec(`UPDATE organization_invites SET revoked_at = ? WHERE id = ? AND org_id = ? AND accepted_at IS NULL AND revoked_at IS NULL`,
+		time.Now(), inviteID, orgID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke organization invite: %v", err)
+	}
+	return affected(result)
+}
+
+// RevokeInvitesForEmail revokes the pending invitations of the organization to the email address
+func (r *organizationRepo) RevokeInvitesForEmail(orgID uint64, email string) error {
+	_, err := r.db.Exec(`UPDATE organization_invites SET revoked_at = ? WHERE org_id = ? AND email = ? AND accepted_at IS NULL AND revoked_at IS NULL`,
+		time.Now(), orgID, email)
+	if err != nil {
+		return fmt.Errorf("failed to revoke organization invites: %v", err)
+	}
+	return nil
+}
+
+// AcceptInvite marks the invitation as accepted and adds the user to the organization with its role in a single
+// transaction. An invitation is accepted once.
+func (r *organizationRepo) AcceptInvite(invite *entity.OrganizationInvite, userID uint64) (bool, error) {
+	now := time.Now()
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE organization_invites SET accepted_at = ? WHERE id = ? AND accepted_at IS NULL AND revoked_at IS NULL`, now, invite.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to accept organization invite: %v", err)
+	}
+	if pending, err := affected(result); err != nil || !pending {
+		return false, err
+	}
+	_, err = tx.Exec(`INSERT INTO organization_members (org_id, user_id, role, created_at) VALUES (?, ?, ?, ?)`, invite.OrgID, userID, invite.Role, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to add organization member: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	invite.AcceptedAt = &now
+	return true, nil
+}
+
+func scanOrganizationInvite(row rowScanner) (*entity.OrganizationInvite, error) {
+	invite := &entity.OrganizationInvite{}
+	var acceptedAt, revokedAt sql.NullTime
+	err := row.Scan(&invite.ID, &invite.OrgID, &invite.Email, &invite.Role, &invite.TokenHash, &invite.InvitedBy, &invite.ExpiresAt,
+		&acceptedAt, &revokedAt, &invite.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if acceptedAt.Valid {
+		invite.AcceptedAt = &acceptedAt.Time
+	}
+	if revokedAt.Valid {
+		invite.RevokedAt = &revokedAt.Time
+	}
+	return invite, nil
+}
+
+// affected reports whether the statement changed any row
+func affected(result sql.Result) (bool, error) {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	return rowsAffected > 0, nil
+}
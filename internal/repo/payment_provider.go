@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"mlvt/internal/entity"
+	"sort"
+)
+
+var (
+	ErrProviderNotConfigured   = errors.New("payment provider is not configured")
+	ErrCheckoutNotSupported    = errors.New("the provider does not take payments of this currency or amount")
+	ErrInvalidWebhookSignature = errors.New("invalid notification signature")
+	ErrMalformedWebhook        = errors.New("malformed notification")
+	ErrNoProviderTransaction   = errors.New("the order has no transaction at the provider")
+)
+
+// PaymentProvider is a payment gateway orders are paid through. Requests the provider rejects return an error
+// implementing entity.PaymentProviderError.
+type PaymentProvider interface {
+	// Name is the name of the provider in orders and URLs
+	Name() string
+	// CreateCheckout creates a payment of the order at the provider, for the user at clientIP to complete
+	CreateCheckout(order *entity.Order, description, clientIP string) (*entity.Checkout, error)
+	// GetPaymentStatus asks the provider for the status of the payment of the order
+	GetPaymentStatus(order *entity.Order) (*entity.PaymentStatus, error)
+	// Refund refunds amount of the paid order
+	Refund(order *entity.Order, amount int64) (*entity.PaymentRefund, error)
+	// VerifyWebhook checks that a notification comes from the provider and reads the payment event in it. It
+	// returns ErrInvalidWebhookSignature or ErrMalformedWebhook otherwise.
+	VerifyWebhook(request *entity.WebhookRequest) (*entity.PaymentEvent, error)
+	// WebhookResponse returns the answer the provider expects for a notification handled with the outcome
+	WebhookResponse(outcome entity.WebhookOutcome) *entity.WebhookResponse
+}
+
+// PaymentProviders are the payment providers orders may be paid through, by name
+type PaymentProviders map[string]PaymentProvider
+
+// NewPaymentProviders lists the supported payment providers
+func NewPaymentProviders(momoRepo MoMoRepo, vnpayRepo VNPayRepo) PaymentProviders {
+	providers := PaymentProviders{}
+	for _, provider := range []PaymentProvider{momoRepo, vnpayRepo} {
+		providers[provider.Name()] = provider
+	}
+	return providers
+}
+
+// Names returns the names of the providers, sorted
+func (p PaymentProviders) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newPaymentRequestID returns a unique ID for a request to a provider, which providers use to detect retries
+func newPaymentRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,226 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"time"
+)
+
+// ErrPipelineStepChanged is returned when a step is updated from a status it is no longer in
+var ErrPipelineStepChanged = errors.New("pipeline step changed concurrently")
+
+type PipelineRepository interface {
+	CreatePipeline(pipeline *entity.Pipeline) error
+	GetPipelineByID(pipelineID uint64) (*entity.Pipeline, error)
+	ListPipelinesByVideoID(videoID uint64) ([]entity.Pipeline, error)
+	UpdatePipelineStatus(pipelineID uint64, status entity.PipelineStatus, errorMessage string) error
+	GetPipelineStepByID(stepID uint64) (*entity.PipelineStep, error)
+	UpdatePipelineStep(step *entity.PipelineStep, from entity.PipelineStepStatus) error
+}
+
+type pipelineRepo struct {
+	db *sql.DB
+}
+
+func NewPipelineRepo(db *sql.DB) PipelineRepository {
+	return &pipelineRepo{db: db}
+}
+
+const pipelineStepColumns = `id, pipeline_id, name, type, source_lang, target_lang, depends_on, status, job_id,
+	input_transcription_id, input_audio_id, output_transcription_id, output_audio_id, output_folder, output_file_name, error, created_at, updated_at`
+
+// CreatePipeline inserts the pipeline together with its steps in a single transaction
+func (r *pipelineRepo) CreatePipeline(pipeline *entity.Pipeline) error {
+	if pipeline.Status == "" {
+		pipeline.Status = entity.PipelineStatusRunning
+	}
+	now := time.Now()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO pipelines (video_id, user_id, source_lang, status, error, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		pipeline.VideoID, pipeline.UserID, pipeline.SourceLang, pipeline.Status, pipeline.Error, now, now)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for i := range pipeline.Steps {
+		step := &pipeline.Steps[i]
+		step.PipelineID = uint64(id)
+		if step.Status == "" {
+			step.Status = entity.PipelineStepPending
+		}
+		result, err := tx.Exec(`
+			INSERT INTO pipeline_steps (pipeline_id, name, type, source_lang, target_lang, depends_on, status, job_id,
+				input_transcription_id, input_audio_id, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			step.PipelineID, step.Name, step.Type, step.SourceLang, step.TargetLang, step.DependsOn, step.Status, step.JobID,
+			step.InputTranscriptionID, step.InputAudioID, now, now)
+		if err != nil {
+			return fmt.Errorf("failed to insert pipeline step %s: %v", step.Name, err)
+		}
+		stepID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		step.ID = uint64(stepID)
+		step.CreatedAt = now
+		step.UpdatedAt = now
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	pipeline.ID = uint64(id)
+	pipeline.CreatedAt = now
+	pipeline.UpdatedAt = now
+	return nil
+}
+
+// GetPipelineByID retrieves a pipeline and its steps in creation order
+func (r *pipelineRepo) GetPipelineByID(pipelineID uint64) (*entity.Pipeline, error) {
+	query := `SELECT id, video_id, user_id, source_lang, status, error, created_at, updated_at FROM pipelines WHERE id = ?`
+	pipeline := &entity.Pipeline{}
+	err := r.db.QueryRow(query, pipelineID).Scan(&pipeline.ID, &pipeline.VideoID, &pipeline.UserID, &pipeline.SourceLang,
+		&pipeline.Status, &pipeline.Error, &pipeline.CreatedAt, &pipeline.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline.Steps, err = r.listPipelineSteps(pipeline.ID)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+// ListPipelinesByVideoID lists the pipelines of a video with their steps, newest first
+func (r *pipelineRepo) ListPipelinesByVideoID(videoID uint64) ([]entity.Pipeline, error) {
+	query := `SELECT id, video_id, user_id, source_lang, status, error, created_at, updated_at
+	          FROM pipelines WHERE video_id = ? ORDER BY id DESC`
+	rows, err := r.db.Query(query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pipelines := []entity.Pipeline{}
+	for rows.Next() {
+		var pipeline entity.Pipeline
+		if err := rows.Scan(&pipeline.ID, &pipeline.VideoID, &pipeline.UserID, &pipeline.SourceLang,
+			&pipeline.Status, &pipeline.Error, &pipeline.CreatedAt, &pipeline.UpdatedAt); err != nil {
+			return nil, err
+		}
+		pipelines = append(pipelines, pipeline)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range pipelines {
+		pipelines[i].Steps, err = r.listPipelineSteps(pipelines[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pipelines, nil
+}
+
+// UpdatePipelineStatus sets the overall status of a pipeline
+func (r *pipelineRepo) UpdatePipelineStatus(pipelineID uint64, status entity.PipelineStatus, errorMessage string) error {
+	query := `UPDATE pipelines SET status = ?, error = ?, updated_at = ? WHERE id = ?`
+	result, err := r.db.Exec(query, status, errorMessage, time.Now(), pipelineID)
+	if err != nil {
+		return fmt.Errorf("failed to update pipeline: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no pipeline found with id %d", pipelineID)
+	}
+	return nil
+}
+
+// GetPipelineStepByID retrieves a single pipeline step
+func (r *pipelineRepo) GetPipelineStepByID(stepID uint64) (*entity.PipelineStep, error) {
+	row := r.db.QueryRow(`SELECT `+pipelineStepColumns+` FROM pipeline_steps WHERE id = ?`, stepID)
+	step, err := scanPipelineStep(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return step, err
+}
+
+// UpdatePipelineStep stores the status, task, inputs, outputs and error of a step still in the from status.
+// It returns ErrPipelineStepChanged when another update moved the step first.
+func (r *pipelineRepo) UpdatePipelineStep(step *entity.PipelineStep, from entity.PipelineStepStatus) error {
+	query := `
+		UPDATE pipeline_steps
+		SET status = ?, job_id = ?, input_transcription_id = ?, input_audio_id = ?, output_transcription_id = ?,
+			output_audio_id = ?, output_folder = ?, output_file_name = ?, error = ?, updated_at = ?
+		WHERE id = ? AND status = ?`
+	now := time.Now()
+	result, err := r.db.Exec(query, step.Status, step.JobID, step.InputTranscriptionID, step.InputAudioID, step.OutputTranscriptionID,
+		step.OutputAudioID, step.OutputFolder, step.OutputFileName, step.Error, now, step.ID, from)
+	if err != nil {
+		return fmt.Errorf("failed to update pipeline step: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrPipelineStepChanged
+	}
+	step.UpdatedAt = now
+	return nil
+}
+
+func (r *pipelineRepo) listPipelineSteps(pipelineID uint64) ([]entity.PipelineStep, error) {
+	rows, err := r.db.Query(`SELECT `+pipelineStepColumns+` FROM pipeline_steps WHERE pipeline_id = ? ORDER BY id`, pipelineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	steps := []entity.PipelineStep{}
+	for rows.Next() {
+		step, err := scanPipelineStep(rows)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, *step)
+	}
+	return steps, rows.Err()
+}
+
+func scanPipelineStep(row rowScanner) (*entity.PipelineStep, error) {
+	step := &entity.PipelineStep{}
+	err := row.Scan(&step.ID, &step.PipelineID, &step.Name, &step.Type, &step.SourceLang, &step.TargetLang, &step.DependsOn,
+		&step.Status, &step.JobID, &step.InputTranscriptionID, &step.InputAudioID, &step.OutputTranscriptionID,
+		&step.OutputAudioID, &step.OutputFolder, &step.OutputFileName, &step.Error, &step.CreatedAt, &step.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return step, nil
+}
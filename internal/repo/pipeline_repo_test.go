@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"mlvt/internal/entity"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupPipelineTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	schema, err := os.ReadFile("../../migration/0012_create_pipelines_table.up.sql")
+	require.NoError(t, err)
+	_, err = db.Exec(string(schema))
+	require.NoError(t, err)
+	return db
+}
+
+func newTestPipeline(videoID uint64) *entity.Pipeline {
+	return &entity.Pipeline{
+		VideoID:    videoID,
+		UserID:     1,
+		SourceLang: "en",
+		Steps: []entity.PipelineStep{
+			{Name: "stt:en", Type: entity.MLTaskSpeechToText, SourceLang: "en"},
+			{Name: "mt:vi", Type: entity.MLTaskTranslation, SourceLang: "en", TargetLang: "vi", DependsOn: "stt:en"},
+		},
+	}
+}
+
+func TestCreatePipeline(t *testing.T) {
+	pipelineRepo := NewPipelineRepo(setupPipelineTestDB(t))
+
+	pipeline := newTestPipeline(7)
+	require.NoError(t, pipelineRepo.CreatePipeline(pipeline))
+	assert.Equal(t, uint64(1), pipeline.ID)
+	assert.Equal(t, entity.PipelineStatusRunning, pipeline.Status)
+
+	saved, err := pipelineRepo.GetPipelineByID(pipeline.ID)
+	require.NoError(t, err)
+	require.Len(t, saved.Steps, 2)
+	assert.Equal(t, "stt:en", saved.Steps[0].Name)
+	assert.Equal(t, entity.PipelineStepPending, saved.Steps[1].Status)
+	assert.Equal(t, "stt:en", saved.Steps[1].DependsOn)
+	assert.Equal(t, pipeline.ID, saved.Steps[1].PipelineID)
+
+	// Step names are unique within a pipeline, so nothing of a rejected pipeline is stored
+	duplicate := newTestPipeline(7)
+	duplicate.Steps[1].Name = "stt:en"
+	assert.Error(t, pipelineRepo.CreatePipeline(duplicate))
+	pipelines, err := pipelineRepo.ListPipelinesByVideoID(7)
+	require.NoError(t, err)
+	assert.Len(t, pipelines, 1)
+
+	missing, err := pipelineRepo.GetPipelineByID(99)
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestListPipelinesByVideoID(t *testing.T) {
+	pipelineRepo := NewPipelineRepo(setupPipelineTestDB(t))
+
+	for _, videoID := range []uint64{7, 8, 7} {
+		require.NoError(t, pipelineRepo.CreatePipeline(newTestPipeline(videoID)))
+	}
+
+	pipelines, err := pipelineRepo.ListPipelinesByVideoID(7)
+	require.NoError(t, err)
+	require.Len(t, pipelines, 2)
+	assert.Equal(t, uint64(3), pipelines[0].ID, "newest first")
+	assert.Len(t, pipelines[0].Steps, 2)
+}
+
+func TestUpdatePipelineStep(t *testing.T) {
+	pipelineRepo := NewPipelineRepo(setupPipelineTestDB(t))
+	pipeline := newTestPipeline(7)
+	require.NoError(t, pipelineRepo.CreatePipeline(pipeline))
+
+	step := pipeline.Steps[0]
+	step.Status = entity.PipelineStepQueued
+	step.JobID = 12
+	require.NoError(t, pipelineRepo.UpdatePipelineStep(&step, entity.PipelineStepPending))
+
+	// A second update from the old status lost the race
+	stale := pipeline.Steps[0]
+	stale.Status = entity.PipelineStepQueued
+	assert.ErrorIs(t, pipelineRepo.UpdatePipelineStep(&stale, entity.PipelineStepPending), ErrPipelineStepChanged)
+
+	step.Status = entity.PipelineStepSucceeded
+	step.OutputTranscriptionID = 5
+	require.NoError(t, pipelineRepo.UpdatePipelineStep(&step, entity.PipelineStepQueued))
+
+	saved, err := pipelineRepo.GetPipelineStepByID(step.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.PipelineStepSucceeded, saved.Status)
+	assert.Equal(t, uint64(12), saved.JobID)
+	assert.Equal(t, uint64(5), saved.OutputTranscriptionID)
+
+	require.NoError(t, pipelineRepo.UpdatePipelineStatus(pipeline.ID, entity.PipelineStatusFailed, "step mt:vi failed"))
+	updated, err := pipelineRepo.GetPipelineByID(pipeline.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.PipelineStatusFailed, updated.Status)
+	assert.Equal(t, "step mt:vi failed", updated.Error)
+	assert.Error(t, pipelineRepo.UpdatePipelineStatus(99, entity.PipelineStatusFailed, ""))
+}
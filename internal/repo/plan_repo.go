@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"mlvt/internal/entity"
+)
+
+// PlanRepository reads the plans users pay for
+type PlanRepository interface {
+	GetPlanByID(planID uint64) (*entity.Plan, error)
+	GetPlanByCode(code string) (*entity.Plan, error)
+	ListActivePlans() ([]entity.Plan, error) // Cheapest first
+}
+
+type planRepo struct {
+	db *sql.DB
+}
+
+func NewPlanRepo(db *sql.DB) PlanRepository {
+	return &planRepo{db: db}
+}
+
+const planColumns = `id, code, name, amount, currency, billing_period, max_videos, max_video_minutes, active, created_at, updated_at`
+
+// GetPlanByID retrieves a plan, active or not
+func (r *planRepo) GetPlanByID(planID uint64) (*entity.Plan, error) {
+	return r.getPlan(`SELECT `+planColumns+` FROM plans WHERE id = ?`, planID)
+}
+
+// GetPlanByCode retrieves a plan by its code, active or not
+func (r *planRepo) GetPlanByCode(code string) (*entity.Plan, error) {
+	return r.getPlan(`SELECT `+planColumns+` FROM plans WHERE code = ?`, code)
+}
+
+func (r *planRepo) getPlan(query string, arg interface{}) (*entity.Plan, error) {
+	plan, err := scanPlan(r.db.QueryRow(query, arg))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// ListActivePlans retrieves the plans that can be ordered, the cheapest first
+func (r *planRepo) ListActivePlans() ([]entity.Plan, error) {
+	rows, err := r.db.Query(`SELECT ` + planColumns + ` FROM plans WHERE active = 1 ORDER BY amount, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plans: %v", err)
+	}
+	defer rows.Close()
+
+	plans := []entity.Plan{}
+	for rows.Next() {
+		plan, err := scanPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, *plan)
+	}
+	return plans, rows.Err()
+}
+
+func scanPlan(row rowScanner) (*entity.Plan, error) {
+	plan := &entity.Plan{}
+	err := row.Scan(&plan.ID, &plan.Code, &plan.Name, &plan.Amount, &plan.Currency, &plan.BillingPeriod, &plan.Quotas.MaxVideos,
+		&plan.Quotas.MaxVideoMinutes, &plan.Active, &plan.CreatedAt, &plan.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
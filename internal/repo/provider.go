@@ -6,9 +6,28 @@ import "github.com/google/wire"
 var ProviderSetRepository = wire.NewSet(
 	NewUserRepo,
 	NewVideoRepo,
+	NewUploadSessionRepo,
+	NewJobRepo,
+	NewMLWorkerRepo,
+	NewPipelineRepo,
 	NewAudioRepository,
 	NewTranscriptionRepository,
+	NewTranscriptionSegmentRepo,
+	NewAuditLogRepo,
+	NewRefreshTokenRepo,
+	NewUserTokenRepo,
+	NewRecoveryCodeRepo,
+	NewLoginAttemptStore,
+	NewAPIKeyRepo,
+	NewUserIdentityRepo,
+	NewOrganizationRepo,
 	NewMoMoRepo,
+	NewVNPayRepo,
+	NewPaymentProviders,
+	NewTransactionLogRepo,
+	NewPlanRepo,
+	NewOrderRepo,
+	NewSubscriptionRepo,
 	// wire.Bind(new(UserRepository), new(*userRepo)),
 	// wire.Bind(new(VideoRepository), new(*videoRepo)),
 	// wire.Bind(new(AudioRepository), new(*audioRepo)),
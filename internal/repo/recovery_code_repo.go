@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RecoveryCodeRepository stores the hashes of the one-time two-factor recovery codes of users
+type RecoveryCodeRepository interface {
+	ReplaceRecoveryCodes(userID uint64, codeHashes []string) error // Earlier codes of the user stop working
+	UseRecoveryCode(userID uint64, codeHash string) (bool, error)  // Reports whether an unused code matched
+	CountRecoveryCodes(userID uint64) (int, error)                 // Counts the unused codes
+	DeleteRecoveryCodes(userID uint64) error
+}
+
+type recoveryCodeRepo struct {
+	db *sql.DB
+}
+
+func NewRecoveryCodeRepo(db *sql.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepo{db: db}
+}
+
+// ReplaceRecoveryCodes deletes the codes of the user and inserts the new ones in a single transaction
+func (r *recoveryCodeRepo) ReplaceRecoveryCodes(userID uint64, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for _, codeHash := range codeHashes {
+		if _, err := stmt.Exec(userID, codeHash, now); err != nil {
+			return fmt.Errorf("failed to insert recovery code: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode marks the unused code of the user with the hash as used. Of two concurrent calls for the same
+// code only one reports it matched.
+func (r *recoveryCodeRepo) UseRecoveryCode(userID uint64, codeHash string) (bool, error) {
+	result, err := r.db.Exec(`UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		time.Now(), userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// CountRecoveryCodes counts the codes of the user that were not used yet
+func (r *recoveryCodeRepo) CountRecoveryCodes(userID uint64) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// DeleteRecoveryCodes deletes every code of the user
+func (r *recoveryCodeRepo) DeleteRecoveryCodes(userID uint64) error {
+	if _, err := r.db.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"mlvt/internal/entity"
+	"time"
+)
+
+// RefreshTokenRepository stores the hashes of the refresh tokens issued to users
+type RefreshTokenRepository interface {
+	CreateRefreshToken(token *entity.RefreshToken) error
+	GetRefreshTokenByHash(tokenHash string) (*entity.RefreshToken, error)
+	RevokeRefreshToken(tokenID uint64) (bool, error) // Reports whether the token was still active
+}
+
+type refreshTokenRepo struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenRepo(db *sql.DB) RefreshTokenRepository {
+	return &refreshTokenRepo{db: db}
+}
+
+// CreateRefreshToken inserts a refresh token, setting its ID and creation time
+func (r *refreshTokenRepo) CreateRefreshToken(token *entity.RefreshToken) error {
+	token.CreatedAt = time.Now()
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, token_version, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, token.UserID, token.TokenHash, token.TokenVersion, token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	token.ID = uint64(id)
+	return nil
+}
+
+// GetRefreshTokenByHash retrieves a refresh token by the hash of its value, revoked or not
+func (r *refreshTokenRepo) GetRefreshTokenByHash(tokenHash string) (*entity.RefreshToken, error) {
+	query := `SELECT id, user_id, token_hash, token_version, expires_at, revoked_at, created_at FROM refresh_tokens WHERE token_hash = ?`
+
+	token := &entity.RefreshToken{}
+	var revokedAt sql.NullTime
+	err := r.db.QueryRow(query, tokenHash).Scan(&token.ID, &token.UserID, &token.TokenHash, &token.TokenVersion, &token.ExpiresAt, &revokedAt, &token.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return token, nil
+}
+
+// RevokeRefreshToken marks an active refresh token as used. Of two concurrent calls for the same token only one
+// reports it was active, so a token cannot be rotated twice.
+func (r *refreshTokenRepo) RevokeRefreshToken(tokenID uint64) (bool, error) {
+	result, err := r.db.Exec(`UPDATE refresh_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, time.Now(), tokenID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke refresh token: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,241 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"time"
+)
+
+// ErrPeriodExists is returned when the order of a subscription period already added one
+var ErrPeriodExists = errors.New("the order already added a subscription period")
+
+// SubscriptionRepository stores the premium subscriptions of users and the periods their orders paid for.
+// It keeps the premium flag of users in step with the status of their subscription.
+type SubscriptionRepository interface {
+	GetSubscriptionByUserID(userID uint64) (*entity.Subscription, error)
+	ListSubscriptionPeriods(subscriptionID uint64) ([]entity.SubscriptionPeriod, error) // Newest first
+	// ListEndedSubscriptions retrieves the subscriptions still granting premium whose period ended by the time
+	ListEndedSubscriptions(by time.Time) ([]entity.Subscription, error)
+	// SaveSubscription saves the subscription and the premium flag of its user in one transaction, inserting it when
+	// current is nil and otherwise only if its status and period end are still those of current, reporting whether
+	// it did. The period, when given, is added for its order; ErrPeriodExists is returned if the order added one already.
+	SaveSubscription(sub, current *entity.Subscription, period *entity.SubscriptionPeriod) (bool, error)
+	// RemoveSubscriptionPeriod saves the subscription like SaveSubscription does with current, removes the period and
+	// moves the periods that followed it to their new start and end, in one transaction
+	RemoveSubscriptionPeriod(sub, current *entity.Subscription, removed *entity.SubscriptionPeriod, moved []entity.SubscriptionPeriod) (bool, error)
+}
+
+type subscriptionRepo struct {
+	db *sql.DB
+}
+
+func NewSubscriptionRepo(db *sql.DB) SubscriptionRepository {
+	return &subscriptionRepo{db: db}
+}
+
+const subscriptionColumns = `id, user_id, plan_id, status, current_period_start, current_period_end, canceled_at, created_at, updated_at`
+
+const subscriptionPeriodColumns = `id, subscription_id, order_id, plan_id, period_start, period_end, created_at`
+
+// GetSubscriptionByUserID retrieves the subscription of the user, whatever its status
+func (r *subscriptionRepo) GetSubscriptionByUserID(userID uint64) (*entity.Subscription, error) {
+	sub, err := scanSubscription(r.db.QueryRow(`SELECT `+subscriptionColumns+` FROM subscriptions WHERE user_id = ?`, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// ListSubscriptionPeriods retrieves the periods paid for the subscription, the newest first
+func (r *subscriptionRepo) ListSubscriptionPeriods(subscriptionID uint64) ([]entity.SubscriptionPeriod, error) {
+	rows, err := r.db.Query(`SELECT `+subscriptionPeriodColumns+` FROM subscription_periods WHERE subscription_id = ? ORDER BY period_start DESC, id DESC`, subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscription periods: %v", err)
+	}
+	defer rows.Close()
+
+	periods := []entity.SubscriptionPeriod{}
+	for rows.Next() {
+		period := entity.SubscriptionPeriod{}
+		err := rows.Scan(&period.ID, &period.SubscriptionID, &period.OrderID, &period.PlanID, &period.PeriodStart, &period.PeriodEnd, &period.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		periods = append(periods, period)
+	}
+	return periods, rows.Err()
+}
+
+// ListEndedSubscriptions retrieves the subscriptions that are not expired although their period ended by the time
+func (r *subscriptionRepo) ListEndedSubscriptions(by time.Time) ([]entity.Subscription, error) {
+	rows, err := r.db.Query(`SELECT `+subscriptionColumns+` FROM subscriptions WHERE status <> ? AND current_period_end <= ? ORDER BY current_period_end, id`,
+		entity.SubscriptionStatusExpired, by.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ended subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	subs := []entity.Subscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+// SaveSubscription saves the subscription unless it changed since current was read, so a renewal and the expiry
+// check, or two renewals, cannot both apply to the same state
+func (r *subscriptionRepo) SaveSubscription(sub, current *entity.Subscription, period *entity.SubscriptionPeriod) (bool, error) {
+	now := time.Now().UTC()
+	sub.CurrentPeriodStart = sub.CurrentPeriodStart.UTC()
+	sub.CurrentPeriodEnd = sub.CurrentPeriodEnd.UTC()
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var result sql.Result
+	if current == nil {
+		sub.CreatedAt = now
+		result, err = tx.Exec(`
+			INSERT INTO subscriptions (user_id, plan_id, status, current_period_start, current_period_end, canceled_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (user_id) DO NOTHING`,
+			sub.UserID, sub.PlanID, sub.Status, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, sub.CanceledAt, now, now)
+	} else {
+		result, err = updateSubscription(tx, sub, current, now)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to save subscription: %v", err)
+	}
+	if saved, err := affected(result); err != nil || !saved {
+		return false, err
+	}
+	if current == nil {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return false, err
+		}
+		sub.ID = uint64(id)
+	}
+
+	if period != nil {
+		period.SubscriptionID = sub.ID
+		period.CreatedAt = now
+		result, err := tx.Exec(`
+			INSERT INTO subscription_periods (subscription_id, order_id, plan_id, period_start, period_end, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (order_id) DO NOTHING`,
+			period.SubscriptionID, period.OrderID, period.PlanID, period.PeriodStart.UTC(), period.PeriodEnd.UTC(), now)
+		if err != nil {
+			return false, fmt.Errorf("failed to add subscription period: %v", err)
+		}
+		added, err := affected(result)
+		if err != nil {
+			return false, err
+		}
+		if !added {
+			return false, ErrPeriodExists
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return false, err
+		}
+		period.ID = uint64(id)
+	}
+
+	if err := setPremium(tx, sub, now); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	sub.UpdatedAt = now
+	return true, nil
+}
+
+// RemoveSubscriptionPeriod takes the period of a refunded order out of the subscription unless the subscription
+// changed since current was read
+func (r *subscriptionRepo) RemoveSubscriptionPeriod(sub, current *entity.Subscription, removed *entity.SubscriptionPeriod, moved []entity.SubscriptionPeriod) (bool, error) {
+	now := time.Now().UTC()
+	sub.CurrentPeriodStart = sub.CurrentPeriodStart.UTC()
+	sub.CurrentPeriodEnd = sub.CurrentPeriodEnd.UTC()
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := updateSubscription(tx, sub, current, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to save subscription: %v", err)
+	}
+	if saved, err := affected(result); err != nil || !saved {
+		return false, err
+	}
+
+	result, err = tx.Exec(`DELETE FROM subscription_periods WHERE id = ?`, removed.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove subscription period: %v", err)
+	}
+	if deleted, err := affected(result); err != nil || !deleted {
+		return false, err
+	}
+	for _, period := range moved {
+		_, err := tx.Exec(`UPDATE subscription_periods SET period_start = ?, period_end = ? WHERE id = ?`,
+			period.PeriodStart.UTC(), period.PeriodEnd.UTC(), period.ID)
+		if err != nil {
+			return false, fmt.Errorf("failed to move subscription period: %v", err)
+		}
+	}
+
+	if err := setPremium(tx, sub, now); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	sub.UpdatedAt = now
+	return true, nil
+}
+
+// updateSubscription updates the subscription if its status and period end are still those of current
+func updateSubscription(tx *sql.Tx, sub, current *entity.Subscription, now time.Time) (sql.Result, error) {
+	return tx.Exec(`
+		UPDATE subscriptions SET plan_id = ?, status = ?, current_period_start = ?, current_period_end = ?, canceled_at = ?, updated_at = ?
+		WHERE id = ? AND status = ? AND current_period_end = ?`,
+		sub.PlanID, sub.Status, sub.CurrentPeriodStart, sub.CurrentPeriodEnd, sub.CanceledAt, now,
+		current.ID, current.Status, current.CurrentPeriodEnd.UTC())
+}
+
+// setPremium keeps the premium flag of the user of the subscription in step with its status
+func setPremium(tx *sql.Tx, sub *entity.Subscription, now time.Time) error {
+	_, err := tx.Exec(`UPDATE users SET premium = ?, updated_at = ? WHERE id = ?`, sub.Status.IsPremium(), now, sub.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to update premium of user: %v", err)
+	}
+	return nil
+}
+
+func scanSubscription(row rowScanner) (*entity.Subscription, error) {
+	sub := &entity.Subscription{}
+	var canceledAt sql.NullTime
+	err := row.Scan(&sub.ID, &sub.UserID, &sub.PlanID, &sub.Status, &sub.CurrentPeriodStart, &sub.CurrentPeriodEnd, &canceledAt,
+		&sub.CreatedAt, &sub.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if canceledAt.Valid {
+		sub.CanceledAt = &canceledAt.Time
+	}
+	return sub, nil
+}
@@ -4,11 +4,17 @@ import (
 	"database/sql"
 	"fmt"
 	"mlvt/internal/entity"
+	"time"
 )
 
 // TransactionLogRepo is responsible for logging transaction events to the database
 type TransactionLogRepo interface {
 	LogTransaction(log *entity.TransactionLog) error
+	// LogTransactionOnce logs the event unless one with the same payment method and reference was, reporting
+	// whether it was logged now
+	LogTransactionOnce(log *entity.TransactionLog) (bool, error)
+	HasReference(paymentMethod, reference string) (bool, error)
+	ListTransactionLogs(orderID string) ([]entity.TransactionLog, error) // Oldest first
 }
 
 type transactionLogRepo struct {
@@ -22,13 +28,77 @@ func NewTransactionLogRepo(db *sql.DB) TransactionLogRepo {
 
 // LogTransaction inserts a log entry into the transaction_logs table
 func (r *transactionLogRepo) LogTransaction(log *entity.TransactionLog) error {
-	query := `INSERT INTO transaction_logs (order_id, payment_method, action, status, details)
-              VALUES (?, ?, ?, ?, ?)`
+	if _, err := r.insert(log, ""); err != nil {
+		return fmt.Errorf("error logging transaction: %w", err)
+	}
+	return nil
+}
 
-	_, err := r.db.Exec(query, log.OrderID, log.PaymentMethod, log.Action, log.Status, log.Details)
+// LogTransactionOnce inserts a log entry unless its reference was logged for the payment method
+func (r *transactionLogRepo) LogTransactionOnce(log *entity.TransactionLog) (bool, error) {
+	inserted, err := r.insert(log, ` ON CONFLICT (payment_method, reference) DO NOTHING`)
 	if err != nil {
-		fmt.Errorf("Error logging transaction: %v", err)
-		return err
+		return false, fmt.Errorf("error logging transaction: %w", err)
 	}
-	return nil
+	return inserted, nil
+}
+
+func (r *transactionLogRepo) insert(log *entity.TransactionLog, onConflict string) (bool, error) {
+	if log.CreatedAt.IsZero() {
+		log.CreatedAt = time.Now()
+	}
+	query := `INSERT INTO transaction_logs (order_id, payment_method, action, status, details, reference, created_at)
+              VALUES (?, ?, ?, ?, ?, ?, ?)` + onConflict
+
+	result, err := r.db.Exec(query, log.OrderID, log.PaymentMethod, log.Action, log.Status, log.Details,
+		sql.NullString{String: log.Reference, Valid: log.Reference != ""}, log.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := affected(result)
+	if err != nil || !inserted {
+		return false, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	log.ID = uint64(id)
+	return true, nil
+}
+
+// HasReference reports whether an event with the reference was logged for the payment method
+func (r *transactionLogRepo) HasReference(paymentMethod, reference string) (bool, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM transaction_logs WHERE payment_method = ? AND reference = ?`,
+		paymentMethod, reference).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ListTransactionLogs lists the log entries of the order, oldest first
+func (r *transactionLogRepo) ListTransactionLogs(orderID string) ([]entity.TransactionLog, error) {
+	rows, err := r.db.Query(`SELECT id, order_id, payment_method, action, status, details, reference, created_at
+		FROM transaction_logs WHERE order_id = ? ORDER BY id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []entity.TransactionLog
+	for rows.Next() {
+		var log entity.TransactionLog
+		var details, reference sql.NullString
+		var createdAt sql.NullTime
+		if err := rows.Scan(&log.ID, &log.OrderID, &log.PaymentMethod, &log.Action, &log.Status, &details, &reference, &createdAt); err != nil {
+			return nil, err
+		}
+		log.Details = details.String
+		log.Reference = reference.String
+		log.CreatedAt = createdAt.Time
+		logs = append(logs, log)
+	}
+	return logs, rows.Err()
 }
@@ -2,6 +2,8 @@ package repo
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"mlvt/internal/entity"
 	"time"
 )
@@ -13,9 +15,25 @@ type TranscriptionRepository interface {
 	GetTranscriptionByIDAndVideoID(transcriptionID, videoID uint64) (*entity.Transcription, error)
 	ListTranscriptionsByUserID(userID uint64) ([]entity.Transcription, error)
 	ListTranscriptionsByVideoID(videoID uint64) ([]entity.Transcription, error)
+	ListTranscriptionsByOrgID(orgID uint64) ([]entity.Transcription, error)
 	DeleteTranscription(transcriptionID uint64) error
+	UpdateTranscriptionFileInfo(transcription *entity.Transcription) error
+	ApplySegmentEdit(transcriptionID uint64, edit SegmentEdit, record EditRecorder) error
+	GetTranscriptionRevision(transcriptionID uint64, number int) (*entity.TranscriptionRevision, error)
+	ListTranscriptionRevisions(transcriptionID uint64) ([]entity.TranscriptionRevision, error)
 }
 
+// SegmentEdit is a write to the segments of a transcription. Set one of its fields.
+type SegmentEdit struct {
+	Create  []entity.TranscriptionSegment // Segments to add, whose IDs are set
+	Update  *entity.TranscriptionSegment  // Segment to store the timing, text, speaker and confidence of
+	Restore *entity.TranscriptionRevision // Revision whose segments replace all the current ones, keeping their IDs
+}
+
+// EditRecorder derives the text of a transcription and the revisions recording an edit from the segments before and
+// after it. revisions is the number of revisions the transcription had before the edit.
+type EditRecorder func(before, after []entity.TranscriptionSegment, revisions int) (string, []*entity.TranscriptionRevision)
+
 type transcriptionRepo struct {
 	db *sql.DB
 }
@@ -27,22 +45,34 @@ func NewTranscriptionRepository(db *sql.DB) TranscriptionRepository {
 // CreateTranscription inserts a new transcription into the database
 func (r *transcriptionRepo) CreateTranscription(transcription *entity.Transcription) error {
 	query := `
-		INSERT INTO transcriptions (video_id, user_id, text, lang, folder, file_name, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+		INSERT INTO transcriptions (video_id, user_id, org_id, text, lang, folder, file_name, file_size, etag, content_type, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	if transcription.Status == "" {
+		transcription.Status = entity.FileStatusReady
+	}
 	now := time.Now()
-	_, err := r.db.Exec(query, transcription.VideoID, transcription.UserID, transcription.Text,
-		transcription.Lang, transcription.Folder, transcription.FileName, now, now)
-	return err
+	result, err := r.db.Exec(query, transcription.VideoID, transcription.UserID, transcription.OrgID, transcription.Text,
+		transcription.Lang, transcription.Folder, transcription.FileName,
+		transcription.FileSize, transcription.ETag, transcription.ContentType, transcription.Status, now, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	transcription.ID = uint64(id)
+	return nil
 }
 
+// transcriptionColumns are the columns scanTranscription reads, in order
+const transcriptionColumns = `id, video_id, user_id, org_id, text, lang, folder, file_name, file_size, etag, content_type, status, created_at, updated_at`
+
 // GetTranscriptionByID retrieves a transcription by its ID
 func (r *transcriptionRepo) GetTranscriptionByID(transcriptionID uint64) (*entity.Transcription, error) {
-	query := `SELECT id, video_id, user_id, text, lang, folder, file_name, created_at, updated_at
-	          FROM transcriptions WHERE id = ?`
-	row := r.db.QueryRow(query, transcriptionID)
-	transcription := &entity.Transcription{}
-	err := row.Scan(&transcription.ID, &transcription.VideoID, &transcription.UserID, &transcription.Text,
-		&transcription.Lang, &transcription.Folder, &transcription.FileName, &transcription.CreatedAt, &transcription.UpdatedAt)
+	query := `SELECT ` + transcriptionColumns + ` FROM transcriptions WHERE id = ?`
+	transcription, err := scanTranscription(r.db.QueryRow(query, transcriptionID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -51,12 +81,8 @@ func (r *transcriptionRepo) GetTranscriptionByID(transcriptionID uint64) (*entit
 
 // GetTranscriptionByIDAndUserID retrieves a transcription by its ID and User ID
 func (r *transcriptionRepo) GetTranscriptionByIDAndUserID(transcriptionID, userID uint64) (*entity.Transcription, error) {
-	query := `SELECT id, video_id, user_id, text, lang, folder, file_name, created_at, updated_at
-	          FROM transcriptions WHERE id = ? AND user_id = ?`
-	row := r.db.QueryRow(query, transcriptionID, userID)
-	transcription := &entity.Transcription{}
-	err := row.Scan(&transcription.ID, &transcription.VideoID, &transcription.UserID, &transcription.Text,
-		&transcription.Lang, &transcription.Folder, &transcription.FileName, &transcription.CreatedAt, &transcription.UpdatedAt)
+	query := `SELECT ` + transcriptionColumns + ` FROM transcriptions WHERE id = ? AND user_id = ?`
+	transcription, err := scanTranscription(r.db.QueryRow(query, transcriptionID, userID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -65,12 +91,8 @@ func (r *transcriptionRepo) GetTranscriptionByIDAndUserID(transcriptionID, userI
 
 // GetTranscriptionByIDAndVideoID retrieves a transcription by its ID and Video ID
 func (r *transcriptionRepo) GetTranscriptionByIDAndVideoID(transcriptionID, videoID uint64) (*entity.Transcription, error) {
-	query := `SELECT id, video_id, user_id, text, lang, folder, file_name, created_at, updated_at
-	          FROM transcriptions WHERE id = ? AND video_id = ?`
-	row := r.db.QueryRow(query, transcriptionID, videoID)
-	transcription := &entity.Transcription{}
-	err := row.Scan(&transcription.ID, &transcription.VideoID, &transcription.UserID, &transcription.Text,
-		&transcription.Lang, &transcription.Folder, &transcription.FileName, &transcription.CreatedAt, &transcription.UpdatedAt)
+	query := `SELECT ` + transcriptionColumns + ` FROM transcriptions WHERE id = ? AND video_id = ?`
+	transcription, err := scanTranscription(r.db.QueryRow(query, transcriptionID, videoID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -79,9 +101,21 @@ func (r *transcriptionRepo) GetTranscriptionByIDAndVideoID(transcriptionID, vide
 
 // ListTranscriptionsByUserID lists all transcriptions for a specific user
 func (r *transcriptionRepo) ListTranscriptionsByUserID(userID uint64) ([]entity.Transcription, error) {
-	query := `SELECT id, video_id, user_id, text, lang, folder, file_name, created_at, updated_at
-	          FROM transcriptions WHERE user_id = ?`
-	rows, err := r.db.Query(query, userID)
+	return r.listTranscriptions(`SELECT `+transcriptionColumns+` FROM transcriptions WHERE user_id = ?`, userID)
+}
+
+// ListTranscriptionsByVideoID lists all transcriptions for a specific video
+func (r *transcriptionRepo) ListTranscriptionsByVideoID(videoID uint64) ([]entity.Transcription, error) {
+	return r.listTranscriptions(`SELECT `+transcriptionColumns+` FROM transcriptions WHERE video_id = ?`, videoID)
+}
+
+// ListTranscriptionsByOrgID lists all transcriptions shared with an organization
+func (r *transcriptionRepo) ListTranscriptionsByOrgID(orgID uint64) ([]entity.Transcription, error) {
+	return r.listTranscriptions(`SELECT `+transcriptionColumns+` FROM transcriptions WHERE org_id = ?`, orgID)
+}
+
+func (r *transcriptionRepo) listTranscriptions(query string, args ...interface{}) ([]entity.Transcription, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -89,36 +123,25 @@ func (r *transcriptionRepo) ListTranscriptionsByUserID(userID uint64) ([]entity.
 
 	var transcriptions []entity.Transcription
 	for rows.Next() {
-		var transcription entity.Transcription
-		if err := rows.Scan(&transcription.ID, &transcription.VideoID, &transcription.UserID, &transcription.Text,
-			&transcription.Lang, &transcription.Folder, &transcription.FileName, &transcription.CreatedAt, &transcription.UpdatedAt); err != nil {
+		transcription, err := scanTranscription(rows)
+		if err != nil {
 			return nil, err
 		}
-		transcriptions = append(transcriptions, transcription)
+		transcriptions = append(transcriptions, *transcription)
 	}
-	return transcriptions, nil
+	return transcriptions, rows.Err()
 }
 
-// ListTranscriptionsByVideoID lists all transcriptions for a specific video
-func (r *transcriptionRepo) ListTranscriptionsByVideoID(videoID uint64) ([]entity.Transcription, error) {
-	query := `SELECT id, video_id, user_id, text, lang, folder, file_name, created_at, updated_at
-	          FROM transcriptions WHERE video_id = ?`
-	rows, err := r.db.Query(query, videoID)
+func scanTranscription(row rowScanner) (*entity.Transcription, error) {
+	transcription := &entity.Transcription{}
+	var orgID sql.NullInt64
+	err := row.Scan(&transcription.ID, &transcription.VideoID, &transcription.UserID, &orgID, &transcription.Text, &transcription.Lang, &transcription.Folder,
+		&transcription.FileName, &transcription.FileSize, &transcription.ETag, &transcription.ContentType, &transcription.Status, &transcription.CreatedAt, &transcription.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var transcriptions []entity.Transcription
-	for rows.Next() {
-		var transcription entity.Transcription
-		if err := rows.Scan(&transcription.ID, &transcription.VideoID, &transcription.UserID, &transcription.Text,
-			&transcription.Lang, &transcription.Folder, &transcription.FileName, &transcription.CreatedAt, &transcription.UpdatedAt); err != nil {
-			return nil, err
-		}
-		transcriptions = append(transcriptions, transcription)
-	}
-	return transcriptions, nil
+	transcription.OrgID = nullableID(orgID)
+	return transcription, nil
 }
 
 // DeleteTranscription deletes a transcription by its ID
@@ -127,3 +150,180 @@ func (r *transcriptionRepo) DeleteTranscription(transcriptionID uint64) error {
 	_, err := r.db.Exec(query, transcriptionID)
 	return err
 }
+
+// UpdateTranscriptionFileInfo records the size, ETag and content type of the stored file along with the status
+func (r *transcriptionRepo) UpdateTranscriptionFileInfo(transcription *entity.Transcription) error {
+	query := `
+		UPDATE transcriptions
+		SET file_size = ?, etag = ?, content_type = ?, status = ?, updated_at = ?
+		WHERE id = ?`
+	now := time.Now()
+	result, err := r.db.Exec(query, transcription.FileSize, transcription.ETag, transcription.ContentType, transcription.Status, now, transcription.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update transcription file info: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no transcription found with id %d", transcription.ID)
+	}
+	transcription.UpdatedAt = now
+	return nil
+}
+
+// ApplySegmentEdit writes an edit of the segments of a transcription together with the text and the revisions the
+// recorder derives from it, in a single transaction. The segments before and after the edit are read inside it and
+// the edits of a transcription run one at a time, so every revision holds the changes of its own edit only.
+func (r *transcriptionRepo) ApplySegmentEdit(transcriptionID uint64, edit SegmentEdit, record EditRecorder) error {
+	now := time.Now()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Writing first takes the lock that keeps concurrent edits of the transcription out until this one commits
+	result, err := tx.Exec(`UPDATE transcriptions SET updated_at = ? WHERE id = ?`, now, transcriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to update transcription: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no transcription found with id %d", transcriptionID)
+	}
+
+	before, err := listSegments(tx, transcriptionID)
+	if err != nil {
+		return err
+	}
+	var ids []uint64
+	switch {
+	case edit.Restore != nil:
+		err = replaceSegments(tx, transcriptionID, edit.Restore.Segments, now)
+	case edit.Update != nil:
+		err = updateSegment(tx, edit.Update, now)
+	default:
+		ids, err = insertSegments(tx, edit.Create, now)
+	}
+	if err != nil {
+		return err
+	}
+	after, err := listSegments(tx, transcriptionID)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM transcription_revisions WHERE transcription_id = ?`, transcriptionID).Scan(&count); err != nil {
+		return err
+	}
+	text, revisions := record(before, after, count)
+	if _, err := tx.Exec(`UPDATE transcriptions SET text = ? WHERE id = ?`, text, transcriptionID); err != nil {
+		return fmt.Errorf("failed to update transcription text: %v", err)
+	}
+	for _, revision := range revisions {
+		if err := insertTranscriptionRevision(tx, revision, now); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	setSegmentIDs(edit.Create, ids, now)
+	if edit.Update != nil {
+		edit.Update.UpdatedAt = now
+	}
+	return nil
+}
+
+// insertTranscriptionRevision stores a revision under the next number of its transcription, setting its ID and number
+func insertTranscriptionRevision(q sqlQuerier, revision *entity.TranscriptionRevision, now time.Time) error {
+	changes, err := json.Marshal(revision.Changes)
+	if err != nil {
+		return err
+	}
+	segments, err := json.Marshal(revision.Segments)
+	if err != nil {
+		return err
+	}
+
+	// The number is assigned in the insert itself so concurrent edits cannot take the same one
+	query := `
+		INSERT INTO transcription_revisions (transcription_id, number, user_id, action, restored_from, changes, segments, created_at)
+		SELECT ?, COALESCE(MAX(number), 0) + 1, ?, ?, ?, ?, ?, ?
+		FROM transcription_revisions WHERE transcription_id = ?`
+	result, err := q.Exec(query, revision.TranscriptionID, revision.UserID, revision.Action, revision.RestoredFrom,
+		string(changes), string(segments), now, revision.TranscriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to insert transcription revision: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	if err := q.QueryRow(`SELECT number FROM transcription_revisions WHERE id = ?`, id).Scan(&revision.Number); err != nil {
+		return err
+	}
+	revision.ID = uint64(id)
+	revision.CreatedAt = now
+	return nil
+}
+
+// GetTranscriptionRevision retrieves a revision by its number, including the snapshot of its segments
+func (r *transcriptionRepo) GetTranscriptionRevision(transcriptionID uint64, number int) (*entity.TranscriptionRevision, error) {
+	query := `SELECT id, transcription_id, number, user_id, action, restored_from, changes, segments, created_at
+	          FROM transcription_revisions WHERE transcription_id = ? AND number = ?`
+	revision := &entity.TranscriptionRevision{}
+	var changes, segments string
+	err := r.db.QueryRow(query, transcriptionID, number).Scan(&revision.ID, &revision.TranscriptionID, &revision.Number, &revision.UserID,
+		&revision.Action, &revision.RestoredFrom, &changes, &segments, &revision.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(changes), &revision.Changes); err != nil {
+		return nil, fmt.Errorf("failed to decode revision changes: %v", err)
+	}
+	if err := json.Unmarshal([]byte(segments), &revision.Segments); err != nil {
+		return nil, fmt.Errorf("failed to decode revision segments: %v", err)
+	}
+	return revision, nil
+}
+
+// ListTranscriptionRevisions lists the revisions of a transcription, newest first, without their segment snapshots
+func (r *transcriptionRepo) ListTranscriptionRevisions(transcriptionID uint64) ([]entity.TranscriptionRevision, error) {
+	query := `SELECT id, transcription_id, number, user_id, action, restored_from, changes, created_at
+	          FROM transcription_revisions WHERE transcription_id = ? ORDER BY number DESC`
+	rows, err := r.db.Query(query, transcriptionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []entity.TranscriptionRevision{}
+	for rows.Next() {
+		var revision entity.TranscriptionRevision
+		var changes string
+		if err := rows.Scan(&revision.ID, &revision.TranscriptionID, &revision.Number, &revision.UserID, &revision.Action,
+			&revision.RestoredFrom, &changes, &revision.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(changes), &revision.Changes); err != nil {
+			return nil, fmt.Errorf("failed to decode revision changes: %v", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
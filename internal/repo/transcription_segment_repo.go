@@ -0,0 +1,224 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"mlvt/internal/entity"
+	"time"
+)
+
+type TranscriptionSegmentRepository interface {
+	CreateSegments(segments []entity.TranscriptionSegment) error
+	GetSegmentByID(segmentID uint64) (*entity.TranscriptionSegment, error)
+	ListSegments(transcriptionID uint64) ([]entity.TranscriptionSegment, error)
+	ListSegmentsPage(transcriptionID uint64, limit, offset int) ([]entity.TranscriptionSegment, error)
+	CountSegments(transcriptionID uint64) (int, error)
+	UpdateSegment(segment *entity.TranscriptionSegment) error
+	ReplaceSegments(transcriptionID uint64, segments []entity.TranscriptionSegment) error
+}
+
+type transcriptionSegmentRepo struct {
+	db *sql.DB
+}
+
+func NewTranscriptionSegmentRepo(db *sql.DB) TranscriptionSegmentRepository {
+	return &transcriptionSegmentRepo{db: db}
+}
+
+const transcriptionSegmentColumns = `id, transcription_id, start_ms, end_ms, text, speaker, confidence, created_at, updated_at`
+
+// CreateSegments inserts the segments in a single transaction, setting their IDs
+func (r *transcriptionSegmentRepo) CreateSegments(segments []entity.TranscriptionSegment) error {
+	now := time.Now()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ids, err := insertSegments(tx, segments, now)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	setSegmentIDs(segments, ids, now)
+	return nil
+}
+
+// GetSegmentByID retrieves a single transcription segment
+func (r *transcriptionSegmentRepo) GetSegmentByID(segmentID uint64) (*entity.TranscriptionSegment, error) {
+	row := r.db.QueryRow(`SELECT `+transcriptionSegmentColumns+` FROM transcription_segments WHERE id = ?`, segmentID)
+	segment, err := scanTranscriptionSegment(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return segment, err
+}
+
+// ListSegments lists all segments of a transcription in playback order
+func (r *transcriptionSegmentRepo) ListSegments(transcriptionID uint64) ([]entity.TranscriptionSegment, error) {
+	return listSegments(r.db, transcriptionID)
+}
+
+// ListSegmentsPage lists at most limit segments of a transcription in playback order, skipping the first offset
+func (r *transcriptionSegmentRepo) ListSegmentsPage(transcriptionID uint64, limit, offset int) ([]entity.TranscriptionSegment, error) {
+	return querySegments(r.db, `SELECT `+transcriptionSegmentColumns+` FROM transcription_segments
+		WHERE transcription_id = ? ORDER BY start_ms, id LIMIT ? OFFSET ?`, transcriptionID, limit, offset)
+}
+
+// CountSegments returns the number of segments of a transcription
+func (r *transcriptionSegmentRepo) CountSegments(transcriptionID uint64) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM transcription_segments WHERE transcription_id = ?`, transcriptionID).Scan(&count)
+	return count, err
+}
+
+// UpdateSegment stores the timing, text, speaker and confidence of a segment
+func (r *transcriptionSegmentRepo) UpdateSegment(segment *entity.TranscriptionSegment) error {
+	now := time.Now()
+	if err := updateSegment(r.db, segment, now); err != nil {
+		return err
+	}
+	segment.UpdatedAt = now
+	return nil
+}
+
+// ReplaceSegments swaps all segments of a transcription for the given ones in a single transaction.
+// Segments keep their IDs, so a restored snapshot has the IDs it was taken with.
+func (r *transcriptionSegmentRepo) ReplaceSegments(transcriptionID uint64, segments []entity.TranscriptionSegment) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceSegments(tx, transcriptionID, segments, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqlQuerier is implemented by both *sql.DB and *sql.Tx, so the queries below also run inside transactions
+type sqlQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Prepare(query string) (*sql.Stmt, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insertSegments inserts the segments and returns their IDs, in order
+func insertSegments(q sqlQuerier, segments []entity.TranscriptionSegment, now time.Time) ([]uint64, error) {
+	stmt, err := q.Prepare(`
+		INSERT INTO transcription_segments (transcription_id, start_ms, end_ms, text, speaker, confidence, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	ids := make([]uint64, len(segments))
+	for i, segment := range segments {
+		result, err := stmt.Exec(segment.TranscriptionID, segment.StartMs, segment.EndMs, segment.Text, segment.Speaker,
+			segment.Confidence, now, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert transcription segment %d: %v", i, err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = uint64(id)
+	}
+	return ids, nil
+}
+
+// setSegmentIDs records the IDs and timestamps of inserted segments once they are committed
+func setSegmentIDs(segments []entity.TranscriptionSegment, ids []uint64, now time.Time) {
+	for i := range segments {
+		segments[i].ID = ids[i]
+		segments[i].CreatedAt = now
+		segments[i].UpdatedAt = now
+	}
+}
+
+func updateSegment(q sqlQuerier, segment *entity.TranscriptionSegment, now time.Time) error {
+	query := `
+		UPDATE transcription_segments
+		SET start_ms = ?, end_ms = ?, text = ?, speaker = ?, confidence = ?, updated_at = ?
+		WHERE id = ?`
+	result, err := q.Exec(query, segment.StartMs, segment.EndMs, segment.Text, segment.Speaker, segment.Confidence, now, segment.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update transcription segment: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no transcription segment found with id %d", segment.ID)
+	}
+	return nil
+}
+
+func replaceSegments(q sqlQuerier, transcriptionID uint64, segments []entity.TranscriptionSegment, now time.Time) error {
+	if _, err := q.Exec(`DELETE FROM transcription_segments WHERE transcription_id = ?`, transcriptionID); err != nil {
+		return fmt.Errorf("failed to delete transcription segments: %v", err)
+	}
+
+	stmt, err := q.Prepare(`
+		INSERT INTO transcription_segments (id, transcription_id, start_ms, end_ms, text, speaker, confidence, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, segment := range segments {
+		createdAt := segment.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+		if _, err := stmt.Exec(segment.ID, transcriptionID, segment.StartMs, segment.EndMs, segment.Text, segment.Speaker,
+			segment.Confidence, createdAt, now); err != nil {
+			return fmt.Errorf("failed to insert transcription segment %d: %v", segment.ID, err)
+		}
+	}
+	return nil
+}
+
+func listSegments(q sqlQuerier, transcriptionID uint64) ([]entity.TranscriptionSegment, error) {
+	return querySegments(q, `SELECT `+transcriptionSegmentColumns+` FROM transcription_segments
+		WHERE transcription_id = ? ORDER BY start_ms, id`, transcriptionID)
+}
+
+func querySegments(q sqlQuerier, query string, args ...interface{}) ([]entity.TranscriptionSegment, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	segments := []entity.TranscriptionSegment{}
+	for rows.Next() {
+		segment, err := scanTranscriptionSegment(rows)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, *segment)
+	}
+	return segments, rows.Err()
+}
+
+func scanTranscriptionSegment(row rowScanner) (*entity.TranscriptionSegment, error) {
+	segment := &entity.TranscriptionSegment{}
+	err := row.Scan(&segment.ID, &segment.TranscriptionID, &segment.StartMs, &segment.EndMs, &segment.Text, &segment.Speaker,
+		&segment.Confidence, &segment.CreatedAt, &segment.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return segment, nil
+}
@@ -0,0 +1,134 @@
+package repo
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"mlvt/internal/entity"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTranscriptionSegmentTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	schema, err := os.ReadFile("../../migration/0013_create_transcription_segments_table.up.sql")
+	require.NoError(t, err)
+	_, err = db.Exec(string(schema))
+	require.NoError(t, err)
+	return db
+}
+
+func TestCreateSegments(t *testing.T) {
+	segmentRepo := NewTranscriptionSegmentRepo(setupTranscriptionSegmentTestDB(t))
+
+	segments := []entity.TranscriptionSegment{
+		{TranscriptionID: 4, StartMs: 2500, EndMs: 4000, Text: "world", Speaker: "SPEAKER_2", Confidence: 0.8},
+		{TranscriptionID: 4, StartMs: 0, EndMs: 2500, Text: "hello", Speaker: "SPEAKER_1", Confidence: 0.95},
+		{TranscriptionID: 5, StartMs: 0, EndMs: 1000, Text: "other"},
+	}
+	require.NoError(t, segmentRepo.CreateSegments(segments))
+	assert.Equal(t, uint64(1), segments[0].ID)
+	assert.Equal(t, uint64(3), segments[2].ID)
+	assert.False(t, segments[1].CreatedAt.IsZero())
+
+	saved, err := segmentRepo.ListSegments(4)
+	require.NoError(t, err)
+	require.Len(t, saved, 2)
+	assert.Equal(t, "hello", saved[0].Text, "playback order")
+	assert.Equal(t, "SPEAKER_2", saved[1].Speaker)
+	assert.Equal(t, 0.8, saved[1].Confidence)
+
+	segment, err := segmentRepo.GetSegmentByID(2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2500), segment.EndMs)
+
+	missing, err := segmentRepo.GetSegmentByID(99)
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+
+	empty, err := segmentRepo.ListSegments(99)
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestListSegmentsPage(t *testing.T) {
+	segmentRepo := NewTranscriptionSegmentRepo(setupTranscriptionSegmentTestDB(t))
+
+	var segments []entity.TranscriptionSegment
+	for i := int64(0); i < 5; i++ {
+		segments = append(segments, entity.TranscriptionSegment{TranscriptionID: 4, StartMs: i * 1000, EndMs: i*1000 + 900, Text: "line"})
+	}
+	require.NoError(t, segmentRepo.CreateSegments(segments))
+
+	count, err := segmentRepo.CountSegments(4)
+	require.NoError(t, err)
+	assert.Equal(t, 5, count)
+
+	page, err := segmentRepo.ListSegmentsPage(4, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, int64(2000), page[0].StartMs)
+	assert.Equal(t, int64(3000), page[1].StartMs)
+
+	last, err := segmentRepo.ListSegmentsPage(4, 2, 4)
+	require.NoError(t, err)
+	assert.Len(t, last, 1)
+}
+
+func TestUpdateSegment(t *testing.T) {
+	segmentRepo := NewTranscriptionSegmentRepo(setupTranscriptionSegmentTestDB(t))
+
+	segments := []entity.TranscriptionSegment{{TranscriptionID: 4, StartMs: 0, EndMs: 1000, Text: "helo"}}
+	require.NoError(t, segmentRepo.CreateSegments(segments))
+
+	segment := segments[0]
+	segment.Text = "hello"
+	segment.EndMs = 1200
+	segment.Speaker = "SPEAKER_1"
+	require.NoError(t, segmentRepo.UpdateSegment(&segment))
+
+	saved, err := segmentRepo.GetSegmentByID(segment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", saved.Text)
+	assert.Equal(t, int64(1200), saved.EndMs)
+	assert.Equal(t, "SPEAKER_1", saved.Speaker)
+
+	assert.Error(t, segmentRepo.UpdateSegment(&entity.TranscriptionSegment{ID: 99, EndMs: 1}))
+}
+
+func TestReplaceSegments(t *testing.T) {
+	segmentRepo := NewTranscriptionSegmentRepo(setupTranscriptionSegmentTestDB(t))
+
+	segments := []entity.TranscriptionSegment{
+		{TranscriptionID: 4, StartMs: 0, EndMs: 1000, Text: "hello"},
+		{TranscriptionID: 4, StartMs: 1000, EndMs: 2000, Text: "world"},
+		{TranscriptionID: 5, StartMs: 0, EndMs: 1000, Text: "other"},
+	}
+	require.NoError(t, segmentRepo.CreateSegments(segments))
+
+	snapshot := []entity.TranscriptionSegment{{ID: 2, StartMs: 1000, EndMs: 2500, Text: "there", CreatedAt: segments[1].CreatedAt}}
+	require.NoError(t, segmentRepo.ReplaceSegments(4, snapshot))
+
+	saved, err := segmentRepo.ListSegments(4)
+	require.NoError(t, err)
+	require.Len(t, saved, 1)
+	assert.Equal(t, uint64(2), saved[0].ID, "replaced segments keep their IDs")
+	assert.Equal(t, uint64(4), saved[0].TranscriptionID)
+	assert.Equal(t, "there", saved[0].Text)
+
+	other, err := segmentRepo.ListSegments(5)
+	require.NoError(t, err)
+	assert.Len(t, other, 1, "segments of other transcriptions are left alone")
+
+	require.NoError(t, segmentRepo.ReplaceSegments(4, nil))
+	count, err := segmentRepo.CountSegments(4)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
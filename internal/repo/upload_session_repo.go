@@ -0,0 +1,105 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"mlvt/internal/entity"
+	"time"
+)
+
+type UploadSessionRepository interface {
+	CreateUploadSession(session *entity.UploadSession) error
+	GetUploadSessionByUploadID(uploadID string) (*entity.UploadSession, error)
+	ListUploadSessionsByUserID(userID uint64, status entity.UploadSessionStatus) ([]entity.UploadSession, error)
+	UpdateUploadSessionStatus(uploadID string, status entity.UploadSessionStatus) error
+}
+
+type uploadSessionRepo struct {
+	db *sql.DB
+}
+
+func NewUploadSessionRepo(db *sql.DB) UploadSessionRepository {
+	return &uploadSessionRepo{db: db}
+}
+
+// CreateUploadSession inserts a new upload session record into the database
+func (r *uploadSessionRepo) CreateUploadSession(session *entity.UploadSession) error {
+	if session.Status == "" {
+		session.Status = entity.UploadStatusInProgress
+	}
+	query := `
+		INSERT INTO upload_sessions (upload_id, user_id, folder, file_name, file_type, file_size, part_size, total_parts, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	now := time.Now()
+	result, err := r.db.Exec(query, session.UploadID, session.UserID, session.Folder, session.FileName, session.FileType,
+		session.FileSize, session.PartSize, session.TotalParts, session.Status, now, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	session.ID = uint64(id)
+	session.CreatedAt = now
+	session.UpdatedAt = now
+	return nil
+}
+
+// GetUploadSessionByUploadID retrieves an upload session by the upload ID of the storage backend
+func (r *uploadSessionRepo) GetUploadSessionByUploadID(uploadID string) (*entity.UploadSession, error) {
+	query := `SELECT id, upload_id, user_id, folder, file_name, file_type, file_size, part_size, total_parts, status, created_at, updated_at
+	          FROM upload_sessions WHERE upload_id = ?`
+	row := r.db.QueryRow(query, uploadID)
+	session := &entity.UploadSession{}
+	err := row.Scan(&session.ID, &session.UploadID, &session.UserID, &session.Folder, &session.FileName, &session.FileType,
+		&session.FileSize, &session.PartSize, &session.TotalParts, &session.Status, &session.CreatedAt, &session.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return session, err
+}
+
+// ListUploadSessionsByUserID lists the upload sessions of a user in the given status, newest first
+func (r *uploadSessionRepo) ListUploadSessionsByUserID(userID uint64, status entity.UploadSessionStatus) ([]entity.UploadSession, error) {
+	query := `SELECT id, upload_id, user_id, folder, file_name, file_type, file_size, part_size, total_parts, status, created_at, updated_at
+	          FROM upload_sessions WHERE user_id = ? AND status = ? ORDER BY created_at DESC, id DESC`
+	rows, err := r.db.Query(query, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []entity.UploadSession{}
+	for rows.Next() {
+		var session entity.UploadSession
+		if err := rows.Scan(&session.ID, &session.UploadID, &session.UserID, &session.Folder, &session.FileName, &session.FileType,
+			&session.FileSize, &session.PartSize, &session.TotalParts, &session.Status, &session.CreatedAt, &session.UpdatedAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// UpdateUploadSessionStatus updates only the status of an upload session
+func (r *uploadSessionRepo) UpdateUploadSessionStatus(uploadID string, status entity.UploadSessionStatus) error {
+	query := `
+		UPDATE upload_sessions
+		SET status = ?, updated_at = ?
+		WHERE upload_id = ?`
+	result, err := r.db.Exec(query, status, time.Now(), uploadID)
+	if err != nil {
+		return fmt.Errorf("failed to update upload session status: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no upload session found with upload id %s", uploadID)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package repo
+
+import (
+	"mlvt/internal/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUploadSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockUploadSessionRepository) CreateUploadSession(session *entity.UploadSession) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *MockUploadSessionRepository) GetUploadSessionByUploadID(uploadID string) (*entity.UploadSession, error) {
+	args := m.Called(uploadID)
+	session, _ := args.Get(0).(*entity.UploadSession)
+	return session, args.Error(1)
+}
+
+func (m *MockUploadSessionRepository) ListUploadSessionsByUserID(userID uint64, status entity.UploadSessionStatus) ([]entity.UploadSession, error) {
+	args := m.Called(userID, status)
+	sessions, _ := args.Get(0).([]entity.UploadSession)
+	return sessions, args.Error(1)
+}
+
+func (m *MockUploadSessionRepository) UpdateUploadSessionStatus(uploadID string, status entity.UploadSessionStatus) error {
+	args := m.Called(uploadID, status)
+	return args.Error(0)
+}
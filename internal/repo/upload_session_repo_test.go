@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"regexp"
+	"testing"
+
+	"mlvt/internal/entity"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateUploadSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUploadSessionRepo(db)
+	session := &entity.UploadSession{
+		UploadID:   "upload-1",
+		UserID:     1,
+		Folder:     "videos",
+		FileName:   "big.mp4",
+		FileType:   "video/mp4",
+		FileSize:   6 << 30,
+		PartSize:   64 << 20,
+		TotalParts: 96,
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO upload_sessions")).
+		WithArgs("upload-1", uint64(1), "videos", "big.mp4", "video/mp4", int64(6<<30), int64(64<<20), 96,
+			entity.UploadStatusInProgress, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+
+	err = repo.CreateUploadSession(session)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), session.ID)
+	assert.Equal(t, entity.UploadStatusInProgress, session.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateUploadSessionStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUploadSessionRepo(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE upload_sessions")).
+		WithArgs(entity.UploadStatusCompleted, sqlmock.AnyArg(), "upload-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE upload_sessions")).
+		WithArgs(entity.UploadStatusAborted, sqlmock.AnyArg(), "missing").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.UpdateUploadSessionStatus("upload-1", entity.UploadStatusCompleted))
+	assert.EqualError(t, repo.UpdateUploadSessionStatus("missing", entity.UploadStatusAborted), "no upload session found with upload id missing")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
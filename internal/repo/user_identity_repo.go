@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"mlvt/internal/entity"
+	"time"
+)
+
+// UserIdentityRepository stores the links between users and their accounts at OpenID Connect providers
+type UserIdentityRepository interface {
+	CreateUserIdentity(identity *entity.UserIdentity) error
+	GetUserIdentity(provider, subject string) (*entity.UserIdentity, error)
+	TouchUserIdentity(identityID uint64, loginAt time.Time) error
+}
+
+type userIdentityRepo struct {
+	db *sql.DB
+}
+
+func NewUserIdentityRepo(db *sql.DB) UserIdentityRepository {
+	return &userIdentityRepo{db: db}
+}
+
+// CreateUserIdentity inserts a link, setting its ID and creation time. A provider account links to one user only.
+func (r *userIdentityRepo) CreateUserIdentity(identity *entity.UserIdentity) error {
+	identity.CreatedAt = time.Now()
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at, last_login_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, identity.UserID, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt, identity.LastLoginAt)
+	if err != nil {
+		return fmt.Errorf("failed to create user identity: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	identity.ID = uint64(id)
+	return nil
+}
+
+// GetUserIdentity retrieves the link of the provider account
+func (r *userIdentityRepo) GetUserIdentity(provider, subject string) (*entity.UserIdentity, error) {
+	query := `SELECT id, user_id, provider, subject, email, created_at, last_login_at FROM user_identities WHERE provider = ? AND subject = ?`
+
+	identity := &entity.UserIdentity{}
+	var lastLoginAt sql.NullTime
+	err := r.db.QueryRow(query, provider, subject).Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt, &lastLoginAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lastLoginAt.Valid {
+		identity.LastLoginAt = &lastLoginAt.Time
+	}
+	return identity, nil
+}
+
+// TouchUserIdentity records when the user last logged in through the provider
+func (r *userIdentityRepo) TouchUserIdentity(identityID uint64, loginAt time.Time) error {
+	if _, err := r.db.Exec(`UPDATE user_identities SET last_login_at = ? WHERE id = ?`, loginAt, identityID); err != nil {
+		return fmt.Errorf("failed to update user identity: %v", err)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"mlvt/internal/entity"
+	"strings"
 	"time"
 )
 
@@ -12,11 +13,22 @@ type UserRepository interface {
 	GetUserByEmail(email string) (*entity.User, error)
 	GetUserByID(userID uint64) (*entity.User, error)
 	UpdateUser(user *entity.User) error
-	SoftDeleteUser(userID uint64) error
+	SoftDeleteUser(userID uint64) error // Also revokes every token of the user
 	DeleteUser(userID uint64) error
 	GetAllUsers() ([]entity.User, error)
-	UpdateUserPassword(userID uint64, hashedPassword string) error
+	UpdateUserPassword(userID uint64, hashedPassword string) error // Also revokes every token of the user
 	UpdateUserAvatar(userID uint64, avatarPath, avatarFolder string) error
+	UpdateUserRole(userID uint64, role string) error
+	UpdateUserStatus(userID uint64, status int) error // Also revokes every token of the user
+	SetPasswordResetRequired(userID uint64, required bool) error
+	IncrementTokenVersion(userID uint64) error // Revokes every token of the user
+	MarkEmailVerified(userID uint64) error
+	SetTOTPSecret(userID uint64, secret string) error // Stores a pending secret, replacing an unconfirmed one
+	EnableTOTP(userID uint64) error
+	DisableTOTP(userID uint64) error                     // Also clears the secret
+	UseTOTPStep(userID uint64, step int64) (bool, error) // Reports whether the period was not used before
+	ListUsers(filter entity.UserFilter, limit, offset int) ([]entity.User, error)
+	CountUsers(filter entity.UserFilter) (int, error)
 	GetUsersByEmailSuffix(suffix string) ([]entity.User, error)
 }
 
@@ -28,25 +40,40 @@ func NewUserRepo(db *sql.DB) UserRepository {
 	return &userRepo{db: db}
 }
 
-// CreateUser inserts a new user into the database
+const userColumns = `id, first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at, password_reset_required, token_version, email_verified, totp_secret, totp_enabled, totp_last_step`
+
+func scanUser(row rowScanner, user *entity.User) error {
+	return row.Scan(&user.ID, &user.FirstName, &user.LastName, &user.UserName, &user.Email, &user.Password,
+		&user.Status, &user.Premium, &user.Role, &user.Avatar, &user.AvatarFolder, &user.CreatedAt, &user.UpdatedAt,
+		&user.PasswordResetRequired, &user.TokenVersion, &user.EmailVerified, &user.TOTPSecret, &user.TOTPEnabled, &user.TOTPLastStep)
+}
+
+// CreateUser inserts a new user into the database, setting its ID
 func (r *userRepo) CreateUser(user *entity.User) error {
 	query := `
-		INSERT INTO users (first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := r.db.Exec(query, user.FirstName, user.LastName, user.UserName, user.Email, user.Password, user.Status,
-		user.Premium, user.Role, user.Avatar, user.AvatarFolder, user.CreatedAt, user.UpdatedAt)
-	return err
+		INSERT INTO users (first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at, email_verified)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, user.FirstName, user.LastName, user.UserName, user.Email, user.Password, user.Status,
+		user.Premium, user.Role, user.Avatar, user.AvatarFolder, user.CreatedAt, user.UpdatedAt, user.EmailVerified)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	user.ID = uint64(id)
+	return nil
 }
 
 // GetUserByEmail retrieves a user by their email address
 func (r *userRepo) GetUserByEmail(email string) (*entity.User, error) {
-	query := `SELECT id, first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at
-	          FROM users WHERE email = ?`
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = ?`
 	row := r.db.QueryRow(query, email)
 
 	user := &entity.User{}
-	err := row.Scan(&user.ID, &user.FirstName, &user.LastName, &user.UserName, &user.Email, &user.Password,
-		&user.Status, &user.Premium, &user.Role, &user.Avatar, &user.AvatarFolder, &user.CreatedAt, &user.UpdatedAt)
+	err := scanUser(row, user)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -55,32 +82,30 @@ func (r *userRepo) GetUserByEmail(email string) (*entity.User, error) {
 
 // GetUserByID retrieves a user by their ID
 func (r *userRepo) GetUserByID(userID uint64) (*entity.User, error) {
-	query := `SELECT id, first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at
-	          FROM users WHERE id = ?`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
 	row := r.db.QueryRow(query, userID)
 
 	user := &entity.User{}
-	err := row.Scan(&user.ID, &user.FirstName, &user.LastName, &user.UserName, &user.Email, &user.Password,
-		&user.Status, &user.Premium, &user.Role, &user.Avatar, &user.AvatarFolder, &user.CreatedAt, &user.UpdatedAt)
+	err := scanUser(row, user)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	return user, err
 }
 
-// UpdateUser updates user information
+// UpdateUser updates user information, including whether the email address is verified
 func (r *userRepo) UpdateUser(user *entity.User) error {
 	query := `
 		UPDATE users
-		SET first_name = ?, last_name = ?, username = ?, email = ?, status = ?, premium = ?, role = ?, updated_at = ?
+		SET first_name = ?, last_name = ?, username = ?, email = ?, email_verified = ?, status = ?, premium = ?, role = ?, updated_at = ?
 		WHERE id = ?`
-	_, err := r.db.Exec(query, user.FirstName, user.LastName, user.UserName, user.Email, user.Status, user.Premium, user.Role, user.UpdatedAt, user.ID)
+	_, err := r.db.Exec(query, user.FirstName, user.LastName, user.UserName, user.Email, user.EmailVerified, user.Status, user.Premium, user.Role, user.UpdatedAt, user.ID)
 	return err
 }
 
 // SoftDeleteUser performs a soft delete by updating the status of a user to "deleted"
 func (r *userRepo) SoftDeleteUser(userID uint64) error {
-	query := `UPDATE users SET status = ? WHERE id = ?`
+	query := `UPDATE users SET status = ?, token_version = token_version + 1 WHERE id = ?`
 	_, err := r.db.Exec(query, entity.UserStatusDeleted, userID)
 	return err
 }
@@ -94,9 +119,10 @@ func (r *userRepo) DeleteUser(userID uint64) error {
 	return nil
 }
 
-// UpdateUserPassword updates the hashed password for a user
+// UpdateUserPassword updates the hashed password for a user, which clears a forced password reset
+// and revokes the tokens issued with the old password
 func (r *userRepo) UpdateUserPassword(userID uint64, hashedPassword string) error {
-	query := `UPDATE users SET password = ?, updated_at = ? WHERE id = ?`
+	query := `UPDATE users SET password = ?, password_reset_required = FALSE, token_version = token_version + 1, updated_at = ? WHERE id = ?`
 	_, err := r.db.Exec(query, hashedPassword, time.Now(), userID)
 	return err
 }
@@ -108,33 +134,156 @@ func (r *userRepo) UpdateUserAvatar(userID uint64, avatarPath, avatarFolder stri
 	return err
 }
 
-// GetAllUsers retrieves all users
-func (r *userRepo) GetAllUsers() ([]entity.User, error) {
-	query := `SELECT id, first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at
-	          FROM users`
-	rows, err := r.db.Query(query)
+// UpdateUserRole sets the role of a user
+func (r *userRepo) UpdateUserRole(userID uint64, role string) error {
+	query := `UPDATE users SET role = ?, updated_at = ? WHERE id = ?`
+	result, err := r.db.Exec(query, role, time.Now(), userID)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to update user role: %v", err)
 	}
-	defer rows.Close()
 
-	var users []entity.User
-	for rows.Next() {
-		var user entity.User
-		err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.UserName, &user.Email, &user.Password,
-			&user.Status, &user.Premium, &user.Role, &user.Avatar, &user.AvatarFolder, &user.CreatedAt, &user.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		users = append(users, user)
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
 	}
-	return users, nil
+	if rowsAffected == 0 {
+		return fmt.Errorf("no user found with id %d", userID)
+	}
+	return nil
 }
 
-func (r *userRepo) GetUsersByEmailSuffix(suffix string) ([]entity.User, error) {
-	query := `SELECT id, first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at FROM users WHERE email LIKE ?` // AND deleted_at IS NULL`
-	likePattern := "%" + suffix
-	rows, err := r.db.Query(query, likePattern)
+// UpdateUserStatus sets the status of a user and revokes their tokens, so a suspension takes effect at once
+func (r *userRepo) UpdateUserStatus(userID uint64, status int) error {
+	return r.updateUser(userID, `UPDATE users SET status = ?, token_version = token_version + 1, updated_at = ? WHERE id = ?`, status)
+}
+
+// SetPasswordResetRequired sets whether the user must change their password before using the API again
+func (r *userRepo) SetPasswordResetRequired(userID uint64, required bool) error {
+	return r.updateUser(userID, `UPDATE users SET password_reset_required = ?, updated_at = ? WHERE id = ?`, required)
+}
+
+// MarkEmailVerified records that the user proved they own their email address
+func (r *userRepo) MarkEmailVerified(userID uint64) error {
+	return r.updateUser(userID, `UPDATE users SET email_verified = ?, updated_at = ? WHERE id = ?`, true)
+}
+
+// SetTOTPSecret stores a TOTP secret awaiting confirmation. It fails once two-factor authentication is enabled,
+// so an enabled secret is never replaced.
+func (r *userRepo) SetTOTPSecret(userID uint64, secret string) error {
+	return r.updateUser(userID, `UPDATE users SET totp_secret = ?, totp_last_step = 0, updated_at = ? WHERE id = ? AND totp_enabled = FALSE`, secret)
+}
+
+// EnableTOTP turns on two-factor authentication with the pending secret
+func (r *userRepo) EnableTOTP(userID uint64) error {
+	return r.updateUser(userID, `UPDATE users SET totp_enabled = ?, updated_at = ? WHERE id = ? AND totp_secret != ''`, true)
+}
+
+// DisableTOTP turns off two-factor authentication and forgets the secret
+func (r *userRepo) DisableTOTP(userID uint64) error {
+	return r.updateUser(userID, `UPDATE users SET totp_enabled = ?, totp_secret = '', totp_last_step = 0, updated_at = ? WHERE id = ?`, false)
+}
+
+// UseTOTPStep records that a code of the period was accepted. Of two concurrent calls for the same period
+// only one reports it was unused, so a code cannot be used twice.
+func (r *userRepo) UseTOTPStep(userID uint64, step int64) (bool, error) {
+	result, err := r.db.Exec(`UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`, step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to use TOTP step: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// IncrementTokenVersion revokes every access and refresh token issued to the user so far
+func (r *userRepo) IncrementTokenVersion(userID uint64) error {
+	result, err := r.db.Exec(`UPDATE users SET token_version = token_version + 1 WHERE id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no user found with id %d", userID)
+	}
+	return nil
+}
+
+// updateUser runs an update of a single column of a user, failing when the user does not exist
+func (r *userRepo) updateUser(userID uint64, query string, value interface{}) error {
+	result, err := r.db.Exec(query, value, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no user found with id %d", userID)
+	}
+	return nil
+}
+
+// GetAllUsers retrieves all users
+func (r *userRepo) GetAllUsers() ([]entity.User, error) {
+	return r.queryUsers(`SELECT ` + userColumns + ` FROM users`)
+}
+
+// ListUsers lists at most limit users matching the filter, oldest first, skipping the first offset
+func (r *userRepo) ListUsers(filter entity.UserFilter, limit, offset int) ([]entity.User, error) {
+	where, args := userFilterClause(filter)
+	args = append(args, limit, offset)
+	return r.queryUsers(`SELECT `+userColumns+` FROM users`+where+` ORDER BY id LIMIT ? OFFSET ?`, args...)
+}
+
+// CountUsers returns the number of users matching the filter
+func (r *userRepo) CountUsers(filter entity.UserFilter) (int, error) {
+	where, args := userFilterClause(filter)
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM users`+where, args...).Scan(&count)
+	return count, err
+}
+
+// userFilterClause builds the WHERE clause and its arguments for the conditions set in the filter
+func userFilterClause(filter entity.UserFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if filter.Status != 0 {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.Role != "" {
+		conditions = append(conditions, "role = ?")
+		args = append(args, filter.Role)
+	}
+	if filter.Premium != nil {
+		conditions = append(conditions, "premium = ?")
+		args = append(args, *filter.Premium)
+	}
+	if filter.CreatedAfter != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, *filter.CreatedBefore)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (r *userRepo) queryUsers(query string, args ...interface{}) ([]entity.User, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -143,11 +292,16 @@ func (r *userRepo) GetUsersByEmailSuffix(suffix string) ([]entity.User, error) {
 	var users []entity.User
 	for rows.Next() {
 		var user entity.User
-		if err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.UserName, &user.Email, &user.Password, &user.Status, &user.Premium, &user.Role, &user.Avatar, &user.AvatarFolder, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		if err := scanUser(rows, &user); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
 	}
+	return users, rows.Err()
+}
 
-	return users, nil
+func (r *userRepo) GetUsersByEmailSuffix(suffix string) ([]entity.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE email LIKE ?` // AND deleted_at IS NULL`
+	likePattern := "%" + suffix
+	return r.queryUsers(query, likePattern)
 }
@@ -37,6 +37,11 @@ func (m *MockUserRepository) UpdateUser(user *entity.User) error {
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) SoftDeleteUser(userID uint64) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
 func (m *MockUserRepository) DeleteUser(userID uint64) error {
 	args := m.Called(userID)
 	return args.Error(0)
@@ -59,3 +64,69 @@ func (m *MockUserRepository) UpdateUserAvatar(userID uint64, avatarPath, avatarF
 	args := m.Called(userID, avatarPath, avatarFolder)
 	return args.Error(0)
 }
+
+func (m *MockUserRepository) UpdateUserRole(userID uint64, role string) error {
+	args := m.Called(userID, role)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdateUserStatus(userID uint64, status int) error {
+	args := m.Called(userID, status)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) SetPasswordResetRequired(userID uint64, required bool) error {
+	args := m.Called(userID, required)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) IncrementTokenVersion(userID uint64) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) MarkEmailVerified(userID uint64) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) SetTOTPSecret(userID uint64, secret string) error {
+	args := m.Called(userID, secret)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) EnableTOTP(userID uint64) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) DisableTOTP(userID uint64) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UseTOTPStep(userID uint64, step int64) (bool, error) {
+	args := m.Called(userID, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) ListUsers(filter entity.UserFilter, limit, offset int) ([]entity.User, error) {
+	args := m.Called(filter, limit, offset)
+	if users, ok := args.Get(0).([]entity.User); ok {
+		return users, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockUserRepository) CountUsers(filter entity.UserFilter) (int, error) {
+	args := m.Called(filter)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUsersByEmailSuffix(suffix string) ([]entity.User, error) {
+	args := m.Called(suffix)
+	if users, ok := args.Get(0).([]entity.User); ok {
+		return users, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
@@ -35,14 +35,15 @@ func TestCreateUser(t *testing.T) {
 
 	// Expect the INSERT query
 	mock.ExpectExec(regexp.QuoteMeta(`
-		INSERT INTO users (first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)).
+		INSERT INTO users (first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at, email_verified)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)).
 		WithArgs(user.FirstName, user.LastName, user.UserName, user.Email, user.Password, user.Status,
-			user.Premium, user.Role, user.Avatar, user.AvatarFolder, user.CreatedAt, user.UpdatedAt).
+			user.Premium, user.Role, user.Avatar, user.AvatarFolder, user.CreatedAt, user.UpdatedAt, user.EmailVerified).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err = repo.CreateUser(user)
 	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), user.ID)
 
 	// Ensure all expectations were met
 	err = mock.ExpectationsWereMet()
@@ -59,16 +60,14 @@ func TestGetUserByEmail(t *testing.T) {
 
 	rows := sqlmock.NewRows([]string{
 		"id", "first_name", "last_name", "username", "email", "password",
-		"status", "premium", "role", "avatar", "avatar_folder", "created_at", "updated_at",
+		"status", "premium", "role", "avatar", "avatar_folder", "created_at", "updated_at", "password_reset_required", "token_version", "email_verified", "totp_secret", "totp_enabled", "totp_last_step",
 	}).AddRow(
 		1, "John", "Doe", "johndoe", email, "hashedpassword",
 		entity.UserStatusAvailable, false, "user", "avatar.jpg", "avatars",
-		time.Now(), time.Now(),
+		time.Now(), time.Now(), false, 0, true, "", false, 0,
 	)
 
-	mock.ExpectQuery(regexp.QuoteMeta(`
-		SELECT id, first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at
-		          FROM users WHERE email = ?`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at, password_reset_required, token_version, email_verified, totp_secret, totp_enabled, totp_last_step FROM users WHERE email = ?`)).
 		WithArgs(email).
 		WillReturnRows(rows)
 
@@ -91,16 +90,14 @@ func TestGetUserByID(t *testing.T) {
 
 	rows := sqlmock.NewRows([]string{
 		"id", "first_name", "last_name", "username", "email", "password",
-		"status", "premium", "role", "avatar", "avatar_folder", "created_at", "updated_at",
+		"status", "premium", "role", "avatar", "avatar_folder", "created_at", "updated_at", "password_reset_required", "token_version", "email_verified", "totp_secret", "totp_enabled", "totp_last_step",
 	}).AddRow(
 		userID, "John", "Doe", "johndoe", "john@example.com", "hashedpassword",
 		entity.UserStatusAvailable, false, "user", "avatar.jpg", "avatars",
-		time.Now(), time.Now(),
+		time.Now(), time.Now(), false, 0, true, "", false, 0,
 	)
 
-	mock.ExpectQuery(regexp.QuoteMeta(`
-		SELECT id, first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at
-		          FROM users WHERE id = ?`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at, password_reset_required, token_version, email_verified, totp_secret, totp_enabled, totp_last_step FROM users WHERE id = ?`)).
 		WithArgs(userID).
 		WillReturnRows(rows)
 
@@ -134,9 +131,9 @@ func TestUpdateUser(t *testing.T) {
 
 	mock.ExpectExec(regexp.QuoteMeta(`
 		UPDATE users
-		SET first_name = ?, last_name = ?, username = ?, email = ?, status = ?, premium = ?, role = ?, updated_at = ?
+		SET first_name = ?, last_name = ?, username = ?, email = ?, email_verified = ?, status = ?, premium = ?, role = ?, updated_at = ?
 		WHERE id = ?`)).
-		WithArgs(user.FirstName, user.LastName, user.UserName, user.Email, user.Status, user.Premium, user.Role, user.UpdatedAt, user.ID).
+		WithArgs(user.FirstName, user.LastName, user.UserName, user.Email, user.EmailVerified, user.Status, user.Premium, user.Role, user.UpdatedAt, user.ID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	err = repo.UpdateUser(user)
@@ -174,7 +171,7 @@ func TestUpdateUserPassword(t *testing.T) {
 	userID := uint64(1)
 	hashedPassword := "newhashedpassword"
 
-	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET password = ?, updated_at = ? WHERE id = ?`)).
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE users SET password = ?, password_reset_required = FALSE, token_version = token_version + 1, updated_at = ? WHERE id = ?`)).
 		WithArgs(hashedPassword, sqlmock.AnyArg(), userID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
@@ -215,22 +212,20 @@ func TestGetAllUsers(t *testing.T) {
 
 	rows := sqlmock.NewRows([]string{
 		"id", "first_name", "last_name", "username", "email", "password",
-		"status", "premium", "role", "avatar", "avatar_folder", "created_at", "updated_at",
+		"status", "premium", "role", "avatar", "avatar_folder", "created_at", "updated_at", "password_reset_required", "token_version", "email_verified", "totp_secret", "totp_enabled", "totp_last_step",
 	}).
 		AddRow(
 			1, "John", "Doe", "johndoe", "john@example.com", "hashedpassword",
 			entity.UserStatusAvailable, false, "user", "avatar.jpg", "avatars",
-			time.Now(), time.Now(),
+			time.Now(), time.Now(), false, 0, true, "", false, 0,
 		).
 		AddRow(
 			2, "Jane", "Smith", "janesmith", "jane@example.com", "hashedpassword2",
 			entity.UserStatusAvailable, true, "admin", "avatar2.jpg", "avatars",
-			time.Now(), time.Now(),
+			time.Now(), time.Now(), false, 0, true, "", false, 0,
 		)
 
-	mock.ExpectQuery(regexp.QuoteMeta(`
-		SELECT id, first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at
-		          FROM users`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, first_name, last_name, username, email, password, status, premium, role, avatar, avatar_folder, created_at, updated_at, password_reset_required, token_version, email_verified, totp_secret, totp_enabled, totp_last_step FROM users`)).
 		WillReturnRows(rows)
 
 	users, err := repo.GetAllUsers()
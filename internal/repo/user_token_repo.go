@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"database/sql"
+	"fmt"
+	"mlvt/internal/entity"
+	"time"
+)
+
+// UserTokenRepository stores the hashes of the email verification and password reset tokens mailed to users
+type UserTokenRepository interface {
+	CreateUserToken(token *entity.UserToken) error
+	GetUserTokenByHash(purpose entity.UserTokenPurpose, tokenHash string) (*entity.UserToken, error)
+	UseUserToken(tokenID uint64) (bool, error)                          // Reports whether the token was still unused
+	UseUserTokens(userID uint64, purpose entity.UserTokenPurpose) error // Uses up every outstanding token of the user
+}
+
+type userTokenRepo struct {
+	db *sql.DB
+}
+
+func NewUserTokenRepo(db *sql.DB) UserTokenRepository {
+	return &userTokenRepo{db: db}
+}
+
+// CreateUserToken inserts a user token, setting its ID and creation time
+func (r *userTokenRepo) CreateUserToken(token *entity.UserToken) error {
+	token.CreatedAt = time.Now()
+	query := `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create user token: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	token.ID = uint64(id)
+	return nil
+}
+
+// GetUserTokenByHash retrieves a token issued for the purpose by the hash of its value, used or not
+func (r *userTokenRepo) GetUserTokenByHash(purpose entity.UserTokenPurpose, tokenHash string) (*entity.UserToken, error) {
+	query := `SELECT id, user_id, purpose, token_hash, expires_at, used_at, created_at FROM user_tokens WHERE purpose = ? AND token_hash = ?`
+
+	token := &entity.UserToken{}
+	var usedAt sql.NullTime
+	err := r.db.QueryRow(query, purpose, tokenHash).Scan(&token.ID, &token.UserID, &token.Purpose, &token.TokenHash, &token.ExpiresAt, &usedAt, &token.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	return token, nil
+}
+
+// UseUserToken marks an unused token as used. Of two concurrent calls for the same token only one
+// reports it was unused, so a token cannot be used twice.
+func (r *userTokenRepo) UseUserToken(tokenID uint64) (bool, error) {
+	result, err := r.db.Exec(`UPDATE user_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`, time.Now(), tokenID)
+	if err != nil {
+		return false, fmt.Errorf("failed to use user token: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// UseUserTokens marks every unused token of the user for the purpose as used, so only a newer one works
+func (r *userTokenRepo) UseUserTokens(userID uint64, purpose entity.UserTokenPurpose) error {
+	_, err := r.db.Exec(`UPDATE user_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL`, time.Now(), userID, purpose)
+	if err != nil {
+		return fmt.Errorf("failed to use user tokens: %v", err)
+	}
+	return nil
+}
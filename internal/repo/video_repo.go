@@ -11,10 +11,13 @@ type VideoRepository interface {
 	CreateVideo(video *entity.Video) error
 	GetVideoByID(videoID uint64) (*entity.Video, error)
 	ListVideosByUserID(userID uint64) ([]entity.Video, error)
+	CountVideosByUserID(userID uint64) (int, error)
+	ListVideosByOrgID(orgID uint64) ([]entity.Video, error)
 	DeleteVideo(videoID uint64) error
 	UpdateVideo(video *entity.Video) error
 	GetVideoStatus(videoID uint64) (entity.VideoStatus, error)
 	UpdateVideoStatus(videoId uint64, status entity.VideoStatus) error
+	UpdateVideoFileInfo(video *entity.Video) error
 }
 
 type videoRepo struct {
@@ -31,20 +34,30 @@ func (r *videoRepo) CreateVideo(video *entity.Video) error {
 		video.Status = entity.StatusRaw
 	}
 	query := `
-		INSERT INTO videos (title, duration, description, file_name, folder, image, status, user_id, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		INSERT INTO videos (title, duration, description, file_name, folder, image, status, user_id, org_id, file_size, etag, content_type, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	now := time.Now()
-	_, err := r.db.Exec(query, video.Title, video.Duration, video.Description, video.FileName, video.Folder, video.Image, video.Status, video.UserID, now, now)
-	return err
+	result, err := r.db.Exec(query, video.Title, video.Duration, video.Description, video.FileName, video.Folder, video.Image, video.Status, video.UserID, video.OrgID,
+		video.FileSize, video.ETag, video.ContentType, now, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	video.ID = uint64(id)
+	return nil
 }
 
+// videoColumns are the columns scanVideo reads, in order
+const videoColumns = `id, title, duration, description, file_name, folder, image, status, user_id, org_id, file_size, etag, content_type, created_at, updated_at`
+
 // GetVideoByID retrieves a video record by its ID
 func (r *videoRepo) GetVideoByID(videoID uint64) (*entity.Video, error) {
-	query := `SELECT id, title, duration, description, file_name, folder, image, status, user_id, created_at, updated_at
-	          FROM videos WHERE id = ?`
-	row := r.db.QueryRow(query, videoID)
-	video := &entity.Video{}
-	err := row.Scan(&video.ID, &video.Title, &video.Duration, &video.Description, &video.FileName, &video.Folder, &video.Image, &video.Status, &video.UserID, &video.CreatedAt, &video.UpdatedAt)
+	query := `SELECT ` + videoColumns + ` FROM videos WHERE id = ?`
+	video, err := scanVideo(r.db.QueryRow(query, videoID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -53,9 +66,25 @@ func (r *videoRepo) GetVideoByID(videoID uint64) (*entity.Video, error) {
 
 // ListVideosByUserID lists all videos uploaded by a specific user
 func (r *videoRepo) ListVideosByUserID(userID uint64) ([]entity.Video, error) {
-	query := `SELECT id, title, duration, description, file_name, folder, image, status, user_id, created_at, updated_at
-	          FROM videos WHERE user_id = ?`
-	rows, err := r.db.Query(query, userID)
+	return r.listVideos(`SELECT `+videoColumns+` FROM videos WHERE user_id = ?`, userID)
+}
+
+// CountVideosByUserID counts the videos uploaded by a specific user
+func (r *videoRepo) CountVideosByUserID(userID uint64) (int, error) {
+	var count int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM videos WHERE user_id = ?`, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count videos: %v", err)
+	}
+	return count, nil
+}
+
+// ListVideosByOrgID lists all videos shared with an organization
+func (r *videoRepo) ListVideosByOrgID(orgID uint64) ([]entity.Video, error) {
+	return r.listVideos(`SELECT `+videoColumns+` FROM videos WHERE org_id = ?`, orgID)
+}
+
+func (r *videoRepo) listVideos(query string, args ...interface{}) ([]entity.Video, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -63,13 +92,34 @@ func (r *videoRepo) ListVideosByUserID(userID uint64) ([]entity.Video, error) {
 
 	var videos []entity.Video
 	for rows.Next() {
-		var video entity.Video
-		if err := rows.Scan(&video.ID, &video.Title, &video.Duration, &video.Description, &video.FileName, &video.Folder, &video.Image, &video.Status, &video.UserID, &video.CreatedAt, &video.UpdatedAt); err != nil {
+		video, err := scanVideo(rows)
+		if err != nil {
 			return nil, err
 		}
-		videos = append(videos, video)
+		videos = append(videos, *video)
+	}
+	return videos, rows.Err()
+}
+
+func scanVideo(row rowScanner) (*entity.Video, error) {
+	video := &entity.Video{}
+	var orgID sql.NullInt64
+	err := row.Scan(&video.ID, &video.Title, &video.Duration, &video.Description, &video.FileName, &video.Folder, &video.Image, &video.Status,
+		&video.UserID, &orgID, &video.FileSize, &video.ETag, &video.ContentType, &video.CreatedAt, &video.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	video.OrgID = nullableID(orgID)
+	return video, nil
+}
+
+// nullableID returns the ID held by a nullable column, nil when it is NULL
+func nullableID(id sql.NullInt64) *uint64 {
+	if !id.Valid {
+		return nil
 	}
-	return videos, nil
+	value := uint64(id.Int64)
+	return &value
 }
 
 // DeleteVideo deletes a video record by its ID
@@ -100,6 +150,29 @@ func (r *videoRepo) UpdateVideo(video *entity.Video) error {
 	return nil
 }
 
+// UpdateVideoFileInfo records the size, ETag and content type of the stored file along with the status
+func (r *videoRepo) UpdateVideoFileInfo(video *entity.Video) error {
+	query := `
+		UPDATE videos
+		SET file_size = ?, etag = ?, content_type = ?, status = ?, updated_at = ?
+		WHERE id = ?`
+	now := time.Now()
+	result, err := r.db.Exec(query, video.FileSize, video.ETag, video.ContentType, video.Status, now, video.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update video file info: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no video found with id %d", video.ID)
+	}
+	video.UpdatedAt = now
+	return nil
+}
+
 // UpdateVideoStatus updates only the status of a video record
 func (r *videoRepo) UpdateVideoStatus(videoID uint64, status entity.VideoStatus) error {
 	query := `
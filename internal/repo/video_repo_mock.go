@@ -25,6 +25,16 @@ func (m *MockVideoRepository) ListVideosByUserID(userID uint64) ([]entity.Video,
 	return args.Get(0).([]entity.Video), args.Error(1)
 }
 
+func (m *MockVideoRepository) CountVideosByUserID(userID uint64) (int, error) {
+	args := m.Called(userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockVideoRepository) ListVideosByOrgID(orgID uint64) ([]entity.Video, error) {
+	args := m.Called(orgID)
+	return args.Get(0).([]entity.Video), args.Error(1)
+}
+
 func (m *MockVideoRepository) DeleteVideo(videoID uint64) error {
 	args := m.Called(videoID)
 	return args.Error(0)
@@ -44,3 +54,8 @@ func (m *MockVideoRepository) GetVideoStatus(videoID uint64) (entity.VideoStatus
 	args := m.Called(videoID)
 	return args.Get(0).(entity.VideoStatus), args.Error(1)
 }
+
+func (m *MockVideoRepository) UpdateVideoFileInfo(video *entity.Video) error {
+	args := m.Called(video)
+	return args.Error(0)
+}
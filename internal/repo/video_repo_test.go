@@ -27,6 +27,10 @@ func setupTestDB() (*sql.DB, error) {
 		image TEXT,
 		status TEXT,
 		user_id INTEGER,
+		org_id INTEGER,
+		file_size INTEGER NOT NULL DEFAULT 0,
+		etag TEXT NOT NULL DEFAULT '',
+		content_type TEXT NOT NULL DEFAULT '',
 		created_at DATETIME,
 		updated_at DATETIME
 	);`
@@ -186,6 +190,45 @@ func TestUpdateVideo(t *testing.T) {
 	assert.WithinDuration(t, savedVideo.UpdatedAt, updatedVideo.UpdatedAt, time.Second)
 }
 
+func TestUpdateVideoFileInfo(t *testing.T) {
+	db, err := setupTestDB()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	videoRepo := NewVideoRepo(db)
+
+	video := &entity.Video{
+		Title:       "Test Video",
+		FileName:    "test.mp4",
+		Folder:      "test_folder",
+		Status:      entity.StatusPendingUpload,
+		ContentType: "video/mp4",
+		UserID:      1,
+	}
+	err = videoRepo.CreateVideo(video)
+	assert.NoError(t, err)
+
+	savedVideo, err := videoRepo.GetVideoByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.StatusPendingUpload, savedVideo.Status)
+
+	savedVideo.FileSize = 1024
+	savedVideo.ETag = `"abc"`
+	savedVideo.Status = entity.StatusRaw
+	err = videoRepo.UpdateVideoFileInfo(savedVideo)
+	assert.NoError(t, err)
+
+	updatedVideo, err := videoRepo.GetVideoByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), updatedVideo.FileSize)
+	assert.Equal(t, `"abc"`, updatedVideo.ETag)
+	assert.Equal(t, "video/mp4", updatedVideo.ContentType)
+	assert.Equal(t, entity.StatusRaw, updatedVideo.Status)
+
+	err = videoRepo.UpdateVideoFileInfo(&entity.Video{ID: 99, Status: entity.StatusRaw})
+	assert.EqualError(t, err, "no video found with id 99")
+}
+
 func TestDeleteVideo(t *testing.T) {
 	db, err := setupTestDB()
 	assert.NoError(t, err)
@@ -0,0 +1,307 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrVNPayNotConfigured = fmt.Errorf("vnpay: terminal code and hash secret are not configured: %w", ErrProviderNotConfigured)
+	ErrVNPayBadResponse   = errors.New("vnpay: unexpected response")
+)
+
+// maxVNPayResponseSize bounds the responses read from VNPay
+const maxVNPayResponseSize = 1 << 20
+
+// Limits VNPay puts on payments, in VND
+const (
+	MinVNPayAmount = 5000
+	MaxVNPayAmount = 999999999
+)
+
+// vnpayServerIP is the IP address sent in the API requests this server makes on its own
+const vnpayServerIP = "127.0.0.1"
+
+// VNPayRepo calls the VNPay payment gateway (API 2.1.0). Requests VNPay rejects return an *entity.VNPayError.
+type VNPayRepo interface {
+	PaymentProvider
+	// PaymentURL returns the signed URL of the page the user pays the order on
+	PaymentURL(order *entity.Order, description, clientIP string) (string, error)
+	// QueryPayment returns the status of the payment of the order in its TransactionStatus
+	QueryPayment(order *entity.Order) (*entity.VNPayQueryResponse, error)
+	// RefundPayment refunds amount of the paid order
+	RefundPayment(order *entity.Order, amount int64, description string) (*entity.VNPayRefundResponse, error)
+	// VerifyIPN reports whether the parameters of a notification come from VNPay for this terminal, by their hash
+	VerifyIPN(params url.Values) bool
+}
+
+type vnpayRepo struct {
+	config env.VNPayConfig
+	client *http.Client
+}
+
+// NewVNPayRepo creates a VNPay client from env.EnvConfig.VNPay
+func NewVNPayRepo() VNPayRepo {
+	return NewVNPayRepoWithConfig(env.EnvConfig.VNPay)
+}
+
+// NewVNPayRepoWithConfig creates a VNPay client calling config.PayURL and config.APIURL
+func NewVNPayRepoWithConfig(config env.VNPayConfig) VNPayRepo {
+	return &vnpayRepo{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+func (v *vnpayRepo) configured() bool {
+	return v.config.TmnCode != "" && v.config.HashSecret != ""
+}
+
+func (v *vnpayRepo) PaymentURL(order *entity.Order, description, clientIP string) (string, error) {
+	if !v.configured() {
+		return "", ErrVNPayNotConfigured
+	}
+	if clientIP == "" {
+		clientIP = vnpayServerIP
+	}
+	params := url.Values{}
+	params.Set("vnp_Version", entity.VNPayVersion)
+	params.Set("vnp_Command", "pay")
+	params.Set("vnp_TmnCode", v.config.TmnCode)
+	params.Set("vnp_Amount", strconv.FormatInt(order.Amount*100, 10))
+	params.Set("vnp_CurrCode", order.Currency)
+	params.Set("vnp_TxnRef", order.Reference)
+	params.Set("vnp_OrderInfo", description)
+	params.Set("vnp_OrderType", "other")
+	params.Set("vnp_Locale", v.config.Locale)
+	params.Set("vnp_ReturnUrl", v.config.ReturnURL)
+	params.Set("vnp_IpAddr", clientIP)
+	params.Set("vnp_CreateDate", vnpayDate(order.CreatedAt))
+	params.Set("vnp_ExpireDate", vnpayDate(order.CreatedAt.Add(v.config.ExpireAfter)))
+
+	data := entity.VNPayHashData(params)
+	return v.config.PayURL + "?" + data + "&vnp_SecureHash=" + entity.SignVNPay(v.config.HashSecret, data), nil
+}
+
+func (v *vnpayRepo) QueryPayment(order *entity.Order) (*entity.VNPayQueryResponse, error) {
+	requestID, err := newPaymentRequestID()
+	if err != nil {
+		return nil, err
+	}
+	request := &entity.VNPayQueryRequest{
+		RequestID:       requestID,
+		Version:         entity.VNPayVersion,
+		Command:         "querydr",
+		TmnCode:         v.config.TmnCode,
+		TxnRef:          order.Reference,
+		OrderInfo:       "Query order " + order.Reference,
+		TransactionDate: vnpayDate(order.CreatedAt),
+		CreateDate:      vnpayDate(time.Now()),
+		IPAddr:          vnpayServerIP,
+	}
+	request.Sign(v.config.HashSecret)
+
+	var response entity.VNPayQueryResponse
+	if err := v.post(request, &response); err != nil {
+		return nil, err
+	}
+	if response.ResponseCode != entity.VNPayResponseSuccess {
+		return nil, &entity.VNPayError{ResponseCode: response.ResponseCode, Message: response.Message}
+	}
+	return &response, nil
+}
+
+func (v *vnpayRepo) RefundPayment(order *entity.Order, amount int64, description string) (*entity.VNPayRefundResponse, error) {
+	requestID, err := newPaymentRequestID()
+	if err != nil {
+		return nil, err
+	}
+	transactionType := entity.VNPayRefundPartial
+	if order.RefundedAmount == 0 && amount == order.Amount {
+		transactionType = entity.VNPayRefundFull
+	}
+	request := &entity.VNPayRefundRequest{
+		RequestID:       requestID,
+		Version:         entity.VNPayVersion,
+		Command:         "refund",
+		TmnCode:         v.config.TmnCode,
+		TransactionType: transactionType,
+		TxnRef:          order.Reference,
+		Amount:          amount * 100,
+		OrderInfo:       description,
+		TransactionNo:   order.TransactionID,
+		TransactionDate: vnpayDate(order.CreatedAt),
+		CreateBy:        "system",
+		CreateDate:      vnpayDate(time.Now()),
+		IPAddr:          vnpayServerIP,
+	}
+	request.Sign(v.config.HashSecret)
+
+	var response entity.VNPayRefundResponse
+	if err := v.post(request, &response); err != nil {
+		return nil, err
+	}
+	if response.ResponseCode != entity.VNPayResponseSuccess {
+		return nil, &entity.VNPayError{ResponseCode: response.ResponseCode, Message: response.Message}
+	}
+	return &response, nil
+}
+
+func (v *vnpayRepo) VerifyIPN(params url.Values) bool {
+	if !v.configured() || params.Get("vnp_TmnCode") != v.config.TmnCode {
+		return false
+	}
+	return entity.ValidVNPaySignature(v.config.HashSecret, entity.VNPayHashData(params), params.Get("vnp_SecureHash"))
+}
+
+func (v *vnpayRepo) Name() string {
+	return entity.PaymentProviderVNPay
+}
+
+func (v *vnpayRepo) CreateCheckout(order *entity.Order, description, clientIP string) (*entity.Checkout, error) {
+	if order.Currency != entity.CurrencyVND || order.Amount < MinVNPayAmount || order.Amount > MaxVNPayAmount {
+		return nil, ErrCheckoutNotSupported
+	}
+	payURL, err := v.PaymentURL(order, description, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	// VNPay has no app of its own; banking apps pay by scanning the QR code of the payment page
+	return &entity.Checkout{PayURL: payURL}, nil
+}
+
+func (v *vnpayRepo) GetPaymentStatus(order *entity.Order) (*entity.PaymentStatus, error) {
+	payment, err := v.QueryPayment(order)
+	if err != nil {
+		return nil, err
+	}
+	status := &entity.PaymentStatus{
+		State:   entity.VNPayPaymentState(payment.TransactionStatus),
+		Amount:  payment.Amount / 100,
+		Code:    payment.TransactionStatus,
+		Message: payment.Message,
+	}
+	if status.State == entity.PaymentStatePaid {
+		status.TransactionID = payment.TransactionNo
+	}
+	return status, nil
+}
+
+func (v *vnpayRepo) Refund(order *entity.Order, amount int64) (*entity.PaymentRefund, error) {
+	if order.TransactionID == "" {
+		return nil, ErrNoProviderTransaction
+	}
+	refund, err := v.RefundPayment(order, amount, "Refund of order "+order.Reference)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.PaymentRefund{
+		RefundID:      refund.ResponseID,
+		TransactionID: refund.TransactionNo,
+		Amount:        refund.Amount / 100,
+	}, nil
+}
+
+func (v *vnpayRepo) VerifyWebhook(request *entity.WebhookRequest) (*entity.PaymentEvent, error) {
+	params := request.Query
+	if !v.VerifyIPN(params) {
+		return nil, ErrInvalidWebhookSignature
+	}
+	amount, err := strconv.ParseInt(params.Get("vnp_Amount"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: vnp_Amount: %v", ErrMalformedWebhook, err)
+	}
+	details := make(map[string]string, len(params))
+	for name := range params {
+		details[name] = params.Get(name)
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+
+	responseCode, transactionStatus := params.Get("vnp_ResponseCode"), params.Get("vnp_TransactionStatus")
+	event := &entity.PaymentEvent{
+		// VNPay retries a notification with the same parameters
+		ID:      fmt.Sprintf("ipn:%s:%s:%s:%s", params.Get("vnp_TxnRef"), params.Get("vnp_TransactionNo"), responseCode, transactionStatus),
+		OrderID: params.Get("vnp_TxnRef"),
+		State:   entity.PaymentStateFailed,
+		Amount:  amount / 100,
+		Details: string(detailsJSON),
+	}
+	// VNPay only notifies payments that ended
+	if responseCode == entity.VNPayResponseSuccess && transactionStatus == entity.VNPayTransactionSuccess {
+		event.State = entity.PaymentStatePaid
+		event.TransactionID = params.Get("vnp_TransactionNo")
+	}
+	if payDate, err := time.ParseInLocation(entity.VNPayDateFormat, params.Get("vnp_PayDate"), entity.VNPayLocation); err == nil {
+		event.OccurredAt = payDate
+	}
+	return event, nil
+}
+
+// WebhookResponse answers every notification with 200 and a RspCode, as VNPay expects. VNPay retries those
+// answered with 99.
+func (v *vnpayRepo) WebhookResponse(outcome entity.WebhookOutcome) *entity.WebhookResponse {
+	body := entity.VNPayIPNResponse{RspCode: entity.VNPayIPNUnknownError, Message: "Unknown error"}
+	switch outcome {
+	case entity.WebhookAccepted:
+		body = entity.VNPayIPNResponse{RspCode: entity.VNPayIPNConfirmed, Message: "Confirm Success"}
+	case entity.WebhookOrderNotFound:
+		body = entity.VNPayIPNResponse{RspCode: entity.VNPayIPNOrderNotFound, Message: "Order not found"}
+	case entity.WebhookAmountMismatch:
+		body = entity.VNPayIPNResponse{RspCode: entity.VNPayIPNInvalidAmount, Message: "Invalid amount"}
+	case entity.WebhookInvalidSignature:
+		body = entity.VNPayIPNResponse{RspCode: entity.VNPayIPNInvalidChecksum, Message: "Invalid signature"}
+	}
+	return &entity.WebhookResponse{Status: http.StatusOK, Body: body}
+}
+
+// post sends an API request as JSON and decodes the response
+func (v *vnpayRepo) post(request, response interface{}) error {
+	if !v.configured() {
+		return ErrVNPayNotConfigured
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, v.config.APIURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vnpay: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxVNPayResponseSize))
+	if err != nil {
+		return fmt.Errorf("vnpay: failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: status %d", ErrVNPayBadResponse, resp.StatusCode)
+	}
+	if err := json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("%w: %v", ErrVNPayBadResponse, err)
+	}
+	return nil
+}
+
+// vnpayDate formats a time the way VNPay expects dates
+func vnpayDate(t time.Time) string {
+	return t.In(entity.VNPayLocation).Format(entity.VNPayDateFormat)
+}
@@ -0,0 +1,187 @@
+package repo
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/repo/vnpaytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupVNPayRepo(t *testing.T) (VNPayRepo, *vnpaytest.Server, env.VNPayConfig) {
+	fake := vnpaytest.NewServer("VNPTEST1", "hash-secret")
+	t.Cleanup(fake.Close)
+
+	config := env.VNPayConfig{
+		PayURL:      fake.PayURL,
+		APIURL:      fake.APIURL,
+		TmnCode:     "VNPTEST1",
+		HashSecret:  "hash-secret",
+		ReturnURL:   "http://localhost:3000/payment-result",
+		Locale:      "en",
+		ExpireAfter: 15 * time.Minute,
+		Timeout:     5 * time.Second,
+	}
+	return NewVNPayRepoWithConfig(config), fake, config
+}
+
+func newVNPayTestOrder(reference string) *entity.Order {
+	return &entity.Order{Reference: reference, Amount: 99000, Currency: entity.CurrencyVND, CreatedAt: time.Now()}
+}
+
+func TestVNPayHashData(t *testing.T) {
+	params := url.Values{
+		"vnp_TxnRef":         {"ord-1"},
+		"vnp_Amount":         {"9900000"},
+		"vnp_OrderInfo":      {"Payment for Premium"},
+		"vnp_SecureHash":     {"ignored"},
+		"vnp_SecureHashType": {"HmacSHA512"},
+		"other":              {"ignored"},
+	}
+	assert.Equal(t, "vnp_Amount=9900000&vnp_OrderInfo=Payment+for+Premium&vnp_TxnRef=ord-1", entity.VNPayHashData(params),
+		"sorted by name, URL-encoded, without the hash")
+
+	query := &entity.VNPayQueryRequest{RequestID: "R", Version: "2.1.0", Command: "querydr", TmnCode: "T", TxnRef: "O",
+		OrderInfo: "I", TransactionDate: "D1", CreateDate: "D2", IPAddr: "A"}
+	assert.Equal(t, "R|2.1.0|querydr|T|O|D1|D2|A|I", query.RawSignature())
+	refund := &entity.VNPayRefundRequest{RequestID: "R", Version: "2.1.0", Command: "refund", TmnCode: "T",
+		TransactionType: "03", TxnRef: "O", Amount: 100, OrderInfo: "I", TransactionNo: "N", TransactionDate: "D1",
+		CreateBy: "B", CreateDate: "D2", IPAddr: "A"}
+	assert.Equal(t, "R|2.1.0|refund|T|03|O|100|N|D1|B|D2|A|I", refund.RawSignature())
+}
+
+func TestVNPayCheckoutQueryAndRefund(t *testing.T) {
+	vnpay, fake, _ := setupVNPayRepo(t)
+	order := newVNPayTestOrder("ord-1")
+
+	checkout, err := vnpay.CreateCheckout(order, "Payment for Premium", "203.0.113.7")
+	require.NoError(t, err)
+	require.NoError(t, fake.Open(checkout.PayURL), "the fake accepts the signature of the payment URL")
+	params, ok := fake.Payment(order.Reference)
+	require.True(t, ok)
+	assert.Equal(t, "9900000", params.Get("vnp_Amount"), "VNPay takes amounts times 100")
+	assert.Equal(t, "203.0.113.7", params.Get("vnp_IpAddr"))
+
+	status, err := vnpay.GetPaymentStatus(order)
+	require.NoError(t, err)
+	assert.Equal(t, entity.PaymentStatePending, status.State)
+
+	transactionNo, ok := fake.Pay(order.Reference)
+	require.True(t, ok)
+	status, err = vnpay.GetPaymentStatus(order)
+	require.NoError(t, err)
+	assert.Equal(t, entity.PaymentStatePaid, status.State)
+	assert.Equal(t, int64(99000), status.Amount)
+	assert.Equal(t, transactionNo, status.TransactionID)
+	order.TransactionID = status.TransactionID
+
+	refund, err := vnpay.Refund(order, 9000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9000), refund.Amount)
+	assert.NotEmpty(t, refund.TransactionID)
+	order.RefundedAmount = 9000
+
+	var vnpayErr *entity.VNPayError
+	_, err = vnpay.Refund(order, 91000)
+	require.ErrorAs(t, err, &vnpayErr)
+	assert.Equal(t, entity.VNPayResponseRefundRejected, vnpayErr.ResponseCode, "only 90,000 is left to refund")
+	_, err = vnpay.Refund(order, 90000)
+	require.NoError(t, err)
+}
+
+func TestVNPayWebhook(t *testing.T) {
+	vnpay, fake, _ := setupVNPayRepo(t)
+	order := newVNPayTestOrder("ord-1")
+	checkout, err := vnpay.CreateCheckout(order, "Payment for Premium", "203.0.113.7")
+	require.NoError(t, err)
+	require.NoError(t, fake.Open(checkout.PayURL))
+	transactionNo, ok := fake.Pay(order.Reference)
+	require.True(t, ok)
+
+	params, ok := fake.Notification(order.Reference)
+	require.True(t, ok)
+	event, err := vnpay.VerifyWebhook(&entity.WebhookRequest{Query: params})
+	require.NoError(t, err)
+	assert.Equal(t, order.Reference, event.OrderID)
+	assert.Equal(t, entity.PaymentStatePaid, event.State)
+	assert.Equal(t, int64(99000), event.Amount)
+	assert.Equal(t, transactionNo, event.TransactionID)
+	assert.WithinDuration(t, time.Now(), event.OccurredAt, time.Minute)
+	assert.Contains(t, event.Details, transactionNo)
+
+	tampered := url.Values{}
+	for name, values := range params {
+		tampered[name] = values
+	}
+	tampered.Set("vnp_Amount", "100000")
+	_, err = vnpay.VerifyWebhook(&entity.WebhookRequest{Query: tampered})
+	assert.ErrorIs(t, err, ErrInvalidWebhookSignature)
+	tampered.Set("vnp_Amount", "many")
+	vnpaytest.Sign(tampered, "hash-secret")
+	_, err = vnpay.VerifyWebhook(&entity.WebhookRequest{Query: tampered})
+	assert.ErrorIs(t, err, ErrMalformedWebhook)
+
+	failed := newVNPayTestOrder("ord-2")
+	checkout, err = vnpay.CreateCheckout(failed, "Payment for Premium", "203.0.113.7")
+	require.NoError(t, err)
+	require.NoError(t, fake.Open(checkout.PayURL))
+	require.True(t, fake.Fail(failed.Reference, entity.VNPayResponseCancelled))
+	params, ok = fake.Notification(failed.Reference)
+	require.True(t, ok)
+	event, err = vnpay.VerifyWebhook(&entity.WebhookRequest{Query: params})
+	require.NoError(t, err)
+	assert.Equal(t, entity.PaymentStateFailed, event.State)
+	assert.Empty(t, event.TransactionID)
+
+	for outcome, code := range map[entity.WebhookOutcome]string{
+		entity.WebhookAccepted:         entity.VNPayIPNConfirmed,
+		entity.WebhookOrderNotFound:    entity.VNPayIPNOrderNotFound,
+		entity.WebhookAmountMismatch:   entity.VNPayIPNInvalidAmount,
+		entity.WebhookInvalidSignature: entity.VNPayIPNInvalidChecksum,
+		entity.WebhookFailed:           entity.VNPayIPNUnknownError,
+	} {
+		response := vnpay.WebhookResponse(outcome)
+		assert.Equal(t, http.StatusOK, response.Status)
+		assert.Equal(t, code, response.Body.(entity.VNPayIPNResponse).RspCode, outcome)
+	}
+}
+
+func TestVNPayRejectedRequests(t *testing.T) {
+	vnpay, fake, config := setupVNPayRepo(t)
+	var vnpayErr *entity.VNPayError
+
+	_, err := vnpay.CreateCheckout(&entity.Order{Reference: "ord-1", Amount: 1000, Currency: entity.CurrencyVND}, "Payment", "")
+	assert.ErrorIs(t, err, ErrCheckoutNotSupported, "below VNPay's minimum")
+
+	_, err = vnpay.QueryPayment(newVNPayTestOrder("unknown"))
+	require.ErrorAs(t, err, &vnpayErr)
+	assert.Equal(t, entity.VNPayResponseOrderNotFound, vnpayErr.ResponseCode)
+
+	_, err = vnpay.Refund(newVNPayTestOrder("ord-2"), 1000)
+	assert.ErrorIs(t, err, ErrNoProviderTransaction, "unpaid orders have nothing to refund")
+
+	config.HashSecret = "wrong"
+	wrong := NewVNPayRepoWithConfig(config)
+	_, err = wrong.QueryPayment(newVNPayTestOrder("ord-3"))
+	require.ErrorAs(t, err, &vnpayErr)
+	assert.Equal(t, entity.VNPayResponseInvalidChecksum, vnpayErr.ResponseCode)
+	checkout, err := wrong.CreateCheckout(newVNPayTestOrder("ord-3"), "Payment", "")
+	require.NoError(t, err)
+	assert.Error(t, fake.Open(checkout.PayURL), "the payment page refuses URLs signed with another secret")
+
+	config.HashSecret = ""
+	_, err = NewVNPayRepoWithConfig(config).CreateCheckout(newVNPayTestOrder("ord-4"), "Payment", "")
+	assert.ErrorIs(t, err, ErrVNPayNotConfigured)
+	assert.ErrorIs(t, err, ErrProviderNotConfigured)
+
+	config.HashSecret = "hash-secret"
+	config.APIURL = fake.URL + "/missing"
+	_, err = NewVNPayRepoWithConfig(config).QueryPayment(newVNPayTestOrder("ord-5"))
+	assert.ErrorIs(t, err, ErrVNPayBadResponse)
+}
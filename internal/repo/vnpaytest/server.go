@@ -0,0 +1,341 @@
+// Package vnpaytest runs a local VNPay payment gateway for tests. It checks the terminal code and the hash of
+// every payment URL and API request like VNPay does, keeps the payments in memory and lets tests decide how each
+// payment ends.
+package vnpaytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"mlvt/internal/entity"
+)
+
+// Server is the stand-in gateway. PayURL and APIURL are the URLs to configure the client with.
+type Server struct {
+	URL        string
+	PayURL     string
+	APIURL     string
+	TmnCode    string
+	HashSecret string
+
+	server *httptest.Server
+
+	mu                sync.Mutex
+	payments          map[string]*payment // By vnp_TxnRef
+	requestIDs        map[string]bool     // vnp_RequestId of the API requests
+	nextTransactionNo int64
+}
+
+// payment is a payment opened through a payment URL
+type payment struct {
+	params            url.Values // Of the payment URL
+	amount            int64      // In VND times 100
+	responseCode      string
+	transactionStatus string
+	transactionNo     string
+	payDate           time.Time
+	refunded          int64
+}
+
+// NewServer starts a gateway for the terminal. Close it when done.
+func NewServer(tmnCode, hashSecret string) *Server {
+	s := &Server{
+		TmnCode:           tmnCode,
+		HashSecret:        hashSecret,
+		payments:          make(map[string]*payment),
+		requestIDs:        make(map[string]bool),
+		nextTransactionNo: 14000000,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/paymentv2/vpcpay.html", s.pay)
+	mux.HandleFunc("/merchant_webapi/api/transaction", s.transaction)
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+	s.PayURL = s.URL + "/paymentv2/vpcpay.html"
+	s.APIURL = s.URL + "/merchant_webapi/api/transaction"
+	return s
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Open opens a payment URL as the browser of the user does, which creates the payment at VNPay
+func (s *Server) Open(payURL string) error {
+	resp, err := http.Get(payURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("vnpaytest: status %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// Payment returns the parameters of the payment URL a payment was opened with
+func (s *Server) Payment(txnRef string) (url.Values, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.payments[txnRef]
+	if !ok {
+		return nil, false
+	}
+	return p.params, true
+}
+
+// Pay completes a pending payment as if the user paid it, returning VNPay's transaction number
+func (s *Server) Pay(txnRef string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.payments[txnRef]
+	if !ok || p.transactionStatus != entity.VNPayTransactionPending {
+		return "", false
+	}
+	s.nextTransactionNo++
+	p.responseCode = entity.VNPayResponseSuccess
+	p.transactionStatus = entity.VNPayTransactionSuccess
+	p.transactionNo = strconv.FormatInt(s.nextTransactionNo, 10)
+	p.payDate = time.Now()
+	return p.transactionNo, true
+}
+
+// Fail ends a pending payment with the response code, e.g. entity.VNPayResponseCancelled
+func (s *Server) Fail(txnRef, responseCode string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.payments[txnRef]
+	if !ok || p.transactionStatus != entity.VNPayTransactionPending {
+		return false
+	}
+	p.responseCode = responseCode
+	p.transactionStatus = entity.VNPayTransactionFailed
+	p.transactionNo = "0"
+	p.payDate = time.Now()
+	return true
+}
+
+// Notification returns the signed parameters VNPay sends to the IPN URL of the terminal for a payment that ended
+func (s *Server) Notification(txnRef string) (url.Values, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.payments[txnRef]
+	if !ok || p.transactionStatus == entity.VNPayTransactionPending {
+		return nil, false
+	}
+	params := url.Values{}
+	params.Set("vnp_Amount", strconv.FormatInt(p.amount, 10))
+	params.Set("vnp_BankCode", "NCB")
+	params.Set("vnp_CardType", "ATM")
+	params.Set("vnp_OrderInfo", p.params.Get("vnp_OrderInfo"))
+	params.Set("vnp_PayDate", p.payDate.In(entity.VNPayLocation).Format(entity.VNPayDateFormat))
+	params.Set("vnp_ResponseCode", p.responseCode)
+	params.Set("vnp_TmnCode", s.TmnCode)
+	params.Set("vnp_TransactionNo", p.transactionNo)
+	params.Set("vnp_TransactionStatus", p.transactionStatus)
+	params.Set("vnp_TxnRef", txnRef)
+	Sign(params, s.HashSecret)
+	return params, true
+}
+
+// Notify calls the IPN URL with the notification of a payment that ended, returning the answer
+func (s *Server) Notify(txnRef, ipnURL string) (*entity.VNPayIPNResponse, error) {
+	params, ok := s.Notification(txnRef)
+	if !ok {
+		return nil, errors.New("vnpaytest: no ended payment for the order")
+	}
+	resp, err := http.Get(ipnURL + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vnpaytest: IPN answered status %d", resp.StatusCode)
+	}
+	var answer entity.VNPayIPNResponse
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return nil, err
+	}
+	return &answer, nil
+}
+
+// Sign sets vnp_SecureHash of the parameters
+func Sign(params url.Values, hashSecret string) {
+	params.Set("vnp_SecureHash", entity.SignVNPay(hashSecret, entity.VNPayHashData(params)))
+}
+
+func (s *Server) pay(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if params.Get("vnp_TmnCode") != s.TmnCode ||
+		!entity.ValidVNPaySignature(s.HashSecret, entity.VNPayHashData(params), params.Get("vnp_SecureHash")) {
+		http.Error(w, "Invalid signature (code 70)", http.StatusBadRequest)
+		return
+	}
+	for _, name := range []string{
+		"vnp_Version", "vnp_Command", "vnp_CurrCode", "vnp_TxnRef", "vnp_OrderInfo", "vnp_ReturnUrl", "vnp_IpAddr",
+		"vnp_CreateDate", "vnp_ExpireDate", "vnp_Locale",
+	} {
+		if params.Get(name) == "" {
+			http.Error(w, "Missing "+name+" (code 03)", http.StatusBadRequest)
+			return
+		}
+	}
+	amount, err := strconv.ParseInt(params.Get("vnp_Amount"), 10, 64)
+	if err != nil || amount < 100*5000 || amount%100 != 0 {
+		http.Error(w, "Invalid amount (code 13)", http.StatusBadRequest)
+		return
+	}
+	expires, err := time.ParseInLocation(entity.VNPayDateFormat, params.Get("vnp_ExpireDate"), entity.VNPayLocation)
+	if err != nil || time.Now().After(expires) {
+		http.Error(w, "Payment expired (code 11)", http.StatusBadRequest)
+		return
+	}
+
+	txnRef := params.Get("vnp_TxnRef")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.payments[txnRef]; ok {
+		if p.params.Encode() != params.Encode() {
+			http.Error(w, "Duplicate order (code 94)", http.StatusBadRequest)
+			return
+		}
+	} else {
+		s.payments[txnRef] = &payment{params: params, amount: amount, transactionStatus: entity.VNPayTransactionPending}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	fmt.Fprintf(w, "<html><body>Pay %s</body></html>", txnRef)
+}
+
+func (s *Server) transaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	var command struct {
+		Command string `json:"vnp_Command"`
+	}
+	if err := json.Unmarshal(body, &command); err != nil {
+		writeJSON(w, map[string]string{"vnp_ResponseCode": entity.VNPayResponseUnknownError, "vnp_Message": "Bad format request"})
+		return
+	}
+	switch command.Command {
+	case "querydr":
+		s.query(w, body)
+	case "refund":
+		s.refund(w, body)
+	default:
+		writeJSON(w, map[string]string{"vnp_ResponseCode": entity.VNPayResponseUnknownError, "vnp_Message": "Unknown command"})
+	}
+}
+
+func (s *Server) query(w http.ResponseWriter, body []byte) {
+	var req entity.VNPayQueryRequest
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&req); err != nil {
+		writeJSON(w, entity.VNPayQueryResponse{ResponseCode: entity.VNPayResponseUnknownError, Message: "Bad format request"})
+		return
+	}
+	resp := entity.VNPayQueryResponse{ResponseID: req.RequestID, Command: req.Command, TmnCode: req.TmnCode, TxnRef: req.TxnRef}
+	if code, message, ok := s.check(req.TmnCode, req.RequestID, req.SecureHash, req.RawSignature()); !ok {
+		resp.ResponseCode, resp.Message = code, message
+		writeJSON(w, resp)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.payments[req.TxnRef]
+	if !ok || p.params.Get("vnp_CreateDate") != req.TransactionDate {
+		resp.ResponseCode, resp.Message = entity.VNPayResponseOrderNotFound, "Transaction not found"
+		writeJSON(w, resp)
+		return
+	}
+	resp.ResponseCode, resp.Message = entity.VNPayResponseSuccess, "QueryDR Success"
+	resp.Amount = p.amount
+	resp.OrderInfo = p.params.Get("vnp_OrderInfo")
+	resp.TransactionNo = p.transactionNo
+	resp.TransactionType = "01"
+	resp.TransactionStatus = p.transactionStatus
+	if !p.payDate.IsZero() {
+		resp.BankCode = "NCB"
+		resp.PayDate = p.payDate.In(entity.VNPayLocation).Format(entity.VNPayDateFormat)
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) refund(w http.ResponseWriter, body []byte) {
+	var req entity.VNPayRefundRequest
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&req); err != nil {
+		writeJSON(w, entity.VNPayRefundResponse{ResponseCode: entity.VNPayResponseUnknownError, Message: "Bad format request"})
+		return
+	}
+	resp := entity.VNPayRefundResponse{
+		ResponseID: req.RequestID, Command: req.Command, TmnCode: req.TmnCode, TxnRef: req.TxnRef,
+		Amount: req.Amount, TransactionType: req.TransactionType,
+	}
+	if code, message, ok := s.check(req.TmnCode, req.RequestID, req.SecureHash, req.RawSignature()); !ok {
+		resp.ResponseCode, resp.Message = code, message
+		writeJSON(w, resp)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.payments[req.TxnRef]
+	if !ok || p.transactionNo != req.TransactionNo || p.params.Get("vnp_CreateDate") != req.TransactionDate {
+		resp.ResponseCode, resp.Message = entity.VNPayResponseOrderNotFound, "Transaction not found"
+		writeJSON(w, resp)
+		return
+	}
+	full := req.TransactionType == entity.VNPayRefundFull
+	if p.transactionStatus != entity.VNPayTransactionSuccess || req.Amount < 1 || p.refunded+req.Amount > p.amount ||
+		full != (p.refunded == 0 && req.Amount == p.amount) {
+		resp.ResponseCode, resp.Message = entity.VNPayResponseRefundRejected, "Refund rejected"
+		writeJSON(w, resp)
+		return
+	}
+
+	s.nextTransactionNo++
+	p.refunded += req.Amount
+	resp.ResponseCode, resp.Message = entity.VNPayResponseSuccess, "Refund success"
+	resp.TransactionNo = strconv.FormatInt(s.nextTransactionNo, 10)
+	resp.TransactionStatus = "05" // Refund being processed
+	resp.OrderInfo = req.OrderInfo
+	resp.BankCode = "NCB"
+	writeJSON(w, resp)
+}
+
+// check checks the terminal code, the hash and the uniqueness of the request ID of an API request, returning
+// the response code to answer when one is wrong
+func (s *Server) check(tmnCode, requestID, hash, rawSignature string) (string, string, bool) {
+	if tmnCode != s.TmnCode || !entity.ValidVNPaySignature(s.HashSecret, rawSignature, hash) {
+		return entity.VNPayResponseInvalidChecksum, "Invalid checksum", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if requestID == "" || s.requestIDs[requestID] {
+		return entity.VNPayResponseDuplicate, "Duplicate request", false
+	}
+	s.requestIDs[requestID] = true
+	return "", "", true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	_ = json.NewEncoder(w).Encode(v)
+}
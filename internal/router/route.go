@@ -1,6 +1,7 @@
 package router
 
 import (
+	"mlvt/internal/entity"
 	handler "mlvt/internal/handler/rest/v1"
 	"mlvt/internal/pkg/middleware"
 
@@ -13,18 +14,42 @@ type AppRouter struct {
 	audioController         *handler.AudioController
 	transcriptionController *handler.TranscriptionController
 	authMiddleware          *middleware.AuthUserMiddleware
-	momoPaymentController   *handler.MoMoPaymentController
+	paymentController       *handler.PaymentController
+	orderController         *handler.OrderController
+	subscriptionController  *handler.SubscriptionController
+	storageController       *handler.StorageController
+	mlWorkerController      *handler.MLWorkerController
+	pipelineController      *handler.PipelineController
+	adminController         *handler.AdminController
+	twoFactorController     *handler.TwoFactorController
+	apiKeyController        *handler.APIKeyController
+	oidcController          *handler.OIDCController
+	organizationController  *handler.OrganizationController
+	workerMiddleware        *middleware.AuthWorkerMiddleware
+	ownershipMiddleware     *middleware.OwnershipMiddleware
 	swaggerRouter           *SwaggerRouter
 }
 
-func NewAppRouter(userController *handler.UserController, videoController *handler.VideoController, audioController *handler.AudioController, transcriptionController *handler.TranscriptionController, authMiddleware *middleware.AuthUserMiddleware, momoPaymentController *handler.MoMoPaymentController, swaggerRouter *SwaggerRouter) *AppRouter {
+func NewAppRouter(userController *handler.UserController, videoController *handler.VideoController, audioController *handler.AudioController, transcriptionController *handler.TranscriptionController, authMiddleware *middleware.AuthUserMiddleware, paymentController *handler.PaymentController, orderController *handler.OrderController, subscriptionController *handler.SubscriptionController, storageController *handler.StorageController, mlWorkerController *handler.MLWorkerController, pipelineController *handler.PipelineController, adminController *handler.AdminController, twoFactorController *handler.TwoFactorController, apiKeyController *handler.APIKeyController, oidcController *handler.OIDCController, organizationController *handler.OrganizationController, workerMiddleware *middleware.AuthWorkerMiddleware, ownershipMiddleware *middleware.OwnershipMiddleware, swaggerRouter *SwaggerRouter) *AppRouter {
 	return &AppRouter{
 		userController:          userController,
 		videoController:         videoController,
 		audioController:         audioController,
 		transcriptionController: transcriptionController,
 		authMiddleware:          authMiddleware,
-		momoPaymentController:   momoPaymentController,
+		paymentController:       paymentController,
+		orderController:         orderController,
+		subscriptionController:  subscriptionController,
+		storageController:       storageController,
+		mlWorkerController:      mlWorkerController,
+		pipelineController:      pipelineController,
+		adminController:         adminController,
+		twoFactorController:     twoFactorController,
+		apiKeyController:        apiKeyController,
+		oidcController:          oidcController,
+		organizationController:  organizationController,
+		workerMiddleware:        workerMiddleware,
+		ownershipMiddleware:     ownershipMiddleware,
 		swaggerRouter:           swaggerRouter,
 	}
 }
@@ -35,70 +60,200 @@ func (a *AppRouter) RegisterUserRoutes(r *gin.RouterGroup) {
 	{
 		public.POST("/register", a.userController.RegisterUser)
 		public.POST("/login", a.userController.LoginUser)
+		public.POST("/login/2fa", a.userController.LoginTwoFactor)       // Second login step with the challenge token and a code
+		public.POST("/refresh", a.userController.RefreshToken)           // Rotate the refresh token for a new access token
+		public.POST("/logout", a.userController.Logout)                  // Revoke the refresh token, or every token with "all"
+		public.POST("/forgot-password", a.userController.ForgotPassword) // Mail a password reset link
+		public.POST("/reset-password", a.userController.ResetPassword)   // Set a new password with the mailed token
+		public.GET("/verify-email", a.userController.VerifyEmail)        // Verify the email address with the mailed token
+	}
+
+	// Login through OpenID Connect providers such as Google or a corporate identity provider
+	oidc := r.Group("/users/oidc")
+	{
+		oidc.GET("/providers", a.oidcController.ListProviders)     // Names of the configured providers
+		oidc.GET("/:provider/login", a.oidcController.Login)       // Redirect to the provider
+		oidc.GET("/:provider/callback", a.oidcController.Callback) // The provider redirects back here with the code
 	}
 
 	protected := r.Group("/users")
-	protected.Use(a.authMiddleware.MustAuth())
+	protected.Use(a.authMiddleware.MustAuth(), a.ownershipMiddleware.MustOwnUser("user_id")) // Only the user themselves or an admin
 	{
 		protected.GET("/:user_id", a.userController.GetUser)
 		protected.PUT("/:user_id", a.userController.UpdateUser)
 		protected.DELETE("/:user_id", a.userController.DeleteUser)
-		protected.PUT("/:user_id/change-password", a.userController.ChangePassword)
 		protected.PUT("/:user_id/update-avatar", a.userController.UpdateAvatar)                    // Avatar upload (presigned URL)
 		protected.GET("/:user_id/avatar-download-url", a.userController.GenerateAvatarDownloadURL) // Avatar download (presigned URL)
 		protected.GET("/:user_id/avatar", a.userController.LoadAvatar)                             // Load avatar directly
 	}
+
+	twoFactor := r.Group("/users/2fa")
+	twoFactor.Use(a.authMiddleware.MustAuth()) // Always the current user
+	{
+		twoFactor.GET("", a.twoFactorController.GetStatus)
+		twoFactor.POST("/enroll", a.twoFactorController.Enroll)                          // New secret with its otpauth URI and QR code
+		twoFactor.POST("/confirm", a.twoFactorController.Confirm)                        // Enable with a code, returns the recovery codes
+		twoFactor.POST("/disable", a.twoFactorController.Disable)                        // Disable with a code or a recovery code
+		twoFactor.POST("/recovery-codes", a.twoFactorController.RegenerateRecoveryCodes) // Replace the recovery codes
+	}
+
+	// Managing API keys needs an access token; API keys cannot create more keys
+	apiKeys := r.Group("/users/api-keys")
+	apiKeys.Use(a.authMiddleware.MustAuth()) // Always the current user
+	{
+		apiKeys.POST("", a.apiKeyController.CreateAPIKey)           // Returns the key once
+		apiKeys.GET("", a.apiKeyController.ListAPIKeys)             // Keys not revoked, with their last use
+		apiKeys.DELETE("/:key_id", a.apiKeyController.RevokeAPIKey) // Revoke a key
+	}
+
+	// Users whose password reset was forced by an admin may still change their password
+	password := r.Group("/users")
+	password.Use(a.authMiddleware.MustAuthForPasswordChange(), a.ownershipMiddleware.MustOwnUser("user_id"))
+	{
+		password.PUT("/:user_id/change-password", a.userController.ChangePassword)
+	}
+}
+
+// RegisterAdminRoutes sets up the user-management routes, each gated by the permission it needs
+func (a *AppRouter) RegisterAdminRoutes(r *gin.RouterGroup) {
+	admin := r.Group("/admin")
+	admin.Use(a.authMiddleware.MustAuth())
+	{
+		admin.GET("/users", a.authMiddleware.RequirePermission(entity.PermissionUserList), a.adminController.ListUsers)                                            // List users by status, role, premium and creation time
+		admin.POST("/users/:user_id/suspend", a.authMiddleware.RequirePermission(entity.PermissionUserSuspend), a.adminController.SuspendUser)                     // Suspend a user
+		admin.POST("/users/:user_id/unsuspend", a.authMiddleware.RequirePermission(entity.PermissionUserSuspend), a.adminController.UnsuspendUser)                 // Unsuspend a user
+		admin.POST("/users/:user_id/password-reset", a.authMiddleware.RequirePermission(entity.PermissionUserResetPassword), a.adminController.ForcePasswordReset) // Force a password change
+		admin.PUT("/users/:user_id/role", a.authMiddleware.RequirePermission(entity.PermissionUserAssignRole), a.adminController.ChangeRole)                       // Change the role of a user
+		admin.POST("/users/:user_id/restore", a.authMiddleware.RequirePermission(entity.PermissionUserRestore), a.adminController.RestoreUser)                     // Restore a deleted user
+		admin.POST("/users/:user_id/unlock", a.authMiddleware.RequirePermission(entity.PermissionUserUnlock), a.adminController.UnlockUser)                        // Lift a login lockout
+		admin.GET("/audit-logs", a.authMiddleware.RequirePermission(entity.PermissionAuditLogRead), a.adminController.ListAuditLogs)                               // List the audit trail
+	}
+}
+
+// RegisterOrganizationRoutes sets up the routes for organizations, each gated by the role it needs
+func (a *AppRouter) RegisterOrganizationRoutes(r *gin.RouterGroup) {
+	orgs := r.Group("/organizations")
+	orgs.Use(a.authMiddleware.MustAuth())
+	{
+		orgs.POST("", a.organizationController.CreateOrganization)          // The current user becomes the owner
+		orgs.GET("", a.organizationController.ListOrganizations)            // Organizations of the current user with their role
+		orgs.POST("/invites/accept", a.organizationController.AcceptInvite) // Join with the token from the invitation email
+	}
+
+	members := orgs.Group("/:org_id")
+	members.Use(a.ownershipMiddleware.MustHaveOrgRole("org_id", entity.OrgRoleViewer)) // Any member or an admin
+	{
+		members.GET("", a.organizationController.GetOrganization)
+		members.GET("/members", a.organizationController.ListMembers)
+		members.POST("/leave", a.organizationController.LeaveOrganization) // The last owner cannot leave
+	}
+
+	owners := orgs.Group("/:org_id")
+	owners.Use(a.ownershipMiddleware.MustHaveOrgRole("org_id", entity.OrgRoleOwner)) // Only owners or an admin
+	{
+		owners.PUT("/members/:user_id", a.organizationController.ChangeMemberRole)  // Change the role of a member
+		owners.DELETE("/members/:user_id", a.organizationController.RemoveMember)   // Remove a member
+		owners.POST("/invites", a.organizationController.Invite)                    // Mail an invitation
+		owners.GET("/invites", a.organizationController.ListInvites)                // Pending invitations
+		owners.DELETE("/invites/:invite_id", a.organizationController.RevokeInvite) // Revoke an invitation
+	}
 }
 
 // RegisterVideoRoutes sets up the routes for video-related operations
 func (a *AppRouter) RegisterVideoRoutes(r *gin.RouterGroup) {
 	protected := r.Group("/videos")
-	protected.Use(a.authMiddleware.MustAuth())
-	{
-		protected.POST("/", a.videoController.AddVideo)                                               // Add a new video
-		protected.GET("/:video_id", a.videoController.GetVideoByID)                                   // Get video by ID
-		protected.GET("/user/:user_id", a.videoController.ListVideosByUserID)                         // List videos by user ID
-		protected.DELETE("/:video_id", a.videoController.DeleteVideo)                                 // Delete video by ID
-		protected.GET("/:video_id/status", a.videoController.GetVideoStatus)                          // Get video status
-		protected.PUT("/:video_id/status", a.videoController.UpdateVideoStatus)                       // Update video status
-		protected.POST("/generate-upload-url/video", a.videoController.GenerateUploadURLForVideo)     // Generate presigned upload URL for video
-		protected.POST("/generate-upload-url/image", a.videoController.GenerateUploadURLForImage)     // Generate presigned upload URL for image
-		protected.GET("/:video_id/download-url/video", a.videoController.GenerateDownloadURLForVideo) // Generate presigned download URL for video
-		protected.GET("/:video_id/download-url/image", a.videoController.GenerateDownloadURLForImage) // Generate presigned download URL for image
+	protected.Use(a.authMiddleware.MustAuthWithAPIKey(entity.APIKeyScopeVideosRead, entity.APIKeyScopeVideosWrite)) // Also API keys with a videos scope
+	{
+		protected.POST("/", a.videoController.AddVideo)                                                                                           // Add a new video
+		protected.GET("/user/:user_id", a.ownershipMiddleware.MustOwnUser("user_id"), a.videoController.ListVideosByUserID)                       // List videos by user ID
+		protected.GET("/org/:org_id", a.ownershipMiddleware.MustHaveOrgRole("org_id", entity.OrgRoleViewer), a.videoController.ListVideosByOrgID) // List videos of an organization
+		protected.POST("/generate-upload-url/video", a.videoController.GenerateUploadURLForVideo)                                                 // Generate presigned upload URL for video
+		protected.POST("/generate-upload-url/image", a.videoController.GenerateUploadURLForImage)                                                 // Generate presigned upload URL for image
+
+		// Owners, admins and roles with video:delete:any may delete a video
+		protected.DELETE("/:video_id", a.ownershipMiddleware.MustOwnVideo("video_id", entity.PermissionVideoDeleteAny), a.videoController.DeleteVideo)
+
+		// Multipart uploads for large video files
+		protected.POST("/uploads", a.videoController.InitiateMultipartUpload)                     // Start a multipart upload
+		protected.GET("/uploads", a.videoController.ListUploadSessions)                           // List unfinished uploads of the current user
+		protected.POST("/uploads/:upload_id/parts", a.videoController.PresignUploadParts)         // Generate presigned URLs for parts
+		protected.GET("/uploads/:upload_id/parts", a.videoController.ListUploadedParts)           // List parts already uploaded
+		protected.POST("/uploads/:upload_id/complete", a.videoController.CompleteMultipartUpload) // Complete the upload with the part ETags
+		protected.DELETE("/uploads/:upload_id", a.videoController.AbortMultipartUpload)           // Abort the upload
+	}
+
+	owned := protected.Group("/:video_id")
+	owned.Use(a.ownershipMiddleware.MustOwnVideo("video_id")) // Only the owner of the video or an admin
+	{
+		owned.POST("/finalize", a.videoController.FinalizeVideoUpload)                  // Confirm the video file was uploaded
+		owned.GET("", a.videoController.GetVideoByID)                                   // Get video by ID
+		owned.GET("/status", a.videoController.GetVideoStatus)                          // Get video status
+		owned.PUT("/status", a.videoController.UpdateVideoStatus)                       // Update video status
+		owned.GET("/download-url/video", a.videoController.GenerateDownloadURLForVideo) // Generate presigned download URL for video
+		owned.GET("/download-url/image", a.videoController.GenerateDownloadURLForImage) // Generate presigned download URL for image
+
+		// Translation pipelines (STT -> MT -> TTS -> mux) run by the ML workers
+		owned.POST("/pipelines", a.pipelineController.CreatePipeline)          // Create a pipeline
+		owned.GET("/pipelines", a.pipelineController.ListPipelines)            // List the pipelines of a video
+		owned.GET("/pipelines/:pipeline_id", a.pipelineController.GetPipeline) // Get the aggregated status of a pipeline
 	}
 }
 
 // RegisterTranscriptionRoutes sets up the routes for transcription-related operations
 func (a *AppRouter) RegisterTranscriptionRoutes(r *gin.RouterGroup) {
 	protected := r.Group("/transcriptions")
-	protected.Use(a.authMiddleware.MustAuth()) // Require authentication
-	{
-		protected.POST("/", a.transcriptionController.AddTranscription)                                        // Add a new transcription
-		protected.GET("/:transcriptionID", a.transcriptionController.GetTranscriptionByID)                     // Get transcription by ID
-		protected.GET("/:transcriptionID/user/:userID", a.transcriptionController.GetTranscriptionByUserID)    // Get transcription by transcription ID and user ID
-		protected.GET("/:transcriptionID/video/:videoID", a.transcriptionController.GetTranscriptionByVideoID) // Get transcription by transcription ID and video ID
-		protected.GET("/user/:user_id", a.transcriptionController.ListTranscriptionsByUserID)                  // List transcriptions by user ID
-		protected.GET("/video/:video_id", a.transcriptionController.ListTranscriptionsByVideoID)               // List transcriptions by video ID
-		protected.DELETE("/:transcriptionID", a.transcriptionController.DeleteTranscription)                   // Delete transcription by ID
-		protected.POST("/generate-upload-url", a.transcriptionController.GenerateUploadURL)                    // Generate presigned upload URL
-		protected.GET("/:transcriptionID/download-url", a.transcriptionController.GenerateDownloadURL)         // Generate presigned download URL
+	protected.Use(a.authMiddleware.MustAuthWithAPIKey(entity.APIKeyScopeTranscriptionsRead, entity.APIKeyScopeTranscriptionsWrite)) // Also API keys with a transcriptions scope
+	{
+		protected.POST("/", a.transcriptionController.AddTranscription)                                                                                           // Add a new transcription
+		protected.GET("/user/:user_id", a.ownershipMiddleware.MustOwnUser("user_id"), a.transcriptionController.ListTranscriptionsByUserID)                       // List transcriptions by user ID
+		protected.GET("/org/:org_id", a.ownershipMiddleware.MustHaveOrgRole("org_id", entity.OrgRoleViewer), a.transcriptionController.ListTranscriptionsByOrgID) // List transcriptions of an organization
+		protected.GET("/video/:video_id", a.ownershipMiddleware.MustOwnVideo("video_id"), a.transcriptionController.ListTranscriptionsByVideoID)                  // List transcriptions by video ID
+		protected.POST("/generate-upload-url", a.transcriptionController.GenerateUploadURL)                                                                       // Generate presigned upload URL
+		protected.POST("/import", a.transcriptionController.ImportTranscription)                                                                                  // Create a transcription from a subtitle file
+	}
+
+	owned := protected.Group("/:transcriptionID")
+	owned.Use(a.ownershipMiddleware.MustOwnTranscription("transcriptionID")) // Only the owner of the transcription or an admin
+	{
+		owned.POST("/finalize", a.transcriptionController.FinalizeTranscriptionUpload)        // Confirm the transcription file was uploaded
+		owned.GET("", a.transcriptionController.GetTranscriptionByID)                         // Get transcription by ID
+		owned.GET("/user/:userID", a.transcriptionController.GetTranscriptionByUserID)        // Get transcription by transcription ID and user ID
+		owned.GET("/video/:videoID", a.transcriptionController.GetTranscriptionByVideoID)     // Get transcription by transcription ID and video ID
+		owned.DELETE("", a.transcriptionController.DeleteTranscription)                       // Delete transcription by ID
+		owned.GET("/download-url", a.transcriptionController.GenerateDownloadURL)             // Generate presigned download URL
+		owned.POST("/segments", a.transcriptionController.AddSegments)                        // Add time-coded segments
+		owned.GET("/segments", a.transcriptionController.ListSegments)                        // Page through the segments
+		owned.GET("/segments/:segmentID", a.transcriptionController.GetSegment)               // Get a segment
+		owned.PATCH("/segments/:segmentID", a.transcriptionController.UpdateSegment)          // Update a segment
+		owned.GET("/export", a.transcriptionController.ExportTranscription)                   // Export as SRT, WebVTT or TTML
+		owned.GET("/revisions", a.transcriptionController.ListRevisions)                      // List the edit history
+		owned.GET("/revisions/diff", a.transcriptionController.DiffRevisions)                 // Compare two revisions segment by segment
+		owned.GET("/revisions/:revision", a.transcriptionController.GetRevision)              // Get a revision with its segments
+		owned.POST("/revisions/:revision/restore", a.transcriptionController.RestoreRevision) // Restore the segments of a revision
 	}
 }
 
 // RegisterAudioRoutes sets up the routes for audio-related operations
 func (a *AppRouter) RegisterAudioRoutes(r *gin.RouterGroup) {
 	protected := r.Group("/audios")
-	protected.Use(a.authMiddleware.MustAuth())
-	{
-		protected.POST("/", a.audioController.AddAudio)                                // Add a new audio
-		protected.GET("/:audioID", a.audioController.GetAudio)                         // Get a specific audio by ID
-		protected.DELETE("/:audioID", a.audioController.DeleteAudio)                   // Delete an audio
-		protected.GET("/user/:userID", a.audioController.ListAudiosByUserID)           // Get all audios by user
-		protected.GET("/video/:videoID", a.audioController.ListAudiosByVideoID)        // Get all audios by video
-		protected.GET("/:audioID/user/:userID", a.audioController.GetAudioByUser)      // Get specific audio by audio ID and user ID
-		protected.GET("/:audioID/video/:videoID", a.audioController.GetAudioByVideoID) // Get specific audio by audio ID and video ID
-		protected.POST("/generate-presigned-url", a.audioController.GenerateUploadURL) // Generate presigned URL for audio upload
-		protected.GET("/:audioID/download-url", a.audioController.GenerateDownloadURL) // Generate presigned URL for audio download
+	protected.Use(a.authMiddleware.MustAuthWithAPIKey(entity.APIKeyScopeAudiosRead, entity.APIKeyScopeAudiosWrite)) // Also API keys with an audios scope
+	{
+		protected.POST("/", a.audioController.AddAudio)                                                                                         // Add a new audio
+		protected.GET("/user/:userID", a.ownershipMiddleware.MustOwnUser("userID"), a.audioController.ListAudiosByUserID)                       // Get all audios by user
+		protected.GET("/org/:orgID", a.ownershipMiddleware.MustHaveOrgRole("orgID", entity.OrgRoleViewer), a.audioController.ListAudiosByOrgID) // Get all audios of an organization
+		protected.GET("/video/:videoID", a.ownershipMiddleware.MustOwnVideo("videoID"), a.audioController.ListAudiosByVideoID)                  // Get all audios by video
+		protected.POST("/generate-presigned-url", a.audioController.GenerateUploadURL)                                                          // Generate presigned URL for audio upload
+	}
+
+	owned := protected.Group("/:audioID")
+	owned.Use(a.ownershipMiddleware.MustOwnAudio("audioID")) // Only the owner of the audio or an admin
+	{
+		owned.POST("/finalize", a.audioController.FinalizeAudioUpload)    // Confirm the audio file was uploaded
+		owned.GET("", a.audioController.GetAudio)                         // Get a specific audio by ID
+		owned.DELETE("", a.audioController.DeleteAudio)                   // Delete an audio
+		owned.GET("/user/:userID", a.audioController.GetAudioByUser)      // Get specific audio by audio ID and user ID
+		owned.GET("/video/:videoID", a.audioController.GetAudioByVideoID) // Get specific audio by audio ID and video ID
+		owned.GET("/download-url", a.audioController.GenerateDownloadURL) // Generate presigned URL for audio download
 	}
 }
 
@@ -106,15 +261,81 @@ func (a *AppRouter) RegisterAudioRoutes(r *gin.RouterGroup) {
 func (a *AppRouter) RegisterPaymentRoutes(r *gin.RouterGroup) {
 	payment := r.Group("/payments")
 	{
-		// Group for MoMo-specific routes
-		momo := payment.Group("/momo")
+		payment.GET("/plans", a.orderController.ListPlans) // List the plans that can be paid for
+
+		// Orders of the current user
+		orders := payment.Group("/orders")
+		orders.Use(a.authMiddleware.MustAuth())
 		{
-			momo.POST("/create", a.momoPaymentController.CreateMoMoPayment)     // Create MoMo payment and return QR code
-			momo.POST("/check-status", a.momoPaymentController.CheckMoMoStatus) // Check status of MoMo payment
-			momo.POST("/refund", a.momoPaymentController.RefundMoMoPayment)     // Refund MoMo payment
+			orders.GET("", a.orderController.ListOrders)         // List the orders of the current user
+			orders.GET("/:order_id", a.orderController.GetOrder) // Get an order with the status of its payment
 		}
 
-		// More payment methods can be added here...
+		payment.GET("/providers", a.paymentController.ListProviders) // List the providers orders can be paid through
+
+		// Providers notify the result of a payment, signed with their secret: MoMo posts JSON, VNPay calls with query parameters
+		payment.POST("/:provider/ipn", a.paymentController.PaymentWebhook)
+		payment.GET("/:provider/ipn", a.paymentController.PaymentWebhook)
+
+		// Orders are created for the current user
+		checkout := payment.Group("/:provider")
+		checkout.Use(a.authMiddleware.MustAuth())
+		{
+			checkout.POST("/create", a.paymentController.CreatePayment)            // Create an order of a plan and a payment of it at the provider
+			checkout.POST("/check-status", a.paymentController.CheckPaymentStatus) // Check the status of a payment at the provider and update the order
+		}
+
+		// Refunds need the payment:refund permission
+		refunds := payment.Group("/:provider")
+		refunds.Use(a.authMiddleware.MustAuth(), a.authMiddleware.RequirePermission(entity.PermissionPaymentRefund))
+		{
+			refunds.POST("/refund", a.paymentController.RefundPayment) // Refund a paid order at its provider
+		}
+	}
+}
+
+// RegisterSubscriptionRoutes sets up the routes for the premium subscription of the current user
+func (a *AppRouter) RegisterSubscriptionRoutes(r *gin.RouterGroup) {
+	subscriptions := r.Group("/subscriptions")
+	subscriptions.Use(a.authMiddleware.MustAuth()) // Always the current user
+	{
+		subscriptions.GET("", a.subscriptionController.GetSubscription)            // Premium, plan, period and quotas
+		subscriptions.GET("/periods", a.subscriptionController.ListPeriods)        // Periods added by paid orders
+		subscriptions.POST("/renew", a.subscriptionController.RenewSubscription)   // Create an order of the plan and a payment of it
+		subscriptions.POST("/cancel", a.subscriptionController.CancelSubscription) // Keep premium until the period ends, then expire
+		subscriptions.POST("/resume", a.subscriptionController.ResumeSubscription) // Undo a cancellation before the period ends
+	}
+}
+
+// RegisterStorageRoutes sets up the signed upload and download routes of the local storage backend
+func (a *AppRouter) RegisterStorageRoutes(r *gin.RouterGroup) {
+	// Only served when files are kept on local disk instead of S3
+	if a.storageController == nil || !a.storageController.Enabled() {
+		return
+	}
+
+	storage := r.Group("/storage")
+	{
+		storage.PUT("/*key", a.storageController.UploadObject)   // Upload through a signed URL
+		storage.GET("/*key", a.storageController.DownloadObject) // Download through a signed URL
+	}
+}
+
+// RegisterWorkerRoutes sets up the routes used by the external ML workers to pull and complete tasks
+func (a *AppRouter) RegisterWorkerRoutes(r *gin.RouterGroup) {
+	public := r.Group("/workers")
+	{
+		public.POST("/register", a.mlWorkerController.RegisterWorker) // Register a worker with the shared secret
+	}
+
+	protected := r.Group("/workers")
+	protected.Use(a.workerMiddleware.MustAuthWorker()) // Require a worker token
+	{
+		protected.POST("/heartbeat", a.mlWorkerController.Heartbeat)                        // Record that the worker is alive
+		protected.POST("/tasks/claim", a.mlWorkerController.ClaimTask)                      // Lease the next matching task
+		protected.POST("/tasks/:task_id/progress", a.mlWorkerController.ReportTaskProgress) // Report progress and extend the lease
+		protected.POST("/tasks/:task_id/result", a.mlWorkerController.SubmitTaskResult)     // Submit the uploaded result
+		protected.POST("/tasks/:task_id/fail", a.mlWorkerController.FailTask)               // Report a failed task
 	}
 }
 
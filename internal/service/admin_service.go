@@ -0,0 +1,202 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/repo"
+)
+
+var (
+	ErrCannotModerateSelf = errors.New("cannot take this action on yourself")
+	ErrInsufficientRole   = errors.New("cannot take this action on an admin")
+	ErrUserStatusConflict = errors.New("the status of the user does not allow this action")
+)
+
+const (
+	DefaultAdminPageSize = 50
+	MaxAdminPageSize     = 200
+)
+
+// AdminService manages users on behalf of admins and moderators. Every change is recorded in the audit trail.
+type AdminService interface {
+	ListUsers(filter entity.UserFilter, page, pageSize int) ([]entity.User, int, error) // Also returns the number of matching users
+	SuspendUser(actor *entity.User, userID uint64, reason string) (*entity.User, error)
+	UnsuspendUser(actor *entity.User, userID uint64, reason string) (*entity.User, error)
+	ForcePasswordReset(actor *entity.User, userID uint64, reason string) (*entity.User, error)
+	ChangeRole(actor *entity.User, userID uint64, role, reason string) (*entity.User, error)
+	RestoreUser(actor *entity.User, userID uint64, reason string) (*entity.User, error)          // Reverts a soft delete
+	UnlockUser(actor *entity.User, userID uint64, clientIP, reason string) (*entity.User, error) // Lifts the login lockout, and that of the IP if given
+	ListAuditLogs(targetUserID uint64, page, pageSize int) ([]entity.AuditLog, int, error)
+}
+
+type adminService struct {
+	userRepo     repo.UserRepository
+	auditLogRepo repo.AuditLogRepository
+	userService  UserService
+	throttle     LoginThrottle
+}
+
+func NewAdminService(userRepo repo.UserRepository, auditLogRepo repo.AuditLogRepository, userService UserService, throttle LoginThrottle) AdminService {
+	return &adminService{
+		userRepo:     userRepo,
+		auditLogRepo: auditLogRepo,
+		userService:  userService,
+		throttle:     throttle,
+	}
+}
+
+// ListUsers lists one page of the users matching the filter. Pages start at 1;
+// out of range page sizes fall back to DefaultAdminPageSize or are capped at MaxAdminPageSize.
+func (s *adminService) ListUsers(filter entity.UserFilter, page, pageSize int) ([]entity.User, int, error) {
+	page, pageSize = clampAdminPage(page, pageSize)
+
+	total, err := s.userRepo.CountUsers(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	users, err := s.userRepo.ListUsers(filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// SuspendUser stops an available user from using the API until they are unsuspended
+func (s *adminService) SuspendUser(actor *entity.User, userID uint64, reason string) (*entity.User, error) {
+	return s.changeStatus(actor, userID, entity.UserStatusAvailable, entity.UserStatusSuspended, entity.AuditActionUserSuspend, reason)
+}
+
+// UnsuspendUser lets a suspended user use the API again
+func (s *adminService) UnsuspendUser(actor *entity.User, userID uint64, reason string) (*entity.User, error) {
+	return s.changeStatus(actor, userID, entity.UserStatusSuspended, entity.UserStatusAvailable, entity.AuditActionUserUnsuspend, reason)
+}
+
+// RestoreUser makes a soft-deleted user available again
+func (s *adminService) RestoreUser(actor *entity.User, userID uint64, reason string) (*entity.User, error) {
+	return s.changeStatus(actor, userID, entity.UserStatusDeleted, entity.UserStatusAvailable, entity.AuditActionUserRestore, reason)
+}
+
+// ForcePasswordReset requires the user to change their password before they can use the rest of the API
+func (s *adminService) ForcePasswordReset(actor *entity.User, userID uint64, reason string) (*entity.User, error) {
+	user, err := s.moderatedUser(actor, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.SetPasswordResetRequired(userID, true); err != nil {
+		return nil, err
+	}
+	user.PasswordResetRequired = true
+	return user, s.audit(actor, entity.AuditActionUserPasswordReset, userID, reason, "")
+}
+
+// ChangeRole assigns a new role to the user. Admins cannot change their own role, so there is always an admin left.
+func (s *adminService) ChangeRole(actor *entity.User, userID uint64, role, reason string) (*entity.User, error) {
+	user, err := s.moderatedUser(actor, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := user.Role
+	user, err = s.userService.AssignRole(userID, role)
+	if err != nil {
+		return nil, err
+	}
+	return user, s.audit(actor, entity.AuditActionUserRoleChange, userID, reason, fmt.Sprintf("%s -> %s", previous, role))
+}
+
+// UnlockUser forgets the failed logins of the user, so they can log in again at once. With a client IP,
+// the lockout of that IP is lifted too.
+func (s *adminService) UnlockUser(actor *entity.User, userID uint64, clientIP, reason string) (*entity.User, error) {
+	user, err := s.moderatedUser(actor, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.throttle.Clear(user.Email, clientIP); err != nil {
+		return nil, err
+	}
+	details := ""
+	if clientIP != "" {
+		details = "ip " + clientIP
+	}
+	return user, s.audit(actor, entity.AuditActionUserUnlock, userID, reason, details)
+}
+
+// ListAuditLogs lists one page of the audit trail of a user, or of every user when targetUserID is 0, newest first
+func (s *adminService) ListAuditLogs(targetUserID uint64, page, pageSize int) ([]entity.AuditLog, int, error) {
+	page, pageSize = clampAdminPage(page, pageSize)
+
+	total, err := s.auditLogRepo.CountAuditLogs(targetUserID)
+	if err != nil {
+		return nil, 0, err
+	}
+	auditLogs, err := s.auditLogRepo.ListAuditLogs(targetUserID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	return auditLogs, total, nil
+}
+
+// changeStatus moves the user from one status to another and records the action
+func (s *adminService) changeStatus(actor *entity.User, userID uint64, from, to int, action entity.AuditAction, reason string) (*entity.User, error) {
+	user, err := s.moderatedUser(actor, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != from {
+		return nil, ErrUserStatusConflict
+	}
+
+	if err := s.userRepo.UpdateUserStatus(userID, to); err != nil {
+		return nil, err
+	}
+	user.Status = to
+	return user, s.audit(actor, action, userID, reason, "")
+}
+
+// moderatedUser loads the user an action is taken on. Nobody may moderate themselves, and only admins may moderate admins.
+func (s *adminService) moderatedUser(actor *entity.User, userID uint64) (*entity.User, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.ID == actor.ID {
+		return nil, ErrCannotModerateSelf
+	}
+	if user.IsAdmin() && !actor.IsAdmin() {
+		return nil, ErrInsufficientRole
+	}
+	return user, nil
+}
+
+func (s *adminService) audit(actor *entity.User, action entity.AuditAction, userID uint64, reason, details string) error {
+	err := s.auditLogRepo.CreateAuditLog(&entity.AuditLog{
+		ActorID:      actor.ID,
+		Action:       action,
+		TargetUserID: userID,
+		Reason:       reason,
+		Details:      details,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record audit log: %w", err)
+	}
+	return nil
+}
+
+func clampAdminPage(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultAdminPageSize
+	}
+	if pageSize > MaxAdminPageSize {
+		pageSize = MaxAdminPageSize
+	}
+	return page, pageSize
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"mlvt/internal/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAdminService is a mock implementation of the AdminService interface
+type MockAdminService struct {
+	mock.Mock
+}
+
+func (m *MockAdminService) ListUsers(filter entity.UserFilter, page, pageSize int) ([]entity.User, int, error) {
+	args := m.Called(filter, page, pageSize)
+	users, _ := args.Get(0).([]entity.User)
+	return users, args.Int(1), args.Error(2)
+}
+
+func (m *MockAdminService) SuspendUser(actor *entity.User, userID uint64, reason string) (*entity.User, error) {
+	args := m.Called(actor, userID, reason)
+	user, _ := args.Get(0).(*entity.User)
+	return user, args.Error(1)
+}
+
+func (m *MockAdminService) UnsuspendUser(actor *entity.User, userID uint64, reason string) (*entity.User, error) {
+	args := m.Called(actor, userID, reason)
+	user, _ := args.Get(0).(*entity.User)
+	return user, args.Error(1)
+}
+
+func (m *MockAdminService) ForcePasswordReset(actor *entity.User, userID uint64, reason string) (*entity.User, error) {
+	args := m.Called(actor, userID, reason)
+	user, _ := args.Get(0).(*entity.User)
+	return user, args.Error(1)
+}
+
+func (m *MockAdminService) ChangeRole(actor *entity.User, userID uint64, role, reason string) (*entity.User, error) {
+	args := m.Called(actor, userID, role, reason)
+	user, _ := args.Get(0).(*entity.User)
+	return user, args.Error(1)
+}
+
+func (m *MockAdminService) RestoreUser(actor *entity.User, userID uint64, reason string) (*entity.User, error) {
+	args := m.Called(actor, userID, reason)
+	user, _ := args.Get(0).(*entity.User)
+	return user, args.Error(1)
+}
+
+func (m *MockAdminService) UnlockUser(actor *entity.User, userID uint64, clientIP, reason string) (*entity.User, error) {
+	args := m.Called(actor, userID, clientIP, reason)
+	user, _ := args.Get(0).(*entity.User)
+	return user, args.Error(1)
+}
+
+func (m *MockAdminService) ListAuditLogs(targetUserID uint64, page, pageSize int) ([]entity.AuditLog, int, error) {
+	args := m.Called(targetUserID, page, pageSize)
+	auditLogs, _ := args.Get(0).([]entity.AuditLog)
+	return auditLogs, args.Int(1), args.Error(2)
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/repo"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupAdminService(t *testing.T) (AdminService, repo.UserRepository) {
+	db := setupUserTestDB(t)
+	userRepo := repo.NewUserRepo(db)
+	return NewAdminService(userRepo, repo.NewAuditLogRepo(db), NewUserService(userRepo, nil, nil, nil), NewLoginThrottle(repo.NewLoginAttemptRepo(db))), userRepo
+}
+
+// setupUserTestDB creates an in-memory database with the users table and the tables that refer to users
+func setupUserTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	for _, name := range []string{
+		"0001_create_users_table", "0015_add_password_reset_required_to_users", "0016_create_audit_logs_table",
+		"0017_add_token_version_to_users", "0018_create_refresh_tokens_table", "0019_create_user_tokens_table",
+		"0020_add_two_factor_auth", "0021_create_login_attempts_table", "0022_create_api_keys_table",
+		"0023_create_user_identities_table",
+	} {
+		schema, err := os.ReadFile("../../migration/" + name + ".up.sql")
+		require.NoError(t, err)
+		_, err = db.Exec(string(schema))
+		require.NoError(t, err, name)
+	}
+	return db
+}
+
+// createTestUser stores a user with the role and status and returns it with its ID
+func createTestUser(t *testing.T, userRepo repo.UserRepository, name, role string, status int, createdAt time.Time) *entity.User {
+	require.NoError(t, userRepo.CreateUser(&entity.User{
+		FirstName: name, LastName: "Test", UserName: name, Email: name + "@example.com", Password: "hashed",
+		Status: status, Role: role, CreatedAt: createdAt, UpdatedAt: createdAt, EmailVerified: true,
+	}))
+	user, err := userRepo.GetUserByEmail(name + "@example.com")
+	require.NoError(t, err)
+	return user
+}
+
+func TestAdminListUsers(t *testing.T) {
+	adminService, userRepo := setupAdminService(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	createTestUser(t, userRepo, "alice", entity.UserRoleUser, entity.UserStatusAvailable, start)
+	createTestUser(t, userRepo, "bob", entity.UserRoleUser, entity.UserStatusSuspended, start.AddDate(0, 1, 0))
+	createTestUser(t, userRepo, "carol", entity.UserRoleModerator, entity.UserStatusAvailable, start.AddDate(0, 2, 0))
+	createTestUser(t, userRepo, "dave", entity.UserRoleUser, entity.UserStatusAvailable, start.AddDate(0, 3, 0))
+
+	users, total, err := adminService.ListUsers(entity.UserFilter{}, 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	require.Len(t, users, 1)
+	assert.Equal(t, "dave", users[0].UserName)
+
+	users, total, err = adminService.ListUsers(entity.UserFilter{Status: entity.UserStatusAvailable, Role: entity.UserRoleUser}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, "alice", users[0].UserName)
+	assert.Equal(t, "dave", users[1].UserName)
+
+	after, before := start.AddDate(0, 1, 0), start.AddDate(0, 3, 0)
+	users, total, err = adminService.ListUsers(entity.UserFilter{CreatedAfter: &after, CreatedBefore: &before}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, "bob", users[0].UserName)
+	assert.Equal(t, "carol", users[1].UserName)
+
+	premium := true
+	users, total, err = adminService.ListUsers(entity.UserFilter{Premium: &premium}, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, total)
+	assert.Empty(t, users)
+}
+
+func TestAdminModeration(t *testing.T) {
+	adminService, userRepo := setupAdminService(t)
+	now := time.Now()
+	admin := createTestUser(t, userRepo, "admin", entity.UserRoleAdmin, entity.UserStatusAvailable, now)
+	moderator := createTestUser(t, userRepo, "moderator", entity.UserRoleModerator, entity.UserStatusAvailable, now)
+	user := createTestUser(t, userRepo, "user", entity.UserRoleUser, entity.UserStatusAvailable, now)
+
+	suspended, err := adminService.SuspendUser(moderator, user.ID, "spam")
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserStatusSuspended, suspended.Status)
+
+	_, err = adminService.SuspendUser(moderator, user.ID, "spam again")
+	assert.ErrorIs(t, err, ErrUserStatusConflict, "already suspended")
+
+	_, err = adminService.UnsuspendUser(moderator, user.ID, "appeal accepted")
+	require.NoError(t, err)
+
+	reset, err := adminService.ForcePasswordReset(admin, user.ID, "leaked password")
+	require.NoError(t, err)
+	assert.True(t, reset.PasswordResetRequired)
+	stored, err := userRepo.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.PasswordResetRequired)
+	require.NoError(t, userRepo.UpdateUserPassword(user.ID, "new hash"))
+	stored, err = userRepo.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.PasswordResetRequired, "changing the password clears the forced reset")
+
+	changed, err := adminService.ChangeRole(admin, user.ID, entity.UserRoleModerator, "trusted")
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserRoleModerator, changed.Role)
+
+	_, err = adminService.RestoreUser(admin, user.ID, "")
+	assert.ErrorIs(t, err, ErrUserStatusConflict, "not deleted")
+	require.NoError(t, NewUserService(userRepo, nil, nil, nil).DeleteUser(user.ID))
+	restored, err := adminService.RestoreUser(admin, user.ID, "deleted by mistake")
+	require.NoError(t, err)
+	assert.Equal(t, entity.UserStatusAvailable, restored.Status)
+
+	auditLogs, total, err := adminService.ListAuditLogs(user.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 5, total)
+	assert.Equal(t, entity.AuditActionUserRestore, auditLogs[0].Action, "newest first")
+	assert.Equal(t, "deleted by mistake", auditLogs[0].Reason)
+	assert.Equal(t, entity.AuditActionUserRoleChange, auditLogs[1].Action)
+	assert.Equal(t, "User -> Moderator", auditLogs[1].Details)
+	assert.Equal(t, admin.ID, auditLogs[1].ActorID)
+	assert.Equal(t, entity.AuditActionUserSuspend, auditLogs[4].Action)
+	assert.Equal(t, moderator.ID, auditLogs[4].ActorID)
+	assert.Equal(t, "spam", auditLogs[4].Reason)
+}
+
+func TestAdminModerationRules(t *testing.T) {
+	adminService, userRepo := setupAdminService(t)
+	now := time.Now()
+	admin := createTestUser(t, userRepo, "admin", entity.UserRoleAdmin, entity.UserStatusAvailable, now)
+	moderator := createTestUser(t, userRepo, "moderator", entity.UserRoleModerator, entity.UserStatusAvailable, now)
+
+	_, err := adminService.SuspendUser(moderator, admin.ID, "coup")
+	assert.ErrorIs(t, err, ErrInsufficientRole)
+
+	_, err = adminService.ChangeRole(admin, admin.ID, entity.UserRoleUser, "")
+	assert.ErrorIs(t, err, ErrCannotModerateSelf)
+
+	_, err = adminService.ChangeRole(admin, moderator.ID, "Owner", "")
+	assert.ErrorIs(t, err, ErrInvalidRole)
+
+	_, err = adminService.SuspendUser(admin, 99, "")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	_, total, err := adminService.ListAuditLogs(0, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, total, "rejected actions are not recorded")
+}
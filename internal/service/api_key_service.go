@@ -0,0 +1,156 @@
+package service
+
+import (
+	"errors"
+	"mlvt/internal/entity"
+	"mlvt/internal/repo"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidAPIKey       = errors.New("invalid API key")
+	ErrAPIKeyNotFound      = errors.New("API key not found")
+	ErrInvalidAPIKeyScope  = errors.New("invalid API key scope")
+	ErrInvalidAPIKeyExpiry = errors.New("API key expiry must be in the future")
+	ErrTooManyAPIKeys      = errors.New("too many API keys")
+	ErrAPIKeyNameRequired  = errors.New("API key name is required")
+)
+
+const (
+	APIKeyPrefix      = "mlvt_" // Marks API keys, so leaked ones are easy to find in code and logs
+	APIKeyPrefixShown = 12      // Characters of the key kept in the clear, the marker included
+	MaxAPIKeysPerUser = 20
+	// apiKeyTouchInterval limits how often the last-used time is written for a busy key
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyService manages the personal API keys that let scripts act as their user, limited to the key's scopes
+type APIKeyService interface {
+	// CreateAPIKey returns the stored key and its value, which is not shown again. A nil expiry never expires.
+	CreateAPIKey(userID uint64, name string, scopes []entity.APIKeyScope, expiresAt *time.Time) (*entity.APIKey, string, error)
+	ListAPIKeys(userID uint64) ([]entity.APIKey, error)
+	RevokeAPIKey(userID, keyID uint64) error
+	Authenticate(key string) (*entity.User, *entity.APIKey, error) // ErrInvalidAPIKey unless the key and its user are active
+}
+
+type apiKeyService struct {
+	apiKeyRepo repo.APIKeyRepository
+	userRepo   repo.UserRepository
+}
+
+func NewAPIKeyService(apiKeyRepo repo.APIKeyRepository, userRepo repo.UserRepository) APIKeyService {
+	return &apiKeyService{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+	}
+}
+
+// CreateAPIKey generates a key for the user with the scopes, each given once
+func (s *apiKeyService) CreateAPIKey(userID uint64, name string, scopes []entity.APIKeyScope, expiresAt *time.Time) (*entity.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", ErrAPIKeyNameRequired
+	}
+	scopes, err := normalizeAPIKeyScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", ErrInvalidAPIKeyExpiry
+	}
+
+	count, err := s.apiKeyRepo.CountAPIKeys(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if count >= MaxAPIKeysPerUser {
+		return nil, "", ErrTooManyAPIKeys
+	}
+
+	token, err := newOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	value := APIKeyPrefix + token
+
+	key := &entity.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    value[:APIKeyPrefixShown],
+		KeyHash:   hashToken(value),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.apiKeyRepo.CreateAPIKey(key); err != nil {
+		return nil, "", err
+	}
+	return key, value, nil
+}
+
+// ListAPIKeys returns the keys of the user that were not revoked, expired ones included
+func (s *apiKeyService) ListAPIKeys(userID uint64) ([]entity.APIKey, error) {
+	return s.apiKeyRepo.ListAPIKeysByUserID(userID)
+}
+
+// RevokeAPIKey stops a key of the user from working
+func (s *apiKeyService) RevokeAPIKey(userID, keyID uint64) error {
+	revoked, err := s.apiKeyRepo.RevokeAPIKey(userID, keyID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// Authenticate resolves an active key to its user and records that it was used
+func (s *apiKeyService) Authenticate(value string) (*entity.User, *entity.APIKey, error) {
+	if !strings.HasPrefix(value, APIKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	key, err := s.apiKeyRepo.GetAPIKeyByHash(hashToken(value))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if key == nil || !key.IsActive(now) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.userRepo.GetUserByID(key.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil || !isActiveUser(user) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.apiKeyRepo.TouchAPIKey(key.ID, now); err != nil {
+			return nil, nil, err
+		}
+		key.LastUsedAt = &now
+	}
+	return user, key, nil
+}
+
+// normalizeAPIKeyScopes rejects unknown scopes and drops repeated ones
+func normalizeAPIKeyScopes(scopes []entity.APIKeyScope) ([]entity.APIKeyScope, error) {
+	if len(scopes) == 0 {
+		return nil, ErrInvalidAPIKeyScope
+	}
+	seen := make(map[entity.APIKeyScope]bool, len(scopes))
+	normalized := make([]entity.APIKeyScope, 0, len(scopes))
+	for _, scope := range scopes {
+		if !entity.IsValidAPIKeyScope(scope) {
+			return nil, ErrInvalidAPIKeyScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	return normalized, nil
+}
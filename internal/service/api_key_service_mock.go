@@ -0,0 +1,37 @@
+package service
+
+import (
+	"mlvt/internal/entity"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAPIKeyService is a mock implementation of the APIKeyService interface
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) CreateAPIKey(userID uint64, name string, scopes []entity.APIKeyScope, expiresAt *time.Time) (*entity.APIKey, string, error) {
+	args := m.Called(userID, name, scopes, expiresAt)
+	key, _ := args.Get(0).(*entity.APIKey)
+	return key, args.String(1), args.Error(2)
+}
+
+func (m *MockAPIKeyService) ListAPIKeys(userID uint64) ([]entity.APIKey, error) {
+	args := m.Called(userID)
+	keys, _ := args.Get(0).([]entity.APIKey)
+	return keys, args.Error(1)
+}
+
+func (m *MockAPIKeyService) RevokeAPIKey(userID, keyID uint64) error {
+	args := m.Called(userID, keyID)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyService) Authenticate(key string) (*entity.User, *entity.APIKey, error) {
+	args := m.Called(key)
+	user, _ := args.Get(0).(*entity.User)
+	apiKey, _ := args.Get(1).(*entity.APIKey)
+	return user, apiKey, args.Error(2)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupAPIKeyService(t *testing.T) (APIKeyService, repo.UserRepository, *entity.User) {
+	db := setupUserTestDB(t)
+	userRepo := repo.NewUserRepo(db)
+	user := createTestUser(t, userRepo, "john", entity.UserRoleUser, entity.UserStatusAvailable, time.Now())
+	return NewAPIKeyService(repo.NewAPIKeyRepo(db), userRepo), userRepo, user
+}
+
+func TestCreateAndAuthenticateAPIKey(t *testing.T) {
+	apiKeys, _, user := setupAPIKeyService(t)
+
+	scopes := []entity.APIKeyScope{entity.APIKeyScopeVideosWrite, entity.APIKeyScopeAudiosRead, entity.APIKeyScopeVideosWrite}
+	created, value, err := apiKeys.CreateAPIKey(user.ID, " upload script ", scopes, nil)
+	require.NoError(t, err)
+	assert.Regexp(t, `^mlvt_[A-Za-z0-9_-]{43}$`, value)
+	assert.Equal(t, value[:APIKeyPrefixShown], created.Prefix)
+	assert.NotContains(t, created.KeyHash, value, "only the hash is stored")
+	assert.Equal(t, "upload script", created.Name)
+	assert.Equal(t, []entity.APIKeyScope{entity.APIKeyScopeVideosWrite, entity.APIKeyScopeAudiosRead}, created.Scopes)
+
+	authenticated, apiKey, err := apiKeys.Authenticate(value)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, authenticated.ID)
+	assert.True(t, apiKey.HasScope(entity.APIKeyScopeVideosRead), "write includes read")
+	assert.False(t, apiKey.HasScope(entity.APIKeyScopeAudiosWrite))
+
+	listed, err := apiKeys.ListAPIKeys(user.ID)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	require.NotNil(t, listed[0].LastUsedAt)
+	assert.WithinDuration(t, time.Now(), *listed[0].LastUsedAt, time.Minute)
+
+	_, _, err = apiKeys.Authenticate(value + "x")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	_, _, err = apiKeys.Authenticate("not-a-key")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+}
+
+func TestCreateAPIKeyValidation(t *testing.T) {
+	apiKeys, _, user := setupAPIKeyService(t)
+	past := time.Now().Add(-time.Minute)
+
+	_, _, err := apiKeys.CreateAPIKey(user.ID, " ", []entity.APIKeyScope{entity.APIKeyScopeVideosRead}, nil)
+	assert.ErrorIs(t, err, ErrAPIKeyNameRequired)
+	_, _, err = apiKeys.CreateAPIKey(user.ID, "script", nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidAPIKeyScope)
+	_, _, err = apiKeys.CreateAPIKey(user.ID, "script", []entity.APIKeyScope{"user:admin"}, nil)
+	assert.ErrorIs(t, err, ErrInvalidAPIKeyScope)
+	_, _, err = apiKeys.CreateAPIKey(user.ID, "script", []entity.APIKeyScope{entity.APIKeyScopeVideosRead}, &past)
+	assert.ErrorIs(t, err, ErrInvalidAPIKeyExpiry)
+
+	for i := 0; i < MaxAPIKeysPerUser; i++ {
+		_, _, err = apiKeys.CreateAPIKey(user.ID, "script", []entity.APIKeyScope{entity.APIKeyScopeVideosRead}, nil)
+		require.NoError(t, err)
+	}
+	_, _, err = apiKeys.CreateAPIKey(user.ID, "script", []entity.APIKeyScope{entity.APIKeyScopeVideosRead}, nil)
+	assert.ErrorIs(t, err, ErrTooManyAPIKeys)
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	apiKeys, userRepo, user := setupAPIKeyService(t)
+	other := createTestUser(t, userRepo, "jane", entity.UserRoleUser, entity.UserStatusAvailable, time.Now())
+
+	created, value, err := apiKeys.CreateAPIKey(user.ID, "script", []entity.APIKeyScope{entity.APIKeyScopeVideosRead}, nil)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, apiKeys.RevokeAPIKey(other.ID, created.ID), ErrAPIKeyNotFound, "users only revoke their own keys")
+	require.NoError(t, apiKeys.RevokeAPIKey(user.ID, created.ID))
+	assert.ErrorIs(t, apiKeys.RevokeAPIKey(user.ID, created.ID), ErrAPIKeyNotFound)
+
+	_, _, err = apiKeys.Authenticate(value)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	listed, err := apiKeys.ListAPIKeys(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, listed)
+}
+
+func TestAPIKeyRejectedWhenExpiredOrSuspended(t *testing.T) {
+	apiKeys, userRepo, user := setupAPIKeyService(t)
+
+	expiresAt := time.Now().Add(50 * time.Millisecond)
+	_, expiring, err := apiKeys.CreateAPIKey(user.ID, "short-lived", []entity.APIKeyScope{entity.APIKeyScopeVideosRead}, &expiresAt)
+	require.NoError(t, err)
+	_, _, err = apiKeys.Authenticate(expiring)
+	require.NoError(t, err)
+	time.Sleep(60 * time.Millisecond)
+	_, _, err = apiKeys.Authenticate(expiring)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	_, value, err := apiKeys.CreateAPIKey(user.ID, "script", []entity.APIKeyScope{entity.APIKeyScopeVideosRead}, nil)
+	require.NoError(t, err)
+	require.NoError(t, userRepo.UpdateUserStatus(user.ID, entity.UserStatusSuspended))
+	_, _, err = apiKeys.Authenticate(value)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey, "keys stop working while their user is suspended")
+}
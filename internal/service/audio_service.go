@@ -14,9 +14,11 @@ type AudioService interface {
 	GetAudioByID(audioID uint64) (*entity.Audio, string, error)
 	GetAudioByIDAndUserID(audioID, userID uint64) (*entity.Audio, string, error)
 	ListAudiosByUserID(userID uint64) ([]entity.Audio, error)
+	ListAudiosByOrgID(orgID uint64) ([]entity.Audio, error)
 	GetAudioByVideoID(videoID, audioID uint64) (*entity.Audio, string, error)
 	ListAudiosByVideoID(videoID uint64) ([]entity.Audio, error)
 	DeleteAudio(audioID uint64) error
+	FinalizeAudioUpload(audioID uint64) (*entity.Audio, error) // Verifies the stored file and marks the audio ready
 }
 
 type audioService struct {
@@ -32,7 +34,7 @@ func NewAudioService(repo repo.AudioRepository, s3Client aws.S3ClientInterface)
 }
 
 func (s *audioService) GeneratePresignedUploadURL(folder, fileName, fileType string) (string, error) {
-	return s.s3Client.GeneratePresignedURL(folder, fileName, fileType)
+	return s.s3Client.GeneratePresignedUploadURL(folder, fileName, fileType)
 }
 
 func (s *audioService) GeneratePresignedDownloadURL(audioID uint64) (string, error) {
@@ -41,9 +43,12 @@ func (s *audioService) GeneratePresignedDownloadURL(audioID uint64) (string, err
 	if err != nil {
 		return "", fmt.Errorf("could not find audio with ID %d: %v", audioID, err)
 	}
+	if audio == nil {
+		return "", fmt.Errorf("audio not found")
+	}
 
 	// Generate the presigned URL using S3 client
-	presignedURL, err := s.s3Client.GeneratePresignedURL(audio.Folder, audio.FileName, "audio/mpeg")
+	presignedURL, err := s.s3Client.GeneratePresignedDownloadURL(audio.Folder, audio.FileName, aws.DownloadURLOptions{FileName: audio.FileName})
 	if err != nil {
 		return "", fmt.Errorf("failed to generate presigned download URL: %v", err)
 	}
@@ -52,16 +57,49 @@ func (s *audioService) GeneratePresignedDownloadURL(audioID uint64) (string, err
 	return presignedURL, nil
 }
 
+// CreateAudio records an audio whose file still has to be confirmed with FinalizeAudioUpload
 func (s *audioService) CreateAudio(audio *entity.Audio) error {
+	audio.Status = entity.FileStatusPendingUpload
+	audio.FileSize = 0
+	audio.ETag = ""
 	return s.repo.CreateAudio(audio)
 }
 
+// FinalizeAudioUpload checks the uploaded file and records its size, ETag and content type.
+// Audios already marked ready are returned unchanged.
+func (s *audioService) FinalizeAudioUpload(audioID uint64) (*entity.Audio, error) {
+	audio, err := s.repo.GetAudioByID(audioID)
+	if err != nil {
+		return nil, err
+	}
+	if audio == nil {
+		return nil, fmt.Errorf("audio not found")
+	}
+	if audio.Status != entity.FileStatusPendingUpload {
+		return audio, nil
+	}
+
+	info, contentType, err := verifyUploadedFile(s.s3Client, audio.Folder, audio.FileName, audio.ContentType, audioMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	audio.FileSize = info.Size
+	audio.ETag = info.ETag
+	audio.ContentType = contentType
+	audio.Status = entity.FileStatusReady
+	if err := s.repo.UpdateAudioFileInfo(audio); err != nil {
+		return nil, err
+	}
+	return audio, nil
+}
+
 func (s *audioService) GetAudioByID(audioID uint64) (*entity.Audio, string, error) {
 	audio, err := s.repo.GetAudioByID(audioID)
 	if err != nil {
 		return nil, "", err
 	}
-	presignedURL, err := s.s3Client.GeneratePresignedURL(audio.Folder, audio.FileName, "audio/mpeg")
+	presignedURL, err := s.s3Client.GeneratePresignedDownloadURL(audio.Folder, audio.FileName, aws.DownloadURLOptions{})
 	if err != nil {
 		return nil, "", err
 	}
@@ -80,7 +118,7 @@ func (s *audioService) GetAudioByIDAndUserID(audioID, userID uint64) (*entity.Au
 	}
 
 	// Generate the presigned URL using the S3 client
-	presignedURL, err := s.s3Client.GeneratePresignedURL(audio.Folder, audio.FileName, "audio/mpeg")
+	presignedURL, err := s.s3Client.GeneratePresignedDownloadURL(audio.Folder, audio.FileName, aws.DownloadURLOptions{})
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to generate presigned download URL: %v", err)
 	}
@@ -91,12 +129,17 @@ func (s *audioService) ListAudiosByUserID(userID uint64) ([]entity.Audio, error)
 	return s.repo.ListAudiosByUserID(userID)
 }
 
+// ListAudiosByOrgID lists the audios shared with the organization
+func (s *audioService) ListAudiosByOrgID(orgID uint64) ([]entity.Audio, error) {
+	return s.repo.ListAudiosByOrgID(orgID)
+}
+
 func (s *audioService) GetAudioByVideoID(videoID, audioID uint64) (*entity.Audio, string, error) {
 	audio, err := s.repo.GetAudioByVideoID(videoID, audioID)
 	if err != nil {
 		return nil, "", err
 	}
-	presignedURL, err := s.s3Client.GeneratePresignedURL(audio.Folder, audio.FileName, "audio/mpeg")
+	presignedURL, err := s.s3Client.GeneratePresignedDownloadURL(audio.Folder, audio.FileName, aws.DownloadURLOptions{})
 	if err != nil {
 		return nil, "", err
 	}
@@ -1,6 +1,10 @@
 package service
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"mlvt/internal/entity"
 	"mlvt/internal/infra/reason"
@@ -11,55 +15,212 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token") // The refresh token is unknown, expired, already used or revoked
+	ErrEmailNotVerified    = errors.New("email address not verified")
+	ErrInvalidChallenge    = errors.New("invalid or expired two-factor challenge")
+)
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	ChallengeTTL    = 5 * time.Minute // How long a user has to enter their two-factor code after the password
+)
+
+// challengePurpose marks the JWTs that stand for a login waiting for its two-factor code
+const challengePurpose = "2fa"
+
+// TokenPair is issued on login and on every refresh
+type TokenPair struct {
+	UserID       uint64
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time // When the access token expires
+}
+
+// TwoFactorChallenge is issued instead of tokens when a user with two-factor authentication enters their password
+type TwoFactorChallenge struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// LoginResult holds the tokens of a completed login, or the challenge of a login waiting for a two-factor code
+type LoginResult struct {
+	*TokenPair
+	Challenge *TwoFactorChallenge
+}
+
 // AuthServiceInterface defines the methods used by UserService for authentication
 type AuthServiceInterface interface {
-	Login(email, password string) (string, uint64, error)
+	Login(email, password, clientIP string) (*LoginResult, error)
+	VerifyTwoFactor(challengeToken, code, clientIP string) (*TokenPair, error) // Completes a login with a TOTP or recovery code
+	LoginExternal(user *entity.User) (*LoginResult, error)                     // Logs in a user verified by an identity provider
 	GenerateToken(user *entity.User) (string, error)
 	GetUserByToken(tokenStr string) (*entity.User, error)
+	Refresh(refreshToken string) (*TokenPair, error)   // Rotates the refresh token
+	Logout(refreshToken string, everywhere bool) error // Everywhere revokes every token of the user
 }
 
 // AuthService handles user authentication
 type AuthService struct {
-	userRepo  repo.UserRepository
-	secretKey string
+	userRepo         repo.UserRepository
+	refreshTokenRepo repo.RefreshTokenRepository
+	twoFactor        TwoFactorService
+	throttle         LoginThrottle
+	secretKey        string
 }
 
 // NewAuthService creates a new AuthService
-func NewAuthService(userRepo repo.UserRepository, secretKey string) AuthServiceInterface {
+func NewAuthService(userRepo repo.UserRepository, refreshTokenRepo repo.RefreshTokenRepository, twoFactor TwoFactorService, throttle LoginThrottle, secretKey string) AuthServiceInterface {
 	return &AuthService{
-		userRepo:  userRepo,
-		secretKey: secretKey,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		twoFactor:        twoFactor,
+		throttle:         throttle,
+		secretKey:        secretKey,
 	}
 }
 
-// Login authenticates the user and returns a JWT token
-func (s *AuthService) Login(email, password string) (string, uint64, error) {
+// Login authenticates the user and returns an access token and a refresh token. Users with two-factor
+// authentication get a challenge instead, to exchange for the tokens with VerifyTwoFactor.
+// After too many failures for the email or from the client IP it returns a *LoginLockedError for a while.
+func (s *AuthService) Login(email, password, clientIP string) (*LoginResult, error) {
+	if err := s.throttle.Check(email, clientIP); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetUserByEmail(email)
 	if err != nil {
-		return "", 0, errors.New(reason.UserNotFound.Message())
+		return nil, errors.New(reason.UserNotFound.Message())
+	}
+	if user == nil {
+		if err := s.throttle.RecordFailure(email, clientIP); err != nil {
+			return nil, err
+		}
+		return nil, errors.New(reason.UserNotFound.Message())
 	}
 
 	// Compare the hashed password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	if err != nil {
-		return "", 0, errors.New(reason.InvalidCredentials.Message())
+		if err := s.throttle.RecordFailure(email, clientIP); err != nil {
+			return nil, err
+		}
+		return nil, errors.New(reason.InvalidCredentials.Message())
+	}
+	if !isActiveUser(user) {
+		return nil, errors.New(reason.Unauthorized.Message())
+	}
+	if !user.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	// The failures of users with two-factor authentication are kept until the code is right, so entering
+	// the password again does not allow guessing more codes
+	if !user.TOTPEnabled {
+		if err := s.throttle.RecordSuccess(email); err != nil {
+			return nil, err
+		}
+	}
+	return s.completeLogin(user)
+}
+
+// LoginExternal logs in a user whose identity was verified elsewhere, such as by an OpenID Connect provider.
+// Users with two-factor authentication still get a challenge.
+func (s *AuthService) LoginExternal(user *entity.User) (*LoginResult, error) {
+	if !isActiveUser(user) {
+		return nil, errors.New(reason.Unauthorized.Message())
+	}
+	return s.completeLogin(user)
+}
+
+// completeLogin issues the tokens of an authenticated user, or the challenge of a user with two-factor authentication
+func (s *AuthService) completeLogin(user *entity.User) (*LoginResult, error) {
+	if user.TOTPEnabled {
+		challenge, err := s.generateChallenge(user)
+		if err != nil {
+			return nil, errors.New(reason.FailedToGenerateToken.Message())
+		}
+		return &LoginResult{Challenge: challenge}, nil
 	}
 
-	// Generate JWT token
-	token, err := s.GenerateToken(user)
+	tokens, err := s.issueTokens(user)
 	if err != nil {
-		return "", 0, errors.New(reason.FailedToGenerateToken.Message())
+		return nil, errors.New(reason.FailedToGenerateToken.Message())
 	}
+	return &LoginResult{TokenPair: tokens}, nil
+}
 
-	return token, user.ID, nil
+// VerifyTwoFactor exchanges the challenge from Login and a TOTP or recovery code for an access token and a refresh token.
+// Wrong codes count as failed logins of the user.
+func (s *AuthService) VerifyTwoFactor(challengeToken, code, clientIP string) (*TokenPair, error) {
+	claims, err := s.parseToken(challengeToken)
+	if err != nil || claims["purpose"] != challengePurpose {
+		return nil, ErrInvalidChallenge
+	}
+	userID, ok := claims["challengeUserID"].(float64)
+	if !ok {
+		return nil, ErrInvalidChallenge
+	}
+	tokenVersion, ok := claims["tokenVersion"].(float64)
+	if !ok {
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.userRepo.GetUserByID(uint64(userID))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || int(tokenVersion) != user.TokenVersion || !isActiveUser(user) || !user.TOTPEnabled {
+		return nil, ErrInvalidChallenge
+	}
+
+	if err := s.throttle.Check(user.Email, clientIP); err != nil {
+		return nil, err
+	}
+	if err := s.twoFactor.VerifyCode(user, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			if err := s.throttle.RecordFailure(user.Email, clientIP); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+	if err := s.throttle.RecordSuccess(user.Email); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(user)
+}
+
+// generateChallenge creates a short-lived JWT standing for the login of the user until they enter their
+// two-factor code. It has no userID claim, so it is never accepted as an access token.
+func (s *AuthService) generateChallenge(user *entity.User) (*TwoFactorChallenge, error) {
+	expiresAt := time.Now().Add(ChallengeTTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"challengeUserID": user.ID,
+		"tokenVersion":    user.TokenVersion,
+		"purpose":         challengePurpose,
+		"exp":             expiresAt.Unix(),
+	})
+
+	tokenString, err := token.SignedString([]byte(s.secretKey))
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorChallenge{Token: tokenString, ExpiresAt: expiresAt}, nil
 }
 
-// GenerateToken creates a JWT token for a user
+// GenerateToken creates a short-lived JWT access token for a user
 func (s *AuthService) GenerateToken(user *entity.User) (string, error) {
+	return s.generateToken(user, time.Now().Add(AccessTokenTTL))
+}
+
+func (s *AuthService) generateToken(user *entity.User, expiresAt time.Time) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userID": user.ID,
-		"email":  user.Email,
-		"exp":    time.Now().Add(time.Hour * 72).Unix(), // Token valid for 72 hours
+		"userID":       user.ID,
+		"email":        user.Email,
+		"tokenVersion": user.TokenVersion, // Stops working once the version of the user is bumped
+		"exp":          expiresAt.Unix(),
 	})
 
 	tokenString, err := token.SignedString([]byte(s.secretKey))
@@ -70,8 +231,38 @@ func (s *AuthService) GenerateToken(user *entity.User) (string, error) {
 	return tokenString, nil
 }
 
-// GetUserByToken extracts user information from a JWT token
+// GetUserByToken extracts user information from a JWT token, rejecting tokens revoked by a password change,
+// suspension, deletion or logout from every device
 func (s *AuthService) GetUserByToken(tokenStr string) (*entity.User, error) {
+	claims, err := s.parseToken(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Safely assert types from claims
+	userIDFloat, ok := claims["userID"].(float64)
+	if !ok {
+		return nil, errors.New(reason.InvalidUserIDTypeInToken.Message())
+	}
+	userID := uint64(userIDFloat)
+	tokenVersion, ok := claims["tokenVersion"].(float64)
+	if !ok {
+		return nil, errors.New(reason.InvalidTokenClaims.Message())
+	}
+
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil || user == nil {
+		return nil, errors.New(reason.UserNotFound.Message())
+	}
+	if int(tokenVersion) != user.TokenVersion {
+		return nil, errors.New(reason.InvalidToken.Message())
+	}
+
+	return user, nil
+}
+
+// parseToken verifies the signature and expiry of a JWT issued by this service and returns its claims
+func (s *AuthService) parseToken(tokenStr string) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New(reason.UnexpectedSigningMethod.Message())
@@ -84,21 +275,125 @@ func (s *AuthService) GetUserByToken(tokenStr string) (*entity.User, error) {
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
+	if !ok {
 		return nil, errors.New(reason.InvalidTokenClaims.Message())
 	}
+	return claims, nil
+}
 
-	// Safely assert types from claims
-	userIDFloat, ok := claims["userID"].(float64)
-	if !ok {
-		return nil, errors.New(reason.InvalidUserIDTypeInToken.Message())
+// Refresh exchanges a refresh token for a new access token and refresh token. Each refresh token works once;
+// presenting a used one again means it was stolen, so every token of the user is revoked.
+func (s *AuthService) Refresh(refreshToken string) (*TokenPair, error) {
+	stored, user, err := s.lookupRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
 	}
-	userID := uint64(userIDFloat)
 
-	user, err := s.userRepo.GetUserByID(userID)
+	if stored.RevokedAt != nil {
+		if stored.TokenVersion == user.TokenVersion {
+			if err := s.userRepo.IncrementTokenVersion(user.ID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if !isValidRefreshToken(stored, user) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	active, err := s.refreshTokenRepo.RevokeRefreshToken(stored.ID)
 	if err != nil {
-		return nil, errors.New(reason.UserNotFound.Message())
+		return nil, err
+	}
+	if !active {
+		return nil, ErrInvalidRefreshToken // Rotated by a concurrent request
+	}
+	return s.issueTokens(user)
+}
+
+// Logout revokes the refresh token. With everywhere it revokes every access and refresh token of the user.
+// Logging out with a refresh token that was already revoked succeeds.
+func (s *AuthService) Logout(refreshToken string, everywhere bool) error {
+	stored, user, err := s.lookupRefreshToken(refreshToken)
+	if err != nil {
+		return err
 	}
 
-	return user, nil
+	if everywhere {
+		if stored.RevokedAt != nil || !isValidRefreshToken(stored, user) {
+			return ErrInvalidRefreshToken
+		}
+		return s.userRepo.IncrementTokenVersion(user.ID)
+	}
+	_, err = s.refreshTokenRepo.RevokeRefreshToken(stored.ID)
+	return err
+}
+
+// lookupRefreshToken finds a refresh token by its value together with the user it was issued to
+func (s *AuthService) lookupRefreshToken(refreshToken string) (*entity.RefreshToken, *entity.User, error) {
+	stored, err := s.refreshTokenRepo.GetRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil {
+		return nil, nil, err
+	}
+	if stored == nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetUserByID(stored.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	return stored, user, nil
+}
+
+// issueTokens creates an access token and stores a new refresh token for the user
+func (s *AuthService) issueTokens(user *entity.User) (*TokenPair, error) {
+	now := time.Now()
+	expiresAt := now.Add(AccessTokenTTL)
+	accessToken, err := s.generateToken(user, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	err = s.refreshTokenRepo.CreateRefreshToken(&entity.RefreshToken{
+		UserID:       user.ID,
+		TokenHash:    hashToken(refreshToken),
+		TokenVersion: user.TokenVersion,
+		ExpiresAt:    now.Add(RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{UserID: user.ID, AccessToken: accessToken, RefreshToken: refreshToken, ExpiresAt: expiresAt}, nil
+}
+
+// isValidRefreshToken reports whether an unused refresh token has not expired and was not revoked with the other tokens of the user
+func isValidRefreshToken(stored *entity.RefreshToken, user *entity.User) bool {
+	return time.Now().Before(stored.ExpiresAt) && stored.TokenVersion == user.TokenVersion && isActiveUser(user)
+}
+
+func isActiveUser(user *entity.User) bool {
+	return user.Status != entity.UserStatusSuspended && user.Status != entity.UserStatusDeleted
+}
+
+// newOpaqueToken returns 32 random bytes encoded for use in URLs. Only hashToken of it is stored.
+func newOpaqueToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -10,9 +10,22 @@ type MockAuthService struct {
 	mock.Mock
 }
 
-func (m *MockAuthService) Login(email, password string) (string, error) {
-	args := m.Called(email, password)
-	return args.String(0), args.Error(1)
+func (m *MockAuthService) Login(email, password, clientIP string) (*LoginResult, error) {
+	args := m.Called(email, password, clientIP)
+	result, _ := args.Get(0).(*LoginResult)
+	return result, args.Error(1)
+}
+
+func (m *MockAuthService) VerifyTwoFactor(challengeToken, code, clientIP string) (*TokenPair, error) {
+	args := m.Called(challengeToken, code, clientIP)
+	tokens, _ := args.Get(0).(*TokenPair)
+	return tokens, args.Error(1)
+}
+
+func (m *MockAuthService) LoginExternal(user *entity.User) (*LoginResult, error) {
+	args := m.Called(user)
+	result, _ := args.Get(0).(*LoginResult)
+	return result, args.Error(1)
 }
 
 func (m *MockAuthService) GenerateToken(user *entity.User) (string, error) {
@@ -27,3 +40,14 @@ func (m *MockAuthService) GetUserByToken(tokenStr string) (*entity.User, error)
 	}
 	return nil, args.Error(1)
 }
+
+func (m *MockAuthService) Refresh(refreshToken string) (*TokenPair, error) {
+	args := m.Called(refreshToken)
+	tokens, _ := args.Get(0).(*TokenPair)
+	return tokens, args.Error(1)
+}
+
+func (m *MockAuthService) Logout(refreshToken string, everywhere bool) error {
+	args := m.Called(refreshToken, everywhere)
+	return args.Error(0)
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func setupAuthService(t *testing.T) (AuthServiceInterface, repo.UserRepository, *entity.User) {
+	db := setupUserTestDB(t)
+	userRepo := repo.NewUserRepo(db)
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := createTestUser(t, userRepo, "john", entity.UserRoleUser, entity.UserStatusAvailable, time.Now())
+	require.NoError(t, userRepo.UpdateUserPassword(user.ID, string(hashed)))
+
+	throttle := NewLoginThrottle(repo.NewLoginAttemptRepo(db))
+	return NewAuthService(userRepo, repo.NewRefreshTokenRepo(db), NewTwoFactorService(userRepo, repo.NewRecoveryCodeRepo(db), throttle), throttle, "secret"), userRepo, user
+}
+
+func TestLoginAndRefresh(t *testing.T) {
+	authService, _, user := setupAuthService(t)
+
+	tokens, err := authService.Login("john@example.com", "password123", "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, tokens.UserID)
+	assert.WithinDuration(t, time.Now().Add(AccessTokenTTL), tokens.ExpiresAt, time.Minute)
+
+	authenticated, err := authService.GetUserByToken(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, authenticated.ID)
+
+	refreshed, err := authService.Refresh(tokens.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken, "the refresh token is rotated")
+
+	_, err = authService.GetUserByToken(refreshed.AccessToken)
+	assert.NoError(t, err)
+
+	_, err = authService.Refresh("unknown")
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	_, err = authService.Login("john@example.com", "wrong", "127.0.0.1")
+	assert.Error(t, err)
+	_, err = authService.Login("nobody@example.com", "password123", "127.0.0.1")
+	assert.Error(t, err)
+}
+
+func TestRefreshTokenReuseRevokesEveryToken(t *testing.T) {
+	authService, _, _ := setupAuthService(t)
+
+	tokens, err := authService.Login("john@example.com", "password123", "127.0.0.1")
+	require.NoError(t, err)
+	refreshed, err := authService.Refresh(tokens.RefreshToken)
+	require.NoError(t, err)
+
+	_, err = authService.Refresh(tokens.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken, "a used refresh token is rejected")
+
+	_, err = authService.GetUserByToken(refreshed.AccessToken)
+	assert.Error(t, err, "reuse revokes the access tokens")
+	_, err = authService.Refresh(refreshed.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken, "reuse revokes the refresh tokens")
+}
+
+func TestTokenRevocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		revoke func(t *testing.T, userRepo repo.UserRepository, userID uint64)
+	}{
+		{"Password Change", func(t *testing.T, userRepo repo.UserRepository, userID uint64) {
+			require.NoError(t, userRepo.UpdateUserPassword(userID, "new hash"))
+		}},
+		{"Suspension", func(t *testing.T, userRepo repo.UserRepository, userID uint64) {
+			require.NoError(t, userRepo.UpdateUserStatus(userID, entity.UserStatusSuspended))
+		}},
+		{"Delete", func(t *testing.T, userRepo repo.UserRepository, userID uint64) {
+			require.NoError(t, userRepo.SoftDeleteUser(userID))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, userRepo, user := setupAuthService(t)
+			tokens, err := authService.Login("john@example.com", "password123", "127.0.0.1")
+			require.NoError(t, err)
+
+			tt.revoke(t, userRepo, user.ID)
+
+			_, err = authService.GetUserByToken(tokens.AccessToken)
+			assert.Error(t, err)
+			_, err = authService.Refresh(tokens.RefreshToken)
+			assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	authService, _, _ := setupAuthService(t)
+
+	first, err := authService.Login("john@example.com", "password123", "127.0.0.1")
+	require.NoError(t, err)
+	second, err := authService.Login("john@example.com", "password123", "127.0.0.1")
+	require.NoError(t, err)
+
+	require.NoError(t, authService.Logout(first.RefreshToken, false))
+	assert.NoError(t, authService.Logout(first.RefreshToken, false), "logging out twice succeeds")
+
+	_, err = authService.GetUserByToken(second.AccessToken)
+	require.NoError(t, err, "other sessions stay logged in")
+
+	require.NoError(t, authService.Logout(second.RefreshToken, true))
+	_, err = authService.GetUserByToken(second.AccessToken)
+	assert.Error(t, err, "logging out everywhere revokes the access tokens")
+	_, err = authService.Refresh(first.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	assert.ErrorIs(t, authService.Logout("unknown", false), ErrInvalidRefreshToken)
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/repo"
+	"time"
+)
+
+// Retry delays grow exponentially from jobRetryBaseDelay up to jobRetryMaxDelay
+const (
+	jobRetryBaseDelay = 30 * time.Second
+	jobRetryMaxDelay  = time.Hour
+)
+
+var ErrJobNotFound = errors.New("job not found")
+
+// permanentJobError marks a failure that retrying cannot fix
+type permanentJobError struct {
+	err error
+}
+
+func (e *permanentJobError) Error() string { return e.err.Error() }
+func (e *permanentJobError) Unwrap() error { return e.err }
+
+// PermanentJobError wraps err so that Fail dead-letters the job instead of retrying it
+func PermanentJobError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentJobError{err: err}
+}
+
+// IsPermanentJobError reports whether err was wrapped with PermanentJobError
+func IsPermanentJobError(err error) bool {
+	var permanent *permanentJobError
+	return errors.As(err, &permanent)
+}
+
+type JobService interface {
+	Enqueue(job *entity.Job, payload interface{}) (*entity.Job, error)
+	GetJob(jobID uint64) (*entity.Job, error)
+	ListDeadJobs(limit int) ([]entity.Job, error)
+	Lease(owner string, filter repo.JobFilter, visibilityTimeout time.Duration) (*entity.Job, error) // Returns nil when no job is runnable
+	Heartbeat(job *entity.Job, visibilityTimeout time.Duration) error
+	ReportProgress(job *entity.Job, progress int, visibilityTimeout time.Duration) error
+	Complete(job *entity.Job) error
+	Fail(job *entity.Job, cause error) (bool, error) // Returns true when the job was dead-lettered
+	Release(job *entity.Job) error
+}
+
+type jobService struct {
+	repo repo.JobRepository
+}
+
+func NewJobService(repo repo.JobRepository) JobService {
+	return &jobService{repo: repo}
+}
+
+// Enqueue stores the job with the JSON encoded payload. Type is required; empty fields get the repository defaults.
+func (s *jobService) Enqueue(job *entity.Job, payload interface{}) (*entity.Job, error) {
+	return enqueueJob(s.repo, job, payload)
+}
+
+func (s *jobService) GetJob(jobID uint64) (*entity.Job, error) {
+	job, err := s.repo.GetJobByID(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// ListDeadJobs lists dead-lettered jobs, oldest first
+func (s *jobService) ListDeadJobs(limit int) ([]entity.Job, error) {
+	return s.repo.ListJobsByStatus(entity.JobStatusDead, limit)
+}
+
+func (s *jobService) Lease(owner string, filter repo.JobFilter, visibilityTimeout time.Duration) (*entity.Job, error) {
+	return s.repo.LeaseJob(filter, owner, visibilityTimeout)
+}
+
+// Heartbeat extends the lease so the job stays invisible to other workers while it runs
+func (s *jobService) Heartbeat(job *entity.Job, visibilityTimeout time.Duration) error {
+	return s.repo.ExtendJobLease(job.ID, job.LeaseOwner, visibilityTimeout)
+}
+
+// ReportProgress records the progress of the job, clamped to 0-100, and extends its lease like Heartbeat
+func (s *jobService) ReportProgress(job *entity.Job, progress int, visibilityTimeout time.Duration) error {
+	if progress < 0 {
+		progress = 0
+	}
+	if progress > 100 {
+		progress = 100
+	}
+	return s.repo.ReportJobProgress(job.ID, job.LeaseOwner, progress, visibilityTimeout)
+}
+
+func (s *jobService) Complete(job *entity.Job) error {
+	return s.repo.CompleteJob(job.ID, job.LeaseOwner)
+}
+
+// Fail schedules a retry with exponential backoff, or dead-letters the job when the failure is permanent
+// or the job has used all of its attempts
+func (s *jobService) Fail(job *entity.Job, cause error) (bool, error) {
+	message := "unknown error"
+	if cause != nil {
+		message = cause.Error()
+	}
+
+	if IsPermanentJobError(cause) || job.Attempts >= job.MaxAttempts {
+		if err := s.repo.DeadLetterJob(job.ID, job.LeaseOwner, message); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	runAt := time.Now().Add(jobRetryDelay(job.Attempts))
+	return false, s.repo.RetryJob(job.ID, job.LeaseOwner, message, runAt)
+}
+
+// Release hands the job back to the queue without counting the attempt
+func (s *jobService) Release(job *entity.Job) error {
+	return s.repo.ReleaseJob(job.ID, job.LeaseOwner)
+}
+
+// jobRetryDelay doubles the delay with each attempt already made, capped at jobRetryMaxDelay
+func jobRetryDelay(attempts int) time.Duration {
+	delay := jobRetryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= jobRetryMaxDelay {
+			return jobRetryMaxDelay
+		}
+	}
+	return delay
+}
+
+// enqueueJob stores job with the JSON encoded payload. It is shared by services that enqueue work from their own operations.
+func enqueueJob(jobRepo repo.JobRepository, job *entity.Job, payload interface{}) (*entity.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %v", err)
+	}
+
+	job.Payload = string(data)
+	if err := jobRepo.CreateJob(job); err != nil {
+		return nil, fmt.Errorf("failed to enqueue job: %v", err)
+	}
+	return job, nil
+}
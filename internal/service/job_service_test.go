@@ -0,0 +1,80 @@
+package service
+
+import (
+	"errors"
+	"mlvt/internal/entity"
+	"mlvt/internal/repo"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestJobRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, jobRetryDelay(tt.attempts), "attempts=%d", tt.attempts)
+	}
+}
+
+func TestFailJobService(t *testing.T) {
+	jobRepo := new(repo.MockJobRepository)
+	jobService := NewJobService(jobRepo)
+
+	t.Run("Retry With Backoff", func(t *testing.T) {
+		job := &entity.Job{ID: 1, Attempts: 2, MaxAttempts: 5, LeaseOwner: "worker-1"}
+		jobRepo.On("RetryJob", uint64(1), "worker-1", "timeout", mock.MatchedBy(func(runAt time.Time) bool {
+			delay := time.Until(runAt)
+			return delay > 55*time.Second && delay <= time.Minute
+		})).Return(nil).Once()
+
+		dead, err := jobService.Fail(job, errors.New("timeout"))
+		assert.NoError(t, err)
+		assert.False(t, dead)
+	})
+
+	t.Run("Last Attempt", func(t *testing.T) {
+		job := &entity.Job{ID: 2, Attempts: 5, MaxAttempts: 5, LeaseOwner: "worker-1"}
+		jobRepo.On("DeadLetterJob", uint64(2), "worker-1", "timeout").Return(nil).Once()
+
+		dead, err := jobService.Fail(job, errors.New("timeout"))
+		assert.NoError(t, err)
+		assert.True(t, dead)
+	})
+
+	t.Run("Permanent Failure", func(t *testing.T) {
+		job := &entity.Job{ID: 3, Attempts: 1, MaxAttempts: 5, LeaseOwner: "worker-1"}
+		jobRepo.On("DeadLetterJob", uint64(3), "worker-1", "video not found").Return(nil).Once()
+
+		dead, err := jobService.Fail(job, PermanentJobError(ErrVideoNotFound))
+		assert.NoError(t, err)
+		assert.True(t, dead)
+	})
+
+	jobRepo.AssertExpectations(t)
+}
+
+func TestEnqueueJobService(t *testing.T) {
+	jobRepo := new(repo.MockJobRepository)
+	jobService := NewJobService(jobRepo)
+
+	jobRepo.On("CreateJob", mock.MatchedBy(func(job *entity.Job) bool {
+		return job.Type == entity.JobTypeProcessVideo && job.Payload == `{"video_id":9}`
+	})).Return(nil).Once()
+
+	job, err := jobService.Enqueue(&entity.Job{Type: entity.JobTypeProcessVideo}, entity.ProcessVideoPayload{VideoID: 9})
+	assert.NoError(t, err)
+	assert.Equal(t, entity.JobTypeProcessVideo, job.Type)
+	jobRepo.AssertExpectations(t)
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"mlvt/internal/infra/reason"
+	"mlvt/internal/repo"
+	"strings"
+	"time"
+)
+
+// ErrTooManyLoginAttempts matches every *LoginLockedError
+var ErrTooManyLoginAttempts = errors.New("too many login attempts")
+
+// LoginLockedError is returned while logins for an email address or from a client IP are refused
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+// Error returns the localized message, which tells how many seconds to wait
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf(reason.TooManyLoginAttempts.Message(), RetryAfterSeconds(e.RetryAfter))
+}
+
+func (e *LoginLockedError) Is(target error) bool {
+	return target == ErrTooManyLoginAttempts
+}
+
+// RetryAfterSeconds rounds the wait up to whole seconds, as sent in the Retry-After header
+func RetryAfterSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// LoginThrottlePolicy decides how long logins are refused after failures. The first FreeFailures cost nothing;
+// every further failure refuses logins for BaseDelay, doubled each time, until LockoutFailures lock out for
+// LockoutDuration. Failures are forgotten after Window without one.
+type LoginThrottlePolicy struct {
+	FreeFailures    int
+	BaseDelay       time.Duration
+	LockoutFailures int
+	LockoutDuration time.Duration
+	Window          time.Duration // At least LockoutDuration, so a lockout is not forgotten while it lasts
+}
+
+var (
+	// EmailLoginPolicy guards each account against password guessing
+	EmailLoginPolicy = LoginThrottlePolicy{FreeFailures: 3, BaseDelay: time.Second, LockoutFailures: 10, LockoutDuration: 15 * time.Minute, Window: time.Hour}
+	// IPLoginPolicy guards against one client trying many accounts. It is looser, since users may share an IP.
+	IPLoginPolicy = LoginThrottlePolicy{FreeFailures: 20, BaseDelay: time.Second, LockoutFailures: 100, LockoutDuration: 30 * time.Minute, Window: time.Hour}
+)
+
+// delay returns how long logins are refused after the number of failures
+func (p LoginThrottlePolicy) delay(failures int) time.Duration {
+	if failures >= p.LockoutFailures {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeFailures {
+		return 0
+	}
+	delay := p.BaseDelay << (failures - p.FreeFailures - 1)
+	if delay <= 0 || delay > p.LockoutDuration {
+		return p.LockoutDuration
+	}
+	return delay
+}
+
+// LoginThrottle counts failed logins per email address and per client IP and refuses logins for a while
+// after too many. An empty email or client IP is not tracked.
+type LoginThrottle interface {
+	Check(email, clientIP string) error         // *LoginLockedError while either is locked out
+	RecordFailure(email, clientIP string) error // Also for unknown emails, so lockouts do not reveal who is registered
+	RecordSuccess(email string) error           // Forgets the failures of the email; those of the IP stay
+	Clear(email, clientIP string) error         // Lifts the lockouts, for admins
+}
+
+type loginThrottle struct {
+	loginAttemptRepo repo.LoginAttemptRepository
+	emailPolicy      LoginThrottlePolicy
+	ipPolicy         LoginThrottlePolicy
+}
+
+func NewLoginThrottle(loginAttemptRepo repo.LoginAttemptRepository) LoginThrottle {
+	return &loginThrottle{
+		loginAttemptRepo: loginAttemptRepo,
+		emailPolicy:      EmailLoginPolicy,
+		ipPolicy:         IPLoginPolicy,
+	}
+}
+
+// Check returns a *LoginLockedError with the longer wait while the email address or the client IP is locked out
+func (t *loginThrottle) Check(email, clientIP string) error {
+	var retryAfter time.Duration
+	for _, key := range t.keys(email, clientIP) {
+		attempt, err := t.loginAttemptRepo.GetLoginAttempt(key)
+		if err != nil {
+			return err
+		}
+		if attempt == nil || attempt.LockedUntil == nil {
+			continue
+		}
+		if wait := time.Until(*attempt.LockedUntil); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login for the email address and the client IP, locking them out as their policies say
+func (t *loginThrottle) RecordFailure(email, clientIP string) error {
+	now := time.Now()
+	record := func(key string, policy LoginThrottlePolicy) error {
+		if key == "" {
+			return nil
+		}
+		failures, err := t.loginAttemptRepo.RecordLoginFailure(key, now.Add(-policy.Window))
+		if err != nil {
+			return err
+		}
+		if delay := policy.delay(failures); delay > 0 {
+			return t.loginAttemptRepo.LockLoginAttempt(key, now.Add(delay))
+		}
+		return nil
+	}
+
+	if err := record(emailKey(email), t.emailPolicy); err != nil {
+		return err
+	}
+	return record(ipKey(clientIP), t.ipPolicy)
+}
+
+// RecordSuccess forgets the failures of the email address. Those of the client IP stay, so logging in to
+// one account does not let a client go on guessing the passwords of others.
+func (t *loginThrottle) RecordSuccess(email string) error {
+	if key := emailKey(email); key != "" {
+		return t.loginAttemptRepo.DeleteLoginAttempt(key)
+	}
+	return nil
+}
+
+// Clear forgets the failures of the email address and the client IP, lifting their lockouts
+func (t *loginThrottle) Clear(email, clientIP string) error {
+	for _, key := range t.keys(email, clientIP) {
+		if err := t.loginAttemptRepo.DeleteLoginAttempt(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *loginThrottle) keys(email, clientIP string) []string {
+	var keys []string
+	for _, key := range []string{emailKey(email), ipKey(clientIP)} {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// emailKey ignores case and surrounding spaces, so variants of an address share their failures
+func emailKey(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return ""
+	}
+	return "email:" + email
+}
+
+func ipKey(clientIP string) string {
+	if clientIP == "" {
+		return ""
+	}
+	return "ip:" + clientIP
+}
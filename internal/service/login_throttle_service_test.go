@@ -0,0 +1,216 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/pkg/localization"
+	"mlvt/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type throttleFixture struct {
+	throttle  LoginThrottle
+	twoFactor TwoFactorService
+	auth      AuthServiceInterface
+	admin     AdminService
+	userRepo  repo.UserRepository
+	user      *entity.User
+}
+
+// setupLoginThrottle stores a user with the password "password123", with the messages in English
+func setupLoginThrottle(t *testing.T) *throttleFixture {
+	i18nPath := env.EnvConfig.I18NPath
+	env.EnvConfig.I18NPath = "../../i18n/"
+	localization.SetLanguage("en")
+	t.Cleanup(func() { env.EnvConfig.I18NPath = i18nPath })
+
+	db := setupUserTestDB(t)
+	userRepo := repo.NewUserRepo(db)
+	throttle := NewLoginThrottle(repo.NewLoginAttemptRepo(db))
+	twoFactor := NewTwoFactorService(userRepo, repo.NewRecoveryCodeRepo(db), throttle)
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := createTestUser(t, userRepo, "john", entity.UserRoleUser, entity.UserStatusAvailable, time.Now())
+	require.NoError(t, userRepo.UpdateUserPassword(user.ID, string(hashed)))
+
+	return &throttleFixture{
+		throttle:  throttle,
+		twoFactor: twoFactor,
+		auth:      NewAuthService(userRepo, repo.NewRefreshTokenRepo(db), twoFactor, throttle, "secret"),
+		admin:     NewAdminService(userRepo, repo.NewAuditLogRepo(db), NewUserService(userRepo, nil, nil, nil), throttle),
+		userRepo:  userRepo,
+		user:      user,
+	}
+}
+
+// assertLocked checks the error refuses the login for about the duration
+func assertLocked(t *testing.T, err error, retryAfter time.Duration) {
+	t.Helper()
+	require.ErrorIs(t, err, ErrTooManyLoginAttempts)
+	locked, ok := err.(*LoginLockedError)
+	require.True(t, ok)
+	assert.InDelta(t, retryAfter.Seconds(), locked.RetryAfter.Seconds(), 1)
+}
+
+func TestLoginThrottlePolicy(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{9, 32 * time.Second},
+		{10, 15 * time.Minute},
+		{50, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, EmailLoginPolicy.delay(tt.failures), "after %d failures", tt.failures)
+	}
+	assert.Equal(t, IPLoginPolicy.LockoutDuration, IPLoginPolicy.delay(99), "delays are capped at the lockout")
+}
+
+func TestLoginProgressiveDelay(t *testing.T) {
+	f := setupLoginThrottle(t)
+
+	for i := 0; i < EmailLoginPolicy.FreeFailures; i++ {
+		_, err := f.auth.Login("john@example.com", "wrong", "10.0.0.1")
+		assert.NotErrorIs(t, err, ErrTooManyLoginAttempts, "the first failures cost nothing")
+	}
+	_, err := f.auth.Login("john@example.com", "wrong", "10.0.0.1")
+	assert.NotErrorIs(t, err, ErrTooManyLoginAttempts, "the failure that starts the delay still checks the password")
+
+	_, err = f.auth.Login("JOHN@example.com", "password123", "10.0.0.2")
+	assertLocked(t, err, time.Second)
+	assert.Equal(t, "Too many login attempts. Please try again in 1 seconds", err.Error())
+}
+
+func TestLoginLockout(t *testing.T) {
+	f := setupLoginThrottle(t)
+
+	for i := 0; i < EmailLoginPolicy.LockoutFailures; i++ {
+		require.NoError(t, f.throttle.RecordFailure("john@example.com", ""))
+	}
+	_, err := f.auth.Login("john@example.com", "password123", "10.0.0.1")
+	assertLocked(t, err, EmailLoginPolicy.LockoutDuration)
+
+	require.NoError(t, f.throttle.Clear("john@example.com", ""))
+	result, err := f.auth.Login("john@example.com", "password123", "10.0.0.1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.AccessToken)
+}
+
+func TestLoginLockoutByIP(t *testing.T) {
+	f := setupLoginThrottle(t)
+
+	// Unknown emails count too, so one client cannot go through many addresses
+	for i := 0; i < IPLoginPolicy.LockoutFailures; i++ {
+		require.NoError(t, f.throttle.RecordFailure("", "10.0.0.1"))
+	}
+	_, err := f.auth.Login("nobody@example.com", "password123", "10.0.0.1")
+	assertLocked(t, err, IPLoginPolicy.LockoutDuration)
+	_, err = f.auth.Login("john@example.com", "password123", "10.0.0.1")
+	assertLocked(t, err, IPLoginPolicy.LockoutDuration)
+
+	_, err = f.auth.Login("john@example.com", "password123", "10.0.0.2")
+	assert.NoError(t, err, "other clients may still log in")
+}
+
+func TestLoginSuccessForgetsFailures(t *testing.T) {
+	f := setupLoginThrottle(t)
+
+	for i := 0; i < EmailLoginPolicy.FreeFailures; i++ {
+		_, err := f.auth.Login("john@example.com", "wrong", "10.0.0.1")
+		require.Error(t, err)
+	}
+	_, err := f.auth.Login("john@example.com", "password123", "10.0.0.1")
+	require.NoError(t, err)
+
+	_, err = f.auth.Login("john@example.com", "wrong", "10.0.0.1")
+	assert.NotErrorIs(t, err, ErrTooManyLoginAttempts)
+	_, err = f.auth.Login("john@example.com", "password123", "10.0.0.1")
+	assert.NoError(t, err, "the count started over")
+}
+
+func TestTwoFactorCodesAreThrottled(t *testing.T) {
+	f := setupLoginThrottle(t)
+	enrollment, err := f.twoFactor.Enroll(f.user.ID)
+	require.NoError(t, err)
+	_, err = f.twoFactor.Confirm(f.user.ID, totpCode(t, enrollment.Secret, -1))
+	require.NoError(t, err)
+
+	result, err := f.auth.Login("john@example.com", "password123", "10.0.0.1")
+	require.NoError(t, err)
+	for i := 0; i <= EmailLoginPolicy.FreeFailures; i++ {
+		_, err = f.auth.VerifyTwoFactor(result.Challenge.Token, "000000", "10.0.0.1")
+		assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+	}
+
+	_, err = f.auth.VerifyTwoFactor(result.Challenge.Token, totpCode(t, enrollment.Secret, 0), "10.0.0.1")
+	assertLocked(t, err, time.Second)
+	_, err = f.auth.Login("john@example.com", "password123", "10.0.0.1")
+	assertLocked(t, err, time.Second)
+}
+
+func TestTwoFactorSettingsAreThrottled(t *testing.T) {
+	f := setupLoginThrottle(t)
+	enrollment, err := f.twoFactor.Enroll(f.user.ID)
+	require.NoError(t, err)
+	_, err = f.twoFactor.Confirm(f.user.ID, totpCode(t, enrollment.Secret, -1))
+	require.NoError(t, err)
+
+	// A stolen session must not give unlimited guesses either
+	for i := 0; i <= EmailLoginPolicy.FreeFailures; i++ {
+		assert.ErrorIs(t, f.twoFactor.Disable(f.user.ID, "000000", "10.0.0.1"), ErrInvalidTwoFactorCode)
+	}
+
+	err = f.twoFactor.Disable(f.user.ID, totpCode(t, enrollment.Secret, 0), "10.0.0.1")
+	assertLocked(t, err, time.Second)
+	_, err = f.twoFactor.RegenerateRecoveryCodes(f.user.ID, totpCode(t, enrollment.Secret, 0), "10.0.0.1")
+	assertLocked(t, err, time.Second)
+	_, err = f.auth.Login("john@example.com", "password123", "10.0.0.1")
+	assertLocked(t, err, time.Second)
+
+	status, err := f.twoFactor.Status(f.user.ID)
+	require.NoError(t, err)
+	assert.True(t, status.Enabled)
+}
+
+func TestAdminUnlockUser(t *testing.T) {
+	f := setupLoginThrottle(t)
+	moderator := createTestUser(t, f.userRepo, "moderator", entity.UserRoleModerator, entity.UserStatusAvailable, time.Now())
+
+	for i := 0; i < IPLoginPolicy.LockoutFailures; i++ {
+		require.NoError(t, f.throttle.RecordFailure("john@example.com", "10.0.0.1"))
+	}
+
+	_, err := f.admin.UnlockUser(moderator, f.user.ID, "", "forgot password")
+	require.NoError(t, err)
+	_, err = f.auth.Login("john@example.com", "password123", "10.0.0.2")
+	assert.NoError(t, err)
+	_, err = f.auth.Login("john@example.com", "password123", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrTooManyLoginAttempts, "the IP stays locked out")
+
+	_, err = f.admin.UnlockUser(moderator, f.user.ID, "10.0.0.1", "office network")
+	require.NoError(t, err)
+	_, err = f.auth.Login("john@example.com", "password123", "10.0.0.1")
+	assert.NoError(t, err)
+
+	auditLogs, total, err := f.admin.ListAuditLogs(f.user.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, entity.AuditActionUserUnlock, auditLogs[0].Action)
+	assert.Equal(t, "ip 10.0.0.1", auditLogs[0].Details)
+	assert.Equal(t, moderator.ID, auditLogs[0].ActorID)
+
+	_, err = f.admin.UnlockUser(moderator, 99, "", "")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
@@ -0,0 +1,596 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/aws"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/repo"
+	"strings"
+	"time"
+)
+
+// defaultMLTaskVisibilityTimeout is used when JOB_VISIBILITY_TIMEOUT is not set
+const defaultMLTaskVisibilityTimeout = 5 * time.Minute
+
+var (
+	ErrWorkerRegistrationDisabled = errors.New("worker registration is disabled")
+	ErrInvalidRegistrationSecret  = errors.New("invalid worker registration secret")
+	ErrInvalidWorkerCapabilities  = errors.New("a worker needs at least one known task type and one language")
+	ErrWorkerUnauthorized         = errors.New("invalid worker token")
+	ErrInvalidMLTask              = errors.New("invalid ML task")
+	ErrTaskNotFound               = errors.New("task not found")
+	ErrTaskNotLeased              = errors.New("task is not leased by this worker")
+	ErrInvalidTaskResult          = errors.New("invalid task result")
+)
+
+// Where and as what each task type stores its result file
+var mlTaskOutputs = map[entity.MLTaskType]struct {
+	contentType  string
+	extension    string
+	allowedTypes []string
+}{
+	entity.MLTaskSpeechToText: {"text/plain", ".txt", transcriptionMediaTypes},
+	entity.MLTaskTranslation:  {"text/plain", ".txt", transcriptionMediaTypes},
+	entity.MLTaskTextToSpeech: {"audio/mpeg", ".mp3", audioMediaTypes},
+	entity.MLTaskMux:          {"video/mp4", ".mp4", videoMediaTypes},
+}
+
+// MLTaskRequest describes a task to queue for the ML workers
+type MLTaskRequest struct {
+	Type            entity.MLTaskType
+	VideoID         uint64
+	SourceLang      string // Spoken language for stt; defaults to the language of the input transcription for mt and tts, of the input audio for mux
+	TargetLang      string // Language to translate to, mt only
+	TranscriptionID uint64 // Input transcription, mt and tts only
+	AudioID         uint64 // Input audio, mux only
+	PipelineStepID  uint64 // Step of the pipeline the task runs for, if any
+}
+
+// MLTaskAssignment is what a worker receives when it claims a task
+type MLTaskAssignment struct {
+	Task          *entity.Job
+	TaskType      entity.MLTaskType
+	Payload       entity.MLTaskPayload
+	InputURL      string // Download URL of the video for stt and mux, of the input transcription file for mt and tts
+	InputText     string // Text of the input transcription for mt and tts
+	InputAudioURL string // Download URL of the input audio for mux
+	UploadURL     string // Presigned PUT URL the result file must be uploaded to
+}
+
+// MLTaskResult is what a worker reports once the result file is uploaded
+type MLTaskResult struct {
+	Text     string // Transcribed or translated text, stt and mt only
+	Duration int    // Duration of the synthesized audio in seconds, tts only
+}
+
+// MLTaskOutcome holds the record created from a task result
+type MLTaskOutcome struct {
+	Transcription *entity.Transcription
+	Audio         *entity.Audio
+	Step          *entity.PipelineStep // Pipeline step completed by the task, if any
+}
+
+type MLWorkerService interface {
+	RegisterWorker(secret, name string, taskTypes []entity.MLTaskType, languages []string) (*entity.MLWorker, string, error) // Returns the worker and its token
+	AuthenticateWorker(token string) (*entity.MLWorker, error)
+	Heartbeat(worker *entity.MLWorker) error
+	EnqueueTask(req MLTaskRequest) (*entity.Job, error)
+	ClaimTask(worker *entity.MLWorker) (*MLTaskAssignment, error) // Returns nil when no task matches the worker
+	ReportProgress(worker *entity.MLWorker, taskID uint64, progress int) (*entity.Job, error)
+	SubmitResult(worker *entity.MLWorker, taskID uint64, result MLTaskResult) (*MLTaskOutcome, error)
+	FailTask(worker *entity.MLWorker, taskID uint64, message string, retryable bool) (*entity.Job, error)
+}
+
+type mlWorkerService struct {
+	workerRepo        repo.MLWorkerRepository
+	jobService        JobService
+	videoRepo         repo.VideoRepository
+	transcriptionRepo repo.TranscriptionRepository
+	audioRepo         repo.AudioRepository
+	pipelineRepo      repo.PipelineRepository
+	s3Client          aws.S3ClientInterface
+}
+
+func NewMLWorkerService(workerRepo repo.MLWorkerRepository, jobService JobService, videoRepo repo.VideoRepository,
+	transcriptionRepo repo.TranscriptionRepository, audioRepo repo.AudioRepository, pipelineRepo repo.PipelineRepository,
+	s3Client aws.S3ClientInterface) MLWorkerService {
+	return &mlWorkerService{
+		workerRepo:        workerRepo,
+		jobService:        jobService,
+		videoRepo:         videoRepo,
+		transcriptionRepo: transcriptionRepo,
+		audioRepo:         audioRepo,
+		pipelineRepo:      pipelineRepo,
+		s3Client:          s3Client,
+	}
+}
+
+// RegisterWorker creates a worker when secret matches WORKER_REGISTRATION_SECRET.
+// The returned token authenticates the worker from then on; only its hash is stored.
+func (s *mlWorkerService) RegisterWorker(secret, name string, taskTypes []entity.MLTaskType, languages []string) (*entity.MLWorker, string, error) {
+	expected := env.EnvConfig.WorkerRegistrationSecret
+	if expected == "" {
+		return nil, "", ErrWorkerRegistrationDisabled
+	}
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) != 1 {
+		return nil, "", ErrInvalidRegistrationSecret
+	}
+
+	if len(taskTypes) == 0 || len(languages) == 0 {
+		return nil, "", ErrInvalidWorkerCapabilities
+	}
+	for _, taskType := range taskTypes {
+		if !taskType.IsValid() {
+			return nil, "", fmt.Errorf("%w: unknown task type %q", ErrInvalidWorkerCapabilities, taskType)
+		}
+	}
+	normalized := make([]string, 0, len(languages))
+	for _, language := range languages {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(language)))
+	}
+
+	token, err := newWorkerToken()
+	if err != nil {
+		return nil, "", err
+	}
+	worker := &entity.MLWorker{
+		Name:      name,
+		TokenHash: hashWorkerToken(token),
+		TaskTypes: taskTypes,
+		Languages: normalized,
+	}
+	if err := s.workerRepo.CreateMLWorker(worker); err != nil {
+		return nil, "", err
+	}
+	return worker, token, nil
+}
+
+func (s *mlWorkerService) AuthenticateWorker(token string) (*entity.MLWorker, error) {
+	if token == "" {
+		return nil, ErrWorkerUnauthorized
+	}
+	worker, err := s.workerRepo.GetMLWorkerByTokenHash(hashWorkerToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if worker == nil {
+		return nil, ErrWorkerUnauthorized
+	}
+	return worker, nil
+}
+
+// Heartbeat records that the worker is alive
+func (s *mlWorkerService) Heartbeat(worker *entity.MLWorker) error {
+	return s.workerRepo.UpdateMLWorkerLastSeen(worker.ID)
+}
+
+// EnqueueTask queues a task for the workers and decides where its result file is uploaded
+func (s *mlWorkerService) EnqueueTask(req MLTaskRequest) (*entity.Job, error) {
+	output, ok := mlTaskOutputs[req.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown task type %q", ErrInvalidMLTask, req.Type)
+	}
+
+	video, err := s.videoRepo.GetVideoByID(req.VideoID)
+	if err != nil {
+		return nil, err
+	}
+	if video == nil {
+		return nil, ErrVideoNotFound
+	}
+
+	payload := entity.MLTaskPayload{
+		VideoID:        req.VideoID,
+		SourceLang:     strings.ToLower(req.SourceLang),
+		TargetLang:     strings.ToLower(req.TargetLang),
+		PipelineStepID: req.PipelineStepID,
+		ContentType:    output.contentType,
+	}
+	switch req.Type {
+	case entity.MLTaskTranslation, entity.MLTaskTextToSpeech:
+		transcription, err := s.transcriptionRepo.GetTranscriptionByIDAndVideoID(req.TranscriptionID, req.VideoID)
+		if err != nil {
+			return nil, err
+		}
+		if transcription == nil {
+			return nil, fmt.Errorf("%w: transcription %d not found for video %d", ErrInvalidMLTask, req.TranscriptionID, req.VideoID)
+		}
+		payload.TranscriptionID = transcription.ID
+		if payload.SourceLang == "" {
+			payload.SourceLang = strings.ToLower(transcription.Lang)
+		}
+	case entity.MLTaskMux:
+		audio, err := s.audioRepo.GetAudioByVideoID(req.VideoID, req.AudioID)
+		if err != nil {
+			return nil, err
+		}
+		if audio == nil {
+			return nil, fmt.Errorf("%w: audio %d not found for video %d", ErrInvalidMLTask, req.AudioID, req.VideoID)
+		}
+		payload.AudioID = audio.ID
+		if payload.SourceLang == "" {
+			payload.SourceLang = strings.ToLower(audio.Lang)
+		}
+	}
+	if payload.SourceLang == "" {
+		return nil, fmt.Errorf("%w: source language is required", ErrInvalidMLTask)
+	}
+	if req.Type == entity.MLTaskTranslation && (payload.TargetLang == "" || payload.TargetLang == payload.SourceLang) {
+		return nil, fmt.Errorf("%w: a target language different from the source language is required", ErrInvalidMLTask)
+	}
+	if req.Type != entity.MLTaskTranslation {
+		payload.TargetLang = ""
+	}
+
+	resultLang := payload.SourceLang
+	payload.Folder = env.EnvConfig.TranscriptionsFolder
+	switch req.Type {
+	case entity.MLTaskTextToSpeech:
+		payload.Folder = env.EnvConfig.AudioFolder
+	case entity.MLTaskMux:
+		payload.Folder = env.EnvConfig.VideosFolder
+	}
+	if req.Type == entity.MLTaskTranslation {
+		resultLang = payload.TargetLang
+	}
+	payload.FileName = fmt.Sprintf("video_%d_%s_%s_%d%s", req.VideoID, req.Type, resultLang, time.Now().UnixNano(), output.extension)
+
+	job := &entity.Job{Type: req.Type.JobType(), SourceLang: payload.SourceLang, TargetLang: payload.TargetLang}
+	return s.jobService.Enqueue(job, payload)
+}
+
+// ClaimTask leases the next task the worker supports and marks its video as processing
+func (s *mlWorkerService) ClaimTask(worker *entity.MLWorker) (*MLTaskAssignment, error) {
+	if err := s.workerRepo.UpdateMLWorkerLastSeen(worker.ID); err != nil {
+		log.Warnf("Failed to record heartbeat of worker %d: %v", worker.ID, err)
+	}
+
+	filter := repo.JobFilter{Languages: worker.Languages}
+	for _, taskType := range worker.TaskTypes {
+		filter.Types = append(filter.Types, taskType.JobType())
+	}
+	job, err := s.jobService.Lease(workerLeaseOwner(worker), filter, mlTaskVisibilityTimeout())
+	if err != nil || job == nil {
+		return nil, err
+	}
+
+	assignment, err := s.prepareAssignment(job)
+	if err != nil {
+		// The task cannot be started by any worker, so it is dead-lettered instead of handed out again
+		if errors.Is(err, ErrVideoNotFound) || errors.Is(err, ErrInvalidMLTask) {
+			s.failTask(job, PermanentJobError(err))
+			return nil, nil
+		}
+		if releaseErr := s.jobService.Release(job); releaseErr != nil {
+			log.Errorf("Failed to release task %d: %v", job.ID, releaseErr)
+		}
+		return nil, err
+	}
+
+	if err := s.videoRepo.UpdateVideoStatus(assignment.Payload.VideoID, entity.StatusProcessing); err != nil {
+		log.Warnf("Failed to mark video %d as processing: %v", assignment.Payload.VideoID, err)
+	}
+	return assignment, nil
+}
+
+// prepareAssignment loads the input of a leased task and presigns the upload of its result
+func (s *mlWorkerService) prepareAssignment(job *entity.Job) (*MLTaskAssignment, error) {
+	taskType, payload, err := decodeMLTask(job)
+	if err != nil {
+		return nil, err
+	}
+
+	video, err := s.videoRepo.GetVideoByID(payload.VideoID)
+	if err != nil {
+		return nil, err
+	}
+	if video == nil {
+		return nil, ErrVideoNotFound
+	}
+
+	assignment := &MLTaskAssignment{Task: job, TaskType: taskType, Payload: *payload}
+	switch taskType {
+	case entity.MLTaskSpeechToText, entity.MLTaskMux:
+		assignment.InputURL, err = s.s3Client.GeneratePresignedDownloadURL(video.Folder, video.FileName, aws.DownloadURLOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate presigned video URL: %v", err)
+		}
+	default:
+		transcription, err := s.transcriptionRepo.GetTranscriptionByID(payload.TranscriptionID)
+		if err != nil {
+			return nil, err
+		}
+		if transcription == nil {
+			return nil, fmt.Errorf("%w: transcription %d no longer exists", ErrInvalidMLTask, payload.TranscriptionID)
+		}
+		assignment.InputText = transcription.Text
+		if transcription.FileName != "" {
+			assignment.InputURL, err = s.s3Client.GeneratePresignedDownloadURL(transcription.Folder, transcription.FileName, aws.DownloadURLOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate presigned transcription URL: %v", err)
+			}
+		}
+	}
+	if taskType == entity.MLTaskMux {
+		audio, err := s.audioRepo.GetAudioByID(payload.AudioID)
+		if err != nil {
+			return nil, err
+		}
+		if audio == nil {
+			return nil, fmt.Errorf("%w: audio %d no longer exists", ErrInvalidMLTask, payload.AudioID)
+		}
+		assignment.InputAudioURL, err = s.s3Client.GeneratePresignedDownloadURL(audio.Folder, audio.FileName, aws.DownloadURLOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate presigned audio URL: %v", err)
+		}
+	}
+
+	assignment.UploadURL, err = s.s3Client.GeneratePresignedUploadURL(payload.Folder, payload.FileName, payload.ContentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate presigned upload URL: %v", err)
+	}
+	return assignment, nil
+}
+
+// ReportProgress records the progress of a task and extends its lease
+func (s *mlWorkerService) ReportProgress(worker *entity.MLWorker, taskID uint64, progress int) (*entity.Job, error) {
+	job, err := s.getLeasedTask(worker, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.jobService.ReportProgress(job, progress, mlTaskVisibilityTimeout()); err != nil {
+		return nil, leaseError(err)
+	}
+	return s.jobService.GetJob(taskID)
+}
+
+// SubmitResult checks the uploaded result file, records it as a transcription or audio of the video and
+// completes the task. The video is marked as success, unless the task belongs to a pipeline which then advances instead.
+func (s *mlWorkerService) SubmitResult(worker *entity.MLWorker, taskID uint64, result MLTaskResult) (*MLTaskOutcome, error) {
+	job, err := s.getLeasedTask(worker, taskID)
+	if err != nil {
+		return nil, err
+	}
+	taskType, payload, err := decodeMLTask(job)
+	if err != nil {
+		return nil, err
+	}
+	textual := taskType == entity.MLTaskSpeechToText || taskType == entity.MLTaskTranslation
+	if textual && strings.TrimSpace(result.Text) == "" {
+		return nil, fmt.Errorf("%w: text is required", ErrInvalidTaskResult)
+	}
+	if taskType == entity.MLTaskTextToSpeech && result.Duration < 0 {
+		return nil, fmt.Errorf("%w: duration cannot be negative", ErrInvalidTaskResult)
+	}
+
+	video, err := s.videoRepo.GetVideoByID(payload.VideoID)
+	if err != nil {
+		return nil, err
+	}
+	if video == nil {
+		s.failTask(job, PermanentJobError(ErrVideoNotFound))
+		return nil, ErrVideoNotFound
+	}
+
+	info, contentType, err := verifyUploadedFile(s.s3Client, payload.Folder, payload.FileName, payload.ContentType, mlTaskOutputs[taskType].allowedTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := &MLTaskOutcome{}
+	switch taskType {
+	case entity.MLTaskMux:
+		// The dubbed video is only recorded on its pipeline step
+	case entity.MLTaskTextToSpeech:
+		outcome.Audio = &entity.Audio{
+			VideoID:     video.ID,
+			UserID:      video.UserID,
+			OrgID:       video.OrgID, // Shared like the video
+			Duration:    result.Duration,
+			Lang:        payload.SourceLang,
+			Folder:      payload.Folder,
+			FileName:    payload.FileName,
+			FileSize:    info.Size,
+			ETag:        info.ETag,
+			ContentType: contentType,
+			Status:      entity.FileStatusReady,
+		}
+		err = s.audioRepo.CreateAudio(outcome.Audio)
+	default:
+		lang := payload.SourceLang
+		if taskType == entity.MLTaskTranslation {
+			lang = payload.TargetLang
+		}
+		outcome.Transcription = &entity.Transcription{
+			VideoID:     video.ID,
+			UserID:      video.UserID,
+			OrgID:       video.OrgID, // Shared like the video
+			Text:        result.Text,
+			Lang:        lang,
+			Folder:      payload.Folder,
+			FileName:    payload.FileName,
+			FileSize:    info.Size,
+			ETag:        info.ETag,
+			ContentType: contentType,
+			Status:      entity.FileStatusReady,
+		}
+		err = s.transcriptionRepo.CreateTranscription(outcome.Transcription)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record task result: %v", err)
+	}
+
+	if payload.PipelineStepID != 0 {
+		outcome.Step, err = s.completePipelineStep(job, payload, outcome)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.jobService.Complete(job); err != nil {
+		return nil, leaseError(err)
+	}
+	if payload.PipelineStepID != 0 {
+		s.advancePipeline(outcome.Step.PipelineID)
+		return outcome, nil
+	}
+	if err := s.videoRepo.UpdateVideoStatus(video.ID, entity.StatusSuccess); err != nil {
+		log.Warnf("Failed to mark video %d as success: %v", video.ID, err)
+	}
+	return outcome, nil
+}
+
+// completePipelineStep records the outputs of the task on its pipeline step
+func (s *mlWorkerService) completePipelineStep(job *entity.Job, payload *entity.MLTaskPayload, outcome *MLTaskOutcome) (*entity.PipelineStep, error) {
+	step, err := s.pipelineRepo.GetPipelineStepByID(payload.PipelineStepID)
+	if err != nil {
+		return nil, err
+	}
+	if step == nil {
+		return nil, fmt.Errorf("pipeline step %d not found", payload.PipelineStepID)
+	}
+
+	from := step.Status
+	step.Status = entity.PipelineStepSucceeded
+	step.JobID = job.ID
+	step.Error = ""
+	switch {
+	case outcome.Transcription != nil:
+		step.OutputTranscriptionID = outcome.Transcription.ID
+	case outcome.Audio != nil:
+		step.OutputAudioID = outcome.Audio.ID
+	default:
+		step.OutputFolder = payload.Folder
+		step.OutputFileName = payload.FileName
+	}
+	if err := s.pipelineRepo.UpdatePipelineStep(step, from); err != nil {
+		return nil, fmt.Errorf("failed to record pipeline step %d: %v", step.ID, err)
+	}
+	return step, nil
+}
+
+// advancePipeline queues a job moving the pipeline on to the steps that were waiting for a finished task
+func (s *mlWorkerService) advancePipeline(pipelineID uint64) {
+	_, err := s.jobService.Enqueue(&entity.Job{Type: entity.JobTypeAdvancePipeline}, entity.PipelinePayload{PipelineID: pipelineID})
+	if err != nil {
+		log.Errorf("Failed to queue advancing pipeline %d: %v", pipelineID, err)
+	}
+}
+
+// FailTask records a failure reported by the worker. Non-retryable failures and failures on the last
+// attempt dead-letter the task and mark its video as failed.
+func (s *mlWorkerService) FailTask(worker *entity.MLWorker, taskID uint64, message string, retryable bool) (*entity.Job, error) {
+	job, err := s.getLeasedTask(worker, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	cause := errors.New(message)
+	if !retryable {
+		cause = PermanentJobError(cause)
+	}
+	if err := s.failTask(job, cause); err != nil {
+		return nil, leaseError(err)
+	}
+	return s.jobService.GetJob(taskID)
+}
+
+// failTask fails the job and, once it is dead-lettered, marks the video failed or advances its pipeline
+func (s *mlWorkerService) failTask(job *entity.Job, cause error) error {
+	dead, err := s.jobService.Fail(job, cause)
+	if err != nil {
+		log.Errorf("Failed to record failure of task %d: %v", job.ID, err)
+		return err
+	}
+	if !dead || errors.Is(cause, ErrVideoNotFound) {
+		return nil
+	}
+
+	var payload entity.MLTaskPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return nil
+	}
+	if payload.PipelineStepID != 0 {
+		step, err := s.pipelineRepo.GetPipelineStepByID(payload.PipelineStepID)
+		if err != nil || step == nil {
+			log.Errorf("Failed to load pipeline step %d of task %d: %v", payload.PipelineStepID, job.ID, err)
+			return nil
+		}
+		s.advancePipeline(step.PipelineID)
+		return nil
+	}
+	if err := s.videoRepo.UpdateVideoStatus(payload.VideoID, entity.StatusFailed); err != nil {
+		log.Warnf("Failed to mark video %d as failed: %v", payload.VideoID, err)
+	}
+	return nil
+}
+
+// getLeasedTask loads an ML task and checks the worker holds its lease
+func (s *mlWorkerService) getLeasedTask(worker *entity.MLWorker, taskID uint64) (*entity.Job, error) {
+	job, err := s.jobService.GetJob(taskID)
+	if errors.Is(err, ErrJobNotFound) {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(string(job.Type), "ml.") {
+		return nil, ErrTaskNotFound
+	}
+	if job.Status != entity.JobStatusRunning || job.LeaseOwner != workerLeaseOwner(worker) {
+		return nil, ErrTaskNotLeased
+	}
+	return job, nil
+}
+
+func decodeMLTask(job *entity.Job) (entity.MLTaskType, *entity.MLTaskPayload, error) {
+	taskType := entity.MLTaskType(strings.TrimPrefix(string(job.Type), "ml."))
+	if !taskType.IsValid() {
+		return "", nil, fmt.Errorf("%w: unknown job type %s", ErrInvalidMLTask, job.Type)
+	}
+	var payload entity.MLTaskPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return "", nil, fmt.Errorf("%w: invalid payload: %v", ErrInvalidMLTask, err)
+	}
+	return taskType, &payload, nil
+}
+
+// leaseError turns a lost lease into ErrTaskNotLeased
+func leaseError(err error) error {
+	if errors.Is(err, repo.ErrJobLeaseLost) {
+		return ErrTaskNotLeased
+	}
+	return err
+}
+
+func workerLeaseOwner(worker *entity.MLWorker) string {
+	return fmt.Sprintf("ml-worker-%d", worker.ID)
+}
+
+func mlTaskVisibilityTimeout() time.Duration {
+	if env.EnvConfig.JobVisibilityTimeout > 0 {
+		return env.EnvConfig.JobVisibilityTimeout
+	}
+	return defaultMLTaskVisibilityTimeout
+}
+
+func newWorkerToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate worker token: %v", err)
+	}
+	return hex.EncodeToString(token), nil
+}
+
+func hashWorkerToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,363 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/oidc"
+	"mlvt/internal/repo"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrOIDCProviderNotFound    = errors.New("unknown identity provider")
+	ErrInvalidOIDCState        = errors.New("invalid or expired login state")
+	ErrOIDCLoginFailed         = errors.New("login with the identity provider failed")
+	ErrOIDCEmailNotVerified    = errors.New("the identity provider did not verify the email address")
+	ErrOIDCProviderUnavailable = errors.New("identity provider unavailable")
+)
+
+const (
+	OIDCStateTTL = 10 * time.Minute // How long a user has to log in at the provider
+	// oidcStatePurpose marks the JWTs that carry the state of a login at a provider
+	oidcStatePurpose = "oidc"
+	oidcHTTPTimeout  = 10 * time.Second
+)
+
+// usernameUnsafe matches what is dropped from an email address to make a username
+var usernameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// OIDCAuthorization starts a login at a provider. The state token is kept by the browser, in a cookie, until
+// the provider redirects back, so only the browser that started the login can finish it.
+type OIDCAuthorization struct {
+	URL        string // The login page of the provider
+	StateToken string
+	ExpiresAt  time.Time
+}
+
+// OIDCService logs users in through OpenID Connect providers, linking the provider account to the user with
+// the same verified email address or creating one
+type OIDCService interface {
+	Providers() []string // Names of the configured providers
+	Authorize(providerName string) (*OIDCAuthorization, error)
+	// Callback exchanges the code for an ID token and logs its user in, with a challenge for two-factor users
+	Callback(providerName, code, state, stateToken string) (*LoginResult, error)
+}
+
+type oidcService struct {
+	userRepo     repo.UserRepository
+	identityRepo repo.UserIdentityRepository
+	auth         AuthServiceInterface
+	secretKey    string
+	configs      map[string]env.OIDCProvider
+	names        []string
+	client       *http.Client
+
+	mu        sync.Mutex
+	providers map[string]*oidc.Provider // Discovered on first use, so the server starts while a provider is down
+}
+
+func NewOIDCService(userRepo repo.UserRepository, identityRepo repo.UserIdentityRepository, auth AuthServiceInterface, secretKey string) OIDCService {
+	s := &oidcService{
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		auth:         auth,
+		secretKey:    secretKey,
+		configs:      make(map[string]env.OIDCProvider),
+		client:       &http.Client{Timeout: oidcHTTPTimeout},
+		providers:    make(map[string]*oidc.Provider),
+	}
+	for _, config := range env.EnvConfig.OIDCProviders {
+		s.configs[config.Name] = config
+		s.names = append(s.names, config.Name)
+	}
+	return s
+}
+
+// Providers returns the names of the configured providers in configuration order
+func (s *oidcService) Providers() []string {
+	return s.names
+}
+
+// Authorize returns the login page of the provider with a new state, nonce and PKCE code verifier, which
+// the state token carries signed until the callback
+func (s *oidcService) Authorize(providerName string) (*OIDCAuthorization, error) {
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := oidc.NewState()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := oidc.NewState()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(OIDCStateTTL)
+	stateToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose":  oidcStatePurpose,
+		"provider": providerName,
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+		"exp":      expiresAt.Unix(),
+	}).SignedString([]byte(s.secretKey))
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCAuthorization{
+		URL:        provider.AuthCodeURL(state, nonce, verifier),
+		StateToken: stateToken,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+// Callback checks the state against the state token of the browser, exchanges the code and logs in the user
+// of the ID token
+func (s *oidcService) Callback(providerName, code, state, stateToken string) (*LoginResult, error) {
+	nonce, verifier, err := s.parseStateToken(stateToken, providerName, state)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcHTTPTimeout)
+	defer cancel()
+	token, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+
+	user, err := s.resolveUser(providerName, claims)
+	if err != nil {
+		return nil, err
+	}
+	if !isActiveUser(user) {
+		return nil, ErrOIDCLoginFailed
+	}
+	return s.auth.LoginExternal(user)
+}
+
+// resolveUser returns the user linked to the provider account. An account not linked yet is linked to the
+// user with its verified email address, or to a new user.
+func (s *oidcService) resolveUser(providerName string, claims *oidc.Claims) (*entity.User, error) {
+	now := time.Now()
+	identity, err := s.identityRepo.GetUserIdentity(providerName, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		if err := s.identityRepo.TouchUserIdentity(identity.ID, now); err != nil {
+			return nil, err
+		}
+		user, err := s.userRepo.GetUserByID(identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, ErrOIDCLoginFailed
+		}
+		return user, nil
+	}
+
+	// Linking by email is only safe when the provider vouches for the address
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+	user, err := s.userRepo.GetUserByEmail(claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		if user, err = s.createUser(claims); err != nil {
+			return nil, err
+		}
+	} else if !user.EmailVerified {
+		// Whoever registered the address without verifying it may not be its owner, so their password and
+		// sessions stop working before the owner gets the account
+		if err := s.takeOverUnverifiedUser(user); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.identityRepo.CreateUserIdentity(&entity.UserIdentity{
+		UserID:      user.ID,
+		Provider:    providerName,
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		LastLoginAt: &now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// createUser registers a user with a verified email address and a random password. They log in through the
+// provider, or set a password with the forgot-password flow.
+func (s *oidcService) createUser(claims *oidc.Claims) (*entity.User, error) {
+	password, err := unusablePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	firstName, lastName := claims.GivenName, claims.FamilyName
+	if firstName == "" && lastName == "" {
+		firstName, lastName, _ = strings.Cut(strings.TrimSpace(claims.Name), " ")
+	}
+	localPart, _, _ := strings.Cut(claims.Email, "@")
+	if firstName == "" {
+		firstName = localPart
+	}
+
+	now := time.Now()
+	user := &entity.User{
+		FirstName:     firstName,
+		LastName:      lastName,
+		Email:         claims.Email,
+		Password:      password,
+		Status:        entity.UserStatusAvailable,
+		Role:          entity.UserRoleUser,
+		EmailVerified: true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	// Usernames are unique, so a taken one gets a random suffix
+	base := usernameUnsafe.ReplaceAllString(localPart, "")
+	if base == "" {
+		base = "user"
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		user.UserName = base
+		if attempt > 0 {
+			suffix := make([]byte, 3)
+			if _, err = rand.Read(suffix); err != nil {
+				return nil, err
+			}
+			user.UserName = base + "-" + hex.EncodeToString(suffix)
+		}
+		if err = s.userRepo.CreateUser(user); err == nil {
+			return user, nil
+		}
+	}
+	return nil, err
+}
+
+// takeOverUnverifiedUser replaces the password of the user, revoking their tokens, and marks the email verified
+func (s *oidcService) takeOverUnverifiedUser(user *entity.User) error {
+	password, err := unusablePassword()
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdateUserPassword(user.ID, password); err != nil {
+		return err
+	}
+	if err := s.userRepo.MarkEmailVerified(user.ID); err != nil {
+		return err
+	}
+	log.Infof("Reset the password of user %d, whose unverified email address was verified by an OpenID Connect login", user.ID)
+
+	updated, err := s.userRepo.GetUserByID(user.ID)
+	if err != nil {
+		return err
+	}
+	*user = *updated
+	return nil
+}
+
+// parseStateToken checks the state token was issued for the provider and the state, and returns the nonce
+// and code verifier it carries
+func (s *oidcService) parseStateToken(stateToken, providerName, state string) (string, string, error) {
+	token, err := jwt.Parse(stateToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidOIDCState
+		}
+		return []byte(s.secretKey), nil
+	})
+	if err != nil || !token.Valid {
+		return "", "", ErrInvalidOIDCState
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != oidcStatePurpose || claims["provider"] != providerName {
+		return "", "", ErrInvalidOIDCState
+	}
+
+	expected, _ := claims["state"].(string)
+	nonce, _ := claims["nonce"].(string)
+	verifier, _ := claims["verifier"].(string)
+	if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(state)) != 1 {
+		return "", "", ErrInvalidOIDCState
+	}
+	return nonce, verifier, nil
+}
+
+// provider returns the discovered provider, discovering it on first use
+func (s *oidcService) provider(name string) (*oidc.Provider, error) {
+	config, ok := s.configs[name]
+	if !ok {
+		return nil, ErrOIDCProviderNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if provider, ok := s.providers[name]; ok {
+		return provider, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcHTTPTimeout)
+	defer cancel()
+	provider, err := oidc.Discover(ctx, s.client, oidc.Config{
+		Issuer:       config.Issuer,
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		RedirectURL:  config.RedirectURL,
+		Scopes:       config.Scopes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCProviderUnavailable, err)
+	}
+	s.providers[name] = provider
+	return provider, nil
+}
+
+// unusablePassword returns the bcrypt hash of a random password nobody knows
+func unusablePassword() (string, error) {
+	password, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
@@ -0,0 +1,26 @@
+package service
+
+import "github.com/stretchr/testify/mock"
+
+// MockOIDCService is a mock implementation of the OIDCService interface
+type MockOIDCService struct {
+	mock.Mock
+}
+
+func (m *MockOIDCService) Providers() []string {
+	args := m.Called()
+	providers, _ := args.Get(0).([]string)
+	return providers
+}
+
+func (m *MockOIDCService) Authorize(providerName string) (*OIDCAuthorization, error) {
+	args := m.Called(providerName)
+	authorization, _ := args.Get(0).(*OIDCAuthorization)
+	return authorization, args.Error(1)
+}
+
+func (m *MockOIDCService) Callback(providerName, code, state, stateToken string) (*LoginResult, error) {
+	args := m.Called(providerName, code, state, stateToken)
+	result, _ := args.Get(0).(*LoginResult)
+	return result, args.Error(1)
+}
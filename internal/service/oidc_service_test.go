@@ -0,0 +1,194 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/pkg/oidc/oidctest"
+	"mlvt/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type oidcFixture struct {
+	oidc      OIDCService
+	auth      AuthServiceInterface
+	twoFactor TwoFactorService
+	provider  *oidctest.Provider
+	userRepo  repo.UserRepository
+}
+
+// setupOIDCService configures the stand-in provider as "test"
+func setupOIDCService(t *testing.T) *oidcFixture {
+	provider := oidctest.NewProvider("mlvt", "client secret")
+	t.Cleanup(provider.Close)
+
+	providers := env.EnvConfig.OIDCProviders
+	env.EnvConfig.OIDCProviders = []env.OIDCProvider{{
+		Name:         "test",
+		Issuer:       provider.Issuer,
+		ClientID:     "mlvt",
+		ClientSecret: "client secret",
+		RedirectURL:  "http://localhost:8080/api/users/oidc/test/callback",
+	}}
+	t.Cleanup(func() { env.EnvConfig.OIDCProviders = providers })
+
+	db := setupUserTestDB(t)
+	userRepo := repo.NewUserRepo(db)
+	throttle := NewLoginThrottle(repo.NewLoginAttemptRepo(db))
+	twoFactor := NewTwoFactorService(userRepo, repo.NewRecoveryCodeRepo(db), throttle)
+	auth := NewAuthService(userRepo, repo.NewRefreshTokenRepo(db), twoFactor, throttle, "secret")
+	return &oidcFixture{
+		oidc:      NewOIDCService(userRepo, repo.NewUserIdentityRepo(db), auth, "secret"),
+		auth:      auth,
+		twoFactor: twoFactor,
+		provider:  provider,
+		userRepo:  userRepo,
+	}
+}
+
+// login goes through the provider's login page and the callback as the browser would
+func (f *oidcFixture) login(t *testing.T) (*LoginResult, error) {
+	authorization, err := f.oidc.Authorize("test")
+	require.NoError(t, err)
+	callback, err := f.provider.Authorize(authorization.URL)
+	require.NoError(t, err)
+	return f.oidc.Callback("test", callback.Query().Get("code"), callback.Query().Get("state"), authorization.StateToken)
+}
+
+// loggedInUser returns the user the access token of the login is for
+func (f *oidcFixture) loggedInUser(t *testing.T, result *LoginResult) *entity.User {
+	require.NotNil(t, result.TokenPair)
+	user, err := f.auth.GetUserByToken(result.AccessToken)
+	require.NoError(t, err)
+	return user
+}
+
+func TestOIDCLoginCreatesUser(t *testing.T) {
+	f := setupOIDCService(t)
+	assert.Equal(t, []string{"test"}, f.oidc.Providers())
+
+	result, err := f.login(t)
+	require.NoError(t, err)
+	user := f.loggedInUser(t, result)
+	assert.Equal(t, "john@example.com", user.Email)
+	assert.Equal(t, "john", user.UserName)
+	assert.Equal(t, "John", user.FirstName)
+	assert.Equal(t, "Doe", user.LastName)
+	assert.True(t, user.EmailVerified)
+	assert.Equal(t, entity.UserRoleUser, user.Role)
+
+	// The link follows the provider account, even after its email address changed
+	f.provider.SetUser(oidctest.User{Subject: "subject-1", Email: "john.doe@example.com", EmailVerified: true})
+	result, err = f.login(t)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, f.loggedInUser(t, result).ID)
+}
+
+func TestOIDCLoginCreatesUserWithFreeUsername(t *testing.T) {
+	f := setupOIDCService(t)
+	createTestUser(t, f.userRepo, "john", entity.UserRoleUser, entity.UserStatusAvailable, time.Now())
+
+	f.provider.SetUser(oidctest.User{Subject: "subject-2", Email: "john@other.example.com", EmailVerified: true})
+	result, err := f.login(t)
+	require.NoError(t, err)
+	assert.Regexp(t, `^john-[0-9a-f]{6}$`, f.loggedInUser(t, result).UserName)
+}
+
+func TestOIDCLoginLinksUserByEmail(t *testing.T) {
+	f := setupOIDCService(t)
+	existing := createTestUser(t, f.userRepo, "john", entity.UserRoleAdmin, entity.UserStatusAvailable, time.Now())
+
+	result, err := f.login(t)
+	require.NoError(t, err)
+	user := f.loggedInUser(t, result)
+	assert.Equal(t, existing.ID, user.ID)
+	assert.Equal(t, "hashed", user.Password, "the password of a verified user stays")
+	assert.Equal(t, entity.UserRoleAdmin, user.Role)
+}
+
+func TestOIDCLoginTakesOverUnverifiedUser(t *testing.T) {
+	f := setupOIDCService(t)
+	existing := &entity.User{
+		FirstName: "John", LastName: "Test", UserName: "john", Email: "john@example.com", Password: "hashed",
+		Status: entity.UserStatusAvailable, Role: entity.UserRoleUser,
+	}
+	require.NoError(t, f.userRepo.CreateUser(existing))
+	stale, err := f.auth.GenerateToken(existing)
+	require.NoError(t, err)
+
+	result, err := f.login(t)
+	require.NoError(t, err)
+	user := f.loggedInUser(t, result)
+	assert.Equal(t, existing.ID, user.ID)
+	assert.True(t, user.EmailVerified)
+	assert.NotEqual(t, "hashed", user.Password, "whoever registered the address loses the password")
+	_, err = f.auth.GetUserByToken(stale)
+	assert.Error(t, err, "and their sessions")
+}
+
+func TestOIDCLoginRequiresVerifiedEmail(t *testing.T) {
+	f := setupOIDCService(t)
+	createTestUser(t, f.userRepo, "john", entity.UserRoleUser, entity.UserStatusAvailable, time.Now())
+
+	f.provider.SetUser(oidctest.User{Subject: "subject-1", Email: "john@example.com", EmailVerified: false})
+	_, err := f.login(t)
+	assert.ErrorIs(t, err, ErrOIDCEmailNotVerified)
+}
+
+func TestOIDCLoginRejectsSuspendedUser(t *testing.T) {
+	f := setupOIDCService(t)
+	createTestUser(t, f.userRepo, "john", entity.UserRoleUser, entity.UserStatusSuspended, time.Now())
+
+	_, err := f.login(t)
+	assert.ErrorIs(t, err, ErrOIDCLoginFailed)
+}
+
+func TestOIDCLoginWithTwoFactor(t *testing.T) {
+	f := setupOIDCService(t)
+	user := createTestUser(t, f.userRepo, "john", entity.UserRoleUser, entity.UserStatusAvailable, time.Now())
+	enrollment, err := f.twoFactor.Enroll(user.ID)
+	require.NoError(t, err)
+	_, err = f.twoFactor.Confirm(user.ID, totpCode(t, enrollment.Secret, -1))
+	require.NoError(t, err)
+
+	result, err := f.login(t)
+	require.NoError(t, err)
+	assert.Nil(t, result.TokenPair)
+	require.NotNil(t, result.Challenge)
+
+	tokens, err := f.auth.VerifyTwoFactor(result.Challenge.Token, totpCode(t, enrollment.Secret, 0), "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, tokens.UserID)
+}
+
+func TestOIDCCallbackChecksState(t *testing.T) {
+	f := setupOIDCService(t)
+
+	_, err := f.oidc.Authorize("other")
+	assert.ErrorIs(t, err, ErrOIDCProviderNotFound)
+
+	authorization, err := f.oidc.Authorize("test")
+	require.NoError(t, err)
+	callback, err := f.provider.Authorize(authorization.URL)
+	require.NoError(t, err)
+	code, state := callback.Query().Get("code"), callback.Query().Get("state")
+
+	// A login started in another browser, whose state token does not match
+	other, err := f.oidc.Authorize("test")
+	require.NoError(t, err)
+	_, err = f.oidc.Callback("test", code, state, other.StateToken)
+	assert.ErrorIs(t, err, ErrInvalidOIDCState)
+	_, err = f.oidc.Callback("test", code, state, "")
+	assert.ErrorIs(t, err, ErrInvalidOIDCState)
+	_, err = f.oidc.Callback("other", code, state, authorization.StateToken)
+	assert.ErrorIs(t, err, ErrInvalidOIDCState)
+
+	_, err = f.oidc.Callback("test", code, state, authorization.StateToken)
+	require.NoError(t, err)
+	_, err = f.oidc.Callback("test", code, state, authorization.StateToken)
+	assert.ErrorIs(t, err, ErrOIDCLoginFailed, "a code works once")
+}
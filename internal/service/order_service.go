@@ -0,0 +1,178 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/repo"
+	"time"
+)
+
+var (
+	ErrPlanNotFound           = errors.New("plan not found")
+	ErrOrderNotFound          = errors.New("order not found")
+	ErrInvalidOrderTransition = errors.New("invalid order status change")
+)
+
+// maxOrderUpdateAttempts bounds how often a status change is retried when the order changed meanwhile
+const maxOrderUpdateAttempts = 3
+
+// OrderService creates the orders users pay plans with and moves them through their statuses:
+// pending to paid or failed, and paid to partially refunded or refunded. Other changes are refused.
+type OrderService interface {
+	ListPlans() ([]entity.Plan, error)
+	// CreateOrder creates a pending order of the plan for the user, for the amount of the plan
+	CreateOrder(userID uint64, planCode, provider string) (*entity.Order, *entity.Plan, error)
+	GetOrder(reference string) (*entity.Order, error)
+	ListOrders(userID uint64) ([]entity.Order, error) // Newest first
+	// MarkPaid records the payment of a pending order. Orders already paid are left as they are, so repeated
+	// notifications of a payment are harmless.
+	MarkPaid(order *entity.Order, transactionID string) error
+	// MarkFailed records that the payment of a pending order failed. Orders that already failed are left as they are.
+	MarkFailed(order *entity.Order) error
+	// RecordRefund adds the amount to what was refunded of a paid order
+	RecordRefund(order *entity.Order, amount int64) error
+}
+
+type orderService struct {
+	orderRepo repo.OrderRepository
+	planRepo  repo.PlanRepository
+}
+
+func NewOrderService(orderRepo repo.OrderRepository, planRepo repo.PlanRepository) OrderService {
+	return &orderService{orderRepo: orderRepo, planRepo: planRepo}
+}
+
+func (s *orderService) ListPlans() ([]entity.Plan, error) {
+	return s.planRepo.ListActivePlans()
+}
+
+func (s *orderService) CreateOrder(userID uint64, planCode, provider string) (*entity.Order, *entity.Plan, error) {
+	plan, err := s.planRepo.GetPlanByCode(planCode)
+	if err != nil {
+		return nil, nil, err
+	}
+	if plan == nil || !plan.Active {
+		return nil, nil, ErrPlanNotFound
+	}
+
+	reference, err := newOrderReference()
+	if err != nil {
+		return nil, nil, err
+	}
+	order := &entity.Order{
+		Reference: reference,
+		UserID:    userID,
+		PlanID:    plan.ID,
+		Amount:    plan.Amount,
+		Currency:  plan.Currency,
+		Provider:  provider,
+		Status:    entity.OrderStatusPending,
+	}
+	if err := s.orderRepo.CreateOrder(order); err != nil {
+		return nil, nil, err
+	}
+	return order, plan, nil
+}
+
+func (s *orderService) GetOrder(reference string) (*entity.Order, error) {
+	order, err := s.orderRepo.GetOrderByReference(reference)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+	return order, nil
+}
+
+func (s *orderService) ListOrders(userID uint64) ([]entity.Order, error) {
+	return s.orderRepo.ListOrdersByUserID(userID)
+}
+
+func (s *orderService) MarkPaid(order *entity.Order, transactionID string) error {
+	return s.transition(order, func(next *entity.Order) error {
+		if next.Status == entity.OrderStatusPaid {
+			return errOrderUnchanged
+		}
+		paidAt := time.Now()
+		next.Status = entity.OrderStatusPaid
+		next.TransactionID = transactionID
+		next.PaidAt = &paidAt
+		return nil
+	})
+}
+
+func (s *orderService) MarkFailed(order *entity.Order) error {
+	return s.transition(order, func(next *entity.Order) error {
+		if next.Status == entity.OrderStatusFailed {
+			return errOrderUnchanged
+		}
+		next.Status = entity.OrderStatusFailed
+		return nil
+	})
+}
+
+func (s *orderService) RecordRefund(order *entity.Order, amount int64) error {
+	return s.transition(order, func(next *entity.Order) error {
+		if amount <= 0 || amount > next.RefundableAmount() {
+			return ErrRefundTooLarge
+		}
+		next.RefundedAmount += amount
+		next.Status = entity.OrderStatusPartiallyRefunded
+		if next.RefundedAmount == next.Amount {
+			next.Status = entity.OrderStatusRefunded
+		}
+		return nil
+	})
+}
+
+// errOrderUnchanged is returned by the changes of a transition when the order already is as they would leave it
+var errOrderUnchanged = errors.New("order unchanged")
+
+// transition applies the change to a copy of the order and saves it, unless the state machine forbids the new
+// status. When the order changed since it was read, it is reloaded and the change applied again.
+func (s *orderService) transition(order *entity.Order, change func(next *entity.Order) error) error {
+	for attempt := 0; attempt < maxOrderUpdateAttempts; attempt++ {
+		next := *order
+		if err := change(&next); err != nil {
+			if errors.Is(err, errOrderUnchanged) {
+				return nil
+			}
+			return err
+		}
+		if !order.Status.CanTransitionTo(next.Status) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidOrderTransition, order.Status, next.Status)
+		}
+
+		updated, err := s.orderRepo.UpdateOrderPayment(&next, order)
+		if err != nil {
+			return err
+		}
+		if updated {
+			*order = next
+			return nil
+		}
+
+		current, err := s.orderRepo.GetOrderByID(order.ID)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			return ErrOrderNotFound
+		}
+		*order = *current
+	}
+	return fmt.Errorf("order %s keeps changing", order.Reference)
+}
+
+// newOrderReference returns a random order ID in the format payment providers accept
+func newOrderReference() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "ord-" + hex.EncodeToString(b), nil
+}
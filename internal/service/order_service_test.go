@@ -0,0 +1,144 @@
+package service
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupOrderTestDB adds the plans, orders, subscriptions and transaction logs to the tables of setupUserTestDB
+func setupOrderTestDB(t *testing.T) *sql.DB {
+	db := setupUserTestDB(t)
+	for _, name := range []string{
+		"0004_create_transaction_logs_table", "0025_rework_transaction_logs", "0026_create_orders_tables",
+		"0027_create_subscriptions_tables",
+	} {
+		schema, err := os.ReadFile("../../migration/" + name + ".up.sql")
+		require.NoError(t, err)
+		_, err = db.Exec(string(schema))
+		require.NoError(t, err, name)
+	}
+	return db
+}
+
+func setupOrderService(t *testing.T) (OrderService, *entity.User) {
+	db := setupOrderTestDB(t)
+	user := createTestUser(t, repo.NewUserRepo(db), "buyer", entity.UserRoleUser, entity.UserStatusAvailable, time.Now())
+	return NewOrderService(repo.NewOrderRepo(db), repo.NewPlanRepo(db)), user
+}
+
+func TestOrderStatusTransitions(t *testing.T) {
+	allowed := map[entity.OrderStatus][]entity.OrderStatus{
+		entity.OrderStatusPending:           {entity.OrderStatusPaid, entity.OrderStatusFailed},
+		entity.OrderStatusPaid:              {entity.OrderStatusPartiallyRefunded, entity.OrderStatusRefunded},
+		entity.OrderStatusPartiallyRefunded: {entity.OrderStatusPartiallyRefunded, entity.OrderStatusRefunded},
+	}
+	statuses := []entity.OrderStatus{
+		entity.OrderStatusPending, entity.OrderStatusPaid, entity.OrderStatusFailed,
+		entity.OrderStatusRefunded, entity.OrderStatusPartiallyRefunded,
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			assert.Equal(t, contains(allowed[from], to), from.CanTransitionTo(to), "%s to %s", from, to)
+		}
+	}
+}
+
+func contains(statuses []entity.OrderStatus, status entity.OrderStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCreateOrder(t *testing.T) {
+	orders, user := setupOrderService(t)
+
+	plans, err := orders.ListPlans()
+	require.NoError(t, err)
+	require.Len(t, plans, 2)
+	assert.Equal(t, "premium_monthly", plans[0].Code, "the cheapest plan comes first")
+
+	order, plan, err := orders.CreateOrder(user.ID, "premium_yearly", entity.PaymentProviderMoMo)
+	require.NoError(t, err)
+	assert.Equal(t, plan.ID, order.PlanID)
+	assert.Equal(t, plan.Amount, order.Amount)
+	assert.Equal(t, plan.Currency, order.Currency)
+	assert.Equal(t, entity.OrderStatusPending, order.Status)
+	assert.Regexp(t, `^ord-[0-9a-f]{20}$`, order.Reference)
+
+	saved, err := orders.GetOrder(order.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, order.ID, saved.ID)
+
+	_, _, err = orders.CreateOrder(user.ID, "unknown", entity.PaymentProviderMoMo)
+	assert.ErrorIs(t, err, ErrPlanNotFound)
+	_, err = orders.GetOrder("unknown")
+	assert.ErrorIs(t, err, ErrOrderNotFound)
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	orders, user := setupOrderService(t)
+	order, _, err := orders.CreateOrder(user.ID, "premium_monthly", entity.PaymentProviderMoMo)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, orders.RecordRefund(order, 1000), ErrRefundTooLarge, "pending orders have nothing to refund")
+
+	require.NoError(t, orders.MarkPaid(order, "4000000001"))
+	assert.Equal(t, entity.OrderStatusPaid, order.Status)
+	require.NotNil(t, order.PaidAt)
+	require.NoError(t, orders.MarkPaid(order, "4000000001"), "paying a paid order again changes nothing")
+	assert.ErrorIs(t, orders.MarkFailed(order), ErrInvalidOrderTransition)
+
+	require.NoError(t, orders.RecordRefund(order, 9000))
+	assert.Equal(t, entity.OrderStatusPartiallyRefunded, order.Status)
+	require.NoError(t, orders.RecordRefund(order, 40000))
+	assert.Equal(t, entity.OrderStatusPartiallyRefunded, order.Status)
+	assert.ErrorIs(t, orders.RecordRefund(order, 60000), ErrRefundTooLarge)
+	require.NoError(t, orders.RecordRefund(order, 50000))
+	assert.Equal(t, entity.OrderStatusRefunded, order.Status)
+	assert.Equal(t, order.Amount, order.RefundedAmount)
+
+	saved, err := orders.GetOrder(order.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, entity.OrderStatusRefunded, saved.Status)
+	assert.Equal(t, "4000000001", saved.TransactionID)
+
+	failed, _, err := orders.CreateOrder(user.ID, "premium_monthly", entity.PaymentProviderMoMo)
+	require.NoError(t, err)
+	require.NoError(t, orders.MarkFailed(failed))
+	assert.ErrorIs(t, orders.MarkPaid(failed, "4000000002"), ErrInvalidOrderTransition, "failed orders are final")
+
+	listed, err := orders.ListOrders(user.ID)
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.Equal(t, failed.ID, listed[0].ID, "the newest order comes first")
+}
+
+func TestOrderTransitionFromStaleCopy(t *testing.T) {
+	orders, user := setupOrderService(t)
+	order, _, err := orders.CreateOrder(user.ID, "premium_monthly", entity.PaymentProviderMoMo)
+	require.NoError(t, err)
+
+	// Two notifications of the same payment read the order before either saved it
+	first, second := *order, *order
+	require.NoError(t, orders.MarkPaid(&first, "4000000001"))
+	require.NoError(t, orders.MarkPaid(&second, "4000000001"))
+	assert.Equal(t, entity.OrderStatusPaid, second.Status, "the second one finds the order paid")
+
+	// Two refunds of a copy read before either was saved both count
+	first = second
+	require.NoError(t, orders.RecordRefund(&first, 50000))
+	require.NoError(t, orders.RecordRefund(&second, 40000))
+	assert.Equal(t, int64(90000), second.RefundedAmount)
+	assert.ErrorIs(t, orders.RecordRefund(&first, 10000), ErrRefundTooLarge, "only 9000 is left once reloaded")
+}
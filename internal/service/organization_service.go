@@ -0,0 +1,309 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/infra/mailer"
+	"mlvt/internal/infra/reason"
+	"mlvt/internal/repo"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrOrganizationNotFound   = errors.New("organization not found")
+	ErrInvalidOrgName         = errors.New("organization name must be 1 to 100 characters")
+	ErrInvalidOrgRole         = errors.New("invalid organization role")
+	ErrOrgMemberNotFound      = errors.New("organization member not found")
+	ErrLastOrgOwner           = errors.New("an organization needs at least one owner")
+	ErrAlreadyOrgMember       = errors.New("user is already a member of the organization")
+	ErrOrgInviteNotFound      = errors.New("organization invite not found")
+	ErrInvalidOrgInvite       = errors.New("invalid or expired invitation")
+	ErrOrgInviteEmailMismatch = errors.New("the invitation was sent to another email address")
+)
+
+const (
+	// OrgInviteTTL is how long invitations may be accepted. The invitation email states it.
+	OrgInviteTTL     = 7 * 24 * time.Hour
+	MaxOrgNameLength = 100 // ErrInvalidOrgName states it
+)
+
+// OrganizationService manages organizations, their members and the invitations to join them. Whether the
+// caller may act on an organization is checked by the routes; the service keeps every organization owned.
+type OrganizationService interface {
+	CreateOrganization(userID uint64, name string) (*entity.Organization, error) // The user becomes the owner
+	GetOrganization(orgID uint64) (*entity.Organization, error)
+	ListOrganizations(userID uint64) ([]entity.Organization, error) // With the role of the user
+	MemberRole(orgID, userID uint64) (entity.OrgRole, error)        // Empty when the user is no member
+	ListMembers(orgID uint64) ([]entity.OrganizationMember, error)
+	ChangeMemberRole(orgID, userID uint64, role entity.OrgRole) error
+	RemoveMember(orgID, userID uint64) error // Also for members leaving
+	// Invite mails an invitation to the email address, replacing the pending ones sent to it
+	Invite(orgID uint64, inviter *entity.User, email string, role entity.OrgRole) (*entity.OrganizationInvite, error)
+	ListInvites(orgID uint64) ([]entity.OrganizationInvite, error) // Pending invitations, newest first
+	RevokeInvite(orgID, inviteID uint64) error
+	AcceptInvite(user *entity.User, token string) (*entity.Organization, error) // The user must have the invited email address
+}
+
+type organizationService struct {
+	orgRepo      repo.OrganizationRepository
+	userRepo     repo.UserRepository
+	mailer       mailer.Mailer
+	orgInviteURL string
+}
+
+func NewOrganizationService(orgRepo repo.OrganizationRepository, userRepo repo.UserRepository, mailer mailer.Mailer) OrganizationService {
+	return &organizationService{
+		orgRepo:      orgRepo,
+		userRepo:     userRepo,
+		mailer:       mailer,
+		orgInviteURL: env.EnvConfig.OrgInviteURL,
+	}
+}
+
+// CreateOrganization creates an organization owned by the user
+func (s *organizationService) CreateOrganization(userID uint64, name string) (*entity.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxOrgNameLength {
+		return nil, ErrInvalidOrgName
+	}
+
+	org := &entity.Organization{Name: name, CreatedBy: userID}
+	if err := s.orgRepo.CreateOrganization(org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// GetOrganization retrieves an organization by its ID
+func (s *organizationService) GetOrganization(orgID uint64) (*entity.Organization, error) {
+	org, err := s.orgRepo.GetOrganizationByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, ErrOrganizationNotFound
+	}
+	return org, nil
+}
+
+// ListOrganizations lists the organizations the user is a member of, with their role in each
+func (s *organizationService) ListOrganizations(userID uint64) ([]entity.Organization, error) {
+	return s.orgRepo.ListOrganizationsByUserID(userID)
+}
+
+// MemberRole returns the role of the user in the organization, empty when they are no member
+func (s *organizationService) MemberRole(orgID, userID uint64) (entity.OrgRole, error) {
+	return s.orgRepo.GetMemberRole(orgID, userID)
+}
+
+// ListMembers lists the members of the organization in the order they joined
+func (s *organizationService) ListMembers(orgID uint64) ([]entity.OrganizationMember, error) {
+	if _, err := s.GetOrganization(orgID); err != nil {
+		return nil, err
+	}
+	return s.orgRepo.ListMembers(orgID)
+}
+
+// ChangeMemberRole gives a member another role. The last owner cannot step down.
+func (s *organizationService) ChangeMemberRole(orgID, userID uint64, role entity.OrgRole) error {
+	if !entity.IsValidOrgRole(role) {
+		return ErrInvalidOrgRole
+	}
+	current, err := s.memberRole(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if current == role {
+		return nil
+	}
+	if current == entity.OrgRoleOwner {
+		if err := s.keepAnOwner(orgID); err != nil {
+			return err
+		}
+	}
+
+	updated, err := s.orgRepo.UpdateMemberRole(orgID, userID, role)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrOrgMemberNotFound
+	}
+	return nil
+}
+
+// RemoveMember removes the user from the organization. The last owner cannot leave.
+func (s *organizationService) RemoveMember(orgID, userID uint64) error {
+	current, err := s.memberRole(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if current == entity.OrgRoleOwner {
+		if err := s.keepAnOwner(orgID); err != nil {
+			return err
+		}
+	}
+
+	removed, err := s.orgRepo.RemoveMember(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrOrgMemberNotFound
+	}
+	return nil
+}
+
+// Invite stores an invitation to join the organization with the role and mails its link to the email address
+func (s *organizationService) Invite(orgID uint64, inviter *entity.User, email string, role entity.OrgRole) (*entity.OrganizationInvite, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !entity.IsValidOrgRole(role) {
+		return nil, ErrInvalidOrgRole
+	}
+	org, err := s.GetOrganization(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	invitee, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if invitee != nil {
+		current, err := s.orgRepo.GetMemberRole(orgID, invitee.ID)
+		if err != nil {
+			return nil, err
+		}
+		if current != "" {
+			return nil, ErrAlreadyOrgMember
+		}
+	}
+
+	if err := s.orgRepo.RevokeInvitesForEmail(orgID, email); err != nil {
+		return nil, err
+	}
+	token, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	invite := &entity.OrganizationInvite{
+		OrgID:     orgID,
+		Email:     email,
+		Role:      role,
+		TokenHash: hashToken(token),
+		InvitedBy: inviter.ID,
+		ExpiresAt: time.Now().Add(OrgInviteTTL),
+	}
+	if err := s.orgRepo.CreateInvite(invite); err != nil {
+		return nil, err
+	}
+
+	if err := s.sendInvite(org, inviter, email, token); err != nil {
+		// An invitation nobody received should not stay pending
+		if _, revokeErr := s.orgRepo.RevokeInvite(orgID, invite.ID); revokeErr != nil {
+			return nil, fmt.Errorf("failed to send the invitation: %v (revoking it also failed: %v)", err, revokeErr)
+		}
+		return nil, fmt.Errorf("failed to send the invitation: %v", err)
+	}
+	return invite, nil
+}
+
+// ListInvites lists the invitations of the organization that may still be accepted
+func (s *organizationService) ListInvites(orgID uint64) ([]entity.OrganizationInvite, error) {
+	if _, err := s.GetOrganization(orgID); err != nil {
+		return nil, err
+	}
+	return s.orgRepo.ListPendingInvites(orgID, time.Now())
+}
+
+// RevokeInvite revokes a pending invitation of the organization, so its link stops working
+func (s *organizationService) RevokeInvite(orgID, inviteID uint64) error {
+	revoked, err := s.orgRepo.RevokeInvite(orgID, inviteID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrOrgInviteNotFound
+	}
+	return nil
+}
+
+// AcceptInvite adds the user to the organization of the invitation with its role. Only the user with the
+// email address the invitation was sent to may accept it, once.
+func (s *organizationService) AcceptInvite(user *entity.User, token string) (*entity.Organization, error) {
+	invite, err := s.orgRepo.GetInviteByHash(hashToken(strings.TrimSpace(token)))
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil || !invite.IsPending(time.Now()) {
+		return nil, ErrInvalidOrgInvite
+	}
+	if !strings.EqualFold(invite.Email, strings.TrimSpace(user.Email)) {
+		return nil, ErrOrgInviteEmailMismatch
+	}
+
+	org, err := s.GetOrganization(invite.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.orgRepo.GetMemberRole(invite.OrgID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current != "" {
+		return nil, ErrAlreadyOrgMember
+	}
+
+	accepted, err := s.orgRepo.AcceptInvite(invite, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, ErrInvalidOrgInvite
+	}
+	org.Role = invite.Role
+	return org, nil
+}
+
+// memberRole returns the role of a member, or ErrOrgMemberNotFound
+func (s *organizationService) memberRole(orgID, userID uint64) (entity.OrgRole, error) {
+	role, err := s.orgRepo.GetMemberRole(orgID, userID)
+	if err != nil {
+		return "", err
+	}
+	if role == "" {
+		return "", ErrOrgMemberNotFound
+	}
+	return role, nil
+}
+
+// keepAnOwner returns ErrLastOrgOwner unless another owner remains when one steps down
+func (s *organizationService) keepAnOwner(orgID uint64) error {
+	owners, err := s.orgRepo.CountOwners(orgID)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOrgOwner
+	}
+	return nil
+}
+
+// sendInvite mails the invitation link in the language of the server
+func (s *organizationService) sendInvite(org *entity.Organization, inviter *entity.User, email, token string) error {
+	name := inviter.FirstName
+	if name == "" {
+		name = inviter.UserName
+	}
+	link := s.orgInviteURL + "?token=" + url.QueryEscape(token)
+	return s.mailer.Send(mailer.Message{
+		To:      email,
+		Subject: fmt.Sprintf(reason.OrgInviteSubject.Message(), org.Name),
+		Body:    fmt.Sprintf(reason.OrgInviteBody.Message(), name, org.Name, link),
+	})
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"mlvt/internal/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockOrganizationService is a mock implementation of the OrganizationService interface
+type MockOrganizationService struct {
+	mock.Mock
+}
+
+func (m *MockOrganizationService) CreateOrganization(userID uint64, name string) (*entity.Organization, error) {
+	args := m.Called(userID, name)
+	org, _ := args.Get(0).(*entity.Organization)
+	return org, args.Error(1)
+}
+
+func (m *MockOrganizationService) GetOrganization(orgID uint64) (*entity.Organization, error) {
+	args := m.Called(orgID)
+	org, _ := args.Get(0).(*entity.Organization)
+	return org, args.Error(1)
+}
+
+func (m *MockOrganizationService) ListOrganizations(userID uint64) ([]entity.Organization, error) {
+	args := m.Called(userID)
+	orgs, _ := args.Get(0).([]entity.Organization)
+	return orgs, args.Error(1)
+}
+
+func (m *MockOrganizationService) MemberRole(orgID, userID uint64) (entity.OrgRole, error) {
+	args := m.Called(orgID, userID)
+	role, _ := args.Get(0).(entity.OrgRole)
+	return role, args.Error(1)
+}
+
+func (m *MockOrganizationService) ListMembers(orgID uint64) ([]entity.OrganizationMember, error) {
+	args := m.Called(orgID)
+	members, _ := args.Get(0).([]entity.OrganizationMember)
+	return members, args.Error(1)
+}
+
+func (m *MockOrganizationService) ChangeMemberRole(orgID, userID uint64, role entity.OrgRole) error {
+	args := m.Called(orgID, userID, role)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationService) RemoveMember(orgID, userID uint64) error {
+	args := m.Called(orgID, userID)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationService) Invite(orgID uint64, inviter *entity.User, email string, role entity.OrgRole) (*entity.OrganizationInvite, error) {
+	args := m.Called(orgID, inviter, email, role)
+	invite, _ := args.Get(0).(*entity.OrganizationInvite)
+	return invite, args.Error(1)
+}
+
+func (m *MockOrganizationService) ListInvites(orgID uint64) ([]entity.OrganizationInvite, error) {
+	args := m.Called(orgID)
+	invites, _ := args.Get(0).([]entity.OrganizationInvite)
+	return invites, args.Error(1)
+}
+
+func (m *MockOrganizationService) RevokeInvite(orgID, inviteID uint64) error {
+	args := m.Called(orgID, inviteID)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationService) AcceptInvite(user *entity.User, token string) (*entity.Organization, error) {
+	args := m.Called(user, token)
+	org, _ := args.Get(0).(*entity.Organization)
+	return org, args.Error(1)
+}
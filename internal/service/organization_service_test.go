@@ -0,0 +1,176 @@
+package service
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/infra/mailer"
+	"mlvt/internal/pkg/localization"
+	"mlvt/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type organizationFixture struct {
+	orgs     OrganizationService
+	userRepo repo.UserRepository
+	mailer   *mailer.FileMailer
+	owner    *entity.User
+	invitee  *entity.User
+}
+
+// setupOrganizationService stores an owner and an invitee and mails through a file mailer, with the emails in English
+func setupOrganizationService(t *testing.T) *organizationFixture {
+	i18nPath := env.EnvConfig.I18NPath
+	env.EnvConfig.I18NPath = "../../i18n/"
+	localization.SetLanguage("en")
+	t.Cleanup(func() { env.EnvConfig.I18NPath = i18nPath })
+
+	db := setupUserTestDB(t)
+	for _, name := range []string{
+		"0002_create_videos_table", "0003_create_transcriptions_table", "0006_create_audios_table",
+		"0009_add_file_info_columns", "0024_create_organizations_tables",
+	} {
+		schema, err := os.ReadFile("../../migration/" + name + ".up.sql")
+		require.NoError(t, err)
+		_, err = db.Exec(string(schema))
+		require.NoError(t, err, name)
+	}
+
+	userRepo := repo.NewUserRepo(db)
+	fileMailer, err := mailer.NewFileMailer(t.TempDir(), "noreply@example.com")
+	require.NoError(t, err)
+
+	return &organizationFixture{
+		orgs:     NewOrganizationService(repo.NewOrganizationRepo(db), userRepo, fileMailer),
+		userRepo: userRepo,
+		mailer:   fileMailer,
+		owner:    createTestUser(t, userRepo, "alice", entity.UserRoleUser, entity.UserStatusAvailable, time.Now()),
+		invitee:  createTestUser(t, userRepo, "bob", entity.UserRoleUser, entity.UserStatusAvailable, time.Now()),
+	}
+}
+
+// invite invites the email address and returns the token mailed to it
+func (f *organizationFixture) invite(t *testing.T, orgID uint64, email string, role entity.OrgRole) string {
+	_, err := f.orgs.Invite(orgID, f.owner, email, role)
+	require.NoError(t, err)
+	messages := mustMessages(t, f.mailer)
+	require.NotEmpty(t, messages)
+	match := mailedToken.FindStringSubmatch(messages[len(messages)-1].Body)
+	require.NotNil(t, match, "the email carries a link with the token")
+	return match[1]
+}
+
+func TestCreateOrganization(t *testing.T) {
+	f := setupOrganizationService(t)
+
+	org, err := f.orgs.CreateOrganization(f.owner.ID, " Studio ")
+	require.NoError(t, err)
+	assert.Equal(t, "Studio", org.Name)
+	assert.Equal(t, entity.OrgRoleOwner, org.Role)
+
+	listed, err := f.orgs.ListOrganizations(f.owner.ID)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, org.ID, listed[0].ID)
+	assert.Equal(t, entity.OrgRoleOwner, listed[0].Role)
+
+	none, err := f.orgs.ListOrganizations(f.invitee.ID)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	_, err = f.orgs.CreateOrganization(f.owner.ID, "  ")
+	assert.ErrorIs(t, err, ErrInvalidOrgName)
+	_, err = f.orgs.GetOrganization(org.ID + 1)
+	assert.ErrorIs(t, err, ErrOrganizationNotFound)
+}
+
+func TestInviteAndAccept(t *testing.T) {
+	f := setupOrganizationService(t)
+	org, err := f.orgs.CreateOrganization(f.owner.ID, "Studio")
+	require.NoError(t, err)
+
+	token := f.invite(t, org.ID, "Bob@Example.com", entity.OrgRoleEditor)
+	messages := mustMessages(t, f.mailer)
+	assert.Equal(t, "bob@example.com", messages[0].To)
+	assert.Contains(t, messages[0].Subject, "Studio")
+
+	pending, err := f.orgs.ListInvites(org.ID)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, entity.OrgRoleEditor, pending[0].Role)
+
+	_, err = f.orgs.AcceptInvite(f.owner, token)
+	assert.ErrorIs(t, err, ErrOrgInviteEmailMismatch, "only the invited address may accept")
+
+	joined, err := f.orgs.AcceptInvite(f.invitee, token)
+	require.NoError(t, err)
+	assert.Equal(t, org.ID, joined.ID)
+	assert.Equal(t, entity.OrgRoleEditor, joined.Role)
+
+	role, err := f.orgs.MemberRole(org.ID, f.invitee.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.OrgRoleEditor, role)
+
+	_, err = f.orgs.AcceptInvite(f.invitee, token)
+	assert.ErrorIs(t, err, ErrInvalidOrgInvite, "an invitation is accepted once")
+	_, err = f.orgs.Invite(org.ID, f.owner, "bob@example.com", entity.OrgRoleViewer)
+	assert.ErrorIs(t, err, ErrAlreadyOrgMember)
+
+	pending, err = f.orgs.ListInvites(org.ID)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestRevokedAndReplacedInvites(t *testing.T) {
+	f := setupOrganizationService(t)
+	org, err := f.orgs.CreateOrganization(f.owner.ID, "Studio")
+	require.NoError(t, err)
+
+	first := f.invite(t, org.ID, "bob@example.com", entity.OrgRoleViewer)
+	second := f.invite(t, org.ID, "bob@example.com", entity.OrgRoleEditor)
+
+	_, err = f.orgs.AcceptInvite(f.invitee, first)
+	assert.ErrorIs(t, err, ErrInvalidOrgInvite, "a new invitation replaces the pending one")
+
+	pending, err := f.orgs.ListInvites(org.ID)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.NoError(t, f.orgs.RevokeInvite(org.ID, pending[0].ID))
+	assert.ErrorIs(t, f.orgs.RevokeInvite(org.ID, pending[0].ID), ErrOrgInviteNotFound)
+
+	_, err = f.orgs.AcceptInvite(f.invitee, second)
+	assert.ErrorIs(t, err, ErrInvalidOrgInvite)
+	_, err = f.orgs.Invite(org.ID, f.owner, "bob@example.com", "admin")
+	assert.ErrorIs(t, err, ErrInvalidOrgRole)
+}
+
+func TestMemberRolesKeepAnOwner(t *testing.T) {
+	f := setupOrganizationService(t)
+	org, err := f.orgs.CreateOrganization(f.owner.ID, "Studio")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, f.orgs.ChangeMemberRole(org.ID, f.owner.ID, entity.OrgRoleEditor), ErrLastOrgOwner)
+	assert.ErrorIs(t, f.orgs.RemoveMember(org.ID, f.owner.ID), ErrLastOrgOwner)
+	assert.ErrorIs(t, f.orgs.ChangeMemberRole(org.ID, f.invitee.ID, entity.OrgRoleEditor), ErrOrgMemberNotFound)
+
+	_, err = f.orgs.AcceptInvite(f.invitee, f.invite(t, org.ID, "bob@example.com", entity.OrgRoleViewer))
+	require.NoError(t, err)
+	assert.ErrorIs(t, f.orgs.ChangeMemberRole(org.ID, f.invitee.ID, "admin"), ErrInvalidOrgRole)
+	require.NoError(t, f.orgs.ChangeMemberRole(org.ID, f.invitee.ID, entity.OrgRoleOwner))
+
+	// With a second owner the first may step down and leave
+	require.NoError(t, f.orgs.ChangeMemberRole(org.ID, f.owner.ID, entity.OrgRoleViewer))
+	require.NoError(t, f.orgs.RemoveMember(org.ID, f.owner.ID))
+
+	members, err := f.orgs.ListMembers(org.ID)
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, f.invitee.ID, members[0].UserID)
+	assert.Equal(t, "bob", members[0].UserName)
+	assert.Equal(t, entity.OrgRoleOwner, members[0].Role)
+}
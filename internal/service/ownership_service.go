@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"mlvt/internal/entity"
+	"mlvt/internal/repo"
+)
+
+// ErrAudioNotFound is returned when an audio looked up by ID does not exist
+var ErrAudioNotFound = errors.New("audio not found")
+
+// ResourceOwner tells who a video, audio or transcription belongs to: the user who owns it and the organization
+// it is shared with, if any
+type ResourceOwner struct {
+	UserID uint64
+	OrgID  *uint64
+}
+
+// OwnershipService looks up the owners of user-scoped resources and the roles of organization members so
+// requests can be authorized against them
+type OwnershipService interface {
+	VideoOwner(videoID uint64) (*ResourceOwner, error)
+	AudioOwner(audioID uint64) (*ResourceOwner, error)
+	TranscriptionOwner(transcriptionID uint64) (*ResourceOwner, error)
+	OrgRole(orgID, userID uint64) (entity.OrgRole, error) // Empty when the user is no member
+}
+
+type ownershipService struct {
+	videoRepo         repo.VideoRepository
+	audioRepo         repo.AudioRepository
+	transcriptionRepo repo.TranscriptionRepository
+	orgRepo           repo.OrganizationRepository
+}
+
+func NewOwnershipService(videoRepo repo.VideoRepository, audioRepo repo.AudioRepository, transcriptionRepo repo.TranscriptionRepository,
+	orgRepo repo.OrganizationRepository) OwnershipService {
+	return &ownershipService{
+		videoRepo:         videoRepo,
+		audioRepo:         audioRepo,
+		transcriptionRepo: transcriptionRepo,
+		orgRepo:           orgRepo,
+	}
+}
+
+// VideoOwner returns the user who uploaded the video and its organization
+func (s *ownershipService) VideoOwner(videoID uint64) (*ResourceOwner, error) {
+	video, err := s.videoRepo.GetVideoByID(videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video == nil {
+		return nil, ErrVideoNotFound
+	}
+	return &ResourceOwner{UserID: video.UserID, OrgID: video.OrgID}, nil
+}
+
+// AudioOwner returns the user who owns the audio and its organization
+func (s *ownershipService) AudioOwner(audioID uint64) (*ResourceOwner, error) {
+	audio, err := s.audioRepo.GetAudioByID(audioID)
+	if err != nil {
+		return nil, err
+	}
+	if audio == nil {
+		return nil, ErrAudioNotFound
+	}
+	return &ResourceOwner{UserID: audio.UserID, OrgID: audio.OrgID}, nil
+}
+
+// TranscriptionOwner returns the user who owns the transcription and its organization
+func (s *ownershipService) TranscriptionOwner(transcriptionID uint64) (*ResourceOwner, error) {
+	transcription, err := s.transcriptionRepo.GetTranscriptionByID(transcriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if transcription == nil {
+		return nil, ErrTranscriptionNotFound
+	}
+	return &ResourceOwner{UserID: transcription.UserID, OrgID: transcription.OrgID}, nil
+}
+
+// OrgRole returns the role of the user in the organization, empty when they are no member
+func (s *ownershipService) OrgRole(orgID, userID uint64) (entity.OrgRole, error) {
+	return s.orgRepo.GetMemberRole(orgID, userID)
+}
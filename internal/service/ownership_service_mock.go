@@ -0,0 +1,36 @@
+package service
+
+import (
+	"mlvt/internal/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockOwnershipService is a mock implementation of the OwnershipService interface
+type MockOwnershipService struct {
+	mock.Mock
+}
+
+func (m *MockOwnershipService) VideoOwner(videoID uint64) (*ResourceOwner, error) {
+	args := m.Called(videoID)
+	owner, _ := args.Get(0).(*ResourceOwner)
+	return owner, args.Error(1)
+}
+
+func (m *MockOwnershipService) AudioOwner(audioID uint64) (*ResourceOwner, error) {
+	args := m.Called(audioID)
+	owner, _ := args.Get(0).(*ResourceOwner)
+	return owner, args.Error(1)
+}
+
+func (m *MockOwnershipService) TranscriptionOwner(transcriptionID uint64) (*ResourceOwner, error) {
+	args := m.Called(transcriptionID)
+	owner, _ := args.Get(0).(*ResourceOwner)
+	return owner, args.Error(1)
+}
+
+func (m *MockOwnershipService) OrgRole(orgID, userID uint64) (entity.OrgRole, error) {
+	args := m.Called(orgID, userID)
+	role, _ := args.Get(0).(entity.OrgRole)
+	return role, args.Error(1)
+}
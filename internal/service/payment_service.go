@@ -0,0 +1,313 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/repo"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+var (
+	ErrUnknownPaymentProvider = errors.New("unknown payment provider")
+	ErrInvalidPaymentAmount   = errors.New("amount must not be negative")
+	ErrPaymentNotPaid         = errors.New("the payment was not completed")
+	ErrRefundTooLarge         = errors.New("refund exceeds the amount left to refund")
+	ErrStaleWebhook           = errors.New("notification is too old")
+	ErrWebhookAmountMismatch  = errors.New("notification amount does not match the order")
+	ErrPlanNotPayable         = errors.New("the plan cannot be paid with this provider")
+)
+
+const (
+	// WebhookMaxAge is how old a notification may be when it first arrives. Older ones are taken for replays;
+	// the status of their order can still be checked with the provider.
+	WebhookMaxAge = time.Hour
+	// webhookClockSkew is how far the clocks of a provider and this server may drift apart
+	webhookClockSkew = 5 * time.Minute
+)
+
+type PaymentService interface {
+	// Providers returns the names of the providers orders may be paid through
+	Providers() []string
+	// CreateCheckout creates a pending order of the plan for the user, for the amount of the plan, and a payment
+	// of the order at the provider
+	CreateCheckout(userID uint64, provider, planCode, clientIP string) (*Checkout, error)
+	// CheckPaymentStatus asks the provider for the status of the payment of the order and updates the order with it
+	CheckPaymentStatus(provider, orderID string) (*entity.Order, *entity.PaymentStatus, error)
+	// RefundPayment refunds the amount of a paid order, or what is left of it when amount is 0. Refunding an order
+	// in full takes the period it paid for out of the subscription of its user.
+	RefundPayment(provider, orderID string, amount int64) (*entity.Order, *entity.PaymentRefund, error)
+	// HandleWebhook updates the order with the result of a payment the provider notified this server of, and logs
+	// it. Notifications already handled are accepted again without effect, since providers retry until answered.
+	HandleWebhook(provider string, request *entity.WebhookRequest) error
+	// WebhookResponse returns the answer the provider expects to a notification HandleWebhook returned err for
+	WebhookResponse(provider string, err error) (*entity.WebhookResponse, error)
+}
+
+// Checkout is the payment created at a provider for an order
+type Checkout struct {
+	Order    *entity.Order
+	PayURL   string // Payment page to open in a browser
+	Deeplink string // Opens the provider's app on a phone, if it has one
+	QRCode   []byte // PNG QR code to scan with a phone
+}
+
+type paymentService struct {
+	providers           repo.PaymentProviders
+	transactionLogRepo  repo.TransactionLogRepo
+	orderService        OrderService
+	subscriptionService SubscriptionService
+}
+
+func NewPaymentService(providers repo.PaymentProviders, transactionLogRepo repo.TransactionLogRepo, orderService OrderService,
+	subscriptionService SubscriptionService) PaymentService {
+	return &paymentService{
+		providers:           providers,
+		transactionLogRepo:  transactionLogRepo,
+		orderService:        orderService,
+		subscriptionService: subscriptionService,
+	}
+}
+
+func (p *paymentService) Providers() []string {
+	return p.providers.Names()
+}
+
+func (p *paymentService) provider(name string) (repo.PaymentProvider, error) {
+	provider, ok := p.providers[name]
+	if !ok {
+		return nil, ErrUnknownPaymentProvider
+	}
+	return provider, nil
+}
+
+func (p *paymentService) CreateCheckout(userID uint64, providerName, planCode, clientIP string) (*Checkout, error) {
+	provider, err := p.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	order, plan, err := p.orderService.CreateOrder(userID, planCode, provider.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	checkout, err := provider.CreateCheckout(order, "Payment for "+plan.Name, clientIP)
+	if err != nil {
+		p.failOrder(order)
+		if errors.Is(err, repo.ErrCheckoutNotSupported) {
+			return nil, ErrPlanNotPayable
+		}
+		return nil, err
+	}
+
+	// The QR code data opens the payment in the provider's app; the payment page is the fallback
+	data := checkout.QRData
+	if data == "" {
+		data = checkout.PayURL
+	}
+	png, err := qrcode.Encode(data, qrcode.Medium, 256)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Checkout{Order: order, PayURL: checkout.PayURL, Deeplink: checkout.Deeplink, QRCode: png}, nil
+}
+
+// failOrder marks an order the provider never got a payment for as failed, so it does not stay pending forever
+func (p *paymentService) failOrder(order *entity.Order) {
+	if err := p.orderService.MarkFailed(order); err != nil {
+		log.Errorf("Failed to mark order %s as failed: %v", order.Reference, err)
+	}
+}
+
+func (p *paymentService) CheckPaymentStatus(providerName, orderID string) (*entity.Order, *entity.PaymentStatus, error) {
+	provider, order, err := p.providerOrder(providerName, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	status, err := provider.GetPaymentStatus(order)
+	if err != nil {
+		return nil, nil, err
+	}
+	if order.Status == entity.OrderStatusPending {
+		if err := p.updateOrder(order, status.State, status.TransactionID); err != nil {
+			return nil, nil, err
+		}
+	}
+	return order, status, nil
+}
+
+func (p *paymentService) RefundPayment(providerName, orderID string, amount int64) (*entity.Order, *entity.PaymentRefund, error) {
+	if amount < 0 {
+		return nil, nil, ErrInvalidPaymentAmount
+	}
+	provider, order, err := p.providerOrder(providerName, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !order.Status.IsPaid() {
+		return nil, nil, ErrPaymentNotPaid
+	}
+
+	left := order.RefundableAmount()
+	if amount == 0 {
+		amount = left
+	}
+	if amount == 0 || amount > left {
+		return nil, nil, ErrRefundTooLarge
+	}
+
+	refund, err := provider.Refund(order, amount)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := p.orderService.RecordRefund(order, amount); err != nil {
+		return nil, nil, fmt.Errorf("%s refunded %d of order %s but the order was not updated: %w", provider.Name(), amount, order.Reference, err)
+	}
+	// A full refund takes back the time the order paid for; a partial one leaves the subscription as it is
+	if order.Status == entity.OrderStatusRefunded {
+		if err := p.subscriptionService.RevokeOrder(order); err != nil {
+			return nil, nil, fmt.Errorf("order %s was refunded but its subscription period was not removed: %w", order.Reference, err)
+		}
+	}
+	return order, refund, nil
+}
+
+// providerOrder returns the provider and the order with the ID paid through it
+func (p *paymentService) providerOrder(providerName, orderID string) (repo.PaymentProvider, *entity.Order, error) {
+	provider, err := p.provider(providerName)
+	if err != nil {
+		return nil, nil, err
+	}
+	order, err := p.orderService.GetOrder(orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if order.Provider != provider.Name() {
+		return nil, nil, ErrOrderNotFound
+	}
+	return provider, order, nil
+}
+
+// updateOrder records the result of the payment of the order: paid, failed, or nothing yet while pending.
+// A paid order extends the subscription of its user; if that fails, a retried notification extends it
+// with the order already paid.
+func (p *paymentService) updateOrder(order *entity.Order, state entity.PaymentState, transactionID string) error {
+	switch state {
+	case entity.PaymentStatePaid:
+		if err := p.orderService.MarkPaid(order, transactionID); err != nil {
+			return err
+		}
+		return p.subscriptionService.ActivateOrder(order)
+	case entity.PaymentStatePending:
+		return nil
+	default:
+		return p.orderService.MarkFailed(order)
+	}
+}
+
+func (p *paymentService) HandleWebhook(providerName string, request *entity.WebhookRequest) error {
+	provider, err := p.provider(providerName)
+	if err != nil {
+		return err
+	}
+	event, err := provider.VerifyWebhook(request)
+	if err != nil {
+		return err
+	}
+
+	handled, err := p.transactionLogRepo.HasReference(provider.Name(), event.ID)
+	if err != nil {
+		return err
+	}
+	if handled {
+		return nil
+	}
+
+	if !event.OccurredAt.IsZero() {
+		age := time.Since(event.OccurredAt)
+		if age > WebhookMaxAge || age < -webhookClockSkew {
+			return ErrStaleWebhook
+		}
+	}
+
+	// The order is updated before the notification is logged, so a retry after a failure updates it again
+	order, err := p.orderService.GetOrder(event.OrderID)
+	if err != nil && !errors.Is(err, ErrOrderNotFound) {
+		return err
+	}
+	if order == nil || order.Provider != provider.Name() {
+		// Logged all the same, for the payment to be traced
+		log.Warnf("%s notified a payment of unknown order %q", provider.Name(), event.OrderID)
+		if err := p.logEvent(provider.Name(), event); err != nil {
+			return err
+		}
+		return ErrOrderNotFound
+	}
+	if event.Amount != order.Amount {
+		return ErrWebhookAmountMismatch
+	}
+	err = p.updateOrder(order, event.State, event.TransactionID)
+	if errors.Is(err, ErrInvalidOrderTransition) {
+		// e.g. a late failure of an order already refunded; the notification is still logged
+		log.Warnf("Ignored %s notification for order %s: %v", provider.Name(), order.Reference, err)
+	} else if err != nil {
+		return err
+	}
+	return p.logEvent(provider.Name(), event)
+}
+
+// logEvent logs a notification once, even when a retry arrives at the same time
+func (p *paymentService) logEvent(providerName string, event *entity.PaymentEvent) error {
+	_, err := p.transactionLogRepo.LogTransactionOnce(&entity.TransactionLog{
+		OrderID:       event.OrderID,
+		PaymentMethod: providerName,
+		Action:        "ipn",
+		Status:        transactionStatus(event.State),
+		Details:       event.Details,
+		Reference:     event.ID,
+	})
+	return err
+}
+
+func (p *paymentService) WebhookResponse(providerName string, err error) (*entity.WebhookResponse, error) {
+	provider, providerErr := p.provider(providerName)
+	if providerErr != nil {
+		return nil, providerErr
+	}
+	return provider.WebhookResponse(webhookOutcome(err)), nil
+}
+
+// webhookOutcome returns the outcome of a notification HandleWebhook returned err for
+func webhookOutcome(err error) entity.WebhookOutcome {
+	switch {
+	case err == nil:
+		return entity.WebhookAccepted
+	case errors.Is(err, repo.ErrInvalidWebhookSignature):
+		return entity.WebhookInvalidSignature
+	case errors.Is(err, ErrOrderNotFound):
+		return entity.WebhookOrderNotFound
+	case errors.Is(err, ErrWebhookAmountMismatch):
+		return entity.WebhookAmountMismatch
+	case errors.Is(err, repo.ErrMalformedWebhook), errors.Is(err, ErrStaleWebhook):
+		return entity.WebhookRejected
+	default:
+		return entity.WebhookFailed
+	}
+}
+
+// transactionStatus returns the status logged for a payment in the state
+func transactionStatus(state entity.PaymentState) string {
+	switch state {
+	case entity.PaymentStatePaid:
+		return entity.TransactionStatusPaid
+	case entity.PaymentStatePending:
+		return entity.TransactionStatusPending
+	default:
+		return entity.TransactionStatusFailed
+	}
+}
@@ -0,0 +1,380 @@
+package service
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/repo"
+	"mlvt/internal/repo/momotest"
+	"mlvt/internal/repo/vnpaytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type paymentTestEnv struct {
+	db              *sql.DB
+	payments        PaymentService
+	orders          OrderService
+	subscriptions   SubscriptionService
+	transactionLogs repo.TransactionLogRepo
+	momo            *momotest.Server
+	vnpay           *vnpaytest.Server
+	user            *entity.User
+}
+
+func setupPaymentService(t *testing.T) *paymentTestEnv {
+	db := setupOrderTestDB(t)
+	user := createTestUser(t, repo.NewUserRepo(db), "buyer", entity.UserRoleUser, entity.UserStatusAvailable, time.Now())
+	transactionLogRepo := repo.NewTransactionLogRepo(db)
+	orderService := NewOrderService(repo.NewOrderRepo(db), repo.NewPlanRepo(db))
+	subscriptionService := NewSubscriptionService(repo.NewSubscriptionRepo(db), repo.NewPlanRepo(db), repo.NewVideoRepo(db))
+
+	momo := momotest.NewServer("MOMOTEST", "access-key", "secret-key")
+	t.Cleanup(momo.Close)
+	vnpay := vnpaytest.NewServer("VNPTEST1", "hash-secret")
+	t.Cleanup(vnpay.Close)
+
+	momoRepo := repo.NewMoMoRepoWithConfig(env.MoMoConfig{
+		Endpoint:    momo.URL,
+		PartnerCode: "MOMOTEST",
+		AccessKey:   "access-key",
+		SecretKey:   "secret-key",
+		RedirectURL: "http://localhost:3000/payment-result",
+		IPNURL:      "http://localhost:8080/api/payments/momo/ipn",
+		RequestType: "captureWallet",
+		Lang:        "vi",
+		Timeout:     5 * time.Second,
+	})
+	vnpayRepo := repo.NewVNPayRepoWithConfig(env.VNPayConfig{
+		PayURL:      vnpay.PayURL,
+		APIURL:      vnpay.APIURL,
+		TmnCode:     "VNPTEST1",
+		HashSecret:  "hash-secret",
+		ReturnURL:   "http://localhost:3000/payment-result",
+		Locale:      "vn",
+		ExpireAfter: 15 * time.Minute,
+		Timeout:     5 * time.Second,
+	})
+	return &paymentTestEnv{
+		db:              db,
+		payments:        NewPaymentService(repo.NewPaymentProviders(momoRepo, vnpayRepo), transactionLogRepo, orderService, subscriptionService),
+		orders:          orderService,
+		subscriptions:   subscriptionService,
+		transactionLogs: transactionLogRepo,
+		momo:            momo,
+		vnpay:           vnpay,
+		user:            user,
+	}
+}
+
+// checkout orders the monthly plan through the provider and, for VNPay, opens the payment page
+func (e *paymentTestEnv) checkout(t *testing.T, provider string) *entity.Order {
+	checkout, err := e.payments.CreateCheckout(e.user.ID, provider, "premium_monthly", "203.0.113.7")
+	require.NoError(t, err)
+	if provider == entity.PaymentProviderVNPay {
+		require.NoError(t, e.vnpay.Open(checkout.PayURL))
+	}
+	return checkout.Order
+}
+
+// pay pays the order at the fake gateway of its provider, returning the provider's transaction ID
+func (e *paymentTestEnv) pay(t *testing.T, order *entity.Order) string {
+	if order.Provider == entity.PaymentProviderVNPay {
+		transactionNo, ok := e.vnpay.Pay(order.Reference)
+		require.True(t, ok)
+		return transactionNo
+	}
+	transID, ok := e.momo.Pay(order.Reference)
+	require.True(t, ok)
+	return strconv.FormatInt(transID, 10)
+}
+
+// momoWebhook returns the request MoMo posts a notification with
+func momoWebhook(t *testing.T, notification *entity.MoMoIPN) *entity.WebhookRequest {
+	body, err := json.Marshal(notification)
+	require.NoError(t, err)
+	return &entity.WebhookRequest{Body: body}
+}
+
+func TestCreateCheckout(t *testing.T) {
+	e := setupPaymentService(t)
+	assert.Equal(t, []string{"momo", "vnpay"}, e.payments.Providers())
+
+	checkout, err := e.payments.CreateCheckout(e.user.ID, entity.PaymentProviderMoMo, "premium_monthly", "203.0.113.7")
+	require.NoError(t, err)
+	_, err = png.Decode(bytes.NewReader(checkout.QRCode))
+	assert.NoError(t, err, "the QR code is a PNG")
+	assert.NotEmpty(t, checkout.PayURL)
+	assert.NotEmpty(t, checkout.Deeplink)
+
+	order := checkout.Order
+	assert.Equal(t, e.user.ID, order.UserID)
+	assert.Equal(t, int64(99000), order.Amount, "the amount comes from the plan")
+	assert.Equal(t, entity.CurrencyVND, order.Currency)
+	assert.Equal(t, entity.PaymentProviderMoMo, order.Provider)
+	assert.Equal(t, entity.OrderStatusPending, order.Status)
+	sent, ok := e.momo.Payment(order.Reference)
+	require.True(t, ok)
+	assert.Equal(t, order.Amount, sent.Amount)
+	assert.Contains(t, sent.OrderInfo, "Premium")
+
+	checkout, err = e.payments.CreateCheckout(e.user.ID, entity.PaymentProviderVNPay, "premium_yearly", "203.0.113.7")
+	require.NoError(t, err)
+	assert.Equal(t, entity.PaymentProviderVNPay, checkout.Order.Provider)
+	_, err = png.Decode(bytes.NewReader(checkout.QRCode))
+	assert.NoError(t, err, "the QR code opens the payment page")
+	require.NoError(t, e.vnpay.Open(checkout.PayURL))
+	params, ok := e.vnpay.Payment(checkout.Order.Reference)
+	require.True(t, ok)
+	assert.Equal(t, "99000000", params.Get("vnp_Amount"))
+
+	_, err = e.payments.CreateCheckout(e.user.ID, "paypal", "premium_monthly", "")
+	assert.ErrorIs(t, err, ErrUnknownPaymentProvider)
+	_, err = e.payments.CreateCheckout(e.user.ID, entity.PaymentProviderMoMo, "unknown", "")
+	assert.ErrorIs(t, err, ErrPlanNotFound)
+
+	_, err = e.db.Exec(`INSERT INTO plans (code, name, amount, currency) VALUES ('premium_usd', 'Premium (USD)', 999, 'USD')`)
+	require.NoError(t, err)
+	for _, provider := range []string{entity.PaymentProviderMoMo, entity.PaymentProviderVNPay} {
+		_, err = e.payments.CreateCheckout(e.user.ID, provider, "premium_usd", "")
+		assert.ErrorIs(t, err, ErrPlanNotPayable, provider)
+	}
+	orders, err := e.orders.ListOrders(e.user.ID)
+	require.NoError(t, err)
+	require.Len(t, orders, 4)
+	assert.Equal(t, entity.OrderStatusFailed, orders[0].Status, "orders the provider never got are not left pending")
+	assert.Equal(t, entity.OrderStatusFailed, orders[1].Status)
+}
+
+func TestCheckPaymentStatus(t *testing.T) {
+	for _, provider := range []string{entity.PaymentProviderMoMo, entity.PaymentProviderVNPay} {
+		t.Run(provider, func(t *testing.T) {
+			e := setupPaymentService(t)
+			created := e.checkout(t, provider)
+
+			order, status, err := e.payments.CheckPaymentStatus(provider, created.Reference)
+			require.NoError(t, err)
+			assert.Equal(t, entity.PaymentStatePending, status.State)
+			assert.Equal(t, entity.OrderStatusPending, order.Status)
+
+			transactionID := e.pay(t, created)
+			order, status, err = e.payments.CheckPaymentStatus(provider, created.Reference)
+			require.NoError(t, err)
+			assert.Equal(t, entity.PaymentStatePaid, status.State)
+			assert.Equal(t, int64(99000), status.Amount)
+			assert.Equal(t, entity.OrderStatusPaid, order.Status)
+			assert.Equal(t, transactionID, order.TransactionID)
+			assert.NotNil(t, order.PaidAt)
+
+			_, _, err = e.payments.CheckPaymentStatus(provider, "unknown")
+			assert.ErrorIs(t, err, ErrOrderNotFound)
+		})
+	}
+
+	e := setupPaymentService(t)
+	order := e.checkout(t, entity.PaymentProviderMoMo)
+	_, _, err := e.payments.CheckPaymentStatus(entity.PaymentProviderVNPay, order.Reference)
+	assert.ErrorIs(t, err, ErrOrderNotFound, "the order is paid through another provider")
+	_, _, err = e.payments.CheckPaymentStatus("paypal", order.Reference)
+	assert.ErrorIs(t, err, ErrUnknownPaymentProvider)
+}
+
+func TestRefundPayment(t *testing.T) {
+	for _, provider := range []string{entity.PaymentProviderMoMo, entity.PaymentProviderVNPay} {
+		t.Run(provider, func(t *testing.T) {
+			e := setupPaymentService(t)
+			reference := e.checkout(t, provider).Reference
+
+			_, _, err := e.payments.RefundPayment(provider, reference, 0)
+			assert.ErrorIs(t, err, ErrPaymentNotPaid)
+
+			order, err := e.orders.GetOrder(reference)
+			require.NoError(t, err)
+			e.pay(t, order)
+			_, _, err = e.payments.CheckPaymentStatus(provider, reference)
+			require.NoError(t, err)
+
+			order, refund, err := e.payments.RefundPayment(provider, reference, 30000)
+			require.NoError(t, err)
+			assert.Equal(t, int64(30000), refund.Amount)
+			assert.Equal(t, entity.OrderStatusPartiallyRefunded, order.Status)
+			assert.Equal(t, int64(30000), order.RefundedAmount)
+
+			_, _, err = e.payments.RefundPayment(provider, reference, 80000)
+			assert.ErrorIs(t, err, ErrRefundTooLarge)
+			_, _, err = e.payments.RefundPayment(provider, reference, -1)
+			assert.ErrorIs(t, err, ErrInvalidPaymentAmount)
+
+			// Without an amount, what is left is refunded
+			order, refund, err = e.payments.RefundPayment(provider, reference, 0)
+			require.NoError(t, err)
+			assert.Equal(t, int64(69000), refund.Amount)
+			assert.Equal(t, entity.OrderStatusRefunded, order.Status)
+			_, _, err = e.payments.RefundPayment(provider, reference, 0)
+			assert.ErrorIs(t, err, ErrRefundTooLarge)
+
+			_, _, err = e.payments.RefundPayment(provider, "unknown", 0)
+			assert.ErrorIs(t, err, ErrOrderNotFound)
+		})
+	}
+}
+
+func TestHandleMoMoWebhook(t *testing.T) {
+	e := setupPaymentService(t)
+	order := e.checkout(t, entity.PaymentProviderMoMo)
+	transID := e.pay(t, order)
+	notification, ok := e.momo.Notification(order.Reference)
+	require.True(t, ok)
+
+	require.NoError(t, e.payments.HandleWebhook("momo", momoWebhook(t, notification)))
+	paid, err := e.orders.GetOrder(order.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, entity.OrderStatusPaid, paid.Status)
+	assert.Equal(t, transID, paid.TransactionID)
+	logs, err := e.transactionLogs.ListTransactionLogs(order.Reference)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "momo", logs[0].PaymentMethod)
+	assert.Equal(t, entity.TransactionStatusPaid, logs[0].Status)
+	assert.Contains(t, logs[0].Details, transID)
+
+	// MoMo retries until answered; retries are accepted without logging again
+	require.NoError(t, e.payments.HandleWebhook("momo", momoWebhook(t, notification)))
+	logs, err = e.transactionLogs.ListTransactionLogs(order.Reference)
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+
+	tampered := *notification
+	tampered.Amount = 1000
+	assert.ErrorIs(t, e.payments.HandleWebhook("momo", momoWebhook(t, &tampered)), repo.ErrInvalidWebhookSignature)
+	tampered = *notification
+	tampered.Signature = entity.SignMoMo("other-secret", tampered.RawSignature(e.momo.AccessKey))
+	assert.ErrorIs(t, e.payments.HandleWebhook("momo", momoWebhook(t, &tampered)), repo.ErrInvalidWebhookSignature)
+	tampered = *notification
+	tampered.PartnerCode = "OTHER"
+	tampered.Sign(e.momo.AccessKey, e.momo.SecretKey)
+	assert.ErrorIs(t, e.payments.HandleWebhook("momo", momoWebhook(t, &tampered)), repo.ErrInvalidWebhookSignature)
+	assert.ErrorIs(t, e.payments.HandleWebhook("vnpay", momoWebhook(t, notification)), repo.ErrInvalidWebhookSignature,
+		"a notification is only valid for its provider")
+
+	// A signed notification replayed long after is refused
+	replayed := *notification
+	replayed.RequestID = "replayed"
+	replayed.ResponseTime = time.Now().Add(-WebhookMaxAge - time.Minute).UnixMilli()
+	replayed.Sign(e.momo.AccessKey, e.momo.SecretKey)
+	assert.ErrorIs(t, e.payments.HandleWebhook("momo", momoWebhook(t, &replayed)), ErrStaleWebhook)
+	logs, err = e.transactionLogs.ListTransactionLogs(order.Reference)
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+}
+
+func TestHandleWebhookChecksOrder(t *testing.T) {
+	e := setupPaymentService(t)
+	reference := e.checkout(t, entity.PaymentProviderMoMo).Reference
+	require.True(t, e.momo.Fail(reference, entity.MoMoResultDeniedByUser))
+	notification, ok := e.momo.Notification(reference)
+	require.True(t, ok)
+
+	// A notification for another amount than the order's is refused, even when signed
+	wrongAmount := *notification
+	wrongAmount.RequestID = "other"
+	wrongAmount.Amount = 1000
+	wrongAmount.Sign(e.momo.AccessKey, e.momo.SecretKey)
+	assert.ErrorIs(t, e.payments.HandleWebhook("momo", momoWebhook(t, &wrongAmount)), ErrWebhookAmountMismatch)
+
+	require.NoError(t, e.payments.HandleWebhook("momo", momoWebhook(t, notification)))
+	order, err := e.orders.GetOrder(reference)
+	require.NoError(t, err)
+	assert.Equal(t, entity.OrderStatusFailed, order.Status)
+	logs, err := e.transactionLogs.ListTransactionLogs(reference)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, entity.TransactionStatusFailed, logs[0].Status)
+
+	// A failed order cannot be paid anymore; the notification is still logged
+	paid := *notification
+	paid.RequestID = "late"
+	paid.ResultCode = entity.MoMoResultSuccess
+	paid.Sign(e.momo.AccessKey, e.momo.SecretKey)
+	require.NoError(t, e.payments.HandleWebhook("momo", momoWebhook(t, &paid)))
+	order, err = e.orders.GetOrder(reference)
+	require.NoError(t, err)
+	assert.Equal(t, entity.OrderStatusFailed, order.Status)
+	logs, err = e.transactionLogs.ListTransactionLogs(reference)
+	require.NoError(t, err)
+	assert.Len(t, logs, 2)
+
+	// Notifications of unknown orders are logged for the payment to be traced
+	unknown := *notification
+	unknown.OrderID = "ord-unknown"
+	unknown.RequestID = "unknown"
+	unknown.Sign(e.momo.AccessKey, e.momo.SecretKey)
+	assert.ErrorIs(t, e.payments.HandleWebhook("momo", momoWebhook(t, &unknown)), ErrOrderNotFound)
+	logs, err = e.transactionLogs.ListTransactionLogs("ord-unknown")
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+}
+
+func TestHandleVNPayWebhook(t *testing.T) {
+	e := setupPaymentService(t)
+	order := e.checkout(t, entity.PaymentProviderVNPay)
+	transactionNo := e.pay(t, order)
+	params, ok := e.vnpay.Notification(order.Reference)
+	require.True(t, ok)
+
+	require.NoError(t, e.payments.HandleWebhook("vnpay", &entity.WebhookRequest{Query: params}))
+	require.NoError(t, e.payments.HandleWebhook("vnpay", &entity.WebhookRequest{Query: params}))
+	paid, err := e.orders.GetOrder(order.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, entity.OrderStatusPaid, paid.Status)
+	assert.Equal(t, transactionNo, paid.TransactionID)
+	logs, err := e.transactionLogs.ListTransactionLogs(order.Reference)
+	require.NoError(t, err)
+	require.Len(t, logs, 1, "retries are logged once")
+	assert.Equal(t, "vnpay", logs[0].PaymentMethod)
+
+	wrongAmount := url.Values{}
+	for name, values := range params {
+		wrongAmount[name] = values
+	}
+	wrongAmount.Set("vnp_TransactionNo", "other")
+	wrongAmount.Set("vnp_Amount", "100000")
+	assert.ErrorIs(t, e.payments.HandleWebhook("vnpay", &entity.WebhookRequest{Query: wrongAmount}), repo.ErrInvalidWebhookSignature)
+	vnpaytest.Sign(wrongAmount, e.vnpay.HashSecret)
+	err = e.payments.HandleWebhook("vnpay", &entity.WebhookRequest{Query: wrongAmount})
+	assert.ErrorIs(t, err, ErrWebhookAmountMismatch)
+
+	response, err := e.payments.WebhookResponse("vnpay", err)
+	require.NoError(t, err)
+	assert.Equal(t, entity.VNPayIPNResponse{RspCode: entity.VNPayIPNInvalidAmount, Message: "Invalid amount"}, response.Body)
+	_, err = e.payments.WebhookResponse("paypal", nil)
+	assert.ErrorIs(t, err, ErrUnknownPaymentProvider)
+}
+
+func TestWebhookOutcome(t *testing.T) {
+	tests := []struct {
+		err  error
+		want entity.WebhookOutcome
+	}{
+		{nil, entity.WebhookAccepted},
+		{repo.ErrInvalidWebhookSignature, entity.WebhookInvalidSignature},
+		{fmt.Errorf("%w: unexpected end of JSON input", repo.ErrMalformedWebhook), entity.WebhookRejected},
+		{ErrStaleWebhook, entity.WebhookRejected},
+		{ErrOrderNotFound, entity.WebhookOrderNotFound},
+		{ErrWebhookAmountMismatch, entity.WebhookAmountMismatch},
+		{sql.ErrConnDone, entity.WebhookFailed},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, webhookOutcome(tt.err), "%v", tt.err)
+	}
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/repo"
+	"strings"
+)
+
+var (
+	ErrPipelineNotFound = errors.New("pipeline not found")
+	ErrInvalidPipeline  = errors.New("invalid pipeline")
+)
+
+// PipelineTarget is a language the video is translated to
+type PipelineTarget struct {
+	Lang       string
+	Synthesize bool // Synthesize speech from the translation
+	Mux        bool // Replace the audio track of the video with the synthesized speech, requires Synthesize
+}
+
+// PipelineSpec declares what to run for a video, e.g. "transcribe en, translate to vi and ja, synthesize audio for each"
+type PipelineSpec struct {
+	SourceLang      string // Spoken language of the video; defaults to the language of TranscriptionID
+	TranscriptionID uint64 // Existing transcription to translate instead of transcribing the video
+	Targets         []PipelineTarget
+}
+
+type PipelineService interface {
+	CreatePipeline(userID, videoID uint64, spec PipelineSpec) (*entity.Pipeline, error)
+	GetPipeline(videoID, pipelineID uint64) (*entity.Pipeline, error)
+	ListPipelinesByVideoID(videoID uint64) ([]entity.Pipeline, error)
+	AdvancePipeline(pipelineID uint64) (*entity.Pipeline, error)
+}
+
+type pipelineService struct {
+	pipelineRepo      repo.PipelineRepository
+	videoRepo         repo.VideoRepository
+	transcriptionRepo repo.TranscriptionRepository
+	jobService        JobService
+	mlWorkerService   MLWorkerService
+}
+
+func NewPipelineService(pipelineRepo repo.PipelineRepository, videoRepo repo.VideoRepository, transcriptionRepo repo.TranscriptionRepository,
+	jobService JobService, mlWorkerService MLWorkerService) PipelineService {
+	return &pipelineService{
+		pipelineRepo:      pipelineRepo,
+		videoRepo:         videoRepo,
+		transcriptionRepo: transcriptionRepo,
+		jobService:        jobService,
+		mlWorkerService:   mlWorkerService,
+	}
+}
+
+// CreatePipeline builds the steps declared by spec for the video, stores them and queues the steps without dependencies.
+// Each target gets an mt step, optionally followed by tts and mux, all depending on the stt step unless a transcription is given.
+func (s *pipelineService) CreatePipeline(userID, videoID uint64, spec PipelineSpec) (*entity.Pipeline, error) {
+	video, err := s.videoRepo.GetVideoByID(videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video == nil {
+		return nil, ErrVideoNotFound
+	}
+
+	sourceLang := normalizeLang(spec.SourceLang)
+	if spec.TranscriptionID != 0 {
+		transcription, err := s.transcriptionRepo.GetTranscriptionByIDAndVideoID(spec.TranscriptionID, videoID)
+		if err != nil {
+			return nil, err
+		}
+		if transcription == nil {
+			return nil, fmt.Errorf("%w: transcription %d not found for video %d", ErrInvalidPipeline, spec.TranscriptionID, videoID)
+		}
+		if sourceLang == "" {
+			sourceLang = normalizeLang(transcription.Lang)
+		}
+	}
+	if sourceLang == "" {
+		return nil, fmt.Errorf("%w: source language is required", ErrInvalidPipeline)
+	}
+
+	pipeline := &entity.Pipeline{VideoID: videoID, UserID: userID, SourceLang: sourceLang}
+	root := ""
+	if spec.TranscriptionID == 0 {
+		root = "stt:" + sourceLang
+		pipeline.Steps = append(pipeline.Steps, entity.PipelineStep{Name: root, Type: entity.MLTaskSpeechToText, SourceLang: sourceLang})
+	}
+
+	seen := map[string]bool{}
+	for _, target := range spec.Targets {
+		lang := normalizeLang(target.Lang)
+		switch {
+		case lang == "" || lang == sourceLang:
+			return nil, fmt.Errorf("%w: target language %q must differ from the source language", ErrInvalidPipeline, target.Lang)
+		case seen[lang]:
+			return nil, fmt.Errorf("%w: target language %q is listed twice", ErrInvalidPipeline, lang)
+		case target.Mux && !target.Synthesize:
+			return nil, fmt.Errorf("%w: muxing %q requires synthesizing its audio", ErrInvalidPipeline, lang)
+		}
+		seen[lang] = true
+
+		translation := entity.PipelineStep{Name: "mt:" + lang, Type: entity.MLTaskTranslation, SourceLang: sourceLang, TargetLang: lang, DependsOn: root}
+		if root == "" {
+			translation.InputTranscriptionID = spec.TranscriptionID
+		}
+		pipeline.Steps = append(pipeline.Steps, translation)
+		if target.Synthesize {
+			pipeline.Steps = append(pipeline.Steps, entity.PipelineStep{Name: "tts:" + lang, Type: entity.MLTaskTextToSpeech, SourceLang: lang, DependsOn: translation.Name})
+		}
+		if target.Mux {
+			pipeline.Steps = append(pipeline.Steps, entity.PipelineStep{Name: "mux:" + lang, Type: entity.MLTaskMux, SourceLang: lang, DependsOn: "tts:" + lang})
+		}
+	}
+	if len(pipeline.Steps) == 0 {
+		return nil, fmt.Errorf("%w: no step to run", ErrInvalidPipeline)
+	}
+
+	if err := s.pipelineRepo.CreatePipeline(pipeline); err != nil {
+		return nil, fmt.Errorf("failed to create pipeline: %v", err)
+	}
+	if err := s.advance(pipeline); err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+// GetPipeline retrieves a pipeline of the video with its status brought up to date
+func (s *pipelineService) GetPipeline(videoID, pipelineID uint64) (*entity.Pipeline, error) {
+	pipeline, err := s.pipelineRepo.GetPipelineByID(pipelineID)
+	if err != nil {
+		return nil, err
+	}
+	if pipeline == nil || pipeline.VideoID != videoID {
+		return nil, ErrPipelineNotFound
+	}
+	if err := s.advance(pipeline); err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+// ListPipelinesByVideoID lists the pipelines of the video, newest first, with their status brought up to date
+func (s *pipelineService) ListPipelinesByVideoID(videoID uint64) ([]entity.Pipeline, error) {
+	pipelines, err := s.pipelineRepo.ListPipelinesByVideoID(videoID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pipelines {
+		if err := s.advance(&pipelines[i]); err != nil {
+			return nil, err
+		}
+	}
+	return pipelines, nil
+}
+
+// AdvancePipeline queues the steps whose dependencies succeeded, skips those whose dependencies failed
+// and finishes the pipeline, and its video, once every step is done. It is safe to run concurrently.
+func (s *pipelineService) AdvancePipeline(pipelineID uint64) (*entity.Pipeline, error) {
+	pipeline, err := s.pipelineRepo.GetPipelineByID(pipelineID)
+	if err != nil {
+		return nil, err
+	}
+	if pipeline == nil {
+		return nil, ErrPipelineNotFound
+	}
+	if err := s.advance(pipeline); err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+func (s *pipelineService) advance(pipeline *entity.Pipeline) error {
+	jobs := map[uint64]*entity.Job{}
+	if pipeline.Status == entity.PipelineStatusRunning {
+		// Steps are stored after the step they depend on, so one pass in order sees every dependency settled first
+		byName := map[string]*entity.PipelineStep{}
+		for i := range pipeline.Steps {
+			step := &pipeline.Steps[i]
+			byName[step.Name] = step
+
+			var err error
+			switch step.Status {
+			case entity.PipelineStepQueued:
+				err = s.reconcileStep(step, jobs)
+			case entity.PipelineStepPending:
+				err = s.startStep(pipeline, step, byName[step.DependsOn])
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := s.finish(pipeline); err != nil {
+			return err
+		}
+	}
+
+	s.summarize(pipeline, jobs)
+	return nil
+}
+
+// reconcileStep fails a queued step whose task was dead-lettered
+func (s *pipelineService) reconcileStep(step *entity.PipelineStep, jobs map[uint64]*entity.Job) error {
+	if step.JobID == 0 {
+		return nil
+	}
+	job, err := s.jobService.GetJob(step.JobID)
+	if err != nil {
+		return err
+	}
+	jobs[job.ID] = job
+	if job.Status != entity.JobStatusDead {
+		return nil
+	}
+
+	step.Status = entity.PipelineStepFailed
+	step.Error = job.LastError
+	return s.updateStep(step, entity.PipelineStepQueued)
+}
+
+// startStep queues the task of a pending step once the step it depends on succeeded, or skips it when that step failed
+func (s *pipelineService) startStep(pipeline *entity.Pipeline, step, dependency *entity.PipelineStep) error {
+	if dependency != nil {
+		switch dependency.Status {
+		case entity.PipelineStepFailed, entity.PipelineStepSkipped:
+			step.Status = entity.PipelineStepSkipped
+			step.Error = fmt.Sprintf("step %s did not succeed", dependency.Name)
+			return s.updateStep(step, entity.PipelineStepPending)
+		case entity.PipelineStepSucceeded:
+			switch step.Type {
+			case entity.MLTaskTranslation, entity.MLTaskTextToSpeech:
+				step.InputTranscriptionID = dependency.OutputTranscriptionID
+			case entity.MLTaskMux:
+				step.InputAudioID = dependency.OutputAudioID
+			}
+		default:
+			return nil
+		}
+	}
+
+	// Claim the step first so concurrent advances do not queue its task twice
+	step.Status = entity.PipelineStepQueued
+	err := s.pipelineRepo.UpdatePipelineStep(step, entity.PipelineStepPending)
+	if errors.Is(err, repo.ErrPipelineStepChanged) {
+		return s.reloadStep(step)
+	}
+	if err != nil {
+		return err
+	}
+
+	job, err := s.mlWorkerService.EnqueueTask(MLTaskRequest{
+		Type:            step.Type,
+		VideoID:         pipeline.VideoID,
+		SourceLang:      step.SourceLang,
+		TargetLang:      step.TargetLang,
+		TranscriptionID: step.InputTranscriptionID,
+		AudioID:         step.InputAudioID,
+		PipelineStepID:  step.ID,
+	})
+	if errors.Is(err, ErrInvalidMLTask) || errors.Is(err, ErrVideoNotFound) {
+		step.Status = entity.PipelineStepFailed
+		step.Error = err.Error()
+		return s.updateStep(step, entity.PipelineStepQueued)
+	}
+	if err != nil {
+		step.Status = entity.PipelineStepPending
+		if updateErr := s.updateStep(step, entity.PipelineStepQueued); updateErr != nil {
+			log.Errorf("Failed to release pipeline step %d: %v", step.ID, updateErr)
+		}
+		return fmt.Errorf("failed to queue pipeline step %s: %v", step.Name, err)
+	}
+
+	step.JobID = job.ID
+	return s.updateStep(step, entity.PipelineStepQueued)
+}
+
+// updateStep stores the step, reloading it instead when another update moved it first
+func (s *pipelineService) updateStep(step *entity.PipelineStep, from entity.PipelineStepStatus) error {
+	err := s.pipelineRepo.UpdatePipelineStep(step, from)
+	if !errors.Is(err, repo.ErrPipelineStepChanged) {
+		return err
+	}
+	return s.reloadStep(step)
+}
+
+func (s *pipelineService) reloadStep(step *entity.PipelineStep) error {
+	current, err := s.pipelineRepo.GetPipelineStepByID(step.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return fmt.Errorf("pipeline step %d no longer exists", step.ID)
+	}
+	*step = *current
+	return nil
+}
+
+// finish completes the pipeline and its video once every step is done
+func (s *pipelineService) finish(pipeline *entity.Pipeline) error {
+	status := entity.PipelineStatusSucceeded
+	errorMessage := ""
+	for _, step := range pipeline.Steps {
+		if !step.Status.IsFinished() {
+			return nil
+		}
+		if step.Status == entity.PipelineStepFailed && errorMessage == "" {
+			status = entity.PipelineStatusFailed
+			errorMessage = fmt.Sprintf("step %s failed: %s", step.Name, step.Error)
+		}
+	}
+
+	if err := s.pipelineRepo.UpdatePipelineStatus(pipeline.ID, status, errorMessage); err != nil {
+		return err
+	}
+	pipeline.Status = status
+	pipeline.Error = errorMessage
+
+	videoStatus := entity.StatusSuccess
+	if status == entity.PipelineStatusFailed {
+		videoStatus = entity.StatusFailed
+	}
+	if err := s.videoRepo.UpdateVideoStatus(pipeline.VideoID, videoStatus); err != nil {
+		log.Warnf("Failed to mark video %d as %s: %v", pipeline.VideoID, videoStatus, err)
+	}
+	return nil
+}
+
+// summarize fills the progress of the steps and of the pipeline, reporting queued steps leased by a worker as running
+func (s *pipelineService) summarize(pipeline *entity.Pipeline, jobs map[uint64]*entity.Job) {
+	total := 0
+	for i := range pipeline.Steps {
+		step := &pipeline.Steps[i]
+		switch {
+		case step.Status.IsFinished():
+			step.Progress = 100
+		case step.Status == entity.PipelineStepQueued && step.JobID != 0:
+			job, ok := jobs[step.JobID]
+			if !ok {
+				var err error
+				if job, err = s.jobService.GetJob(step.JobID); err != nil {
+					log.Warnf("Failed to load task %d of pipeline step %d: %v", step.JobID, step.ID, err)
+					break
+				}
+			}
+			step.Progress = job.Progress
+			if job.Status == entity.JobStatusRunning {
+				step.Status = entity.PipelineStepRunning
+			}
+		}
+		total += step.Progress
+	}
+	if len(pipeline.Steps) > 0 {
+		pipeline.Progress = total / len(pipeline.Steps)
+	}
+}
+
+func normalizeLang(lang string) string {
+	return strings.ToLower(strings.TrimSpace(lang))
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"mlvt/internal/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPipelineService is a mock implementation of the PipelineService interface
+type MockPipelineService struct {
+	mock.Mock
+}
+
+func (m *MockPipelineService) CreatePipeline(userID, videoID uint64, spec PipelineSpec) (*entity.Pipeline, error) {
+	args := m.Called(userID, videoID, spec)
+	pipeline, _ := args.Get(0).(*entity.Pipeline)
+	return pipeline, args.Error(1)
+}
+
+func (m *MockPipelineService) GetPipeline(videoID, pipelineID uint64) (*entity.Pipeline, error) {
+	args := m.Called(videoID, pipelineID)
+	pipeline, _ := args.Get(0).(*entity.Pipeline)
+	return pipeline, args.Error(1)
+}
+
+func (m *MockPipelineService) ListPipelinesByVideoID(videoID uint64) ([]entity.Pipeline, error) {
+	args := m.Called(videoID)
+	pipelines, _ := args.Get(0).([]entity.Pipeline)
+	return pipelines, args.Error(1)
+}
+
+func (m *MockPipelineService) AdvancePipeline(pipelineID uint64) (*entity.Pipeline, error) {
+	args := m.Called(pipelineID)
+	pipeline, _ := args.Get(0).(*entity.Pipeline)
+	return pipeline, args.Error(1)
+}
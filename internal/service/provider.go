@@ -12,9 +12,22 @@ var SecretKey = env.EnvConfig.JWTSecret
 var ProviderSetService = wire.NewSet(
 	NewAuthService,
 	NewUserService,
+	NewVerificationService,
+	NewTwoFactorService,
+	NewLoginThrottle,
+	NewAPIKeyService,
+	NewOIDCService,
 	NewVideoService,
+	NewJobService,
+	NewMLWorkerService,
+	NewPipelineService,
 	NewAudioService,
 	NewTranscriptionService,
-	NewMoMoPaymentService,
+	NewOwnershipService,
+	NewOrganizationService,
+	NewAdminService,
+	NewOrderService,
+	NewSubscriptionService,
+	NewPaymentService,
 	wire.Value(SecretKey),
 )
@@ -0,0 +1,349 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/repo"
+	"time"
+)
+
+var (
+	ErrSubscriptionNotFound     = errors.New("no premium subscription")
+	ErrSubscriptionNotResumable = errors.New("only a canceled subscription whose period has not ended can be resumed")
+	ErrOrderNotPaid             = errors.New("the order is not paid")
+	ErrOrderNotRefunded         = errors.New("the order is not refunded in full")
+	ErrVideoQuotaExceeded       = errors.New("the plan allows no more videos")
+	ErrVideoTooLong             = errors.New("the video is longer than the plan allows")
+)
+
+// maxSubscriptionUpdateAttempts bounds how often a change is retried when the subscription changed meanwhile
+const maxSubscriptionUpdateAttempts = 3
+
+// SubscriptionService manages the premium subscriptions of users. Each paid order adds the billing period of its
+// plan to the subscription of its user, after the time already paid for. A subscription whose period ended unpaid
+// is past due and keeps premium for the grace period, then expires; a canceled one expires when its period ends.
+type SubscriptionService interface {
+	// GetSubscription returns the subscription of the user and its plan, or ErrSubscriptionNotFound if the user never subscribed
+	GetSubscription(userID uint64) (*entity.Subscription, *entity.Plan, error)
+	ListPeriods(userID uint64) ([]entity.SubscriptionPeriod, error) // Newest first
+	// Quotas returns the quotas of the plan of the user while subscribed, and the free ones otherwise
+	Quotas(userID uint64) (entity.Quotas, error)
+	// CheckVideoQuota returns ErrVideoQuotaExceeded when the user has as many videos as their quotas allow, and
+	// ErrVideoTooLong when the duration in seconds exceeds them. A duration of 0 is not known yet and not checked.
+	CheckVideoQuota(userID uint64, duration int) error
+	// GracePeriodEnd returns until when a past due subscription keeps premium
+	GracePeriodEnd(sub *entity.Subscription) time.Time
+	// ActivateOrder adds the period of a paid order to the subscription of its user, creating or reactivating it,
+	// and grants premium. An order adds its period once, so calling it again for the same order is harmless.
+	ActivateOrder(order *entity.Order) error
+	// RevokeOrder takes the period of a refunded order out of the subscription of its user: the periods paid after
+	// it move back by its length and the subscription ends that much earlier. A subscription left without paid time
+	// expires at once, which ends premium. Calling it again for the same order is harmless.
+	RevokeOrder(order *entity.Order) error
+	// Cancel stops the subscription from being renewed. Premium is kept until the period ends, except for a
+	// subscription already past due, which expires at once.
+	Cancel(userID uint64) (*entity.Subscription, error)
+	// Resume undoes Cancel while the period has not ended
+	Resume(userID uint64) (*entity.Subscription, error)
+	// ExpireSubscriptions moves the subscriptions whose period ended by now to past due, and those past the grace
+	// period or canceled to expired, which drops their users back to free. It returns how many expired.
+	ExpireSubscriptions(now time.Time) (int, error)
+}
+
+type subscriptionService struct {
+	subscriptionRepo repo.SubscriptionRepository
+	planRepo         repo.PlanRepository
+	videoRepo        repo.VideoRepository
+	gracePeriod      time.Duration
+}
+
+func NewSubscriptionService(subscriptionRepo repo.SubscriptionRepository, planRepo repo.PlanRepository, videoRepo repo.VideoRepository) SubscriptionService {
+	return &subscriptionService{
+		subscriptionRepo: subscriptionRepo,
+		planRepo:         planRepo,
+		videoRepo:        videoRepo,
+		gracePeriod:      env.EnvConfig.SubscriptionGracePeriod,
+	}
+}
+
+func (s *subscriptionService) GetSubscription(userID uint64) (*entity.Subscription, *entity.Plan, error) {
+	sub, err := s.subscription(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := s.planRepo.GetPlanByID(sub.PlanID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if plan == nil {
+		return nil, nil, ErrPlanNotFound
+	}
+	return sub, plan, nil
+}
+
+func (s *subscriptionService) subscription(userID uint64) (*entity.Subscription, error) {
+	sub, err := s.subscriptionRepo.GetSubscriptionByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, ErrSubscriptionNotFound
+	}
+	return sub, nil
+}
+
+func (s *subscriptionService) ListPeriods(userID uint64) ([]entity.SubscriptionPeriod, error) {
+	sub, err := s.subscription(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.subscriptionRepo.ListSubscriptionPeriods(sub.ID)
+}
+
+func (s *subscriptionService) Quotas(userID uint64) (entity.Quotas, error) {
+	sub, plan, err := s.GetSubscription(userID)
+	if errors.Is(err, ErrSubscriptionNotFound) {
+		return entity.FreeQuotas, nil
+	}
+	if err != nil {
+		return entity.Quotas{}, err
+	}
+	return sub.Quotas(plan), nil
+}
+
+func (s *subscriptionService) CheckVideoQuota(userID uint64, duration int) error {
+	quotas, err := s.Quotas(userID)
+	if err != nil {
+		return err
+	}
+	if quotas.MaxVideoMinutes > 0 && duration > quotas.MaxVideoMinutes*60 {
+		return ErrVideoTooLong
+	}
+	if quotas.MaxVideos > 0 {
+		count, err := s.videoRepo.CountVideosByUserID(userID)
+		if err != nil {
+			return err
+		}
+		if count >= quotas.MaxVideos {
+			return ErrVideoQuotaExceeded
+		}
+	}
+	return nil
+}
+
+func (s *subscriptionService) GracePeriodEnd(sub *entity.Subscription) time.Time {
+	return sub.CurrentPeriodEnd.Add(s.gracePeriod)
+}
+
+func (s *subscriptionService) ActivateOrder(order *entity.Order) error {
+	if !order.Status.IsPaid() || order.PaidAt == nil {
+		return ErrOrderNotPaid
+	}
+	plan, err := s.planRepo.GetPlanByID(order.PlanID)
+	if err != nil {
+		return err
+	}
+	if plan == nil {
+		return ErrPlanNotFound
+	}
+
+	for attempt := 0; attempt < maxSubscriptionUpdateAttempts; attempt++ {
+		current, err := s.subscriptionRepo.GetSubscriptionByUserID(order.UserID)
+		if err != nil {
+			return err
+		}
+
+		// A renewal continues from the end of the time already paid for, even when past due, so the grace period
+		// is not free; a new or expired subscription starts from the payment
+		next := entity.Subscription{UserID: order.UserID}
+		start := *order.PaidAt
+		if current != nil {
+			next = *current
+			if current.Status != entity.SubscriptionStatusExpired {
+				start = current.CurrentPeriodEnd
+			}
+		}
+		end := plan.BillingPeriod.After(start)
+		if current == nil || current.Status == entity.SubscriptionStatusExpired || current.Status == entity.SubscriptionStatusPastDue {
+			next.CurrentPeriodStart = start
+		}
+		next.PlanID = plan.ID
+		next.Status = entity.SubscriptionStatusActive
+		next.CurrentPeriodEnd = end
+		next.CanceledAt = nil
+
+		period := &entity.SubscriptionPeriod{OrderID: order.ID, PlanID: plan.ID, PeriodStart: start, PeriodEnd: end}
+		saved, err := s.subscriptionRepo.SaveSubscription(&next, current, period)
+		if errors.Is(err, repo.ErrPeriodExists) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if saved {
+			log.Infof("Order %s extended the subscription of user %d to %s", order.Reference, order.UserID, end.Format(time.RFC3339))
+			return nil
+		}
+	}
+	return fmt.Errorf("subscription of user %d keeps changing", order.UserID)
+}
+
+func (s *subscriptionService) RevokeOrder(order *entity.Order) error {
+	if order.Status != entity.OrderStatusRefunded {
+		return ErrOrderNotRefunded
+	}
+
+	for attempt := 0; attempt < maxSubscriptionUpdateAttempts; attempt++ {
+		current, err := s.subscriptionRepo.GetSubscriptionByUserID(order.UserID)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			return nil
+		}
+		periods, err := s.subscriptionRepo.ListSubscriptionPeriods(current.ID)
+		if err != nil {
+			return err
+		}
+		index := -1
+		for i := range periods {
+			if periods[i].OrderID == order.ID {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil
+		}
+		removed := periods[index]
+		length := removed.PeriodEnd.Sub(removed.PeriodStart)
+
+		// The periods paid right after it move back; a gap means the subscription expired in between and the
+		// later periods started from their payments
+		var moved []entity.SubscriptionPeriod
+		end := removed.PeriodEnd
+		for i := index - 1; i >= 0 && periods[i].PeriodStart.Equal(end); i-- {
+			end = periods[i].PeriodEnd
+			period := periods[i]
+			period.PeriodStart = period.PeriodStart.Add(-length)
+			period.PeriodEnd = period.PeriodEnd.Add(-length)
+			moved = append(moved, period)
+		}
+
+		next := *current
+		if end.Equal(current.CurrentPeriodEnd) {
+			next.CurrentPeriodEnd = current.CurrentPeriodEnd.Add(-length)
+		}
+		if index == 0 && len(periods) > 1 {
+			next.PlanID = periods[1].PlanID
+		}
+		if next.Status != entity.SubscriptionStatusExpired && !next.CurrentPeriodEnd.After(time.Now()) {
+			next.Status = entity.SubscriptionStatusExpired
+		}
+
+		saved, err := s.subscriptionRepo.RemoveSubscriptionPeriod(&next, current, &removed, moved)
+		if err != nil {
+			return err
+		}
+		if saved {
+			log.Infof("Refunded order %s shortened the subscription of user %d to %s", order.Reference, order.UserID, next.CurrentPeriodEnd.Format(time.RFC3339))
+			return nil
+		}
+	}
+	return fmt.Errorf("subscription of user %d keeps changing", order.UserID)
+}
+
+func (s *subscriptionService) Cancel(userID uint64) (*entity.Subscription, error) {
+	return s.change(userID, func(next *entity.Subscription, now time.Time) error {
+		switch next.Status {
+		case entity.SubscriptionStatusCanceled:
+			return errSubscriptionUnchanged
+		case entity.SubscriptionStatusActive:
+			next.Status = entity.SubscriptionStatusCanceled
+		case entity.SubscriptionStatusPastDue:
+			next.Status = entity.SubscriptionStatusExpired
+		default:
+			return ErrSubscriptionNotFound
+		}
+		next.CanceledAt = &now
+		return nil
+	})
+}
+
+func (s *subscriptionService) Resume(userID uint64) (*entity.Subscription, error) {
+	return s.change(userID, func(next *entity.Subscription, now time.Time) error {
+		switch {
+		case next.Status == entity.SubscriptionStatusActive:
+			return errSubscriptionUnchanged
+		case next.Status != entity.SubscriptionStatusCanceled || !next.CurrentPeriodEnd.After(now):
+			return ErrSubscriptionNotResumable
+		}
+		next.Status = entity.SubscriptionStatusActive
+		next.CanceledAt = nil
+		return nil
+	})
+}
+
+// errSubscriptionUnchanged is returned by the changes of change when the subscription already is as they would leave it
+var errSubscriptionUnchanged = errors.New("subscription unchanged")
+
+// change applies the change to a copy of the subscription of the user and saves it. When the subscription changed
+// since it was read, it is reloaded and the change applied again.
+func (s *subscriptionService) change(userID uint64, change func(next *entity.Subscription, now time.Time) error) (*entity.Subscription, error) {
+	for attempt := 0; attempt < maxSubscriptionUpdateAttempts; attempt++ {
+		current, err := s.subscription(userID)
+		if err != nil {
+			return nil, err
+		}
+		next := *current
+		if err := change(&next, time.Now()); err != nil {
+			if errors.Is(err, errSubscriptionUnchanged) {
+				return current, nil
+			}
+			return nil, err
+		}
+		saved, err := s.subscriptionRepo.SaveSubscription(&next, current, nil)
+		if err != nil {
+			return nil, err
+		}
+		if saved {
+			return &next, nil
+		}
+	}
+	return nil, fmt.Errorf("subscription of user %d keeps changing", userID)
+}
+
+func (s *subscriptionService) ExpireSubscriptions(now time.Time) (int, error) {
+	subs, err := s.subscriptionRepo.ListEndedSubscriptions(now)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range subs {
+		current := &subs[i]
+		next := *current
+		switch {
+		case current.Status == entity.SubscriptionStatusActive && now.Before(s.GracePeriodEnd(current)):
+			next.Status = entity.SubscriptionStatusPastDue
+		case current.Status == entity.SubscriptionStatusPastDue && now.Before(s.GracePeriodEnd(current)):
+			continue
+		default:
+			next.Status = entity.SubscriptionStatusExpired
+		}
+
+		// A subscription that changed meanwhile was renewed or canceled; the next check sees it again if need be
+		saved, err := s.subscriptionRepo.SaveSubscription(&next, current, nil)
+		if err != nil {
+			return expired, err
+		}
+		if saved && next.Status == entity.SubscriptionStatusExpired {
+			log.Infof("Subscription of user %d expired", next.UserID)
+			expired++
+		}
+	}
+	return expired, nil
+}
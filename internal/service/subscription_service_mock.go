@@ -0,0 +1,68 @@
+package service
+
+import (
+	"mlvt/internal/entity"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSubscriptionService is a mock implementation of the SubscriptionService interface
+type MockSubscriptionService struct {
+	mock.Mock
+}
+
+func (m *MockSubscriptionService) GetSubscription(userID uint64) (*entity.Subscription, *entity.Plan, error) {
+	args := m.Called(userID)
+	sub, _ := args.Get(0).(*entity.Subscription)
+	plan, _ := args.Get(1).(*entity.Plan)
+	return sub, plan, args.Error(2)
+}
+
+func (m *MockSubscriptionService) ListPeriods(userID uint64) ([]entity.SubscriptionPeriod, error) {
+	args := m.Called(userID)
+	periods, _ := args.Get(0).([]entity.SubscriptionPeriod)
+	return periods, args.Error(1)
+}
+
+func (m *MockSubscriptionService) Quotas(userID uint64) (entity.Quotas, error) {
+	args := m.Called(userID)
+	return args.Get(0).(entity.Quotas), args.Error(1)
+}
+
+func (m *MockSubscriptionService) CheckVideoQuota(userID uint64, duration int) error {
+	args := m.Called(userID, duration)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionService) GracePeriodEnd(sub *entity.Subscription) time.Time {
+	args := m.Called(sub)
+	return args.Get(0).(time.Time)
+}
+
+func (m *MockSubscriptionService) ActivateOrder(order *entity.Order) error {
+	args := m.Called(order)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionService) RevokeOrder(order *entity.Order) error {
+	args := m.Called(order)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionService) Cancel(userID uint64) (*entity.Subscription, error) {
+	args := m.Called(userID)
+	sub, _ := args.Get(0).(*entity.Subscription)
+	return sub, args.Error(1)
+}
+
+func (m *MockSubscriptionService) Resume(userID uint64) (*entity.Subscription, error) {
+	args := m.Called(userID)
+	sub, _ := args.Get(0).(*entity.Subscription)
+	return sub, args.Error(1)
+}
+
+func (m *MockSubscriptionService) ExpireSubscriptions(now time.Time) (int, error) {
+	args := m.Called(now)
+	return args.Int(0), args.Error(1)
+}
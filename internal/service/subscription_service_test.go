@@ -0,0 +1,319 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type subscriptionTestEnv struct {
+	subscriptions *subscriptionService
+	orders        OrderService
+	userRepo      repo.UserRepository
+	videoRepo     repo.VideoRepository
+	user          *entity.User
+}
+
+func setupSubscriptionService(t *testing.T) *subscriptionTestEnv {
+	db := setupOrderTestDB(t)
+	for _, name := range []string{
+		"0002_create_videos_table", "0003_create_transcriptions_table", "0006_create_audios_table",
+		"0009_add_file_info_columns", "0024_create_organizations_tables",
+	} {
+		schema, err := os.ReadFile("../../migration/" + name + ".up.sql")
+		require.NoError(t, err)
+		_, err = db.Exec(string(schema))
+		require.NoError(t, err, name)
+	}
+	userRepo := repo.NewUserRepo(db)
+	videoRepo := repo.NewVideoRepo(db)
+	user := createTestUser(t, userRepo, "subscriber", entity.UserRoleUser, entity.UserStatusAvailable, time.Now())
+	subscriptions := NewSubscriptionService(repo.NewSubscriptionRepo(db), repo.NewPlanRepo(db), videoRepo).(*subscriptionService)
+	subscriptions.gracePeriod = 72 * time.Hour
+	return &subscriptionTestEnv{
+		subscriptions: subscriptions,
+		orders:        NewOrderService(repo.NewOrderRepo(db), repo.NewPlanRepo(db)),
+		userRepo:      userRepo,
+		videoRepo:     videoRepo,
+		user:          user,
+	}
+}
+
+// paidOrder creates an order of the plan for the user and marks it paid
+func (e *subscriptionTestEnv) paidOrder(t *testing.T, planCode string) *entity.Order {
+	order, _, err := e.orders.CreateOrder(e.user.ID, planCode, entity.PaymentProviderMoMo)
+	require.NoError(t, err)
+	require.NoError(t, e.orders.MarkPaid(order, "txn-"+order.Reference))
+	return order
+}
+
+func (e *subscriptionTestEnv) premium(t *testing.T) bool {
+	user, err := e.userRepo.GetUserByID(e.user.ID)
+	require.NoError(t, err)
+	return user.Premium
+}
+
+func TestActivateOrder(t *testing.T) {
+	e := setupSubscriptionService(t)
+	_, _, err := e.subscriptions.GetSubscription(e.user.ID)
+	assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+
+	pending, _, err := e.orders.CreateOrder(e.user.ID, "premium_monthly", entity.PaymentProviderMoMo)
+	require.NoError(t, err)
+	assert.ErrorIs(t, e.subscriptions.ActivateOrder(pending), ErrOrderNotPaid)
+
+	monthly := e.paidOrder(t, "premium_monthly")
+	require.NoError(t, e.subscriptions.ActivateOrder(monthly))
+	sub, plan, err := e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "premium_monthly", plan.Code)
+	assert.Equal(t, entity.Quotas{MaxVideos: 100, MaxVideoMinutes: 120}, plan.Quotas)
+	assert.Equal(t, entity.SubscriptionStatusActive, sub.Status)
+	assert.WithinDuration(t, *monthly.PaidAt, sub.CurrentPeriodStart, time.Millisecond)
+	assert.WithinDuration(t, monthly.PaidAt.AddDate(0, 1, 0), sub.CurrentPeriodEnd, time.Millisecond)
+	assert.True(t, e.premium(t))
+
+	require.NoError(t, e.subscriptions.ActivateOrder(monthly), "an order adds its period once")
+	again, _, err := e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, sub.CurrentPeriodEnd, again.CurrentPeriodEnd)
+
+	yearly := e.paidOrder(t, "premium_yearly")
+	require.NoError(t, e.subscriptions.ActivateOrder(yearly))
+	renewed, plan, err := e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "premium_yearly", plan.Code)
+	assert.Equal(t, sub.CurrentPeriodStart, renewed.CurrentPeriodStart, "the current period goes on")
+	assert.Equal(t, sub.CurrentPeriodEnd.AddDate(1, 0, 0), renewed.CurrentPeriodEnd, "renewals add to the time already paid for")
+
+	periods, err := e.subscriptions.ListPeriods(e.user.ID)
+	require.NoError(t, err)
+	require.Len(t, periods, 2)
+	assert.Equal(t, yearly.ID, periods[0].OrderID)
+	assert.Equal(t, sub.CurrentPeriodEnd, periods[0].PeriodStart)
+	assert.Equal(t, monthly.ID, periods[1].OrderID)
+}
+
+func TestCancelAndResumeSubscription(t *testing.T) {
+	e := setupSubscriptionService(t)
+	_, err := e.subscriptions.Cancel(e.user.ID)
+	assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+
+	require.NoError(t, e.subscriptions.ActivateOrder(e.paidOrder(t, "premium_monthly")))
+	_, err = e.subscriptions.Resume(e.user.ID)
+	assert.NoError(t, err, "an active subscription is left as it is")
+
+	sub, err := e.subscriptions.Cancel(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.SubscriptionStatusCanceled, sub.Status)
+	assert.NotNil(t, sub.CanceledAt)
+	assert.True(t, e.premium(t), "premium is kept until the period ends")
+	_, err = e.subscriptions.Cancel(e.user.ID)
+	assert.NoError(t, err)
+
+	sub, err = e.subscriptions.Resume(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.SubscriptionStatusActive, sub.Status)
+	assert.Nil(t, sub.CanceledAt)
+
+	_, err = e.subscriptions.Cancel(e.user.ID)
+	require.NoError(t, err)
+	_, err = e.subscriptions.ExpireSubscriptions(sub.CurrentPeriodEnd)
+	require.NoError(t, err)
+	_, err = e.subscriptions.Resume(e.user.ID)
+	assert.ErrorIs(t, err, ErrSubscriptionNotResumable)
+	_, err = e.subscriptions.Cancel(e.user.ID)
+	assert.ErrorIs(t, err, ErrSubscriptionNotFound, "an expired subscription cannot be canceled")
+}
+
+func TestExpireSubscriptions(t *testing.T) {
+	e := setupSubscriptionService(t)
+	require.NoError(t, e.subscriptions.ActivateOrder(e.paidOrder(t, "premium_monthly")))
+	sub, _, err := e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	end := sub.CurrentPeriodEnd
+
+	expired, err := e.subscriptions.ExpireSubscriptions(end.Add(-time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, expired)
+
+	expired, err = e.subscriptions.ExpireSubscriptions(end.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, expired)
+	sub, _, err = e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.SubscriptionStatusPastDue, sub.Status)
+	assert.Equal(t, end.Add(72*time.Hour), e.subscriptions.GracePeriodEnd(sub))
+	assert.True(t, e.premium(t), "premium is kept for the grace period")
+
+	expired, err = e.subscriptions.ExpireSubscriptions(end.Add(72 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	sub, _, err = e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.SubscriptionStatusExpired, sub.Status)
+	assert.False(t, e.premium(t))
+
+	// The next payment starts a new period from the payment
+	order := e.paidOrder(t, "premium_monthly")
+	require.NoError(t, e.subscriptions.ActivateOrder(order))
+	sub, _, err = e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.SubscriptionStatusActive, sub.Status)
+	assert.WithinDuration(t, *order.PaidAt, sub.CurrentPeriodStart, time.Millisecond)
+	assert.True(t, e.premium(t))
+}
+
+func TestRenewPastDueSubscription(t *testing.T) {
+	e := setupSubscriptionService(t)
+	require.NoError(t, e.subscriptions.ActivateOrder(e.paidOrder(t, "premium_monthly")))
+	sub, _, err := e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	end := sub.CurrentPeriodEnd
+	_, err = e.subscriptions.ExpireSubscriptions(end.Add(time.Hour))
+	require.NoError(t, err)
+
+	require.NoError(t, e.subscriptions.ActivateOrder(e.paidOrder(t, "premium_monthly")))
+	sub, _, err = e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.SubscriptionStatusActive, sub.Status)
+	assert.Equal(t, end, sub.CurrentPeriodStart, "the grace period is not free")
+	assert.Equal(t, end.AddDate(0, 1, 0), sub.CurrentPeriodEnd)
+
+	// Canceling a past due subscription ends it at once
+	_, err = e.subscriptions.ExpireSubscriptions(sub.CurrentPeriodEnd)
+	require.NoError(t, err)
+	sub, err = e.subscriptions.Cancel(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.SubscriptionStatusExpired, sub.Status)
+	assert.False(t, e.premium(t))
+}
+
+func TestCheckVideoQuota(t *testing.T) {
+	e := setupSubscriptionService(t)
+	addVideos := func(n int) {
+		for i := 0; i < n; i++ {
+			require.NoError(t, e.videoRepo.CreateVideo(&entity.Video{Title: fmt.Sprintf("Video %d", i), UserID: e.user.ID, Status: entity.StatusRaw}))
+		}
+	}
+
+	quotas, err := e.subscriptions.Quotas(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.FreeQuotas, quotas)
+	assert.NoError(t, e.subscriptions.CheckVideoQuota(e.user.ID, 10*60))
+	assert.ErrorIs(t, e.subscriptions.CheckVideoQuota(e.user.ID, 10*60+1), ErrVideoTooLong)
+	addVideos(entity.FreeQuotas.MaxVideos - 1)
+	assert.NoError(t, e.subscriptions.CheckVideoQuota(e.user.ID, 0))
+	addVideos(1)
+	assert.ErrorIs(t, e.subscriptions.CheckVideoQuota(e.user.ID, 0), ErrVideoQuotaExceeded)
+
+	// Premium users get the quotas of their plan
+	require.NoError(t, e.subscriptions.ActivateOrder(e.paidOrder(t, "premium_monthly")))
+	quotas, err = e.subscriptions.Quotas(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.Quotas{MaxVideos: 100, MaxVideoMinutes: 120}, quotas)
+	assert.NoError(t, e.subscriptions.CheckVideoQuota(e.user.ID, 120*60))
+	assert.ErrorIs(t, e.subscriptions.CheckVideoQuota(e.user.ID, 120*60+1), ErrVideoTooLong)
+	addVideos(quotas.MaxVideos - entity.FreeQuotas.MaxVideos - 1)
+	assert.NoError(t, e.subscriptions.CheckVideoQuota(e.user.ID, 0))
+	addVideos(1)
+	assert.ErrorIs(t, e.subscriptions.CheckVideoQuota(e.user.ID, 0), ErrVideoQuotaExceeded)
+
+	// An expired subscription is back to the free quotas
+	sub, _, err := e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	_, err = e.subscriptions.ExpireSubscriptions(e.subscriptions.GracePeriodEnd(sub))
+	require.NoError(t, err)
+	quotas, err = e.subscriptions.Quotas(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.FreeQuotas, quotas)
+}
+
+func TestRevokeOrder(t *testing.T) {
+	e := setupSubscriptionService(t)
+	monthly := e.paidOrder(t, "premium_monthly")
+	require.NoError(t, e.subscriptions.ActivateOrder(monthly))
+	yearly := e.paidOrder(t, "premium_yearly")
+	require.NoError(t, e.subscriptions.ActivateOrder(yearly))
+	sub, _, err := e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	assert.ErrorIs(t, e.subscriptions.RevokeOrder(monthly), ErrOrderNotRefunded)
+
+	// The yearly period moves back to where the refunded monthly one started
+	require.NoError(t, e.orders.RecordRefund(monthly, monthly.Amount))
+	require.NoError(t, e.subscriptions.RevokeOrder(monthly))
+	revoked, plan, err := e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.SubscriptionStatusActive, revoked.Status)
+	assert.Equal(t, "premium_yearly", plan.Code)
+	assert.Equal(t, sub.CurrentPeriodStart, revoked.CurrentPeriodStart)
+	assert.Equal(t, sub.CurrentPeriodStart.AddDate(1, 0, 0), revoked.CurrentPeriodEnd)
+	periods, err := e.subscriptions.ListPeriods(e.user.ID)
+	require.NoError(t, err)
+	require.Len(t, periods, 1)
+	assert.Equal(t, yearly.ID, periods[0].OrderID)
+	assert.Equal(t, sub.CurrentPeriodStart, periods[0].PeriodStart)
+	assert.Equal(t, revoked.CurrentPeriodEnd, periods[0].PeriodEnd)
+	assert.True(t, e.premium(t))
+
+	require.NoError(t, e.subscriptions.RevokeOrder(monthly), "an order is taken out once")
+
+	// Without paid time left the subscription expires at once
+	require.NoError(t, e.orders.RecordRefund(yearly, yearly.Amount))
+	require.NoError(t, e.subscriptions.RevokeOrder(yearly))
+	revoked, _, err = e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.SubscriptionStatusExpired, revoked.Status)
+	assert.False(t, e.premium(t))
+}
+
+func TestFullRefundEndsPremium(t *testing.T) {
+	e := setupPaymentService(t)
+	order := e.checkout(t, entity.PaymentProviderMoMo)
+	e.pay(t, order)
+	_, _, err := e.payments.CheckPaymentStatus(entity.PaymentProviderMoMo, order.Reference)
+	require.NoError(t, err)
+	users := repo.NewUserRepo(e.db)
+	user, err := users.GetUserByID(e.user.ID)
+	require.NoError(t, err)
+	require.True(t, user.Premium)
+
+	// A partial refund leaves the subscription as it is
+	_, _, err = e.payments.RefundPayment(entity.PaymentProviderMoMo, order.Reference, 30000)
+	require.NoError(t, err)
+	sub, _, err := e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.SubscriptionStatusActive, sub.Status)
+
+	_, _, err = e.payments.RefundPayment(entity.PaymentProviderMoMo, order.Reference, 0)
+	require.NoError(t, err)
+	sub, _, err = e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.SubscriptionStatusExpired, sub.Status)
+	user, err = users.GetUserByID(e.user.ID)
+	require.NoError(t, err)
+	assert.False(t, user.Premium)
+}
+
+func TestPaidOrderActivatesPremium(t *testing.T) {
+	e := setupPaymentService(t)
+	order := e.checkout(t, entity.PaymentProviderVNPay)
+	e.pay(t, order)
+
+	_, _, err := e.payments.CheckPaymentStatus(entity.PaymentProviderVNPay, order.Reference)
+	require.NoError(t, err)
+	sub, plan, err := e.subscriptions.GetSubscription(e.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, order.PlanID, plan.ID)
+	assert.Equal(t, entity.SubscriptionStatusActive, sub.Status)
+	user, err := repo.NewUserRepo(e.db).GetUserByID(e.user.ID)
+	require.NoError(t, err)
+	assert.True(t, user.Premium)
+}
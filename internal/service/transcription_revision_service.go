@@ -0,0 +1,125 @@
+package service
+
+import (
+	"mlvt/internal/entity"
+	"mlvt/internal/repo"
+	"sort"
+)
+
+// ListRevisions lists the revisions of a transcription, newest first
+func (s *transcriptionService) ListRevisions(transcriptionID uint64) ([]entity.TranscriptionRevision, error) {
+	if _, err := s.getTranscription(transcriptionID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListTranscriptionRevisions(transcriptionID)
+}
+
+// GetRevision retrieves a revision of a transcription with the segments it left behind
+func (s *transcriptionService) GetRevision(transcriptionID uint64, number int) (*entity.TranscriptionRevision, error) {
+	if _, err := s.getTranscription(transcriptionID); err != nil {
+		return nil, err
+	}
+	revision, err := s.repo.GetTranscriptionRevision(transcriptionID, number)
+	if err != nil {
+		return nil, err
+	}
+	if revision == nil {
+		return nil, ErrRevisionNotFound
+	}
+	return revision, nil
+}
+
+// DiffRevisions compares the segments left behind by two revisions of a transcription
+func (s *transcriptionService) DiffRevisions(transcriptionID uint64, from, to int) ([]entity.SegmentChange, error) {
+	fromRevision, err := s.GetRevision(transcriptionID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRevision, err := s.GetRevision(transcriptionID, to)
+	if err != nil {
+		return nil, err
+	}
+	return diffSegments(fromRevision.Segments, toRevision.Segments), nil
+}
+
+// RestoreRevision puts the segments of a transcription back as they were after the given revision.
+// The restore is an edit of its own, so it is recorded as a new revision and can be undone in turn.
+func (s *transcriptionService) RestoreRevision(userID, transcriptionID uint64, number int) (*entity.TranscriptionRevision, error) {
+	revision, err := s.GetRevision(transcriptionID, number)
+	if err != nil {
+		return nil, err
+	}
+	transcription, err := s.getTranscription(transcriptionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.applyEdit(userID, transcription, repo.SegmentEdit{Restore: revision}, entity.RevisionActionRestore, number)
+}
+
+// applyEdit writes an edit of the segments of a transcription along with the text derived from them and the revision
+// recording the edit, all or nothing. Transcriptions that had segments before history was kept get an initial
+// revision of those segments first, so every revision can be diffed and restored.
+func (s *transcriptionService) applyEdit(userID uint64, transcription *entity.Transcription, edit repo.SegmentEdit, action entity.TranscriptionRevisionAction, restoredFrom int) (*entity.TranscriptionRevision, error) {
+	var text string
+	var revision *entity.TranscriptionRevision
+	err := s.repo.ApplySegmentEdit(transcription.ID, edit, func(before, after []entity.TranscriptionSegment, revisions int) (string, []*entity.TranscriptionRevision) {
+		var records []*entity.TranscriptionRevision
+		if len(before) > 0 && revisions == 0 {
+			records = append(records, &entity.TranscriptionRevision{
+				TranscriptionID: transcription.ID,
+				UserID:          transcription.UserID,
+				Action:          entity.RevisionActionInitial,
+				Changes:         diffSegments(nil, before),
+				Segments:        before,
+			})
+		}
+		revision = &entity.TranscriptionRevision{
+			TranscriptionID: transcription.ID,
+			UserID:          userID,
+			Action:          action,
+			RestoredFrom:    restoredFrom,
+			Changes:         diffSegments(before, after),
+			Segments:        after,
+		}
+		text = segmentsText(after)
+		return text, append(records, revision)
+	})
+	if err != nil {
+		return nil, err
+	}
+	transcription.Text = text
+	return revision, nil
+}
+
+// diffSegments matches segments by ID and lists the ones added, removed or modified, ordered by segment ID
+func diffSegments(before, after []entity.TranscriptionSegment) []entity.SegmentChange {
+	old := make(map[uint64]entity.TranscriptionSegment, len(before))
+	for _, segment := range before {
+		old[segment.ID] = segment
+	}
+
+	changes := []entity.SegmentChange{}
+	for i := range after {
+		segment := after[i]
+		previous, ok := old[segment.ID]
+		delete(old, segment.ID)
+		switch {
+		case !ok:
+			changes = append(changes, entity.SegmentChange{SegmentID: segment.ID, Type: entity.SegmentAdded, After: &segment})
+		case !sameSegmentContent(previous, segment):
+			changes = append(changes, entity.SegmentChange{SegmentID: segment.ID, Type: entity.SegmentModified, Before: &previous, After: &segment})
+		}
+	}
+	for _, segment := range old {
+		segment := segment
+		changes = append(changes, entity.SegmentChange{SegmentID: segment.ID, Type: entity.SegmentRemoved, Before: &segment})
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].SegmentID < changes[j].SegmentID })
+	return changes
+}
+
+// sameSegmentContent compares the timing, text, speaker and confidence of two segments, ignoring timestamps
+func sameSegmentContent(a, b entity.TranscriptionSegment) bool {
+	return a.StartMs == b.StartMs && a.EndMs == b.EndMs && a.Text == b.Text && a.Speaker == b.Speaker && a.Confidence == b.Confidence
+}
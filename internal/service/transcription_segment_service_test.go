@@ -0,0 +1,294 @@
+package service
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/aws"
+	"mlvt/internal/pkg/subtitle"
+	"mlvt/internal/repo"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTranscriptionSegmentService(t *testing.T) (TranscriptionService, repo.TranscriptionRepository) {
+	db := setupTranscriptionTestDB(t)
+	transcriptionRepo := repo.NewTranscriptionRepository(db)
+	return NewTranscriptionService(transcriptionRepo, repo.NewTranscriptionSegmentRepo(db), repo.NewVideoRepo(db), nil), transcriptionRepo
+}
+
+func setupTranscriptionTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	for _, name := range []string{
+		"0002_create_videos_table", "0003_create_transcriptions_table", "0006_create_audios_table",
+		"0009_add_file_info_columns", "0013_create_transcription_segments_table", "0014_create_transcription_revisions_table",
+		"0024_create_organizations_tables",
+	} {
+		schema, err := os.ReadFile("../../migration/" + name + ".up.sql")
+		require.NoError(t, err)
+		_, err = db.Exec(string(schema))
+		require.NoError(t, err, name)
+	}
+	return db
+}
+
+func TestTranscriptionTextFromSegments(t *testing.T) {
+	transcriptionService, transcriptionRepo := setupTranscriptionSegmentService(t)
+
+	transcription := &entity.Transcription{VideoID: 1, UserID: 1, Text: "stale", Lang: "en"}
+	require.NoError(t, transcriptionRepo.CreateTranscription(transcription))
+
+	created, err := transcriptionService.AddSegments(1, transcription.ID, []entity.TranscriptionSegment{
+		{StartMs: 1500, EndMs: 3000, Text: " world "},
+		{StartMs: 0, EndMs: 1500, Text: "Hello", Speaker: "SPEAKER_1"},
+	})
+	require.NoError(t, err)
+	require.Len(t, created, 2)
+	assert.Equal(t, "world", created[0].Text)
+
+	saved, err := transcriptionRepo.GetTranscriptionByID(transcription.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world", saved.Text, "segments joined in playback order")
+
+	text := "Hello there,"
+	_, err = transcriptionService.UpdateSegment(1, transcription.ID, created[1].ID, TranscriptionSegmentPatch{Text: &text})
+	require.NoError(t, err)
+	saved, err = transcriptionRepo.GetTranscriptionByID(transcription.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there, world", saved.Text)
+
+	page, total, err := transcriptionService.ListSegmentsPage(transcription.ID, 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, page, 1)
+	assert.Equal(t, "world", page[0].Text)
+}
+
+func TestTranscriptionSegmentValidation(t *testing.T) {
+	transcriptionService, transcriptionRepo := setupTranscriptionSegmentService(t)
+
+	transcription := &entity.Transcription{VideoID: 1, UserID: 1, Lang: "en"}
+	require.NoError(t, transcriptionRepo.CreateTranscription(transcription))
+	created, err := transcriptionService.AddSegments(1, transcription.ID, []entity.TranscriptionSegment{{StartMs: 0, EndMs: 1000, Text: "Hello"}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		segment entity.TranscriptionSegment
+	}{
+		{"Negative Start", entity.TranscriptionSegment{StartMs: -1, EndMs: 1000, Text: "a"}},
+		{"End Before Start", entity.TranscriptionSegment{StartMs: 1000, EndMs: 1000, Text: "a"}},
+		{"Blank Text", entity.TranscriptionSegment{StartMs: 0, EndMs: 1000, Text: "  "}},
+		{"Confidence Out Of Range", entity.TranscriptionSegment{StartMs: 0, EndMs: 1000, Text: "a", Confidence: 1.2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := transcriptionService.AddSegments(1, transcription.ID, []entity.TranscriptionSegment{tt.segment})
+			assert.ErrorIs(t, err, ErrInvalidSegment)
+		})
+	}
+
+	_, err = transcriptionService.AddSegments(1, 99, []entity.TranscriptionSegment{{StartMs: 0, EndMs: 1000, Text: "a"}})
+	assert.ErrorIs(t, err, ErrTranscriptionNotFound)
+
+	_, err = transcriptionService.GetSegment(transcription.ID+1, created[0].ID)
+	assert.ErrorIs(t, err, ErrSegmentNotFound, "segments are only found through their own transcription")
+
+	startMs := int64(2000)
+	_, err = transcriptionService.UpdateSegment(1, transcription.ID, created[0].ID, TranscriptionSegmentPatch{StartMs: &startMs})
+	assert.ErrorIs(t, err, ErrInvalidSegment)
+
+	segments, err := transcriptionService.ListSegments(transcription.ID)
+	require.NoError(t, err)
+	require.Len(t, segments, 1, "rejected segments are not stored")
+	assert.Equal(t, int64(0), segments[0].StartMs)
+}
+
+func TestImportExportTranscription(t *testing.T) {
+	db := setupTranscriptionTestDB(t)
+	s3Client := new(aws.MockS3Client)
+	transcriptionRepo := repo.NewTranscriptionRepository(db)
+	transcriptionService := NewTranscriptionService(transcriptionRepo, repo.NewTranscriptionSegmentRepo(db), repo.NewVideoRepo(db), s3Client)
+
+	video := &entity.Video{Title: "Interview", UserID: 1, FileName: "interview.mp4", Folder: "videos", Status: entity.StatusSuccess}
+	require.NoError(t, repo.NewVideoRepo(db).CreateVideo(video))
+
+	data := []byte("1\n00:00:00,000 --> 00:00:01,500\nHello\n\n2\n00:00:01,500 --> 00:00:03,000\nworld\n")
+	s3Client.On("UploadFile", mock.Anything, mock.MatchedBy(func(fileName string) bool {
+		return strings.HasPrefix(fileName, "video_1_import_vi_") && strings.HasSuffix(fileName, ".srt")
+	}), "application/x-subrip", data).Return(nil).Once()
+	s3Client.On("HeadObject", mock.Anything, mock.Anything).Return(&aws.ObjectInfo{Size: int64(len(data)), ETag: "etag-1"}, nil).Once()
+
+	transcription, segments, err := transcriptionService.ImportTranscription(1, video.ID, " VI ", subtitle.FormatSRT, data)
+	require.NoError(t, err)
+	assert.Len(t, segments, 2)
+	assert.Equal(t, "vi", transcription.Lang)
+	assert.Equal(t, entity.FileStatusReady, transcription.Status)
+	assert.Equal(t, "etag-1", transcription.ETag)
+
+	saved, err := transcriptionRepo.GetTranscriptionByID(transcription.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world", saved.Text)
+	assert.Equal(t, int64(len(data)), saved.FileSize)
+
+	_, exported, err := transcriptionService.ExportTranscription(transcription.ID, subtitle.FormatVTT)
+	require.NoError(t, err)
+	assert.Equal(t, "WEBVTT\n\n1\n00:00:00.000 --> 00:00:01.500\nHello\n\n2\n00:00:01.500 --> 00:00:03.000\nworld\n\n", string(exported))
+	s3Client.AssertExpectations(t)
+
+	t.Run("Malformed File", func(t *testing.T) {
+		_, _, err := transcriptionService.ImportTranscription(1, video.ID, "vi", subtitle.FormatSRT, []byte("1\nHello\n"))
+		assert.ErrorIs(t, err, subtitle.ErrMalformed)
+	})
+
+	t.Run("Video Not Found", func(t *testing.T) {
+		_, _, err := transcriptionService.ImportTranscription(1, 99, "vi", subtitle.FormatSRT, data)
+		assert.ErrorIs(t, err, ErrVideoNotFound)
+	})
+
+	t.Run("No Segments", func(t *testing.T) {
+		empty := &entity.Transcription{VideoID: video.ID, UserID: 1, Lang: "en"}
+		require.NoError(t, transcriptionRepo.CreateTranscription(empty))
+		_, _, err := transcriptionService.ExportTranscription(empty.ID, subtitle.FormatSRT)
+		assert.ErrorIs(t, err, ErrNoSegments)
+	})
+}
+
+func TestTranscriptionRevisions(t *testing.T) {
+	transcriptionService, transcriptionRepo := setupTranscriptionSegmentService(t)
+
+	transcription := &entity.Transcription{VideoID: 1, UserID: 1, Lang: "en"}
+	require.NoError(t, transcriptionRepo.CreateTranscription(transcription))
+
+	created, err := transcriptionService.AddSegments(1, transcription.ID, []entity.TranscriptionSegment{
+		{StartMs: 0, EndMs: 1000, Text: "Hello"},
+		{StartMs: 1000, EndMs: 2000, Text: "world"},
+	})
+	require.NoError(t, err)
+	text := "there"
+	_, err = transcriptionService.UpdateSegment(2, transcription.ID, created[1].ID, TranscriptionSegmentPatch{Text: &text})
+	require.NoError(t, err)
+	_, err = transcriptionService.AddSegments(2, transcription.ID, []entity.TranscriptionSegment{{StartMs: 2000, EndMs: 3000, Text: "again"}})
+	require.NoError(t, err)
+
+	revisions, err := transcriptionService.ListRevisions(transcription.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	assert.Equal(t, 3, revisions[0].Number, "newest first")
+	assert.Equal(t, entity.RevisionActionUpdateSegment, revisions[1].Action)
+	assert.Equal(t, uint64(2), revisions[1].UserID)
+	require.Len(t, revisions[1].Changes, 1)
+	assert.Equal(t, entity.SegmentModified, revisions[1].Changes[0].Type)
+	assert.Equal(t, "world", revisions[1].Changes[0].Before.Text)
+	assert.Equal(t, "there", revisions[1].Changes[0].After.Text)
+	assert.Empty(t, revisions[1].Segments, "lists leave out the snapshots")
+
+	first, err := transcriptionService.GetRevision(transcription.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, entity.RevisionActionAddSegments, first.Action)
+	assert.Len(t, first.Changes, 2)
+	assert.Len(t, first.Segments, 2)
+
+	changes, err := transcriptionService.DiffRevisions(transcription.ID, 1, 3)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, entity.SegmentModified, changes[0].Type)
+	assert.Equal(t, created[1].ID, changes[0].SegmentID)
+	assert.Equal(t, entity.SegmentAdded, changes[1].Type)
+
+	restored, err := transcriptionService.RestoreRevision(1, transcription.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 4, restored.Number)
+	assert.Equal(t, entity.RevisionActionRestore, restored.Action)
+	assert.Equal(t, 1, restored.RestoredFrom)
+	require.Len(t, restored.Changes, 2)
+	assert.Equal(t, entity.SegmentRemoved, restored.Changes[1].Type)
+
+	segments, err := transcriptionService.ListSegments(transcription.ID)
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+	assert.Equal(t, created[1].ID, segments[1].ID, "restored segments keep their IDs")
+	saved, err := transcriptionRepo.GetTranscriptionByID(transcription.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world", saved.Text)
+
+	_, err = transcriptionService.GetRevision(transcription.ID, 9)
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+	_, err = transcriptionService.DiffRevisions(transcription.ID, 1, 9)
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+	_, err = transcriptionService.ListRevisions(99)
+	assert.ErrorIs(t, err, ErrTranscriptionNotFound)
+}
+
+func TestInitialRevision(t *testing.T) {
+	db := setupTranscriptionTestDB(t)
+	transcriptionRepo := repo.NewTranscriptionRepository(db)
+	segmentRepo := repo.NewTranscriptionSegmentRepo(db)
+	transcriptionService := NewTranscriptionService(transcriptionRepo, segmentRepo, repo.NewVideoRepo(db), nil)
+
+	transcription := &entity.Transcription{VideoID: 1, UserID: 3, Lang: "en"}
+	require.NoError(t, transcriptionRepo.CreateTranscription(transcription))
+	segments := []entity.TranscriptionSegment{{TranscriptionID: transcription.ID, StartMs: 0, EndMs: 1000, Text: "Hello"}}
+	require.NoError(t, segmentRepo.CreateSegments(segments))
+
+	text := "Hi"
+	_, err := transcriptionService.UpdateSegment(1, transcription.ID, segments[0].ID, TranscriptionSegmentPatch{Text: &text})
+	require.NoError(t, err)
+
+	initial, err := transcriptionService.GetRevision(transcription.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, entity.RevisionActionInitial, initial.Action)
+	assert.Equal(t, uint64(3), initial.UserID, "segments stored before history are attributed to the owner")
+	assert.Equal(t, "Hello", initial.Segments[0].Text)
+
+	restored, err := transcriptionService.RestoreRevision(1, transcription.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, restored.Number)
+	saved, err := transcriptionRepo.GetTranscriptionByID(transcription.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", saved.Text)
+}
+
+func TestEditWithoutRevisionIsRolledBack(t *testing.T) {
+	db := setupTranscriptionTestDB(t)
+	transcriptionRepo := repo.NewTranscriptionRepository(db)
+	transcriptionService := NewTranscriptionService(transcriptionRepo, repo.NewTranscriptionSegmentRepo(db), repo.NewVideoRepo(db), nil)
+
+	transcription := &entity.Transcription{VideoID: 1, UserID: 1, Lang: "en"}
+	require.NoError(t, transcriptionRepo.CreateTranscription(transcription))
+	created, err := transcriptionService.AddSegments(1, transcription.ID, []entity.TranscriptionSegment{{StartMs: 0, EndMs: 1000, Text: "Hello"}})
+	require.NoError(t, err)
+
+	_, err = db.Exec(`CREATE TRIGGER fail_revisions BEFORE INSERT ON transcription_revisions BEGIN SELECT RAISE(ABORT, 'revisions unavailable'); END`)
+	require.NoError(t, err)
+
+	text := "Hi"
+	_, err = transcriptionService.UpdateSegment(2, transcription.ID, created[0].ID, TranscriptionSegmentPatch{Text: &text})
+	assert.Error(t, err)
+	_, err = transcriptionService.AddSegments(2, transcription.ID, []entity.TranscriptionSegment{{StartMs: 1000, EndMs: 2000, Text: "world"}})
+	assert.Error(t, err)
+	_, err = transcriptionService.RestoreRevision(2, transcription.ID, 1)
+	assert.Error(t, err)
+
+	// No edit is kept without the revision recording it
+	segments, err := transcriptionService.ListSegments(transcription.ID)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	assert.Equal(t, "Hello", segments[0].Text)
+	saved, err := transcriptionRepo.GetTranscriptionByID(transcription.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", saved.Text)
+	revisions, err := transcriptionService.ListRevisions(transcription.ID)
+	require.NoError(t, err)
+	assert.Len(t, revisions, 1)
+}
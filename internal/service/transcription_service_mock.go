@@ -0,0 +1,143 @@
+package service
+
+import (
+	"mlvt/internal/entity"
+	"mlvt/internal/pkg/subtitle"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockTranscriptionService is a mock implementation of the TranscriptionService interface
+type MockTranscriptionService struct {
+	mock.Mock
+}
+
+func (m *MockTranscriptionService) CreateTranscription(transcription *entity.Transcription) error {
+	args := m.Called(transcription)
+	return args.Error(0)
+}
+
+func (m *MockTranscriptionService) GetTranscriptionByID(transcriptionID uint64) (*entity.Transcription, string, error) {
+	args := m.Called(transcriptionID)
+	transcription, _ := args.Get(0).(*entity.Transcription)
+	return transcription, args.String(1), args.Error(2)
+}
+
+func (m *MockTranscriptionService) GetTranscriptionByIDAndUserID(transcriptionID, userID uint64) (*entity.Transcription, string, error) {
+	args := m.Called(transcriptionID, userID)
+	transcription, _ := args.Get(0).(*entity.Transcription)
+	return transcription, args.String(1), args.Error(2)
+}
+
+func (m *MockTranscriptionService) GetTranscriptionByIDAndVideoID(transcriptionID, videoID uint64) (*entity.Transcription, string, error) {
+	args := m.Called(transcriptionID, videoID)
+	transcription, _ := args.Get(0).(*entity.Transcription)
+	return transcription, args.String(1), args.Error(2)
+}
+
+func (m *MockTranscriptionService) ListTranscriptionsByUserID(userID uint64) ([]entity.Transcription, error) {
+	args := m.Called(userID)
+	transcriptions, _ := args.Get(0).([]entity.Transcription)
+	return transcriptions, args.Error(1)
+}
+
+func (m *MockTranscriptionService) ListTranscriptionsByOrgID(orgID uint64) ([]entity.Transcription, error) {
+	args := m.Called(orgID)
+	transcriptions, _ := args.Get(0).([]entity.Transcription)
+	return transcriptions, args.Error(1)
+}
+
+func (m *MockTranscriptionService) ListTranscriptionsByVideoID(videoID uint64) ([]entity.Transcription, error) {
+	args := m.Called(videoID)
+	transcriptions, _ := args.Get(0).([]entity.Transcription)
+	return transcriptions, args.Error(1)
+}
+
+func (m *MockTranscriptionService) DeleteTranscription(transcriptionID uint64) error {
+	args := m.Called(transcriptionID)
+	return args.Error(0)
+}
+
+func (m *MockTranscriptionService) GeneratePresignedUploadURL(folder, fileName, fileType string) (string, error) {
+	args := m.Called(folder, fileName, fileType)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockTranscriptionService) GeneratePresignedDownloadURL(transcriptionID uint64) (string, error) {
+	args := m.Called(transcriptionID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockTranscriptionService) FinalizeTranscriptionUpload(transcriptionID uint64) (*entity.Transcription, error) {
+	args := m.Called(transcriptionID)
+	transcription, _ := args.Get(0).(*entity.Transcription)
+	return transcription, args.Error(1)
+}
+
+func (m *MockTranscriptionService) AddSegments(userID, transcriptionID uint64, segments []entity.TranscriptionSegment) ([]entity.TranscriptionSegment, error) {
+	args := m.Called(userID, transcriptionID, segments)
+	created, _ := args.Get(0).([]entity.TranscriptionSegment)
+	return created, args.Error(1)
+}
+
+func (m *MockTranscriptionService) ListSegments(transcriptionID uint64) ([]entity.TranscriptionSegment, error) {
+	args := m.Called(transcriptionID)
+	segments, _ := args.Get(0).([]entity.TranscriptionSegment)
+	return segments, args.Error(1)
+}
+
+func (m *MockTranscriptionService) ListSegmentsPage(transcriptionID uint64, page, pageSize int) ([]entity.TranscriptionSegment, int, error) {
+	args := m.Called(transcriptionID, page, pageSize)
+	segments, _ := args.Get(0).([]entity.TranscriptionSegment)
+	return segments, args.Int(1), args.Error(2)
+}
+
+func (m *MockTranscriptionService) GetSegment(transcriptionID, segmentID uint64) (*entity.TranscriptionSegment, error) {
+	args := m.Called(transcriptionID, segmentID)
+	segment, _ := args.Get(0).(*entity.TranscriptionSegment)
+	return segment, args.Error(1)
+}
+
+func (m *MockTranscriptionService) UpdateSegment(userID, transcriptionID, segmentID uint64, patch TranscriptionSegmentPatch) (*entity.TranscriptionSegment, error) {
+	args := m.Called(userID, transcriptionID, segmentID, patch)
+	segment, _ := args.Get(0).(*entity.TranscriptionSegment)
+	return segment, args.Error(1)
+}
+
+func (m *MockTranscriptionService) ImportTranscription(userID, videoID uint64, lang string, format subtitle.Format, data []byte) (*entity.Transcription, []entity.TranscriptionSegment, error) {
+	args := m.Called(userID, videoID, lang, format, data)
+	transcription, _ := args.Get(0).(*entity.Transcription)
+	segments, _ := args.Get(1).([]entity.TranscriptionSegment)
+	return transcription, segments, args.Error(2)
+}
+
+func (m *MockTranscriptionService) ExportTranscription(transcriptionID uint64, format subtitle.Format) (*entity.Transcription, []byte, error) {
+	args := m.Called(transcriptionID, format)
+	transcription, _ := args.Get(0).(*entity.Transcription)
+	data, _ := args.Get(1).([]byte)
+	return transcription, data, args.Error(2)
+}
+
+func (m *MockTranscriptionService) ListRevisions(transcriptionID uint64) ([]entity.TranscriptionRevision, error) {
+	args := m.Called(transcriptionID)
+	revisions, _ := args.Get(0).([]entity.TranscriptionRevision)
+	return revisions, args.Error(1)
+}
+
+func (m *MockTranscriptionService) GetRevision(transcriptionID uint64, number int) (*entity.TranscriptionRevision, error) {
+	args := m.Called(transcriptionID, number)
+	revision, _ := args.Get(0).(*entity.TranscriptionRevision)
+	return revision, args.Error(1)
+}
+
+func (m *MockTranscriptionService) DiffRevisions(transcriptionID uint64, from, to int) ([]entity.SegmentChange, error) {
+	args := m.Called(transcriptionID, from, to)
+	changes, _ := args.Get(0).([]entity.SegmentChange)
+	return changes, args.Error(1)
+}
+
+func (m *MockTranscriptionService) RestoreRevision(userID, transcriptionID uint64, number int) (*entity.TranscriptionRevision, error) {
+	args := m.Called(userID, transcriptionID, number)
+	revision, _ := args.Get(0).(*entity.TranscriptionRevision)
+	return revision, args.Error(1)
+}
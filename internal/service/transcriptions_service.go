@@ -1,51 +1,129 @@
 package service
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"mlvt/internal/entity"
 	"mlvt/internal/infra/aws"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/pkg/subtitle"
 	"mlvt/internal/repo"
+	"strings"
+	"time"
 )
 
+var (
+	ErrTranscriptionNotFound = errors.New("transcription not found")
+	ErrSegmentNotFound       = errors.New("transcription segment not found")
+	ErrInvalidSegment        = errors.New("invalid transcription segment")
+	ErrNoSegments            = errors.New("transcription has no segments")
+	ErrRevisionNotFound      = errors.New("transcription revision not found")
+)
+
+const (
+	DefaultSegmentPageSize = 50
+	MaxSegmentPageSize     = 500
+)
+
+// TranscriptionSegmentPatch holds the fields of a segment to change; nil fields are left as they are
+type TranscriptionSegmentPatch struct {
+	StartMs    *int64
+	EndMs      *int64
+	Text       *string
+	Speaker    *string
+	Confidence *float64
+}
+
 type TranscriptionService interface {
 	CreateTranscription(transcription *entity.Transcription) error
 	GetTranscriptionByID(transcriptionID uint64) (*entity.Transcription, string, error)
 	GetTranscriptionByIDAndUserID(transcriptionID, userID uint64) (*entity.Transcription, string, error)
 	GetTranscriptionByIDAndVideoID(transcriptionID, videoID uint64) (*entity.Transcription, string, error)
 	ListTranscriptionsByUserID(userID uint64) ([]entity.Transcription, error)
+	ListTranscriptionsByOrgID(orgID uint64) ([]entity.Transcription, error)
 	ListTranscriptionsByVideoID(videoID uint64) ([]entity.Transcription, error)
 	DeleteTranscription(transcriptionID uint64) error
 	GeneratePresignedUploadURL(folder, fileName, fileType string) (string, error)
 	GeneratePresignedDownloadURL(transcriptionID uint64) (string, error)
+	FinalizeTranscriptionUpload(transcriptionID uint64) (*entity.Transcription, error) // Verifies the stored file and marks the transcription ready
+	AddSegments(userID, transcriptionID uint64, segments []entity.TranscriptionSegment) ([]entity.TranscriptionSegment, error)
+	ListSegments(transcriptionID uint64) ([]entity.TranscriptionSegment, error)
+	ListSegmentsPage(transcriptionID uint64, page, pageSize int) ([]entity.TranscriptionSegment, int, error) // Also returns the total number of segments
+	GetSegment(transcriptionID, segmentID uint64) (*entity.TranscriptionSegment, error)
+	UpdateSegment(userID, transcriptionID, segmentID uint64, patch TranscriptionSegmentPatch) (*entity.TranscriptionSegment, error)
+	ImportTranscription(userID, videoID uint64, lang string, format subtitle.Format, data []byte) (*entity.Transcription, []entity.TranscriptionSegment, error)
+	ExportTranscription(transcriptionID uint64, format subtitle.Format) (*entity.Transcription, []byte, error)
+	ListRevisions(transcriptionID uint64) ([]entity.TranscriptionRevision, error)
+	GetRevision(transcriptionID uint64, number int) (*entity.TranscriptionRevision, error)
+	DiffRevisions(transcriptionID uint64, from, to int) ([]entity.SegmentChange, error)                // Segment-level changes from one revision to another
+	RestoreRevision(userID, transcriptionID uint64, number int) (*entity.TranscriptionRevision, error) // Records the restore as a new revision
 }
 
 type transcriptionService struct {
-	repo     repo.TranscriptionRepository
-	s3Client aws.S3ClientInterface
+	repo        repo.TranscriptionRepository
+	segmentRepo repo.TranscriptionSegmentRepository
+	videoRepo   repo.VideoRepository
+	s3Client    aws.S3ClientInterface
 }
 
-func NewTranscriptionService(repo repo.TranscriptionRepository, s3Client aws.S3ClientInterface) TranscriptionService {
+func NewTranscriptionService(repo repo.TranscriptionRepository, segmentRepo repo.TranscriptionSegmentRepository, videoRepo repo.VideoRepository, s3Client aws.S3ClientInterface) TranscriptionService {
 	return &transcriptionService{
-		repo:     repo,
-		s3Client: s3Client,
+		repo:        repo,
+		segmentRepo: segmentRepo,
+		videoRepo:   videoRepo,
+		s3Client:    s3Client,
 	}
 }
 
+// CreateTranscription records a transcription whose file still has to be confirmed with FinalizeTranscriptionUpload
 func (s *transcriptionService) CreateTranscription(transcription *entity.Transcription) error {
+	transcription.Status = entity.FileStatusPendingUpload
+	transcription.FileSize = 0
+	transcription.ETag = ""
 	return s.repo.CreateTranscription(transcription)
 }
 
+// FinalizeTranscriptionUpload checks the uploaded file and records its size, ETag and content type.
+// Transcriptions already marked ready are returned unchanged.
+func (s *transcriptionService) FinalizeTranscriptionUpload(transcriptionID uint64) (*entity.Transcription, error) {
+	transcription, err := s.repo.GetTranscriptionByID(transcriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if transcription == nil {
+		return nil, ErrTranscriptionNotFound
+	}
+	if transcription.Status != entity.FileStatusPendingUpload {
+		return transcription, nil
+	}
+
+	info, contentType, err := verifyUploadedFile(s.s3Client, transcription.Folder, transcription.FileName, transcription.ContentType, transcriptionMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	transcription.FileSize = info.Size
+	transcription.ETag = info.ETag
+	transcription.ContentType = contentType
+	transcription.Status = entity.FileStatusReady
+	if err := s.repo.UpdateTranscriptionFileInfo(transcription); err != nil {
+		return nil, err
+	}
+	return transcription, nil
+}
+
 func (s *transcriptionService) GetTranscriptionByID(transcriptionID uint64) (*entity.Transcription, string, error) {
 	transcription, err := s.repo.GetTranscriptionByID(transcriptionID)
 	if err != nil {
 		return nil, "", err
 	}
 	if transcription == nil {
-		return nil, "", fmt.Errorf("transcription not found")
+		return nil, "", ErrTranscriptionNotFound
 	}
 
 	// Generate presigned URL
-	presignedURL, err := s.s3Client.GeneratePresignedURL(transcription.Folder, transcription.FileName, "application/json")
+	presignedURL, err := s.s3Client.GeneratePresignedDownloadURL(transcription.Folder, transcription.FileName, aws.DownloadURLOptions{})
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to generate presigned download URL: %v", err)
 	}
@@ -59,11 +137,11 @@ func (s *transcriptionService) GetTranscriptionByIDAndUserID(transcriptionID, us
 		return nil, "", err
 	}
 	if transcription == nil {
-		return nil, "", fmt.Errorf("transcription not found")
+		return nil, "", ErrTranscriptionNotFound
 	}
 
 	// Generate presigned URL
-	presignedURL, err := s.s3Client.GeneratePresignedURL(transcription.Folder, transcription.FileName, "application/json")
+	presignedURL, err := s.s3Client.GeneratePresignedDownloadURL(transcription.Folder, transcription.FileName, aws.DownloadURLOptions{})
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to generate presigned download URL: %v", err)
 	}
@@ -77,11 +155,11 @@ func (s *transcriptionService) GetTranscriptionByIDAndVideoID(transcriptionID, v
 		return nil, "", err
 	}
 	if transcription == nil {
-		return nil, "", fmt.Errorf("transcription not found")
+		return nil, "", ErrTranscriptionNotFound
 	}
 
 	// Generate presigned URL
-	presignedURL, err := s.s3Client.GeneratePresignedURL(transcription.Folder, transcription.FileName, "application/json")
+	presignedURL, err := s.s3Client.GeneratePresignedDownloadURL(transcription.Folder, transcription.FileName, aws.DownloadURLOptions{})
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to generate presigned download URL: %v", err)
 	}
@@ -93,6 +171,11 @@ func (s *transcriptionService) ListTranscriptionsByUserID(userID uint64) ([]enti
 	return s.repo.ListTranscriptionsByUserID(userID)
 }
 
+// ListTranscriptionsByOrgID lists the transcriptions shared with the organization
+func (s *transcriptionService) ListTranscriptionsByOrgID(orgID uint64) ([]entity.Transcription, error) {
+	return s.repo.ListTranscriptionsByOrgID(orgID)
+}
+
 func (s *transcriptionService) ListTranscriptionsByVideoID(videoID uint64) ([]entity.Transcription, error) {
 	return s.repo.ListTranscriptionsByVideoID(videoID)
 }
@@ -102,7 +185,7 @@ func (s *transcriptionService) DeleteTranscription(transcriptionID uint64) error
 }
 
 func (s *transcriptionService) GeneratePresignedUploadURL(folder, fileName, fileType string) (string, error) {
-	return s.s3Client.GeneratePresignedURL(folder, fileName, fileType)
+	return s.s3Client.GeneratePresignedUploadURL(folder, fileName, fileType)
 }
 
 func (s *transcriptionService) GeneratePresignedDownloadURL(transcriptionID uint64) (string, error) {
@@ -111,8 +194,232 @@ func (s *transcriptionService) GeneratePresignedDownloadURL(transcriptionID uint
 		return "", err
 	}
 	if transcription == nil {
-		return "", fmt.Errorf("transcription not found")
+		return "", ErrTranscriptionNotFound
+	}
+
+	return s.s3Client.GeneratePresignedDownloadURL(transcription.Folder, transcription.FileName, aws.DownloadURLOptions{FileName: transcription.FileName})
+}
+
+// AddSegments validates and stores the segments of a transcription, then derives its text from all of its segments
+// and records the edit as a revision authored by the user
+func (s *transcriptionService) AddSegments(userID, transcriptionID uint64, segments []entity.TranscriptionSegment) ([]entity.TranscriptionSegment, error) {
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("%w: at least one segment is required", ErrInvalidSegment)
+	}
+	transcription, err := s.getTranscription(transcriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range segments {
+		segments[i].ID = 0
+		segments[i].TranscriptionID = transcriptionID
+		if err := validateSegment(&segments[i]); err != nil {
+			return nil, fmt.Errorf("segment %d: %w", i, err)
+		}
+	}
+
+	if _, err := s.applyEdit(userID, transcription, repo.SegmentEdit{Create: segments}, entity.RevisionActionAddSegments, 0); err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
+
+// ListSegments lists all segments of a transcription in playback order
+func (s *transcriptionService) ListSegments(transcriptionID uint64) ([]entity.TranscriptionSegment, error) {
+	if _, err := s.getTranscription(transcriptionID); err != nil {
+		return nil, err
+	}
+	return s.segmentRepo.ListSegments(transcriptionID)
+}
+
+// ListSegmentsPage lists one page of the segments of a transcription in playback order. Pages start at 1;
+// out of range page sizes fall back to DefaultSegmentPageSize or are capped at MaxSegmentPageSize.
+func (s *transcriptionService) ListSegmentsPage(transcriptionID uint64, page, pageSize int) ([]entity.TranscriptionSegment, int, error) {
+	if _, err := s.getTranscription(transcriptionID); err != nil {
+		return nil, 0, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultSegmentPageSize
+	}
+	if pageSize > MaxSegmentPageSize {
+		pageSize = MaxSegmentPageSize
+	}
+
+	total, err := s.segmentRepo.CountSegments(transcriptionID)
+	if err != nil {
+		return nil, 0, err
+	}
+	segments, err := s.segmentRepo.ListSegmentsPage(transcriptionID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	return segments, total, nil
+}
+
+// GetSegment retrieves a segment of a transcription
+func (s *transcriptionService) GetSegment(transcriptionID, segmentID uint64) (*entity.TranscriptionSegment, error) {
+	segment, err := s.segmentRepo.GetSegmentByID(segmentID)
+	if err != nil {
+		return nil, err
+	}
+	if segment == nil || segment.TranscriptionID != transcriptionID {
+		return nil, ErrSegmentNotFound
+	}
+	return segment, nil
+}
+
+// UpdateSegment applies the patch to a segment, derives the text of the transcription again and records the edit
+// as a revision authored by the user
+func (s *transcriptionService) UpdateSegment(userID, transcriptionID, segmentID uint64, patch TranscriptionSegmentPatch) (*entity.TranscriptionSegment, error) {
+	transcription, err := s.getTranscription(transcriptionID)
+	if err != nil {
+		return nil, err
+	}
+	segment, err := s.GetSegment(transcriptionID, segmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.StartMs != nil {
+		segment.StartMs = *patch.StartMs
+	}
+	if patch.EndMs != nil {
+		segment.EndMs = *patch.EndMs
+	}
+	if patch.Text != nil {
+		segment.Text = *patch.Text
+	}
+	if patch.Speaker != nil {
+		segment.Speaker = *patch.Speaker
+	}
+	if patch.Confidence != nil {
+		segment.Confidence = *patch.Confidence
+	}
+	if err := validateSegment(segment); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.applyEdit(userID, transcription, repo.SegmentEdit{Update: segment}, entity.RevisionActionUpdateSegment, 0); err != nil {
+		return nil, err
+	}
+	return segment, nil
+}
+
+// ImportTranscription parses a subtitle file into segments and creates a ready transcription of the video from them.
+// The original file is kept in storage as the file of the transcription.
+func (s *transcriptionService) ImportTranscription(userID, videoID uint64, lang string, format subtitle.Format, data []byte) (*entity.Transcription, []entity.TranscriptionSegment, error) {
+	segments, err := subtitle.Parse(bytes.NewReader(data), format)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range segments {
+		if err := validateSegment(&segments[i]); err != nil {
+			return nil, nil, fmt.Errorf("cue %d: %w", i+1, err)
+		}
+	}
+
+	video, err := s.videoRepo.GetVideoByID(videoID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if video == nil {
+		return nil, nil, ErrVideoNotFound
+	}
+
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	transcription := &entity.Transcription{
+		VideoID:     videoID,
+		UserID:      userID,
+		OrgID:       video.OrgID, // Shared like the video
+		Lang:        lang,
+		Folder:      env.EnvConfig.TranscriptionsFolder,
+		FileName:    fmt.Sprintf("video_%d_import_%s_%d%s", videoID, lang, time.Now().UnixNano(), format.Extension()),
+		ContentType: format.ContentType(),
+		Status:      entity.FileStatusReady,
+	}
+	if err := s.s3Client.UploadFile(transcription.Folder, transcription.FileName, transcription.ContentType, data); err != nil {
+		return nil, nil, fmt.Errorf("failed to store subtitle file: %v", err)
+	}
+	info, err := s.s3Client.HeadObject(transcription.Folder, transcription.FileName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check stored subtitle file: %v", err)
+	}
+	transcription.FileSize = info.Size
+	transcription.ETag = info.ETag
+
+	if err := s.repo.CreateTranscription(transcription); err != nil {
+		return nil, nil, err
+	}
+	for i := range segments {
+		segments[i].TranscriptionID = transcription.ID
+	}
+	if _, err := s.applyEdit(userID, transcription, repo.SegmentEdit{Create: segments}, entity.RevisionActionImport, 0); err != nil {
+		if deleteErr := s.repo.DeleteTranscription(transcription.ID); deleteErr != nil {
+			return nil, nil, fmt.Errorf("%v (removing the transcription also failed: %v)", err, deleteErr)
+		}
+		return nil, nil, err
+	}
+	return transcription, segments, nil
+}
+
+// ExportTranscription writes the segments of a transcription as a subtitle file
+func (s *transcriptionService) ExportTranscription(transcriptionID uint64, format subtitle.Format) (*entity.Transcription, []byte, error) {
+	transcription, err := s.getTranscription(transcriptionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	segments, err := s.segmentRepo.ListSegments(transcriptionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(segments) == 0 {
+		return nil, nil, ErrNoSegments
+	}
+
+	var buf bytes.Buffer
+	if err := subtitle.Write(&buf, format, segments, transcription.Lang); err != nil {
+		return nil, nil, err
+	}
+	return transcription, buf.Bytes(), nil
+}
+
+func (s *transcriptionService) getTranscription(transcriptionID uint64) (*entity.Transcription, error) {
+	transcription, err := s.repo.GetTranscriptionByID(transcriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if transcription == nil {
+		return nil, ErrTranscriptionNotFound
+	}
+	return transcription, nil
+}
+
+// segmentsText joins the text of the segments with single spaces
+func segmentsText(segments []entity.TranscriptionSegment) string {
+	texts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		texts = append(texts, segment.Text)
 	}
+	return strings.Join(texts, " ")
+}
 
-	return s.s3Client.GeneratePresignedURL(transcription.Folder, transcription.FileName, "application/json")
+// validateSegment trims the text and speaker of a segment and checks its timing and confidence
+func validateSegment(segment *entity.TranscriptionSegment) error {
+	segment.Text = strings.TrimSpace(segment.Text)
+	segment.Speaker = strings.TrimSpace(segment.Speaker)
+	switch {
+	case segment.StartMs < 0:
+		return fmt.Errorf("%w: start_ms must not be negative", ErrInvalidSegment)
+	case segment.EndMs <= segment.StartMs:
+		return fmt.Errorf("%w: end_ms must be after start_ms", ErrInvalidSegment)
+	case segment.Text == "":
+		return fmt.Errorf("%w: text must not be empty", ErrInvalidSegment)
+	case segment.Confidence < 0 || segment.Confidence > 1:
+		return fmt.Errorf("%w: confidence must be between 0 and 1", ErrInvalidSegment)
+	}
+	return nil
 }
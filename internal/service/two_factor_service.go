@@ -0,0 +1,262 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"mlvt/internal/entity"
+	"mlvt/internal/pkg/totp"
+	"mlvt/internal/repo"
+	"strings"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled    = errors.New("two-factor authentication enrollment was not started")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+)
+
+const (
+	TwoFactorIssuer   = "MLVT" // Shown next to the account in authenticator apps
+	RecoveryCodeCount = 10
+)
+
+// recoveryCodeEncoding writes recovery codes in lowercase base32, which is easy to type
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// TwoFactorEnrollment is what the user needs to add the account to an authenticator app
+type TwoFactorEnrollment struct {
+	Secret string
+	URI    string // otpauth:// URI, also encoded in the QR code
+	QRCode []byte // PNG
+}
+
+// TwoFactorStatus tells whether two-factor authentication is on and how many recovery codes are left
+type TwoFactorStatus struct {
+	Enabled           bool
+	RecoveryCodesLeft int
+}
+
+// TwoFactorService manages TOTP two-factor authentication and the one-time recovery codes of users
+type TwoFactorService interface {
+	Status(userID uint64) (*TwoFactorStatus, error)
+	Enroll(userID uint64) (*TwoFactorEnrollment, error)                             // Starts over while not confirmed
+	Confirm(userID uint64, code string) ([]string, error)                           // Enables 2FA and returns the recovery codes
+	Disable(userID uint64, code, clientIP string) error                             // Takes a TOTP or recovery code
+	RegenerateRecoveryCodes(userID uint64, code, clientIP string) ([]string, error) // Takes a TOTP or recovery code
+	VerifyCode(user *entity.User, code string) error                                // Accepts a TOTP code or an unused recovery code once
+}
+
+type twoFactorService struct {
+	userRepo         repo.UserRepository
+	recoveryCodeRepo repo.RecoveryCodeRepository
+	throttle         LoginThrottle
+}
+
+func NewTwoFactorService(userRepo repo.UserRepository, recoveryCodeRepo repo.RecoveryCodeRepository, throttle LoginThrottle) TwoFactorService {
+	return &twoFactorService{
+		userRepo:         userRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		throttle:         throttle,
+	}
+}
+
+// Status reports whether the user has two-factor authentication enabled
+func (s *twoFactorService) Status(userID uint64) (*TwoFactorStatus, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return &TwoFactorStatus{}, nil
+	}
+
+	left, err := s.recoveryCodeRepo.CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorStatus{Enabled: true, RecoveryCodesLeft: left}, nil
+}
+
+// Enroll generates a new TOTP secret for the user. It takes effect once confirmed with a code.
+func (s *twoFactorService) Enroll(userID uint64) (*TwoFactorEnrollment, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	uri := totp.URI(TwoFactorIssuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.SetTOTPSecret(userID, secret); err != nil {
+		return nil, err
+	}
+	return &TwoFactorEnrollment{Secret: secret, URI: uri, QRCode: png}, nil
+}
+
+// Confirm enables two-factor authentication once the user proves their app generates codes for the pending secret
+func (s *twoFactorService) Confirm(userID uint64, code string) ([]string, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	if err := s.verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.EnableTOTP(userID); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(userID)
+}
+
+// Disable turns off two-factor authentication and deletes the recovery codes
+func (s *twoFactorService) Disable(userID uint64, code, clientIP string) error {
+	user, err := s.getEnabledUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyThrottled(user, code, clientIP); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.DisableTOTP(userID); err != nil {
+		return err
+	}
+	return s.recoveryCodeRepo.DeleteRecoveryCodes(userID)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user with new ones
+func (s *twoFactorService) RegenerateRecoveryCodes(userID uint64, code, clientIP string) ([]string, error) {
+	user, err := s.getEnabledUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyThrottled(user, code, clientIP); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(userID)
+}
+
+// VerifyCode checks a code given for the user: a TOTP code of the current period, or one of their recovery codes.
+// Either works once.
+func (s *twoFactorService) VerifyCode(user *entity.User, code string) error {
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return s.verifyTOTP(user, code)
+	}
+
+	used, err := s.recoveryCodeRepo.UseRecoveryCode(user.ID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// verifyThrottled checks a code like VerifyCode, counting wrong codes against the login throttle of the user and client,
+// so a stolen session cannot be used to guess them
+func (s *twoFactorService) verifyThrottled(user *entity.User, code, clientIP string) error {
+	if err := s.throttle.Check(user.Email, clientIP); err != nil {
+		return err
+	}
+	if err := s.VerifyCode(user, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			if err := s.throttle.RecordFailure(user.Email, clientIP); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	return s.throttle.RecordSuccess(user.Email)
+}
+
+// verifyTOTP checks the code against the secret of the user, rejecting codes of a period that was already used
+func (s *twoFactorService) verifyTOTP(user *entity.User, code string) error {
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return ErrInvalidTwoFactorCode
+	}
+
+	unused, err := s.userRepo.UseTOTPStep(user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !unused {
+		return ErrInvalidTwoFactorCode // Used by a concurrent request
+	}
+	return nil
+}
+
+// newRecoveryCodes generates and stores a new set of recovery codes, returning them in the form shown to the user
+func (s *twoFactorService) newRecoveryCodes(userID uint64) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := recoveryCodeEncoding.EncodeToString(raw) // 8 characters
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = hashToken(code)
+	}
+
+	if err := s.recoveryCodeRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *twoFactorService) getUser(userID uint64) (*entity.User, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *twoFactorService) getEnabledUser(userID uint64) (*entity.User, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	return user, nil
+}
+
+// normalizeRecoveryCode accepts recovery codes typed in any case, with or without the dash
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
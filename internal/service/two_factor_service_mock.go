@@ -0,0 +1,46 @@
+package service
+
+import (
+	"mlvt/internal/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockTwoFactorService is a mock implementation of the TwoFactorService interface
+type MockTwoFactorService struct {
+	mock.Mock
+}
+
+func (m *MockTwoFactorService) Status(userID uint64) (*TwoFactorStatus, error) {
+	args := m.Called(userID)
+	status, _ := args.Get(0).(*TwoFactorStatus)
+	return status, args.Error(1)
+}
+
+func (m *MockTwoFactorService) Enroll(userID uint64) (*TwoFactorEnrollment, error) {
+	args := m.Called(userID)
+	enrollment, _ := args.Get(0).(*TwoFactorEnrollment)
+	return enrollment, args.Error(1)
+}
+
+func (m *MockTwoFactorService) Confirm(userID uint64, code string) ([]string, error) {
+	args := m.Called(userID, code)
+	codes, _ := args.Get(0).([]string)
+	return codes, args.Error(1)
+}
+
+func (m *MockTwoFactorService) Disable(userID uint64, code, clientIP string) error {
+	args := m.Called(userID, code, clientIP)
+	return args.Error(0)
+}
+
+func (m *MockTwoFactorService) RegenerateRecoveryCodes(userID uint64, code, clientIP string) ([]string, error) {
+	args := m.Called(userID, code, clientIP)
+	codes, _ := args.Get(0).([]string)
+	return codes, args.Error(1)
+}
+
+func (m *MockTwoFactorService) VerifyCode(user *entity.User, code string) error {
+	args := m.Called(user, code)
+	return args.Error(0)
+}
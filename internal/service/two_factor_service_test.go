@@ -0,0 +1,190 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/pkg/totp"
+	"mlvt/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type twoFactorFixture struct {
+	twoFactor TwoFactorService
+	auth      AuthServiceInterface
+	userRepo  repo.UserRepository
+	user      *entity.User
+}
+
+// setupTwoFactorService stores a user with the password "password123" who has not enrolled yet
+func setupTwoFactorService(t *testing.T) *twoFactorFixture {
+	db := setupUserTestDB(t)
+	userRepo := repo.NewUserRepo(db)
+	throttle := NewLoginThrottle(repo.NewLoginAttemptRepo(db))
+	twoFactor := NewTwoFactorService(userRepo, repo.NewRecoveryCodeRepo(db), throttle)
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := createTestUser(t, userRepo, "john", entity.UserRoleUser, entity.UserStatusAvailable, time.Now())
+	require.NoError(t, userRepo.UpdateUserPassword(user.ID, string(hashed)))
+
+	return &twoFactorFixture{
+		twoFactor: twoFactor,
+		auth:      NewAuthService(userRepo, repo.NewRefreshTokenRepo(db), twoFactor, throttle, "secret"),
+		userRepo:  userRepo,
+		user:      user,
+	}
+}
+
+// enable enrolls and confirms the user, returning the secret and the recovery codes. The confirmation uses
+// the code of the previous period, leaving the current and the next one for the test.
+func (f *twoFactorFixture) enable(t *testing.T) (string, []string) {
+	enrollment, err := f.twoFactor.Enroll(f.user.ID)
+	require.NoError(t, err)
+	codes, err := f.twoFactor.Confirm(f.user.ID, totpCode(t, enrollment.Secret, -1))
+	require.NoError(t, err)
+	return enrollment.Secret, codes
+}
+
+// totpCode returns the code of the secret for the period offset from the current one
+func totpCode(t *testing.T, secret string, offset int64) string {
+	code, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	require.NoError(t, err)
+	return code
+}
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	f := setupTwoFactorService(t)
+
+	_, err := f.twoFactor.Confirm(f.user.ID, "123456")
+	assert.ErrorIs(t, err, ErrTwoFactorNotEnrolled)
+
+	first, err := f.twoFactor.Enroll(f.user.ID)
+	require.NoError(t, err)
+	assert.Contains(t, first.URI, "otpauth://totp/MLVT:john@example.com?")
+	assert.Contains(t, first.URI, "secret="+first.Secret)
+	assert.Equal(t, "\x89PNG", string(first.QRCode[:4]))
+
+	second, err := f.twoFactor.Enroll(f.user.ID)
+	require.NoError(t, err)
+	_, err = f.twoFactor.Confirm(f.user.ID, totpCode(t, first.Secret, 0))
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode, "enrolling again replaces the secret")
+
+	status, err := f.twoFactor.Status(f.user.ID)
+	require.NoError(t, err)
+	assert.False(t, status.Enabled, "not enabled until confirmed")
+
+	codes, err := f.twoFactor.Confirm(f.user.ID, totpCode(t, second.Secret, 0))
+	require.NoError(t, err)
+	assert.Len(t, codes, RecoveryCodeCount)
+	assert.Regexp(t, `^[a-z2-7]{4}-[a-z2-7]{4}$`, codes[0])
+
+	status, err = f.twoFactor.Status(f.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &TwoFactorStatus{Enabled: true, RecoveryCodesLeft: RecoveryCodeCount}, status)
+
+	_, err = f.twoFactor.Enroll(f.user.ID)
+	assert.ErrorIs(t, err, ErrTwoFactorAlreadyEnabled)
+	_, err = f.twoFactor.Confirm(f.user.ID, totpCode(t, second.Secret, 1))
+	assert.ErrorIs(t, err, ErrTwoFactorAlreadyEnabled)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	f := setupTwoFactorService(t)
+	secret, _ := f.enable(t)
+
+	result, err := f.auth.Login("john@example.com", "password123", "127.0.0.1")
+	require.NoError(t, err)
+	assert.Nil(t, result.TokenPair, "no tokens before the second step")
+	require.NotNil(t, result.Challenge)
+	assert.WithinDuration(t, time.Now().Add(ChallengeTTL), result.Challenge.ExpiresAt, time.Minute)
+
+	_, err = f.auth.GetUserByToken(result.Challenge.Token)
+	assert.Error(t, err, "the challenge token is no access token")
+
+	_, err = f.auth.VerifyTwoFactor(result.Challenge.Token, "000000", "127.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+	_, err = f.auth.VerifyTwoFactor("invalid", totpCode(t, secret, 0), "127.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidChallenge)
+
+	code := totpCode(t, secret, 0)
+	tokens, err := f.auth.VerifyTwoFactor(result.Challenge.Token, code, "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, f.user.ID, tokens.UserID)
+	authenticated, err := f.auth.GetUserByToken(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, f.user.ID, authenticated.ID)
+
+	result, err = f.auth.Login("john@example.com", "password123", "127.0.0.1")
+	require.NoError(t, err)
+	_, err = f.auth.VerifyTwoFactor(result.Challenge.Token, code, "127.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode, "a code works once")
+	_, err = f.auth.VerifyTwoFactor(result.Challenge.Token, totpCode(t, secret, -1), "127.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode, "codes of earlier periods do not work after a later one")
+}
+
+func TestTwoFactorChallengeRevokedWithSessions(t *testing.T) {
+	f := setupTwoFactorService(t)
+	secret, _ := f.enable(t)
+
+	result, err := f.auth.Login("john@example.com", "password123", "127.0.0.1")
+	require.NoError(t, err)
+	require.NoError(t, f.userRepo.UpdateUserPassword(f.user.ID, "new hash"))
+
+	_, err = f.auth.VerifyTwoFactor(result.Challenge.Token, totpCode(t, secret, 0), "127.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidChallenge)
+}
+
+func TestTwoFactorRecoveryCodes(t *testing.T) {
+	f := setupTwoFactorService(t)
+	_, codes := f.enable(t)
+
+	result, err := f.auth.Login("john@example.com", "password123", "127.0.0.1")
+	require.NoError(t, err)
+	_, err = f.auth.VerifyTwoFactor(result.Challenge.Token, " "+codes[0][:4]+codes[0][5:]+" ", "127.0.0.1")
+	require.NoError(t, err, "recovery codes work without the dash")
+	_, err = f.auth.VerifyTwoFactor(result.Challenge.Token, codes[0], "127.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode, "a recovery code works once")
+
+	status, err := f.twoFactor.Status(f.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, RecoveryCodeCount-1, status.RecoveryCodesLeft)
+
+	regenerated, err := f.twoFactor.RegenerateRecoveryCodes(f.user.ID, codes[1], "127.0.0.1")
+	require.NoError(t, err)
+	assert.Len(t, regenerated, RecoveryCodeCount)
+	_, err = f.auth.VerifyTwoFactor(result.Challenge.Token, codes[2], "127.0.0.1")
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode, "regenerating replaces the earlier codes")
+	_, err = f.auth.VerifyTwoFactor(result.Challenge.Token, regenerated[0], "127.0.0.1")
+	assert.NoError(t, err)
+}
+
+func TestTwoFactorDisable(t *testing.T) {
+	f := setupTwoFactorService(t)
+
+	assert.ErrorIs(t, f.twoFactor.Disable(f.user.ID, "123456", "127.0.0.1"), ErrTwoFactorNotEnabled)
+
+	secret, codes := f.enable(t)
+	assert.ErrorIs(t, f.twoFactor.Disable(f.user.ID, "000000", "127.0.0.1"), ErrInvalidTwoFactorCode)
+	require.NoError(t, f.twoFactor.Disable(f.user.ID, totpCode(t, secret, 0), "127.0.0.1"))
+
+	status, err := f.twoFactor.Status(f.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &TwoFactorStatus{}, status)
+
+	result, err := f.auth.Login("john@example.com", "password123", "127.0.0.1")
+	require.NoError(t, err)
+	assert.Nil(t, result.Challenge)
+	assert.NotEmpty(t, result.AccessToken)
+
+	// Enabling again starts with a new secret and new recovery codes
+	_, err = f.twoFactor.Confirm(f.user.ID, totpCode(t, secret, 1))
+	assert.ErrorIs(t, err, ErrTwoFactorNotEnrolled)
+	newSecret, _ := f.enable(t)
+	assert.NotEqual(t, secret, newSecret)
+	assert.ErrorIs(t, f.twoFactor.Disable(f.user.ID, codes[0], "127.0.0.1"), ErrInvalidTwoFactorCode)
+}
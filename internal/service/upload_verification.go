@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"mlvt/internal/infra/aws"
+	"path"
+	"strings"
+)
+
+var (
+	ErrFileNotUploaded  = errors.New("file has not been uploaded")
+	ErrFileTypeMismatch = errors.New("uploaded file does not match the declared file type")
+	ErrFileChanged      = errors.New("stored file no longer matches the finalized upload")
+)
+
+// Media types accepted when finalizing each kind of upload
+var (
+	videoMediaTypes         = []string{"video/"}
+	audioMediaTypes         = []string{"audio/"}
+	transcriptionMediaTypes = []string{"text/", "application/json", "application/x-subrip", "application/ttml+xml"}
+)
+
+// verifyUploadedFile checks with a HEAD request that the file exists and was stored with the declared content type.
+// When no type was declared it is derived from the file extension. It returns the object metadata and the normalized type.
+func verifyUploadedFile(s3Client aws.S3ClientInterface, folder, fileName, declaredType string, allowedTypes []string) (*aws.ObjectInfo, string, error) {
+	declared := normalizeMediaType(declaredType)
+	if declared == "" {
+		declared = normalizeMediaType(mime.TypeByExtension(path.Ext(fileName)))
+	}
+	if !isAllowedMediaType(declared, allowedTypes) {
+		return nil, "", fmt.Errorf("%w: %q is not an accepted type", ErrFileTypeMismatch, declared)
+	}
+
+	info, err := s3Client.HeadObject(folder, fileName)
+	if err != nil {
+		if errors.Is(err, aws.ErrObjectNotFound) {
+			return nil, "", ErrFileNotUploaded
+		}
+		return nil, "", fmt.Errorf("failed to check uploaded file: %v", err)
+	}
+
+	if stored := normalizeMediaType(info.ContentType); stored != declared {
+		return nil, "", fmt.Errorf("%w: declared %q, stored %q", ErrFileTypeMismatch, declared, stored)
+	}
+	return info, declared, nil
+}
+
+// normalizeMediaType strips parameters such as charset and lowercases the media type
+func normalizeMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+func isAllowedMediaType(mediaType string, allowedTypes []string) bool {
+	if mediaType == "" {
+		return false
+	}
+	for _, allowed := range allowedTypes {
+		if strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed) || mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
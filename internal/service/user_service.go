@@ -4,40 +4,56 @@ import (
 	"errors"
 	"mlvt/internal/entity"
 	"mlvt/internal/infra/aws"
+	"mlvt/internal/infra/zap-logging/log"
 	"mlvt/internal/repo"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrInvalidRole  = errors.New("invalid role")
+)
+
 type UserService interface {
 	RegisterUser(user *entity.User) error
-	Login(email, password string) (string, uint64, error)
+	Login(email, password, clientIP string) (*LoginResult, error)
+	VerifyTwoFactor(challengeToken, code, clientIP string) (*TokenPair, error)
+	RefreshToken(refreshToken string) (*TokenPair, error)
+	Logout(refreshToken string, everywhere bool) error
+	VerifyEmail(token string) error
+	ForgotPassword(email string) error
+	ResetPassword(token, newPassword string) error
 	ChangePassword(userID uint64, oldPassword, newPassword string) error
-	UpdateUser(user *entity.User) error
+	UpdateUser(user *entity.User) error // Updates the profile; status, premium and role are kept as stored, a new email must be verified
 	UpdateAvatar(userID uint64, avatarPath, avatarFolder string) error
 	GetUserByID(userID uint64) (*entity.User, error)
 	GetAllUsers() ([]entity.User, error)
 	DeleteUser(userID uint64) error
 	GeneratePresignedAvatarUploadURL(folder, fileName, fileType string) (string, error)
 	GeneratePresignedAvatarDownloadURL(userID uint64) (string, error)
+	AssignRole(userID uint64, role string) (*entity.User, error)
 }
 
 type userService struct {
-	repo     repo.UserRepository
-	s3Client aws.S3ClientInterface
-	auth     AuthServiceInterface
+	repo         repo.UserRepository
+	s3Client     aws.S3ClientInterface
+	auth         AuthServiceInterface
+	verification VerificationService
 }
 
-func NewUserService(repo repo.UserRepository, s3Client aws.S3ClientInterface, auth AuthServiceInterface) UserService {
+func NewUserService(repo repo.UserRepository, s3Client aws.S3ClientInterface, auth AuthServiceInterface, verification VerificationService) UserService {
 	return &userService{
-		repo:     repo,
-		s3Client: s3Client,
-		auth:     auth,
+		repo:         repo,
+		s3Client:     s3Client,
+		auth:         auth,
+		verification: verification,
 	}
 }
 
-// RegisterUser creates a new user with hashed password
+// RegisterUser creates a new user with hashed password and mails them a link to verify their email address.
+// The user may log in once the address is verified.
 func (s *userService) RegisterUser(user *entity.User) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -45,15 +61,53 @@ func (s *userService) RegisterUser(user *entity.User) error {
 	}
 	user.Password = string(hashedPassword)
 	user.Status = entity.UserStatusAvailable
+	user.EmailVerified = false
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 
-	return s.repo.CreateUser(user)
+	if err := s.repo.CreateUser(user); err != nil {
+		return err
+	}
+	// The account exists either way; a user who never got the email can verify through a password reset
+	if err := s.verification.SendVerificationEmail(user); err != nil {
+		log.Errorf("Failed to send the verification email to user %d: %v", user.ID, err)
+	}
+	return nil
 }
 
 // Login handles user login
-func (s *userService) Login(email, password string) (string, uint64, error) {
-	return s.auth.Login(email, password)
+func (s *userService) Login(email, password, clientIP string) (*LoginResult, error) {
+	return s.auth.Login(email, password, clientIP)
+}
+
+// VerifyTwoFactor completes the login of a user with two-factor authentication
+func (s *userService) VerifyTwoFactor(challengeToken, code, clientIP string) (*TokenPair, error) {
+	return s.auth.VerifyTwoFactor(challengeToken, code, clientIP)
+}
+
+// RefreshToken exchanges a refresh token for a new token pair
+func (s *userService) RefreshToken(refreshToken string) (*TokenPair, error) {
+	return s.auth.Refresh(refreshToken)
+}
+
+// Logout revokes the refresh token, or every token of the user when everywhere is set
+func (s *userService) Logout(refreshToken string, everywhere bool) error {
+	return s.auth.Logout(refreshToken, everywhere)
+}
+
+// VerifyEmail verifies the email address the token was mailed to
+func (s *userService) VerifyEmail(token string) error {
+	return s.verification.VerifyEmail(token)
+}
+
+// ForgotPassword mails a password reset link to the email address if it belongs to a user
+func (s *userService) ForgotPassword(email string) error {
+	return s.verification.ForgotPassword(email)
+}
+
+// ResetPassword sets a new password with the token from a password reset email
+func (s *userService) ResetPassword(token, newPassword string) error {
+	return s.verification.ResetPassword(token, newPassword)
 }
 
 // ChangePassword changes a user's password
@@ -78,10 +132,32 @@ func (s *userService) ChangePassword(userID uint64, oldPassword, newPassword str
 	return s.repo.UpdateUserPassword(userID, string(hashedPassword))
 }
 
-// UpdateUser updates user information (except avatar)
+// UpdateUser updates user information (except avatar). A new email address is unverified until the user follows
+// the link mailed to it.
 func (s *userService) UpdateUser(user *entity.User) error {
+	existing, err := s.repo.GetUserByID(user.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrUserNotFound
+	}
+
+	// Role and status only change through the permission-gated admin API, premium through payments
+	user.Status = existing.Status
+	user.Premium = existing.Premium
+	user.Role = existing.Role
+	emailChanged := user.Email != existing.Email
+	user.EmailVerified = existing.EmailVerified && !emailChanged
 	user.UpdatedAt = time.Now()
-	return s.repo.UpdateUser(user)
+	if err := s.repo.UpdateUser(user); err != nil {
+		return err
+	}
+
+	if emailChanged {
+		return s.verification.EmailChanged(user)
+	}
+	return nil
 }
 
 // UpdateAvatar updates the user's avatar
@@ -101,12 +177,12 @@ func (s *userService) GetAllUsers() ([]entity.User, error) {
 
 // DeleteUser soft deletes a user by setting their status to "deleted"
 func (s *userService) DeleteUser(userID uint64) error {
-	return s.repo.DeleteUser(userID)
+	return s.repo.SoftDeleteUser(userID)
 }
 
 // GeneratePresignedAvatarUploadURL generates a presigned URL for uploading an avatar
 func (s *userService) GeneratePresignedAvatarUploadURL(folder, fileName, fileType string) (string, error) {
-	return s.s3Client.GeneratePresignedURL(folder, fileName, fileType)
+	return s.s3Client.GeneratePresignedUploadURL(folder, fileName, fileType)
 }
 
 // GeneratePresignedAvatarDownloadURL generates a presigned URL for downloading the user's avatar
@@ -123,10 +199,30 @@ func (s *userService) GeneratePresignedAvatarDownloadURL(userID uint64) (string,
 	}
 
 	// Generate the presigned URL for the avatar image
-	url, err := s.s3Client.GeneratePresignedURL(user.AvatarFolder, user.Avatar, "image/jpeg")
+	url, err := s.s3Client.GeneratePresignedDownloadURL(user.AvatarFolder, user.Avatar, aws.DownloadURLOptions{})
 	if err != nil {
 		return "", err
 	}
 
 	return url, nil
 }
+
+// AssignRole changes the role of a user, which changes the permissions they are granted
+func (s *userService) AssignRole(userID uint64, role string) (*entity.User, error) {
+	if !entity.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	if err := s.repo.UpdateUserRole(userID, role); err != nil {
+		return nil, err
+	}
+	user.Role = role
+	return user, nil
+}
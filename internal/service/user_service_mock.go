@@ -15,9 +15,42 @@ func (m *MockUserService) RegisterUser(user *entity.User) error {
 	return args.Error(0)
 }
 
-func (m *MockUserService) Login(email, password string) (string, error) {
-	args := m.Called(email, password)
-	return args.String(0), args.Error(1)
+func (m *MockUserService) Login(email, password, clientIP string) (*LoginResult, error) {
+	args := m.Called(email, password, clientIP)
+	result, _ := args.Get(0).(*LoginResult)
+	return result, args.Error(1)
+}
+
+func (m *MockUserService) VerifyTwoFactor(challengeToken, code, clientIP string) (*TokenPair, error) {
+	args := m.Called(challengeToken, code, clientIP)
+	tokens, _ := args.Get(0).(*TokenPair)
+	return tokens, args.Error(1)
+}
+
+func (m *MockUserService) RefreshToken(refreshToken string) (*TokenPair, error) {
+	args := m.Called(refreshToken)
+	tokens, _ := args.Get(0).(*TokenPair)
+	return tokens, args.Error(1)
+}
+
+func (m *MockUserService) Logout(refreshToken string, everywhere bool) error {
+	args := m.Called(refreshToken, everywhere)
+	return args.Error(0)
+}
+
+func (m *MockUserService) VerifyEmail(token string) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ForgotPassword(email string) error {
+	args := m.Called(email)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ResetPassword(token, newPassword string) error {
+	args := m.Called(token, newPassword)
+	return args.Error(0)
 }
 
 func (m *MockUserService) ChangePassword(userID uint64, oldPassword, newPassword string) error {
@@ -65,3 +98,9 @@ func (m *MockUserService) GeneratePresignedAvatarDownloadURL(userID uint64) (str
 	args := m.Called(userID)
 	return args.String(0), args.Error(1)
 }
+
+func (m *MockUserService) AssignRole(userID uint64, role string) (*entity.User, error) {
+	args := m.Called(userID, role)
+	user, _ := args.Get(0).(*entity.User)
+	return user, args.Error(1)
+}
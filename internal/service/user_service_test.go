@@ -18,29 +18,51 @@ func TestRegisterUser_Success(t *testing.T) {
 	mockRepo := new(repo.MockUserRepository)
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
+	mockVerification := new(MockVerificationService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, mockVerification)
 
 	user := &entity.User{
-		FirstName: "John",
-		LastName:  "Doe",
-		UserName:  "johndoe",
-		Email:     "john@example.com",
-		Password:  "password123", // Plain password
+		FirstName:     "John",
+		LastName:      "Doe",
+		UserName:      "johndoe",
+		Email:         "john@example.com",
+		Password:      "password123", // Plain password
+		EmailVerified: true,          // Cannot be set by the client
 	}
 
 	// Expect CreateUser to be called with the user (password should be hashed)
 	mockRepo.On("CreateUser", mock.AnythingOfType("*entity.User")).Return(nil)
+	mockVerification.On("SendVerificationEmail", user).Return(nil)
 
 	err := userService.RegisterUser(user)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, user.Password) // Password should be hashed
+	assert.False(t, user.EmailVerified)
 
 	// Verify password is hashed
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("password123"))
 	assert.NoError(t, err)
 
 	mockRepo.AssertExpectations(t)
+	mockVerification.AssertExpectations(t)
+}
+
+func TestRegisterUser_MailFailure(t *testing.T) {
+	mockRepo := new(repo.MockUserRepository)
+	mockVerification := new(MockVerificationService)
+
+	userService := NewUserService(mockRepo, nil, nil, mockVerification)
+
+	user := &entity.User{Email: "john@example.com", Password: "password123"}
+	mockRepo.On("CreateUser", user).Return(nil)
+	mockVerification.On("SendVerificationEmail", user).Return(errors.New("smtp error"))
+
+	// The account is created anyway
+	assert.NoError(t, userService.RegisterUser(user))
+
+	mockRepo.AssertExpectations(t)
+	mockVerification.AssertExpectations(t)
 }
 
 func TestRegisterUser_Failure_HashPassword(t *testing.T) {
@@ -52,7 +74,7 @@ func TestRegisterUser_Failure_HashPassword(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	user := &entity.User{
 		FirstName: "John",
@@ -76,17 +98,18 @@ func TestLogin_Success(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	email := "john@example.com"
 	password := "password123"
-	token := "jwt.token.here"
+	tokens := &LoginResult{TokenPair: &TokenPair{UserID: 1, AccessToken: "jwt.token.here", RefreshToken: "refresh.token.here"}}
 
-	mockAuth.On("Login", email, password).Return(token, nil)
+	mockAuth.On("Login", email, password, "127.0.0.1").Return(tokens, nil)
 
-	returnedToken, err := userService.Login(email, password)
+	returned, err := userService.Login(email, password, "127.0.0.1")
 	assert.NoError(t, err)
-	assert.Equal(t, token, returnedToken)
+	assert.Equal(t, "jwt.token.here", returned.AccessToken)
+	assert.Equal(t, uint64(1), returned.UserID)
 
 	mockAuth.AssertExpectations(t)
 }
@@ -96,16 +119,16 @@ func TestLogin_Failure_InvalidCredentials(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	email := "john@example.com"
 	password := "wrongpassword"
 
-	mockAuth.On("Login", email, password).Return("", errors.New("invalid credentials"))
+	mockAuth.On("Login", email, password, "127.0.0.1").Return(nil, errors.New("invalid credentials"))
 
-	returnedToken, err := userService.Login(email, password)
+	returned, err := userService.Login(email, password, "127.0.0.1")
 	assert.Error(t, err)
-	assert.Equal(t, "", returnedToken)
+	assert.Nil(t, returned)
 	assert.Equal(t, "invalid credentials", err.Error())
 
 	mockAuth.AssertExpectations(t)
@@ -116,7 +139,7 @@ func TestChangePassword_Success(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	userID := uint64(1)
 	oldPassword := "oldpassword"
@@ -149,7 +172,7 @@ func TestChangePassword_Failure_WrongOldPassword(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	userID := uint64(1)
 	oldPassword := "correctpassword"
@@ -178,7 +201,7 @@ func TestChangePassword_Failure_UserNotFound(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	userID := uint64(1)
 	oldPassword := "oldpassword"
@@ -198,7 +221,7 @@ func TestUpdateUser_Success(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	user := &entity.User{
 		ID:        1,
@@ -212,6 +235,7 @@ func TestUpdateUser_Success(t *testing.T) {
 		UpdatedAt: time.Now(),
 	}
 
+	mockRepo.On("GetUserByID", user.ID).Return(&entity.User{ID: 1, Email: "jane@example.com", Status: entity.UserStatusAvailable, Premium: true, Role: "admin"}, nil)
 	mockRepo.On("UpdateUser", user).Return(nil)
 
 	err := userService.UpdateUser(user)
@@ -225,7 +249,7 @@ func TestUpdateUser_Failure(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	user := &entity.User{
 		ID:        1,
@@ -239,6 +263,7 @@ func TestUpdateUser_Failure(t *testing.T) {
 		UpdatedAt: time.Now(),
 	}
 
+	mockRepo.On("GetUserByID", user.ID).Return(&entity.User{ID: 1, Email: "jane@example.com", Status: entity.UserStatusAvailable, Premium: true, Role: "admin"}, nil)
 	mockRepo.On("UpdateUser", user).Return(errors.New("update error"))
 
 	err := userService.UpdateUser(user)
@@ -248,12 +273,46 @@ func TestUpdateUser_Failure(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestUpdateUser_KeepsRoleAndStatus(t *testing.T) {
+	mockRepo := new(repo.MockUserRepository)
+	mockS3 := new(aws.MockS3Client)
+	mockAuth := new(MockAuthService)
+
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
+
+	user := &entity.User{ID: 1, FirstName: "Jane", Status: entity.UserStatusAvailable, Premium: true, Role: entity.UserRoleAdmin}
+	mockRepo.On("GetUserByID", user.ID).Return(&entity.User{ID: 1, Status: entity.UserStatusSuspended, Role: entity.UserRoleUser}, nil)
+	mockRepo.On("UpdateUser", mock.MatchedBy(func(updated *entity.User) bool {
+		return updated.Role == entity.UserRoleUser && updated.Status == entity.UserStatusSuspended && !updated.Premium && updated.FirstName == "Jane"
+	})).Return(nil)
+
+	err := userService.UpdateUser(user)
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateUser_Failure_NotFound(t *testing.T) {
+	mockRepo := new(repo.MockUserRepository)
+	mockS3 := new(aws.MockS3Client)
+	mockAuth := new(MockAuthService)
+
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
+
+	mockRepo.On("GetUserByID", uint64(9)).Return(nil, nil)
+
+	err := userService.UpdateUser(&entity.User{ID: 9})
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	mockRepo.AssertExpectations(t)
+}
+
 func TestUpdateAvatar_Success(t *testing.T) {
 	mockRepo := new(repo.MockUserRepository)
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	userID := uint64(1)
 	avatarPath := "avatar_new.jpg"
@@ -272,7 +331,7 @@ func TestGetUserByID_Success(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	userID := uint64(1)
 	user := &entity.User{
@@ -295,7 +354,7 @@ func TestGetUserByID_Failure(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	userID := uint64(1)
 
@@ -314,7 +373,7 @@ func TestGetAllUsers_Success(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	users := []entity.User{
 		{
@@ -343,7 +402,7 @@ func TestGetAllUsers_Failure(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	mockRepo.On("GetAllUsers").Return(nil, errors.New("db error"))
 
@@ -360,11 +419,11 @@ func TestDeleteUser_Success(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	userID := uint64(1)
 
-	mockRepo.On("DeleteUser", userID).Return(nil)
+	mockRepo.On("SoftDeleteUser", userID).Return(nil)
 
 	err := userService.DeleteUser(userID)
 	assert.NoError(t, err)
@@ -377,11 +436,11 @@ func TestDeleteUser_Failure(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	userID := uint64(1)
 
-	mockRepo.On("DeleteUser", userID).Return(errors.New("user not found"))
+	mockRepo.On("SoftDeleteUser", userID).Return(errors.New("user not found"))
 
 	err := userService.DeleteUser(userID)
 	assert.Error(t, err)
@@ -395,14 +454,14 @@ func TestGeneratePresignedAvatarUploadURL_Success(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	folder := "avatars"
 	fileName := "avatar_new.jpg"
 	fileType := "image/jpeg"
 	expectedURL := "https://s3.amazonaws.com/bucket/avatars/avatar_new.jpg?presigned"
 
-	mockS3.On("GeneratePresignedURL", folder, fileName, fileType).Return(expectedURL, nil)
+	mockS3.On("GeneratePresignedUploadURL", folder, fileName, fileType).Return(expectedURL, nil)
 
 	url, err := userService.GeneratePresignedAvatarUploadURL(folder, fileName, fileType)
 	assert.NoError(t, err)
@@ -416,7 +475,7 @@ func TestGeneratePresignedAvatarDownloadURL_Success(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	userID := uint64(1)
 	user := &entity.User{
@@ -428,7 +487,7 @@ func TestGeneratePresignedAvatarDownloadURL_Success(t *testing.T) {
 	expectedURL := "https://s3.amazonaws.com/bucket/avatars/avatar.jpg?presigned"
 
 	mockRepo.On("GetUserByID", userID).Return(user, nil)
-	mockS3.On("GeneratePresignedURL", user.AvatarFolder, user.Avatar, "image/jpeg").Return(expectedURL, nil)
+	mockS3.On("GeneratePresignedDownloadURL", user.AvatarFolder, user.Avatar, aws.DownloadURLOptions{}).Return(expectedURL, nil)
 
 	url, err := userService.GeneratePresignedAvatarDownloadURL(userID)
 	assert.NoError(t, err)
@@ -443,7 +502,7 @@ func TestGeneratePresignedAvatarDownloadURL_Failure_UserNotFound(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	userID := uint64(1)
 
@@ -462,7 +521,7 @@ func TestGeneratePresignedAvatarDownloadURL_Failure_AvatarNotFound(t *testing.T)
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	userID := uint64(1)
 	user := &entity.User{
@@ -486,7 +545,7 @@ func TestGeneratePresignedAvatarDownloadURL_Failure_S3Error(t *testing.T) {
 	mockS3 := new(aws.MockS3Client)
 	mockAuth := new(MockAuthService)
 
-	userService := NewUserService(mockRepo, mockS3, mockAuth)
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
 
 	userID := uint64(1)
 	user := &entity.User{
@@ -496,7 +555,7 @@ func TestGeneratePresignedAvatarDownloadURL_Failure_S3Error(t *testing.T) {
 	}
 
 	mockRepo.On("GetUserByID", userID).Return(user, nil)
-	mockS3.On("GeneratePresignedURL", user.AvatarFolder, user.Avatar, "image/jpeg").Return("", errors.New("s3 error"))
+	mockS3.On("GeneratePresignedDownloadURL", user.AvatarFolder, user.Avatar, aws.DownloadURLOptions{}).Return("", errors.New("s3 error"))
 
 	url, err := userService.GeneratePresignedAvatarDownloadURL(userID)
 	assert.Error(t, err)
@@ -506,3 +565,37 @@ func TestGeneratePresignedAvatarDownloadURL_Failure_S3Error(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 	mockS3.AssertExpectations(t)
 }
+
+func TestAssignRole_Success(t *testing.T) {
+	mockRepo := new(repo.MockUserRepository)
+	mockS3 := new(aws.MockS3Client)
+	mockAuth := new(MockAuthService)
+
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
+
+	mockRepo.On("GetUserByID", uint64(2)).Return(&entity.User{ID: 2, Role: entity.UserRoleUser}, nil)
+	mockRepo.On("UpdateUserRole", uint64(2), entity.UserRoleModerator).Return(nil)
+
+	user, err := userService.AssignRole(2, entity.UserRoleModerator)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.UserRoleModerator, user.Role)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAssignRole_Failure(t *testing.T) {
+	mockRepo := new(repo.MockUserRepository)
+	mockS3 := new(aws.MockS3Client)
+	mockAuth := new(MockAuthService)
+
+	userService := NewUserService(mockRepo, mockS3, mockAuth, nil)
+
+	_, err := userService.AssignRole(2, "Owner")
+	assert.ErrorIs(t, err, ErrInvalidRole)
+
+	mockRepo.On("GetUserByID", uint64(9)).Return(nil, nil)
+	_, err = userService.AssignRole(9, entity.UserRoleAdmin)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	mockRepo.AssertNotCalled(t, "UpdateUserRole", mock.Anything, mock.Anything)
+}
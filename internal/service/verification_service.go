@@ -0,0 +1,186 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/infra/mailer"
+	"mlvt/internal/infra/reason"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/pkg/localization"
+	"mlvt/internal/repo"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidUserToken is returned when a mailed token is unknown, expired or already used
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// How long the mailed links work. The email texts in i18n state these durations.
+const (
+	EmailVerificationTTL = 24 * time.Hour
+	PasswordResetTTL     = time.Hour
+)
+
+// VerificationService mails single-use links to users to verify their email address and to reset their password
+type VerificationService interface {
+	SendVerificationEmail(user *entity.User) error // Earlier verification links of the user stop working
+	EmailChanged(user *entity.User) error          // Mails a verification link to the new address; links mailed to the old one stop working
+	VerifyEmail(token string) error
+	ForgotPassword(email string) error // Succeeds for unknown emails too, so it does not reveal who is registered
+	ResetPassword(token, newPassword string) error
+}
+
+type verificationService struct {
+	userRepo         repo.UserRepository
+	userTokenRepo    repo.UserTokenRepository
+	mailer           mailer.Mailer
+	verifyEmailURL   string
+	resetPasswordURL string
+}
+
+func NewVerificationService(userRepo repo.UserRepository, userTokenRepo repo.UserTokenRepository, mailer mailer.Mailer) VerificationService {
+	return &verificationService{
+		userRepo:         userRepo,
+		userTokenRepo:    userTokenRepo,
+		mailer:           mailer,
+		verifyEmailURL:   env.EnvConfig.AppBaseURL + "/api/users/verify-email",
+		resetPasswordURL: env.EnvConfig.PasswordResetURL,
+	}
+}
+
+// SendVerificationEmail mails the user a link that verifies their email address
+func (s *verificationService) SendVerificationEmail(user *entity.User) error {
+	token, err := s.issueToken(user.ID, entity.UserTokenEmailVerification, EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	return s.send(user, reason.VerifyEmailSubject, reason.VerifyEmailBody, s.verifyEmailURL, token)
+}
+
+// EmailChanged mails a verification link to the new email address of the user. The links mailed to the old address
+// stop working, so none of them can verify the new one.
+func (s *verificationService) EmailChanged(user *entity.User) error {
+	if err := s.userTokenRepo.UseUserTokens(user.ID, entity.UserTokenPasswordReset); err != nil {
+		return err
+	}
+	return s.SendVerificationEmail(user)
+}
+
+// VerifyEmail marks the email address of the user the token was mailed to as verified
+func (s *verificationService) VerifyEmail(token string) error {
+	user, err := s.redeemToken(token, entity.UserTokenEmailVerification)
+	if err != nil {
+		return err
+	}
+	return s.userRepo.MarkEmailVerified(user.ID)
+}
+
+// ForgotPassword mails a password reset link to the user with the email address, if there is one who may log in
+func (s *verificationService) ForgotPassword(email string) error {
+	user, err := s.userRepo.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil || !isActiveUser(user) {
+		return nil
+	}
+
+	token, err := s.issueToken(user.ID, entity.UserTokenPasswordReset, PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+	if err := s.send(user, reason.ResetPasswordSubject, reason.ResetPasswordBody, s.resetPasswordURL, token); err != nil {
+		// Failing the request would tell the caller the email is registered
+		log.Errorf("Failed to send the password reset email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password for the user the token was mailed to, which also revokes their sessions.
+// Following the link proves the user owns their email address, so it is verified as well.
+func (s *verificationService) ResetPassword(token, newPassword string) error {
+	user, err := s.redeemToken(token, entity.UserTokenPasswordReset)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdateUserPassword(user.ID, string(hashedPassword)); err != nil {
+		return err
+	}
+	if !user.EmailVerified {
+		return s.userRepo.MarkEmailVerified(user.ID)
+	}
+	return nil
+}
+
+// issueToken stores a new token for the purpose, replacing the outstanding ones of the user
+func (s *verificationService) issueToken(userID uint64, purpose entity.UserTokenPurpose, ttl time.Duration) (string, error) {
+	if err := s.userTokenRepo.UseUserTokens(userID, purpose); err != nil {
+		return "", err
+	}
+
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	err = s.userTokenRepo.CreateUserToken(&entity.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// redeemToken uses up a valid token for the purpose and returns the user it was mailed to
+func (s *verificationService) redeemToken(token string, purpose entity.UserTokenPurpose) (*entity.User, error) {
+	stored, err := s.userTokenRepo.GetUserTokenByHash(purpose, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.UsedAt != nil || !time.Now().Before(stored.ExpiresAt) {
+		return nil, ErrInvalidUserToken
+	}
+
+	user, err := s.userRepo.GetUserByID(stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !isActiveUser(user) {
+		return nil, ErrInvalidUserToken
+	}
+
+	unused, err := s.userTokenRepo.UseUserToken(stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !unused {
+		return nil, ErrInvalidUserToken
+	}
+	return user, nil
+}
+
+// send mails the user the email in the language of the server with the link to target carrying the token
+func (s *verificationService) send(user *entity.User, subject, body localization.LocalizedString, target, token string) error {
+	name := user.FirstName
+	if name == "" {
+		name = user.UserName
+	}
+	link := target + "?token=" + url.QueryEscape(token)
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: subject.Message(),
+		Body:    fmt.Sprintf(body.Message(), name, link),
+	})
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"mlvt/internal/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockVerificationService is a mock implementation of the VerificationService interface
+type MockVerificationService struct {
+	mock.Mock
+}
+
+func (m *MockVerificationService) SendVerificationEmail(user *entity.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockVerificationService) EmailChanged(user *entity.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockVerificationService) VerifyEmail(token string) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockVerificationService) ForgotPassword(email string) error {
+	args := m.Called(email)
+	return args.Error(0)
+}
+
+func (m *MockVerificationService) ResetPassword(token, newPassword string) error {
+	args := m.Called(token, newPassword)
+	return args.Error(0)
+}
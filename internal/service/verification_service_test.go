@@ -0,0 +1,203 @@
+package service
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/infra/mailer"
+	"mlvt/internal/pkg/localization"
+	"mlvt/internal/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var mailedToken = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+type verificationFixture struct {
+	verification  VerificationService
+	auth          AuthServiceInterface
+	userRepo      repo.UserRepository
+	userTokenRepo repo.UserTokenRepository
+	mailer        *mailer.FileMailer
+	user          *entity.User
+}
+
+// setupVerificationService stores an unverified user with the password "password123" and mails through a file mailer,
+// with the emails in English
+func setupVerificationService(t *testing.T) *verificationFixture {
+	i18nPath := env.EnvConfig.I18NPath
+	env.EnvConfig.I18NPath = "../../i18n/"
+	localization.SetLanguage("en")
+	t.Cleanup(func() { env.EnvConfig.I18NPath = i18nPath })
+
+	db := setupUserTestDB(t)
+	userRepo := repo.NewUserRepo(db)
+	userTokenRepo := repo.NewUserTokenRepo(db)
+	fileMailer, err := mailer.NewFileMailer(t.TempDir(), "noreply@example.com")
+	require.NoError(t, err)
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &entity.User{FirstName: "John", UserName: "john", Email: "john@example.com", Password: string(hashed),
+		Status: entity.UserStatusAvailable, Role: entity.UserRoleUser, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	require.NoError(t, userRepo.CreateUser(user))
+
+	throttle := NewLoginThrottle(repo.NewLoginAttemptRepo(db))
+	return &verificationFixture{
+		verification:  NewVerificationService(userRepo, userTokenRepo, fileMailer),
+		auth:          NewAuthService(userRepo, repo.NewRefreshTokenRepo(db), NewTwoFactorService(userRepo, repo.NewRecoveryCodeRepo(db), throttle), throttle, "secret"),
+		userRepo:      userRepo,
+		userTokenRepo: userTokenRepo,
+		mailer:        fileMailer,
+		user:          user,
+	}
+}
+
+// lastMail returns the last email sent and the token in its link
+func (f *verificationFixture) lastMail(t *testing.T) (mailer.Message, string) {
+	messages := mustMessages(t, f.mailer)
+	require.NotEmpty(t, messages)
+	msg := messages[len(messages)-1]
+	match := mailedToken.FindStringSubmatch(msg.Body)
+	require.NotNil(t, match, "the email carries a link with the token")
+	return msg, match[1]
+}
+
+func TestVerifyEmail(t *testing.T) {
+	f := setupVerificationService(t)
+
+	_, err := f.auth.Login("john@example.com", "password123", "127.0.0.1")
+	assert.ErrorIs(t, err, ErrEmailNotVerified)
+
+	require.NoError(t, f.verification.SendVerificationEmail(f.user))
+	msg, first := f.lastMail(t)
+	assert.Equal(t, "john@example.com", msg.To)
+	assert.Equal(t, "Verify your email address", msg.Subject)
+	assert.Contains(t, msg.Body, "Hello John,")
+	assert.Contains(t, msg.Body, "/api/users/verify-email?token="+first)
+
+	require.NoError(t, f.verification.SendVerificationEmail(f.user))
+	_, second := f.lastMail(t)
+	assert.ErrorIs(t, f.verification.VerifyEmail(first), ErrInvalidUserToken, "a newer email replaces the link")
+
+	require.NoError(t, f.verification.VerifyEmail(second))
+	assert.ErrorIs(t, f.verification.VerifyEmail(second), ErrInvalidUserToken, "the link works once")
+	assert.ErrorIs(t, f.verification.VerifyEmail("unknown"), ErrInvalidUserToken)
+
+	verified, err := f.userRepo.GetUserByID(f.user.ID)
+	require.NoError(t, err)
+	assert.True(t, verified.EmailVerified)
+	_, err = f.auth.Login("john@example.com", "password123", "127.0.0.1")
+	assert.NoError(t, err)
+}
+
+func TestChangeEmailRequiresVerification(t *testing.T) {
+	f := setupVerificationService(t)
+	users := NewUserService(f.userRepo, nil, f.auth, f.verification)
+	require.NoError(t, f.userRepo.MarkEmailVerified(f.user.ID))
+
+	// A password reset link mailed to the old address must not verify the new one
+	require.NoError(t, f.verification.ForgotPassword("john@example.com"))
+	_, resetToken := f.lastMail(t)
+
+	update := *f.user
+	update.EmailVerified = true // Ignored, as are the other fields only the server sets
+	update.Email = "victim@example.com"
+	require.NoError(t, users.UpdateUser(&update))
+
+	changed, err := f.userRepo.GetUserByID(f.user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "victim@example.com", changed.Email)
+	assert.False(t, changed.EmailVerified)
+	_, err = f.auth.Login("victim@example.com", "password123", "127.0.0.1")
+	assert.ErrorIs(t, err, ErrEmailNotVerified)
+	assert.ErrorIs(t, f.verification.ResetPassword(resetToken, "newpassword"), ErrInvalidUserToken)
+
+	msg, token := f.lastMail(t)
+	assert.Equal(t, "victim@example.com", msg.To)
+	assert.Equal(t, "Verify your email address", msg.Subject)
+	require.NoError(t, f.verification.VerifyEmail(token))
+
+	// Saving the profile with the same address leaves it verified
+	update.FirstName = "Johnny"
+	require.NoError(t, users.UpdateUser(&update))
+	verified, err := f.userRepo.GetUserByID(f.user.ID)
+	require.NoError(t, err)
+	assert.True(t, verified.EmailVerified)
+}
+
+func TestPasswordReset(t *testing.T) {
+	f := setupVerificationService(t)
+
+	require.NoError(t, f.verification.ForgotPassword("nobody@example.com"))
+	assert.Empty(t, mustMessages(t, f.mailer), "no email for unknown addresses")
+
+	require.NoError(t, f.verification.ForgotPassword("john@example.com"))
+	msg, token := f.lastMail(t)
+	assert.Equal(t, "Reset your password", msg.Subject)
+
+	require.NoError(t, f.verification.ResetPassword(token, "newpassword"))
+	assert.ErrorIs(t, f.verification.ResetPassword(token, "again"), ErrInvalidUserToken, "the link works once")
+
+	// Following the link verified the email address too
+	_, err := f.auth.Login("john@example.com", "password123", "127.0.0.1")
+	assert.Error(t, err)
+	_, err = f.auth.Login("john@example.com", "newpassword", "127.0.0.1")
+	assert.NoError(t, err)
+}
+
+func TestPasswordResetRevokesSessions(t *testing.T) {
+	f := setupVerificationService(t)
+	require.NoError(t, f.userRepo.MarkEmailVerified(f.user.ID))
+	tokens, err := f.auth.Login("john@example.com", "password123", "127.0.0.1")
+	require.NoError(t, err)
+
+	require.NoError(t, f.verification.ForgotPassword("john@example.com"))
+	_, token := f.lastMail(t)
+	require.NoError(t, f.verification.ResetPassword(token, "newpassword"))
+
+	_, err = f.auth.GetUserByToken(tokens.AccessToken)
+	assert.Error(t, err)
+	_, err = f.auth.Refresh(tokens.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestInvalidUserTokens(t *testing.T) {
+	f := setupVerificationService(t)
+
+	t.Run("Expired", func(t *testing.T) {
+		require.NoError(t, f.userTokenRepo.CreateUserToken(&entity.UserToken{
+			UserID: f.user.ID, Purpose: entity.UserTokenPasswordReset, TokenHash: hashToken("expired"), ExpiresAt: time.Now().Add(-time.Minute),
+		}))
+		assert.ErrorIs(t, f.verification.ResetPassword("expired", "newpassword"), ErrInvalidUserToken)
+	})
+
+	t.Run("Other Purpose", func(t *testing.T) {
+		require.NoError(t, f.verification.SendVerificationEmail(f.user))
+		_, token := f.lastMail(t)
+		assert.ErrorIs(t, f.verification.ResetPassword(token, "newpassword"), ErrInvalidUserToken)
+	})
+
+	t.Run("Suspended User", func(t *testing.T) {
+		require.NoError(t, f.verification.ForgotPassword("john@example.com"))
+		_, token := f.lastMail(t)
+		require.NoError(t, f.userRepo.UpdateUserStatus(f.user.ID, entity.UserStatusSuspended))
+
+		assert.ErrorIs(t, f.verification.ResetPassword(token, "newpassword"), ErrInvalidUserToken)
+
+		count := len(mustMessages(t, f.mailer))
+		require.NoError(t, f.verification.ForgotPassword("john@example.com"))
+		assert.Len(t, mustMessages(t, f.mailer), count, "suspended users get no reset email")
+	})
+}
+
+func mustMessages(t *testing.T, fileMailer *mailer.FileMailer) []mailer.Message {
+	messages, err := fileMailer.Messages()
+	require.NoError(t, err)
+	return messages
+}
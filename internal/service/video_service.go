@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"mlvt/internal/entity"
 	"mlvt/internal/infra/aws"
@@ -12,6 +14,7 @@ type VideoService interface {
 	CreateVideo(video *entity.Video) error
 	GetVideoByID(videoID uint64) (*entity.Video, string, string, error) // Returns the video record and presigned URLs for video and image
 	ListVideosByUserID(userID uint64) ([]entity.Video, []entity.Frame, error)
+	ListVideosByOrgID(orgID uint64) ([]entity.Video, []entity.Frame, error)
 	DeleteVideo(videoID uint64) error
 	UpdateVideo(video *entity.Video) error
 	UpdateVideoStatus(videoID uint64, status entity.VideoStatus) error
@@ -20,24 +23,114 @@ type VideoService interface {
 	GeneratePresignedUploadURLForImage(folder, fileName, fileType string) (string, error)
 	GeneratePresignedDownloadURLForVideo(videoID uint64) (string, error)
 	GeneratePresignedDownloadURLForImage(videoID uint64) (string, error)
+	FinalizeVideoUpload(videoID uint64) (*entity.Video, error) // Verifies the stored file, moves the video out of pending_upload and enqueues its processing
+	ProcessVideo(ctx context.Context, videoID uint64) error    // Run by the worker pool for JobTypeProcessVideo jobs
+
+	// Multipart uploads for large video files
+	InitiateMultipartUpload(userID uint64, folder, fileName, fileType string, fileSize int64) (*entity.UploadSession, error)
+	GeneratePresignedUploadPartURLs(userID uint64, uploadID string, partNumbers []int32) (map[int32]string, error)
+	ListUploadSessions(userID uint64) ([]entity.UploadSession, error)
+	ListUploadedParts(userID uint64, uploadID string) (*entity.UploadSession, []aws.UploadedPart, error)
+	CompleteMultipartUpload(userID uint64, uploadID string, parts []aws.UploadedPart) (*entity.UploadSession, error)
+	AbortMultipartUpload(userID uint64, uploadID string) error
 }
 
+// ErrVideoNotFound is returned by ProcessVideo so the job can be dead-lettered
+var ErrVideoNotFound = errors.New("video not found")
+
 type videoService struct {
-	repo     repo.VideoRepository
-	s3Client aws.S3ClientInterface
+	repo       repo.VideoRepository
+	uploadRepo repo.UploadSessionRepository
+	jobRepo    repo.JobRepository
+	s3Client   aws.S3ClientInterface
 }
 
-func NewVideoService(repo repo.VideoRepository, s3Client aws.S3ClientInterface) VideoService {
+func NewVideoService(repo repo.VideoRepository, uploadRepo repo.UploadSessionRepository, jobRepo repo.JobRepository, s3Client aws.S3ClientInterface) VideoService {
 	return &videoService{
-		repo:     repo,
-		s3Client: s3Client,
+		repo:       repo,
+		uploadRepo: uploadRepo,
+		jobRepo:    jobRepo,
+		s3Client:   s3Client,
 	}
 }
 
+// CreateVideo records a video whose file still has to be confirmed with FinalizeVideoUpload
 func (s *videoService) CreateVideo(video *entity.Video) error {
+	video.Status = entity.StatusPendingUpload
+	video.FileSize = 0
+	video.ETag = ""
 	return s.repo.CreateVideo(video)
 }
 
+// FinalizeVideoUpload checks the uploaded file and records its size, ETag and content type.
+// Videos already out of pending_upload are returned unchanged.
+func (s *videoService) FinalizeVideoUpload(videoID uint64) (*entity.Video, error) {
+	video, err := s.repo.GetVideoByID(videoID)
+	if err != nil {
+		return nil, err
+	}
+	if video == nil {
+		return nil, fmt.Errorf("video not found")
+	}
+	if video.Status != entity.StatusPendingUpload {
+		return video, nil
+	}
+
+	info, contentType, err := verifyUploadedFile(s.s3Client, video.Folder, video.FileName, video.ContentType, videoMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	video.FileSize = info.Size
+	video.ETag = info.ETag
+	video.ContentType = contentType
+	video.Status = entity.StatusRaw
+	if err := s.repo.UpdateVideoFileInfo(video); err != nil {
+		return nil, err
+	}
+	if _, err := enqueueJob(s.jobRepo, &entity.Job{Type: entity.JobTypeProcessVideo}, entity.ProcessVideoPayload{VideoID: video.ID}); err != nil {
+		return nil, err
+	}
+	return video, nil
+}
+
+// ProcessVideo moves a raw video to processing, checks its file is still stored as finalized and marks it success.
+// Videos already processed are left untouched, so a retried job is harmless.
+func (s *videoService) ProcessVideo(ctx context.Context, videoID uint64) error {
+	video, err := s.repo.GetVideoByID(videoID)
+	if err != nil {
+		return err
+	}
+	if video == nil {
+		return ErrVideoNotFound
+	}
+	if video.Status != entity.StatusRaw && video.Status != entity.StatusProcessing {
+		return nil
+	}
+
+	if video.Status == entity.StatusRaw {
+		if err := s.repo.UpdateVideoStatus(videoID, entity.StatusProcessing); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	info, err := s.s3Client.HeadObject(video.Folder, video.FileName)
+	if err != nil {
+		if errors.Is(err, aws.ErrObjectNotFound) {
+			return ErrFileNotUploaded
+		}
+		return fmt.Errorf("failed to check video file: %v", err)
+	}
+	if info.Size != video.FileSize {
+		return fmt.Errorf("%w: stored size %d, finalized size %d", ErrFileChanged, info.Size, video.FileSize)
+	}
+
+	return s.repo.UpdateVideoStatus(videoID, entity.StatusSuccess)
+}
+
 func (s *videoService) GetVideoByID(videoID uint64) (*entity.Video, string, string, error) {
 	video, err := s.repo.GetVideoByID(videoID)
 	if err != nil {
@@ -48,11 +141,11 @@ func (s *videoService) GetVideoByID(videoID uint64) (*entity.Video, string, stri
 	}
 
 	// Generate presigned URLs for video and image
-	videoURL, err := s.s3Client.GeneratePresignedURL(video.Folder, video.FileName, "video/mp4")
+	videoURL, err := s.s3Client.GeneratePresignedDownloadURL(video.Folder, video.FileName, aws.DownloadURLOptions{})
 	if err != nil {
 		return nil, "", "", fmt.Errorf("failed to generate presigned video URL: %v", err)
 	}
-	imageURL, err := s.s3Client.GeneratePresignedURL(video.Folder, video.Image, "image/jpeg")
+	imageURL, err := s.s3Client.GeneratePresignedDownloadURL(env.EnvConfig.VideoFramesFolder, video.Image, aws.DownloadURLOptions{})
 	if err != nil {
 		return nil, "", "", fmt.Errorf("failed to generate presigned image URL: %v", err)
 	}
@@ -66,12 +159,25 @@ func (s *videoService) ListVideosByUserID(userID uint64) ([]entity.Video, []enti
 	if err != nil {
 		return nil, nil, err
 	}
+	return s.withFrames(videos)
+}
+
+// ListVideosByOrgID lists the videos shared with the organization along with presigned image URLs
+func (s *videoService) ListVideosByOrgID(orgID uint64) ([]entity.Video, []entity.Frame, error) {
+	videos, err := s.repo.ListVideosByOrgID(orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.withFrames(videos)
+}
 
+// withFrames returns the videos with a frame holding the presigned URL of the image of each
+func (s *videoService) withFrames(videos []entity.Video) ([]entity.Video, []entity.Frame, error) {
 	// Prepare a list of Frame objects containing presigned URLs for images
 	var frames []entity.Frame
 	for _, video := range videos {
 		// Generate the presigned URL for the video's image
-		imageURL, err := s.s3Client.GeneratePresignedURL(video.Folder, video.Image, "image/jpeg")
+		imageURL, err := s.s3Client.GeneratePresignedDownloadURL(env.EnvConfig.VideoFramesFolder, video.Image, aws.DownloadURLOptions{})
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to generate presigned URL for image: %v", err)
 		}
@@ -131,12 +237,12 @@ func (s *videoService) GetVideoStatus(videoID uint64) (entity.VideoStatus, error
 
 // GeneratePresignedUploadURLForVideo generates a presigned URL for uploading a video file
 func (s *videoService) GeneratePresignedUploadURLForVideo(folder, fileName, fileType string) (string, error) {
-	return s.s3Client.GeneratePresignedURL(folder, fileName, fileType)
+	return s.s3Client.GeneratePresignedUploadURL(folder, fileName, fileType)
 }
 
 // GeneratePresignedUploadURLForImage generates a presigned URL for uploading an image file
 func (s *videoService) GeneratePresignedUploadURLForImage(folder, fileName, fileType string) (string, error) {
-	return s.s3Client.GeneratePresignedURL(folder, fileName, fileType)
+	return s.s3Client.GeneratePresignedUploadURL(folder, fileName, fileType)
 }
 
 // GeneratePresignedDownloadURLForVideo generates a presigned URL for downloading a video file
@@ -149,7 +255,7 @@ func (s *videoService) GeneratePresignedDownloadURLForVideo(videoID uint64) (str
 		return "", fmt.Errorf("video not found")
 	}
 
-	return s.s3Client.GeneratePresignedURL(video.Folder, video.FileName, "video/mp4")
+	return s.s3Client.GeneratePresignedDownloadURL(video.Folder, video.FileName, aws.DownloadURLOptions{FileName: video.FileName})
 }
 
 // GeneratePresignedDownloadURLForImage generates a presigned URL for downloading an image file
@@ -162,5 +268,5 @@ func (s *videoService) GeneratePresignedDownloadURLForImage(videoID uint64) (str
 		return "", fmt.Errorf("video not found")
 	}
 
-	return s.s3Client.GeneratePresignedURL(video.Folder, video.Image, "image/jpeg")
+	return s.s3Client.GeneratePresignedDownloadURL(env.EnvConfig.VideoFramesFolder, video.Image, aws.DownloadURLOptions{FileName: video.Image})
 }
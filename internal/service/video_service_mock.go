@@ -1,7 +1,9 @@
 package service
 
 import (
+	"context"
 	"mlvt/internal/entity"
+	"mlvt/internal/infra/aws"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -27,6 +29,11 @@ func (m *MockVideoService) ListVideosByUserID(userID uint64) ([]entity.Video, []
 	return args.Get(0).([]entity.Video), args.Get(1).([]entity.Frame), args.Error(2)
 }
 
+func (m *MockVideoService) ListVideosByOrgID(orgID uint64) ([]entity.Video, []entity.Frame, error) {
+	args := m.Called(orgID)
+	return args.Get(0).([]entity.Video), args.Get(1).([]entity.Frame), args.Error(2)
+}
+
 func (m *MockVideoService) DeleteVideo(videoID uint64) error {
 	args := m.Called(videoID)
 	return args.Error(0)
@@ -66,3 +73,50 @@ func (m *MockVideoService) GeneratePresignedDownloadURLForImage(videoID uint64)
 	args := m.Called(videoID)
 	return args.String(0), args.Error(1)
 }
+
+func (m *MockVideoService) InitiateMultipartUpload(userID uint64, folder, fileName, fileType string, fileSize int64) (*entity.UploadSession, error) {
+	args := m.Called(userID, folder, fileName, fileType, fileSize)
+	session, _ := args.Get(0).(*entity.UploadSession)
+	return session, args.Error(1)
+}
+
+func (m *MockVideoService) GeneratePresignedUploadPartURLs(userID uint64, uploadID string, partNumbers []int32) (map[int32]string, error) {
+	args := m.Called(userID, uploadID, partNumbers)
+	urls, _ := args.Get(0).(map[int32]string)
+	return urls, args.Error(1)
+}
+
+func (m *MockVideoService) ListUploadSessions(userID uint64) ([]entity.UploadSession, error) {
+	args := m.Called(userID)
+	sessions, _ := args.Get(0).([]entity.UploadSession)
+	return sessions, args.Error(1)
+}
+
+func (m *MockVideoService) ListUploadedParts(userID uint64, uploadID string) (*entity.UploadSession, []aws.UploadedPart, error) {
+	args := m.Called(userID, uploadID)
+	session, _ := args.Get(0).(*entity.UploadSession)
+	parts, _ := args.Get(1).([]aws.UploadedPart)
+	return session, parts, args.Error(2)
+}
+
+func (m *MockVideoService) CompleteMultipartUpload(userID uint64, uploadID string, parts []aws.UploadedPart) (*entity.UploadSession, error) {
+	args := m.Called(userID, uploadID, parts)
+	session, _ := args.Get(0).(*entity.UploadSession)
+	return session, args.Error(1)
+}
+
+func (m *MockVideoService) AbortMultipartUpload(userID uint64, uploadID string) error {
+	args := m.Called(userID, uploadID)
+	return args.Error(0)
+}
+
+func (m *MockVideoService) FinalizeVideoUpload(videoID uint64) (*entity.Video, error) {
+	args := m.Called(videoID)
+	video, _ := args.Get(0).(*entity.Video)
+	return video, args.Error(1)
+}
+
+func (m *MockVideoService) ProcessVideo(ctx context.Context, videoID uint64) error {
+	args := m.Called(ctx, videoID)
+	return args.Error(0)
+}
@@ -1,13 +1,16 @@
 package service
 
 import (
+	"context"
 	"mlvt/internal/entity"
 	"mlvt/internal/infra/aws"
+	"mlvt/internal/infra/env"
 	"mlvt/internal/repo"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func setupTestRepoAndS3Client() (*repo.MockVideoRepository, *aws.MockS3Client) {
@@ -18,7 +21,7 @@ func setupTestRepoAndS3Client() (*repo.MockVideoRepository, *aws.MockS3Client) {
 
 func TestCreateVideoService(t *testing.T) {
 	videoRepo, s3Client := setupTestRepoAndS3Client()
-	videoService := NewVideoService(videoRepo, s3Client)
+	videoService := NewVideoService(videoRepo, new(repo.MockUploadSessionRepository), new(repo.MockJobRepository), s3Client)
 
 	video := &entity.Video{
 		Title:       "Test Video",
@@ -34,12 +37,13 @@ func TestCreateVideoService(t *testing.T) {
 	videoRepo.On("CreateVideo", video).Return(nil)
 	err := videoService.CreateVideo(video)
 	assert.NoError(t, err)
+	assert.Equal(t, entity.StatusPendingUpload, video.Status)
 	videoRepo.AssertExpectations(t)
 }
 
 func TestGetVideoByIDService(t *testing.T) {
 	videoRepo, s3Client := setupTestRepoAndS3Client()
-	videoService := NewVideoService(videoRepo, s3Client)
+	videoService := NewVideoService(videoRepo, new(repo.MockUploadSessionRepository), new(repo.MockJobRepository), s3Client)
 
 	video := &entity.Video{
 		ID:          1,
@@ -56,8 +60,8 @@ func TestGetVideoByIDService(t *testing.T) {
 	}
 
 	videoRepo.On("GetVideoByID", uint64(1)).Return(video, nil)
-	s3Client.On("GeneratePresignedURL", video.Folder, video.FileName, "video/mp4").Return("https://s3.amazonaws.com/test_video.mp4", nil)
-	s3Client.On("GeneratePresignedURL", video.Folder, video.Image, "image/jpeg").Return("https://s3.amazonaws.com/test_image.jpg", nil)
+	s3Client.On("GeneratePresignedDownloadURL", video.Folder, video.FileName, aws.DownloadURLOptions{}).Return("https://s3.amazonaws.com/test_video.mp4", nil)
+	s3Client.On("GeneratePresignedDownloadURL", env.EnvConfig.VideoFramesFolder, video.Image, aws.DownloadURLOptions{}).Return("https://s3.amazonaws.com/test_image.jpg", nil)
 
 	result, videoURL, imageURL, err := videoService.GetVideoByID(1)
 	assert.NoError(t, err)
@@ -70,7 +74,7 @@ func TestGetVideoByIDService(t *testing.T) {
 
 func TestListVideosByUserIDService(t *testing.T) {
 	videoRepo, s3Client := setupTestRepoAndS3Client()
-	videoService := NewVideoService(videoRepo, s3Client)
+	videoService := NewVideoService(videoRepo, new(repo.MockUploadSessionRepository), new(repo.MockJobRepository), s3Client)
 
 	video1 := entity.Video{
 		ID:          1,
@@ -97,8 +101,8 @@ func TestListVideosByUserIDService(t *testing.T) {
 
 	videos := []entity.Video{video1, video2}
 	videoRepo.On("ListVideosByUserID", uint64(1)).Return(videos, nil)
-	s3Client.On("GeneratePresignedURL", video1.Folder, video1.Image, "image/jpeg").Return("https://s3.amazonaws.com/test_image_1.jpg", nil)
-	s3Client.On("GeneratePresignedURL", video2.Folder, video2.Image, "image/jpeg").Return("https://s3.amazonaws.com/test_image_2.jpg", nil)
+	s3Client.On("GeneratePresignedDownloadURL", env.EnvConfig.VideoFramesFolder, video1.Image, aws.DownloadURLOptions{}).Return("https://s3.amazonaws.com/test_image_1.jpg", nil)
+	s3Client.On("GeneratePresignedDownloadURL", env.EnvConfig.VideoFramesFolder, video2.Image, aws.DownloadURLOptions{}).Return("https://s3.amazonaws.com/test_image_2.jpg", nil)
 
 	resultVideos, frames, err := videoService.ListVideosByUserID(1)
 	assert.NoError(t, err)
@@ -112,10 +116,219 @@ func TestListVideosByUserIDService(t *testing.T) {
 
 func TestDeleteVideoService(t *testing.T) {
 	videoRepo, s3Client := setupTestRepoAndS3Client()
-	videoService := NewVideoService(videoRepo, s3Client)
+	videoService := NewVideoService(videoRepo, new(repo.MockUploadSessionRepository), new(repo.MockJobRepository), s3Client)
 
+	video := &entity.Video{
+		ID:       1,
+		FileName: "test.mp4",
+		Folder:   "test_folder",
+		Image:    "test_image.jpg",
+	}
+
+	videoRepo.On("GetVideoByID", uint64(1)).Return(video, nil)
+	s3Client.On("DeleteFile", video.Folder, video.FileName).Return(nil)
+	s3Client.On("DeleteFile", env.EnvConfig.VideoFramesFolder, video.Image).Return(nil)
 	videoRepo.On("DeleteVideo", uint64(1)).Return(nil)
 	err := videoService.DeleteVideo(1)
 	assert.NoError(t, err)
 	videoRepo.AssertExpectations(t)
+	s3Client.AssertExpectations(t)
+}
+
+func TestInitiateMultipartUploadService(t *testing.T) {
+	videoRepo, s3Client := setupTestRepoAndS3Client()
+	uploadRepo := new(repo.MockUploadSessionRepository)
+	videoService := NewVideoService(videoRepo, uploadRepo, new(repo.MockJobRepository), s3Client)
+
+	fileSize := int64(6 << 30) // 6 GiB
+	s3Client.On("CreateMultipartUpload", "videos", "big.mp4", "video/mp4").Return("upload-1", nil)
+	uploadRepo.On("CreateUploadSession", mock.AnythingOfType("*entity.UploadSession")).Return(nil)
+
+	session, err := videoService.InitiateMultipartUpload(1, "videos", "big.mp4", "video/mp4", fileSize)
+	assert.NoError(t, err)
+	assert.Equal(t, "upload-1", session.UploadID)
+	assert.Equal(t, int64(64<<20), session.PartSize)
+	assert.Equal(t, 96, session.TotalParts)
+	assert.Equal(t, entity.UploadStatusInProgress, session.Status)
+
+	// Files needing more than 10000 parts get a larger part size
+	assert.Equal(t, int64(128<<20), uploadPartSize(int64(700<<30)))
+
+	_, err = videoService.InitiateMultipartUpload(1, "videos", "big.mp4", "video/mp4", 0)
+	assert.ErrorIs(t, err, ErrInvalidFileSize)
+
+	s3Client.AssertExpectations(t)
+	uploadRepo.AssertExpectations(t)
+}
+
+func TestMultipartUploadSessionOwnershipService(t *testing.T) {
+	videoRepo, s3Client := setupTestRepoAndS3Client()
+	uploadRepo := new(repo.MockUploadSessionRepository)
+	videoService := NewVideoService(videoRepo, uploadRepo, new(repo.MockJobRepository), s3Client)
+
+	session := &entity.UploadSession{UploadID: "upload-1", UserID: 1, Folder: "videos", FileName: "big.mp4", TotalParts: 2, Status: entity.UploadStatusInProgress}
+	closed := &entity.UploadSession{UploadID: "upload-2", UserID: 1, TotalParts: 2, Status: entity.UploadStatusCompleted}
+	uploadRepo.On("GetUploadSessionByUploadID", "upload-1").Return(session, nil)
+	uploadRepo.On("GetUploadSessionByUploadID", "upload-2").Return(closed, nil)
+	uploadRepo.On("GetUploadSessionByUploadID", "missing").Return(nil, nil)
+
+	_, err := videoService.GeneratePresignedUploadPartURLs(2, "upload-1", []int32{1})
+	assert.ErrorIs(t, err, ErrUploadSessionNotFound)
+	_, err = videoService.GeneratePresignedUploadPartURLs(1, "missing", []int32{1})
+	assert.ErrorIs(t, err, ErrUploadSessionNotFound)
+	_, err = videoService.GeneratePresignedUploadPartURLs(1, "upload-2", []int32{1})
+	assert.ErrorIs(t, err, ErrUploadSessionClosed)
+	_, err = videoService.GeneratePresignedUploadPartURLs(1, "upload-1", []int32{3})
+	assert.ErrorIs(t, err, ErrInvalidPartNumber)
+
+	s3Client.On("GeneratePresignedUploadPartURL", "videos", "big.mp4", "upload-1", int32(2)).Return("https://s3/part-2", nil)
+	urls, err := videoService.GeneratePresignedUploadPartURLs(1, "upload-1", []int32{2})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int32]string{2: "https://s3/part-2"}, urls)
+}
+
+func TestCompleteMultipartUploadService(t *testing.T) {
+	videoRepo, s3Client := setupTestRepoAndS3Client()
+	uploadRepo := new(repo.MockUploadSessionRepository)
+	videoService := NewVideoService(videoRepo, uploadRepo, new(repo.MockJobRepository), s3Client)
+
+	session := &entity.UploadSession{UploadID: "upload-1", UserID: 1, Folder: "videos", FileName: "big.mp4", TotalParts: 2, Status: entity.UploadStatusInProgress}
+	uploadRepo.On("GetUploadSessionByUploadID", "upload-1").Return(session, nil)
+
+	// Missing parts are rejected before reaching the storage backend
+	_, err := videoService.CompleteMultipartUpload(1, "upload-1", []aws.UploadedPart{{PartNumber: 2, ETag: `"b"`}})
+	assert.ErrorIs(t, err, ErrIncompleteUpload)
+
+	sorted := []aws.UploadedPart{{PartNumber: 1, ETag: `"a"`}, {PartNumber: 2, ETag: `"b"`}}
+	s3Client.On("CompleteMultipartUpload", "videos", "big.mp4", "upload-1", sorted).Return(nil)
+	uploadRepo.On("UpdateUploadSessionStatus", "upload-1", entity.UploadStatusCompleted).Return(nil)
+
+	completed, err := videoService.CompleteMultipartUpload(1, "upload-1", []aws.UploadedPart{sorted[1], sorted[0]})
+	assert.NoError(t, err)
+	assert.Equal(t, entity.UploadStatusCompleted, completed.Status)
+
+	s3Client.AssertExpectations(t)
+	uploadRepo.AssertExpectations(t)
+}
+
+func TestAbortMultipartUploadService(t *testing.T) {
+	videoRepo, s3Client := setupTestRepoAndS3Client()
+	uploadRepo := new(repo.MockUploadSessionRepository)
+	videoService := NewVideoService(videoRepo, uploadRepo, new(repo.MockJobRepository), s3Client)
+
+	session := &entity.UploadSession{UploadID: "upload-1", UserID: 1, Folder: "videos", FileName: "big.mp4", TotalParts: 2, Status: entity.UploadStatusInProgress}
+	uploadRepo.On("GetUploadSessionByUploadID", "upload-1").Return(session, nil)
+	s3Client.On("AbortMultipartUpload", "videos", "big.mp4", "upload-1").Return(nil)
+	uploadRepo.On("UpdateUploadSessionStatus", "upload-1", entity.UploadStatusAborted).Return(nil)
+
+	err := videoService.AbortMultipartUpload(1, "upload-1")
+	assert.NoError(t, err)
+	s3Client.AssertExpectations(t)
+	uploadRepo.AssertExpectations(t)
+}
+
+func TestFinalizeVideoUploadService(t *testing.T) {
+	videoRepo, s3Client := setupTestRepoAndS3Client()
+	jobRepo := new(repo.MockJobRepository)
+	videoService := NewVideoService(videoRepo, new(repo.MockUploadSessionRepository), jobRepo, s3Client)
+
+	pending := func(id uint64, contentType string) *entity.Video {
+		return &entity.Video{ID: id, FileName: "clip.mp4", Folder: "videos", Status: entity.StatusPendingUpload, ContentType: contentType}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		videoRepo.On("GetVideoByID", uint64(1)).Return(pending(1, "video/MP4"), nil).Once()
+		s3Client.On("HeadObject", "videos", "clip.mp4").Return(&aws.ObjectInfo{Size: 2048, ETag: `"abc"`, ContentType: "video/mp4"}, nil).Once()
+		videoRepo.On("UpdateVideoFileInfo", mock.MatchedBy(func(v *entity.Video) bool {
+			return v.ID == 1 && v.Status == entity.StatusRaw && v.FileSize == 2048 && v.ETag == `"abc"` && v.ContentType == "video/mp4"
+		})).Return(nil).Once()
+		jobRepo.On("CreateJob", mock.MatchedBy(func(job *entity.Job) bool {
+			return job.Type == entity.JobTypeProcessVideo && job.Payload == `{"video_id":1}`
+		})).Return(nil).Once()
+
+		video, err := videoService.FinalizeVideoUpload(1)
+		assert.NoError(t, err)
+		assert.Equal(t, entity.StatusRaw, video.Status)
+	})
+
+	t.Run("Type Derived From Extension", func(t *testing.T) {
+		videoRepo.On("GetVideoByID", uint64(2)).Return(pending(2, ""), nil).Once()
+		s3Client.On("HeadObject", "videos", "clip.mp4").Return(&aws.ObjectInfo{Size: 1, ContentType: "audio/mpeg"}, nil).Once()
+
+		_, err := videoService.FinalizeVideoUpload(2)
+		assert.ErrorIs(t, err, ErrFileTypeMismatch)
+	})
+
+	t.Run("Declared Type Not A Video", func(t *testing.T) {
+		videoRepo.On("GetVideoByID", uint64(3)).Return(pending(3, "text/html"), nil).Once()
+
+		_, err := videoService.FinalizeVideoUpload(3)
+		assert.ErrorIs(t, err, ErrFileTypeMismatch)
+	})
+
+	t.Run("File Missing", func(t *testing.T) {
+		videoRepo.On("GetVideoByID", uint64(4)).Return(pending(4, "video/mp4"), nil).Once()
+		s3Client.On("HeadObject", "videos", "clip.mp4").Return(nil, aws.ErrObjectNotFound).Once()
+
+		_, err := videoService.FinalizeVideoUpload(4)
+		assert.ErrorIs(t, err, ErrFileNotUploaded)
+	})
+
+	t.Run("Already Finalized", func(t *testing.T) {
+		video := &entity.Video{ID: 5, Status: entity.StatusSuccess}
+		videoRepo.On("GetVideoByID", uint64(5)).Return(video, nil).Once()
+
+		finalized, err := videoService.FinalizeVideoUpload(5)
+		assert.NoError(t, err)
+		assert.Equal(t, video, finalized)
+	})
+
+	videoRepo.AssertExpectations(t)
+	s3Client.AssertExpectations(t)
+	jobRepo.AssertExpectations(t)
+}
+
+func TestProcessVideoService(t *testing.T) {
+	videoRepo, s3Client := setupTestRepoAndS3Client()
+	videoService := NewVideoService(videoRepo, new(repo.MockUploadSessionRepository), new(repo.MockJobRepository), s3Client)
+
+	raw := func(id uint64) *entity.Video {
+		return &entity.Video{ID: id, FileName: "clip.mp4", Folder: "videos", Status: entity.StatusRaw, FileSize: 2048}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		videoRepo.On("GetVideoByID", uint64(1)).Return(raw(1), nil).Once()
+		videoRepo.On("UpdateVideoStatus", uint64(1), entity.StatusProcessing).Return(nil).Once()
+		s3Client.On("HeadObject", "videos", "clip.mp4").Return(&aws.ObjectInfo{Size: 2048}, nil).Once()
+		videoRepo.On("UpdateVideoStatus", uint64(1), entity.StatusSuccess).Return(nil).Once()
+
+		err := videoService.ProcessVideo(context.Background(), 1)
+		assert.NoError(t, err)
+	})
+
+	t.Run("File Removed", func(t *testing.T) {
+		videoRepo.On("GetVideoByID", uint64(2)).Return(raw(2), nil).Once()
+		videoRepo.On("UpdateVideoStatus", uint64(2), entity.StatusProcessing).Return(nil).Once()
+		s3Client.On("HeadObject", "videos", "clip.mp4").Return(nil, aws.ErrObjectNotFound).Once()
+
+		err := videoService.ProcessVideo(context.Background(), 2)
+		assert.ErrorIs(t, err, ErrFileNotUploaded)
+	})
+
+	t.Run("Already Processed", func(t *testing.T) {
+		videoRepo.On("GetVideoByID", uint64(3)).Return(&entity.Video{ID: 3, Status: entity.StatusSuccess}, nil).Once()
+
+		err := videoService.ProcessVideo(context.Background(), 3)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Video Deleted", func(t *testing.T) {
+		videoRepo.On("GetVideoByID", uint64(4)).Return((*entity.Video)(nil), nil).Once()
+
+		err := videoService.ProcessVideo(context.Background(), 4)
+		assert.ErrorIs(t, err, ErrVideoNotFound)
+	})
+
+	videoRepo.AssertExpectations(t)
+	s3Client.AssertExpectations(t)
 }
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/aws"
+	"sort"
+)
+
+// defaultUploadPartSize is the part size of a multipart upload, doubled for files that would need too many parts
+const defaultUploadPartSize int64 = 64 << 20
+
+var (
+	ErrUploadSessionNotFound = errors.New("upload session not found")
+	ErrUploadSessionClosed   = errors.New("upload session is no longer in progress")
+	ErrInvalidPartNumber     = errors.New("invalid part number")
+	ErrIncompleteUpload      = errors.New("the part list must contain every part of the upload in ascending order")
+	ErrInvalidFileSize       = errors.New("invalid file size")
+)
+
+// InitiateMultipartUpload starts a multipart upload and records the session so the client can resume it later
+func (s *videoService) InitiateMultipartUpload(userID uint64, folder, fileName, fileType string, fileSize int64) (*entity.UploadSession, error) {
+	if fileSize <= 0 || fileSize > aws.MaxObjectSize {
+		return nil, ErrInvalidFileSize
+	}
+
+	uploadID, err := s.s3Client.CreateMultipartUpload(folder, fileName, fileType)
+	if err != nil {
+		return nil, err
+	}
+
+	partSize := uploadPartSize(fileSize)
+	session := &entity.UploadSession{
+		UploadID:   uploadID,
+		UserID:     userID,
+		Folder:     folder,
+		FileName:   fileName,
+		FileType:   fileType,
+		FileSize:   fileSize,
+		PartSize:   partSize,
+		TotalParts: int((fileSize + partSize - 1) / partSize),
+		Status:     entity.UploadStatusInProgress,
+	}
+	if err := s.uploadRepo.CreateUploadSession(session); err != nil {
+		// Do not leave an orphaned upload behind in the bucket
+		if abortErr := s.s3Client.AbortMultipartUpload(folder, fileName, uploadID); abortErr != nil {
+			return nil, fmt.Errorf("failed to save upload session: %v (abort failed: %v)", err, abortErr)
+		}
+		return nil, fmt.Errorf("failed to save upload session: %v", err)
+	}
+
+	return session, nil
+}
+
+// GeneratePresignedUploadPartURLs generates a presigned URL for each of the requested parts
+func (s *videoService) GeneratePresignedUploadPartURLs(userID uint64, uploadID string, partNumbers []int32) (map[int32]string, error) {
+	session, err := s.getOpenUploadSession(userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make(map[int32]string, len(partNumbers))
+	for _, partNumber := range partNumbers {
+		if partNumber < 1 || int(partNumber) > session.TotalParts {
+			return nil, ErrInvalidPartNumber
+		}
+		url, err := s.s3Client.GeneratePresignedUploadPartURL(session.Folder, session.FileName, session.UploadID, partNumber)
+		if err != nil {
+			return nil, err
+		}
+		urls[partNumber] = url
+	}
+
+	return urls, nil
+}
+
+// ListUploadSessions lists the uploads of a user that are still in progress
+func (s *videoService) ListUploadSessions(userID uint64) ([]entity.UploadSession, error) {
+	return s.uploadRepo.ListUploadSessionsByUserID(userID, entity.UploadStatusInProgress)
+}
+
+// ListUploadedParts returns the session and the parts already stored, letting a client resume the upload
+func (s *videoService) ListUploadedParts(userID uint64, uploadID string) (*entity.UploadSession, []aws.UploadedPart, error) {
+	session, err := s.getOpenUploadSession(userID, uploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parts, err := s.s3Client.ListUploadedParts(session.Folder, session.FileName, session.UploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, parts, nil
+}
+
+// CompleteMultipartUpload assembles the parts into the final file once every part has been uploaded
+func (s *videoService) CompleteMultipartUpload(userID uint64, uploadID string, parts []aws.UploadedPart) (*entity.UploadSession, error) {
+	session, err := s.getOpenUploadSession(userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := append([]aws.UploadedPart(nil), parts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PartNumber < sorted[j].PartNumber })
+	if len(sorted) != session.TotalParts {
+		return nil, ErrIncompleteUpload
+	}
+	for i, part := range sorted {
+		if int(part.PartNumber) != i+1 || part.ETag == "" {
+			return nil, ErrIncompleteUpload
+		}
+	}
+
+	if err := s.s3Client.CompleteMultipartUpload(session.Folder, session.FileName, session.UploadID, sorted); err != nil {
+		return nil, err
+	}
+	if err := s.uploadRepo.UpdateUploadSessionStatus(session.UploadID, entity.UploadStatusCompleted); err != nil {
+		return nil, err
+	}
+
+	session.Status = entity.UploadStatusCompleted
+	return session, nil
+}
+
+// AbortMultipartUpload cancels an upload and discards the parts already stored
+func (s *videoService) AbortMultipartUpload(userID uint64, uploadID string) error {
+	session, err := s.getOpenUploadSession(userID, uploadID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.s3Client.AbortMultipartUpload(session.Folder, session.FileName, session.UploadID); err != nil {
+		return err
+	}
+	return s.uploadRepo.UpdateUploadSessionStatus(session.UploadID, entity.UploadStatusAborted)
+}
+
+// getOpenUploadSession fetches an in-progress session owned by the user
+func (s *videoService) getOpenUploadSession(userID uint64, uploadID string) (*entity.UploadSession, error) {
+	session, err := s.uploadRepo.GetUploadSessionByUploadID(uploadID)
+	if err != nil {
+		return nil, err
+	}
+	// Sessions of other users are reported as missing so upload IDs cannot be probed
+	if session == nil || session.UserID != userID {
+		return nil, ErrUploadSessionNotFound
+	}
+	if session.Status != entity.UploadStatusInProgress {
+		return nil, ErrUploadSessionClosed
+	}
+	return session, nil
+}
+
+// uploadPartSize picks a part size keeping the upload within the part count limit of S3
+func uploadPartSize(fileSize int64) int64 {
+	partSize := defaultUploadPartSize
+	for (fileSize+partSize-1)/partSize > aws.MaxUploadParts {
+		partSize *= 2
+	}
+	return partSize
+}
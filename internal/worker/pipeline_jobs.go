@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/service"
+)
+
+// advancePipelineHandler runs JobTypeAdvancePipeline jobs queued whenever a task of a pipeline finishes
+type advancePipelineHandler struct {
+	pipelineService service.PipelineService
+}
+
+func NewAdvancePipelineHandler(pipelineService service.PipelineService) Handler {
+	return &advancePipelineHandler{pipelineService: pipelineService}
+}
+
+func (h *advancePipelineHandler) Handle(ctx context.Context, job *entity.Job) error {
+	var payload entity.PipelinePayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return service.PermanentJobError(fmt.Errorf("invalid payload of job %d: %v", job.ID, err))
+	}
+
+	_, err := h.pipelineService.AdvancePipeline(payload.PipelineID)
+	if errors.Is(err, service.ErrPipelineNotFound) {
+		return service.PermanentJobError(err)
+	}
+	return err
+}
@@ -0,0 +1,304 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/repo"
+	"mlvt/internal/service"
+	"os"
+	"sync"
+	"time"
+)
+
+// Defaults used when Config leaves a field empty
+const (
+	DefaultConcurrency       = 2
+	DefaultVisibilityTimeout = 5 * time.Minute
+	DefaultPollInterval      = 2 * time.Second
+)
+
+// Handler processes one leased job. Returning an error schedules a retry unless it is
+// wrapped with service.PermanentJobError.
+type Handler interface {
+	Handle(ctx context.Context, job *entity.Job) error
+}
+
+// HandlerFunc adapts a function to the Handler interface
+type HandlerFunc func(ctx context.Context, job *entity.Job) error
+
+func (f HandlerFunc) Handle(ctx context.Context, job *entity.Job) error {
+	return f(ctx, job)
+}
+
+// DeadLetterHandler is implemented by handlers that need to react once a job is dead-lettered
+type DeadLetterHandler interface {
+	HandleDeadLetter(job *entity.Job, cause error)
+}
+
+// Task is work the pool runs at a fixed interval rather than from the queue, e.g. a periodic cleanup. Every
+// instance of the server runs it, so it must be safe to run concurrently.
+type Task func(ctx context.Context) error
+
+type scheduledTask struct {
+	name     string
+	interval time.Duration
+	run      Task
+}
+
+// Config tunes the worker pool
+type Config struct {
+	Concurrency       int           // Number of jobs processed at the same time
+	VisibilityTimeout time.Duration // How long a lease hides a job from other workers; renewed by heartbeats
+	PollInterval      time.Duration // Wait between polls when the queue is empty
+}
+
+// Pool runs registered handlers for jobs leased from the queue
+type Pool struct {
+	jobService service.JobService
+	config     Config
+	owner      string
+	handlers   map[entity.JobType]Handler
+	tasks      []scheduledTask
+
+	ctx      context.Context // Cancelled when draining runs out of time
+	cancel   context.CancelFunc
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewPool(jobService service.JobService, config Config) *Pool {
+	if config.Concurrency <= 0 {
+		config.Concurrency = DefaultConcurrency
+	}
+	if config.VisibilityTimeout <= 0 {
+		config.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultPollInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Pool{
+		jobService: jobService,
+		config:     config,
+		owner:      newOwnerID(),
+		handlers:   map[entity.JobType]Handler{},
+		ctx:        ctx,
+		cancel:     cancel,
+		stop:       make(chan struct{}),
+	}
+}
+
+// Register sets the handler of a job type. It must be called before Start.
+func (p *Pool) Register(jobType entity.JobType, handler Handler) {
+	p.handlers[jobType] = handler
+}
+
+// Every runs the task when the pool starts and then at the interval until it shuts down. It must be called
+// before Start.
+func (p *Pool) Every(interval time.Duration, name string, task Task) {
+	p.tasks = append(p.tasks, scheduledTask{name: name, interval: interval, run: task})
+}
+
+// Start launches the workers and the scheduled tasks
+func (p *Pool) Start() {
+	jobTypes := make([]entity.JobType, 0, len(p.handlers))
+	for jobType := range p.handlers {
+		jobTypes = append(jobTypes, jobType)
+	}
+
+	log.Infof("Starting %d job workers as %s", p.config.Concurrency, p.owner)
+	for i := 0; i < p.config.Concurrency; i++ {
+		p.wg.Add(1)
+		go p.work(jobTypes)
+	}
+	for _, task := range p.tasks {
+		p.wg.Add(1)
+		go p.schedule(task)
+	}
+}
+
+// Shutdown stops leasing new jobs and waits for running ones to finish. When ctx expires first the
+// running handlers are cancelled, their jobs are released back to the queue and ctx.Err() is returned.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work(jobTypes []entity.JobType) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		job, err := p.jobService.Lease(p.owner, repo.JobFilter{Types: jobTypes}, p.config.VisibilityTimeout)
+		if err != nil {
+			log.Errorf("Failed to lease job: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(p.config.PollInterval):
+			}
+			continue
+		}
+
+		p.run(job)
+	}
+}
+
+// schedule runs the task now and then at its interval until the pool stops. A failed run is logged and the
+// task runs again at the next tick.
+func (p *Pool) schedule(task scheduledTask) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(task.interval)
+	defer ticker.Stop()
+	for {
+		if err := p.runTask(task); err != nil {
+			log.Errorf("Scheduled task %s failed: %v", task.name, err)
+		}
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runTask runs the task, turning a panic into a failure
+func (p *Pool) runTask(task scheduledTask) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v", r)
+		}
+	}()
+	return task.run(p.ctx)
+}
+
+// run executes the handler of a leased job while a heartbeat keeps the lease alive, then records the outcome
+func (p *Pool) run(job *entity.Job) {
+	handler := p.handlers[job.Type]
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+
+	leaseLost := make(chan struct{})
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		p.heartbeat(ctx, job, cancel, leaseLost)
+	}()
+
+	err := p.handle(ctx, handler, job)
+	cancel()
+	<-heartbeatDone
+
+	select {
+	case <-leaseLost:
+		log.Warnf("Lost the lease of job %d (%s), leaving it to its new owner", job.ID, job.Type)
+		return
+	default:
+	}
+
+	switch {
+	case err == nil:
+		if err := p.jobService.Complete(job); err != nil {
+			log.Errorf("Failed to complete job %d: %v", job.ID, err)
+		}
+	case p.ctx.Err() != nil && errors.Is(err, context.Canceled):
+		// Interrupted by shutdown, not by a failure of the job itself
+		if err := p.jobService.Release(job); err != nil {
+			log.Errorf("Failed to release job %d: %v", job.ID, err)
+		}
+	default:
+		dead, failErr := p.jobService.Fail(job, err)
+		if failErr != nil {
+			log.Errorf("Failed to record failure of job %d: %v", job.ID, failErr)
+			return
+		}
+		if !dead {
+			log.Warnf("Job %d (%s) failed on attempt %d/%d: %v", job.ID, job.Type, job.Attempts, job.MaxAttempts, err)
+			return
+		}
+		log.Errorf("Job %d (%s) dead-lettered after attempt %d: %v", job.ID, job.Type, job.Attempts, err)
+		if deadLetterHandler, ok := handler.(DeadLetterHandler); ok {
+			deadLetterHandler.HandleDeadLetter(job, err)
+		}
+	}
+}
+
+// handle calls the handler, turning a panic into a job failure
+func (p *Pool) handle(ctx context.Context, handler Handler, job *entity.Job) (err error) {
+	if handler == nil {
+		return service.PermanentJobError(fmt.Errorf("no handler registered for job type %s", job.Type))
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job handler panicked: %v", r)
+		}
+	}()
+	return handler.Handle(ctx, job)
+}
+
+// heartbeat renews the lease at a third of the visibility timeout until ctx is done.
+// When the lease is lost it closes leaseLost and cancels the handler.
+func (p *Pool) heartbeat(ctx context.Context, job *entity.Job, cancel context.CancelFunc, leaseLost chan struct{}) {
+	ticker := time.NewTicker(p.config.VisibilityTimeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := p.jobService.Heartbeat(job, p.config.VisibilityTimeout)
+			if errors.Is(err, repo.ErrJobLeaseLost) {
+				close(leaseLost)
+				cancel()
+				return
+			}
+			if err != nil {
+				log.Warnf("Failed to renew the lease of job %d: %v", job.ID, err)
+			}
+		}
+	}
+}
+
+// newOwnerID identifies this process in lease_owner
+func newOwnerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "worker"
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}
@@ -0,0 +1,224 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"mlvt/internal/entity"
+	"mlvt/internal/repo"
+	"mlvt/internal/service"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testJobType entity.JobType = "test.job"
+
+func setupPool(t *testing.T) (*Pool, service.JobService) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	for _, migration := range []string{"0010_create_jobs_table", "0011_create_ml_workers_table"} {
+		schema, err := os.ReadFile("../../migration/" + migration + ".up.sql")
+		require.NoError(t, err)
+		_, err = db.Exec(string(schema))
+		require.NoError(t, err)
+	}
+
+	jobService := service.NewJobService(repo.NewJobRepo(db))
+	pool := NewPool(jobService, Config{Concurrency: 2, VisibilityTimeout: time.Minute, PollInterval: 10 * time.Millisecond})
+	return pool, jobService
+}
+
+func waitForStatus(t *testing.T, jobService service.JobService, jobID uint64, status entity.JobStatus) *entity.Job {
+	var job *entity.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = jobService.GetJob(jobID)
+		return err == nil && job.Status == status
+	}, 2*time.Second, 10*time.Millisecond)
+	return job
+}
+
+type deadLetterRecorder struct {
+	HandlerFunc
+	mu   sync.Mutex
+	dead []uint64
+}
+
+func (h *deadLetterRecorder) HandleDeadLetter(job *entity.Job, cause error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dead = append(h.dead, job.ID)
+}
+
+func TestPoolCompletesJobs(t *testing.T) {
+	pool, jobService := setupPool(t)
+
+	var mu sync.Mutex
+	seen := []string{}
+	pool.Register(testJobType, HandlerFunc(func(ctx context.Context, job *entity.Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, job.Payload)
+		return nil
+	}))
+
+	job, err := jobService.Enqueue(&entity.Job{Type: testJobType}, map[string]int{"n": 1})
+	require.NoError(t, err)
+
+	pool.Start()
+	done := waitForStatus(t, jobService, job.ID, entity.JobStatusSucceeded)
+	assert.NoError(t, pool.Shutdown(context.Background()))
+
+	assert.Equal(t, 1, done.Attempts)
+	assert.Equal(t, []string{`{"n":1}`}, seen)
+}
+
+func TestPoolDeadLettersPermanentFailures(t *testing.T) {
+	pool, jobService := setupPool(t)
+
+	handler := &deadLetterRecorder{HandlerFunc: func(ctx context.Context, job *entity.Job) error {
+		return service.PermanentJobError(errors.New("bad input"))
+	}}
+	pool.Register(testJobType, handler)
+
+	job, err := jobService.Enqueue(&entity.Job{Type: testJobType}, nil)
+	require.NoError(t, err)
+
+	pool.Start()
+	dead := waitForStatus(t, jobService, job.ID, entity.JobStatusDead)
+	assert.NoError(t, pool.Shutdown(context.Background()))
+
+	assert.Equal(t, "bad input", dead.LastError)
+	assert.Equal(t, []uint64{job.ID}, handler.dead)
+}
+
+func TestPoolRetriesFailures(t *testing.T) {
+	pool, jobService := setupPool(t)
+
+	pool.Register(testJobType, HandlerFunc(func(ctx context.Context, job *entity.Job) error {
+		panic("boom")
+	}))
+
+	job, err := jobService.Enqueue(&entity.Job{Type: testJobType}, nil)
+	require.NoError(t, err)
+
+	pool.Start()
+	retried := waitForStatus(t, jobService, job.ID, entity.JobStatusQueued)
+	require.Eventually(t, func() bool {
+		retried, _ = jobService.GetJob(job.ID)
+		return retried.Attempts == 1 && retried.Status == entity.JobStatusQueued
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.NoError(t, pool.Shutdown(context.Background()))
+
+	assert.Equal(t, "job handler panicked: boom", retried.LastError)
+	assert.True(t, retried.RunAt.After(time.Now()), "the retry is scheduled with a backoff")
+}
+
+func TestPoolShutdownReleasesRunningJobs(t *testing.T) {
+	pool, jobService := setupPool(t)
+
+	started := make(chan struct{})
+	pool.Register(testJobType, HandlerFunc(func(ctx context.Context, job *entity.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	job, err := jobService.Enqueue(&entity.Job{Type: testJobType}, nil)
+	require.NoError(t, err)
+
+	pool.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = pool.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	released, err := jobService.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.JobStatusQueued, released.Status)
+	assert.Equal(t, 0, released.Attempts)
+}
+
+func TestProcessVideoHandler(t *testing.T) {
+	videoService := new(service.MockVideoService)
+	handler := NewProcessVideoHandler(videoService)
+	job := &entity.Job{ID: 1, Type: entity.JobTypeProcessVideo, Payload: `{"video_id":7}`}
+
+	videoService.On("ProcessVideo", mock.Anything, uint64(7)).Return(service.ErrFileNotUploaded).Once()
+	err := handler.Handle(context.Background(), job)
+	assert.True(t, service.IsPermanentJobError(err))
+
+	videoService.On("ProcessVideo", mock.Anything, uint64(7)).Return(errors.New("storage unavailable")).Once()
+	err = handler.Handle(context.Background(), job)
+	assert.Error(t, err)
+	assert.False(t, service.IsPermanentJobError(err))
+
+	videoService.On("UpdateVideoStatus", uint64(7), entity.StatusFailed).Return(nil).Once()
+	handler.(DeadLetterHandler).HandleDeadLetter(job, err)
+
+	err = handler.Handle(context.Background(), &entity.Job{ID: 2, Payload: "not json"})
+	assert.True(t, service.IsPermanentJobError(err))
+
+	videoService.AssertExpectations(t)
+}
+
+func TestAdvancePipelineHandler(t *testing.T) {
+	pipelineService := new(service.MockPipelineService)
+	handler := NewAdvancePipelineHandler(pipelineService)
+
+	pipelineService.On("AdvancePipeline", uint64(3)).Return(&entity.Pipeline{ID: 3}, nil).Once()
+	assert.NoError(t, handler.Handle(context.Background(), &entity.Job{ID: 1, Payload: `{"pipeline_id":3}`}))
+
+	pipelineService.On("AdvancePipeline", uint64(4)).Return(nil, service.ErrPipelineNotFound).Once()
+	err := handler.Handle(context.Background(), &entity.Job{ID: 2, Payload: `{"pipeline_id":4}`})
+	assert.True(t, service.IsPermanentJobError(err))
+
+	pipelineService.On("AdvancePipeline", uint64(5)).Return(nil, errors.New("database is locked")).Once()
+	err = handler.Handle(context.Background(), &entity.Job{ID: 3, Payload: `{"pipeline_id":5}`})
+	assert.Error(t, err)
+	assert.False(t, service.IsPermanentJobError(err))
+
+	pipelineService.AssertExpectations(t)
+}
+
+func TestPoolRunsScheduledTasks(t *testing.T) {
+	pool, _ := setupPool(t)
+
+	runs := make(chan int, 10)
+	n := 0
+	pool.Every(10*time.Millisecond, "test", func(ctx context.Context) error {
+		n++
+		runs <- n
+		switch n {
+		case 1:
+			return errors.New("failed runs are retried at the next tick")
+		case 2:
+			panic("so are panics")
+		}
+		return nil
+	})
+
+	pool.Start()
+	for i := 1; i <= 3; i++ {
+		select {
+		case n := <-runs:
+			assert.Equal(t, i, n)
+		case <-time.After(time.Second):
+			t.Fatal("the task did not run")
+		}
+	}
+	assert.NoError(t, pool.Shutdown(context.Background()))
+}
@@ -0,0 +1,28 @@
+package worker
+
+import (
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/env"
+	"mlvt/internal/service"
+
+	"github.com/google/wire"
+)
+
+// ProviderSetWorker is providers.
+var ProviderSetWorker = wire.NewSet(
+	NewAppPool,
+)
+
+// NewAppPool creates the worker pool of the application with every job handler and scheduled task registered
+func NewAppPool(jobService service.JobService, videoService service.VideoService, pipelineService service.PipelineService,
+	subscriptionService service.SubscriptionService) *Pool {
+	pool := NewPool(jobService, Config{
+		Concurrency:       env.EnvConfig.WorkerConcurrency,
+		VisibilityTimeout: env.EnvConfig.JobVisibilityTimeout,
+		PollInterval:      env.EnvConfig.JobPollInterval,
+	})
+	pool.Register(entity.JobTypeProcessVideo, NewProcessVideoHandler(videoService))
+	pool.Register(entity.JobTypeAdvancePipeline, NewAdvancePipelineHandler(pipelineService))
+	pool.Every(env.EnvConfig.SubscriptionCheckEvery, "expire subscriptions", NewExpireSubscriptionsTask(subscriptionService))
+	return pool
+}
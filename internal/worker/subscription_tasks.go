@@ -0,0 +1,19 @@
+package worker
+
+import (
+	"context"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/service"
+	"time"
+)
+
+// NewExpireSubscriptionsTask returns the task that drops users whose subscription ended unpaid back to free
+func NewExpireSubscriptionsTask(subscriptionService service.SubscriptionService) Task {
+	return func(ctx context.Context) error {
+		expired, err := subscriptionService.ExpireSubscriptions(time.Now())
+		if expired > 0 {
+			log.Infof("Expired %d subscriptions", expired)
+		}
+		return err
+	}
+}
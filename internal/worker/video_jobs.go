@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mlvt/internal/entity"
+	"mlvt/internal/infra/zap-logging/log"
+	"mlvt/internal/service"
+)
+
+// processVideoHandler runs JobTypeProcessVideo jobs and marks the video failed once its job is dead-lettered
+type processVideoHandler struct {
+	videoService service.VideoService
+}
+
+func NewProcessVideoHandler(videoService service.VideoService) Handler {
+	return &processVideoHandler{videoService: videoService}
+}
+
+func (h *processVideoHandler) Handle(ctx context.Context, job *entity.Job) error {
+	payload, err := decodeProcessVideoPayload(job)
+	if err != nil {
+		return err
+	}
+
+	err = h.videoService.ProcessVideo(ctx, payload.VideoID)
+	if errors.Is(err, service.ErrVideoNotFound) || errors.Is(err, service.ErrFileNotUploaded) || errors.Is(err, service.ErrFileChanged) {
+		return service.PermanentJobError(err)
+	}
+	return err
+}
+
+func (h *processVideoHandler) HandleDeadLetter(job *entity.Job, cause error) {
+	payload, err := decodeProcessVideoPayload(job)
+	if err != nil || errors.Is(cause, service.ErrVideoNotFound) {
+		return
+	}
+	if err := h.videoService.UpdateVideoStatus(payload.VideoID, entity.StatusFailed); err != nil {
+		log.Errorf("Failed to mark video %d as failed: %v", payload.VideoID, err)
+	}
+}
+
+func decodeProcessVideoPayload(job *entity.Job) (*entity.ProcessVideoPayload, error) {
+	var payload entity.ProcessVideoPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return nil, service.PermanentJobError(fmt.Errorf("invalid payload of job %d: %v", job.ID, err))
+	}
+	return &payload, nil
+}
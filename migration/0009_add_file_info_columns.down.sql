@@ -0,0 +1,13 @@
+ALTER TABLE transcriptions DROP COLUMN status;
+ALTER TABLE transcriptions DROP COLUMN content_type;
+ALTER TABLE transcriptions DROP COLUMN etag;
+ALTER TABLE transcriptions DROP COLUMN file_size;
+
+ALTER TABLE audios DROP COLUMN status;
+ALTER TABLE audios DROP COLUMN content_type;
+ALTER TABLE audios DROP COLUMN etag;
+ALTER TABLE audios DROP COLUMN file_size;
+
+ALTER TABLE videos DROP COLUMN content_type;
+ALTER TABLE videos DROP COLUMN etag;
+ALTER TABLE videos DROP COLUMN file_size;
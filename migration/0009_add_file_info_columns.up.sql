@@ -0,0 +1,13 @@
+ALTER TABLE videos ADD COLUMN file_size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE videos ADD COLUMN etag TEXT NOT NULL DEFAULT '';
+ALTER TABLE videos ADD COLUMN content_type TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE audios ADD COLUMN file_size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE audios ADD COLUMN etag TEXT NOT NULL DEFAULT '';
+ALTER TABLE audios ADD COLUMN content_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE audios ADD COLUMN status TEXT NOT NULL DEFAULT 'ready';
+
+ALTER TABLE transcriptions ADD COLUMN file_size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transcriptions ADD COLUMN etag TEXT NOT NULL DEFAULT '';
+ALTER TABLE transcriptions ADD COLUMN content_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE transcriptions ADD COLUMN status TEXT NOT NULL DEFAULT 'ready';
@@ -0,0 +1,5 @@
+ALTER TABLE jobs DROP COLUMN progress;
+ALTER TABLE jobs DROP COLUMN target_lang;
+ALTER TABLE jobs DROP COLUMN source_lang;
+
+DROP TABLE IF EXISTS ml_workers;
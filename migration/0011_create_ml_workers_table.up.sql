@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS ml_workers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    task_types TEXT NOT NULL DEFAULT '[]',
+    languages TEXT NOT NULL DEFAULT '[]',
+    last_seen_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Languages a job needs from the worker leasing it, empty when any worker can take it
+ALTER TABLE jobs ADD COLUMN source_lang TEXT NOT NULL DEFAULT '';
+ALTER TABLE jobs ADD COLUMN target_lang TEXT NOT NULL DEFAULT '';
+-- Progress in percent reported by the worker holding the lease
+ALTER TABLE jobs ADD COLUMN progress INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_pipelines_video_id;
+DROP TABLE IF EXISTS pipeline_steps;
+DROP TABLE IF EXISTS pipelines;
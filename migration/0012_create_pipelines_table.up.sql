@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS pipelines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    video_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    source_lang TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'running',
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Steps reference the step they depend on by name, unique within the pipeline (e.g., "mt:vi")
+CREATE TABLE IF NOT EXISTS pipeline_steps (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    pipeline_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    source_lang TEXT NOT NULL DEFAULT '',
+    target_lang TEXT NOT NULL DEFAULT '',
+    depends_on TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending',
+    job_id INTEGER NOT NULL DEFAULT 0,
+    input_transcription_id INTEGER NOT NULL DEFAULT 0,
+    input_audio_id INTEGER NOT NULL DEFAULT 0,
+    output_transcription_id INTEGER NOT NULL DEFAULT 0,
+    output_audio_id INTEGER NOT NULL DEFAULT 0,
+    output_folder TEXT NOT NULL DEFAULT '',
+    output_file_name TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (pipeline_id, name),
+    FOREIGN KEY (pipeline_id) REFERENCES pipelines(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_pipelines_video_id ON pipelines (video_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_transcription_segments_start;
+DROP TABLE IF EXISTS transcription_segments;
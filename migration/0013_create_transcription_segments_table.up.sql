@@ -0,0 +1,15 @@
+-- Time-coded segments of a transcription; the text of the transcription is derived from them
+CREATE TABLE IF NOT EXISTS transcription_segments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    transcription_id INTEGER NOT NULL,
+    start_ms INTEGER NOT NULL,
+    end_ms INTEGER NOT NULL,
+    text TEXT NOT NULL,
+    speaker TEXT NOT NULL DEFAULT '',
+    confidence REAL NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (transcription_id) REFERENCES transcriptions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_transcription_segments_start ON transcription_segments (transcription_id, start_ms);
@@ -0,0 +1,15 @@
+-- Every edit of the segments of a transcription stores a revision with its author, the segments it changed
+-- and a snapshot of all segments after the edit, so older revisions can be compared and restored
+CREATE TABLE IF NOT EXISTS transcription_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    transcription_id INTEGER NOT NULL,
+    number INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    restored_from INTEGER NOT NULL DEFAULT 0,
+    changes TEXT NOT NULL DEFAULT '[]',
+    segments TEXT NOT NULL DEFAULT '[]',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (transcription_id, number),
+    FOREIGN KEY (transcription_id) REFERENCES transcriptions(id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+-- Set by admins to force a user to change their password before using the API again
+ALTER TABLE users ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
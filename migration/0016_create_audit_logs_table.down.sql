@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_audit_logs_target_user_id;
+DROP TABLE IF EXISTS audit_logs;
@@ -0,0 +1,2 @@
+-- Access and refresh tokens carry the token version they were issued with; bumping it revokes them all
+ALTER TABLE users ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0;
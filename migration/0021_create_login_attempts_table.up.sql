@@ -0,0 +1,10 @@
+-- Failed logins counted per email address ("email:...") and per client IP ("ip:...").
+-- Rows without a failure for longer than the counting window are deleted as new failures come in.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    attempt_key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at DATETIME NOT NULL,
+    locked_until DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_last_failure_at ON login_attempts (last_failure_at);
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_transcriptions_org_id;
+DROP INDEX IF EXISTS idx_audios_org_id;
+DROP INDEX IF EXISTS idx_videos_org_id;
+
+ALTER TABLE transcriptions DROP COLUMN org_id;
+ALTER TABLE audios DROP COLUMN org_id;
+ALTER TABLE videos DROP COLUMN org_id;
+
+DROP INDEX IF EXISTS idx_organization_invites_org_id;
+DROP TABLE IF EXISTS organization_invites;
+DROP INDEX IF EXISTS idx_organization_members_user_id;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
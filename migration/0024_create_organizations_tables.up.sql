@@ -0,0 +1,46 @@
+-- Organizations let users share videos, audios and transcriptions. Members are owners, editors or viewers.
+CREATE TABLE IF NOT EXISTS organizations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    created_by INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    org_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (org_id, user_id),
+    FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members (user_id);
+
+-- Invitations are mailed to an email address; only the SHA-256 hash of the token is stored
+CREATE TABLE IF NOT EXISTS organization_invites (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    org_id INTEGER NOT NULL,
+    email TEXT NOT NULL,
+    role TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    invited_by INTEGER NOT NULL,
+    expires_at DATETIME NOT NULL,
+    accepted_at DATETIME,
+    revoked_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_invites_org_id ON organization_invites (org_id);
+
+-- Resources without an organization belong to their user alone
+ALTER TABLE videos ADD COLUMN org_id INTEGER REFERENCES organizations(id) ON DELETE SET NULL;
+ALTER TABLE audios ADD COLUMN org_id INTEGER REFERENCES organizations(id) ON DELETE SET NULL;
+ALTER TABLE transcriptions ADD COLUMN org_id INTEGER REFERENCES organizations(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_videos_org_id ON videos (org_id);
+CREATE INDEX IF NOT EXISTS idx_audios_org_id ON audios (org_id);
+CREATE INDEX IF NOT EXISTS idx_transcriptions_org_id ON transcriptions (org_id);
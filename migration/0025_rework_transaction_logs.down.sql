@@ -0,0 +1,16 @@
+CREATE TABLE transaction_logs_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id TEXT NOT NULL UNIQUE,
+    payment_method TEXT NOT NULL,
+    action TEXT NOT NULL,
+    status TEXT NOT NULL,
+    details TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Only the first row of each order fits the old table
+INSERT OR IGNORE INTO transaction_logs_old (id, order_id, payment_method, action, status, details, created_at)
+SELECT id, order_id, payment_method, action, status, details, created_at FROM transaction_logs ORDER BY id;
+
+DROP TABLE transaction_logs;
+ALTER TABLE transaction_logs_old RENAME TO transaction_logs;
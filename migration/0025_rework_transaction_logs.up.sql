@@ -0,0 +1,21 @@
+-- An order gets a log row for every payment event, so order_id is no longer unique.
+-- reference identifies an event from the gateway, e.g. a MoMo notification, so retried events are logged once.
+CREATE TABLE transaction_logs_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id TEXT NOT NULL,
+    payment_method TEXT NOT NULL,
+    action TEXT NOT NULL,
+    status TEXT NOT NULL,
+    details TEXT,
+    reference TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO transaction_logs_new (id, order_id, payment_method, action, status, details, created_at)
+SELECT id, order_id, payment_method, action, status, details, created_at FROM transaction_logs;
+
+DROP TABLE transaction_logs;
+ALTER TABLE transaction_logs_new RENAME TO transaction_logs;
+
+CREATE INDEX IF NOT EXISTS idx_transaction_logs_order_id ON transaction_logs (order_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transaction_logs_reference ON transaction_logs (payment_method, reference);
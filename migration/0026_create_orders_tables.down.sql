@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_orders_user_id;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS plans;
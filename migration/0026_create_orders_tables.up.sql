@@ -0,0 +1,37 @@
+-- Plans are what users pay for. Amounts are in minor units of the currency; VND has none, so they are whole VND.
+CREATE TABLE IF NOT EXISTS plans (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    amount INTEGER NOT NULL,
+    currency TEXT NOT NULL,
+    active INTEGER NOT NULL DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO plans (code, name, amount, currency) VALUES
+    ('premium_monthly', 'Premium (monthly)', 99000, 'VND'),
+    ('premium_yearly', 'Premium (yearly)', 990000, 'VND');
+
+-- An order is one payment of a plan by a user. reference is the order ID given to the payment provider and
+-- transaction_id the provider's ID of the payment, needed to refund it.
+CREATE TABLE IF NOT EXISTS orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    reference TEXT NOT NULL UNIQUE,
+    user_id INTEGER NOT NULL,
+    plan_id INTEGER NOT NULL,
+    amount INTEGER NOT NULL,
+    currency TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    transaction_id TEXT NOT NULL DEFAULT '',
+    refunded_amount INTEGER NOT NULL DEFAULT 0,
+    paid_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (plan_id) REFERENCES plans(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders (user_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_subscription_periods_subscription_id;
+DROP TABLE IF EXISTS subscription_periods;
+DROP INDEX IF EXISTS idx_subscriptions_status_period_end;
+DROP TABLE IF EXISTS subscriptions;
+ALTER TABLE plans DROP COLUMN max_video_minutes;
+ALTER TABLE plans DROP COLUMN max_videos;
+ALTER TABLE plans DROP COLUMN billing_period;
@@ -0,0 +1,41 @@
+-- Plans are billed monthly or yearly and grant quotas while subscribed; 0 means no limit.
+ALTER TABLE plans ADD COLUMN billing_period TEXT NOT NULL DEFAULT 'monthly';
+ALTER TABLE plans ADD COLUMN max_videos INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE plans ADD COLUMN max_video_minutes INTEGER NOT NULL DEFAULT 0;
+
+UPDATE plans SET max_videos = 100, max_video_minutes = 120 WHERE code = 'premium_monthly';
+UPDATE plans SET billing_period = 'yearly', max_videos = 100, max_video_minutes = 120 WHERE code = 'premium_yearly';
+
+-- A user has at most one subscription, renewed by paying orders of a plan. Premium is kept until
+-- current_period_end, and for the grace period after it while past_due.
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL UNIQUE,
+    plan_id INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    current_period_start DATETIME NOT NULL,
+    current_period_end DATETIME NOT NULL,
+    canceled_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (plan_id) REFERENCES plans(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_status_period_end ON subscriptions (status, current_period_end);
+
+-- Every paid order adds one period to a subscription, and only one.
+CREATE TABLE IF NOT EXISTS subscription_periods (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    subscription_id INTEGER NOT NULL,
+    order_id INTEGER NOT NULL UNIQUE,
+    plan_id INTEGER NOT NULL,
+    period_start DATETIME NOT NULL,
+    period_end DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE CASCADE,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (plan_id) REFERENCES plans(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_periods_subscription_id ON subscription_periods (subscription_id);